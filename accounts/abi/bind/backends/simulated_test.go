@@ -0,0 +1,162 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backends
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddr    = crypto.PubkeyToAddress(testKey.PublicKey)
+	testBalance = big.NewInt(1000000000)
+)
+
+// sendTestTx sends a value transfer from the test account with the given nonce.
+func sendTestTx(t *testing.T, sim *SimulatedBackend, nonce uint64, value int64) *types.Transaction {
+	tx, err := types.SignTx(types.NewTransaction(nonce, common.Address{0x01}, big.NewInt(value), big.NewInt(21000), big.NewInt(1), nil), types.HomesteadSigner{}, testKey)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if err := sim.SendTransaction(context.Background(), tx); err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+	return tx
+}
+
+// Tests that the chain can be reverted to snapshots, discarding the blocks and
+// snapshots created since.
+func TestSimulatedSnapshot(t *testing.T) {
+	sim := NewSimulatedBackend(core.GenesisAlloc{testAddr: {Balance: testBalance}})
+
+	sendTestTx(t, sim, 0, 1)
+	sim.Commit()
+	first := sim.Snapshot()
+
+	sendTestTx(t, sim, 1, 2)
+	sim.Commit()
+	second := sim.Snapshot()
+
+	sendTestTx(t, sim, 2, 4)
+	sim.Commit()
+
+	if err := sim.RevertToSnapshot(second); err != nil {
+		t.Fatalf("failed to revert to second snapshot: %v", err)
+	}
+	if balance, _ := sim.BalanceAt(context.Background(), common.Address{0x01}, nil); balance.Int64() != 3 {
+		t.Errorf("balance mismatch after second revert: have %v, want 3", balance)
+	}
+	if err := sim.RevertToSnapshot(first); err != nil {
+		t.Fatalf("failed to revert to first snapshot: %v", err)
+	}
+	if balance, _ := sim.BalanceAt(context.Background(), common.Address{0x01}, nil); balance.Int64() != 1 {
+		t.Errorf("balance mismatch after first revert: have %v, want 1", balance)
+	}
+	if err := sim.RevertToSnapshot(second); err != errUnknownSnapshot {
+		t.Errorf("reverted snapshot error mismatch: have %v, want %v", err, errUnknownSnapshot)
+	}
+	// The chain continues from the reverted head
+	if nonce, _ := sim.PendingNonceAt(context.Background(), testAddr); nonce != 1 {
+		t.Errorf("pending nonce mismatch: have %d, want 1", nonce)
+	}
+	sendTestTx(t, sim, 1, 8)
+	sim.Commit()
+	if balance, _ := sim.BalanceAt(context.Background(), common.Address{0x01}, nil); balance.Int64() != 9 {
+		t.Errorf("balance mismatch after revert and commit: have %v, want 9", balance)
+	}
+}
+
+// Tests that committing blocks on a fork reorganises the chain once it becomes
+// the heavier one, notifying about the new heads.
+func TestSimulatedFork(t *testing.T) {
+	sim := NewSimulatedBackend(core.GenesisAlloc{testAddr: {Balance: testBalance}})
+	parent := sim.blockchain.CurrentBlock()
+
+	heads := make(chan *types.Header, 16)
+	sub, _ := sim.SubscribeNewHead(context.Background(), heads)
+	defer sub.Unsubscribe()
+
+	tx := sendTestTx(t, sim, 0, 1)
+	sim.Commit()
+	snap := sim.Snapshot()
+
+	// Build a longer fork not including the transaction
+	if err := sim.Fork(context.Background(), common.Hash{0xff}); err != errUnknownParent {
+		t.Fatalf("unknown parent error mismatch: have %v, want %v", err, errUnknownParent)
+	}
+	if err := sim.Fork(context.Background(), parent.Hash()); err != nil {
+		t.Fatalf("failed to fork: %v", err)
+	}
+	sim.Commit()
+	sim.Commit()
+	if head := sim.blockchain.CurrentBlock(); head.NumberU64() != 2 {
+		t.Fatalf("fork not canonical: head #%d", head.NumberU64())
+	}
+	if receipt, _ := sim.TransactionReceipt(context.Background(), tx.Hash()); receipt != nil {
+		t.Errorf("transaction not reorged out")
+	}
+	if balance, _ := sim.BalanceAt(context.Background(), common.Address{0x01}, nil); balance.Sign() != 0 {
+		t.Errorf("balance mismatch after reorg: have %v, want 0", balance)
+	}
+	if err := sim.RevertToSnapshot(snap); err != errSnapshotReorged {
+		t.Errorf("reorged snapshot error mismatch: have %v, want %v", err, errSnapshotReorged)
+	}
+	// The reorged head should have been announced (an equally heavy fork block
+	// may or may not have become the head in between)
+	for {
+		select {
+		case head := <-heads:
+			if head.Number.Uint64() == 2 {
+				return
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("reorged head not announced")
+		}
+	}
+}
+
+// Tests that pending transactions are announced as soon as they are sent.
+func TestSimulatedPendingSubscription(t *testing.T) {
+	sim := NewSimulatedBackend(core.GenesisAlloc{testAddr: {Balance: testBalance}})
+
+	txs := make(chan *types.Transaction)
+	sub, _ := sim.SubscribePendingTransactions(context.Background(), txs)
+	defer sub.Unsubscribe()
+
+	done := make(chan *types.Transaction)
+	go func() { done <- sendTestTx(t, sim, 0, 1) }()
+
+	select {
+	case tx := <-txs:
+		if nonce, _ := sim.PendingNonceAt(context.Background(), testAddr); nonce != 1 {
+			t.Errorf("pending nonce mismatch: have %d, want 1", nonce)
+		}
+		if sent := <-done; tx.Hash() != sent.Hash() {
+			t.Errorf("announced transaction mismatch: have %x, want %x", tx.Hash(), sent.Hash())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("pending transaction not announced")
+	}
+}
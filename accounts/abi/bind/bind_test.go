@@ -447,6 +447,101 @@ var bindTests = []struct {
 			}
 		`,
 	},
+	// Tests that events can be filtered, watched and unpacked. The bytecode is hand
+	// assembled, raising the event with the call arguments regardless of the method.
+	{
+		`Eventer`,
+		`
+			contract Eventer {
+				event SimpleEvent(address indexed Addr, bytes32 indexed Id, bool indexed Flag, uint Value);
+
+				function raiseSimpleEvent(address addr, bytes32 id, bool flag, uint value) {
+					SimpleEvent(addr, id, flag, value);
+				}
+			}
+		`, `6036600c60003960366000f36064356000526044356024356004357f1f097de4289df643bd9c11011cc61367aa12983405c021056e706eb5ba1250c860206000a400`,
+		`[{"constant":false,"inputs":[{"name":"addr","type":"address"},{"name":"id","type":"bytes32"},{"name":"flag","type":"bool"},{"name":"value","type":"uint256"}],"name":"raiseSimpleEvent","outputs":[],"payable":false,"type":"function"},{"anonymous":false,"inputs":[{"indexed":true,"name":"Addr","type":"address"},{"indexed":true,"name":"Id","type":"bytes32"},{"indexed":true,"name":"Flag","type":"bool"},{"indexed":false,"name":"Value","type":"uint256"}],"name":"SimpleEvent","type":"event"}]`,
+		`
+			// Generate a new random account and a funded simulator
+			key, _ := crypto.GenerateKey()
+			auth := bind.NewKeyedTransactor(key)
+			sim := backends.NewSimulatedBackend(core.GenesisAlloc{auth.From: {Balance: big.NewInt(10000000000)}})
+
+			// Deploy an eventer contract
+			_, _, eventer, err := DeployEventer(auth, sim)
+			if err != nil {
+				t.Fatalf("Failed to deploy eventer contract: %v", err)
+			}
+			sim.Commit()
+
+			// Inject a few events into the contract, gradually committing them
+			for i := 0; i < 3; i++ {
+				if _, err := eventer.RaiseSimpleEvent(auth, common.Address{byte(i)}, [32]byte{byte(i)}, i%2 == 0, big.NewInt(int64(10*i))); err != nil {
+					t.Fatalf("block %d: failed to raise simple event: %v", i, err)
+				}
+				sim.Commit()
+			}
+			// Test filtering for the events matching the indexed flag
+			sit, err := eventer.FilterSimpleEvent(nil, nil, nil, []bool{true})
+			if err != nil {
+				t.Fatalf("failed to filter for simple events: %v", err)
+			}
+			defer sit.Close()
+
+			seen := 0
+			for sit.Next() {
+				i := 2 * seen
+				if have, want := sit.Event.Addr, (common.Address{byte(i)}); have != want {
+					t.Errorf("simple log %d: address mismatch: have %x, want %x", seen, have, want)
+				}
+				if have, want := sit.Event.Id, ([32]byte{byte(i)}); have != want {
+					t.Errorf("simple log %d: id mismatch: have %x, want %x", seen, have, want)
+				}
+				if !sit.Event.Flag {
+					t.Errorf("simple log %d: flag mismatch: have false, want true", seen)
+				}
+				if have, want := sit.Event.Value.Int64(), int64(10*i); have != want {
+					t.Errorf("simple log %d: value mismatch: have %d, want %d", seen, have, want)
+				}
+				seen++
+			}
+			if err := sit.Error(); err != nil {
+				t.Fatalf("simple event iteration failed: %v", err)
+			}
+			if seen != 2 {
+				t.Fatalf("simple event count mismatch: have %d, want %d", seen, 2)
+			}
+			// Test subscribing to an event and raising it afterwards
+			ch := make(chan *EventerSimpleEvent, 16)
+			sub, err := eventer.WatchSimpleEvent(nil, ch, []common.Address{{0xff}}, nil, nil)
+			if err != nil {
+				t.Fatalf("failed to subscribe to simple events: %v", err)
+			}
+			defer sub.Unsubscribe()
+
+			if _, err := eventer.RaiseSimpleEvent(auth, common.Address{0xfe}, [32]byte{}, false, big.NewInt(1)); err != nil {
+				t.Fatalf("failed to raise filtered out event: %v", err)
+			}
+			if _, err := eventer.RaiseSimpleEvent(auth, common.Address{0xff}, [32]byte{0xff}, true, big.NewInt(255)); err != nil {
+				t.Fatalf("failed to raise watched event: %v", err)
+			}
+			sim.Commit()
+
+			select {
+			case event := <-ch:
+				if event.Addr != (common.Address{0xff}) || event.Id != ([32]byte{0xff}) || !event.Flag || event.Value.Int64() != 255 {
+					t.Fatalf("watched event mismatch: %+v", event)
+				}
+			case <-time.After(250 * time.Millisecond):
+				t.Fatalf("watched event timed out")
+			}
+			select {
+			case event := <-ch:
+				t.Fatalf("unexpected event delivered: %+v", event)
+			case <-time.After(50 * time.Millisecond):
+			}
+		`,
+	},
 }
 
 // Tests that packages generated by the binder can be successfully compiled and
@@ -0,0 +1,158 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bind
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// makeTopics converts a filter query argument list into a filter topic set. Each
+// query entry lists the alternative values accepted in the corresponding topic
+// position, an empty entry matches any value.
+func makeTopics(query ...[]interface{}) ([][]common.Hash, error) {
+	topics := make([][]common.Hash, len(query))
+	for i, filter := range query {
+		for _, rule := range filter {
+			topic, err := makeTopic(rule)
+			if err != nil {
+				return nil, err
+			}
+			topics[i] = append(topics[i], topic)
+		}
+	}
+	return topics, nil
+}
+
+// makeTopic encodes a single indexed argument value as a topic. Value types are
+// stored as their ABI encoding, while dynamic types (strings and byte slices) by
+// the hash of their contents.
+func makeTopic(rule interface{}) (common.Hash, error) {
+	var topic common.Hash
+
+	switch rule := rule.(type) {
+	case common.Hash:
+		copy(topic[:], rule[:])
+	case common.Address:
+		copy(topic[common.HashLength-common.AddressLength:], rule[:])
+	case *big.Int:
+		copy(topic[:], math.PaddedBigBytes(math.U256(new(big.Int).Set(rule)), common.HashLength))
+	case bool:
+		if rule {
+			topic[common.HashLength-1] = 1
+		}
+	case int8:
+		return makeTopic(big.NewInt(int64(rule)))
+	case int16:
+		return makeTopic(big.NewInt(int64(rule)))
+	case int32:
+		return makeTopic(big.NewInt(int64(rule)))
+	case int64:
+		return makeTopic(big.NewInt(rule))
+	case uint8:
+		return makeTopic(new(big.Int).SetUint64(uint64(rule)))
+	case uint16:
+		return makeTopic(new(big.Int).SetUint64(uint64(rule)))
+	case uint32:
+		return makeTopic(new(big.Int).SetUint64(uint64(rule)))
+	case uint64:
+		return makeTopic(new(big.Int).SetUint64(rule))
+	case string:
+		topic = crypto.Keccak256Hash([]byte(rule))
+	case []byte:
+		topic = crypto.Keccak256Hash(rule)
+	default:
+		// Fixed size byte arrays (bytesN) are left aligned
+		val := reflect.ValueOf(rule)
+		if val.Kind() != reflect.Array || val.Type().Elem().Kind() != reflect.Uint8 || val.Len() > common.HashLength {
+			return topic, fmt.Errorf("unsupported indexed type: %T", rule)
+		}
+		reflect.Copy(reflect.ValueOf(topic[:val.Len()]), val)
+	}
+	return topic, nil
+}
+
+// parseTopics unpacks the topics of a log into the fields of the out struct named
+// after the given indexed arguments. Dynamic types can't be recovered from their
+// hashes, so those fields are set to the topic itself.
+func parseTopics(out interface{}, fields []abi.Argument, topics []common.Hash) error {
+	if len(fields) != len(topics) {
+		return errors.New("topic/field count mismatch")
+	}
+	value := reflect.ValueOf(out)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot unpack topics into %T", out)
+	}
+	for i, arg := range fields {
+		field := value.Elem().FieldByName(arg.Name)
+		if !field.IsValid() {
+			return fmt.Errorf("field %s missing from %T", arg.Name, out)
+		}
+		topic := topics[i]
+
+		var result reflect.Value
+		switch arg.Type.T {
+		case abi.BoolTy:
+			result = reflect.ValueOf(topic[common.HashLength-1] == 1)
+		case abi.AddressTy:
+			result = reflect.ValueOf(common.BytesToAddress(topic[:]))
+		case abi.IntTy, abi.UintTy:
+			num := new(big.Int).SetBytes(topic[:])
+			if arg.Type.T == abi.IntTy {
+				num = math.S256(num)
+			}
+			switch arg.Type.Kind {
+			case reflect.Int8:
+				result = reflect.ValueOf(int8(num.Int64()))
+			case reflect.Int16:
+				result = reflect.ValueOf(int16(num.Int64()))
+			case reflect.Int32:
+				result = reflect.ValueOf(int32(num.Int64()))
+			case reflect.Int64:
+				result = reflect.ValueOf(num.Int64())
+			case reflect.Uint8:
+				result = reflect.ValueOf(uint8(num.Uint64()))
+			case reflect.Uint16:
+				result = reflect.ValueOf(uint16(num.Uint64()))
+			case reflect.Uint32:
+				result = reflect.ValueOf(uint32(num.Uint64()))
+			case reflect.Uint64:
+				result = reflect.ValueOf(num.Uint64())
+			default:
+				result = reflect.ValueOf(num)
+			}
+		case abi.FixedBytesTy:
+			array := reflect.New(arg.Type.Type).Elem()
+			reflect.Copy(array, reflect.ValueOf(topic[:arg.Type.Size]))
+			result = array
+		default:
+			result = reflect.ValueOf(topic)
+		}
+		if !result.Type().AssignableTo(field.Type()) {
+			return fmt.Errorf("cannot unpack topic of %s into field %s of type %v", arg.Type, arg.Name, field.Type())
+		}
+		field.Set(result)
+	}
+	return nil
+}
@@ -21,21 +21,75 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/log"
 )
 
+// WaitOpts is the collection of options to fine tune waiting for a transaction
+// to be mined.
+type WaitOpts struct {
+	Interval    time.Duration // Delay between receipt queries (default 1 second)
+	MaxInterval time.Duration // Maximum delay the queries back off to (default no backoff)
+}
+
+// headSubscriber is implemented by backends that can notify about new chain heads,
+// allowing receipts to be queried as soon as new blocks arrive.
+type headSubscriber interface {
+	SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error)
+}
+
 // WaitMined waits for tx to be mined on the blockchain.
 // It stops waiting when the context is canceled.
+//
+// If the transaction was mined but its execution failed, the receipt is returned
+// along with ErrTransactionFailed.
 func WaitMined(ctx context.Context, b DeployBackend, tx *types.Transaction) (*types.Receipt, error) {
-	queryTicker := time.NewTicker(time.Second)
-	defer queryTicker.Stop()
+	return WaitMinedWithOpts(ctx, b, tx, nil)
+}
 
+// WaitMinedWithOpts waits for tx to be mined on the blockchain, querying for its
+// receipt with the delays configured in opts (nil for the defaults). If the backend
+// supports head subscriptions, the receipt is also queried on every new block.
+func WaitMinedWithOpts(ctx context.Context, b DeployBackend, tx *types.Transaction, opts *WaitOpts) (*types.Receipt, error) {
+	interval, maxInterval := time.Second, time.Duration(0)
+	if opts != nil {
+		if opts.Interval > 0 {
+			interval = opts.Interval
+		}
+		maxInterval = opts.MaxInterval
+	}
+	if maxInterval < interval {
+		maxInterval = interval
+	}
 	logger := log.New("hash", tx.Hash())
+
+	// Subscribe to new chain heads if possible, polling only otherwise
+	var (
+		heads   chan *types.Header
+		headErr <-chan error
+	)
+	if hs, ok := b.(headSubscriber); ok {
+		heads = make(chan *types.Header, 1)
+		if sub, err := hs.SubscribeNewHead(ctx, heads); err == nil {
+			defer sub.Unsubscribe()
+			headErr = sub.Err()
+		} else {
+			logger.Trace("Head subscription failed, polling", "err", err)
+			heads = nil
+		}
+	}
+	queryTimer := time.NewTimer(interval)
+	defer queryTimer.Stop()
+
 	for {
 		receipt, err := b.TransactionReceipt(ctx, tx.Hash())
 		if receipt != nil {
+			if receipt.PostState == nil && receipt.Status == types.ReceiptStatusFailed {
+				logger.Debug("Transaction execution failed", "gas", receipt.GasUsed)
+				return receipt, ErrTransactionFailed
+			}
 			return receipt, nil
 		}
 		if err != nil {
@@ -47,19 +101,37 @@ func WaitMined(ctx context.Context, b DeployBackend, tx *types.Transaction) (*ty
 		select {
 		case <-ctx.Done():
 			return nil, ctx.Err()
-		case <-queryTicker.C:
+		case <-heads:
+		case err := <-headErr:
+			logger.Trace("Head subscription dropped, polling", "err", err)
+			heads, headErr = nil, nil
+			continue
+		case <-queryTimer.C:
+			if interval *= 2; interval > maxInterval {
+				interval = maxInterval
+			}
 		}
+		queryTimer.Reset(interval)
 	}
 }
 
 // WaitDeployed waits for a contract deployment transaction and returns the on-chain
 // contract address when it is mined. It stops waiting when ctx is canceled.
 func WaitDeployed(ctx context.Context, b DeployBackend, tx *types.Transaction) (common.Address, error) {
+	return WaitDeployedWithOpts(ctx, b, tx, nil)
+}
+
+// WaitDeployedWithOpts waits for a contract deployment transaction like WaitDeployed,
+// querying for its receipt with the delays configured in opts.
+func WaitDeployedWithOpts(ctx context.Context, b DeployBackend, tx *types.Transaction, opts *WaitOpts) (common.Address, error) {
 	if tx.To() != nil {
 		return common.Address{}, fmt.Errorf("tx is not contract creation")
 	}
-	receipt, err := WaitMined(ctx, b, tx)
+	receipt, err := WaitMinedWithOpts(ctx, b, tx, opts)
 	if err != nil {
+		if receipt != nil {
+			return receipt.ContractAddress, err
+		}
 		return common.Address{}, err
 	}
 	if receipt.ContractAddress == (common.Address{}) {
@@ -49,6 +49,12 @@ var waitDeployedTests = map[string]struct {
 		wantErr:     bind.ErrNoCodeAfterDeploy,
 		wantAddress: common.HexToAddress("0x3a220f351252089d385b29beca14e27f204c296a"),
 	},
+	"reverted deploy": {
+		code:        `60006000fd`,
+		gas:         big.NewInt(300000),
+		wantErr:     bind.ErrTransactionFailed,
+		wantAddress: common.HexToAddress("0x3a220f351252089d385b29beca14e27f204c296a"),
+	},
 }
 
 func TestWaitDeployed(t *testing.T) {
@@ -90,3 +96,22 @@ func TestWaitDeployed(t *testing.T) {
 		}
 	}
 }
+
+// Tests that waiting for a transaction never mined backs off and terminates when
+// the context is canceled.
+func TestWaitMinedCanceled(t *testing.T) {
+	backend := backends.NewSimulatedBackend(nil)
+
+	tx := types.NewContractCreation(0, big.NewInt(0), big.NewInt(300000), big.NewInt(1), nil)
+	tx, _ = types.SignTx(tx, types.HomesteadSigner{}, testKey)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	opts := &bind.WaitOpts{Interval: time.Millisecond, MaxInterval: 20 * time.Millisecond}
+	if receipt, err := bind.WaitMinedWithOpts(ctx, backend, tx, opts); err != context.DeadlineExceeded {
+		t.Errorf("error mismatch: have %v, want %v", err, context.DeadlineExceeded)
+	} else if receipt != nil {
+		t.Errorf("unexpected receipt: %v", receipt)
+	}
+}
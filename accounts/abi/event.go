@@ -34,6 +34,17 @@ type Event struct {
 	Inputs    []Argument
 }
 
+func (e Event) String() string {
+	inputs := make([]string, len(e.Inputs))
+	for i, input := range e.Inputs {
+		inputs[i] = fmt.Sprintf("%v %v", input.Name, input.Type)
+		if input.Indexed {
+			inputs[i] = fmt.Sprintf("%v indexed %v", input.Name, input.Type)
+		}
+	}
+	return fmt.Sprintf("event %v(%v)", e.Name, strings.Join(inputs, ", "))
+}
+
 // Id returns the canonical representation of the event's signature used by the
 // abi definition to identify event names and types.
 func (e Event) Id() common.Hash {
@@ -65,28 +76,34 @@ func (e Event) tupleUnpack(v interface{}, output []byte) error {
 		return fmt.Errorf("abi: cannot unmarshal tuple in to %v", typ)
 	}
 
-	j := 0
+	offset := 0
 	for i := 0; i < len(e.Inputs); i++ {
 		input := e.Inputs[i]
 		if input.Indexed {
-			// can't read, continue
+			// indexed arguments are stored in the topics, not the data
 			continue
-		} else if input.Type.T == ArrayTy {
-			// need to move this up because they read sequentially
-			j += input.Type.Size
 		}
-		marshalledValue, err := toGoType((i+j)*32, input.Type, output)
+		marshalledValue, err := toGoType(offset, input.Type, output)
 		if err != nil {
 			return err
 		}
+		if input.Type.T == ArrayTy {
+			offset += 32 * input.Type.Size
+		} else {
+			offset += 32
+		}
 		reflectValue := reflect.ValueOf(marshalledValue)
 
 		switch value.Kind() {
 		case reflect.Struct:
+			name := input.Name
+			if name == "" {
+				name = fmt.Sprintf("arg%d", i)
+			}
 			for j := 0; j < typ.NumField(); j++ {
 				field := typ.Field(j)
 				// TODO read tags: `abi:"fieldName"`
-				if field.Name == strings.ToUpper(e.Inputs[i].Name[:1])+e.Inputs[i].Name[1:] {
+				if field.Name == strings.ToUpper(name[:1])+name[1:] {
 					if err := set(value.Field(j), reflectValue, e.Inputs[i]); err != nil {
 						return err
 					}
@@ -120,12 +137,16 @@ func (e Event) singleUnpack(v interface{}, output []byte) error {
 		return fmt.Errorf("abi: Unpack(non-pointer %T)", v)
 	}
 
+	value := valueOf.Elem()
+
+	// events are usually unpacked into structs, regardless of their arity
+	if value.Kind() == reflect.Struct {
+		return e.tupleUnpack(v, output)
+	}
 	if e.Inputs[0].Indexed {
 		return fmt.Errorf("abi: attempting to unpack indexed variable into element.")
 	}
 
-	value := valueOf.Elem()
-
 	marshalledValue, err := toGoType(0, e.Inputs[0].Type, output)
 	if err != nil {
 		return err
@@ -17,6 +17,7 @@
 package abi
 
 import (
+	"math/big"
 	"strings"
 	"testing"
 
@@ -54,3 +55,41 @@ func TestEventId(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the data of events is unpacked into structs, skipping the indexed
+// arguments which are only available in the topics.
+func TestEventTupleUnpack(t *testing.T) {
+	definition := `[
+	{ "type" : "event", "name" : "transfer", "inputs": [{ "name" : "from", "type": "address", "indexed": true }, { "name": "value", "type": "uint256" }, { "name": "memo", "type": "string" }] },
+	{ "type" : "event", "name" : "deposit", "inputs": [{ "name" : "amount", "type": "uint256" }] }
+	]`
+	abi, err := JSON(strings.NewReader(definition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := common.Hex2Bytes("000000000000000000000000000000000000000000000000000000000000002a" +
+		"0000000000000000000000000000000000000000000000000000000000000040" +
+		"0000000000000000000000000000000000000000000000000000000000000002" +
+		"6869000000000000000000000000000000000000000000000000000000000000")
+
+	var transfer struct {
+		From  common.Address
+		Value *big.Int
+		Memo  string
+	}
+	if err := abi.Unpack(&transfer, "transfer", data); err != nil {
+		t.Fatalf("failed to unpack transfer: %v", err)
+	}
+	if transfer.Value.Int64() != 42 || transfer.Memo != "hi" || (transfer.From != common.Address{}) {
+		t.Errorf("transfer mismatch: have %+v, want value 42, memo hi and no sender", transfer)
+	}
+	var deposit struct {
+		Amount *big.Int
+	}
+	if err := abi.Unpack(&deposit, "deposit", data[:32]); err != nil {
+		t.Fatalf("failed to unpack deposit: %v", err)
+	}
+	if deposit.Amount.Int64() != 42 {
+		t.Errorf("deposit mismatch: have %v, want 42", deposit.Amount)
+	}
+}
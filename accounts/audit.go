@@ -0,0 +1,255 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package accounts
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Kinds of signing operations recorded in the audit log.
+const (
+	AuditTransaction = "transaction" // Transaction signature, recorded with the transaction hash
+	AuditHash        = "hash"        // Arbitrary hash signature, recorded with the signed digest
+)
+
+// ErrAuditTampered is returned if the entries of an audit log don't form an
+// unbroken authenticated chain ending at the persisted head, meaning the log was
+// modified, truncated or deleted outside of the node.
+var ErrAuditTampered = errors.New("audit log tampered with")
+
+// auditHead is the persisted head of the audit log, allowing truncation of the
+// log to be detected when it's opened.
+type auditHead struct {
+	Entries int         `json:"entries"` // Number of entries in the log
+	Head    common.Hash `json:"head"`    // MAC of the last line of the log
+}
+
+// AuditEntry is a single signing operation recorded in the audit log.
+type AuditEntry struct {
+	Time    time.Time      `json:"time"`    // Time of the signing operation
+	Account common.Address `json:"account"` // Account the signature was made with
+	Origin  string         `json:"origin"`  // Operation the signature was requested by
+	Kind    string         `json:"kind"`    // Kind of the signed data
+	Digest  common.Hash    `json:"digest"`  // Transaction hash or signed message digest
+	Parent  common.Hash    `json:"parent"`  // MAC of the previous log line, chaining the log
+}
+
+// AuditLog is an append-only log of the signing operations performed with the
+// managed accounts. Every line of the log is a JSON encoded entry containing the
+// MAC of the previous line, keyed with a secret held by the node, so the chain
+// can't be rewritten consistently without the key. The MAC of the last line is
+// persisted alongside the log, so truncating or deleting it is detected too.
+type AuditLog struct {
+	path    string      // Path of the log file
+	key     []byte      // Secret key authenticating the log lines
+	head    common.Hash // MAC of the last line of the log
+	entries int         // Number of entries in the log
+	lock    sync.Mutex
+}
+
+// OpenAuditLog opens the audit log at the given path, creating it if it doesn't
+// exist yet. The existing entries are authenticated with the given key, and the
+// chain must end at the head persisted by the last recorded entry.
+func OpenAuditLog(path string, key []byte) (*AuditLog, error) {
+	if len(key) == 0 {
+		return nil, errors.New("audit log needs a key")
+	}
+	log := &AuditLog{path: path, key: key}
+
+	entries, head, err := log.read(nil)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := log.loadHead()
+	if err != nil {
+		return nil, err
+	}
+	if stored.Entries != len(entries) || stored.Head != head {
+		// Tolerate a crash between appending an entry and persisting the head
+		if n := len(entries); n != stored.Entries+1 || entries[n-1].Parent != stored.Head {
+			return nil, ErrAuditTampered
+		}
+		if err := log.storeHead(&auditHead{Entries: len(entries), Head: head}); err != nil {
+			return nil, err
+		}
+	}
+	log.head, log.entries = head, len(entries)
+	return log, nil
+}
+
+// Record appends a signing operation to the audit log.
+func (l *AuditLog) Record(account common.Address, origin string, kind string, digest common.Hash) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	line, err := json.Marshal(&AuditEntry{
+		Time:    time.Now(),
+		Account: account,
+		Origin:  origin,
+		Kind:    kind,
+		Digest:  digest,
+		Parent:  l.head,
+	})
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	head := l.mac(line)
+	if err := l.storeHead(&auditHead{Entries: l.entries + 1, Head: head}); err != nil {
+		return err
+	}
+	l.head, l.entries = head, l.entries+1
+	return nil
+}
+
+// Entries returns the signing operations recorded in the audit log, optionally
+// filtered by account. The hash chain of the whole log is verified.
+func (l *AuditLog) Entries(account *common.Address) ([]*AuditEntry, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	entries, head, err := l.read(nil)
+	if err != nil {
+		return nil, err
+	}
+	if head != l.head || len(entries) != l.entries {
+		return nil, ErrAuditTampered
+	}
+	if account == nil {
+		return entries, nil
+	}
+	var filtered []*AuditEntry
+	for _, entry := range entries {
+		if entry.Account == *account {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered, nil
+}
+
+// mac authenticates a log line with the key of the log.
+func (l *AuditLog) mac(line []byte) common.Hash {
+	mac := hmac.New(sha256.New, l.key)
+	mac.Write(line)
+	return common.BytesToHash(mac.Sum(nil))
+}
+
+// loadHead retrieves the persisted head of the log, or an empty one if the log
+// was never written to.
+func (l *AuditLog) loadHead() (*auditHead, error) {
+	blob, err := ioutil.ReadFile(l.path + ".head")
+	if os.IsNotExist(err) {
+		return new(auditHead), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	head := new(auditHead)
+	if err := json.Unmarshal(blob, head); err != nil {
+		return nil, fmt.Errorf("audit log head: %v", err)
+	}
+	return head, nil
+}
+
+// storeHead atomically persists the head of the log.
+func (l *AuditLog) storeHead(head *auditHead) error {
+	blob, err := json.Marshal(head)
+	if err != nil {
+		return err
+	}
+	tmp := l.path + ".head.tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(blob); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path+".head")
+}
+
+// read parses and verifies all the entries of the log file, returning the ones
+// of the given account (or all if nil) and the MAC of the last line.
+func (l *AuditLog) read(account *common.Address) ([]*AuditEntry, common.Hash, error) {
+	file, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, common.Hash{}, nil
+	}
+	if err != nil {
+		return nil, common.Hash{}, err
+	}
+	defer file.Close()
+
+	var (
+		entries []*AuditEntry
+		head    common.Hash
+		number  int
+	)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		number++
+
+		line := bytes.TrimSpace(scanner.Bytes())
+		entry := new(AuditEntry)
+		if err := json.Unmarshal(line, entry); err != nil {
+			return nil, common.Hash{}, fmt.Errorf("audit log line %d: %v", number, err)
+		}
+		if entry.Parent != head {
+			return nil, common.Hash{}, fmt.Errorf("%v: line %d", ErrAuditTampered, number)
+		}
+		head = l.mac(line)
+
+		if account == nil || entry.Account == *account {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, common.Hash{}, err
+	}
+	return entries, head, nil
+}
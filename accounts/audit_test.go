@@ -0,0 +1,138 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package accounts
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var testAuditKey = []byte("audit test key")
+
+// Tests that signing operations are recorded, survive reopening the log, and can
+// be filtered by account.
+func TestAuditLogRecording(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+	audit, err := OpenAuditLog(path, testAuditKey)
+	if err != nil {
+		t.Fatalf("failed to open audit log: %v", err)
+	}
+	alice, bob := common.Address{0x01}, common.Address{0x02}
+
+	audit.Record(alice, "eth_sendTransaction", AuditTransaction, common.Hash{0x01})
+	audit.Record(bob, "eth_sign", AuditHash, common.Hash{0x02})
+
+	// Reopen the log and ensure new entries chain onto the old ones
+	if audit, err = OpenAuditLog(path, testAuditKey); err != nil {
+		t.Fatalf("failed to reopen audit log: %v", err)
+	}
+	audit.Record(alice, "personal_sign", AuditHash, common.Hash{0x03})
+
+	all, err := audit.Entries(nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve entries: %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("entry count mismatch: have %d, want %d", len(all), 3)
+	}
+	filtered, err := audit.Entries(&alice)
+	if err != nil {
+		t.Fatalf("failed to retrieve entries: %v", err)
+	}
+	if len(filtered) != 2 || filtered[0].Digest != (common.Hash{0x01}) || filtered[1].Origin != "personal_sign" {
+		t.Errorf("filtered entries mismatch: %v", filtered)
+	}
+}
+
+// Tests that modifications of the audit log are detected.
+func TestAuditLogTampering(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+	audit, _ := OpenAuditLog(path, testAuditKey)
+	for i := byte(0); i < 3; i++ {
+		audit.Record(common.Address{0x01}, "eth_sign", AuditHash, common.Hash{i})
+	}
+	// Remove the middle entry and ensure it's detected
+	blob, _ := ioutil.ReadFile(path)
+	lines := bytes.SplitAfter(blob, []byte("\n"))
+	ioutil.WriteFile(path, append(lines[0], lines[2]...), 0600)
+
+	if _, err := audit.Entries(nil); err == nil {
+		t.Errorf("removed entry not detected")
+	}
+	if _, err := OpenAuditLog(path, testAuditKey); err == nil {
+		t.Errorf("tampered log opened")
+	}
+	// Truncate the last entry and ensure it's detected by the running log
+	ioutil.WriteFile(path, append(lines[0], lines[1]...), 0600)
+	if _, err := audit.Entries(nil); err != ErrAuditTampered {
+		t.Errorf("truncation error mismatch: have %v, want %v", err, ErrAuditTampered)
+	}
+	// Ensure the truncation is also detected when reopening the log
+	if _, err := OpenAuditLog(path, testAuditKey); err != ErrAuditTampered {
+		t.Errorf("truncation error mismatch on open: have %v, want %v", err, ErrAuditTampered)
+	}
+	// Delete the whole log and ensure it's detected on reopen
+	os.Remove(path)
+	if _, err := OpenAuditLog(path, testAuditKey); err != ErrAuditTampered {
+		t.Errorf("deletion error mismatch on open: have %v, want %v", err, ErrAuditTampered)
+	}
+}
+
+// Tests that a log rewritten without the node's key is rejected, even if its
+// chain is consistent.
+func TestAuditLogForgery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+	audit, _ := OpenAuditLog(path, testAuditKey)
+	audit.Record(common.Address{0x01}, "eth_sign", AuditHash, common.Hash{0x01})
+
+	if _, err := OpenAuditLog(path, []byte("forger key")); err == nil {
+		t.Errorf("log opened with the wrong key")
+	}
+	// Rewrite the log and its head with a different key and ensure it's rejected
+	forged := filepath.Join(dir, "forged.log")
+	forger, _ := OpenAuditLog(forged, []byte("forger key"))
+	forger.Record(common.Address{0x02}, "eth_sign", AuditHash, common.Hash{0x02})
+
+	os.Rename(forged, path)
+	os.Rename(forged+".head", path+".head")
+	if _, err := OpenAuditLog(path, testAuditKey); err == nil {
+		t.Errorf("forged log opened")
+	}
+}
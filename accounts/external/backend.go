@@ -0,0 +1,314 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package external implements an accounts backend forwarding signing requests to
+// an external signer process, so that private keys never have to be loaded into
+// the networked node.
+//
+// The signer is reached through its local IPC endpoint, which on Unix platforms
+// must not be accessible by other users. It is expected to serve the following
+// JSON-RPC methods:
+//
+//   account_version() string
+//   account_list() []address
+//   account_signHash(meta, address, hash) signature
+//   account_signTransaction(meta, address, tx, chainId) rlp
+//
+// Every signing request carries a metadata object identifying the node, the
+// operation it originates from and the details of that operation (account, hash
+// to sign and transaction), which the signer may present when asking for the
+// request's approval.
+package external
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+	"runtime"
+	"sync"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ExternalScheme is the protocol scheme prefixing account and wallet URLs.
+const ExternalScheme = "extapi"
+
+// callTimeout is the maximum time to wait for the non-interactive calls of the
+// signer. Signing requests aren't limited, as they may await user approval.
+const callTimeout = 5 * time.Second
+
+// BackendType is the reflect type of an external signer backend.
+var BackendType = reflect.TypeOf(&Backend{})
+
+var (
+	// errInsecureEndpoint is returned if the IPC endpoint of the signer can be
+	// accessed by other users, so it can't be trusted.
+	errInsecureEndpoint = errors.New("signer endpoint accessible by other users")
+
+	// errSignatureMismatch is returned if the signer returns a transaction that
+	// wasn't signed by the requested account or differs from the requested one.
+	errSignatureMismatch = errors.New("signed transaction mismatch")
+)
+
+// Metadata describes the origin of a signing request, for the signer to present
+// when asking for its approval.
+type Metadata struct {
+	Origin string   `json:"origin"` // Name of the node requesting the signature
+	Call   string   `json:"call"`   // Wallet operation the signature is requested for
+	Info   CallInfo `json:"info"`   // Details of the requested operation
+}
+
+// CallInfo details the wallet operation a signature is requested for.
+type CallInfo struct {
+	Account common.Address   `json:"account"`           // Account requested to sign
+	Hash    hexutil.Bytes    `json:"hash"`              // Hash to sign (the signing hash for transactions)
+	ChainID *hexutil.Big     `json:"chainId,omitempty"` // Chain the transaction is signed for (transactions only)
+	Tx      *TransactionArgs `json:"tx,omitempty"`      // Transaction to sign (transactions only)
+}
+
+// TransactionArgs are the fields of a transaction to be signed by the signer.
+type TransactionArgs struct {
+	From     common.Address  `json:"from"`
+	To       *common.Address `json:"to"`
+	Gas      *hexutil.Big    `json:"gas"`
+	GasPrice *hexutil.Big    `json:"gasPrice"`
+	Value    *hexutil.Big    `json:"value"`
+	Nonce    hexutil.Uint64  `json:"nonce"`
+	Data     hexutil.Bytes   `json:"data"`
+}
+
+// Backend is an accounts.Backend exposing the single wallet of an external signer.
+type Backend struct {
+	signers []accounts.Wallet
+}
+
+// NewBackend connects to the external signer listening on the given IPC endpoint,
+// tagging all the signing requests with the given origin. The signer is required
+// to respond to a version request to be accepted.
+func NewBackend(endpoint string, origin string) (*Backend, error) {
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		if info.Mode().Perm()&0077 != 0 {
+			return nil, errInsecureEndpoint
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	client, err := rpc.DialIPC(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	var version string
+	if err := client.CallContext(ctx, &version, "account_version"); err != nil {
+		client.Close()
+		return nil, err
+	}
+	log.Info("Connected to external signer", "endpoint", endpoint, "version", version)
+
+	signer := &signer{
+		client: client,
+		url:    accounts.URL{Scheme: ExternalScheme, Path: endpoint},
+		origin: origin,
+	}
+	return &Backend{signers: []accounts.Wallet{signer}}, nil
+}
+
+// Wallets implements accounts.Backend, returning the wallet of the signer.
+func (b *Backend) Wallets() []accounts.Wallet {
+	cpy := make([]accounts.Wallet, len(b.signers))
+	copy(cpy, b.signers)
+	return cpy
+}
+
+// Subscribe implements accounts.Backend. As the wallet of the signer is static,
+// no events are ever sent.
+func (b *Backend) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+}
+
+// signer implements accounts.Wallet, forwarding all requests to an external
+// signer process.
+type signer struct {
+	client *rpc.Client  // RPC connection to the signer
+	url    accounts.URL // Textual URL uniquely identifying the signer
+	origin string       // Name of the node, sent along signing requests
+
+	cache []accounts.Account // Accounts last listed by the signer
+	lock  sync.RWMutex
+}
+
+// URL implements accounts.Wallet, returning the URL of the signer endpoint.
+func (s *signer) URL() accounts.URL {
+	return s.url
+}
+
+// Status implements accounts.Wallet, returning the version reported by the signer.
+func (s *signer) Status() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	var version string
+	if err := s.client.CallContext(ctx, &version, "account_version"); err != nil {
+		return "Failed: " + err.Error(), err
+	}
+	return fmt.Sprintf("Online, version %s", version), nil
+}
+
+// Open implements accounts.Wallet, but is a noop as the signer manages its own
+// keys and their unlocking.
+func (s *signer) Open(passphrase string) error {
+	return nil
+}
+
+// Close implements accounts.Wallet, but is a noop as the connection to the signer
+// is kept for the lifetime of the node.
+func (s *signer) Close() error {
+	return nil
+}
+
+// Accounts implements accounts.Wallet, returning the accounts the signer manages.
+// If the signer can't be reached, the last known accounts are returned.
+func (s *signer) Accounts() []accounts.Account {
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	var addresses []common.Address
+	if err := s.client.CallContext(ctx, &addresses, "account_list"); err != nil {
+		log.Warn("Failed to list external signer accounts", "url", s.url, "err", err)
+
+		s.lock.RLock()
+		defer s.lock.RUnlock()
+		return append([]accounts.Account{}, s.cache...)
+	}
+	list := make([]accounts.Account, len(addresses))
+	for i, address := range addresses {
+		list[i] = accounts.Account{Address: address, URL: s.url}
+	}
+	s.lock.Lock()
+	s.cache = list
+	s.lock.Unlock()
+
+	return append([]accounts.Account{}, list...)
+}
+
+// Contains implements accounts.Wallet, returning whether a particular account is
+// managed by the signer.
+func (s *signer) Contains(account accounts.Account) bool {
+	for _, known := range s.Accounts() {
+		if known.Address == account.Address {
+			return true
+		}
+	}
+	return false
+}
+
+// Derive implements accounts.Wallet, but is not supported by external signers.
+func (s *signer) Derive(path accounts.DerivationPath, pin bool) (accounts.Account, error) {
+	return accounts.Account{}, accounts.ErrNotSupported
+}
+
+// SelfDerive implements accounts.Wallet, but is a noop for external signers.
+func (s *signer) SelfDerive(base accounts.DerivationPath, chain ethereum.ChainStateReader) {
+}
+
+// SignHash implements accounts.Wallet, requesting the signer to sign the hash
+// with the given account.
+func (s *signer) SignHash(account accounts.Account, hash []byte) ([]byte, error) {
+	var sig hexutil.Bytes
+	meta := Metadata{
+		Origin: s.origin,
+		Call:   "SignHash",
+		Info:   CallInfo{Account: account.Address, Hash: hash},
+	}
+	if err := s.client.Call(&sig, "account_signHash", meta, account.Address, hexutil.Bytes(hash)); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// SignTx implements accounts.Wallet, requesting the signer to sign the transaction
+// with the given account. The returned transaction is verified to be the requested
+// one, signed by the requested account.
+func (s *signer) SignTx(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	args := &TransactionArgs{
+		From:     account.Address,
+		To:       tx.To(),
+		Gas:      (*hexutil.Big)(tx.Gas()),
+		GasPrice: (*hexutil.Big)(tx.GasPrice()),
+		Value:    (*hexutil.Big)(tx.Value()),
+		Nonce:    hexutil.Uint64(tx.Nonce()),
+		Data:     tx.Data(),
+	}
+	var txSigner types.Signer = types.HomesteadSigner{}
+	if chainID != nil {
+		txSigner = types.NewEIP155Signer(chainID)
+	}
+	var raw hexutil.Bytes
+	meta := Metadata{
+		Origin: s.origin,
+		Call:   "SignTx",
+		Info:   CallInfo{Account: account.Address, Hash: txSigner.Hash(tx).Bytes(), ChainID: (*hexutil.Big)(chainID), Tx: args},
+	}
+	if err := s.client.Call(&raw, "account_signTransaction", meta, account.Address, args, (*hexutil.Big)(chainID)); err != nil {
+		return nil, err
+	}
+	signed := new(types.Transaction)
+	if err := rlp.DecodeBytes(raw, signed); err != nil {
+		return nil, err
+	}
+	// Ensure the signer didn't tamper with the transaction or sign with another key
+	if chainID != nil && !signed.Protected() {
+		return nil, errSignatureMismatch
+	}
+	if txSigner.Hash(signed) != txSigner.Hash(tx) {
+		return nil, errSignatureMismatch
+	}
+	if sender, err := types.Sender(txSigner, signed); err != nil || sender != account.Address {
+		return nil, errSignatureMismatch
+	}
+	return signed, nil
+}
+
+// SignHashWithPassphrase implements accounts.Wallet, but is not supported as the
+// signer handles the unlocking of its keys itself.
+func (s *signer) SignHashWithPassphrase(account accounts.Account, passphrase string, hash []byte) ([]byte, error) {
+	return nil, accounts.ErrNotSupported
+}
+
+// SignTxWithPassphrase implements accounts.Wallet, but is not supported as the
+// signer handles the unlocking of its keys itself.
+func (s *signer) SignTxWithPassphrase(account accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return nil, accounts.ErrNotSupported
+}
@@ -0,0 +1,180 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package external
+
+import (
+	"crypto/ecdsa"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// MockSigner is a mock external signer approving every request, optionally
+// tampering with the signed transactions.
+type MockSigner struct {
+	key    *ecdsa.PrivateKey
+	meta   Metadata
+	tamper bool
+}
+
+func (s *MockSigner) Version() string {
+	return "1.0.0"
+}
+
+func (s *MockSigner) List() []common.Address {
+	return []common.Address{crypto.PubkeyToAddress(s.key.PublicKey)}
+}
+
+func (s *MockSigner) SignHash(meta Metadata, address common.Address, hash hexutil.Bytes) (hexutil.Bytes, error) {
+	s.meta = meta
+	return crypto.Sign(hash, s.key)
+}
+
+func (s *MockSigner) SignTransaction(meta Metadata, address common.Address, args TransactionArgs, chainID *hexutil.Big) (hexutil.Bytes, error) {
+	s.meta = meta
+	if s.tamper {
+		args.Value = (*hexutil.Big)(new(big.Int).Add(args.Value.ToInt(), big.NewInt(1)))
+	}
+	tx := types.NewTransaction(uint64(args.Nonce), *args.To, args.Value.ToInt(), args.Gas.ToInt(), args.GasPrice.ToInt(), args.Data)
+	signed, err := types.SignTx(tx, types.NewEIP155Signer(chainID.ToInt()), s.key)
+	if err != nil {
+		return nil, err
+	}
+	return rlp.EncodeToBytes(signed)
+}
+
+// newTestBackend starts a mock signer on a temporary IPC endpoint and connects
+// a backend to it.
+func newTestBackend(t *testing.T) (*Backend, *MockSigner, func()) {
+	dir, err := ioutil.TempDir("", "external-signer-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := crypto.GenerateKey()
+	mock := &MockSigner{key: key}
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("account", mock); err != nil {
+		t.Fatal(err)
+	}
+	endpoint := filepath.Join(dir, "signer.ipc")
+	listener, err := rpc.CreateIPCListener(endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.ServeListener(listener)
+
+	backend, err := NewBackend(endpoint, "test-node")
+	if err != nil {
+		t.Fatalf("failed to connect to signer: %v", err)
+	}
+	return backend, mock, func() {
+		listener.Close()
+		server.Stop()
+		os.RemoveAll(dir)
+	}
+}
+
+// Tests that signing requests are forwarded to the external signer along with
+// their metadata.
+func TestExternalSigning(t *testing.T) {
+	backend, mock, teardown := newTestBackend(t)
+	defer teardown()
+
+	wallet := backend.Wallets()[0]
+	if _, err := wallet.Status(); err != nil {
+		t.Fatalf("failed to retrieve status: %v", err)
+	}
+	accs := wallet.Accounts()
+	if len(accs) != 1 || accs[0].Address != crypto.PubkeyToAddress(mock.key.PublicKey) {
+		t.Fatalf("account mismatch: have %v", accs)
+	}
+	// Sign a hash and ensure it was signed by the right key
+	hash := make([]byte, 32)
+	sig, err := wallet.SignHash(accs[0], hash)
+	if err != nil {
+		t.Fatalf("failed to sign hash: %v", err)
+	}
+	if pub, err := crypto.SigToPub(hash, sig); err != nil || crypto.PubkeyToAddress(*pub) != accs[0].Address {
+		t.Errorf("hash signer mismatch")
+	}
+	want := Metadata{Origin: "test-node", Call: "SignHash", Info: CallInfo{Account: accs[0].Address, Hash: hash}}
+	if !reflect.DeepEqual(mock.meta, want) {
+		t.Errorf("metadata mismatch: have %+v, want %+v", mock.meta, want)
+	}
+	// Sign a transaction and ensure it's verified
+	chainID := big.NewInt(1)
+	tx := types.NewTransaction(1, common.Address{0x01}, big.NewInt(2), big.NewInt(21000), big.NewInt(3), []byte{0x04})
+
+	signed, err := wallet.SignTx(accs[0], tx, chainID)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if sender, err := types.Sender(types.NewEIP155Signer(chainID), signed); err != nil || sender != accs[0].Address {
+		t.Errorf("transaction signer mismatch")
+	}
+	want = Metadata{Origin: "test-node", Call: "SignTx", Info: CallInfo{
+		Account: accs[0].Address,
+		Hash:    types.NewEIP155Signer(chainID).Hash(tx).Bytes(),
+		ChainID: (*hexutil.Big)(chainID),
+		Tx: &TransactionArgs{
+			From:     accs[0].Address,
+			To:       tx.To(),
+			Gas:      (*hexutil.Big)(tx.Gas()),
+			GasPrice: (*hexutil.Big)(tx.GasPrice()),
+			Value:    (*hexutil.Big)(tx.Value()),
+			Nonce:    hexutil.Uint64(tx.Nonce()),
+			Data:     tx.Data(),
+		},
+	}}
+	if !reflect.DeepEqual(mock.meta, want) {
+		t.Errorf("metadata mismatch: have %+v, want %+v", mock.meta, want)
+	}
+	// Ensure tampered transactions are rejected
+	mock.tamper = true
+	if _, err := wallet.SignTx(accs[0], tx, chainID); err != errSignatureMismatch {
+		t.Errorf("tampered transaction error mismatch: have %v, want %v", err, errSignatureMismatch)
+	}
+}
+
+// Tests that signer endpoints accessible by other users are rejected.
+func TestExternalInsecureEndpoint(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("endpoint permissions not checked on windows")
+	}
+	backend, _, teardown := newTestBackend(t)
+	defer teardown()
+
+	endpoint := backend.Wallets()[0].URL().Path
+	if err := os.Chmod(endpoint, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewBackend(endpoint, "test-node"); err != errInsecureEndpoint {
+		t.Errorf("error mismatch: have %v, want %v", err, errInsecureEndpoint)
+	}
+}
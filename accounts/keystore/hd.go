@@ -0,0 +1,110 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package keystore
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/status-im/status-go/extkeys"
+)
+
+// ErrInvalidMnemonic is returned if a mnemonic is not a valid English BIP-39
+// mnemonic, i.e. has an invalid word count, unknown words or a bad checksum.
+var ErrInvalidMnemonic = errors.New("invalid mnemonic")
+
+// masterKey validates a BIP-39 mnemonic and derives the master key of the
+// hierarchical deterministic wallet it describes, along with its password.
+func masterKey(mnemonic, password string) (*extkeys.ExtendedKey, error) {
+	m := extkeys.NewMnemonic(extkeys.Salt)
+	if !m.ValidMnemonic(mnemonic, extkeys.EnglishLanguage) {
+		return nil, ErrInvalidMnemonic
+	}
+	return extkeys.NewMaster(m.MnemonicSeed(mnemonic, password), []byte(extkeys.Salt))
+}
+
+// DeriveKey derives the private key at the given path of the hierarchical
+// deterministic wallet described by a BIP-39 mnemonic and its optional password.
+func DeriveKey(mnemonic, password string, path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	key, err := masterKey(mnemonic, password)
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range path {
+		if key, err = key.Child(index); err != nil {
+			return nil, err
+		}
+	}
+	return key.ToECDSA(), nil
+}
+
+// ImportMnemonic imports the hierarchical deterministic wallet described by a
+// BIP-39 mnemonic and its optional password, the same way as ImportExtendedKey
+// does for master keys: its main account (m/44'/60'/0'/0/0) is stored along with
+// the root of its sub-accounts, encrypted with the passphrase.
+func (ks *KeyStore) ImportMnemonic(mnemonic, password, passphrase string) (accounts.Account, error) {
+	master, err := masterKey(mnemonic, password)
+	if err != nil {
+		return accounts.Account{}, err
+	}
+	return ks.ImportExtendedKey(master, passphrase)
+}
+
+// ImportMnemonicKey derives the key at the given path of the hierarchical
+// deterministic wallet described by a BIP-39 mnemonic and its optional password,
+// and stores it along with its path, encrypted with the passphrase.
+func (ks *KeyStore) ImportMnemonicKey(mnemonic, password string, path accounts.DerivationPath, passphrase string) (accounts.Account, error) {
+	priv, err := DeriveKey(mnemonic, password, path)
+	if err != nil {
+		return accounts.Account{}, err
+	}
+	key := newKeyFromECDSA(priv)
+	if ks.cache.hasAddress(key.Address) {
+		return accounts.Account{}, fmt.Errorf("account already exists")
+	}
+	key.DerivationPath = append(accounts.DerivationPath{}, path...)
+	return ks.importKey(key, passphrase)
+}
+
+// DerivationPath returns the derivation path of an account, if its key was derived
+// from an imported mnemonic or hierarchical deterministic master key. The path of
+// keys imported from child extended keys is unknown.
+func (ks *KeyStore) DerivationPath(a accounts.Account) (accounts.DerivationPath, bool) {
+	a, err := ks.Find(a)
+	if err != nil {
+		return nil, false
+	}
+	blob, err := ioutil.ReadFile(a.URL.Path)
+	if err != nil {
+		return nil, false
+	}
+	var key struct {
+		DerivationPath string `json:"derivationpath"`
+	}
+	if err := json.Unmarshal(blob, &key); err != nil || key.DerivationPath == "" {
+		return nil, false
+	}
+	path, err := accounts.ParseDerivationPath(key.DerivationPath)
+	if err != nil {
+		return nil, false
+	}
+	return path, true
+}
@@ -0,0 +1,156 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package keystore
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+// Main account of testMnemonic without password, as per the BIP-39/44 test vectors.
+var testMnemonicAddress = common.HexToAddress("0x9858EfFD232B4033E47d90003D41EC34EcaEda94")
+
+// Tests that invalid mnemonics are rejected, and that the mnemonic password is
+// part of the master key.
+func TestMnemonicMasterKey(t *testing.T) {
+	if _, err := masterKey(strings.Repeat("abandon ", 11), ""); err != ErrInvalidMnemonic {
+		t.Errorf("short mnemonic error mismatch: have %v, want %v", err, ErrInvalidMnemonic)
+	}
+	plain, err := masterKey(testMnemonic, "")
+	if err != nil {
+		t.Fatalf("failed to create master key: %v", err)
+	}
+	protected, err := masterKey(testMnemonic, "TREZOR")
+	if err != nil {
+		t.Fatalf("failed to create protected master key: %v", err)
+	}
+	if plain.Depth != 0 || protected.Depth != 0 {
+		t.Errorf("master key depth mismatch: have %d and %d, want 0", plain.Depth, protected.Depth)
+	}
+	if crypto.PubkeyToAddress(plain.ToECDSA().PublicKey) == crypto.PubkeyToAddress(protected.ToECDSA().PublicKey) {
+		t.Errorf("mnemonic password ignored")
+	}
+}
+
+// Tests that keys derived straight from a mnemonic match the standard Ethereum
+// accounts.
+func TestHDDeriveKey(t *testing.T) {
+	key, err := DeriveKey(testMnemonic, "", accounts.DefaultBaseDerivationPath)
+	if err != nil {
+		t.Fatalf("failed to derive key: %v", err)
+	}
+	if address := crypto.PubkeyToAddress(key.PublicKey); address != testMnemonicAddress {
+		t.Errorf("address mismatch: have %x, want %x", address, testMnemonicAddress)
+	}
+	if _, err := DeriveKey("abandon", "", accounts.DefaultBaseDerivationPath); err != ErrInvalidMnemonic {
+		t.Errorf("invalid mnemonic error mismatch: have %v, want %v", err, ErrInvalidMnemonic)
+	}
+}
+
+// Tests that imported mnemonics store their locked main account along with its
+// derivation path, and that the mnemonic password is independent of the passphrase.
+func TestImportMnemonic(t *testing.T) {
+	dir, ks := tmpKeyStore(t, true)
+	defer os.RemoveAll(dir)
+
+	a, err := ks.ImportMnemonic(testMnemonic, "", "foo")
+	if err != nil {
+		t.Fatalf("failed to import mnemonic: %v", err)
+	}
+	if a.Address != testMnemonicAddress {
+		t.Errorf("account mismatch: have %x, want %x", a.Address, testMnemonicAddress)
+	}
+	if _, err := ks.SignHash(a, testSigData); err != ErrLocked {
+		t.Errorf("imported account signing error mismatch: have %v, want %v", err, ErrLocked)
+	}
+	if err := ks.Unlock(a, "foo"); err != nil {
+		t.Fatalf("failed to unlock imported account: %v", err)
+	}
+	path, ok := ks.DerivationPath(a)
+	if !ok {
+		t.Fatalf("imported account has no derivation path")
+	}
+	if path.String() != accounts.DefaultBaseDerivationPath.String() {
+		t.Errorf("derivation path mismatch: have %v, want %v", path, accounts.DefaultBaseDerivationPath)
+	}
+	// The mnemonic password selects a different wallet, the passphrase doesn't
+	key, _ := DeriveKey(testMnemonic, "TREZOR", accounts.DefaultBaseDerivationPath)
+	b, err := ks.ImportMnemonic(testMnemonic, "TREZOR", "foo")
+	if err != nil {
+		t.Fatalf("failed to import protected mnemonic: %v", err)
+	}
+	if want := crypto.PubkeyToAddress(key.PublicKey); b.Address != want {
+		t.Errorf("protected account mismatch: have %x, want %x", b.Address, want)
+	}
+}
+
+// Tests that derivation paths are only reported for keys whose path is known.
+func TestDerivationPathUnknown(t *testing.T) {
+	dir, ks := tmpKeyStore(t, true)
+	defer os.RemoveAll(dir)
+
+	// Child extended keys only record their depth
+	master, _ := masterKey(testMnemonic, "")
+	child, err := master.Child(0x80000000 + 44)
+	if err != nil {
+		t.Fatalf("failed to derive child key: %v", err)
+	}
+	a, err := ks.ImportExtendedKey(child, "foo")
+	if err != nil {
+		t.Fatalf("failed to import child key: %v", err)
+	}
+	if path, ok := ks.DerivationPath(a); ok {
+		t.Errorf("child key has derivation path %v", path)
+	}
+	// Plain keys have no derivation path at all
+	key, _ := crypto.GenerateKey()
+	b, err := ks.ImportECDSA(key, "foo")
+	if err != nil {
+		t.Fatalf("failed to import key: %v", err)
+	}
+	if path, ok := ks.DerivationPath(b); ok {
+		t.Errorf("plain key has derivation path %v", path)
+	}
+	// Unknown accounts neither
+	if path, ok := ks.DerivationPath(accounts.Account{Address: common.Address{0x01}}); ok {
+		t.Errorf("unknown account has derivation path %v", path)
+	}
+}
+
+// Tests that the derivation path survives updates of the key file.
+func TestDerivationPathUpdate(t *testing.T) {
+	dir, ks := tmpKeyStore(t, true)
+	defer os.RemoveAll(dir)
+
+	a, err := ks.ImportMnemonic(testMnemonic, "", "foo")
+	if err != nil {
+		t.Fatalf("failed to import mnemonic: %v", err)
+	}
+	if err := ks.Update(a, "foo", "bar"); err != nil {
+		t.Fatalf("failed to update account: %v", err)
+	}
+	if path, ok := ks.DerivationPath(a); !ok || path.String() != accounts.DefaultBaseDerivationPath.String() {
+		t.Errorf("derivation path mismatch after update: have %v (%v), want %v", path, ok, accounts.DefaultBaseDerivationPath)
+	}
+}
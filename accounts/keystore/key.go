@@ -51,6 +51,8 @@ type Key struct {
 	ExtendedKey *extkeys.ExtendedKey
 	// next index to be used for sub-account child derivation
 	SubAccountIndex uint32
+	// path of the private key in its hierarchical deterministic wallet, if known
+	DerivationPath accounts.DerivationPath
 }
 
 type keyStore interface {
@@ -76,6 +78,7 @@ type encryptedKeyJSONV3 struct {
 	Version         int        `json:"version"`
 	ExtendedKey     cryptoJSON `json:"extendedkey"`
 	SubAccountIndex uint32     `json:"subaccountindex"`
+	DerivationPath  string     `json:"derivationpath,omitempty"`
 }
 
 type encryptedKeyJSONV1 struct {
@@ -175,6 +178,10 @@ func newKeyFromExtendedKey(extKey *extkeys.ExtendedKey) (*Key, error) {
 		PrivateKey:  privateKeyECDSA,
 		ExtendedKey: extChild2,
 	}
+	// The path of child keys is unknown, only their depth is recorded
+	if extKey.Depth == 0 {
+		key.DerivationPath = append(accounts.DerivationPath{}, accounts.DefaultBaseDerivationPath...)
+	}
 	return key, nil
 }
 
@@ -35,7 +35,9 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"time"
 
+	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -163,6 +165,10 @@ func EncryptKey(key *Key, auth string, scryptN, scryptP int) ([]byte, error) {
 		version,
 		encryptedExtendedKey,
 		key.SubAccountIndex,
+		"",
+	}
+	if key.DerivationPath != nil {
+		encryptedKeyJSONV3.DerivationPath = key.DerivationPath.String()
 	}
 	return json.Marshal(encryptedKeyJSONV3)
 }
@@ -227,6 +233,12 @@ func DecryptKey(keyjson []byte, auth string) (*Key, error) {
 	if !ok {
 		subAccountIndex = 0
 	}
+	var derivationPath accounts.DerivationPath
+	if path, ok := m["derivationpath"].(string); ok {
+		if derivationPath, err = accounts.ParseDerivationPath(path); err != nil {
+			return nil, err
+		}
+	}
 
 	if version, ok := m["version"].(string); ok && version == "1" {
 		k := new(encryptedKeyJSONV1)
@@ -268,6 +280,7 @@ func DecryptKey(keyjson []byte, auth string) (*Key, error) {
 		PrivateKey:      key,
 		ExtendedKey:     extKey,
 		SubAccountIndex: uint32(subAccountIndex),
+		DerivationPath:  derivationPath,
 	}, nil
 }
 
@@ -429,3 +442,32 @@ func ensureInt(x interface{}) int {
 	}
 	return res
 }
+
+// BenchmarkScrypt measures the time it takes to derive a key encryption key with
+// the given scrypt parameters on the current machine.
+func BenchmarkScrypt(scryptN, scryptP int) (time.Duration, error) {
+	start := time.Now()
+	if _, err := scrypt.Key([]byte("benchmark"), make([]byte, 32), scryptN, scryptR, scryptP, scryptDKLen); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// CalibrateScryptN returns the highest scrypt N parameter between the light and
+// the standard one, for which a key derivation with the given P parameter takes
+// at most the given time on the current machine. This allows resource constrained
+// devices to encrypt keys as strongly as they can afford without long stalls.
+func CalibrateScryptN(limit time.Duration, scryptP int) (int, error) {
+	scryptN := LightScryptN
+	for scryptN < StandardScryptN {
+		elapsed, err := BenchmarkScrypt(scryptN*2, scryptP)
+		if err != nil {
+			return 0, err
+		}
+		if elapsed > limit {
+			break
+		}
+		scryptN *= 2
+	}
+	return scryptN, nil
+}
@@ -58,3 +58,18 @@ func TestKeyEncryptDecrypt(t *testing.T) {
 		}
 	}
 }
+
+// Tests that scrypt calibration never goes below the light parameters, and that
+// invalid parameters are reported by the benchmark.
+func TestScryptCalibration(t *testing.T) {
+	if _, err := BenchmarkScrypt(1000, LightScryptP); err == nil {
+		t.Errorf("invalid scrypt N accepted")
+	}
+	n, err := CalibrateScryptN(0, LightScryptP)
+	if err != nil {
+		t.Fatalf("failed to calibrate scrypt: %v", err)
+	}
+	if n != LightScryptN {
+		t.Errorf("scrypt N mismatch: have %d, want %d", n, LightScryptN)
+	}
+}
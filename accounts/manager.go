@@ -21,7 +21,10 @@ import (
 	"sort"
 	"sync"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
 )
 
 // Manager is an overarching account manager that can communicate with various
@@ -32,7 +35,9 @@ type Manager struct {
 	updates  chan WalletEvent           // Subscription sink for backend wallet changes
 	wallets  []Wallet                   // Cache of all wallets from all registered backends
 
-	feed event.Feed // Wallet feed notifying of arrivals/departures
+	feed  event.Feed    // Wallet feed notifying of arrivals/departures
+	audit *AuditLog     // Optional log of the signing operations performed
+	guard *SigningGuard // Optional rules transactions must satisfy to be signed
 
 	quit chan chan error
 	lock sync.RWMutex
@@ -70,6 +75,69 @@ func NewManager(backends ...Backend) *Manager {
 	return am
 }
 
+// SetAuditLog sets the log to record the signing operations performed with the
+// managed accounts into.
+func (am *Manager) SetAuditLog(log *AuditLog) {
+	am.lock.Lock()
+	defer am.lock.Unlock()
+
+	am.audit = log
+}
+
+// AuditLog returns the log of signing operations, or nil if none is kept.
+func (am *Manager) AuditLog() *AuditLog {
+	am.lock.RLock()
+	defer am.lock.RUnlock()
+
+	return am.audit
+}
+
+// Audit records a signing operation into the audit log, if one is kept.
+func (am *Manager) Audit(account common.Address, origin string, kind string, digest common.Hash) error {
+	if log := am.AuditLog(); log != nil {
+		return log.Record(account, origin, kind, digest)
+	}
+	return nil
+}
+
+// SetSigningGuard sets the guard enforcing rules on the transactions signed with
+// the managed accounts.
+func (am *Manager) SetSigningGuard(guard *SigningGuard) {
+	am.lock.Lock()
+	defer am.lock.Unlock()
+
+	am.guard = guard
+}
+
+// SigningGuard returns the guard enforcing the signing rules, or nil if there
+// are no rules.
+func (am *Manager) SigningGuard() *SigningGuard {
+	am.lock.RLock()
+	defer am.lock.RUnlock()
+
+	return am.guard
+}
+
+// AuthorizeTx checks a transaction against the signing rules, if any, blocking
+// until it's interactively approved or rejected if it violates them. Authorized
+// transactions must be settled with SettleTx once signed or failed.
+func (am *Manager) AuthorizeTx(account common.Address, origin string, tx *types.Transaction) error {
+	if guard := am.SigningGuard(); guard != nil {
+		return guard.Authorize(account, origin, tx)
+	}
+	return nil
+}
+
+// SettleTx records the outcome of signing an authorized transaction, counting its
+// value towards the daily limit of the signing rules only if it was signed.
+func (am *Manager) SettleTx(account common.Address, tx *types.Transaction, signed bool) {
+	if guard := am.SigningGuard(); guard != nil {
+		if err := guard.Settle(account, tx, signed); err != nil {
+			log.Error("Failed to persist signing spending", "err", err)
+		}
+	}
+}
+
 // Close terminates the account manager's internal notification processes.
 func (am *Manager) Close() error {
 	errc := make(chan error)
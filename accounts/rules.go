@@ -0,0 +1,325 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package accounts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// approvalTimeout is the time a signing request violating the rules waits for an
+// interactive approval before being rejected.
+var approvalTimeout = 5 * time.Minute
+
+var (
+	// ErrRequestRejected is returned if a signing request violating the rules was
+	// rejected, or wasn't approved in time.
+	ErrRequestRejected = errors.New("signing request rejected")
+
+	// ErrUnknownApproval is returned if a decision is made on a signing request
+	// that is not (or no longer) awaiting approval.
+	ErrUnknownApproval = errors.New("unknown signing approval")
+)
+
+// SigningRules are the constraints transactions must satisfy to be signed without
+// interactive approval. Unset constraints are not enforced.
+type SigningRules struct {
+	MaxValue     *big.Int         `json:"maxValue,omitempty"`     // Maximum value of a single transaction
+	DailyLimit   *big.Int         `json:"dailyLimit,omitempty"`   // Maximum value spent per account and day (UTC)
+	Destinations []common.Address `json:"destinations,omitempty"` // Allowed transaction recipients
+	AutoApprove  []string         `json:"autoApprove,omitempty"`  // Operations (e.g. RPC methods) exempt from the rules
+}
+
+// LoadSigningRules reads a set of signing rules from a JSON file.
+func LoadSigningRules(path string) (*SigningRules, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rules := new(SigningRules)
+	if err := json.Unmarshal(blob, rules); err != nil {
+		return nil, fmt.Errorf("invalid signing rules %s: %v", path, err)
+	}
+	return rules, nil
+}
+
+// SigningApproval is a transaction signing request violating the rules, awaiting
+// an interactive decision.
+type SigningApproval struct {
+	ID      uint64          `json:"id"`
+	Account common.Address  `json:"account"`
+	Origin  string          `json:"origin"`
+	To      *common.Address `json:"to"`
+	Value   *hexutil.Big    `json:"value"`
+	Reason  string          `json:"reason"`
+
+	decision chan bool
+}
+
+// spending is the daily spending of the accounts, as persisted across restarts.
+type spending struct {
+	Day   int64                       `json:"day"`   // Day (since the epoch, UTC) the spending is tracked for
+	Spent map[common.Address]*big.Int `json:"spent"` // Value spent by each account during the day
+}
+
+// SigningGuard enforces a set of signing rules on transactions, holding back the
+// ones violating them until they are approved.
+//
+// The value of authorized transactions is reserved against the daily limit until
+// they are settled, and only counts as spent if they were actually signed. The
+// daily spending is persisted, so restarting the node doesn't reset it.
+type SigningGuard struct {
+	rules *SigningRules
+	path  string // File the daily spending is persisted to (empty = memory only)
+
+	day      int64                       // Day (since the epoch, UTC) the spending is tracked for
+	spent    map[common.Address]*big.Int // Value spent by each account during the day
+	reserved map[common.Address]*big.Int // Value of authorized transactions not yet settled
+
+	pending map[uint64]*SigningApproval // Requests awaiting approval
+	nextID  uint64                      // Identifier of the next request awaiting approval
+
+	lock sync.Mutex
+}
+
+// NewSigningGuard creates a guard enforcing the given signing rules. The daily
+// spending of the accounts is persisted into the file at path, if one is given.
+func NewSigningGuard(rules *SigningRules, path string) (*SigningGuard, error) {
+	g := &SigningGuard{
+		rules:    rules,
+		path:     path,
+		spent:    make(map[common.Address]*big.Int),
+		reserved: make(map[common.Address]*big.Int),
+		pending:  make(map[uint64]*SigningApproval),
+	}
+	if path == "" {
+		return g, nil
+	}
+	blob, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return g, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state spending
+	if err := json.Unmarshal(blob, &state); err != nil {
+		return nil, fmt.Errorf("invalid signing spending %s: %v", path, err)
+	}
+	if state.Spent != nil {
+		g.day, g.spent = state.Day, state.Spent
+	}
+	return g, nil
+}
+
+// Authorize checks whether the account may sign the transaction on behalf of the
+// given origin. If the transaction violates the rules, it blocks until the request
+// is approved or rejected through Decide, or the approval times out.
+//
+// The value of an authorized transaction is reserved against the daily limit, and
+// Settle must be called once the signing finished or failed.
+func (g *SigningGuard) Authorize(account common.Address, origin string, tx *types.Transaction) error {
+	g.lock.Lock()
+	reason := g.violation(account, origin, tx)
+	if reason == "" {
+		g.reserve(account, tx.Value())
+		g.lock.Unlock()
+		return nil
+	}
+	// The rules are violated, wait for an interactive decision
+	g.nextID++
+	approval := &SigningApproval{
+		ID:       g.nextID,
+		Account:  account,
+		Origin:   origin,
+		To:       tx.To(),
+		Value:    (*hexutil.Big)(tx.Value()),
+		Reason:   reason,
+		decision: make(chan bool, 1),
+	}
+	g.pending[approval.ID] = approval
+	g.lock.Unlock()
+
+	log.Warn("Signing request awaiting approval", "id", approval.ID, "account", account, "origin", origin, "reason", reason)
+
+	timeout := time.NewTimer(approvalTimeout)
+	defer timeout.Stop()
+
+	var approved bool
+	select {
+	case approved = <-approval.decision:
+	case <-timeout.C:
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	delete(g.pending, approval.ID)
+	if !approved {
+		return ErrRequestRejected
+	}
+	g.reserve(account, tx.Value())
+	return nil
+}
+
+// Settle releases the reservation of an authorized transaction, accounting its
+// value to the daily spending of the account if it was signed.
+func (g *SigningGuard) Settle(account common.Address, tx *types.Transaction, signed bool) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	value := tx.Value()
+	if reserved, ok := g.reserved[account]; ok {
+		if left := new(big.Int).Sub(reserved, value); left.Sign() > 0 {
+			g.reserved[account] = left
+		} else {
+			delete(g.reserved, account)
+		}
+	}
+	if !signed {
+		return nil
+	}
+	g.rollover()
+	g.spent[account] = new(big.Int).Add(g.spentBy(account), value)
+	return g.store()
+}
+
+// violation returns the reason a transaction violates the rules, or an empty
+// string if it doesn't. The lock must be held.
+func (g *SigningGuard) violation(account common.Address, origin string, tx *types.Transaction) string {
+	for _, method := range g.rules.AutoApprove {
+		if method == origin {
+			return ""
+		}
+	}
+	if g.rules.MaxValue != nil && tx.Value().Cmp(g.rules.MaxValue) > 0 {
+		return fmt.Sprintf("value %v above maximum %v", tx.Value(), g.rules.MaxValue)
+	}
+	if len(g.rules.Destinations) > 0 {
+		allowed := false
+		if to := tx.To(); to != nil {
+			for _, dest := range g.rules.Destinations {
+				if dest == *to {
+					allowed = true
+					break
+				}
+			}
+		}
+		if !allowed {
+			return "destination not allowed"
+		}
+	}
+	if g.rules.DailyLimit != nil {
+		g.rollover()
+		total := new(big.Int).Add(tx.Value(), g.spentBy(account))
+		if reserved, ok := g.reserved[account]; ok {
+			total.Add(total, reserved)
+		}
+		if total.Cmp(g.rules.DailyLimit) > 0 {
+			return fmt.Sprintf("daily spending %v above limit %v", total, g.rules.DailyLimit)
+		}
+	}
+	return ""
+}
+
+// rollover resets the spending tracking if a new day started. The lock must be
+// held.
+func (g *SigningGuard) rollover() {
+	if day := time.Now().Unix() / 86400; day != g.day {
+		g.day = day
+		g.spent = make(map[common.Address]*big.Int)
+	}
+}
+
+// spentBy returns the value spent by an account during the current day. The lock
+// must be held.
+func (g *SigningGuard) spentBy(account common.Address) *big.Int {
+	if spent, ok := g.spent[account]; ok {
+		return spent
+	}
+	return new(big.Int)
+}
+
+// reserve accounts the value of an authorized transaction to the reservations of
+// the account until it's settled. The lock must be held.
+func (g *SigningGuard) reserve(account common.Address, value *big.Int) {
+	if reserved, ok := g.reserved[account]; ok {
+		g.reserved[account] = new(big.Int).Add(reserved, value)
+	} else {
+		g.reserved[account] = new(big.Int).Set(value)
+	}
+}
+
+// store persists the daily spending, if a file was configured. The lock must be
+// held.
+func (g *SigningGuard) store() error {
+	if g.path == "" {
+		return nil
+	}
+	blob, err := json.Marshal(&spending{Day: g.day, Spent: g.spent})
+	if err != nil {
+		return err
+	}
+	tmp := g.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, blob, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, g.path)
+}
+
+// Pending returns the signing requests currently awaiting approval.
+func (g *SigningGuard) Pending() []*SigningApproval {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	ids := make([]int, 0, len(g.pending))
+	for id := range g.pending {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+
+	pending := make([]*SigningApproval, len(ids))
+	for i, id := range ids {
+		pending[i] = g.pending[uint64(id)]
+	}
+	return pending
+}
+
+// Decide approves or rejects a signing request awaiting approval.
+func (g *SigningGuard) Decide(id uint64, approve bool) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	approval, ok := g.pending[id]
+	if !ok {
+		return ErrUnknownApproval
+	}
+	delete(g.pending, id)
+	approval.decision <- approve
+	return nil
+}
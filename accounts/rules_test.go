@@ -0,0 +1,158 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package accounts
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// newRulesTestTx creates a transaction transferring the given value.
+func newRulesTestTx(to common.Address, value int64) *types.Transaction {
+	return types.NewTransaction(0, to, big.NewInt(value), big.NewInt(21000), big.NewInt(1), nil)
+}
+
+// signTx authorizes a transaction and settles it as signed if it was authorized.
+func signTx(guard *SigningGuard, account common.Address, origin string, tx *types.Transaction) error {
+	if err := guard.Authorize(account, origin, tx); err != nil {
+		return err
+	}
+	return guard.Settle(account, tx, true)
+}
+
+// awaitApproval waits until a signing request is pending approval and decides on it.
+func awaitApproval(t *testing.T, guard *SigningGuard, approve bool) {
+	for i := 0; i < 100; i++ {
+		if pending := guard.Pending(); len(pending) > 0 {
+			if err := guard.Decide(pending[0].ID, approve); err != nil {
+				t.Errorf("failed to decide on approval: %v", err)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("no signing request awaiting approval")
+}
+
+// Tests that transactions within the rules are signed right away, and ones
+// violating them only after an interactive approval.
+func TestSigningRules(t *testing.T) {
+	account, friend, stranger := common.Address{0x01}, common.Address{0x02}, common.Address{0x03}
+
+	guard, _ := NewSigningGuard(&SigningRules{
+		MaxValue:     big.NewInt(100),
+		DailyLimit:   big.NewInt(150),
+		Destinations: []common.Address{friend},
+		AutoApprove:  []string{"trusted"},
+	}, "")
+	// Transactions within the rules don't need approval
+	if err := signTx(guard, account, "eth_sendTransaction", newRulesTestTx(friend, 100)); err != nil {
+		t.Fatalf("conforming transaction rejected: %v", err)
+	}
+	// Violations need approval, rejection or approval decides the outcome
+	go awaitApproval(t, guard, false)
+	if err := signTx(guard, account, "eth_sendTransaction", newRulesTestTx(stranger, 1)); err != ErrRequestRejected {
+		t.Errorf("unknown destination error mismatch: have %v, want %v", err, ErrRequestRejected)
+	}
+	go awaitApproval(t, guard, true)
+	if err := signTx(guard, account, "eth_sendTransaction", newRulesTestTx(friend, 101)); err != nil {
+		t.Errorf("approved transaction rejected: %v", err)
+	}
+	// The daily limit is reached by now, while other accounts are unaffected
+	go awaitApproval(t, guard, false)
+	if err := signTx(guard, account, "eth_sendTransaction", newRulesTestTx(friend, 1)); err != ErrRequestRejected {
+		t.Errorf("daily limit error mismatch: have %v, want %v", err, ErrRequestRejected)
+	}
+	if err := signTx(guard, friend, "eth_sendTransaction", newRulesTestTx(friend, 1)); err != nil {
+		t.Errorf("other account transaction rejected: %v", err)
+	}
+	// Auto approved origins skip the rules
+	if err := signTx(guard, account, "trusted", newRulesTestTx(stranger, 1000)); err != nil {
+		t.Errorf("auto approved transaction rejected: %v", err)
+	}
+	if err := guard.Decide(1000, true); err != ErrUnknownApproval {
+		t.Errorf("unknown approval error mismatch: have %v, want %v", err, ErrUnknownApproval)
+	}
+}
+
+// Tests that signing requests not decided on in time are rejected.
+func TestSigningRulesTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { approvalTimeout = timeout }(approvalTimeout)
+	approvalTimeout = 50 * time.Millisecond
+
+	guard, _ := NewSigningGuard(&SigningRules{MaxValue: big.NewInt(0)}, "")
+	if err := guard.Authorize(common.Address{0x01}, "eth_sendTransaction", newRulesTestTx(common.Address{}, 1)); err != ErrRequestRejected {
+		t.Errorf("timeout error mismatch: have %v, want %v", err, ErrRequestRejected)
+	}
+	if pending := guard.Pending(); len(pending) != 0 {
+		t.Errorf("timed out request still pending")
+	}
+}
+
+// Tests that only signed transactions count towards the daily limit, that pending
+// signatures reserve their value, and that the spending survives a restart.
+func TestSigningRulesSpending(t *testing.T) {
+	defer func(timeout time.Duration) { approvalTimeout = timeout }(approvalTimeout)
+	approvalTimeout = 50 * time.Millisecond
+
+	dir, err := ioutil.TempDir("", "rules-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		path    = filepath.Join(dir, "spent.json")
+		rules   = &SigningRules{DailyLimit: big.NewInt(100)}
+		account = common.Address{0x01}
+		tx      = newRulesTestTx(common.Address{0x02}, 60)
+	)
+	guard, err := NewSigningGuard(rules, path)
+	if err != nil {
+		t.Fatalf("failed to create signing guard: %v", err)
+	}
+	// Failed signatures must not burn the allowance
+	if err := guard.Authorize(account, "eth_sendTransaction", tx); err != nil {
+		t.Fatalf("conforming transaction rejected: %v", err)
+	}
+	guard.Settle(account, tx, false)
+
+	// Authorized signatures reserve their value until settled
+	if err := guard.Authorize(account, "eth_sendTransaction", tx); err != nil {
+		t.Fatalf("conforming transaction rejected after failed signature: %v", err)
+	}
+	if err := guard.Authorize(account, "eth_sendTransaction", tx); err != ErrRequestRejected {
+		t.Errorf("reserved limit error mismatch: have %v, want %v", err, ErrRequestRejected)
+	}
+	if err := guard.Settle(account, tx, true); err != nil {
+		t.Fatalf("failed to settle signed transaction: %v", err)
+	}
+	// Restart the guard and ensure the spending was retained
+	if guard, err = NewSigningGuard(rules, path); err != nil {
+		t.Fatalf("failed to recreate signing guard: %v", err)
+	}
+	if err := guard.Authorize(account, "eth_sendTransaction", tx); err != ErrRequestRejected {
+		t.Errorf("daily limit error mismatch after restart: have %v, want %v", err, ErrRequestRejected)
+	}
+}
@@ -41,6 +41,11 @@ import (
 // encoded passphrase.
 var ErrTrezorPINNeeded = errors.New("trezor: pin needed")
 
+// ErrTrezorPassphraseNeeded is returned if opening the trezor requires a passphrase
+// to access a (hidden) wallet. In this case, the calling application should ask
+// for the passphrase and send it back, an empty one selecting the standard wallet.
+var ErrTrezorPassphraseNeeded = errors.New("trezor: passphrase needed")
+
 // errTrezorReplyInvalidHeader is the error message returned by a Trezor data exchange
 // if the device replies with a mismatching header. This usually means the device
 // is in browser mode.
@@ -48,12 +53,13 @@ var errTrezorReplyInvalidHeader = errors.New("trezor: invalid reply header")
 
 // trezorDriver implements the communication with a Trezor hardware wallet.
 type trezorDriver struct {
-	device  io.ReadWriter // USB device connection to communicate through
-	version [3]uint32     // Current version of the Trezor firmware
-	label   string        // Current textual label of the Trezor device
-	pinwait bool          // Flags whether the device is waiting for PIN entry
-	failure error         // Any failure that would make the device unusable
-	log     log.Logger    // Contextual logger to tag the trezor with its id
+	device   io.ReadWriter // USB device connection to communicate through
+	version  [3]uint32     // Current version of the Trezor firmware
+	label    string        // Current textual label of the Trezor device
+	pinwait  bool          // Flags whether the device is waiting for PIN entry
+	passwait bool          // Flags whether the device is waiting for passphrase entry
+	failure  error         // Any failure that would make the device unusable
+	log      log.Logger    // Contextual logger to tag the trezor with its id
 }
 
 // newTrezorDriver creates a new instance of a Trezor USB protocol driver.
@@ -75,11 +81,14 @@ func (w *trezorDriver) Status() (string, error) {
 	if w.pinwait {
 		return fmt.Sprintf("Trezor v%d.%d.%d '%s' waiting for PIN", w.version[0], w.version[1], w.version[2], w.label), w.failure
 	}
+	if w.passwait {
+		return fmt.Sprintf("Trezor v%d.%d.%d '%s' waiting for passphrase", w.version[0], w.version[1], w.version[2], w.label), w.failure
+	}
 	return fmt.Sprintf("Trezor v%d.%d.%d '%s' online", w.version[0], w.version[1], w.version[2], w.label), w.failure
 }
 
 // Open implements usbwallet.driver, attempting to initialize the connection to
-// the Trezor hardware wallet. Initializing the Trezor is a two phase operation:
+// the Trezor hardware wallet. Initializing the Trezor is a two or three phase operation:
 //  * The first phase is to initialize the connection and read the wallet's
 //    features. This phase is invoked is the provided passphrase is empty. The
 //    device will display the pinpad as a result and will return an appropriate
@@ -87,9 +96,22 @@ func (w *trezorDriver) Status() (string, error) {
 //  * The second phase is to unlock access to the Trezor, which is done by the
 //    user actually providing a passphrase mapping a keyboard keypad to the pin
 //    number of the user (shuffled according to the pinpad displayed).
+//  * If the Trezor has passphrase protection enabled, a third phase provides the
+//    passphrase of the wallet to access, which is also done through a second (or
+//    third) open, an empty passphrase selecting the standard wallet.
 func (w *trezorDriver) Open(device io.ReadWriter, passphrase string) error {
 	w.device, w.failure = device, nil
 
+	// If the device is waiting for the wallet passphrase, send it over
+	if w.passwait {
+		w.passwait = false
+
+		if _, err := w.trezorExchange(&trezor.PassphraseAck{Passphrase: &passphrase}, new(trezor.Success)); err != nil {
+			w.failure = err
+			return err
+		}
+		return nil
+	}
 	// If phase 1 is requested, init the connection and wait for user callback
 	if passphrase == "" {
 		// If we're already waiting for a PIN entry, insta-return
@@ -104,33 +126,42 @@ func (w *trezorDriver) Open(device io.ReadWriter, passphrase string) error {
 		w.version = [3]uint32{features.GetMajorVersion(), features.GetMinorVersion(), features.GetPatchVersion()}
 		w.label = features.GetLabel()
 
-		// Do a manual ping, forcing the device to ask for its PIN
-		askPin := true
-		res, err := w.trezorExchange(&trezor.Ping{PinProtection: &askPin}, new(trezor.PinMatrixRequest), new(trezor.Success))
+		// Do a manual ping, forcing the device to ask for its PIN and passphrase
+		askPin, askPassphrase := true, true
+		res, err := w.trezorExchange(&trezor.Ping{PinProtection: &askPin, PassphraseProtection: &askPassphrase}, new(trezor.PinMatrixRequest), new(trezor.PassphraseRequest), new(trezor.Success))
 		if err != nil {
 			return err
 		}
-		// Only return the PIN request if the device wasn't unlocked until now
-		if res == 1 {
-			return nil // Device responded with trezor.Success
+		// Only return the PIN or passphrase request if the device wasn't unlocked until now
+		switch res {
+		case 0:
+			w.pinwait = true
+			return ErrTrezorPINNeeded
+		case 1:
+			w.passwait = true
+			return ErrTrezorPassphraseNeeded
 		}
-		w.pinwait = true
-		return ErrTrezorPINNeeded
+		return nil // Device responded with trezor.Success
 	}
 	// Phase 2 requested with actual PIN entry
 	w.pinwait = false
 
-	if _, err := w.trezorExchange(&trezor.PinMatrixAck{Pin: &passphrase}, new(trezor.Success)); err != nil {
+	res, err := w.trezorExchange(&trezor.PinMatrixAck{Pin: &passphrase}, new(trezor.PassphraseRequest), new(trezor.Success))
+	if err != nil {
 		w.failure = err
 		return err
 	}
+	if res == 0 {
+		w.passwait = true
+		return ErrTrezorPassphraseNeeded
+	}
 	return nil
 }
 
 // Close implements usbwallet.driver, cleaning up and metadata maintained within
 // the Trezor driver.
 func (w *trezorDriver) Close() error {
-	w.version, w.label, w.pinwait = [3]uint32{}, "", false
+	w.version, w.label, w.pinwait, w.passwait = [3]uint32{}, "", false, false
 	return nil
 }
 
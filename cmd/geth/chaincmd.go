@@ -20,8 +20,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -29,7 +31,6 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/console"
 	"github.com/ethereum/go-ethereum/core"
-	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/ethdb"
@@ -66,6 +67,10 @@ It expects the genesis file as argument.`,
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
 			utils.CacheFlag,
+			utils.CacheDatabaseFlag,
+			utils.CacheTrieFlag,
+			utils.CacheCodeFlag,
+			utils.CacheBlocksFlag,
 			utils.LightModeFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
@@ -122,6 +127,24 @@ The first argument must be the directory containing the blockchain to download f
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
 Remove blockchain and state databases`,
+	}
+	migratedbCommand = cli.Command{
+		Action:    utils.MigrateFlags(migrateDB),
+		Name:      "migratedb",
+		Usage:     "Convert the blockchain and state databases to another engine",
+		ArgsUsage: "<engine>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.CacheFlag,
+			utils.TestnetFlag,
+			utils.RinkebyFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The migratedb command copies the content of the chain databases into new ones
+using the given database engine (` + strings.Join(ethdb.Engines, ", ") + `), replacing them.
+The original databases are kept alongside with a .bak suffix, to be removed by
+the user once the node runs fine with the new ones. The node must not be running.`,
 	}
 	dumpCommand = cli.Command{
 		Action:    utils.MigrateFlags(dump),
@@ -215,13 +238,14 @@ func importChain(ctx *cli.Context) error {
 	fmt.Printf("Import done in %v.\n\n", time.Since(start))
 
 	// Output pre-compaction stats mostly to see the import trashing
-	db := chainDb.(*ethdb.LDBDatabase)
-
-	stats, err := db.LDB().GetProperty("leveldb.stats")
-	if err != nil {
-		utils.Fatalf("Failed to read database stats: %v", err)
+	db, isLDB := core.KeyValueStore(chainDb).(*ethdb.LDBDatabase)
+	if isLDB {
+		stats, err := db.LDB().GetProperty("leveldb.stats")
+		if err != nil {
+			utils.Fatalf("Failed to read database stats: %v", err)
+		}
+		fmt.Println(stats)
 	}
-	fmt.Println(stats)
 	fmt.Printf("Trie cache misses:  %d\n", trie.CacheMisses())
 	fmt.Printf("Trie cache unloads: %d\n\n", trie.CacheUnloads())
 
@@ -234,19 +258,19 @@ func importChain(ctx *cli.Context) error {
 	fmt.Printf("Allocations:   %.3f million\n", float64(mem.Mallocs)/1000000)
 	fmt.Printf("GC pause:      %v\n\n", time.Duration(mem.PauseTotalNs))
 
-	if ctx.GlobalIsSet(utils.NoCompactionFlag.Name) {
+	if ctx.GlobalIsSet(utils.NoCompactionFlag.Name) || !isLDB {
 		return nil
 	}
 
 	// Compact the entire database to more accurately measure disk io and print the stats
 	start = time.Now()
 	fmt.Println("Compacting entire database...")
-	if err = db.LDB().CompactRange(util.Range{}); err != nil {
+	if err := db.LDB().CompactRange(util.Range{}); err != nil {
 		utils.Fatalf("Compaction failed: %v", err)
 	}
 	fmt.Printf("Compaction done in %v.\n\n", time.Since(start))
 
-	stats, err = db.LDB().GetProperty("leveldb.stats")
+	stats, err := db.LDB().GetProperty("leveldb.stats")
 	if err != nil {
 		utils.Fatalf("Failed to read database stats: %v", err)
 	}
@@ -325,12 +349,14 @@ func copyDb(ctx *cli.Context) error {
 	fmt.Printf("Database copy done in %v\n", time.Since(start))
 
 	// Compact the entire database to remove any sync overhead
-	start = time.Now()
-	fmt.Println("Compacting entire database...")
-	if err = chainDb.(*ethdb.LDBDatabase).LDB().CompactRange(util.Range{}); err != nil {
-		utils.Fatalf("Compaction failed: %v", err)
+	if db, ok := core.KeyValueStore(chainDb).(*ethdb.LDBDatabase); ok {
+		start = time.Now()
+		fmt.Println("Compacting entire database...")
+		if err = db.LDB().CompactRange(util.Range{}); err != nil {
+			utils.Fatalf("Compaction failed: %v", err)
+		}
+		fmt.Printf("Compaction done in %v.\n\n", time.Since(start))
 	}
-	fmt.Printf("Compaction done in %v.\n\n", time.Since(start))
 
 	return nil
 }
@@ -364,6 +390,96 @@ func removeDB(ctx *cli.Context) error {
 	return nil
 }
 
+func migrateDB(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires the target database engine as argument.")
+	}
+	engine := ctx.Args().First()
+
+	stack, _ := makeConfigNode(ctx)
+	for _, name := range []string{"chaindata", "lightchaindata"} {
+		logger := log.New("database", name)
+
+		dbdir := stack.ResolvePath(name)
+		current := ethdb.DetectEngine(dbdir)
+		switch {
+		case current == "":
+			logger.Info("Database doesn't exist, skipping", "path", dbdir)
+			continue
+		case current == engine:
+			logger.Info("Database already uses the engine, skipping", "engine", engine)
+			continue
+		}
+		if err := migrateDatabase(dbdir, current, engine, ctx.GlobalInt(utils.CacheFlag.Name)); err != nil {
+			utils.Fatalf("Failed to migrate %s database: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// migrateDatabase converts the database stored in dbdir from one engine to the
+// other, moving the original database aside and the ancient store over.
+func migrateDatabase(dbdir string, from, to string, cache int) error {
+	logger := log.New("database", dbdir)
+
+	src, err := ethdb.Open(from, dbdir, cache, 256)
+	if err != nil {
+		return err
+	}
+	tmpdir, backup := dbdir+".migrating", dbdir+".bak"
+	if common.FileExist(tmpdir) {
+		src.Close()
+		return fmt.Errorf("leftover of an interrupted migration found at %s, remove it first", tmpdir)
+	}
+	if common.FileExist(backup) {
+		src.Close()
+		return fmt.Errorf("backup of a previous migration found at %s, remove it first", backup)
+	}
+	dst, err := ethdb.Open(to, tmpdir, cache, 256)
+	if err != nil {
+		src.Close()
+		return err
+	}
+	logger.Info("Migrating database", "from", from, "to", to)
+	start := time.Now()
+
+	count, err := ethdb.Migrate(dst, src)
+	src.Close()
+	dst.Close()
+	if err != nil {
+		return err
+	}
+	// Swap the databases, keeping the ancient store in the new one
+	ancient, moved := filepath.Join(dbdir, "ancient"), false
+	if common.FileExist(ancient) {
+		if err := os.Rename(ancient, filepath.Join(tmpdir, "ancient")); err != nil {
+			return err
+		}
+		moved = true
+	}
+	// restore moves the ancient store back into the original database on failure
+	restore := func(err error) error {
+		if moved {
+			if rerr := os.Rename(filepath.Join(tmpdir, "ancient"), ancient); rerr != nil {
+				logger.Error("Failed to restore ancient store", "path", ancient, "err", rerr)
+			}
+		}
+		return err
+	}
+	if err := os.Rename(dbdir, backup); err != nil {
+		return restore(err)
+	}
+	if err := os.Rename(tmpdir, dbdir); err != nil {
+		if rerr := os.Rename(backup, dbdir); rerr != nil {
+			logger.Error("Failed to restore original database", "path", dbdir, "backup", backup, "err", rerr)
+			return err
+		}
+		return restore(err)
+	}
+	logger.Info("Database successfully migrated", "entries", count, "backup", backup, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
 func dump(ctx *cli.Context) error {
 	stack := makeFullNode(ctx)
 	chain, chainDb := utils.MakeChain(ctx, stack)
@@ -379,7 +495,7 @@ func dump(ctx *cli.Context) error {
 			fmt.Println("{}")
 			utils.Fatalf("block not found")
 		} else {
-			state, err := state.New(block.Root(), state.NewDatabase(chainDb))
+			state, err := chain.StateAt(block.Root())
 			if err != nil {
 				utils.Fatalf("could not create new state: %v", err)
 			}
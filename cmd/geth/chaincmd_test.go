@@ -0,0 +1,58 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// Tests that a database migration refuses to run if the backup of a previous one
+// is in the way, leaving the original database and its ancient store untouched.
+func TestMigrateDatabaseBackupExists(t *testing.T) {
+	datadir := tmpdir(t)
+	defer os.RemoveAll(datadir)
+
+	dbdir := filepath.Join(datadir, "chaindata")
+	db, err := ethdb.Open(ethdb.EngineLevelDB, dbdir, 16, 16)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	db.Put([]byte("key"), []byte("value"))
+	db.Close()
+
+	ancient := filepath.Join(dbdir, "ancient")
+	if err := os.Mkdir(ancient, 0700); err != nil {
+		t.Fatalf("failed to create ancient store: %v", err)
+	}
+	if err := os.Mkdir(dbdir+".bak", 0700); err != nil {
+		t.Fatalf("failed to create stale backup: %v", err)
+	}
+	if err := migrateDatabase(dbdir, ethdb.EngineLevelDB, ethdb.EngineMemory, 16); err == nil {
+		t.Fatalf("migration succeeded with a stale backup in the way")
+	}
+	if !common.FileExist(ancient) {
+		t.Errorf("ancient store moved out of the original database")
+	}
+	if common.FileExist(dbdir + ".migrating") {
+		t.Errorf("migration target created despite the refusal")
+	}
+}
@@ -76,12 +76,19 @@ type ethstatsConfig struct {
 	URL string `toml:",omitempty"`
 }
 
+// servicesConfig selects the optional services to run along with the eth protocol.
+type servicesConfig struct {
+	Whisper   bool `toml:",omitempty"`
+	Dashboard bool `toml:",omitempty"`
+}
+
 type gethConfig struct {
 	Eth       eth.Config
 	Shh       whisper.Config
 	Node      node.Config
 	Ethstats  ethstatsConfig
 	Dashboard dashboard.Config
+	Services  servicesConfig
 }
 
 func loadConfig(file string, cfg *gethConfig) error {
@@ -139,6 +146,13 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
 	utils.SetShhConfig(ctx, stack, &cfg.Shh)
 	utils.SetDashboardConfig(ctx, &cfg.Dashboard)
 
+	// Whisper is enabled by specifying at least 1 whisper flag or in dev mode
+	if enableWhisper(ctx) || (!ctx.GlobalIsSet(utils.WhisperEnabledFlag.Name) && ctx.GlobalIsSet(utils.DeveloperFlag.Name)) {
+		cfg.Services.Whisper = true
+	}
+	if ctx.GlobalIsSet(utils.DashboardEnabledFlag.Name) {
+		cfg.Services.Dashboard = ctx.GlobalBool(utils.DashboardEnabledFlag.Name)
+	}
 	return stack, cfg
 }
 
@@ -157,19 +171,11 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 
 	utils.RegisterEthService(stack, &cfg.Eth)
 
-	if ctx.GlobalBool(utils.DashboardEnabledFlag.Name) {
+	if cfg.Services.Dashboard {
 		utils.RegisterDashboardService(stack, &cfg.Dashboard)
 	}
-	// Whisper must be explicitly enabled by specifying at least 1 whisper flag or in dev mode
-	shhEnabled := enableWhisper(ctx)
-	shhAutoEnabled := !ctx.GlobalIsSet(utils.WhisperEnabledFlag.Name) && ctx.GlobalIsSet(utils.DeveloperFlag.Name)
-	if shhEnabled || shhAutoEnabled {
-		if ctx.GlobalIsSet(utils.WhisperMaxMessageSizeFlag.Name) {
-			cfg.Shh.MaxMessageSize = uint32(ctx.Int(utils.WhisperMaxMessageSizeFlag.Name))
-		}
-		if ctx.GlobalIsSet(utils.WhisperMinPOWFlag.Name) {
-			cfg.Shh.MinimumAcceptedPOW = ctx.Float64(utils.WhisperMinPOWFlag.Name)
-		}
+	// Whisper must be explicitly enabled, its flags are already applied to the config
+	if cfg.Services.Whisper {
 		utils.RegisterShhService(stack, &cfg.Shh)
 	}
 
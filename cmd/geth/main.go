@@ -59,8 +59,18 @@ var (
 		utils.BootnodesV4Flag,
 		utils.BootnodesV5Flag,
 		utils.DataDirFlag,
+		utils.AncientFlag,
+		utils.DBCompactionFlag,
+		utils.DBEngineFlag,
 		utils.KeyStoreDirFlag,
 		utils.NoUSBFlag,
+		utils.ExternalSignerFlag,
+		utils.SignAuditLogFlag,
+		utils.SigningRulesFlag,
+		utils.PluginsFlag,
+		utils.PluginFilesFlag,
+		utils.ShutdownTimeoutFlag,
+		utils.ServiceStopTimeoutFlag,
 		utils.DashboardEnabledFlag,
 		utils.DashboardAddrFlag,
 		utils.DashboardPortFlag,
@@ -72,12 +82,14 @@ var (
 		utils.EthashDatasetDirFlag,
 		utils.EthashDatasetsInMemoryFlag,
 		utils.EthashDatasetsOnDiskFlag,
+		utils.TxPoolLocalsFlag,
 		utils.TxPoolNoLocalsFlag,
 		utils.TxPoolJournalFlag,
 		utils.TxPoolRejournalFlag,
 		utils.TxPoolPriceLimitFlag,
 		utils.TxPoolPriceBumpFlag,
 		utils.TxPoolAccountSlotsFlag,
+		utils.TxPoolAccountPendingFlag,
 		utils.TxPoolGlobalSlotsFlag,
 		utils.TxPoolAccountQueueFlag,
 		utils.TxPoolGlobalQueueFlag,
@@ -85,10 +97,32 @@ var (
 		utils.FastSyncFlag,
 		utils.LightModeFlag,
 		utils.SyncModeFlag,
+		utils.GCModeFlag,
+		utils.SafeDepthFlag,
+		utils.TxLookupLimitFlag,
+		utils.StateSnapshotsFlag,
+		utils.OverrideByzantiumFlag,
 		utils.LightServFlag,
 		utils.LightPeersFlag,
+		utils.LightPriorityFlag,
+		utils.LightQuotaFlag,
+		utils.LightPayeeFlag,
+		utils.LightPriceFlag,
+		utils.LightCheckpointFlag,
+		utils.LightCacheFlag,
+		utils.LightIndexFlag,
 		utils.LightKDFFlag,
+		utils.KeyStoreScryptNFlag,
+		utils.KeyStoreScryptPFlag,
+		utils.ServeLimitFlag,
+		utils.ServeNoStateFlag,
+		utils.WhitelistFlag,
 		utils.CacheFlag,
+		utils.CacheDatabaseFlag,
+		utils.CacheTrieFlag,
+		utils.CacheCodeFlag,
+		utils.CacheBlocksFlag,
+		utils.CachePreimagesFlag,
 		utils.TrieCacheGenFlag,
 		utils.ListenPortFlag,
 		utils.MaxPeersFlag,
@@ -98,6 +132,7 @@ var (
 		utils.MinerThreadsFlag,
 		utils.MiningEnabledFlag,
 		utils.TargetGasLimitFlag,
+		utils.MinerStratumFlag,
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
 		utils.DiscoveryV5Flag,
@@ -109,10 +144,20 @@ var (
 		utils.TestnetFlag,
 		utils.RinkebyFlag,
 		utils.VMEnableDebugFlag,
+		utils.VMStatsFlag,
+		utils.VMProfileLabelsFlag,
+		utils.VMEWASMFlag,
 		utils.NetworkIdFlag,
 		utils.RPCCORSDomainFlag,
 		utils.EthStatsURLFlag,
 		utils.MetricsEnabledFlag,
+		utils.MetricsPrometheusFlag,
+		utils.MetricsInfluxDBEndpointFlag,
+		utils.MetricsInfluxDBDatabaseFlag,
+		utils.MetricsInfluxDBUsernameFlag,
+		utils.MetricsInfluxDBPasswordFlag,
+		utils.MetricsInfluxDBTagsFlag,
+		utils.MetricsInfluxDBIntervalFlag,
 		utils.FakePoWFlag,
 		utils.NoCompactionFlag,
 		utils.GpoBlocksFlag,
@@ -126,6 +171,7 @@ var (
 		utils.RPCListenAddrFlag,
 		utils.RPCPortFlag,
 		utils.RPCApiFlag,
+		utils.RPCCallWorkersFlag,
 		utils.WSEnabledFlag,
 		utils.WSListenAddrFlag,
 		utils.WSPortFlag,
@@ -154,6 +200,7 @@ func init() {
 		exportCommand,
 		copydbCommand,
 		removedbCommand,
+		migratedbCommand,
 		dumpCommand,
 		// See monitorcmd.go:
 		monitorCommand,
@@ -188,8 +235,10 @@ func init() {
 		}
 		// Start system runtime metrics collection
 		go metrics.CollectProcessMetrics(3 * time.Second)
+		utils.SetupMetrics(ctx)
 
 		utils.SetupNetwork(ctx)
+		utils.SetupInterpreters(ctx)
 		return nil
 	}
 
@@ -68,17 +68,44 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: []cli.Flag{
 			configFileFlag,
 			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.DBCompactionFlag,
+			utils.DBEngineFlag,
 			utils.KeyStoreDirFlag,
 			utils.NoUSBFlag,
+			utils.ExternalSignerFlag,
+			utils.SignAuditLogFlag,
+			utils.SigningRulesFlag,
+			utils.PluginsFlag,
+			utils.PluginFilesFlag,
+			utils.ShutdownTimeoutFlag,
+			utils.ServiceStopTimeoutFlag,
 			utils.NetworkIdFlag,
 			utils.TestnetFlag,
 			utils.RinkebyFlag,
 			utils.SyncModeFlag,
+			utils.GCModeFlag,
+			utils.SafeDepthFlag,
+			utils.TxLookupLimitFlag,
+			utils.StateSnapshotsFlag,
+			utils.OverrideByzantiumFlag,
 			utils.EthStatsURLFlag,
 			utils.IdentityFlag,
 			utils.LightServFlag,
 			utils.LightPeersFlag,
+			utils.LightPriorityFlag,
+			utils.LightQuotaFlag,
+			utils.LightPayeeFlag,
+			utils.LightPriceFlag,
+			utils.LightCheckpointFlag,
+			utils.LightCacheFlag,
+			utils.LightIndexFlag,
 			utils.LightKDFFlag,
+			utils.KeyStoreScryptNFlag,
+			utils.KeyStoreScryptPFlag,
+			utils.ServeLimitFlag,
+			utils.ServeNoStateFlag,
+			utils.WhitelistFlag,
 		},
 	},
 	{Name: "DEVELOPER CHAIN",
@@ -111,12 +138,14 @@ var AppHelpFlagGroups = []flagGroup{
 	{
 		Name: "TRANSACTION POOL",
 		Flags: []cli.Flag{
+			utils.TxPoolLocalsFlag,
 			utils.TxPoolNoLocalsFlag,
 			utils.TxPoolJournalFlag,
 			utils.TxPoolRejournalFlag,
 			utils.TxPoolPriceLimitFlag,
 			utils.TxPoolPriceBumpFlag,
 			utils.TxPoolAccountSlotsFlag,
+			utils.TxPoolAccountPendingFlag,
 			utils.TxPoolGlobalSlotsFlag,
 			utils.TxPoolAccountQueueFlag,
 			utils.TxPoolGlobalQueueFlag,
@@ -127,6 +156,11 @@ var AppHelpFlagGroups = []flagGroup{
 		Name: "PERFORMANCE TUNING",
 		Flags: []cli.Flag{
 			utils.CacheFlag,
+			utils.CacheDatabaseFlag,
+			utils.CacheTrieFlag,
+			utils.CacheCodeFlag,
+			utils.CacheBlocksFlag,
+			utils.CachePreimagesFlag,
 			utils.TrieCacheGenFlag,
 		},
 	},
@@ -144,6 +178,7 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.RPCListenAddrFlag,
 			utils.RPCPortFlag,
 			utils.RPCApiFlag,
+			utils.RPCCallWorkersFlag,
 			utils.WSEnabledFlag,
 			utils.WSListenAddrFlag,
 			utils.WSPortFlag,
@@ -183,6 +218,7 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.TargetGasLimitFlag,
 			utils.GasPriceFlag,
 			utils.ExtraDataFlag,
+			utils.MinerStratumFlag,
 		},
 	},
 	{
@@ -196,12 +232,22 @@ var AppHelpFlagGroups = []flagGroup{
 		Name: "VIRTUAL MACHINE",
 		Flags: []cli.Flag{
 			utils.VMEnableDebugFlag,
+			utils.VMStatsFlag,
+			utils.VMProfileLabelsFlag,
+			utils.VMEWASMFlag,
 		},
 	},
 	{
 		Name: "LOGGING AND DEBUGGING",
 		Flags: append([]cli.Flag{
 			utils.MetricsEnabledFlag,
+			utils.MetricsPrometheusFlag,
+			utils.MetricsInfluxDBEndpointFlag,
+			utils.MetricsInfluxDBDatabaseFlag,
+			utils.MetricsInfluxDBUsernameFlag,
+			utils.MetricsInfluxDBPasswordFlag,
+			utils.MetricsInfluxDBTagsFlag,
+			utils.MetricsInfluxDBIntervalFlag,
 			utils.FakePoWFlag,
 			utils.NoCompactionFlag,
 		}, debug.Flags...),
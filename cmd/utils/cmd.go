@@ -18,6 +18,7 @@
 package utils
 
 import (
+	"bytes"
 	"compress/gzip"
 	"fmt"
 	"io"
@@ -25,7 +26,9 @@ import (
 	"os/signal"
 	"runtime"
 	"strings"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/internal/debug"
@@ -35,7 +38,8 @@ import (
 )
 
 const (
-	importBatchSize = 2500
+	importBatchSize      = 2500
+	importReportInterval = 8 * time.Second // Time interval between import progress reports
 )
 
 // Fatalf formats a message to standard error and exits the program.
@@ -80,6 +84,10 @@ func StartNode(stack *node.Node) {
 	}()
 }
 
+// ImportChain imports the RLP encoded (optionally gzipped) chain from the given
+// file. Blocks already present in the canonical chain are skipped without being
+// fully decoded, so an interrupted import can be resumed by simply running it
+// again on the same file.
 func ImportChain(chain *core.BlockChain, fn string) error {
 	// Watch for Ctrl-C while the import is running.
 	// If a signal is received, the import will stop at the next batch.
@@ -110,7 +118,11 @@ func ImportChain(chain *core.BlockChain, fn string) error {
 	}
 	defer fh.Close()
 
-	var reader io.Reader = fh
+	stats := &importStats{start: time.Now(), logged: time.Now(), file: &countingReader{r: fh}}
+	if info, err := fh.Stat(); err == nil {
+		stats.size = info.Size()
+	}
+	var reader io.Reader = stats.file
 	if strings.HasSuffix(fn, ".gz") {
 		if reader, err = gzip.NewReader(reader); err != nil {
 			return err
@@ -120,55 +132,132 @@ func ImportChain(chain *core.BlockChain, fn string) error {
 	stream := rlp.NewStream(reader, 0)
 
 	// Run actual the import.
-	blocks := make(types.Blocks, importBatchSize)
+	blocks := make(types.Blocks, 0, importBatchSize)
 	n := 0
-	for batch := 0; ; batch++ {
-		// Load a batch of RLP blocks.
+	for eof := false; !eof; {
+		// Load a batch of RLP blocks, skipping the ones already imported.
 		if checkInterrupt() {
 			return fmt.Errorf("interrupted")
 		}
-		i := 0
-		for ; i < importBatchSize; i++ {
-			var b types.Block
-			if err := stream.Decode(&b); err == io.EOF {
+		blocks = blocks[:0]
+		for len(blocks) < importBatchSize {
+			raw, err := stream.Raw()
+			if err == io.EOF {
+				eof = true
 				break
 			} else if err != nil {
 				return fmt.Errorf("at block %d: %v", n, err)
 			}
-			// don't import first block
-			if b.NumberU64() == 0 {
-				i--
+			n++
+
+			known, err := knownBlock(chain, raw)
+			if err != nil {
+				return fmt.Errorf("at block %d: %v", n, err)
+			}
+			if known {
+				stats.skipped++
+				stats.report(chain, false)
 				continue
 			}
-			blocks[i] = &b
-			n++
+			b := new(types.Block)
+			if err := rlp.DecodeBytes(raw, b); err != nil {
+				return fmt.Errorf("at block %d: %v", n, err)
+			}
+			blocks = append(blocks, b)
 		}
-		if i == 0 {
-			break
+		if len(blocks) == 0 {
+			continue
 		}
 		// Import the batch.
 		if checkInterrupt() {
 			return fmt.Errorf("interrupted")
 		}
-		if hasAllBlocks(chain, blocks[:i]) {
-			log.Info("Skipping batch as all blocks present", "batch", batch, "first", blocks[0].Hash(), "last", blocks[i-1].Hash())
-			continue
-		}
-
-		if _, err := chain.InsertChain(blocks[:i]); err != nil {
+		if _, err := chain.InsertChain(blocks); err != nil {
 			return fmt.Errorf("invalid block %d: %v", n, err)
 		}
+		stats.imported += len(blocks)
+		stats.report(chain, false)
 	}
+	stats.report(chain, true)
 	return nil
 }
 
-func hasAllBlocks(chain *core.BlockChain, bs []*types.Block) bool {
-	for _, b := range bs {
-		if !chain.HasBlock(b.Hash(), b.NumberU64()) {
-			return false
+// knownBlock decodes only the header of an RLP encoded block and checks whether
+// it is the genesis block or already part of the local canonical chain.
+func knownBlock(chain *core.BlockChain, raw []byte) (bool, error) {
+	content, _, err := rlp.SplitList(raw)
+	if err != nil {
+		return false, err
+	}
+	header := new(types.Header)
+	if err := rlp.NewStream(bytes.NewReader(content), 0).Decode(header); err != nil {
+		return false, err
+	}
+	number := header.Number.Uint64()
+	if number == 0 {
+		return true, nil
+	}
+	if number > chain.CurrentBlock().NumberU64() {
+		return false, nil
+	}
+	canon := chain.GetHeaderByNumber(number)
+	return canon != nil && canon.Hash() == header.Hash(), nil
+}
+
+// importStats tracks and periodically reports the progress of a chain import.
+type importStats struct {
+	start    time.Time       // Time when the import was started
+	logged   time.Time       // Time when the progress was last reported
+	file     *countingReader // Input file, tracking the number of bytes consumed
+	size     int64           // Total size of the input file (0 if unknown)
+	imported int             // Number of blocks inserted into the chain
+	skipped  int             // Number of blocks skipped as already imported
+}
+
+// report logs the current import progress if enough time elapsed since the last
+// report, or unconditionally if forced.
+func (st *importStats) report(chain *core.BlockChain, force bool) {
+	now := time.Now()
+	if !force && now.Sub(st.logged) < importReportInterval {
+		return
+	}
+	st.logged = now
+
+	var (
+		elapsed = now.Sub(st.start)
+		head    = chain.CurrentBlock()
+		speed   = float64(st.imported+st.skipped) / elapsed.Seconds()
+		context = []interface{}{
+			"number", head.Number(), "hash", head.Hash(), "imported", st.imported, "skipped", st.skipped,
+			"blocks/s", fmt.Sprintf("%.2f", speed), "elapsed", common.PrettyDuration(elapsed),
 		}
+	)
+	if force {
+		log.Info("Imported blockchain", context...)
+		return
 	}
-	return true
+	if read := st.file.Count(); st.size > 0 && read > 0 {
+		eta := time.Duration(float64(elapsed) * float64(st.size-read) / float64(read))
+		context = append(context, "progress", fmt.Sprintf("%.2f%%", 100*float64(read)/float64(st.size)), "eta", common.PrettyDuration(eta))
+	}
+	log.Info("Importing blockchain", context...)
+}
+
+// countingReader wraps a reader, counting the number of bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// Count returns the number of bytes read so far.
+func (r *countingReader) Count() int64 {
+	return r.n
 }
 
 func ExportChain(blockchain *core.BlockChain, fn string) error {
@@ -0,0 +1,89 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// newTestChain creates a blockchain with the given number of blocks imported.
+func newTestChain(t *testing.T, blocks int) *core.BlockChain {
+	db, _ := ethdb.NewMemDatabase()
+	genesis := new(core.Genesis).MustCommit(db)
+
+	chain, err := core.NewBlockChain(db, nil, params.TestChainConfig, ethash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	gendb, _ := ethdb.NewMemDatabase()
+	new(core.Genesis).MustCommit(gendb)
+	bs, _ := core.GenerateChain(params.TestChainConfig, genesis, gendb, blocks, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(common.Address{1})
+	})
+	if n, err := chain.InsertChain(bs); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	return chain
+}
+
+// Tests that a gzipped chain export can be imported on top of a partially synced
+// chain, skipping the already known blocks and resuming after them.
+func TestImportChainResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geth-import")
+	if err != nil {
+		t.Fatalf("failed to create temporary folder: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Export a full chain into a gzipped file
+	source := newTestChain(t, 32)
+	defer source.Stop()
+
+	file := filepath.Join(dir, "chain.rlp.gz")
+	if err := ExportChain(source, file); err != nil {
+		t.Fatalf("failed to export chain: %v", err)
+	}
+	// Simulate an interrupted import by pre-importing the first part of the chain
+	chain := newTestChain(t, 0)
+	defer chain.Stop()
+
+	var prefix []*types.Block
+	for i := uint64(1); i <= 10; i++ {
+		prefix = append(prefix, source.GetBlockByNumber(i))
+	}
+	if n, err := chain.InsertChain(prefix); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	// Resume the import and ensure the entire chain is present
+	if err := ImportChain(chain, file); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	if head, want := chain.CurrentBlock(), source.CurrentBlock(); head.Hash() != want.Hash() {
+		t.Fatalf("head mismatch: have #%d [%x], want #%d [%x]", head.NumberU64(), head.Hash(), want.NumberU64(), want.Hash())
+	}
+}
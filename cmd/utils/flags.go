@@ -27,16 +27,19 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/clique"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/ewasm"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/dashboard"
 	"github.com/ethereum/go-ethereum/eth"
@@ -47,6 +50,8 @@ import (
 	"github.com/ethereum/go-ethereum/les"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/metrics/influxdb"
+	"github.com/ethereum/go-ethereum/metrics/prometheus"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/discover"
@@ -55,6 +60,7 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/netutil"
 	"github.com/ethereum/go-ethereum/params"
 	whisper "github.com/ethereum/go-ethereum/whisper/whisperv5"
+	gometrics "github.com/rcrowley/go-metrics"
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -117,6 +123,18 @@ var (
 		Usage: "Data directory for the databases and keystore",
 		Value: DirectoryString{node.DefaultDataDir()},
 	}
+	AncientFlag = DirectoryFlag{
+		Name:  "datadir.ancient",
+		Usage: "Data directory for ancient chain segments (default = inside chaindata)",
+	}
+	DBCompactionFlag = cli.StringFlag{
+		Name:  "db.compaction",
+		Usage: "Daily local time window to compact the chain database in (e.g. 03:00-05:00)",
+	}
+	DBEngineFlag = cli.StringFlag{
+		Name:  "db.engine",
+		Usage: "Database engine for new databases (" + strings.Join(ethdb.Engines, ", ") + "; default = leveldb, or the existing one)",
+	}
 	KeyStoreDirFlag = DirectoryFlag{
 		Name:  "keystore",
 		Usage: "Directory for the keystore (default = inside the datadir)",
@@ -125,6 +143,34 @@ var (
 		Name:  "nousb",
 		Usage: "Disables monitoring for and managing USB hardware wallets",
 	}
+	ExternalSignerFlag = cli.StringFlag{
+		Name:  "signer",
+		Usage: "IPC endpoint of an external signer to forward signing requests to",
+	}
+	SignAuditLogFlag = cli.StringFlag{
+		Name:  "signauditlog",
+		Usage: "File to record all signing operations into (relative paths are placed inside the datadir)",
+	}
+	SigningRulesFlag = cli.StringFlag{
+		Name:  "signrules",
+		Usage: "JSON file with the rules transactions must satisfy to be signed without interactive approval",
+	}
+	PluginsFlag = cli.StringFlag{
+		Name:  "plugins",
+		Usage: "Comma separated list of registered service plugins to run",
+	}
+	PluginFilesFlag = cli.StringFlag{
+		Name:  "pluginfiles",
+		Usage: "Comma separated list of Go plugin files (.so) registering service plugins",
+	}
+	ShutdownTimeoutFlag = cli.DurationFlag{
+		Name:  "shutdown.timeout",
+		Usage: "Maximum time to wait for the node to shut down gracefully (0 = unlimited)",
+	}
+	ServiceStopTimeoutFlag = cli.DurationFlag{
+		Name:  "shutdown.servicetimeout",
+		Usage: "Maximum time to wait for each service to stop during shutdown (0 = unlimited)",
+	}
 	NetworkIdFlag = cli.Uint64Flag{
 		Name:  "networkid",
 		Usage: "Network identifier (integer, 1=Frontier, 2=Morden (disused), 3=Ropsten, 4=Rinkeby)",
@@ -169,6 +215,32 @@ var (
 		Usage: `Blockchain sync mode ("fast", "full", or "light")`,
 		Value: &defaultSyncMode,
 	}
+	GCModeFlag = cli.StringFlag{
+		Name:  "gcmode",
+		Usage: `Blockchain garbage collection mode ("full", "archive")`,
+		Value: "full",
+	}
+	CachePreimagesFlag = cli.BoolFlag{
+		Name:  "cache.preimages",
+		Usage: "Enable recording the SHA3/keccak preimages of trie keys (always on in archive mode)",
+	}
+	SafeDepthFlag = cli.Uint64Flag{
+		Name:  "safedepth",
+		Usage: "Number of blocks below the head after which blocks are considered safe (default = network specific)",
+	}
+	TxLookupLimitFlag = cli.Uint64Flag{
+		Name:  "txlookuplimit",
+		Usage: "Number of recent blocks to maintain transactions index for (default = index all blocks)",
+		Value: 0,
+	}
+	StateSnapshotsFlag = cli.BoolFlag{
+		Name:  "snapshots",
+		Usage: "Generate flat state snapshots every 30000 blocks for fast syncing peers (only the latest is kept)",
+	}
+	OverrideByzantiumFlag = cli.Uint64Flag{
+		Name:  "override.byzantium",
+		Usage: "Manually specify Byzantium fork-block, overriding the bundled setting",
+	}
 
 	LightServFlag = cli.IntFlag{
 		Name:  "lightserv",
@@ -180,10 +252,60 @@ var (
 		Usage: "Maximum number of LES client peers",
 		Value: 20,
 	}
+	LightPriorityFlag = cli.StringFlag{
+		Name:  "lightserv.priority",
+		Usage: "Comma separated node IDs of LES clients served with larger flow control buffers, exempt from --lightpeers",
+	}
+	LightQuotaFlag = cli.Uint64Flag{
+		Name:  "lightserv.quota",
+		Usage: "Request cost served to each LES client before it must deposit credit through the les API (0 = no accounting)",
+	}
+	LightPayeeFlag = cli.StringFlag{
+		Name:  "lightserv.payee",
+		Usage: "Address LES clients deposit their payments to, with their node ID as transaction data",
+	}
+	LightPriceFlag = BigFlag{
+		Name:  "lightserv.price",
+		Usage: "Wei LES clients deposit per unit of request cost served beyond their free quota",
+		Value: big.NewInt(1),
+	}
+	ServeLimitFlag = cli.IntFlag{
+		Name:  "serve.limit",
+		Usage: "Maximum number of headers, bodies, receipts and state entries served to each peer per second (0 = unlimited)",
+	}
+	ServeNoStateFlag = cli.BoolFlag{
+		Name:  "serve.nostate",
+		Usage: "Refuse serving state trie data to peers",
+	}
+	WhitelistFlag = cli.StringFlag{
+		Name:  "whitelist",
+		Usage: "Comma separated block number-to-hash mappings the synced chain must contain (<number>=<hash>)",
+	}
+	LightCheckpointFlag = cli.StringFlag{
+		Name:  "lightcheckpoint",
+		Usage: "Trusted checkpoint to start light syncing from (<section>,<sectionhead>,<chtroot>,<bloomroot>)",
+	}
+	LightCacheFlag = cli.IntFlag{
+		Name:  "lightcache",
+		Usage: "Megabytes of retrieved light client data to keep on disk (0 = unlimited)",
+		Value: eth.DefaultConfig.LightCache,
+	}
+	LightIndexFlag = cli.BoolFlag{
+		Name:  "lightindex",
+		Usage: "Generate the light client helper tries in the background even if not serving LES clients",
+	}
 	LightKDFFlag = cli.BoolFlag{
 		Name:  "lightkdf",
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
 	}
+	KeyStoreScryptNFlag = cli.IntFlag{
+		Name:  "keystore.scryptn",
+		Usage: "Scrypt N parameter (power of two) for encrypting new keys (default = standard or light KDF)",
+	}
+	KeyStoreScryptPFlag = cli.IntFlag{
+		Name:  "keystore.scryptp",
+		Usage: "Scrypt P parameter for encrypting new keys (default = standard or light KDF)",
+	}
 	// Dashboard settings
 	DashboardEnabledFlag = cli.BoolFlag{
 		Name:  "dashboard",
@@ -240,6 +362,10 @@ var (
 		Value: eth.DefaultConfig.EthashDatasetsOnDisk,
 	}
 	// Transaction pool settings
+	TxPoolLocalsFlag = cli.StringFlag{
+		Name:  "txpool.locals",
+		Usage: "Comma separated accounts to treat as locals (no flush, priority inclusion)",
+	}
 	TxPoolNoLocalsFlag = cli.BoolFlag{
 		Name:  "txpool.nolocals",
 		Usage: "Disables price exemptions for locally submitted transactions",
@@ -269,6 +395,11 @@ var (
 		Usage: "Minimum number of executable transaction slots guaranteed per account",
 		Value: eth.DefaultConfig.TxPool.AccountSlots,
 	}
+	TxPoolAccountPendingFlag = cli.Uint64Flag{
+		Name:  "txpool.accountpending",
+		Usage: "Maximum number of executable transaction slots permitted per account (0 = unlimited)",
+		Value: eth.DefaultConfig.TxPool.AccountPending,
+	}
 	TxPoolGlobalSlotsFlag = cli.Uint64Flag{
 		Name:  "txpool.globalslots",
 		Usage: "Maximum number of executable transaction slots for all accounts",
@@ -290,11 +421,36 @@ var (
 		Value: eth.DefaultConfig.TxPool.Lifetime,
 	}
 	// Performance tuning settings
+	//
+	// By default --cache only sizes the database cache, keeping the footprint of
+	// light and mobile nodes small. Full nodes with memory to spare should raise
+	// --cache and set any of the --cache.* percentages, which splits the whole
+	// allowance between the database, trie, contract code and block caches.
 	CacheFlag = cli.IntFlag{
 		Name:  "cache",
 		Usage: "Megabytes of memory allocated to internal caching (min 16MB / database forced)",
 		Value: 128,
 	}
+	CacheDatabaseFlag = cli.IntFlag{
+		Name:  "cache.database",
+		Usage: "Percentage of cache memory allowance to use for database io (splits --cache when set)",
+		Value: 50,
+	}
+	CacheTrieFlag = cli.IntFlag{
+		Name:  "cache.trie",
+		Usage: "Percentage of cache memory allowance to use for in-memory trie nodes (splits --cache when set)",
+		Value: 25,
+	}
+	CacheCodeFlag = cli.IntFlag{
+		Name:  "cache.code",
+		Usage: "Percentage of cache memory allowance to use for contract code caching (splits --cache when set)",
+		Value: 15,
+	}
+	CacheBlocksFlag = cli.IntFlag{
+		Name:  "cache.blocks",
+		Usage: "Percentage of cache memory allowance to use for block, body and receipt caching (splits --cache when set)",
+		Value: 10,
+	}
 	TrieCacheGenFlag = cli.IntFlag{
 		Name:  "trie-cache-gens",
 		Usage: "Number of trie node generations to keep in memory",
@@ -329,6 +485,10 @@ var (
 		Name:  "extradata",
 		Usage: "Block extra data set by the miner (default = client version)",
 	}
+	MinerStratumFlag = cli.StringFlag{
+		Name:  "minerstratum",
+		Usage: "Listening address of the stratum work server for external mining farms (e.g. :8008)",
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -345,6 +505,18 @@ var (
 		Name:  "vmdebug",
 		Usage: "Record information useful for VM and contract debugging",
 	}
+	VMStatsFlag = cli.BoolFlag{
+		Name:  "vmstats",
+		Usage: "Collect opcode, gas and contract execution statistics during block processing",
+	}
+	VMProfileLabelsFlag = cli.BoolFlag{
+		Name:  "vmprofilelabels",
+		Usage: "Label CPU profiles with the contracts executed during block processing",
+	}
+	VMEWASMFlag = cli.BoolFlag{
+		Name:  "vmewasm",
+		Usage: "Make the experimental eWASM interpreter available to genesis configs (\"interpreters\": {\"ewasm\": <block>})",
+	}
 	// Logging and debug settings
 	EthStatsURLFlag = cli.StringFlag{
 		Name:  "ethstats",
@@ -354,6 +526,36 @@ var (
 		Name:  metrics.MetricsEnabledFlag,
 		Usage: "Enable metrics collection and reporting",
 	}
+	MetricsPrometheusFlag = cli.StringFlag{
+		Name:  "metrics.prometheus",
+		Usage: "Serve the metrics in Prometheus format on the given address (e.g. 127.0.0.1:6061)",
+	}
+	MetricsInfluxDBEndpointFlag = cli.StringFlag{
+		Name:  "metrics.influxdb.endpoint",
+		Usage: "Push the metrics to the given InfluxDB server (e.g. http://localhost:8086)",
+	}
+	MetricsInfluxDBDatabaseFlag = cli.StringFlag{
+		Name:  "metrics.influxdb.database",
+		Usage: "InfluxDB database to push the metrics into",
+		Value: "geth",
+	}
+	MetricsInfluxDBUsernameFlag = cli.StringFlag{
+		Name:  "metrics.influxdb.username",
+		Usage: "Username to authenticate with the InfluxDB server",
+	}
+	MetricsInfluxDBPasswordFlag = cli.StringFlag{
+		Name:  "metrics.influxdb.password",
+		Usage: "Password to authenticate with the InfluxDB server",
+	}
+	MetricsInfluxDBTagsFlag = cli.StringFlag{
+		Name:  "metrics.influxdb.tags",
+		Usage: "Comma separated tags attached to the pushed metrics (e.g. node=node1,network=mainnet)",
+	}
+	MetricsInfluxDBIntervalFlag = cli.DurationFlag{
+		Name:  "metrics.influxdb.interval",
+		Usage: "Time between two metrics pushes to InfluxDB",
+		Value: 10 * time.Second,
+	}
 	FakePoWFlag = cli.BoolFlag{
 		Name:  "fakepow",
 		Usage: "Disables proof-of-work verification",
@@ -387,6 +589,11 @@ var (
 		Usage: "API's offered over the HTTP-RPC interface",
 		Value: "",
 	}
+	RPCCallWorkersFlag = cli.IntFlag{
+		Name:  "rpccallworkers",
+		Usage: "Maximum number of eth_call and eth_estimateGas executions running at once (0 = number of CPUs)",
+		Value: 0,
+	}
 	IPCDisabledFlag = cli.BoolFlag{
 		Name:  "ipcdisable",
 		Usage: "Disable the IPC-RPC server",
@@ -854,6 +1061,9 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	case ctx.GlobalBool(RinkebyFlag.Name):
 		cfg.DataDir = filepath.Join(node.DefaultDataDir(), "rinkeby")
 	}
+	if ctx.GlobalIsSet(DBEngineFlag.Name) {
+		cfg.DBEngine = ctx.GlobalString(DBEngineFlag.Name)
+	}
 
 	if ctx.GlobalIsSet(KeyStoreDirFlag.Name) {
 		cfg.KeyStoreDir = ctx.GlobalString(KeyStoreDirFlag.Name)
@@ -861,9 +1071,36 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(LightKDFFlag.Name) {
 		cfg.UseLightweightKDF = ctx.GlobalBool(LightKDFFlag.Name)
 	}
+	if ctx.GlobalIsSet(KeyStoreScryptNFlag.Name) {
+		cfg.KeyStoreScryptN = ctx.GlobalInt(KeyStoreScryptNFlag.Name)
+	}
+	if ctx.GlobalIsSet(KeyStoreScryptPFlag.Name) {
+		cfg.KeyStoreScryptP = ctx.GlobalInt(KeyStoreScryptPFlag.Name)
+	}
 	if ctx.GlobalIsSet(NoUSBFlag.Name) {
 		cfg.NoUSB = ctx.GlobalBool(NoUSBFlag.Name)
 	}
+	if ctx.GlobalIsSet(ExternalSignerFlag.Name) {
+		cfg.ExternalSigner = ctx.GlobalString(ExternalSignerFlag.Name)
+	}
+	if ctx.GlobalIsSet(SignAuditLogFlag.Name) {
+		cfg.SignAuditLog = ctx.GlobalString(SignAuditLogFlag.Name)
+	}
+	if ctx.GlobalIsSet(SigningRulesFlag.Name) {
+		cfg.SigningRules = ctx.GlobalString(SigningRulesFlag.Name)
+	}
+	if ctx.GlobalIsSet(PluginsFlag.Name) {
+		cfg.Plugins = splitAndTrim(ctx.GlobalString(PluginsFlag.Name))
+	}
+	if ctx.GlobalIsSet(PluginFilesFlag.Name) {
+		cfg.PluginFiles = splitAndTrim(ctx.GlobalString(PluginFilesFlag.Name))
+	}
+	if ctx.GlobalIsSet(ShutdownTimeoutFlag.Name) {
+		cfg.ShutdownTimeout = ctx.GlobalDuration(ShutdownTimeoutFlag.Name)
+	}
+	if ctx.GlobalIsSet(ServiceStopTimeoutFlag.Name) {
+		cfg.ServiceStopTimeout = ctx.GlobalDuration(ServiceStopTimeoutFlag.Name)
+	}
 }
 
 func setGPO(ctx *cli.Context, cfg *gasprice.Config) {
@@ -876,6 +1113,16 @@ func setGPO(ctx *cli.Context, cfg *gasprice.Config) {
 }
 
 func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
+	if ctx.GlobalIsSet(TxPoolLocalsFlag.Name) {
+		locals := strings.Split(ctx.GlobalString(TxPoolLocalsFlag.Name), ",")
+		for _, account := range locals {
+			if trimmed := strings.TrimSpace(account); !common.IsHexAddress(trimmed) {
+				Fatalf("Invalid account in --txpool.locals: %s", trimmed)
+			} else {
+				cfg.Locals = append(cfg.Locals, common.HexToAddress(trimmed))
+			}
+		}
+	}
 	if ctx.GlobalIsSet(TxPoolNoLocalsFlag.Name) {
 		cfg.NoLocals = ctx.GlobalBool(TxPoolNoLocalsFlag.Name)
 	}
@@ -894,6 +1141,9 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolAccountSlotsFlag.Name) {
 		cfg.AccountSlots = ctx.GlobalUint64(TxPoolAccountSlotsFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolAccountPendingFlag.Name) {
+		cfg.AccountPending = ctx.GlobalUint64(TxPoolAccountPendingFlag.Name)
+	}
 	if ctx.GlobalIsSet(TxPoolGlobalSlotsFlag.Name) {
 		cfg.GlobalSlots = ctx.GlobalUint64(TxPoolGlobalSlotsFlag.Name)
 	}
@@ -977,6 +1227,71 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	if ctx.GlobalIsSet(LightPeersFlag.Name) {
 		cfg.LightPeers = ctx.GlobalInt(LightPeersFlag.Name)
 	}
+	if ctx.GlobalIsSet(LightPriorityFlag.Name) {
+		for _, id := range strings.Split(ctx.GlobalString(LightPriorityFlag.Name), ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				cfg.LightPriority = append(cfg.LightPriority, id)
+			}
+		}
+	}
+	if ctx.GlobalIsSet(LightQuotaFlag.Name) {
+		cfg.LightQuota = ctx.GlobalUint64(LightQuotaFlag.Name)
+	}
+	if ctx.GlobalIsSet(LightPayeeFlag.Name) {
+		payee := ctx.GlobalString(LightPayeeFlag.Name)
+		if !common.IsHexAddress(payee) {
+			Fatalf("Option %q: invalid address %q", LightPayeeFlag.Name, payee)
+		}
+		cfg.LightPayee = common.HexToAddress(payee)
+	}
+	if ctx.GlobalIsSet(LightPriceFlag.Name) {
+		cfg.LightPrice = GlobalBig(ctx, LightPriceFlag.Name)
+	}
+	if ctx.GlobalIsSet(ServeLimitFlag.Name) {
+		cfg.ServeLimit = ctx.GlobalInt(ServeLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(ServeNoStateFlag.Name) {
+		cfg.NoServeState = ctx.GlobalBool(ServeNoStateFlag.Name)
+	}
+	if ctx.GlobalIsSet(LightIndexFlag.Name) {
+		cfg.LightIndex = ctx.GlobalBool(LightIndexFlag.Name)
+	}
+	if ctx.GlobalIsSet(LightCacheFlag.Name) {
+		cfg.LightCache = ctx.GlobalInt(LightCacheFlag.Name)
+	}
+	if ctx.GlobalIsSet(LightCheckpointFlag.Name) {
+		checkpoint, err := parseCheckpoint(ctx.GlobalString(LightCheckpointFlag.Name))
+		if err != nil {
+			Fatalf("Invalid --%s: %v", LightCheckpointFlag.Name, err)
+		}
+		cfg.Checkpoint = checkpoint
+	}
+	if ctx.GlobalIsSet(WhitelistFlag.Name) {
+		whitelist, err := parseWhitelist(ctx.GlobalString(WhitelistFlag.Name))
+		if err != nil {
+			Fatalf("Invalid --%s: %v", WhitelistFlag.Name, err)
+		}
+		cfg.Whitelist = whitelist
+	}
+	if ctx.GlobalIsSet(GCModeFlag.Name) {
+		cfg.NoPruning = archiveMode(ctx)
+	}
+	if ctx.GlobalIsSet(CachePreimagesFlag.Name) {
+		cfg.Preimages = ctx.GlobalBool(CachePreimagesFlag.Name)
+	}
+
+	if ctx.GlobalIsSet(SafeDepthFlag.Name) {
+		cfg.SafeDepth = ctx.GlobalUint64(SafeDepthFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxLookupLimitFlag.Name) {
+		cfg.TxLookupLimit = ctx.GlobalUint64(TxLookupLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(StateSnapshotsFlag.Name) {
+		cfg.StateSnapshots = ctx.GlobalBool(StateSnapshotsFlag.Name)
+	}
+	if ctx.GlobalIsSet(OverrideByzantiumFlag.Name) {
+		cfg.OverrideByzantium = new(big.Int).SetUint64(ctx.GlobalUint64(OverrideByzantiumFlag.Name))
+	}
 	if ctx.GlobalIsSet(NetworkIdFlag.Name) {
 		cfg.NetworkId = ctx.GlobalUint64(NetworkIdFlag.Name)
 	}
@@ -984,7 +1299,19 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	if ctx.GlobalIsSet(CacheFlag.Name) {
 		cfg.DatabaseCache = ctx.GlobalInt(CacheFlag.Name)
 	}
+	if cacheSplitSet(ctx) {
+		cfg.DatabaseCache = cacheAllowance(ctx, CacheDatabaseFlag)
+		cfg.TrieCache = cacheAllowance(ctx, CacheTrieFlag)
+		cfg.CodeCache = cacheAllowance(ctx, CacheCodeFlag)
+		cfg.BlockCache = cacheAllowance(ctx, CacheBlocksFlag)
+	}
 	cfg.DatabaseHandles = makeDatabaseHandles()
+	if ctx.GlobalIsSet(AncientFlag.Name) {
+		cfg.DatabaseFreezer = ctx.GlobalString(AncientFlag.Name)
+	}
+	if ctx.GlobalIsSet(DBCompactionFlag.Name) {
+		cfg.DatabaseCompaction = ctx.GlobalString(DBCompactionFlag.Name)
+	}
 
 	if ctx.GlobalIsSet(MinerThreadsFlag.Name) {
 		cfg.MinerThreads = ctx.GlobalInt(MinerThreadsFlag.Name)
@@ -998,10 +1325,22 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	if ctx.GlobalIsSet(GasPriceFlag.Name) {
 		cfg.GasPrice = GlobalBig(ctx, GasPriceFlag.Name)
 	}
+	if ctx.GlobalIsSet(MinerStratumFlag.Name) {
+		cfg.MinerStratum = ctx.GlobalString(MinerStratumFlag.Name)
+	}
 	if ctx.GlobalIsSet(VMEnableDebugFlag.Name) {
 		// TODO(fjl): force-enable this in --dev mode
 		cfg.EnablePreimageRecording = ctx.GlobalBool(VMEnableDebugFlag.Name)
 	}
+	if ctx.GlobalIsSet(VMStatsFlag.Name) {
+		cfg.VMStats = ctx.GlobalBool(VMStatsFlag.Name)
+	}
+	if ctx.GlobalIsSet(VMProfileLabelsFlag.Name) {
+		cfg.VMProfileLabels = ctx.GlobalBool(VMProfileLabelsFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCCallWorkersFlag.Name) {
+		cfg.RPCCallWorkers = ctx.GlobalInt(RPCCallWorkersFlag.Name)
+	}
 
 	// Override any default configs for hard coded networks.
 	switch {
@@ -1009,11 +1348,17 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 		if !ctx.GlobalIsSet(NetworkIdFlag.Name) {
 			cfg.NetworkId = 3
 		}
+		if !ctx.GlobalIsSet(SafeDepthFlag.Name) {
+			cfg.SafeDepth = params.TestnetSafeDepth
+		}
 		cfg.Genesis = core.DefaultTestnetGenesisBlock()
 	case ctx.GlobalBool(RinkebyFlag.Name):
 		if !ctx.GlobalIsSet(NetworkIdFlag.Name) {
 			cfg.NetworkId = 4
 		}
+		if !ctx.GlobalIsSet(SafeDepthFlag.Name) {
+			cfg.SafeDepth = params.RinkebySafeDepth
+		}
 		cfg.Genesis = core.DefaultRinkebyGenesisBlock()
 	case ctx.GlobalBool(DeveloperFlag.Name):
 		// Create new developer account or reuse existing one
@@ -1035,6 +1380,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 		log.Info("Using developer account", "address", developer.Address)
 
 		cfg.Genesis = core.DeveloperGenesisBlock(uint64(ctx.GlobalInt(DeveloperPeriodFlag.Name)), developer.Address)
+		if !ctx.GlobalIsSet(SafeDepthFlag.Name) {
+			cfg.SafeDepth = 0
+		}
 		if !ctx.GlobalIsSet(GasPriceFlag.Name) {
 			cfg.GasPrice = big.NewInt(1)
 		}
@@ -1047,10 +1395,18 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 
 // SetDashboardConfig applies dashboard related command line flags to the config.
 func SetDashboardConfig(ctx *cli.Context, cfg *dashboard.Config) {
-	cfg.Host = ctx.GlobalString(DashboardAddrFlag.Name)
-	cfg.Port = ctx.GlobalInt(DashboardPortFlag.Name)
-	cfg.Refresh = ctx.GlobalDuration(DashboardRefreshFlag.Name)
-	cfg.Assets = ctx.GlobalString(DashboardAssetsFlag.Name)
+	if ctx.GlobalIsSet(DashboardAddrFlag.Name) {
+		cfg.Host = ctx.GlobalString(DashboardAddrFlag.Name)
+	}
+	if ctx.GlobalIsSet(DashboardPortFlag.Name) {
+		cfg.Port = ctx.GlobalInt(DashboardPortFlag.Name)
+	}
+	if ctx.GlobalIsSet(DashboardRefreshFlag.Name) {
+		cfg.Refresh = ctx.GlobalDuration(DashboardRefreshFlag.Name)
+	}
+	if ctx.GlobalIsSet(DashboardAssetsFlag.Name) {
+		cfg.Assets = ctx.GlobalString(DashboardAssetsFlag.Name)
+	}
 }
 
 // RegisterEthService adds an Ethereum client to the stack.
@@ -1108,18 +1464,170 @@ func RegisterEthStatsService(stack *node.Node, url string) {
 	}
 }
 
+// parseCheckpoint parses a trusted light client checkpoint given in the form of
+// <section>,<sectionhead>,<chtroot>,<bloomroot>.
+func parseCheckpoint(spec string) (*params.TrustedCheckpoint, error) {
+	parts := strings.Split(spec, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("expected 4 comma separated fields, have %d", len(parts))
+	}
+	section, err := strconv.ParseUint(strings.TrimSpace(parts[0]), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid section index: %v", err)
+	}
+	var roots [3]common.Hash
+	for i, part := range parts[1:] {
+		blob, err := hexutil.Decode(strings.TrimSpace(part))
+		if err != nil || len(blob) != common.HashLength {
+			return nil, fmt.Errorf("invalid hash %q", part)
+		}
+		roots[i] = common.BytesToHash(blob)
+	}
+	return &params.TrustedCheckpoint{
+		Name:         "custom",
+		SectionIndex: section,
+		SectionHead:  roots[0],
+		CHTRoot:      roots[1],
+		BloomRoot:    roots[2],
+	}, nil
+}
+
+// parseWhitelist parses a list of pinned block hashes given in the form of
+// <number>=<hash>,<number>=<hash>,...
+func parseWhitelist(spec string) (map[uint64]common.Hash, error) {
+	whitelist := make(map[uint64]common.Hash)
+	for _, entry := range strings.Split(spec, ",") {
+		parts := strings.Split(entry, "=")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid entry %q", entry)
+		}
+		number, err := strconv.ParseUint(strings.TrimSpace(parts[0]), 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid block number %q: %v", parts[0], err)
+		}
+		blob, err := hexutil.Decode(strings.TrimSpace(parts[1]))
+		if err != nil || len(blob) != common.HashLength {
+			return nil, fmt.Errorf("invalid hash %q", parts[1])
+		}
+		whitelist[number] = common.BytesToHash(blob)
+	}
+	return whitelist, nil
+}
+
+// archiveMode validates the garbage collection mode flag and reports whether the
+// archive mode (no state pruning) was requested.
+func archiveMode(ctx *cli.Context) bool {
+	switch gcmode := ctx.GlobalString(GCModeFlag.Name); gcmode {
+	case "full":
+		return false
+	case "archive":
+		return true
+	default:
+		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
+	}
+	return false
+}
+
+// cacheSplitSet reports whether any of the cache allowance percentages were set,
+// in which case the entire --cache budget is split according to them. Otherwise
+// --cache only sizes the database cache and the other caches use their defaults.
+// It fails if the percentages add up to more than the total allowance.
+func cacheSplitSet(ctx *cli.Context) bool {
+	var (
+		set   bool
+		total int
+	)
+	for _, flag := range []cli.IntFlag{CacheDatabaseFlag, CacheTrieFlag, CacheCodeFlag, CacheBlocksFlag} {
+		if percent := ctx.GlobalInt(flag.Name); percent < 0 || percent > 100 {
+			Fatalf("--%s must be between 0 and 100", flag.Name)
+		}
+		total += ctx.GlobalInt(flag.Name)
+		set = set || ctx.GlobalIsSet(flag.Name)
+	}
+	if total > 100 {
+		Fatalf("Cache allowance percentages add up to %d%%, must be at most 100%%", total)
+	}
+	return set
+}
+
+// cacheAllowance returns the megabytes of the total cache allowance assigned to
+// the cache configured by the given percentage flag.
+func cacheAllowance(ctx *cli.Context, flag cli.IntFlag) int {
+	return ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(flag.Name) / 100
+}
+
 // SetupNetwork configures the system for either the main net or some test network.
 func SetupNetwork(ctx *cli.Context) {
 	// TODO(fjl): move target gas limit into config
 	params.TargetGasLimit = new(big.Int).SetUint64(ctx.GlobalUint64(TargetGasLimitFlag.Name))
 }
 
+// SetupInterpreters registers the alternative VM interpreters enabled by the flags,
+// which must happen before any chain config activating them is loaded.
+func SetupInterpreters(ctx *cli.Context) {
+	if ctx.GlobalBool(VMEWASMFlag.Name) {
+		log.Warn("Enabling experimental eWASM interpreter")
+		ewasm.Register()
+	}
+}
+
+// SetupMetrics starts the metrics exporters configured by the flags.
+func SetupMetrics(ctx *cli.Context) {
+	if !metrics.Enabled {
+		return
+	}
+	if addr := ctx.GlobalString(MetricsPrometheusFlag.Name); addr != "" {
+		log.Info("Starting Prometheus metrics exporter", "addr", fmt.Sprintf("http://%s/metrics", addr))
+		go func() {
+			if err := prometheus.ListenAndServe(addr, gometrics.DefaultRegistry); err != nil {
+				log.Error("Failure in running Prometheus exporter", "err", err)
+			}
+		}()
+	}
+	if endpoint := ctx.GlobalString(MetricsInfluxDBEndpointFlag.Name); endpoint != "" {
+		config := influxdb.Config{
+			Endpoint: endpoint,
+			Database: ctx.GlobalString(MetricsInfluxDBDatabaseFlag.Name),
+			Username: ctx.GlobalString(MetricsInfluxDBUsernameFlag.Name),
+			Password: ctx.GlobalString(MetricsInfluxDBPasswordFlag.Name),
+			Tags:     make(map[string]string),
+			Interval: ctx.GlobalDuration(MetricsInfluxDBIntervalFlag.Name),
+		}
+		if tags := ctx.GlobalString(MetricsInfluxDBTagsFlag.Name); tags != "" {
+			for _, tag := range strings.Split(tags, ",") {
+				kv := strings.SplitN(tag, "=", 2)
+				if len(kv) != 2 || kv[0] == "" {
+					Fatalf("Invalid InfluxDB tag %q, want key=value", tag)
+				}
+				config.Tags[kv[0]] = kv[1]
+			}
+		}
+		if _, ok := config.Tags["node"]; !ok {
+			if host, err := os.Hostname(); err == nil {
+				config.Tags["node"] = host
+			}
+		}
+		if config.Interval <= 0 {
+			Fatalf("Invalid InfluxDB reporting interval %v", config.Interval)
+		}
+		log.Info("Starting InfluxDB metrics reporter", "endpoint", endpoint, "database", config.Database)
+		go func() {
+			if err := influxdb.Report(gometrics.DefaultRegistry, config); err != nil {
+				log.Error("Failure in running InfluxDB reporter", "err", err)
+			}
+		}()
+	}
+}
+
 // MakeChainDatabase open an LevelDB using the flags passed to the client and will hard crash if it fails.
 func MakeChainDatabase(ctx *cli.Context, stack *node.Node) ethdb.Database {
 	var (
 		cache   = ctx.GlobalInt(CacheFlag.Name)
 		handles = makeDatabaseHandles()
 	)
+	if cacheSplitSet(ctx) {
+		cache = cacheAllowance(ctx, CacheDatabaseFlag)
+	}
 	name := "chaindata"
 	if ctx.GlobalBool(LightModeFlag.Name) {
 		name = "lightchaindata"
@@ -1128,6 +1636,19 @@ func MakeChainDatabase(ctx *cli.Context, stack *node.Node) ethdb.Database {
 	if err != nil {
 		Fatalf("Could not open database: %v", err)
 	}
+	if ctx.GlobalBool(LightModeFlag.Name) {
+		return chainDb
+	}
+	freezer := stack.ResolvePath(filepath.Join(name, "ancient"))
+	if ctx.GlobalIsSet(AncientFlag.Name) {
+		freezer = stack.ResolvePath(ctx.GlobalString(AncientFlag.Name))
+	}
+	if freezer == "" {
+		return chainDb
+	}
+	if chainDb, err = core.NewDatabaseWithFreezer(chainDb, freezer); err != nil {
+		Fatalf("Could not open ancient database: %v", err)
+	}
 	return chainDb
 }
 
@@ -1149,7 +1670,11 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	var err error
 	chainDb = MakeChainDatabase(ctx, stack)
 
-	config, _, err := core.SetupGenesisBlock(chainDb, MakeGenesis(ctx))
+	var overrideByzantium *big.Int
+	if ctx.GlobalIsSet(OverrideByzantiumFlag.Name) {
+		overrideByzantium = new(big.Int).SetUint64(ctx.GlobalUint64(OverrideByzantiumFlag.Name))
+	}
+	config, _, err := core.SetupGenesisBlockWithOverride(chainDb, MakeGenesis(ctx), overrideByzantium)
 	if err != nil {
 		Fatalf("%v", err)
 	}
@@ -1165,8 +1690,21 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 			)
 		}
 	}
+	cache := &core.CacheConfig{
+		Disabled:      archiveMode(ctx),
+		Preimages:     ctx.GlobalBool(CachePreimagesFlag.Name),
+		TrieNodeLimit: eth.DefaultConfig.TrieCache,
+		TrieTimeLimit: 5 * time.Minute,
+		CodeCache:     eth.DefaultConfig.CodeCache,
+		BlockCache:    eth.DefaultConfig.BlockCache,
+	}
+	if cacheSplitSet(ctx) {
+		cache.TrieNodeLimit = cacheAllowance(ctx, CacheTrieFlag)
+		cache.CodeCache = cacheAllowance(ctx, CacheCodeFlag)
+		cache.BlockCache = cacheAllowance(ctx, CacheBlocksFlag)
+	}
 	vmcfg := vm.Config{EnablePreimageRecording: ctx.GlobalBool(VMEnableDebugFlag.Name)}
-	chain, err = core.NewBlockChain(chainDb, config, engine, vmcfg)
+	chain, err = core.NewBlockChain(chainDb, cache, config, engine, vmcfg)
 	if err != nil {
 		Fatalf("Can't create BlockChain: %v", err)
 	}
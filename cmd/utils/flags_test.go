@@ -0,0 +1,74 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Tests that trusted light client checkpoints are parsed from their command line
+// representation and malformed ones are rejected.
+func TestParseCheckpoint(t *testing.T) {
+	var (
+		head  = common.HexToHash("0x01")
+		cht   = common.HexToHash("0x02")
+		bloom = common.HexToHash("0x03")
+	)
+	cp, err := parseCheckpoint("42," + head.Hex() + ", " + cht.Hex() + "," + bloom.Hex())
+	if err != nil {
+		t.Fatalf("failed to parse checkpoint: %v", err)
+	}
+	if cp.SectionIndex != 42 || cp.SectionHead != head || cp.CHTRoot != cht || cp.BloomRoot != bloom {
+		t.Errorf("checkpoint mismatch: have %+v", cp)
+	}
+	for _, spec := range []string{
+		"",
+		"42," + head.Hex() + "," + cht.Hex(),
+		"x," + head.Hex() + "," + cht.Hex() + "," + bloom.Hex(),
+		"42,0x01," + cht.Hex() + "," + bloom.Hex(),
+	} {
+		if _, err := parseCheckpoint(spec); err == nil {
+			t.Errorf("spec %q: expected error", spec)
+		}
+	}
+}
+
+func TestParseWhitelist(t *testing.T) {
+	var (
+		hash1 = common.HexToHash("0x01")
+		hash2 = common.HexToHash("0x02")
+	)
+	whitelist, err := parseWhitelist("1000=" + hash1.Hex() + ", 0x2000=" + hash2.Hex())
+	if err != nil {
+		t.Fatalf("failed to parse whitelist: %v", err)
+	}
+	if len(whitelist) != 2 || whitelist[1000] != hash1 || whitelist[0x2000] != hash2 {
+		t.Errorf("whitelist mismatch: have %v", whitelist)
+	}
+	for _, spec := range []string{
+		"",
+		"1000",
+		"x=" + hash1.Hex(),
+		"1000=0x01",
+	} {
+		if _, err := parseWhitelist(spec); err == nil {
+			t.Errorf("spec %q: expected error", spec)
+		}
+	}
+}
@@ -85,7 +85,8 @@ func (b *bridge) NewAccount(call otto.FunctionCall) (response otto.Value) {
 }
 
 // OpenWallet is a wrapper around personal.openWallet which can interpret and
-// react to certain error messages, such as the Trezor PIN matrix request.
+// react to certain error messages, such as the Trezor PIN matrix and passphrase
+// requests.
 func (b *bridge) OpenWallet(call otto.FunctionCall) (response otto.Value) {
 	// Make sure we have an wallet specified to open
 	if !call.Argument(0).IsString() {
@@ -105,18 +106,29 @@ func (b *bridge) OpenWallet(call otto.FunctionCall) (response otto.Value) {
 		return val
 	}
 	// Wallet open failed, report error unless it's a PIN entry
-	if !strings.HasSuffix(err.Error(), usbwallet.ErrTrezorPINNeeded.Error()) {
+	if strings.HasSuffix(err.Error(), usbwallet.ErrTrezorPINNeeded.Error()) {
+		// Trezor PIN matrix input requested, display the matrix to the user and fetch the data
+		fmt.Fprintf(b.printer, "Look at the device for number positions\n\n")
+		fmt.Fprintf(b.printer, "7 | 8 | 9\n")
+		fmt.Fprintf(b.printer, "--+---+--\n")
+		fmt.Fprintf(b.printer, "4 | 5 | 6\n")
+		fmt.Fprintf(b.printer, "--+---+--\n")
+		fmt.Fprintf(b.printer, "1 | 2 | 3\n\n")
+
+		if input, err := b.prompter.PromptPassword("Please enter current PIN: "); err != nil {
+			throwJSException(err.Error())
+		} else {
+			passwd, _ = otto.ToValue(input)
+		}
+		if val, err = call.Otto.Call("jeth.openWallet", nil, wallet, passwd); err == nil {
+			return val
+		}
+	}
+	// Report the error unless it's a passphrase entry (possibly following the PIN)
+	if !strings.HasSuffix(err.Error(), usbwallet.ErrTrezorPassphraseNeeded.Error()) {
 		throwJSException(err.Error())
 	}
-	// Trezor PIN matrix input requested, display the matrix to the user and fetch the data
-	fmt.Fprintf(b.printer, "Look at the device for number positions\n\n")
-	fmt.Fprintf(b.printer, "7 | 8 | 9\n")
-	fmt.Fprintf(b.printer, "--+---+--\n")
-	fmt.Fprintf(b.printer, "4 | 5 | 6\n")
-	fmt.Fprintf(b.printer, "--+---+--\n")
-	fmt.Fprintf(b.printer, "1 | 2 | 3\n\n")
-
-	if input, err := b.prompter.PromptPassword("Please enter current PIN: "); err != nil {
+	if input, err := b.prompter.PromptPassword("Please enter wallet passphrase (empty for the standard wallet): "); err != nil {
 		throwJSException(err.Error())
 	} else {
 		passwd, _ = otto.ToValue(input)
@@ -19,6 +19,7 @@ package core
 import (
 	"fmt"
 	"math/big"
+	"runtime"
 
 	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/consensus"
@@ -51,18 +52,33 @@ func NewBlockValidator(config *params.ChainConfig, blockchain *BlockChain, engin
 // header's transaction and uncle roots. The headers are assumed to be already
 // validated at this point.
 func (v *BlockValidator) ValidateBody(block *types.Block) error {
+	if err := v.validateBodyLinks(block); err != nil {
+		return err
+	}
+	return validateBodyRoots(block)
+}
+
+// validateBodyLinks runs the part of the body validation depending on the local
+// chain: whether the block is already known, linkable and has valid uncles.
+func (v *BlockValidator) validateBodyLinks(block *types.Block) error {
 	// Check whether the block's known, and if not, that it's linkable
 	if v.bc.HasBlockAndState(block.Hash()) {
 		return ErrKnownBlock
 	}
 	if !v.bc.HasBlockAndState(block.ParentHash()) {
-		return consensus.ErrUnknownAncestor
+		if !v.bc.HasBlock(block.ParentHash(), block.NumberU64()-1) {
+			return consensus.ErrUnknownAncestor
+		}
+		return consensus.ErrPrunedAncestor
 	}
-	// Header validity is known at this point, check the uncles and transactions
+	// Header validity is known at this point, check the uncles
+	return v.engine.VerifyUncles(v.bc, block)
+}
+
+// validateBodyRoots verifies the block header's transaction and uncle roots. It
+// doesn't depend on the local chain, so it can be run ahead of the import.
+func validateBodyRoots(block *types.Block) error {
 	header := block.Header()
-	if err := v.engine.VerifyUncles(v.bc, block); err != nil {
-		return err
-	}
 	if hash := types.CalcUncleHash(block.Uncles()); hash != header.UncleHash {
 		return fmt.Errorf("uncle root hash mismatch: have %x, want %x", hash, header.UncleHash)
 	}
@@ -72,6 +88,61 @@ func (v *BlockValidator) ValidateBody(block *types.Block) error {
 	return nil
 }
 
+// verifyBodyRoots concurrently verifies the transaction and uncle roots of a batch
+// of blocks, delivering the results in the order of the blocks. The returned
+// abort channel may be closed to terminate the verification early.
+func verifyBodyRoots(blocks []*types.Block) (chan<- struct{}, <-chan error) {
+	// Spawn as many workers as allowed threads
+	workers := runtime.GOMAXPROCS(0)
+	if len(blocks) < workers {
+		workers = len(blocks)
+	}
+	var (
+		inputs  = make(chan int)
+		done    = make(chan int, workers)
+		errs    = make([]error, len(blocks))
+		abort   = make(chan struct{})
+		results = make(chan error, len(blocks))
+	)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for index := range inputs {
+				errs[index] = validateBodyRoots(blocks[index])
+				done <- index
+			}
+		}()
+	}
+	go func() {
+		defer close(inputs)
+		if len(blocks) == 0 {
+			return
+		}
+		var (
+			in, out = 0, 0
+			checked = make([]bool, len(blocks))
+			inputs  = inputs
+		)
+		for {
+			select {
+			case inputs <- in:
+				if in++; in == len(blocks) {
+					inputs = nil
+				}
+			case index := <-done:
+				for checked[index] = true; checked[out]; out++ {
+					results <- errs[out]
+					if out == len(blocks)-1 {
+						return
+					}
+				}
+			case <-abort:
+				return
+			}
+		}
+	}()
+	return abort, results
+}
+
 // ValidateState validates the various changes that happen after a state
 // transition, such as amount of used gas, the receipt roots and the state root
 // itself. ValidateState returns a database batch if the validation was a success
@@ -81,20 +152,31 @@ func (v *BlockValidator) ValidateState(block, parent *types.Block, statedb *stat
 	if block.GasUsed().Cmp(usedGas) != 0 {
 		return fmt.Errorf("invalid gas used (remote: %v local: %v)", block.GasUsed(), usedGas)
 	}
-	// Validate the received block's bloom with the one derived from the generated receipts.
-	// For valid blocks this should always validate to true.
-	rbloom := types.CreateBloom(receipts)
-	if rbloom != header.Bloom {
-		return fmt.Errorf("invalid bloom (remote: %x  local: %x)", header.Bloom, rbloom)
-	}
-	// Tre receipt Trie's root (R = (Tr [[H1, R1], ... [Hn, R1]]))
-	receiptSha := types.DeriveSha(receipts)
-	if receiptSha != header.ReceiptHash {
-		return fmt.Errorf("invalid receipt root hash (remote: %x local: %x)", header.ReceiptHash, receiptSha)
+	// Validate the receipts concurrently with hashing the state, which is costlier
+	receiptErr := make(chan error, 1)
+	go func() {
+		// Validate the received block's bloom with the one derived from the generated receipts.
+		// For valid blocks this should always validate to true.
+		rbloom := types.CreateBloom(receipts)
+		if rbloom != header.Bloom {
+			receiptErr <- fmt.Errorf("invalid bloom (remote: %x  local: %x)", header.Bloom, rbloom)
+			return
+		}
+		// Tre receipt Trie's root (R = (Tr [[H1, R1], ... [Hn, R1]]))
+		receiptSha := types.DeriveSha(receipts)
+		if receiptSha != header.ReceiptHash {
+			receiptErr <- fmt.Errorf("invalid receipt root hash (remote: %x local: %x)", header.ReceiptHash, receiptSha)
+			return
+		}
+		receiptErr <- nil
+	}()
+	root := statedb.IntermediateRoot(v.config.IsEIP158(header.Number))
+	if err := <-receiptErr; err != nil {
+		return err
 	}
 	// Validate the state root against the received state root and throw
 	// an error if they don't match.
-	if root := statedb.IntermediateRoot(v.config.IsEIP158(header.Number)); header.Root != root {
+	if header.Root != root {
 		return fmt.Errorf("invalid merkle root (remote: %x local: %x)", header.Root, root)
 	}
 	return nil
@@ -17,13 +17,16 @@
 package core
 
 import (
+	"math/big"
 	"runtime"
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/params"
 )
@@ -42,7 +45,7 @@ func TestHeaderVerification(t *testing.T) {
 		headers[i] = block.Header()
 	}
 	// Run the header checker for blocks one-by-one, checking for both valid and invalid nonces
-	chain, _ := NewBlockChain(testdb, params.TestChainConfig, ethash.NewFaker(), vm.Config{})
+	chain, _ := NewBlockChain(testdb, nil, params.TestChainConfig, ethash.NewFaker(), vm.Config{})
 	defer chain.Stop()
 
 	for i := 0; i < len(blocks); i++ {
@@ -106,11 +109,11 @@ func testHeaderConcurrentVerification(t *testing.T, threads int) {
 		var results <-chan error
 
 		if valid {
-			chain, _ := NewBlockChain(testdb, params.TestChainConfig, ethash.NewFaker(), vm.Config{})
+			chain, _ := NewBlockChain(testdb, nil, params.TestChainConfig, ethash.NewFaker(), vm.Config{})
 			_, results = chain.engine.VerifyHeaders(chain, headers, seals)
 			chain.Stop()
 		} else {
-			chain, _ := NewBlockChain(testdb, params.TestChainConfig, ethash.NewFakeFailer(uint64(len(headers)-1)), vm.Config{})
+			chain, _ := NewBlockChain(testdb, nil, params.TestChainConfig, ethash.NewFakeFailer(uint64(len(headers)-1)), vm.Config{})
 			_, results = chain.engine.VerifyHeaders(chain, headers, seals)
 			chain.Stop()
 		}
@@ -173,7 +176,7 @@ func testHeaderConcurrentAbortion(t *testing.T, threads int) {
 	defer runtime.GOMAXPROCS(old)
 
 	// Start the verifications and immediately abort
-	chain, _ := NewBlockChain(testdb, params.TestChainConfig, ethash.NewFakeDelayer(time.Millisecond), vm.Config{})
+	chain, _ := NewBlockChain(testdb, nil, params.TestChainConfig, ethash.NewFakeDelayer(time.Millisecond), vm.Config{})
 	defer chain.Stop()
 
 	abort, results := chain.engine.VerifyHeaders(chain, headers, seals)
@@ -197,3 +200,50 @@ func testHeaderConcurrentAbortion(t *testing.T, threads int) {
 		t.Errorf("verification count too large: have %d, want below %d", verified, 2*threads)
 	}
 }
+
+// Tests that the body roots of a batch of blocks are verified concurrently, with
+// the results delivered in the order of the blocks.
+func TestBodyConcurrentVerification2(t *testing.T)  { testBodyConcurrentVerification(t, 2) }
+func TestBodyConcurrentVerification8(t *testing.T)  { testBodyConcurrentVerification(t, 8) }
+func TestBodyConcurrentVerification32(t *testing.T) { testBodyConcurrentVerification(t, 32) }
+
+func testBodyConcurrentVerification(t *testing.T, threads int) {
+	// Create a simple chain with transactions and corrupt one of the bodies
+	var (
+		testdb, _ = ethdb.NewMemDatabase()
+		key, _    = crypto.GenerateKey()
+		address   = crypto.PubkeyToAddress(key.PublicKey)
+		funds     = big.NewInt(1000000000)
+		gspec     = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{address: {Balance: funds}}}
+		genesis   = gspec.MustCommit(testdb)
+		signer    = types.NewEIP155Signer(gspec.Config.ChainId)
+	)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, testdb, 16, func(i int, block *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{0xaa}, big.NewInt(1), new(big.Int).SetUint64(params.TxGas), nil, nil), signer, key)
+		block.AddTx(tx)
+	})
+	header := blocks[5].Header()
+	header.TxHash = common.Hash{0x01}
+	blocks[5] = types.NewBlockWithHeader(header).WithBody(blocks[5].Transactions(), blocks[5].Uncles())
+
+	// Set the number of threads to verify on
+	old := runtime.GOMAXPROCS(threads)
+	defer runtime.GOMAXPROCS(old)
+
+	abort, results := verifyBodyRoots(blocks)
+	defer close(abort)
+
+	for i := range blocks {
+		select {
+		case err := <-results:
+			if i == 5 && err == nil {
+				t.Errorf("body %d: corrupt transaction root accepted", i)
+			}
+			if i != 5 && err != nil {
+				t.Errorf("body %d: validation failed: %v", i, err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("body %d: verification timeout", i)
+		}
+	}
+}
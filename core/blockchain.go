@@ -28,6 +28,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core/state"
@@ -42,10 +43,19 @@ import (
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/trie"
 	"github.com/hashicorp/golang-lru"
+	"gopkg.in/karalabe/cookiejar.v2/collections/prque"
 )
 
 var (
 	blockInsertTimer = metrics.NewTimer("chain/inserts")
+	headBlockGauge   = metrics.NewGauge("chain/head/block")
+
+	bodyCacheHitMeter      = metrics.NewMeter("chain/cache/bodies/hit")
+	bodyCacheMissMeter     = metrics.NewMeter("chain/cache/bodies/miss")
+	blockCacheHitMeter     = metrics.NewMeter("chain/cache/blocks/hit")
+	blockCacheMissMeter    = metrics.NewMeter("chain/cache/blocks/miss")
+	receiptsCacheHitMeter  = metrics.NewMeter("chain/cache/receipts/hit")
+	receiptsCacheMissMeter = metrics.NewMeter("chain/cache/receipts/miss")
 
 	ErrNoGenesis = errors.New("Genesis not found in chain")
 )
@@ -53,14 +63,52 @@ var (
 const (
 	bodyCacheLimit      = 256
 	blockCacheLimit     = 256
+	receiptsCacheLimit  = 32
+	cachedBlockSize     = 32 * 1024 // Approximate memory used by a cached block, body or receipt set
 	maxFutureBlocks     = 256
 	maxTimeFutureBlocks = 30
 	badBlockLimit       = 10
+	triesInMemory       = 128
 
 	// BlockChainVersion ensures that an incompatible database forces a resync from scratch.
 	BlockChainVersion = 3
 )
 
+// CacheConfig contains the configuration values for the trie caching/pruning
+// that's resident in a blockchain.
+type CacheConfig struct {
+	Disabled      bool          // Whether to disable trie write caching (archive node)
+	TrieNodeLimit int           // Memory limit (MB) at which to flush the current in-memory trie to disk
+	TrieTimeLimit time.Duration // Time limit after which to flush the current in-memory trie to disk
+	TxLookupLimit uint64        // Number of recent blocks to maintain transaction lookup entries for (0 = all)
+	NoPrefetch    bool          // Whether to disable state prefetching of the next block during imports
+	Preimages     bool          // Whether to record trie key preimages (always on for archive nodes)
+	CodeCache     int           // Memory allowance (MB) for caching contract code (0 = disabled)
+	BlockCache    int           // Memory allowance (MB) for caching recent blocks, bodies and receipts (0 = default)
+	Snapshots     bool          // Whether to generate flat state snapshots at epoch blocks for serving
+}
+
+// blockCacheLimits converts the block cache allowance of the configuration into
+// the number of entries to keep in the body, block and receipt LRU caches. The
+// allowance is split evenly between the three caches, each holding at least 16
+// entries. The default limits are used if no allowance was set.
+func (c *CacheConfig) blockCacheLimits() (bodies, blocks, receipts int) {
+	if c.BlockCache <= 0 {
+		return bodyCacheLimit, blockCacheLimit, receiptsCacheLimit
+	}
+	limit := c.BlockCache * 1024 * 1024 / 3 / cachedBlockSize
+	if limit < 16 {
+		limit = 16
+	}
+	return limit, limit, limit
+}
+
+// defaultCacheConfig is used if no cache configuration is given to NewBlockChain.
+var defaultCacheConfig = &CacheConfig{
+	TrieNodeLimit: 256,
+	TrieTimeLimit: 5 * time.Minute,
+}
+
 // BlockChain represents the canonical chain given a database with a genesis
 // block. The Blockchain manages chain imports, reverts, chain reorganisations.
 //
@@ -76,7 +124,12 @@ const (
 // included in the canonical one where as GetBlockByNumber always represents the
 // canonical chain.
 type BlockChain struct {
-	config *params.ChainConfig // chain & network configuration
+	config      *params.ChainConfig // chain & network configuration
+	cacheConfig *CacheConfig        // Cache configuration for pruning
+
+	nodes  *trie.NodeStore // In-memory trie nodes of recent states (nil for archive nodes)
+	triegc *prque.Prque    // Priority queue mapping block numbers to tries to gc
+	gcproc time.Duration   // Accumulates canonical block processing for trie dumping
 
 	hc            *HeaderChain
 	chainDb       ethdb.Database
@@ -85,22 +138,34 @@ type BlockChain struct {
 	chainSideFeed event.Feed
 	chainHeadFeed event.Feed
 	logsFeed      event.Feed
+	reorgFeed     event.Feed
+	safeFeed      event.Feed
 	scope         event.SubscriptionScope
 	genesisBlock  *types.Block
 
+	reorgQueue []ReorgEvent // Reorgs done but not yet posted, in the order they happened
+	reorgLock  sync.Mutex   // Protects the reorg queue
+	reorgPost  sync.Mutex   // Serializes posting the reorgs, keeping them in order
+	snapLock   sync.Mutex   // Serializes the state snapshot generations
+
 	mu      sync.RWMutex // global mutex for locking chain operations
 	chainmu sync.RWMutex // blockchain insertion lock
 	procmu  sync.RWMutex // block processor lock
 
+	safeDepth uint64      // Number of blocks below the head after which blocks are deemed safe (atomic access)
+	safeHash  common.Hash // Hash of the last announced safe block
+	safeLock  sync.Mutex  // Lock serialising the safe block announcements
+
 	checkpoint       int          // checkpoint counts towards the new checkpoint
 	currentBlock     *types.Block // Current head of the block chain
 	currentFastBlock *types.Block // Current head of the fast-sync chain (may be above the block chain!)
 
-	stateCache   state.Database // State database to reuse between imports (contains state cache)
-	bodyCache    *lru.Cache     // Cache for the most recent block bodies
-	bodyRLPCache *lru.Cache     // Cache for the most recent block bodies in RLP encoded format
-	blockCache   *lru.Cache     // Cache for the most recent entire blocks
-	futureBlocks *lru.Cache     // future blocks are blocks added for later processing
+	stateCache    state.Database // State database to reuse between imports (contains state cache)
+	bodyCache     *lru.Cache     // Cache for the most recent block bodies
+	bodyRLPCache  *lru.Cache     // Cache for the most recent block bodies in RLP encoded format
+	blockCache    *lru.Cache     // Cache for the most recent entire blocks
+	receiptsCache *lru.Cache     // Cache for the receipts of the most recent blocks
+	futureBlocks  *lru.Cache     // future blocks are blocks added for later processing
 
 	quit    chan struct{} // blockchain quit channel
 	running int32         // running must be called atomically
@@ -108,39 +173,54 @@ type BlockChain struct {
 	procInterrupt int32          // interrupt signaler for block processing
 	wg            sync.WaitGroup // chain processing wait group for shutting down
 
-	engine    consensus.Engine
-	processor Processor // block processor interface
-	validator Validator // block and state validator interface
-	vmConfig  vm.Config
+	engine     consensus.Engine
+	processor  Processor        // block processor interface
+	validator  Validator        // block and state validator interface
+	prefetcher *statePrefetcher // block state prefetcher warming caches ahead of processing
+	vmConfig   vm.Config
 
-	badBlocks *lru.Cache // Bad block cache
+	whitelist map[uint64]common.Hash // Operator pinned block hashes the chain must contain
 }
 
 // NewBlockChain returns a fully initialised block chain using information
 // available in the database. It initialises the default Ethereum Validator and
-// Processor.
-func NewBlockChain(chainDb ethdb.Database, config *params.ChainConfig, engine consensus.Engine, vmConfig vm.Config) (*BlockChain, error) {
-	bodyCache, _ := lru.New(bodyCacheLimit)
-	bodyRLPCache, _ := lru.New(bodyCacheLimit)
-	blockCache, _ := lru.New(blockCacheLimit)
+// Processor. If cacheConfig is nil, recent state is kept in memory and stale
+// state is pruned using the default limits.
+func NewBlockChain(chainDb ethdb.Database, cacheConfig *CacheConfig, config *params.ChainConfig, engine consensus.Engine, vmConfig vm.Config) (*BlockChain, error) {
+	if cacheConfig == nil {
+		cacheConfig = defaultCacheConfig
+	}
+	bodyLimit, blockLimit, receiptsLimit := cacheConfig.blockCacheLimits()
+
+	bodyCache, _ := lru.New(bodyLimit)
+	bodyRLPCache, _ := lru.New(bodyLimit)
+	blockCache, _ := lru.New(blockLimit)
+	receiptsCache, _ := lru.New(receiptsLimit)
 	futureBlocks, _ := lru.New(maxFutureBlocks)
-	badBlocks, _ := lru.New(badBlockLimit)
 
 	bc := &BlockChain{
-		config:       config,
-		chainDb:      chainDb,
-		stateCache:   state.NewDatabase(chainDb),
-		quit:         make(chan struct{}),
-		bodyCache:    bodyCache,
-		bodyRLPCache: bodyRLPCache,
-		blockCache:   blockCache,
-		futureBlocks: futureBlocks,
-		engine:       engine,
-		vmConfig:     vmConfig,
-		badBlocks:    badBlocks,
+		config:        config,
+		cacheConfig:   cacheConfig,
+		chainDb:       chainDb,
+		triegc:        prque.New(),
+		quit:          make(chan struct{}),
+		bodyCache:     bodyCache,
+		bodyRLPCache:  bodyRLPCache,
+		blockCache:    blockCache,
+		receiptsCache: receiptsCache,
+		futureBlocks:  futureBlocks,
+		engine:        engine,
+		vmConfig:      vmConfig,
+	}
+	if cacheConfig.Disabled {
+		bc.stateCache = state.NewDatabaseWithCache(chainDb, cacheConfig.CodeCache)
+	} else {
+		bc.nodes = state.NewNodeStore(chainDb, cacheConfig.Preimages)
+		bc.stateCache = state.NewDatabaseWithCache(bc.nodes, cacheConfig.CodeCache)
 	}
 	bc.SetValidator(NewBlockValidator(config, bc, engine))
 	bc.SetProcessor(NewStateProcessor(config, bc, engine))
+	bc.prefetcher = newStatePrefetcher(config, bc, engine)
 
 	var err error
 	bc.hc, err = NewHeaderChain(chainDb, config, engine, bc.getProcInterrupt)
@@ -169,6 +249,9 @@ func NewBlockChain(chainDb ethdb.Database, config *params.ChainConfig, engine co
 	}
 	// Take ownership of this particular state
 	go bc.update()
+
+	bc.wg.Add(1)
+	go bc.maintainTxIndex()
 	return bc, nil
 }
 
@@ -195,9 +278,12 @@ func (bc *BlockChain) loadLastState() error {
 	}
 	// Make sure the state associated with the block is available
 	if _, err := state.New(currentBlock.Root(), bc.stateCache); err != nil {
-		// Dangling block without a state associated, init from scratch
-		log.Warn("Head state missing, resetting chain", "number", currentBlock.Number(), "hash", currentBlock.Hash())
-		return bc.Reset()
+		// Dangling block without a state associated, rewind to the last one with state
+		log.Warn("Head state missing, repairing chain", "number", currentBlock.Number(), "hash", currentBlock.Hash())
+		if currentBlock = bc.repair(currentBlock); currentBlock == nil {
+			log.Warn("No block with state found, resetting chain")
+			return bc.Reset()
+		}
 	}
 	// Everything seems to be fine, set as the head block
 	bc.currentBlock = currentBlock
@@ -231,6 +317,23 @@ func (bc *BlockChain) loadLastState() error {
 	return nil
 }
 
+// repair rolls back the given head block until one with associated state is
+// found, which is expected after an unclean shutdown of a pruning node. It
+// returns nil if no such ancestor is available.
+func (bc *BlockChain) repair(head *types.Block) *types.Block {
+	for head != nil {
+		if _, err := state.New(head.Root(), bc.stateCache); err == nil {
+			log.Info("Rewound blockchain to past state", "number", head.Number(), "hash", head.Hash())
+			return head
+		}
+		if head.NumberU64() == 0 {
+			return nil
+		}
+		head = bc.GetBlock(head.ParentHash(), head.NumberU64()-1)
+	}
+	return nil
+}
+
 // SetHead rewinds the local chain to a new head. In the case of headers, everything
 // above the new head will be deleted and the new one set. In the case of blocks
 // though, the head may be further rewound if block bodies are missing (non-archive
@@ -252,6 +355,7 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	bc.bodyCache.Purge()
 	bc.bodyRLPCache.Purge()
 	bc.blockCache.Purge()
+	bc.receiptsCache.Purge()
 	bc.futureBlocks.Purge()
 
 	// Rewind the block chain, ensuring we don't end up with a stateless head block
@@ -361,6 +465,27 @@ func (bc *BlockChain) SetValidator(validator Validator) {
 	bc.validator = validator
 }
 
+// SetWhitelist pins the canonical hashes of a set of block numbers. Any block or
+// header conflicting with a pinned hash is refused on import, regardless of the
+// path (sync, propagation or manual import) it arrived through.
+func (bc *BlockChain) SetWhitelist(whitelist map[uint64]common.Hash) {
+	bc.procmu.Lock()
+	defer bc.procmu.Unlock()
+	bc.whitelist = whitelist
+}
+
+// checkWhitelist returns an error if the header conflicts with a pinned hash.
+func (bc *BlockChain) checkWhitelist(header *types.Header) error {
+	bc.procmu.RLock()
+	defer bc.procmu.RUnlock()
+
+	if want, ok := bc.whitelist[header.Number.Uint64()]; ok && header.Hash() != want {
+		log.Warn("Whitelisted block mismatch, rejecting import", "number", header.Number, "have", header.Hash(), "want", want)
+		return ErrWhitelistMismatch
+	}
+	return nil
+}
+
 // Validator returns the current validator.
 func (bc *BlockChain) Validator() Validator {
 	bc.procmu.RLock()
@@ -381,8 +506,33 @@ func (bc *BlockChain) State() (*state.StateDB, error) {
 }
 
 // StateAt returns a new mutable state based on a particular point in time.
+//
+// If the chain is running in pruning mode and the requested state is missing and
+// doesn't belong to one of the recent blocks whose state is retained, it was
+// garbage collected and ErrStatePruned is returned. Missing state of a retained
+// block is reported as is, since it signals a corrupted database.
 func (bc *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
-	return state.New(root, bc.stateCache)
+	statedb, err := state.New(root, bc.stateCache)
+	if _, missing := err.(*trie.MissingNodeError); missing && bc.nodes != nil && !bc.retainedState(root) {
+		return nil, ErrStatePruned
+	}
+	return statedb, err
+}
+
+// retainedState reports whether the given state root belongs to one of the
+// recent canonical blocks whose state is not garbage collected in pruning mode.
+func (bc *BlockChain) retainedState(root common.Hash) bool {
+	header := bc.hc.CurrentHeader()
+	for i := 0; i < triesInMemory && header != nil; i++ {
+		if header.Root == root {
+			return true
+		}
+		if header.Number.Sign() == 0 {
+			break
+		}
+		header = bc.hc.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	}
+	return false
 }
 
 // Reset purges the entire blockchain, restoring it to its genesis state.
@@ -432,6 +582,10 @@ func (bc *BlockChain) ExportN(w io.Writer, first uint64, last uint64) error {
 	}
 	log.Info("Exporting batch of blocks", "count", last-first+1)
 
+	var (
+		start  = time.Now()
+		logged = time.Now()
+	)
 	for nr := first; nr <= last; nr++ {
 		block := bc.GetBlockByNumber(nr)
 		if block == nil {
@@ -441,8 +595,18 @@ func (bc *BlockChain) ExportN(w io.Writer, first uint64, last uint64) error {
 		if err := block.EncodeRLP(w); err != nil {
 			return err
 		}
+		if time.Since(logged) > statsReportLimit {
+			var (
+				done    = nr - first + 1
+				elapsed = time.Since(start)
+				eta     = time.Duration(float64(elapsed) * float64(last-nr) / float64(done))
+			)
+			log.Info("Exporting blocks", "exported", done, "number", nr, "blocks/s", fmt.Sprintf("%.2f", float64(done)/elapsed.Seconds()),
+				"elapsed", common.PrettyDuration(elapsed), "eta", common.PrettyDuration(eta))
+			logged = time.Now()
+		}
 	}
-
+	log.Info("Exported batch of blocks", "count", last-first+1, "elapsed", common.PrettyDuration(time.Since(start)))
 	return nil
 }
 
@@ -464,6 +628,7 @@ func (bc *BlockChain) insert(block *types.Block) {
 		log.Crit("Failed to insert head block hash", "err", err)
 	}
 	bc.currentBlock = block
+	headBlockGauge.Update(int64(block.NumberU64()))
 
 	// If the block is better than out head or is on a different chain, force update heads
 	if updateHeads {
@@ -486,9 +651,11 @@ func (bc *BlockChain) Genesis() *types.Block {
 func (bc *BlockChain) GetBody(hash common.Hash) *types.Body {
 	// Short circuit if the body's already in the cache, retrieve otherwise
 	if cached, ok := bc.bodyCache.Get(hash); ok {
+		bodyCacheHitMeter.Mark(1)
 		body := cached.(*types.Body)
 		return body
 	}
+	bodyCacheMissMeter.Mark(1)
 	body := GetBody(bc.chainDb, hash, bc.hc.GetBlockNumber(hash))
 	if body == nil {
 		return nil
@@ -503,8 +670,10 @@ func (bc *BlockChain) GetBody(hash common.Hash) *types.Body {
 func (bc *BlockChain) GetBodyRLP(hash common.Hash) rlp.RawValue {
 	// Short circuit if the body's already in the cache, retrieve otherwise
 	if cached, ok := bc.bodyRLPCache.Get(hash); ok {
+		bodyCacheHitMeter.Mark(1)
 		return cached.(rlp.RawValue)
 	}
+	bodyCacheMissMeter.Mark(1)
 	body := GetBodyRLP(bc.chainDb, hash, bc.hc.GetBlockNumber(hash))
 	if len(body) == 0 {
 		return nil
@@ -519,8 +688,32 @@ func (bc *BlockChain) HasBlock(hash common.Hash, number uint64) bool {
 	if bc.blockCache.Contains(hash) {
 		return true
 	}
-	ok, _ := bc.chainDb.Has(blockBodyKey(hash, number))
-	return ok
+	if ok, _ := bc.chainDb.Has(blockBodyKey(hash, number)); ok {
+		return true
+	}
+	return hasAncient(bc.chainDb, hash, number)
+}
+
+// TrieDB retrieves the database to read state trie nodes and contract code from.
+// On pruning nodes this includes the recent state that's only held in memory.
+func (bc *BlockChain) TrieDB() trie.Database {
+	if bc.nodes != nil {
+		return bc.nodes
+	}
+	return bc.chainDb
+}
+
+// TrieNode retrieves a blob of data associated with a trie node (or contract
+// code) either from the in-memory cache or from the persistent database.
+func (bc *BlockChain) TrieNode(hash common.Hash) ([]byte, error) {
+	return bc.TrieDB().Get(hash[:])
+}
+
+// HasState checks if the state trie with the given root is fully present in
+// the database (or the in-memory trie cache) or not.
+func (bc *BlockChain) HasState(root common.Hash) bool {
+	_, err := bc.stateCache.OpenTrie(root)
+	return err == nil
 }
 
 // HasBlockAndState checks if a block and associated state trie is fully present
@@ -532,8 +725,7 @@ func (bc *BlockChain) HasBlockAndState(hash common.Hash) bool {
 		return false
 	}
 	// Ensure the associated state is also present
-	_, err := bc.stateCache.OpenTrie(block.Root())
-	return err == nil
+	return bc.HasState(block.Root())
 }
 
 // GetBlock retrieves a block from the database by hash and number,
@@ -541,8 +733,11 @@ func (bc *BlockChain) HasBlockAndState(hash common.Hash) bool {
 func (bc *BlockChain) GetBlock(hash common.Hash, number uint64) *types.Block {
 	// Short circuit if the block's already in the cache, retrieve otherwise
 	if block, ok := bc.blockCache.Get(hash); ok {
+		blockCacheHitMeter.Mark(1)
 		return block.(*types.Block)
 	}
+	blockCacheMissMeter.Mark(1)
+
 	block := GetBlock(bc.chainDb, hash, number)
 	if block == nil {
 		return nil
@@ -552,6 +747,25 @@ func (bc *BlockChain) GetBlock(hash common.Hash, number uint64) *types.Block {
 	return block
 }
 
+// GetReceiptsByHash retrieves the receipts for all transactions in a given block,
+// caching them if found.
+func (bc *BlockChain) GetReceiptsByHash(hash common.Hash) types.Receipts {
+	// Short circuit if the receipts are already in the cache, retrieve otherwise
+	if receipts, ok := bc.receiptsCache.Get(hash); ok {
+		receiptsCacheHitMeter.Mark(1)
+		return receipts.(types.Receipts)
+	}
+	receiptsCacheMissMeter.Mark(1)
+
+	receipts := GetBlockReceipts(bc.chainDb, hash, bc.hc.GetBlockNumber(hash))
+	if receipts == nil {
+		return nil
+	}
+	// Cache the found receipts for next time and return
+	bc.receiptsCache.Add(hash, receipts)
+	return receipts
+}
+
 // GetBlockByHash retrieves a block from the database by hash, caching it if found.
 func (bc *BlockChain) GetBlockByHash(hash common.Hash) *types.Block {
 	return bc.GetBlock(hash, bc.hc.GetBlockNumber(hash))
@@ -606,6 +820,30 @@ func (bc *BlockChain) Stop() {
 	atomic.StoreInt32(&bc.procInterrupt, 1)
 
 	bc.wg.Wait()
+
+	// Ensure the state of a few recent blocks is stored to disk before exiting, so
+	// a restart can resume from the head without reprocessing and small reorgs
+	// don't require deep reprocessing either.
+	if bc.nodes != nil {
+		for _, offset := range []uint64{0, 1, triesInMemory - 1} {
+			if number := bc.CurrentBlock().NumberU64(); number >= offset {
+				recent := bc.GetBlockByNumber(number - offset)
+				if recent == nil {
+					continue
+				}
+				log.Info("Writing cached state to disk", "block", recent.Number(), "hash", recent.Hash(), "root", recent.Root())
+				if err := bc.nodes.Commit(recent.Root()); err != nil {
+					log.Error("Failed to commit recent state trie", "err", err)
+				}
+			}
+		}
+		for !bc.triegc.Empty() {
+			bc.nodes.Dereference(bc.triegc.PopItem().(common.Hash))
+		}
+		if nodes := bc.nodes.Nodes(); nodes != 0 {
+			log.Error("Dangling trie nodes after full cleanup", "nodes", nodes, "size", bc.nodes.Size())
+		}
+	}
 	log.Info("Blockchain manager stopped")
 }
 
@@ -782,6 +1020,19 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 	return 0, nil
 }
 
+// WriteBlockWithoutState writes only the block and its metadata to the database,
+// but does not write any state. This is used to construct competing side forks
+// up to the point where they exceed the canonical total difficulty.
+func (bc *BlockChain) WriteBlockWithoutState(block *types.Block, td *big.Int) error {
+	bc.wg.Add(1)
+	defer bc.wg.Done()
+
+	if err := bc.hc.WriteTd(block.Hash(), block.NumberU64(), td); err != nil {
+		return err
+	}
+	return WriteBlock(bc.chainDb, block)
+}
+
 // WriteBlock writes the block to the chain.
 func (bc *BlockChain) WriteBlockAndState(block *types.Block, receipts []*types.Receipt, state *state.StateDB) (status WriteStatus, err error) {
 	bc.wg.Add(1)
@@ -808,8 +1059,46 @@ func (bc *BlockChain) WriteBlockAndState(block *types.Block, receipts []*types.R
 	if err := WriteBlock(batch, block); err != nil {
 		return NonStatTy, err
 	}
-	if _, err := state.CommitTo(batch, bc.config.IsEIP158(block.Number())); err != nil {
-		return NonStatTy, err
+	if bc.nodes == nil {
+		// Archive node, write the state straight to disk
+		if _, err := state.CommitTo(batch, bc.config.IsEIP158(block.Number())); err != nil {
+			return NonStatTy, err
+		}
+	} else {
+		// Full node, keep the state in memory and garbage collect stale tries
+		root, err := state.CommitTo(bc.nodes, bc.config.IsEIP158(block.Number()))
+		if err != nil {
+			return NonStatTy, err
+		}
+		bc.nodes.Reference(root)
+		bc.triegc.Push(root, -float32(block.NumberU64()))
+
+		if current := block.NumberU64(); current > triesInMemory {
+			// Find the next state trie we need to commit
+			chosen := current - triesInMemory
+
+			// If we exceeded our time or memory allowance, flush an entire trie to disk
+			limit := common.StorageSize(bc.cacheConfig.TrieNodeLimit) * 1024 * 1024
+			if bc.gcproc > bc.cacheConfig.TrieTimeLimit || bc.nodes.Size() > limit {
+				if header := bc.GetHeaderByNumber(chosen); header == nil {
+					log.Warn("Reorg in progress, trie commit postponed", "number", chosen)
+				} else {
+					if err := bc.nodes.Commit(header.Root); err != nil {
+						return NonStatTy, err
+					}
+					bc.gcproc = 0
+				}
+			}
+			// Garbage collect anything below our required write retention
+			for !bc.triegc.Empty() {
+				root, number := bc.triegc.Pop()
+				if uint64(-number) > chosen {
+					bc.triegc.Push(root, number)
+					break
+				}
+				bc.nodes.Dereference(root.(common.Hash))
+			}
+		}
 	}
 	if err := WriteBlockReceipts(batch, block.Hash(), block.NumberU64(), receipts); err != nil {
 		return NonStatTy, err
@@ -849,6 +1138,10 @@ func (bc *BlockChain) WriteBlockAndState(block *types.Block, receipts []*types.R
 	// Set new head.
 	if status == CanonStatTy {
 		bc.insert(block)
+
+		if bc.cacheConfig.Snapshots && isSnapshotBlock(block.NumberU64()) {
+			bc.snapshotState(block)
+		}
 	}
 	bc.futureBlocks.Remove(block.Hash())
 	return status, nil
@@ -870,6 +1163,10 @@ func (bc *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 // only reason this method exists as a separate one is to make locking cleaner
 // with deferred statements.
 func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*types.Log, error) {
+	// If the chain is empty, there's nothing to import
+	if len(chain) == 0 {
+		return 0, nil, nil, nil
+	}
 	// Do a sanity check that the provided chain is actually ordered and linked
 	for i := 1; i < len(chain); i++ {
 		if chain[i].NumberU64() != chain[i-1].NumberU64()+1 || chain[i].ParentHash() != chain[i-1].Hash() {
@@ -908,6 +1205,19 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 	abort, results := bc.engine.VerifyHeaders(bc, headers, seals)
 	defer close(abort)
 
+	// Verify the body roots concurrently with the headers if the default validator
+	// is used, leaving only the chain dependent body checks to the import loop
+	validator, pipelined := bc.Validator().(*BlockValidator)
+
+	var bodyResults <-chan error
+	if pipelined {
+		bodyAbort, results := verifyBodyRoots(chain)
+		defer close(bodyAbort)
+		bodyResults = results
+	}
+	// Start a parallel signature recovery (signer will fluke on fork transition, minimal perf loss)
+	senderCacher.recoverFromBlocks(types.MakeSigner(bc.config, chain[0].Number()), chain)
+
 	// Iterate over the blocks and insert when the verifier permits
 	for i, block := range chain {
 		// If the chain is terminating, stop processing blocks
@@ -920,12 +1230,25 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 			bc.reportBlock(block, nil, ErrBlacklistedHash)
 			return i, events, coalescedLogs, ErrBlacklistedHash
 		}
+		if err := bc.checkWhitelist(block.Header()); err != nil {
+			bc.reportBlock(block, nil, err)
+			return i, events, coalescedLogs, err
+		}
 		// Wait for the block's verification to complete
 		bstart := time.Now()
 
 		err := <-results
+		if pipelined {
+			if berr := <-bodyResults; err == nil {
+				err = berr
+			}
+		}
 		if err == nil {
-			err = bc.Validator().ValidateBody(block)
+			if pipelined {
+				err = validator.validateBodyLinks(block)
+			} else {
+				err = bc.Validator().ValidateBody(block)
+			}
 		}
 		if err != nil {
 			if err == ErrKnownBlock {
@@ -952,8 +1275,41 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 				continue
 			}
 
-			bc.reportBlock(block, nil, err)
-			return i, events, coalescedLogs, err
+			if err == consensus.ErrPrunedAncestor {
+				// Block competing with the canonical chain, store in the db, but don't
+				// process until the competitor TD goes above the canonical TD
+				localTd := bc.GetTd(bc.currentBlock.Hash(), bc.currentBlock.NumberU64())
+				externTd := new(big.Int).Add(bc.GetTd(block.ParentHash(), block.NumberU64()-1), block.Difficulty())
+				if localTd.Cmp(externTd) > 0 {
+					if err = bc.WriteBlockWithoutState(block, externTd); err != nil {
+						return i, events, coalescedLogs, err
+					}
+					continue
+				}
+				// Competitor chain beat canonical, gather all blocks from the common ancestor
+				var winner []*types.Block
+
+				parent := bc.GetBlock(block.ParentHash(), block.NumberU64()-1)
+				for parent != nil && !bc.HasState(parent.Root()) {
+					winner = append(winner, parent)
+					parent = bc.GetBlock(parent.ParentHash(), parent.NumberU64()-1)
+				}
+				for j := 0; j < len(winner)/2; j++ {
+					winner[j], winner[len(winner)-1-j] = winner[len(winner)-1-j], winner[j]
+				}
+				// Import all the pruned blocks to make the state available
+				bc.chainmu.Unlock()
+				_, evs, logs, err := bc.insertChain(winner)
+				bc.chainmu.Lock()
+
+				events, coalescedLogs = append(events, evs...), append(coalescedLogs, logs...)
+				if err != nil {
+					return i, events, coalescedLogs, err
+				}
+			} else {
+				bc.reportBlock(block, nil, err)
+				return i, events, coalescedLogs, err
+			}
 		}
 		// Create a new statedb using the parent block and report an
 		// error if it fails.
@@ -967,18 +1323,29 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 		if err != nil {
 			return i, events, coalescedLogs, err
 		}
+		// If we have a followup block, run that against the current state to pre-cache
+		// transactions and probabilistically some of the account/storage trie nodes.
+		var followupInterrupt uint32
+		if !bc.cacheConfig.NoPrefetch && i+1 < len(chain) {
+			go bc.prefetcher.Prefetch(chain[i+1], state.Copy(), bc.vmConfig, &followupInterrupt)
+		}
 		// Process block using the parent state as reference point.
 		receipts, logs, usedGas, err := bc.processor.Process(block, state, bc.vmConfig)
 		if err != nil {
+			atomic.StoreUint32(&followupInterrupt, 1)
 			bc.reportBlock(block, receipts, err)
 			return i, events, coalescedLogs, err
 		}
 		// Validate the state using the default validator
 		err = bc.Validator().ValidateState(block, parent, state, receipts, usedGas)
 		if err != nil {
+			atomic.StoreUint32(&followupInterrupt, 1)
 			bc.reportBlock(block, receipts, err)
 			return i, events, coalescedLogs, err
 		}
+		atomic.StoreUint32(&followupInterrupt, 1)
+		proctime := time.Since(bstart)
+
 		// Write the block to the chain and get the status.
 		status, err := bc.WriteBlockAndState(block, receipts, state)
 		if err != nil {
@@ -994,6 +1361,9 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 			events = append(events, ChainEvent{block, block.Hash(), logs})
 			lastCanon = block
 
+			// Only count canonical blocks for GC processing time
+			bc.gcproc += proctime
+
 		case SideStatTy:
 			log.Debug("Inserted forked block", "number", block.Number(), "hash", block.Hash(), "diff", block.Difficulty(), "elapsed",
 				common.PrettyDuration(time.Since(bstart)), "txs", len(block.Transactions()), "gas", block.GasUsed(), "uncles", len(block.Uncles()))
@@ -1168,6 +1538,14 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 				bc.chainSideFeed.Send(ChainSideEvent{Block: block})
 			}
 		}()
+		adopted := make(types.Blocks, len(newChain))
+		for i, block := range newChain {
+			adopted[len(newChain)-1-i] = block
+		}
+		// Queue the reorg to be posted in order along with the other chain events
+		bc.reorgLock.Lock()
+		bc.reorgQueue = append(bc.reorgQueue, ReorgEvent{Dropped: oldChain, Adopted: adopted})
+		bc.reorgLock.Unlock()
 	}
 
 	return nil
@@ -1177,6 +1555,9 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 // posts them into the event feed.
 // TODO: Should not expose PostChainEvents. The chain events should be posted in WriteBlock.
 func (bc *BlockChain) PostChainEvents(events []interface{}, logs []*types.Log) {
+	// post the reorgs done since the last post, in the order they happened
+	bc.postReorgs()
+
 	// post event logs for further processing
 	if logs != nil {
 		bc.logsFeed.Send(logs)
@@ -1188,6 +1569,7 @@ func (bc *BlockChain) PostChainEvents(events []interface{}, logs []*types.Log) {
 
 		case ChainHeadEvent:
 			bc.chainHeadFeed.Send(ev)
+			bc.postSafeEvent()
 
 		case ChainSideEvent:
 			bc.chainSideFeed.Send(ev)
@@ -1195,6 +1577,50 @@ func (bc *BlockChain) PostChainEvents(events []interface{}, logs []*types.Log) {
 	}
 }
 
+// postReorgs posts the queued reorg events, in the order the reorgs happened.
+func (bc *BlockChain) postReorgs() {
+	bc.reorgPost.Lock()
+	defer bc.reorgPost.Unlock()
+
+	bc.reorgLock.Lock()
+	reorgs := bc.reorgQueue
+	bc.reorgQueue = nil
+	bc.reorgLock.Unlock()
+
+	for _, ev := range reorgs {
+		bc.reorgFeed.Send(ev)
+	}
+}
+
+// SetSafeDepth sets the number of blocks below the chain head at which canonical
+// blocks are considered safe from reorganisations.
+func (bc *BlockChain) SetSafeDepth(depth uint64) {
+	atomic.StoreUint64(&bc.safeDepth, depth)
+}
+
+// SafeBlock retrieves the canonical block at the safe depth below the current
+// head, or the genesis block if the chain is not long enough.
+func (bc *BlockChain) SafeBlock() *types.Block {
+	head, depth := bc.CurrentBlock().NumberU64(), atomic.LoadUint64(&bc.safeDepth)
+	if head <= depth {
+		return bc.genesisBlock
+	}
+	return bc.GetBlockByNumber(head - depth)
+}
+
+// postSafeEvent announces the safe block if it changed since the last time.
+func (bc *BlockChain) postSafeEvent() {
+	bc.safeLock.Lock()
+	defer bc.safeLock.Unlock()
+
+	safe := bc.SafeBlock()
+	if safe == nil || safe.Hash() == bc.safeHash {
+		return
+	}
+	bc.safeHash = safe.Hash()
+	bc.safeFeed.Send(ChainSafeEvent{Block: safe})
+}
+
 func (bc *BlockChain) update() {
 	futureTimer := time.Tick(5 * time.Second)
 	for {
@@ -1209,30 +1635,50 @@ func (bc *BlockChain) update() {
 
 // BadBlockArgs represents the entries in the list returned when bad blocks are queried.
 type BadBlockArgs struct {
-	Hash   common.Hash   `json:"hash"`
-	Header *types.Header `json:"header"`
+	Hash     common.Hash      `json:"hash"`
+	Header   *types.Header    `json:"header"`
+	RLP      hexutil.Bytes    `json:"rlp"`
+	Receipts []*types.Receipt `json:"receipts"`
+	Error    string           `json:"error"`
 }
 
-// BadBlocks returns a list of the last 'bad blocks' that the client has seen on the network
+// BadBlocks returns a list of the last 'bad blocks' that the client has seen on
+// the network, along with the reason they were rejected and the receipts that
+// were computed locally before the failure.
 func (bc *BlockChain) BadBlocks() ([]BadBlockArgs, error) {
-	headers := make([]BadBlockArgs, 0, bc.badBlocks.Len())
-	for _, hash := range bc.badBlocks.Keys() {
-		if hdr, exist := bc.badBlocks.Peek(hash); exist {
-			header := hdr.(*types.Header)
-			headers = append(headers, BadBlockArgs{header.Hash(), header})
+	blocks := GetBadBlocks(bc.chainDb)
+
+	args := make([]BadBlockArgs, 0, len(blocks))
+	for _, bad := range blocks {
+		blob, err := rlp.EncodeToBytes(bad.Block)
+		if err != nil {
+			return nil, err
 		}
+		receipts := make([]*types.Receipt, len(bad.Receipts))
+		for i, receipt := range bad.Receipts {
+			receipts[i] = (*types.Receipt)(receipt)
+		}
+		args = append(args, BadBlockArgs{
+			Hash:     bad.Block.Hash(),
+			Header:   bad.Block.Header(),
+			RLP:      blob,
+			Receipts: receipts,
+			Error:    bad.Error,
+		})
 	}
-	return headers, nil
+	return args, nil
 }
 
-// addBadBlock adds a bad block to the bad-block LRU cache
-func (bc *BlockChain) addBadBlock(block *types.Block) {
-	bc.badBlocks.Add(block.Header().Hash(), block.Header())
+// addBadBlock persists a bad block into the database for later investigation.
+func (bc *BlockChain) addBadBlock(block *types.Block, receipts types.Receipts, err error) {
+	if err := WriteBadBlock(bc.chainDb, block, receipts, err); err != nil {
+		log.Error("Failed to store bad block", "number", block.Number(), "hash", block.Hash(), "err", err)
+	}
 }
 
 // reportBlock logs a bad block error.
 func (bc *BlockChain) reportBlock(block *types.Block, receipts types.Receipts, err error) {
-	bc.addBadBlock(block)
+	bc.addBadBlock(block, receipts, err)
 
 	var receiptString string
 	for _, receipt := range receipts {
@@ -1261,6 +1707,11 @@ Error: %v
 // because nonces can be verified sparsely, not needing to check each.
 func (bc *BlockChain) InsertHeaderChain(chain []*types.Header, checkFreq int) (int, error) {
 	start := time.Now()
+	for i, header := range chain {
+		if err := bc.checkWhitelist(header); err != nil {
+			return i, err
+		}
+	}
 	if i, err := bc.hc.ValidateHeaderChain(chain, checkFreq); err != nil {
 		return i, err
 	}
@@ -1380,6 +1831,16 @@ func (bc *BlockChain) SubscribeChainSideEvent(ch chan<- ChainSideEvent) event.Su
 	return bc.scope.Track(bc.chainSideFeed.Subscribe(ch))
 }
 
+// SubscribeChainSafeEvent registers a subscription of ChainSafeEvent.
+func (bc *BlockChain) SubscribeChainSafeEvent(ch chan<- ChainSafeEvent) event.Subscription {
+	return bc.scope.Track(bc.safeFeed.Subscribe(ch))
+}
+
+// SubscribeReorgEvent registers a subscription of ReorgEvent.
+func (bc *BlockChain) SubscribeReorgEvent(ch chan<- ReorgEvent) event.Subscription {
+	return bc.scope.Track(bc.reorgFeed.Subscribe(ch))
+}
+
 // SubscribeLogsEvent registers a subscription of []*types.Log.
 func (bc *BlockChain) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription {
 	return bc.scope.Track(bc.logsFeed.Subscribe(ch))
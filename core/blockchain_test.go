@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"math/big"
 	"math/rand"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -32,6 +33,7 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 // newTestBlockChain creates a blockchain without validation.
@@ -46,7 +48,7 @@ func newTestBlockChain(fake bool) *BlockChain {
 	if !fake {
 		engine = ethash.NewTester()
 	}
-	blockchain, err := NewBlockChain(db, gspec.Config, engine, vm.Config{})
+	blockchain, err := NewBlockChain(db, nil, gspec.Config, engine, vm.Config{})
 	if err != nil {
 		panic(err)
 	}
@@ -462,6 +464,71 @@ func testBadHashes(t *testing.T, full bool) {
 	}
 }
 
+// Tests that the insertion functions refuse blocks conflicting with the whitelist.
+func TestWhitelistHeaderMismatch(t *testing.T) { testWhitelistMismatch(t, false) }
+func TestWhitelistBlockMismatch(t *testing.T)  { testWhitelistMismatch(t, true) }
+
+func testWhitelistMismatch(t *testing.T, full bool) {
+	bc := newTestBlockChain(true)
+	defer bc.Stop()
+
+	// Pin a hash the chain to import doesn't contain and try to import it
+	bc.SetWhitelist(map[uint64]common.Hash{3: {0x01}})
+
+	var (
+		n   int
+		err error
+	)
+	if full {
+		blocks := makeBlockChainWithDiff(bc.genesisBlock, []int{1, 2, 3}, 10)
+		n, err = bc.InsertChain(blocks)
+	} else {
+		headers := makeHeaderChainWithDiff(bc.genesisBlock, []int{1, 2, 3}, 10)
+		n, err = bc.InsertHeaderChain(headers, 1)
+	}
+	if err != ErrWhitelistMismatch {
+		t.Errorf("error mismatch: have: %v, want: %v", err, ErrWhitelistMismatch)
+	}
+	if n != 2 {
+		t.Errorf("failed index mismatch: have %d, want %d", n, 2)
+	}
+}
+
+// Tests that blocks failing state validation are persisted into the bad block
+// store along with the rejection reason.
+func TestBadBlockStore(t *testing.T) {
+	var (
+		db, _   = ethdb.NewMemDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+	)
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer blockchain.Stop()
+
+	chain, _ := GenerateChain(gspec.Config, genesis, db, 2, func(i int, gen *BlockGen) {})
+	header := chain[1].Header()
+	header.Root = common.Hash{0x01}
+	chain[1] = chain[1].WithSeal(header)
+
+	if _, err := blockchain.InsertChain(chain); err == nil {
+		t.Fatalf("invalid block imported")
+	}
+	bad, err := blockchain.BadBlocks()
+	if err != nil {
+		t.Fatalf("failed to retrieve bad blocks: %v", err)
+	}
+	if len(bad) != 1 || bad[0].Hash != chain[1].Hash() {
+		t.Fatalf("bad block mismatch: have %v, want %x", bad, chain[1].Hash())
+	}
+	if !strings.Contains(bad[0].Error, "invalid merkle root") {
+		t.Errorf("bad block error mismatch: have %q", bad[0].Error)
+	}
+	var block types.Block
+	if err := rlp.DecodeBytes(bad[0].RLP, &block); err != nil || block.Hash() != chain[1].Hash() {
+		t.Errorf("bad block rlp mismatch: err %v", err)
+	}
+}
+
 // Tests that bad hashes are detected on boot, and the chain rolled back to a
 // good state prior to the bad hash.
 func TestReorgBadHeaderHashes(t *testing.T) { testReorgBadHashes(t, false) }
@@ -496,7 +563,7 @@ func testReorgBadHashes(t *testing.T, full bool) {
 	}
 
 	// Create a new BlockChain and check that it rolled back the state.
-	ncm, err := NewBlockChain(bc.chainDb, bc.config, ethash.NewFaker(), vm.Config{})
+	ncm, err := NewBlockChain(bc.chainDb, nil, bc.config, ethash.NewFaker(), vm.Config{})
 	if err != nil {
 		t.Fatalf("failed to create new chain manager: %v", err)
 	}
@@ -609,7 +676,7 @@ func TestFastVsFullChains(t *testing.T) {
 	// Import the chain as an archive node for the comparison baseline
 	archiveDb, _ := ethdb.NewMemDatabase()
 	gspec.MustCommit(archiveDb)
-	archive, _ := NewBlockChain(archiveDb, gspec.Config, ethash.NewFaker(), vm.Config{})
+	archive, _ := NewBlockChain(archiveDb, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
 	defer archive.Stop()
 
 	if n, err := archive.InsertChain(blocks); err != nil {
@@ -618,7 +685,7 @@ func TestFastVsFullChains(t *testing.T) {
 	// Fast import the chain as a non-archive node to test
 	fastDb, _ := ethdb.NewMemDatabase()
 	gspec.MustCommit(fastDb)
-	fast, _ := NewBlockChain(fastDb, gspec.Config, ethash.NewFaker(), vm.Config{})
+	fast, _ := NewBlockChain(fastDb, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
 	defer fast.Stop()
 
 	headers := make([]*types.Header, len(blocks))
@@ -696,7 +763,7 @@ func TestLightVsFastVsFullChainHeads(t *testing.T) {
 	archiveDb, _ := ethdb.NewMemDatabase()
 	gspec.MustCommit(archiveDb)
 
-	archive, _ := NewBlockChain(archiveDb, gspec.Config, ethash.NewFaker(), vm.Config{})
+	archive, _ := NewBlockChain(archiveDb, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
 	if n, err := archive.InsertChain(blocks); err != nil {
 		t.Fatalf("failed to process block %d: %v", n, err)
 	}
@@ -709,7 +776,7 @@ func TestLightVsFastVsFullChainHeads(t *testing.T) {
 	// Import the chain as a non-archive node and ensure all pointers are updated
 	fastDb, _ := ethdb.NewMemDatabase()
 	gspec.MustCommit(fastDb)
-	fast, _ := NewBlockChain(fastDb, gspec.Config, ethash.NewFaker(), vm.Config{})
+	fast, _ := NewBlockChain(fastDb, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
 	defer fast.Stop()
 
 	headers := make([]*types.Header, len(blocks))
@@ -730,7 +797,7 @@ func TestLightVsFastVsFullChainHeads(t *testing.T) {
 	lightDb, _ := ethdb.NewMemDatabase()
 	gspec.MustCommit(lightDb)
 
-	light, _ := NewBlockChain(lightDb, gspec.Config, ethash.NewFaker(), vm.Config{})
+	light, _ := NewBlockChain(lightDb, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
 	if n, err := light.InsertHeaderChain(headers, 1); err != nil {
 		t.Fatalf("failed to insert header %d: %v", n, err)
 	}
@@ -799,7 +866,7 @@ func TestChainTxReorgs(t *testing.T) {
 		}
 	})
 	// Import the chain. This runs all block validation rules.
-	blockchain, _ := NewBlockChain(db, gspec.Config, ethash.NewFaker(), vm.Config{})
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
 	if i, err := blockchain.InsertChain(chain); err != nil {
 		t.Fatalf("failed to insert original chain[%d]: %v", i, err)
 	}
@@ -870,7 +937,7 @@ func TestLogReorgs(t *testing.T) {
 		signer  = types.NewEIP155Signer(gspec.Config.ChainId)
 	)
 
-	blockchain, _ := NewBlockChain(db, gspec.Config, ethash.NewFaker(), vm.Config{})
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
 	defer blockchain.Stop()
 
 	rmLogsCh := make(chan RemovedLogsEvent)
@@ -904,6 +971,174 @@ func TestLogReorgs(t *testing.T) {
 	}
 }
 
+// Tests that the safe block trails the head at the configured depth, and that a
+// safe event is posted whenever it advances.
+func TestSafeBlockEvent(t *testing.T) {
+	var (
+		db, _   = ethdb.NewMemDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+	)
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer blockchain.Stop()
+
+	blockchain.SetSafeDepth(3)
+	if safe := blockchain.SafeBlock(); safe.Hash() != genesis.Hash() {
+		t.Fatalf("safe block mismatch on empty chain: have #%d", safe.NumberU64())
+	}
+	safeCh := make(chan ChainSafeEvent, 1)
+	blockchain.SubscribeChainSafeEvent(safeCh)
+
+	chain, _ := GenerateChain(gspec.Config, genesis, db, 6, func(i int, gen *BlockGen) {})
+	for i, want := range []uint64{2, 3} {
+		if _, err := blockchain.InsertChain(chain[:5+i]); err != nil {
+			t.Fatalf("failed to insert chain: %v", err)
+		}
+		select {
+		case ev := <-safeCh:
+			if ev.Block.Hash() != chain[want-1].Hash() {
+				t.Errorf("safe event %d: block mismatch: have #%d, want #%d", i, ev.Block.NumberU64(), want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("safe event %d: timeout", i)
+		}
+		if safe := blockchain.SafeBlock(); safe.Hash() != chain[want-1].Hash() {
+			t.Errorf("safe block %d: mismatch: have #%d, want #%d", i, safe.NumberU64(), want)
+		}
+	}
+}
+
+// Tests that the block cache allowance is converted into LRU limits and that the
+// receipts of a block are served through the receipt cache.
+func TestBlockCacheAllowance(t *testing.T) {
+	tests := []struct {
+		allowance int
+		want      int
+	}{
+		{0, receiptsCacheLimit},
+		{1, 16},
+		{48, 512},
+	}
+	for i, tt := range tests {
+		if _, _, receipts := (&CacheConfig{BlockCache: tt.allowance}).blockCacheLimits(); receipts != tt.want {
+			t.Errorf("test %d: receipt cache limit mismatch: have %d, want %d", i, receipts, tt.want)
+		}
+	}
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr    = crypto.PubkeyToAddress(key.PublicKey)
+		db, _   = ethdb.NewMemDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{addr: {Balance: big.NewInt(1000000)}}}
+		genesis = gspec.MustCommit(db)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainId)
+	)
+	blockchain, _ := NewBlockChain(db, &CacheConfig{TrieNodeLimit: 256, TrieTimeLimit: 5 * time.Minute, BlockCache: 1}, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer blockchain.Stop()
+
+	chain, _ := GenerateChain(gspec.Config, genesis, db, 1, func(i int, gen *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(addr), common.Address{0x01}, big.NewInt(1000), bigTxGas, nil, nil), signer, key)
+		gen.AddTx(tx)
+	})
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		receipts := blockchain.GetReceiptsByHash(chain[0].Hash())
+		if len(receipts) != 1 || receipts[0].TxHash != chain[0].Transactions()[0].Hash() {
+			t.Fatalf("attempt %d: receipts mismatch: %v", i, receipts)
+		}
+	}
+	if !blockchain.receiptsCache.Contains(chain[0].Hash()) {
+		t.Errorf("receipts not cached")
+	}
+}
+
+// Tests that a reorg event is posted with the dropped and adopted blocks in the
+// order they need to be rolled back and applied.
+func TestReorgEvent(t *testing.T) {
+	var (
+		db, _   = ethdb.NewMemDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+	)
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer blockchain.Stop()
+
+	reorgCh := make(chan ReorgEvent, 1)
+	blockchain.SubscribeReorgEvent(reorgCh)
+
+	chain, _ := GenerateChain(gspec.Config, genesis, db, 3, func(i int, gen *BlockGen) {})
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	// Generate a fork of equal length but higher difficulty to avoid td ties
+	fork, _ := GenerateChain(gspec.Config, genesis, db, 3, func(i int, gen *BlockGen) {
+		gen.SetCoinbase(common.Address{0x01})
+		gen.OffsetTime(-9)
+	})
+	if _, err := blockchain.InsertChain(fork); err != nil {
+		t.Fatalf("failed to insert forked chain: %v", err)
+	}
+	select {
+	case ev := <-reorgCh:
+		if len(ev.Dropped) != len(chain) {
+			t.Fatalf("dropped block count mismatch: have %d, want %d", len(ev.Dropped), len(chain))
+		}
+		for i, block := range ev.Dropped {
+			if want := chain[len(chain)-1-i].Hash(); block.Hash() != want {
+				t.Errorf("dropped block %d: hash mismatch: have %x, want %x", i, block.Hash(), want)
+			}
+		}
+		if len(ev.Adopted) != len(fork) {
+			t.Fatalf("adopted block count mismatch: have %d, want %d", len(ev.Adopted), len(fork))
+		}
+		for i, block := range ev.Adopted {
+			if want := fork[i].Hash(); block.Hash() != want {
+				t.Errorf("adopted block %d: hash mismatch: have %x, want %x", i, block.Hash(), want)
+			}
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for reorg event")
+	}
+}
+
+// Tests that consecutive reorg events are posted in the order the reorgs happened,
+// by the time the chain insertion returns.
+func TestReorgEventOrder(t *testing.T) {
+	var (
+		db, _   = ethdb.NewMemDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+	)
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer blockchain.Stop()
+
+	reorgCh := make(chan ReorgEvent, 2)
+	blockchain.SubscribeReorgEvent(reorgCh)
+
+	chain, _ := GenerateChain(gspec.Config, genesis, db, 3, func(i int, gen *BlockGen) {})
+	fork, _ := GenerateChain(gspec.Config, genesis, db, 4, func(i int, gen *BlockGen) {
+		gen.SetCoinbase(common.Address{0x01})
+	})
+	longer, _ := GenerateChain(gspec.Config, chain[len(chain)-1], db, 3, func(i int, gen *BlockGen) {})
+
+	for i, blocks := range []types.Blocks{chain, fork, longer} {
+		if _, err := blockchain.InsertChain(blocks); err != nil {
+			t.Fatalf("failed to insert chain %d: %v", i, err)
+		}
+	}
+	for i, first := range []*types.Block{fork[0], chain[0]} {
+		select {
+		case ev := <-reorgCh:
+			if ev.Adopted[0].Hash() != first.Hash() {
+				t.Errorf("reorg %d: adopted chain mismatch: have %x, want %x", i, ev.Adopted[0].Hash(), first.Hash())
+			}
+		default:
+			t.Fatalf("reorg %d: event not posted", i)
+		}
+	}
+}
+
 func TestReorgSideEvent(t *testing.T) {
 	var (
 		db, _   = ethdb.NewMemDatabase()
@@ -917,7 +1152,7 @@ func TestReorgSideEvent(t *testing.T) {
 		signer  = types.NewEIP155Signer(gspec.Config.ChainId)
 	)
 
-	blockchain, _ := NewBlockChain(db, gspec.Config, ethash.NewFaker(), vm.Config{})
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
 	defer blockchain.Stop()
 
 	chain, _ := GenerateChain(gspec.Config, genesis, db, 3, func(i int, gen *BlockGen) {})
@@ -1043,7 +1278,7 @@ func TestEIP155Transition(t *testing.T) {
 		genesis = gspec.MustCommit(db)
 	)
 
-	blockchain, _ := NewBlockChain(db, gspec.Config, ethash.NewFaker(), vm.Config{})
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
 	defer blockchain.Stop()
 
 	blocks, _ := GenerateChain(gspec.Config, genesis, db, 4, func(i int, block *BlockGen) {
@@ -1151,7 +1386,7 @@ func TestEIP161AccountRemoval(t *testing.T) {
 		}
 		genesis = gspec.MustCommit(db)
 	)
-	blockchain, _ := NewBlockChain(db, gspec.Config, ethash.NewFaker(), vm.Config{})
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
 	defer blockchain.Stop()
 
 	blocks, _ := GenerateChain(gspec.Config, genesis, db, 3, func(i int, block *BlockGen) {
@@ -1197,3 +1432,165 @@ func TestEIP161AccountRemoval(t *testing.T) {
 		t.Error("account should not exist")
 	}
 }
+
+// Tests that a pruning full node only retains the state of recent blocks, and
+// that the most recent states are persisted on shutdown.
+func TestTrieGarbageCollection(t *testing.T) {
+	// Generate a canonical chain to act as the main dataset
+	engine := ethash.NewFaker()
+
+	db, _ := ethdb.NewMemDatabase()
+	genesis := new(Genesis).MustCommit(db)
+
+	gendb, _ := ethdb.NewMemDatabase()
+	new(Genesis).MustCommit(gendb)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, gendb, 2*triesInMemory, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{1})
+	})
+	// Import the chain as a pruning full node
+	chain, err := NewBlockChain(db, nil, params.TestChainConfig, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	// Old states must be gone, recent ones available from memory only
+	for i, block := range blocks {
+		if i < triesInMemory {
+			if chain.HasState(block.Root()) {
+				t.Errorf("block %d: stale state not pruned", block.NumberU64())
+			}
+			if _, err := chain.StateAt(block.Root()); err != ErrStatePruned {
+				t.Errorf("block %d: pruned state error mismatch: have %v, want %v", block.NumberU64(), err, ErrStatePruned)
+			}
+		} else {
+			if !chain.HasState(block.Root()) {
+				t.Errorf("block %d: recent state missing", block.NumberU64())
+			}
+			if ok, _ := db.Has(block.Root().Bytes()); ok {
+				t.Errorf("block %d: recent state written to disk", block.NumberU64())
+			}
+		}
+	}
+	// Stop the chain and ensure the head state survives a restart
+	chain.Stop()
+
+	chain, err = NewBlockChain(db, nil, params.TestChainConfig, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to recreate tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if head := chain.CurrentBlock(); head.Hash() != blocks[len(blocks)-1].Hash() {
+		t.Fatalf("head block mismatch after restart: have #%d, want #%d", head.NumberU64(), blocks[len(blocks)-1].NumberU64())
+	}
+	for _, block := range []*types.Block{blocks[len(blocks)-1], blocks[len(blocks)-2], blocks[len(blocks)-triesInMemory]} {
+		if ok, _ := db.Has(block.Root().Bytes()); !ok {
+			t.Errorf("block %d: recent state not persisted on shutdown", block.NumberU64())
+		}
+	}
+}
+
+// Tests that only missing state outside of the window of recent blocks retained
+// by a pruning node is reported as pruned.
+func TestPrunedStateWindow(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	genesis := new(Genesis).MustCommit(db)
+
+	gendb, _ := ethdb.NewMemDatabase()
+	new(Genesis).MustCommit(gendb)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, gendb, 4, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{1})
+	})
+	chain, err := NewBlockChain(db, nil, params.TestChainConfig, ethash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	for _, block := range blocks {
+		if !chain.retainedState(block.Root()) {
+			t.Errorf("block %d: recent state not considered retained", block.NumberU64())
+		}
+	}
+	if chain.retainedState(common.Hash{0x01}) {
+		t.Errorf("unknown state considered retained")
+	}
+	if _, err := chain.StateAt(common.Hash{0x01}); err != ErrStatePruned {
+		t.Errorf("unknown state error mismatch: have %v, want %v", err, ErrStatePruned)
+	}
+}
+
+// Tests that archive nodes never prune any state.
+func TestTrieArchiveMode(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	genesis := new(Genesis).MustCommit(db)
+
+	gendb, _ := ethdb.NewMemDatabase()
+	new(Genesis).MustCommit(gendb)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, gendb, 2*triesInMemory, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{1})
+	})
+	chain, err := NewBlockChain(db, &CacheConfig{Disabled: true}, params.TestChainConfig, ethash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	for _, block := range blocks {
+		if ok, _ := db.Has(block.Root().Bytes()); !ok {
+			t.Errorf("block %d: archive state missing from disk", block.NumberU64())
+		}
+		if _, err := chain.StateAt(block.Root()); err != nil {
+			t.Errorf("block %d: failed to open archive state: %v", block.NumberU64(), err)
+		}
+	}
+}
+
+// Tests that a side chain forking off below the pruned state horizon is stored
+// without state until it becomes canonical, at which point the missing ancestor
+// states are regenerated and the fork imported.
+func TestPrunedSideChainImport(t *testing.T) {
+	engine := ethash.NewFaker()
+
+	db, _ := ethdb.NewMemDatabase()
+	genesis := new(Genesis).MustCommit(db)
+
+	gendb, _ := ethdb.NewMemDatabase()
+	new(Genesis).MustCommit(gendb)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, gendb, 2*triesInMemory, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{1})
+	})
+	forks, _ := GenerateChain(params.TestChainConfig, blocks[9], gendb, 2*triesInMemory, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{2})
+	})
+	chain, err := NewBlockChain(db, nil, params.TestChainConfig, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	if chain.HasState(blocks[9].Root()) {
+		t.Fatalf("fork point state not pruned")
+	}
+	if n, err := chain.InsertChain(forks); err != nil {
+		t.Fatalf("fork %d: failed to insert into chain: %v", n, err)
+	}
+	if head := chain.CurrentBlock(); head.Hash() != forks[len(forks)-1].Hash() {
+		t.Fatalf("head block mismatch: have #%d [%x…], want #%d [%x…]", head.NumberU64(), head.Hash().Bytes()[:4],
+			forks[len(forks)-1].NumberU64(), forks[len(forks)-1].Hash().Bytes()[:4])
+	}
+	if !chain.HasState(forks[len(forks)-1].Root()) {
+		t.Fatalf("fork head state missing")
+	}
+}
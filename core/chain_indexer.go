@@ -201,10 +201,15 @@ func (c *ChainIndexer) eventLoop(currentHeader *types.Header, events chan ChainE
 			}
 			header := ev.Block.Header()
 			if header.ParentHash != prevHash {
-				// Reorg to the common ancestor (might not exist in light sync mode, skip reorg then)
+				// If the previous head is still canonical, the chain was merely extended
+				// without events (e.g. by a fast sync), so catch up without a rollback.
+				// Otherwise reorg to the common ancestor (might not exist in light sync
+				// mode, skip reorg then).
 				// TODO(karalabe, zsfelfoldi): This seems a bit brittle, can we detect this case explicitly?
-				if h := FindCommonAncestor(c.chainDb, prevHeader, header); h != nil {
-					c.newHead(h.Number.Uint64(), true)
+				if GetCanonicalHash(c.chainDb, prevHeader.Number.Uint64()) != prevHash {
+					if h := FindCommonAncestor(c.chainDb, prevHeader, header); h != nil {
+						c.newHead(h.Number.Uint64(), true)
+					}
 				}
 			}
 			c.newHead(header.Number.Uint64(), false)
@@ -279,7 +284,7 @@ func (c *ChainIndexer) updateLoop() {
 				if time.Since(updated) > 8*time.Second {
 					if c.knownSections > c.storedSections+1 {
 						updating = true
-						c.log.Info("Upgrading chain index", "percentage", c.storedSections*100/c.knownSections)
+						c.log.Info("Upgrading chain index", "percentage", c.storedSections*100/c.knownSections, "sections", c.storedSections, "known", c.knownSections)
 					}
 					updated = time.Now()
 				}
@@ -376,6 +381,16 @@ func (c *ChainIndexer) Sections() (uint64, uint64, common.Hash) {
 	return c.storedSections, c.storedSections*c.sectionSize - 1, c.SectionHead(c.storedSections - 1)
 }
 
+// Progress returns the number of sections successfully indexed into the database
+// and the number of sections known to be complete block wise. The difference is
+// the amount of work the indexer still has to do in the background.
+func (c *ChainIndexer) Progress() (stored uint64, known uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.storedSections, c.knownSections
+}
+
 // AddChildIndexer adds a child ChainIndexer that can use the output of this one
 func (c *ChainIndexer) AddChildIndexer(indexer *ChainIndexer) {
 	c.lock.Lock()
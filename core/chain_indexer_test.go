@@ -20,12 +20,14 @@ import (
 	"fmt"
 	"math/big"
 	"math/rand"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
 )
 
 // Runs multiple tests with randomized parameters.
@@ -132,6 +134,97 @@ func testChainIndexer(t *testing.T, count int) {
 	}
 }
 
+// Tests that if the chain is extended without events (e.g. by a fast sync), the
+// indexer catches up from the next head event without rolling anything back.
+func TestChainIndexerCatchUp(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	defer db.Close()
+
+	// Create a short chain with a few indexed sections
+	var headers []*types.Header
+	inject := func(number uint64) {
+		header := &types.Header{Number: big.NewInt(int64(number)), Extra: big.NewInt(rand.Int63()).Bytes()}
+		if number > 0 {
+			header.ParentHash = headers[number-1].Hash()
+		}
+		WriteHeader(db, header)
+		WriteCanonicalHash(db, header.Hash(), number)
+		headers = append(headers, header)
+	}
+	for i := uint64(0); i < 32; i++ {
+		inject(i)
+	}
+	backend := new(testCountingIndexBackend)
+	indexer := NewChainIndexer(db, ethdb.NewTable(db, "i"), backend, 8, 0, 0, "catchup")
+	defer indexer.Close()
+
+	chain := &testIndexerChain{head: headers[31]}
+	indexer.Start(chain)
+	waitIndexerProgress(t, indexer, 4)
+
+	// Extend the chain silently, then announce only the new head
+	for i := uint64(32); i < 80; i++ {
+		inject(i)
+	}
+	chain.feed.Send(ChainEvent{Block: types.NewBlockWithHeader(headers[79])})
+	waitIndexerProgress(t, indexer, 10)
+
+	if resets := backend.resets(); resets != 10 {
+		t.Fatalf("section processing count mismatch: have %d, want %d", resets, 10)
+	}
+}
+
+// waitIndexerProgress waits until the indexer both knows about and has stored
+// the given number of sections.
+func waitIndexerProgress(t *testing.T, indexer *ChainIndexer, sections uint64) {
+	var stored, known uint64
+	for i := 0; i < 300; i++ {
+		if stored, known = indexer.Progress(); stored == sections && known == sections {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("indexer progress mismatch: have %d/%d, want %d", stored, known, sections)
+}
+
+// testIndexerChain implements ChainIndexerChain on top of a static head and a
+// manually driven event feed.
+type testIndexerChain struct {
+	head *types.Header
+	feed event.Feed
+}
+
+func (c *testIndexerChain) CurrentHeader() *types.Header { return c.head }
+
+func (c *testIndexerChain) SubscribeChainEvent(ch chan<- ChainEvent) event.Subscription {
+	return c.feed.Subscribe(ch)
+}
+
+// testCountingIndexBackend implements ChainIndexerBackend, counting the sections
+// it was asked to process.
+type testCountingIndexBackend struct {
+	lock  sync.Mutex
+	count int
+}
+
+func (b *testCountingIndexBackend) Reset(section uint64, prevHead common.Hash) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.count++
+	return nil
+}
+
+func (b *testCountingIndexBackend) Process(header *types.Header) {}
+func (b *testCountingIndexBackend) Commit() error                { return nil }
+
+func (b *testCountingIndexBackend) resets() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.count
+}
+
 // testChainIndexBackend implements ChainIndexerBackend
 type testChainIndexBackend struct {
 	t                          *testing.T
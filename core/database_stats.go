@@ -0,0 +1,83 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// databaseTables are the groups of chain database entries sharing a key prefix
+// whose storage space is reported separately.
+var databaseTables = []struct {
+	name   string
+	prefix []byte
+}{
+	{"headers", headerPrefix},
+	{"hashes", blockHashPrefix},
+	{"bodies", bodyPrefix},
+	{"receipts", blockReceiptsPrefix},
+	{"txlookups", lookupPrefix},
+	{"bloombits", bloomBitsPrefix},
+	{"bloombits-index", BloomBitsIndexPrefix},
+	{"preimages", []byte(preimagePrefix)},
+}
+
+// TableUsage is the storage space used by a group of chain database entries.
+type TableUsage struct {
+	Table string `json:"table"`
+	Size  uint64 `json:"size"` // Approximate size in bytes
+}
+
+// DatabaseUsage returns the storage space used by the chain database, broken
+// down by the tables of the key-value store and the ancient store. The entries
+// not belonging to any table (e.g. the state trie) are reported as "other". It
+// returns nil if the key-value store can't measure its size.
+func DatabaseUsage(db ethdb.Database) ([]TableUsage, error) {
+	measurer, ok := KeyValueStore(db).(ethdb.Measurer)
+	if !ok {
+		return nil, nil
+	}
+	total, err := measurer.SizeOfPrefix(nil)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		usage    []TableUsage
+		assigned uint64
+	)
+	for _, table := range databaseTables {
+		size, err := measurer.SizeOfPrefix(table.prefix)
+		if err != nil {
+			return nil, err
+		}
+		usage = append(usage, TableUsage{Table: table.name, Size: size})
+		assigned += size
+	}
+	// The sizes are approximations, don't let them underflow the remainder
+	other := uint64(0)
+	if total > assigned {
+		other = total - assigned
+	}
+	usage = append(usage, TableUsage{Table: "other", Size: other})
+
+	if frdb, ok := db.(*freezerDatabase); ok {
+		for _, name := range frdb.freezer.tableNames() {
+			usage = append(usage, TableUsage{Table: "ancient/" + name, Size: frdb.freezer.tables[name].size()})
+		}
+	}
+	return usage, nil
+}
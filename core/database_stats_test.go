@@ -0,0 +1,57 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// Tests that the storage space of the chain database is attributed to the right
+// tables.
+func TestDatabaseUsage(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+
+	header := &types.Header{Number: big.NewInt(1), Extra: []byte("test header")}
+	if err := WriteHeader(db, header); err != nil {
+		t.Fatalf("failed to write header: %v", err)
+	}
+	db.Put(header.Root[:], []byte("trie node"))
+
+	usage, err := DatabaseUsage(db)
+	if err != nil {
+		t.Fatalf("failed to measure database: %v", err)
+	}
+	sizes := make(map[string]uint64)
+	for _, table := range usage {
+		sizes[table.Table] = table.Size
+	}
+	if len(sizes) != len(databaseTables)+1 {
+		t.Errorf("table count mismatch: have %d, want %d", len(sizes), len(databaseTables)+1)
+	}
+	for _, table := range []string{"headers", "hashes", "other"} {
+		if sizes[table] == 0 {
+			t.Errorf("table %s: no storage space accounted", table)
+		}
+	}
+	if sizes["bodies"] != 0 {
+		t.Errorf("bodies: unexpected storage space accounted: %d", sizes["bodies"])
+	}
+}
@@ -44,9 +44,14 @@ type DatabaseDeleter interface {
 }
 
 var (
-	headHeaderKey = []byte("LastHeader")
-	headBlockKey  = []byte("LastBlock")
-	headFastKey   = []byte("LastFast")
+	headHeaderKey  = []byte("LastHeader")
+	headBlockKey   = []byte("LastBlock")
+	headFastKey    = []byte("LastFast")
+	txIndexTailKey = []byte("TransactionIndexTail")
+	trieSyncKey    = []byte("TrieSync")
+	fastPivotKey   = []byte("FastPivot")
+	backfillKey    = []byte("HeaderBackfill")
+	badBlocksKey   = []byte("InvalidBlocks")
 
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`).
 	headerPrefix        = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
@@ -74,6 +79,14 @@ var (
 	preimageHitCounter = metrics.NewCounter("db/preimage/hits")
 )
 
+// BadBlock is a block that failed import, persisted along with the validation
+// error and the locally computed receipts for later investigation.
+type BadBlock struct {
+	Block    *types.Block
+	Receipts []*types.ReceiptForStorage
+	Error    string
+}
+
 // TxLookupEntry is a positional metadata to help looking up the data content of
 // a transaction or receipt given only its hash.
 type TxLookupEntry struct {
@@ -93,7 +106,12 @@ func encodeBlockNumber(number uint64) []byte {
 func GetCanonicalHash(db DatabaseReader, number uint64) common.Hash {
 	data, _ := db.Get(append(append(headerPrefix, encodeBlockNumber(number)...), numSuffix...))
 	if len(data) == 0 {
-		return common.Hash{}
+		if store, ok := db.(AncientReader); ok {
+			data, _ = store.Ancient(freezerHashTable, number)
+		}
+		if len(data) == 0 {
+			return common.Hash{}
+		}
 	}
 	return common.BytesToHash(data)
 }
@@ -146,10 +164,54 @@ func GetHeadFastBlockHash(db DatabaseReader) common.Hash {
 	return common.BytesToHash(data)
 }
 
+// GetTxIndexTail retrieves the number of the oldest block whose transactions
+// are indexed. If the tail is not stored, every transaction is indexed.
+func GetTxIndexTail(db DatabaseReader) *uint64 {
+	data, _ := db.Get(txIndexTailKey)
+	if len(data) != 8 {
+		return nil
+	}
+	number := binary.BigEndian.Uint64(data)
+	return &number
+}
+
+// GetFastTrieProgress retrieves the number of trie nodes fast synced to allow
+// reporting correct numbers across restarts.
+func GetFastTrieProgress(db DatabaseReader) uint64 {
+	data, _ := db.Get(trieSyncKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// GetFastSyncPivot retrieves the number of the pivot block an interrupted fast
+// sync was targeting, or zero if no fast sync is in progress.
+func GetFastSyncPivot(db DatabaseReader) uint64 {
+	data, _ := db.Get(fastPivotKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// GetHeaderBackfillTail retrieves the number of the lowest header downloaded by an
+// interrupted reverse header backfill, or zero if no backfill is in progress.
+func GetHeaderBackfillTail(db DatabaseReader) uint64 {
+	data, _ := db.Get(backfillKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
 // GetHeaderRLP retrieves a block header in its raw RLP database encoding, or nil
 // if the header's not found.
 func GetHeaderRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
 	data, _ := db.Get(headerKey(hash, number))
+	if len(data) == 0 {
+		data = readAncient(db, freezerHeaderTable, hash, number)
+	}
 	return data
 }
 
@@ -171,6 +233,9 @@ func GetHeader(db DatabaseReader, hash common.Hash, number uint64) *types.Header
 // GetBodyRLP retrieves the block body (transactions and uncles) in RLP encoding.
 func GetBodyRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
 	data, _ := db.Get(blockBodyKey(hash, number))
+	if len(data) == 0 {
+		data = readAncient(db, freezerBodiesTable, hash, number)
+	}
 	return data
 }
 
@@ -182,6 +247,14 @@ func blockBodyKey(hash common.Hash, number uint64) []byte {
 	return append(append(bodyPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
 }
 
+func blockReceiptsKey(hash common.Hash, number uint64) []byte {
+	return append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
+func headerTDKey(hash common.Hash, number uint64) []byte {
+	return append(headerKey(hash, number), tdSuffix...)
+}
+
 // GetBody retrieves the block body (transactons, uncles) corresponding to the
 // hash, nil if none found.
 func GetBody(db DatabaseReader, hash common.Hash, number uint64) *types.Body {
@@ -200,7 +273,10 @@ func GetBody(db DatabaseReader, hash common.Hash, number uint64) *types.Body {
 // GetTd retrieves a block's total difficulty corresponding to the hash, nil if
 // none found.
 func GetTd(db DatabaseReader, hash common.Hash, number uint64) *big.Int {
-	data, _ := db.Get(append(append(append(headerPrefix, encodeBlockNumber(number)...), hash[:]...), tdSuffix...))
+	data, _ := db.Get(headerTDKey(hash, number))
+	if len(data) == 0 {
+		data = readAncient(db, freezerDifficultyTable, hash, number)
+	}
 	if len(data) == 0 {
 		return nil
 	}
@@ -235,7 +311,10 @@ func GetBlock(db DatabaseReader, hash common.Hash, number uint64) *types.Block {
 // GetBlockReceipts retrieves the receipts generated by the transactions included
 // in a block given by its hash.
 func GetBlockReceipts(db DatabaseReader, hash common.Hash, number uint64) types.Receipts {
-	data, _ := db.Get(append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash[:]...))
+	data, _ := db.Get(blockReceiptsKey(hash, number))
+	if len(data) == 0 {
+		data = readAncient(db, freezerReceiptTable, hash, number)
+	}
 	if len(data) == 0 {
 		return nil
 	}
@@ -374,6 +453,42 @@ func WriteHeadFastBlockHash(db ethdb.Putter, hash common.Hash) error {
 	return nil
 }
 
+// WriteTxIndexTail stores the number of the oldest block whose transactions
+// are indexed.
+func WriteTxIndexTail(db ethdb.Putter, number uint64) error {
+	if err := db.Put(txIndexTailKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store transaction index tail", "err", err)
+	}
+	return nil
+}
+
+// WriteFastTrieProgress stores the fast sync trie process counter to support
+// retrieving it across restarts.
+func WriteFastTrieProgress(db ethdb.Putter, count uint64) error {
+	if err := db.Put(trieSyncKey, encodeBlockNumber(count)); err != nil {
+		log.Crit("Failed to store fast sync trie progress", "err", err)
+	}
+	return nil
+}
+
+// WriteFastSyncPivot stores the number of the pivot block of the running fast
+// sync to allow resuming it across restarts.
+func WriteFastSyncPivot(db ethdb.Putter, number uint64) error {
+	if err := db.Put(fastPivotKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store fast sync pivot", "err", err)
+	}
+	return nil
+}
+
+// WriteHeaderBackfillTail stores the number of the lowest header downloaded by the
+// running reverse header backfill to allow resuming it across restarts.
+func WriteHeaderBackfillTail(db ethdb.Putter, number uint64) error {
+	if err := db.Put(backfillKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store header backfill tail", "err", err)
+	}
+	return nil
+}
+
 // WriteHeader serializes a block header into the database.
 func WriteHeader(db ethdb.Putter, header *types.Header) error {
 	data, err := rlp.EncodeToBytes(header)
@@ -493,6 +608,17 @@ func WriteBloomBits(db ethdb.Putter, bit uint, section uint64, head common.Hash,
 	}
 }
 
+// DeleteFastSyncPivot removes the fast sync pivot marker once fast sync completes.
+func DeleteFastSyncPivot(db DatabaseDeleter) {
+	db.Delete(fastPivotKey)
+}
+
+// DeleteHeaderBackfillTail removes the backfill marker once the header history is
+// complete.
+func DeleteHeaderBackfillTail(db DatabaseDeleter) {
+	db.Delete(backfillKey)
+}
+
 // DeleteCanonicalHash removes the number to hash canonical mapping.
 func DeleteCanonicalHash(db DatabaseDeleter, number uint64) {
 	db.Delete(append(append(headerPrefix, encodeBlockNumber(number)...), numSuffix...))
@@ -604,6 +730,50 @@ func GetChainConfig(db DatabaseReader, hash common.Hash) (*params.ChainConfig, e
 	return &config, nil
 }
 
+// GetBadBlocks retrieves the bad blocks stored in the database, ordered from the
+// highest block number downwards.
+func GetBadBlocks(db DatabaseReader) []*BadBlock {
+	data, _ := db.Get(badBlocksKey)
+	if len(data) == 0 {
+		return nil
+	}
+	var blocks []*BadBlock
+	if err := rlp.DecodeBytes(data, &blocks); err != nil {
+		log.Error("Invalid bad block list RLP", "err", err)
+		return nil
+	}
+	return blocks
+}
+
+// WriteBadBlock stores a bad block along with the receipts computed for it and
+// the reason it was rejected, evicting the lowest entries above the limit.
+func WriteBadBlock(db ethdb.Database, block *types.Block, receipts types.Receipts, reason error) error {
+	blocks := GetBadBlocks(db)
+	for _, bad := range blocks {
+		if bad.Block.Hash() == block.Hash() {
+			return nil
+		}
+	}
+	bad := &BadBlock{Block: block, Error: reason.Error()}
+	for _, receipt := range receipts {
+		bad.Receipts = append(bad.Receipts, (*types.ReceiptForStorage)(receipt))
+	}
+	// Insert the new entry in number order, dropping the lowest ones above the limit
+	i := 0
+	for i < len(blocks) && blocks[i].Block.NumberU64() >= block.NumberU64() {
+		i++
+	}
+	blocks = append(blocks[:i], append([]*BadBlock{bad}, blocks[i:]...)...)
+	if len(blocks) > badBlockLimit {
+		blocks = blocks[:badBlockLimit]
+	}
+	data, err := rlp.EncodeToBytes(blocks)
+	if err != nil {
+		return err
+	}
+	return db.Put(badBlocksKey, data)
+}
+
 // FindCommonAncestor returns the last common ancestor of two block headers
 func FindCommonAncestor(db DatabaseReader, a, b *types.Header) *types.Header {
 	for bn := b.Number.Uint64(); a.Number.Uint64() > bn; {
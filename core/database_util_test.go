@@ -18,6 +18,7 @@ package core
 
 import (
 	"bytes"
+	"errors"
 	"math/big"
 	"testing"
 
@@ -286,6 +287,36 @@ func TestHeadStorage(t *testing.T) {
 	}
 }
 
+// Tests that the fast sync trie progress counter can be stored and retrieved.
+func TestFastTrieProgressStorage(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+
+	if count := GetFastTrieProgress(db); count != 0 {
+		t.Fatalf("Non zero progress in pristine database: %d", count)
+	}
+	WriteFastTrieProgress(db, 314)
+	if count := GetFastTrieProgress(db); count != 314 {
+		t.Fatalf("Trie progress mismatch: have %d, want %d", count, 314)
+	}
+}
+
+// Tests that the fast sync pivot marker can be stored, retrieved and removed.
+func TestFastSyncPivotStorage(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+
+	if pivot := GetFastSyncPivot(db); pivot != 0 {
+		t.Fatalf("Non zero pivot in pristine database: %d", pivot)
+	}
+	WriteFastSyncPivot(db, 4321)
+	if pivot := GetFastSyncPivot(db); pivot != 4321 {
+		t.Fatalf("Pivot mismatch: have %d, want %d", pivot, 4321)
+	}
+	DeleteFastSyncPivot(db)
+	if pivot := GetFastSyncPivot(db); pivot != 0 {
+		t.Fatalf("Deleted pivot returned: %d", pivot)
+	}
+}
+
 // Tests that positional lookup metadata can be stored and retrieved.
 func TestLookupStorage(t *testing.T) {
 	db, _ := ethdb.NewMemDatabase()
@@ -386,3 +417,45 @@ func TestBlockReceiptStorage(t *testing.T) {
 		t.Fatalf("deleted receipts returned: %v", rs)
 	}
 }
+
+// Tests that bad blocks are stored ordered by number, deduplicated and capped.
+func TestBadBlockStorage(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+
+	if blocks := GetBadBlocks(db); len(blocks) != 0 {
+		t.Fatalf("non existent bad blocks returned: %v", blocks)
+	}
+	receipt := &types.Receipt{CumulativeGasUsed: big.NewInt(1), GasUsed: big.NewInt(1), Logs: []*types.Log{}}
+	for _, number := range []int64{3, 1, 2, 3} {
+		block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(number), Extra: []byte("bad block")})
+		if err := WriteBadBlock(db, block, types.Receipts{receipt}, errors.New("invalid merkle root")); err != nil {
+			t.Fatalf("failed to write bad block #%d: %v", number, err)
+		}
+	}
+	blocks := GetBadBlocks(db)
+	if len(blocks) != 3 {
+		t.Fatalf("bad block count mismatch: have %d, want %d", len(blocks), 3)
+	}
+	for i, bad := range blocks {
+		if number := bad.Block.NumberU64(); number != uint64(3-i) {
+			t.Errorf("bad block %d: number mismatch: have %d, want %d", i, number, 3-i)
+		}
+		if bad.Error != "invalid merkle root" {
+			t.Errorf("bad block %d: error mismatch: have %q", i, bad.Error)
+		}
+		if len(bad.Receipts) != 1 {
+			t.Errorf("bad block %d: receipt count mismatch: have %d, want 1", i, len(bad.Receipts))
+		}
+	}
+	// Overflow the store and ensure only the highest entries are retained
+	for number := int64(10); number < 10+badBlockLimit; number++ {
+		WriteBadBlock(db, types.NewBlockWithHeader(&types.Header{Number: big.NewInt(number)}), nil, errors.New("bad"))
+	}
+	blocks = GetBadBlocks(db)
+	if len(blocks) != badBlockLimit {
+		t.Fatalf("bad block count mismatch: have %d, want %d", len(blocks), badBlockLimit)
+	}
+	if number := blocks[len(blocks)-1].Block.NumberU64(); number != 10 {
+		t.Fatalf("lowest retained bad block mismatch: have #%d, want #10", number)
+	}
+}
@@ -29,7 +29,15 @@ var (
 	// ErrBlacklistedHash is returned if a block to import is on the blacklist.
 	ErrBlacklistedHash = errors.New("blacklisted hash")
 
+	// ErrWhitelistMismatch is returned if a block to import conflicts with a block
+	// hash pinned by the operator.
+	ErrWhitelistMismatch = errors.New("whitelisted block mismatch")
+
 	// ErrNonceTooHigh is returned if the nonce of a transaction is higher than the
 	// next one expected based on the local chain.
 	ErrNonceTooHigh = errors.New("nonce too high")
+
+	// ErrStatePruned is returned if the state of a historical block is requested,
+	// but it was already garbage collected by a pruning (non-archive) node.
+	ErrStatePruned = errors.New("historical state not available in full (pruning) mode, use an archive node")
 )
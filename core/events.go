@@ -52,3 +52,16 @@ type ChainSideEvent struct {
 }
 
 type ChainHeadEvent struct{ Block *types.Block }
+
+// ChainSafeEvent is posted when the safe block, i.e. the canonical block at the
+// configured safe depth below the head, changes.
+type ChainSafeEvent struct{ Block *types.Block }
+
+// ReorgEvent is posted when the canonical chain is reorganised. Dropped holds the
+// blocks removed from the canonical chain, ordered from the old head backwards,
+// and Adopted the blocks that replaced them, ordered from the common ancestor
+// forwards, i.e. in the order they need to be rolled back and applied.
+type ReorgEvent struct {
+	Dropped types.Blocks
+	Adopted types.Blocks
+}
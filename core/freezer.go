@@ -0,0 +1,414 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const (
+	// freezerHashTable indicates the name of the freezer canonical hash table.
+	freezerHashTable = "hashes"
+
+	// freezerHeaderTable indicates the name of the freezer header table.
+	freezerHeaderTable = "headers"
+
+	// freezerBodiesTable indicates the name of the freezer block body table.
+	freezerBodiesTable = "bodies"
+
+	// freezerReceiptTable indicates the name of the freezer receipts table.
+	freezerReceiptTable = "receipts"
+
+	// freezerDifficultyTable indicates the name of the freezer total difficulty table.
+	freezerDifficultyTable = "diffs"
+)
+
+// freezerNoSnappy configures whether compression is disabled for the ancient
+// tables. Hashes and difficulties don't compress well.
+var freezerNoSnappy = map[string]bool{
+	freezerHashTable:       true,
+	freezerHeaderTable:     false,
+	freezerBodiesTable:     false,
+	freezerReceiptTable:    false,
+	freezerDifficultyTable: true,
+}
+
+const (
+	// freezerRecheckInterval is the frequency to check the key-value database for
+	// chain progression that might permit new blocks to be frozen into immutable
+	// storage.
+	freezerRecheckInterval = time.Minute
+
+	// freezerBatchLimit is the maximum number of blocks to freeze in one batch
+	// before doing an fsync and deleting it from the key-value store.
+	freezerBatchLimit = 30000
+)
+
+// errUnknownTable is returned if the user attempts to read from a table that is
+// not tracked by the freezer.
+var errUnknownTable = errors.New("unknown table")
+
+// AncientReader is implemented by chain databases backed by an ancient store,
+// allowing the accessors to fall back to immutable chain data that has already
+// been moved out of the key-value store.
+type AncientReader interface {
+	// HasAncient returns an indicator whether the specified data exists in the
+	// ancient store.
+	HasAncient(kind string, number uint64) bool
+
+	// Ancient retrieves an ancient binary blob from the append-only immutable files.
+	Ancient(kind string, number uint64) ([]byte, error)
+
+	// Ancients returns the number of items frozen into the ancient store.
+	Ancients() uint64
+}
+
+// AncientWriter is implemented by chain databases backed by an ancient store,
+// allowing the chain to be rewound below the frozen threshold.
+type AncientWriter interface {
+	// TruncateAncients discards all but the first n ancient items.
+	TruncateAncients(n uint64) error
+}
+
+// freezer is an append-only database to store immutable chain data into flat
+// files. The append only nature ensures that disk writes are minimized and that
+// ancient data doesn't take part in the compaction of the key-value store, so
+// it can also live on cheaper storage.
+type freezer struct {
+	frozen uint64 // Number of blocks already frozen (atomic, needs 64 bit alignment)
+
+	tables map[string]*freezerTable // Data tables for storing everything
+	lock   sync.Mutex               // Mutex serializing appends and truncations
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newFreezer creates a chain freezer that moves ancient chain data into
+// append-only flat file containers.
+func newFreezer(datadir string) (*freezer, error) {
+	freezer := &freezer{
+		tables: make(map[string]*freezerTable),
+		quit:   make(chan struct{}),
+	}
+	for name, disableSnappy := range freezerNoSnappy {
+		table, err := newTable(datadir, name, disableSnappy)
+		if err != nil {
+			for _, table := range freezer.tables {
+				table.Close()
+			}
+			return nil, err
+		}
+		freezer.tables[name] = table
+	}
+	if err := freezer.repair(); err != nil {
+		freezer.close()
+		return nil, err
+	}
+	log.Info("Opened ancient database", "database", datadir, "frozen", atomic.LoadUint64(&freezer.frozen))
+	return freezer, nil
+}
+
+// repair truncates all data tables to the same length, as a crash might have
+// interrupted a batch half way through.
+func (f *freezer) repair() error {
+	min := uint64(0)
+	for i, name := range f.tableNames() {
+		items := atomic.LoadUint64(&f.tables[name].items)
+		if i == 0 || items < min {
+			min = items
+		}
+	}
+	for _, table := range f.tables {
+		if err := table.truncate(min); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, min)
+	return nil
+}
+
+// tableNames returns the names of the freezer tables in a deterministic order.
+func (f *freezer) tableNames() []string {
+	return []string{freezerHashTable, freezerHeaderTable, freezerBodiesTable, freezerReceiptTable, freezerDifficultyTable}
+}
+
+// close terminates the chain freezer, closing all the data files.
+func (f *freezer) close() error {
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// HasAncient returns an indicator whether the specified ancient data exists
+// in the freezer.
+func (f *freezer) HasAncient(kind string, number uint64) bool {
+	if table := f.tables[kind]; table != nil {
+		return table.has(number)
+	}
+	return false
+}
+
+// Ancient retrieves an ancient binary blob from the append-only immutable files.
+func (f *freezer) Ancient(kind string, number uint64) ([]byte, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.Retrieve(number)
+	}
+	return nil, errUnknownTable
+}
+
+// Ancients returns the length of the frozen items.
+func (f *freezer) Ancients() uint64 {
+	return atomic.LoadUint64(&f.frozen)
+}
+
+// TruncateAncients discards any recent data above the provided threshold number.
+func (f *freezer) TruncateAncients(items uint64) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if atomic.LoadUint64(&f.frozen) <= items {
+		return nil
+	}
+	for _, table := range f.tables {
+		if err := table.truncate(items); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, items)
+	return nil
+}
+
+// sync flushes all data tables to disk.
+func (f *freezer) sync() error {
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Sync(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// freeze is a background thread that periodically checks the blockchain for any
+// import progress and moves ancient data from the fast database into the freezer.
+func (f *freezer) freeze(db ethdb.Database) {
+	defer f.wg.Done()
+
+	for {
+		// Retrieve the freezing threshold, waiting a bit if there's nothing to do
+		var limit uint64
+		if hash := GetHeadBlockHash(db); hash != (common.Hash{}) {
+			if number := GetBlockNumber(db, hash); number != missingNumber && number > params.ImmutabilityThreshold {
+				limit = number - params.ImmutabilityThreshold
+			}
+		}
+		if frozen := atomic.LoadUint64(&f.frozen); limit > frozen+freezerBatchLimit {
+			limit = frozen + freezerBatchLimit
+		}
+		if limit <= atomic.LoadUint64(&f.frozen) {
+			select {
+			case <-time.After(freezerRecheckInterval):
+				continue
+			case <-f.quit:
+				return
+			}
+		}
+		if err := f.freezeRange(db, limit); err != nil {
+			log.Error("Failed to freeze ancient chain data", "err", err)
+			select {
+			case <-time.After(freezerRecheckInterval):
+			case <-f.quit:
+				return
+			}
+		}
+		select {
+		case <-f.quit:
+			return
+		default:
+		}
+	}
+}
+
+// freezeRange moves all canonical blocks below limit from the key-value store
+// into the freezer, syncs the flat files and only afterwards wipes the frozen
+// data from the active database. The genesis block is never deleted.
+func (f *freezer) freezeRange(db ethdb.Database, limit uint64) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var (
+		start  = time.Now()
+		first  = atomic.LoadUint64(&f.frozen)
+		hashes []common.Hash
+	)
+	for number := first; number < limit; number++ {
+		// Retrieves all the components of the canonical block
+		hash := GetCanonicalHash(db, number)
+		if hash == (common.Hash{}) {
+			return fmt.Errorf("canonical hash missing, can't freeze block %d", number)
+		}
+		header, _ := db.Get(headerKey(hash, number))
+		if len(header) == 0 {
+			return fmt.Errorf("block header missing, can't freeze block %d", number)
+		}
+		body, _ := db.Get(blockBodyKey(hash, number))
+		if len(body) == 0 {
+			return fmt.Errorf("block body missing, can't freeze block %d", number)
+		}
+		receipts, _ := db.Get(blockReceiptsKey(hash, number))
+		if len(receipts) == 0 {
+			return fmt.Errorf("block receipts missing, can't freeze block %d", number)
+		}
+		td, _ := db.Get(headerTDKey(hash, number))
+		if len(td) == 0 {
+			return fmt.Errorf("total difficulty missing, can't freeze block %d", number)
+		}
+		// Inject all the components into the relevant data tables
+		blobs := map[string][]byte{
+			freezerHashTable:       hash[:],
+			freezerHeaderTable:     header,
+			freezerBodiesTable:     body,
+			freezerReceiptTable:    receipts,
+			freezerDifficultyTable: td,
+		}
+		for _, name := range f.tableNames() {
+			if err := f.tables[name].Append(number, blobs[name]); err != nil {
+				// Roll back the partially appended block, the tables must stay aligned
+				for _, table := range f.tables {
+					table.truncate(number)
+				}
+				return err
+			}
+		}
+		atomic.AddUint64(&f.frozen, 1)
+		hashes = append(hashes, hash)
+	}
+	if len(hashes) == 0 {
+		return nil
+	}
+	// Batch of blocks have been frozen, flush them before wiping from the database
+	if err := f.sync(); err != nil {
+		return err
+	}
+	for i, hash := range hashes {
+		number := first + uint64(i)
+		if number == 0 {
+			continue
+		}
+		DeleteCanonicalHash(db, number)
+		db.Delete(headerKey(hash, number))
+		DeleteBody(db, hash, number)
+		DeleteBlockReceipts(db, hash, number)
+		DeleteTd(db, hash, number)
+	}
+	log.Info("Deep froze chain segment", "blocks", len(hashes), "elapsed", common.PrettyDuration(time.Since(start)),
+		"number", first+uint64(len(hashes))-1, "hash", hashes[len(hashes)-1])
+	return nil
+}
+
+// freezerDatabase is a chain database backed by a key-value store for recent
+// data and an append-only freezer for immutable ancient chain segments.
+type freezerDatabase struct {
+	ethdb.Database
+	*freezer
+}
+
+// NewDatabaseWithFreezer wraps a key-value store with a freezer storing ancient
+// chain data as flat files in the given directory. A background thread moves
+// canonical blocks older than params.ImmutabilityThreshold out of the key-value
+// store, relieving it of compaction work.
+func NewDatabaseWithFreezer(db ethdb.Database, freezer string) (ethdb.Database, error) {
+	frdb, err := newFreezer(freezer)
+	if err != nil {
+		return nil, err
+	}
+	frdb.wg.Add(1)
+	go frdb.freeze(db)
+
+	return &freezerDatabase{Database: db, freezer: frdb}, nil
+}
+
+// Compact flattens the given key range of the key-value store, if it supports
+// compaction. The ancient data files are append-only and need no compaction.
+func (db *freezerDatabase) Compact(start []byte, limit []byte) error {
+	if compacter, ok := db.Database.(ethdb.Compacter); ok {
+		return compacter.Compact(start, limit)
+	}
+	return nil
+}
+
+// Close terminates the background freezer and closes both the key-value store
+// and the ancient data files.
+func (db *freezerDatabase) Close() {
+	close(db.freezer.quit)
+	db.freezer.wg.Wait()
+
+	if err := db.freezer.close(); err != nil {
+		log.Error("Failed to close ancient database", "err", err)
+	}
+	db.Database.Close()
+}
+
+// KeyValueStore returns the key-value store backing a chain database, unwrapping
+// it from the ancient store if one is attached.
+func KeyValueStore(db ethdb.Database) ethdb.Database {
+	if frdb, ok := db.(*freezerDatabase); ok {
+		return frdb.Database
+	}
+	return db
+}
+
+// readAncient retrieves a canonical chain item from the ancient store backing
+// db, if any, provided the frozen block at that height matches the hash.
+func readAncient(db DatabaseReader, kind string, hash common.Hash, number uint64) []byte {
+	if !hasAncient(db, hash, number) {
+		return nil
+	}
+	data, _ := db.(AncientReader).Ancient(kind, number)
+	return data
+}
+
+// hasAncient checks whether the block with the given hash and number has been
+// frozen into the ancient store backing db.
+func hasAncient(db DatabaseReader, hash common.Hash, number uint64) bool {
+	store, ok := db.(AncientReader)
+	if !ok || !store.HasAncient(freezerHashTable, number) {
+		return false
+	}
+	data, _ := store.Ancient(freezerHashTable, number)
+	return common.BytesToHash(data) == hash
+}
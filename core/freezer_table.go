@@ -0,0 +1,303 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/golang/snappy"
+)
+
+var (
+	// errClosed is returned if an operation attempts to read from or write to the
+	// freezer table after it has already been closed.
+	errClosed = errors.New("closed")
+
+	// errOutOfBounds is returned if the item requested is not contained within the
+	// freezer table.
+	errOutOfBounds = errors.New("out of bounds")
+
+	// errOutOrderInsertion is returned if the user attempts to inject out-of-order
+	// binary blobs into the freezer.
+	errOutOrderInsertion = errors.New("the append operation is out-order")
+)
+
+// indexEntrySize is the size of a single entry in a freezer table index file,
+// which is the big endian end offset of the item in the data file.
+const indexEntrySize = 8
+
+// freezerTable is an append-only flat file database of binary blobs. Items are
+// stored back to back in a data file, with an accompanying index file holding
+// the end offset of each item, allowing random access by item number.
+type freezerTable struct {
+	items uint64 // Number of items stored in the table (atomic, needs 64 bit alignment)
+
+	noCompression bool     // if true, disables snappy compression
+	head          *os.File // File descriptor for the data file of the table
+	index         *os.File // File descriptor for the index file of the table
+	headBytes     uint64   // Number of bytes written to the data file
+
+	logger log.Logger   // Logger with database path and table name embedded
+	lock   sync.RWMutex // Mutex protecting the data file descriptors
+}
+
+// newTable opens a freezer table, creating the data and index files if they are
+// non existent. Both files are truncated to the last consistent item, which may
+// be needed after an unclean shutdown.
+func newTable(path string, name string, noCompression bool) (*freezerTable, error) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+	ext := "rdat"
+	if noCompression {
+		ext = "rdat.raw"
+	}
+	index, err := os.OpenFile(filepath.Join(path, name+".ridx"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	head, err := os.OpenFile(filepath.Join(path, fmt.Sprintf("%s.%s", name, ext)), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		index.Close()
+		return nil, err
+	}
+	tab := &freezerTable{
+		noCompression: noCompression,
+		head:          head,
+		index:         index,
+		logger:        log.New("database", path, "table", name),
+	}
+	if err := tab.repair(); err != nil {
+		tab.Close()
+		return nil, err
+	}
+	return tab, nil
+}
+
+// repair cross checks the data and index files and truncates them to be in sync
+// with each other after a potential crash.
+func (t *freezerTable) repair() error {
+	stat, err := t.index.Stat()
+	if err != nil {
+		return err
+	}
+	// Drop any partially written index entry
+	items := uint64(stat.Size()) / indexEntrySize
+	if uint64(stat.Size()) != items*indexEntrySize {
+		t.logger.Warn("Truncating dangling index entry", "size", stat.Size())
+		if err := t.index.Truncate(int64(items * indexEntrySize)); err != nil {
+			return err
+		}
+	}
+	if stat, err = t.head.Stat(); err != nil {
+		return err
+	}
+	size := uint64(stat.Size())
+
+	// Drop any index entries pointing past the data file, then any unindexed data
+	for items > 0 {
+		end, err := t.readOffset(items - 1)
+		if err != nil {
+			return err
+		}
+		if end <= size {
+			break
+		}
+		items--
+	}
+	if err := t.index.Truncate(int64(items * indexEntrySize)); err != nil {
+		return err
+	}
+	end := uint64(0)
+	if items > 0 {
+		if end, err = t.readOffset(items - 1); err != nil {
+			return err
+		}
+	}
+	if end != size {
+		t.logger.Warn("Truncating dangling table data", "indexed", end, "stored", size)
+		if err := t.head.Truncate(int64(end)); err != nil {
+			return err
+		}
+	}
+	t.headBytes = end
+	atomic.StoreUint64(&t.items, items)
+	return nil
+}
+
+// readOffset retrieves the end offset of an item from the index file.
+func (t *freezerTable) readOffset(item uint64) (uint64, error) {
+	buf := make([]byte, indexEntrySize)
+	if _, err := t.index.ReadAt(buf, int64(item*indexEntrySize)); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(buf), nil
+}
+
+// truncate discards any recent data above the provided threshold number.
+func (t *freezerTable) truncate(items uint64) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.index == nil || t.head == nil {
+		return errClosed
+	}
+	if atomic.LoadUint64(&t.items) <= items {
+		return nil
+	}
+	t.logger.Warn("Truncating freezer table", "items", atomic.LoadUint64(&t.items), "limit", items)
+
+	end := uint64(0)
+	if items > 0 {
+		var err error
+		if end, err = t.readOffset(items - 1); err != nil {
+			return err
+		}
+	}
+	if err := t.index.Truncate(int64(items * indexEntrySize)); err != nil {
+		return err
+	}
+	if err := t.head.Truncate(int64(end)); err != nil {
+		return err
+	}
+	t.headBytes = end
+	atomic.StoreUint64(&t.items, items)
+	return nil
+}
+
+// Append injects a binary blob at the end of the freezer table. The item number
+// is a precautionary parameter to ensure data correctness, but the table will
+// reject already existing data.
+func (t *freezerTable) Append(item uint64, blob []byte) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.index == nil || t.head == nil {
+		return errClosed
+	}
+	if atomic.LoadUint64(&t.items) != item {
+		return errOutOrderInsertion
+	}
+	if !t.noCompression {
+		blob = snappy.Encode(nil, blob)
+	}
+	if _, err := t.head.WriteAt(blob, int64(t.headBytes)); err != nil {
+		return err
+	}
+	t.headBytes += uint64(len(blob))
+
+	entry := make([]byte, indexEntrySize)
+	binary.BigEndian.PutUint64(entry, t.headBytes)
+	if _, err := t.index.WriteAt(entry, int64(item*indexEntrySize)); err != nil {
+		return err
+	}
+	atomic.AddUint64(&t.items, 1)
+	return nil
+}
+
+// Retrieve looks up the data offset of an item with the given number and
+// retrieves the raw binary blob from the data file.
+func (t *freezerTable) Retrieve(item uint64) ([]byte, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if t.index == nil || t.head == nil {
+		return nil, errClosed
+	}
+	if atomic.LoadUint64(&t.items) <= item {
+		return nil, errOutOfBounds
+	}
+	start := uint64(0)
+	if item > 0 {
+		var err error
+		if start, err = t.readOffset(item - 1); err != nil {
+			return nil, err
+		}
+	}
+	end, err := t.readOffset(item)
+	if err != nil {
+		return nil, err
+	}
+	blob := make([]byte, end-start)
+	if _, err := t.head.ReadAt(blob, int64(start)); err != nil {
+		return nil, err
+	}
+	if t.noCompression {
+		return blob, nil
+	}
+	return snappy.Decode(nil, blob)
+}
+
+// has returns an indicator whether the specified number data exists in the
+// freezer table.
+func (t *freezerTable) has(number uint64) bool {
+	return atomic.LoadUint64(&t.items) > number
+}
+
+// size returns the total data size in the freezer table.
+func (t *freezerTable) size() uint64 {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.headBytes + atomic.LoadUint64(&t.items)*indexEntrySize
+}
+
+// Sync pushes any pending data from memory out to disk. This is an expensive
+// operation, so use it with care.
+func (t *freezerTable) Sync() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.index == nil || t.head == nil {
+		return errClosed
+	}
+	if err := t.index.Sync(); err != nil {
+		return err
+	}
+	return t.head.Sync()
+}
+
+// Close closes all opened files.
+func (t *freezerTable) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var errs []error
+	if t.index != nil {
+		if err := t.index.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		t.index = nil
+	}
+	if t.head != nil {
+		if err := t.head.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		t.head = nil
+	}
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// getChunk returns a chunk of data of the given size, filled with b.
+func getChunk(size int, b int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(b)
+	}
+	return data
+}
+
+// Tests that items can be appended to and retrieved from a freezer table, both
+// with and without compression, and that they survive a reopen.
+func TestFreezerTableBasics(t *testing.T) {
+	for _, noCompression := range []bool{false, true} {
+		dir, err := ioutil.TempDir("", "freezer")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		table, err := newTable(dir, "test", noCompression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 255; i++ {
+			if err := table.Append(uint64(i), getChunk(15+i, i)); err != nil {
+				t.Fatalf("item %d: failed to append: %v", i, err)
+			}
+		}
+		if err := table.Append(300, getChunk(15, 0)); err != errOutOrderInsertion {
+			t.Fatalf("out of order append error mismatch: have %v, want %v", err, errOutOrderInsertion)
+		}
+		table.Close()
+
+		if table, err = newTable(dir, "test", noCompression); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 255; i++ {
+			blob, err := table.Retrieve(uint64(i))
+			if err != nil {
+				t.Fatalf("item %d: failed to retrieve: %v", i, err)
+			}
+			if want := getChunk(15+i, i); !bytes.Equal(blob, want) {
+				t.Fatalf("item %d: content mismatch: have %x, want %x", i, blob, want)
+			}
+		}
+		if _, err := table.Retrieve(255); err != errOutOfBounds {
+			t.Fatalf("out of bounds error mismatch: have %v, want %v", err, errOutOfBounds)
+		}
+		table.Close()
+	}
+}
+
+// Tests that a table with a partially written item (e.g. after a crash) is
+// repaired on open, dropping the inconsistent tail.
+func TestFreezerTableRepair(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	table, err := newTable(dir, "test", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		table.Append(uint64(i), getChunk(20, i))
+	}
+	table.Close()
+
+	// Chop a few bytes off the data file, invalidating the last item
+	path := filepath.Join(dir, "test.rdat.raw")
+	if err := os.Truncate(path, 10*20-5); err != nil {
+		t.Fatal(err)
+	}
+	// Append some junk to the index file too
+	index, _ := os.OpenFile(filepath.Join(dir, "test.ridx"), os.O_APPEND|os.O_WRONLY, 0644)
+	index.Write([]byte{0x01, 0x02, 0x03})
+	index.Close()
+
+	if table, err = newTable(dir, "test", true); err != nil {
+		t.Fatal(err)
+	}
+	defer table.Close()
+
+	if items := table.items; items != 9 {
+		t.Fatalf("item count mismatch after repair: have %d, want %d", items, 9)
+	}
+	for i := 0; i < 9; i++ {
+		if blob, err := table.Retrieve(uint64(i)); err != nil || !bytes.Equal(blob, getChunk(20, i)) {
+			t.Fatalf("item %d: content mismatch: have %x, err %v", i, blob, err)
+		}
+	}
+	// The repaired table must accept new data in place of the dropped item
+	if err := table.Append(9, getChunk(20, 0xff)); err != nil {
+		t.Fatalf("failed to append after repair: %v", err)
+	}
+	if blob, _ := table.Retrieve(9); !bytes.Equal(blob, getChunk(20, 0xff)) {
+		t.Fatalf("appended item mismatch: have %x", blob)
+	}
+}
+
+// Tests that truncating a table discards all items above the limit.
+func TestFreezerTableTruncate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	table, err := newTable(dir, "test", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer table.Close()
+
+	for i := 0; i < 20; i++ {
+		table.Append(uint64(i), []byte(fmt.Sprintf("item-%d", i)))
+	}
+	if err := table.truncate(5); err != nil {
+		t.Fatalf("failed to truncate: %v", err)
+	}
+	if table.has(5) || !table.has(4) {
+		t.Fatalf("table boundary mismatch after truncation: items %d", table.items)
+	}
+	if err := table.Append(5, []byte("replaced")); err != nil {
+		t.Fatalf("failed to append after truncation: %v", err)
+	}
+	if blob, _ := table.Retrieve(5); string(blob) != "replaced" {
+		t.Fatalf("appended item mismatch: have %s", blob)
+	}
+	if blob, _ := table.Retrieve(4); string(blob) != "item-4" {
+		t.Fatalf("retained item mismatch: have %s", blob)
+	}
+}
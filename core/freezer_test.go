@@ -0,0 +1,138 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that canonical blocks moved into the freezer are wiped from the key-value
+// store, yet remain accessible through the chain database and the blockchain.
+func TestFreezerChainAccess(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	frdb, err := newFreezer(dir)
+	if err != nil {
+		t.Fatalf("failed to create freezer: %v", err)
+	}
+	defer frdb.close()
+
+	kvdb, _ := ethdb.NewMemDatabase()
+	db := &freezerDatabase{Database: kvdb, freezer: frdb}
+
+	gspec := &Genesis{Config: params.TestChainConfig}
+	genesis := gspec.MustCommit(db)
+
+	chain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer chain.Stop()
+
+	gendb, _ := ethdb.NewMemDatabase()
+	gspec.MustCommit(gendb)
+	blocks, _ := GenerateChain(gspec.Config, genesis, gendb, 32, nil)
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	if err := frdb.freezeRange(kvdb, 16); err != nil {
+		t.Fatalf("failed to freeze chain segment: %v", err)
+	}
+	if frozen := db.Ancients(); frozen != 16 {
+		t.Fatalf("frozen count mismatch: have %d, want %d", frozen, 16)
+	}
+	for _, block := range blocks {
+		hash, number := block.Hash(), block.NumberU64()
+
+		// Frozen blocks must be gone from the key-value store, the rest untouched
+		if data, _ := kvdb.Get(headerKey(hash, number)); (len(data) == 0) != (number < 16) {
+			t.Errorf("block #%d: key-value presence mismatch: have %v", number, len(data) != 0)
+		}
+		// Everything must be accessible through the chain database
+		if have := GetCanonicalHash(db, number); have != hash {
+			t.Errorf("block #%d: canonical hash mismatch: have %x, want %x", number, have, hash)
+		}
+		if have := GetBlock(db, hash, number); have == nil || have.Hash() != hash {
+			t.Errorf("block #%d: block mismatch: have %v", number, have)
+		}
+		if GetTd(db, hash, number) == nil {
+			t.Errorf("block #%d: total difficulty missing", number)
+		}
+		if GetBlockReceipts(db, hash, number) == nil {
+			t.Errorf("block #%d: receipts missing", number)
+		}
+		if !chain.HasHeader(hash, number) || !chain.HasBlock(hash, number) {
+			t.Errorf("block #%d: not reported available by the chain", number)
+		}
+	}
+	// Rewinding below the frozen threshold must truncate the freezer
+	chain.SetHead(7)
+	if frozen := db.Ancients(); frozen != 8 {
+		t.Fatalf("frozen count mismatch after rewind: have %d, want %d", frozen, 8)
+	}
+	if hash := GetCanonicalHash(db, 10); hash != (common.Hash{}) {
+		t.Fatalf("rewound block still canonical: %x", hash)
+	}
+	if hash := GetCanonicalHash(db, 7); hash != blocks[6].Hash() {
+		t.Fatalf("retained block hash mismatch: have %x, want %x", hash, blocks[6].Hash())
+	}
+}
+
+// Tests that the freezer aligns all its tables on open, discarding any partially
+// frozen block left behind by a crash.
+func TestFreezerRepair(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	frdb, err := newFreezer(dir)
+	if err != nil {
+		t.Fatalf("failed to create freezer: %v", err)
+	}
+	for i := uint64(0); i < 4; i++ {
+		for _, name := range frdb.tableNames() {
+			frdb.tables[name].Append(i, []byte{byte(i)})
+		}
+	}
+	// Simulate a crash mid-block, with only some tables updated
+	frdb.tables[freezerHashTable].Append(4, []byte{4})
+	frdb.tables[freezerHeaderTable].Append(4, []byte{4})
+	frdb.close()
+
+	if frdb, err = newFreezer(dir); err != nil {
+		t.Fatalf("failed to reopen freezer: %v", err)
+	}
+	defer frdb.close()
+
+	if frozen := frdb.Ancients(); frozen != 4 {
+		t.Fatalf("frozen count mismatch: have %d, want %d", frozen, 4)
+	}
+	if frdb.HasAncient(freezerHashTable, 4) {
+		t.Fatalf("partially frozen block retained")
+	}
+}
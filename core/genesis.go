@@ -30,6 +30,7 @@ import (
 	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
@@ -136,6 +137,45 @@ func (e *GenesisMismatchError) Error() string {
 	return fmt.Sprintf("database already contains an incompatible genesis block (have %x, new %x)", e.Stored[:8], e.New[:8])
 }
 
+// publicNetworks maps the genesis hashes of the public networks to their network
+// identifiers.
+var publicNetworks = map[common.Hash]uint64{
+	params.MainnetGenesisHash: 1,
+	params.TestnetGenesisHash: 3,
+	params.RinkebyGenesisHash: 4,
+}
+
+// NetworkMismatchError is raised when the chain of a public network is run with
+// the network identifier of another one.
+type NetworkMismatchError struct {
+	Genesis            common.Hash
+	Network, Requested uint64
+}
+
+func (e *NetworkMismatchError) Error() string {
+	return fmt.Sprintf("genesis block %x belongs to network %d, but network %d requested", e.Genesis[:8], e.Network, e.Requested)
+}
+
+// CheckNetworkId verifies that the chain in db, or the one SetupGenesisBlock would
+// write into an empty db, isn't a public network's chain run with a different
+// network identifier. It should be called before SetupGenesisBlock, so that a
+// misconfigured first run doesn't initialize the database with the wrong chain.
+func CheckNetworkId(db ethdb.Database, genesis *Genesis, networkId uint64) error {
+	hash := GetCanonicalHash(db, 0)
+	if (hash == common.Hash{}) {
+		if genesis == nil {
+			hash = params.MainnetGenesisHash
+		} else {
+			block, _ := genesis.ToBlock()
+			hash = block.Hash()
+		}
+	}
+	if network, ok := publicNetworks[hash]; ok && network != networkId {
+		return &NetworkMismatchError{hash, network, networkId}
+	}
+	return nil
+}
+
 // SetupGenesisBlock writes or updates the genesis block in db.
 // The block that will be used is:
 //
@@ -150,6 +190,13 @@ func (e *GenesisMismatchError) Error() string {
 //
 // The returned chain configuration is never nil.
 func SetupGenesisBlock(db ethdb.Database, genesis *Genesis) (*params.ChainConfig, common.Hash, error) {
+	return SetupGenesisBlockWithOverride(db, genesis, nil)
+}
+
+// SetupGenesisBlockWithOverride is the same as SetupGenesisBlock, but allows the
+// Byzantium fork block to be rescheduled, mostly for testing fork transitions on
+// existing networks. The override is subject to the usual compatibility checks.
+func SetupGenesisBlockWithOverride(db ethdb.Database, genesis *Genesis, overrideByzantium *big.Int) (*params.ChainConfig, common.Hash, error) {
 	if genesis != nil && genesis.Config == nil {
 		return params.AllEthashProtocolChanges, common.Hash{}, errGenesisNoConfig
 	}
@@ -163,6 +210,14 @@ func SetupGenesisBlock(db ethdb.Database, genesis *Genesis) (*params.ChainConfig
 		} else {
 			log.Info("Writing custom genesis block")
 		}
+		if overrideByzantium != nil {
+			cpy := *genesis
+			cpy.Config = overrideConfig(genesis.Config, overrideByzantium)
+			genesis = &cpy
+		}
+		if err := checkChainConfig(genesis.Config); err != nil {
+			return genesis.Config, common.Hash{}, err
+		}
 		block, err := genesis.Commit(db)
 		return genesis.Config, block.Hash(), err
 	}
@@ -189,9 +244,18 @@ func SetupGenesisBlock(db ethdb.Database, genesis *Genesis) (*params.ChainConfig
 	}
 	// Special case: don't change the existing config of a non-mainnet chain if no new
 	// config is supplied. These chains would get AllProtocolChanges (and a compat error)
-	// if we just continued here.
+	// if we just continued here. Fork overrides are still applied on top though.
 	if genesis == nil && stored != params.MainnetGenesisHash {
-		return storedcfg, stored, nil
+		if overrideByzantium == nil {
+			return storedcfg, stored, vm.CheckPrecompiles(storedcfg)
+		}
+		newcfg = storedcfg
+	}
+	if overrideByzantium != nil {
+		newcfg = overrideConfig(newcfg, overrideByzantium)
+	}
+	if err := checkChainConfig(newcfg); err != nil {
+		return newcfg, stored, err
 	}
 
 	// Check config compatibility and write the config. Compatibility errors
@@ -207,6 +271,30 @@ func SetupGenesisBlock(db ethdb.Database, genesis *Genesis) (*params.ChainConfig
 	return newcfg, stored, WriteChainConfig(db, stored, newcfg)
 }
 
+// checkChainConfig verifies that a chain config is usable: its forks are ordered,
+// all its custom precompiled contracts and interpreters are implemented and it only
+// reprices opcodes with fixed costs.
+func checkChainConfig(config *params.ChainConfig) error {
+	if err := config.CheckConfigForkOrder(); err != nil {
+		return err
+	}
+	if err := vm.CheckPrecompiles(config); err != nil {
+		return err
+	}
+	if err := vm.CheckGasRepricings(config); err != nil {
+		return err
+	}
+	return vm.CheckInterpreters(config)
+}
+
+// overrideConfig returns a copy of the chain config with the Byzantium fork block
+// rescheduled, leaving the (possibly shared, built-in) original untouched.
+func overrideConfig(config *params.ChainConfig, byzantium *big.Int) *params.ChainConfig {
+	cpy := *config
+	cpy.ByzantiumBlock = new(big.Int).Set(byzantium)
+	return &cpy
+}
+
 func (g *Genesis) configOrDefault(ghash common.Hash) *params.ChainConfig {
 	switch {
 	case g != nil:
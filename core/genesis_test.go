@@ -17,6 +17,7 @@
 package core
 
 import (
+	"errors"
 	"math/big"
 	"reflect"
 	"testing"
@@ -38,6 +39,10 @@ func TestDefaultGenesisBlock(t *testing.T) {
 	if block.Hash() != params.TestnetGenesisHash {
 		t.Errorf("wrong testnet genesis hash, got %v, want %v", block.Hash(), params.TestnetGenesisHash)
 	}
+	block, _ = DefaultRinkebyGenesisBlock().ToBlock()
+	if block.Hash() != params.RinkebyGenesisHash {
+		t.Errorf("wrong rinkeby genesis hash, got %v, want %v", block.Hash(), params.RinkebyGenesisHash)
+	}
 }
 
 func TestSetupGenesis(t *testing.T) {
@@ -50,8 +55,10 @@ func TestSetupGenesis(t *testing.T) {
 			},
 		}
 		oldcustomg = customg
+		overridden = *params.MainnetChainConfig
 	)
 	oldcustomg.Config = &params.ChainConfig{HomesteadBlock: big.NewInt(2)}
+	overridden.ByzantiumBlock = big.NewInt(5000000)
 	tests := []struct {
 		name       string
 		fn         func(ethdb.Database) (*params.ChainConfig, common.Hash, error)
@@ -84,6 +91,25 @@ func TestSetupGenesis(t *testing.T) {
 			wantHash:   params.MainnetGenesisHash,
 			wantConfig: params.MainnetChainConfig,
 		},
+		{
+			name: "mainnet block in DB, byzantium override",
+			fn: func(db ethdb.Database) (*params.ChainConfig, common.Hash, error) {
+				DefaultGenesisBlock().MustCommit(db)
+				return SetupGenesisBlockWithOverride(db, nil, big.NewInt(5000000))
+			},
+			wantHash:   params.MainnetGenesisHash,
+			wantConfig: &overridden,
+		},
+		{
+			name: "custom block in DB, misordered byzantium override",
+			fn: func(db ethdb.Database) (*params.ChainConfig, common.Hash, error) {
+				customg.MustCommit(db)
+				return SetupGenesisBlockWithOverride(db, nil, big.NewInt(10))
+			},
+			wantErr:    errors.New("unsupported fork ordering: eip158Block not enabled, but byzantiumBlock enabled at 10"),
+			wantHash:   customghash,
+			wantConfig: &params.ChainConfig{HomesteadBlock: big.NewInt(3), ByzantiumBlock: big.NewInt(10)},
+		},
 		{
 			name: "custom block in DB, genesis == nil",
 			fn: func(db ethdb.Database) (*params.ChainConfig, common.Hash, error) {
@@ -118,7 +144,7 @@ func TestSetupGenesis(t *testing.T) {
 				// Commit the 'old' genesis block with Homestead transition at #2.
 				// Advance to block #4, past the homestead transition block of customg.
 				genesis := oldcustomg.MustCommit(db)
-				bc, _ := NewBlockChain(db, oldcustomg.Config, ethash.NewFullFaker(), vm.Config{})
+				bc, _ := NewBlockChain(db, nil, oldcustomg.Config, ethash.NewFullFaker(), vm.Config{})
 				defer bc.Stop()
 				bc.SetValidator(bproc{})
 				bc.InsertChain(makeBlockChainWithDiff(genesis, []int{2, 3, 4, 5}, 0))
@@ -158,4 +184,44 @@ func TestSetupGenesis(t *testing.T) {
 			}
 		}
 	}
+	// Fork overrides must never leak into the bundled configs
+	if params.MainnetChainConfig.ByzantiumBlock.Cmp(big.NewInt(4370000)) != 0 {
+		t.Errorf("mainnet config modified by override: byzantium block %v", params.MainnetChainConfig.ByzantiumBlock)
+	}
+}
+
+// Tests that the chains of the public networks can't be run with the network
+// identifier of another network, neither from an existing database nor on the
+// first run.
+func TestCheckNetworkId(t *testing.T) {
+	custom := &Genesis{
+		Config: params.AllEthashProtocolChanges,
+		Alloc:  GenesisAlloc{{1}: {Balance: big.NewInt(1)}},
+	}
+	tests := []struct {
+		name      string
+		stored    *Genesis
+		genesis   *Genesis
+		networkId uint64
+		wantErr   bool
+	}{
+		{name: "empty db, mainnet", networkId: 1},
+		{name: "empty db, mainnet with testnet id", networkId: 3, wantErr: true},
+		{name: "empty db, testnet", genesis: DefaultTestnetGenesisBlock(), networkId: 3},
+		{name: "empty db, rinkeby with mainnet id", genesis: DefaultRinkebyGenesisBlock(), networkId: 1, wantErr: true},
+		{name: "empty db, custom", genesis: custom, networkId: 1},
+		{name: "stored testnet", stored: DefaultTestnetGenesisBlock(), networkId: 3},
+		{name: "stored testnet with mainnet id", stored: DefaultTestnetGenesisBlock(), networkId: 1, wantErr: true},
+		{name: "stored custom", stored: custom, networkId: 1234},
+	}
+	for _, test := range tests {
+		db, _ := ethdb.NewMemDatabase()
+		if test.stored != nil {
+			test.stored.MustCommit(db)
+		}
+		err := CheckNetworkId(db, test.genesis, test.networkId)
+		if _, ok := err.(*NetworkMismatchError); ok != test.wantErr || (err != nil && !ok) {
+			t.Errorf("%s: error mismatch: have %v, want mismatch %v", test.name, err, test.wantErr)
+		}
+	}
 }
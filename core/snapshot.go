@@ -0,0 +1,132 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// errSnapshotAborted is returned if the chain is stopped while a state snapshot
+// is being generated.
+var errSnapshotAborted = errors.New("snapshot generation aborted")
+
+// isSnapshotBlock reports whether a state snapshot is taken at the given block.
+func isSnapshotBlock(number uint64) bool {
+	return number > 0 && snapshot.EpochBlock(number) == number
+}
+
+// snapshotState pins the state of a canonical epoch block, so it's not garbage
+// collected, and generates a flat snapshot of it in the background. It must be
+// called with the chain mutex held.
+func (bc *BlockChain) snapshotState(block *types.Block) {
+	root := block.Root()
+	if bc.nodes != nil {
+		bc.nodes.Reference(root)
+	}
+	bc.wg.Add(1)
+	go func() {
+		defer bc.wg.Done()
+		if bc.nodes != nil {
+			defer bc.nodes.Dereference(root)
+		}
+		if err := bc.generateSnapshot(block); err != nil {
+			log.Warn("Failed to generate state snapshot", "number", block.Number(), "hash", block.Hash(), "err", err)
+		}
+	}()
+}
+
+// generateSnapshot splits the state of the given block into chunks and stores
+// them along with the snapshot manifest. Once done, the previous snapshot is
+// deleted, so only the latest one is kept around for serving.
+func (bc *BlockChain) generateSnapshot(block *types.Block) error {
+	bc.snapLock.Lock()
+	defer bc.snapLock.Unlock()
+
+	number, hash := block.NumberU64(), block.Hash()
+
+	prev := snapshot.ReadLatest(bc.chainDb)
+	if prev != nil && prev.Number == number && prev.Block == hash {
+		return nil
+	}
+	var (
+		start   = time.Now()
+		written []common.Hash
+	)
+	manifest, err := snapshot.Generate(bc.stateCache, number, hash, block.Root(), func(hash common.Hash, blob []byte) error {
+		select {
+		case <-bc.quit:
+			return errSnapshotAborted
+		default:
+		}
+		written = append(written, hash)
+		return snapshot.WriteChunk(bc.chainDb, hash, blob)
+	})
+	if err != nil {
+		// Drop the chunks of the partial snapshot, unless shared with the previous one
+		if err := snapshot.DeleteSnapshot(bc.chainDb, &snapshot.Manifest{Number: number, Chunks: written}, prev); err != nil {
+			log.Error("Failed to delete partial state snapshot", "err", err)
+		}
+		return err
+	}
+	if err := snapshot.WriteManifest(bc.chainDb, manifest); err != nil {
+		return err
+	}
+	if prev != nil {
+		if err := snapshot.DeleteSnapshot(bc.chainDb, prev, manifest); err != nil {
+			log.Error("Failed to delete stale state snapshot", "number", prev.Number, "err", err)
+		}
+	}
+	log.Info("Generated state snapshot", "number", number, "hash", hash, "chunks", len(manifest.Chunks), "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// Snapshot retrieves the manifest of the latest state snapshot, or nil if none
+// was generated yet or it's not part of the canonical chain any more.
+func (bc *BlockChain) Snapshot() *snapshot.Manifest {
+	manifest := snapshot.ReadLatest(bc.chainDb)
+	if manifest == nil || GetCanonicalHash(bc.chainDb, manifest.Number) != manifest.Block {
+		return nil
+	}
+	return manifest
+}
+
+// SnapshotChunk retrieves a chunk of a stored state snapshot by its hash.
+func (bc *BlockChain) SnapshotChunk(hash common.Hash) []byte {
+	return snapshot.ReadChunk(bc.chainDb, hash)
+}
+
+// NewSnapshotRestorer creates a restorer rebuilding the state of a snapshot into
+// the chain database. The header of the snapshot block must already be known,
+// and its state root must match the one claimed by the manifest, so the state
+// is authenticated by the header chain once the restoration is finalized.
+func (bc *BlockChain) NewSnapshotRestorer(manifest *snapshot.Manifest) (*snapshot.Restorer, error) {
+	header := bc.GetHeader(manifest.Block, manifest.Number)
+	if header == nil {
+		return nil, fmt.Errorf("unknown snapshot block #%d [%x…]", manifest.Number, manifest.Block.Bytes()[:4])
+	}
+	if header.Root != manifest.Root {
+		return nil, fmt.Errorf("snapshot state root mismatch: have %x, want %x", manifest.Root, header.Root)
+	}
+	return snapshot.NewRestorer(bc.chainDb, manifest)
+}
@@ -0,0 +1,120 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that state snapshots are generated at epoch blocks during import, that
+// only the latest one is kept, and that it can be restored into another node.
+func TestStateSnapshots(t *testing.T) {
+	defer func(epoch uint64) { snapshot.Epoch = epoch }(snapshot.Epoch)
+	snapshot.Epoch = 4
+
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{address: {Balance: big.NewInt(1000000000)}},
+		}
+		signer = types.NewEIP155Signer(gspec.Config.ChainId)
+	)
+	gendb, _ := ethdb.NewMemDatabase()
+	genesis := gspec.MustCommit(gendb)
+	blocks, _ := GenerateChain(gspec.Config, genesis, gendb, 10, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{byte(i)}, big.NewInt(1000), bigTxGas, nil, nil), signer, key)
+		if err != nil {
+			panic(err)
+		}
+		block.AddTx(tx)
+	})
+	// Import the chain with snapshots enabled and wait for the second one
+	db, _ := ethdb.NewMemDatabase()
+	gspec.MustCommit(db)
+
+	config := &CacheConfig{TrieNodeLimit: 256, TrieTimeLimit: 5 * time.Minute, Snapshots: true}
+	chain, _ := NewBlockChain(db, config, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	var manifest *snapshot.Manifest
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		if manifest = chain.Snapshot(); manifest != nil && manifest.Number == 8 {
+			break
+		}
+	}
+	if manifest == nil || manifest.Number != 8 || manifest.Block != blocks[7].Hash() || manifest.Root != blocks[7].Root() {
+		t.Fatalf("snapshot manifest mismatch: have %+v, want block #8 [%x]", manifest, blocks[7].Hash())
+	}
+	if stale := snapshot.ReadManifest(db, 4); stale != nil {
+		t.Errorf("stale snapshot not deleted: %+v", stale)
+	}
+	// Restore the snapshot into a node only having the headers
+	restoredb, _ := ethdb.NewMemDatabase()
+	gspec.MustCommit(restoredb)
+
+	restored, _ := NewBlockChain(restoredb, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer restored.Stop()
+
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	if n, err := restored.InsertHeaderChain(headers, 1); err != nil {
+		t.Fatalf("failed to insert header %d: %v", n, err)
+	}
+	forged := *manifest
+	forged.Root = common.Hash{0x01}
+	if _, err := restored.NewSnapshotRestorer(&forged); err == nil {
+		t.Errorf("snapshot with forged state root accepted")
+	}
+	restorer, err := restored.NewSnapshotRestorer(manifest)
+	if err != nil {
+		t.Fatalf("failed to create snapshot restorer: %v", err)
+	}
+	for _, hash := range manifest.Chunks {
+		if err := restorer.Feed(chain.SnapshotChunk(hash)); err != nil {
+			t.Fatalf("failed to feed chunk %x: %v", hash, err)
+		}
+	}
+	if err := restorer.Finalize(); err != nil {
+		t.Fatalf("failed to finalize snapshot: %v", err)
+	}
+	statedb, err := state.New(manifest.Root, state.NewDatabase(restoredb))
+	if err != nil {
+		t.Fatalf("failed to open restored state: %v", err)
+	}
+	if balance := statedb.GetBalance(common.Address{7}); balance.Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("restored balance mismatch: have %v, want %v", balance, 1000)
+	}
+}
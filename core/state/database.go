@@ -17,11 +17,15 @@
 package state
 
 import (
+	"bytes"
 	"fmt"
 	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/trie"
 	lru "github.com/hashicorp/golang-lru"
 )
@@ -36,6 +40,15 @@ const (
 
 	// Number of codehash->size associations to keep.
 	codeSizeCacheSize = 100000
+
+	// Approximate memory used by a cached contract code, used to convert the
+	// code cache allowance into a number of entries.
+	cachedCodeSize = 4 * 1024
+)
+
+var (
+	codeCacheHitMeter  = metrics.NewMeter("state/cache/code/hit")
+	codeCacheMissMeter = metrics.NewMeter("state/cache/code/miss")
 )
 
 // Database wraps access to tries and contract code.
@@ -66,15 +79,54 @@ type Trie interface {
 // NewDatabase creates a backing store for state. The returned database is safe for
 // concurrent use and retains cached trie nodes in memory.
 func NewDatabase(db ethdb.Database) Database {
+	return NewDatabaseWithCache(db, 0)
+}
+
+// NewDatabaseWithCache creates a backing store for state which additionally keeps
+// up to cache megabytes of contract code in memory. The trie database may be an
+// in-memory node store created with NewNodeStore, allowing stale state to be
+// garbage collected before it reaches the disk.
+func NewDatabaseWithCache(db trie.Database, cache int) Database {
 	csc, _ := lru.New(codeSizeCacheSize)
-	return &cachingDB{db: db, codeSizeCache: csc}
+	cdb := &cachingDB{db: db, codeSizeCache: csc}
+	if limit := cache * 1024 * 1024 / cachedCodeSize; limit > 0 {
+		cdb.codeCache, _ = lru.New(limit)
+	}
+	return cdb
+}
+
+// NewNodeStore creates an in-memory trie node store on top of db which keeps the
+// storage tries and contract code referenced by account trie leaves alive along
+// with the accounts themselves. If preimages is set, the preimages of the hashed
+// account addresses and storage keys are recorded into the preimage table.
+func NewNodeStore(db ethdb.Database, preimages bool) *trie.NodeStore {
+	return trie.NewNodeStore(db, accountReferences, preimages)
+}
+
+// accountReferences decodes an account trie leaf and returns the storage trie
+// root and contract code hash it refers to. Storage trie leaves do not decode
+// as accounts and have no references.
+func accountReferences(leaf []byte) []common.Hash {
+	var account Account
+	if err := rlp.DecodeBytes(leaf, &account); err != nil {
+		return nil
+	}
+	var refs []common.Hash
+	if account.Root != types.EmptyRootHash {
+		refs = append(refs, account.Root)
+	}
+	if !bytes.Equal(account.CodeHash, emptyCodeHash) {
+		refs = append(refs, common.BytesToHash(account.CodeHash))
+	}
+	return refs
 }
 
 type cachingDB struct {
-	db            ethdb.Database
+	db            trie.Database
 	mu            sync.Mutex
 	pastTries     []*trie.SecureTrie
 	codeSizeCache *lru.Cache
+	codeCache     *lru.Cache // Recently accessed contract code (nil = disabled)
 }
 
 func (db *cachingDB) OpenTrie(root common.Hash) (Trie, error) {
@@ -121,9 +173,19 @@ func (db *cachingDB) CopyTrie(t Trie) Trie {
 }
 
 func (db *cachingDB) ContractCode(addrHash, codeHash common.Hash) ([]byte, error) {
+	if db.codeCache != nil {
+		if cached, ok := db.codeCache.Get(codeHash); ok {
+			codeCacheHitMeter.Mark(1)
+			return cached.([]byte), nil
+		}
+		codeCacheMissMeter.Mark(1)
+	}
 	code, err := db.db.Get(codeHash[:])
 	if err == nil {
 		db.codeSizeCache.Add(codeHash, len(code))
+		if db.codeCache != nil {
+			db.codeCache.Add(codeHash, code)
+		}
 	}
 	return code, err
 }
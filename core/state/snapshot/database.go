@@ -0,0 +1,125 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"encoding/binary"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var (
+	latestKey      = []byte("LastSnapshot")       // latestKey -> number of the latest stored snapshot
+	manifestPrefix = []byte("snapshot-manifest-") // manifestPrefix + num (uint64 big endian) -> snapshot manifest
+	chunkPrefix    = []byte("snapshot-chunk-")    // chunkPrefix + hash -> snapshot chunk
+)
+
+// manifestKey = manifestPrefix + num (uint64 big endian)
+func manifestKey(number uint64) []byte {
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, number)
+	return append(append([]byte{}, manifestPrefix...), enc...)
+}
+
+// chunkKey = chunkPrefix + hash
+func chunkKey(hash common.Hash) []byte {
+	return append(append([]byte{}, chunkPrefix...), hash[:]...)
+}
+
+// ReadLatest retrieves the manifest of the most recently stored snapshot, or nil
+// if no snapshot was stored yet.
+func ReadLatest(db ethdb.Database) *Manifest {
+	data, _ := db.Get(latestKey)
+	if len(data) != 8 {
+		return nil
+	}
+	return ReadManifest(db, binary.BigEndian.Uint64(data))
+}
+
+// ReadManifest retrieves the manifest of the snapshot taken at the given block
+// number, or nil if no such snapshot is stored.
+func ReadManifest(db ethdb.Database, number uint64) *Manifest {
+	data, _ := db.Get(manifestKey(number))
+	if len(data) == 0 {
+		return nil
+	}
+	manifest := new(Manifest)
+	if err := rlp.DecodeBytes(data, manifest); err != nil {
+		log.Error("Invalid snapshot manifest RLP", "number", number, "err", err)
+		return nil
+	}
+	return manifest
+}
+
+// WriteManifest stores the manifest of a snapshot and marks it as the latest one.
+// The chunks of the snapshot must already be stored.
+func WriteManifest(db ethdb.Putter, manifest *Manifest) error {
+	data, err := rlp.EncodeToBytes(manifest)
+	if err != nil {
+		return err
+	}
+	if err := db.Put(manifestKey(manifest.Number), data); err != nil {
+		return err
+	}
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, manifest.Number)
+	return db.Put(latestKey, enc)
+}
+
+// ReadChunk retrieves a snapshot chunk by its hash, or nil if it's not stored.
+func ReadChunk(db ethdb.Database, hash common.Hash) []byte {
+	data, _ := db.Get(chunkKey(hash))
+	return data
+}
+
+// WriteChunk stores a snapshot chunk keyed by its hash.
+func WriteChunk(db ethdb.Putter, hash common.Hash, blob []byte) error {
+	return db.Put(chunkKey(hash), blob)
+}
+
+// DeleteSnapshot removes the manifest and the chunks of a snapshot, except for
+// the chunks also referenced by the optional keep manifest (chunks are keyed by
+// content, so identical ones are shared between snapshots).
+func DeleteSnapshot(db ethdb.Database, manifest *Manifest, keep *Manifest) error {
+	if err := DeleteChunks(db, manifest.Chunks, keep); err != nil {
+		return err
+	}
+	return db.Delete(manifestKey(manifest.Number))
+}
+
+// DeleteChunks removes a set of stored chunks, except for the ones referenced by
+// the optional keep manifest.
+func DeleteChunks(db ethdb.Database, hashes []common.Hash, keep *Manifest) error {
+	shared := make(map[common.Hash]struct{})
+	if keep != nil {
+		for _, hash := range keep.Chunks {
+			shared[hash] = struct{}{}
+		}
+	}
+	for _, hash := range hashes {
+		if _, ok := shared[hash]; ok {
+			continue
+		}
+		if err := db.Delete(chunkKey(hash)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
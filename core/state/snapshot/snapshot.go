@@ -0,0 +1,286 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package snapshot implements flat state snapshots to bootstrap the state of a
+// node without downloading the state trie node by node.
+//
+// A snapshot consists of a manifest and a list of chunks. The chunks contain the
+// accounts of the state in flat form (along with their code and storage), while
+// the manifest references every chunk by its hash. Chunks can thus be verified
+// individually against the manifest as they arrive, and the manifest itself is
+// verified by rebuilding the state trie and comparing its root to the one in
+// the header of the snapshot block.
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// Epoch is the number of blocks between two state snapshots.
+var Epoch uint64 = 30000
+
+// ChunkSize is the approximate size of the chunks a snapshot is split into.
+// Accounts are never split across chunks, so a chunk containing an account with
+// a large storage may exceed it.
+var ChunkSize = 4 * 1024 * 1024
+
+var (
+	// errUnknownChunk is returned if a chunk not referenced by the manifest of the
+	// snapshot being restored is delivered.
+	errUnknownChunk = errors.New("unknown snapshot chunk")
+
+	// errDuplicateChunk is returned if a chunk is delivered multiple times.
+	errDuplicateChunk = errors.New("duplicate snapshot chunk")
+
+	// errMissingChunks is returned if a snapshot restoration is finalized before
+	// all the chunks of the manifest were delivered.
+	errMissingChunks = errors.New("missing snapshot chunks")
+
+	// errRootMismatch is returned if the state trie rebuilt from the chunks of a
+	// snapshot does not match the state root of the manifest.
+	errRootMismatch = errors.New("snapshot state root mismatch")
+)
+
+// emptyCode is the known hash of the empty EVM bytecode.
+var emptyCode = crypto.Keccak256Hash(nil)
+
+// EpochBlock returns the number of the last snapshot block at or below the given
+// block number.
+func EpochBlock(number uint64) uint64 {
+	return number - number%Epoch
+}
+
+// Manifest describes a snapshot of the state at a particular block.
+type Manifest struct {
+	Number uint64        // Number of the block the snapshot was taken at
+	Block  common.Hash   // Hash of the block the snapshot was taken at
+	Root   common.Hash   // State root of the block the snapshot was taken at
+	Chunks []common.Hash // Hashes of the chunks containing the state
+}
+
+// Slot is a single storage entry of an account.
+type Slot struct {
+	Key   common.Hash // Hash of the storage key
+	Value []byte      // RLP encoded storage value
+}
+
+// Account is the flat representation of a single account in the state.
+type Account struct {
+	Hash    common.Hash // Hash of the account address
+	Nonce   uint64
+	Balance *big.Int
+	Code    []byte // Contract code of the account (nil for plain accounts)
+	Storage []Slot // Storage entries of the account, sorted by key hash
+}
+
+// Chunk is a batch of consecutive accounts of a snapshot.
+type Chunk struct {
+	Accounts []Account
+}
+
+// Generate iterates over the state identified by the given root and splits it
+// into chunks, passing each one to the provided callback along with its hash.
+// The returned manifest references all of the generated chunks.
+func Generate(db state.Database, number uint64, block common.Hash, root common.Hash, onChunk func(hash common.Hash, blob []byte) error) (*Manifest, error) {
+	tr, err := db.OpenTrie(root)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		manifest = &Manifest{Number: number, Block: block, Root: root}
+		chunk    = new(Chunk)
+		size     int
+	)
+	flush := func() error {
+		blob, err := rlp.EncodeToBytes(chunk)
+		if err != nil {
+			return err
+		}
+		hash := crypto.Keccak256Hash(blob)
+		if err := onChunk(hash, blob); err != nil {
+			return err
+		}
+		manifest.Chunks = append(manifest.Chunks, hash)
+		chunk, size = new(Chunk), 0
+		return nil
+	}
+	it := trie.NewIterator(tr.NodeIterator(nil))
+	for it.Next() {
+		var data state.Account
+		if err := rlp.DecodeBytes(it.Value, &data); err != nil {
+			return nil, fmt.Errorf("invalid account %x: %v", it.Key, err)
+		}
+		account := Account{
+			Hash:    common.BytesToHash(it.Key),
+			Nonce:   data.Nonce,
+			Balance: data.Balance,
+		}
+		size += common.HashLength + 8 + len(data.Balance.Bytes())
+
+		// Gather the contract code and storage of the account
+		if codeHash := common.BytesToHash(data.CodeHash); codeHash != emptyCode {
+			if account.Code, err = db.ContractCode(account.Hash, codeHash); err != nil {
+				return nil, fmt.Errorf("missing code %x of account %x: %v", codeHash, account.Hash, err)
+			}
+			size += len(account.Code)
+		}
+		storage, err := db.OpenStorageTrie(account.Hash, data.Root)
+		if err != nil {
+			return nil, err
+		}
+		sit := trie.NewIterator(storage.NodeIterator(nil))
+		for sit.Next() {
+			account.Storage = append(account.Storage, Slot{Key: common.BytesToHash(sit.Key), Value: common.CopyBytes(sit.Value)})
+			size += common.HashLength + len(sit.Value)
+		}
+		if sit.Err != nil {
+			return nil, sit.Err
+		}
+		chunk.Accounts = append(chunk.Accounts, account)
+
+		// Close the chunk if it grew large enough
+		if size >= ChunkSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if it.Err != nil {
+		return nil, it.Err
+	}
+	if len(chunk.Accounts) > 0 || len(manifest.Chunks) == 0 {
+		if err := flush(); err != nil {
+			return nil, err
+		}
+	}
+	log.Debug("Generated state snapshot", "number", number, "hash", block, "root", root, "chunks", len(manifest.Chunks))
+	return manifest, nil
+}
+
+// Restorer rebuilds the state described by a snapshot manifest from its chunks.
+type Restorer struct {
+	manifest *Manifest
+	db       trie.Database
+
+	pending map[common.Hash]struct{} // Chunks not yet restored
+	trie    *trie.Trie               // Account trie being rebuilt
+}
+
+// NewRestorer creates a restorer to rebuild the state of the given manifest into
+// the database. Chunks may be fed in any order.
+func NewRestorer(db trie.Database, manifest *Manifest) (*Restorer, error) {
+	tr, err := trie.New(common.Hash{}, db)
+	if err != nil {
+		return nil, err
+	}
+	pending := make(map[common.Hash]struct{}, len(manifest.Chunks))
+	for _, hash := range manifest.Chunks {
+		pending[hash] = struct{}{}
+	}
+	return &Restorer{manifest: manifest, db: db, pending: pending, trie: tr}, nil
+}
+
+// Pending returns the number of chunks not yet restored.
+func (r *Restorer) Pending() int {
+	return len(r.pending)
+}
+
+// Feed verifies a chunk against the manifest and inserts its accounts into the
+// state being restored.
+func (r *Restorer) Feed(blob []byte) error {
+	hash := crypto.Keccak256Hash(blob)
+	if _, ok := r.pending[hash]; !ok {
+		for _, known := range r.manifest.Chunks {
+			if known == hash {
+				return errDuplicateChunk
+			}
+		}
+		return errUnknownChunk
+	}
+	var chunk Chunk
+	if err := rlp.DecodeBytes(blob, &chunk); err != nil {
+		return fmt.Errorf("invalid snapshot chunk %x: %v", hash, err)
+	}
+	for _, account := range chunk.Accounts {
+		if err := r.restore(account); err != nil {
+			return err
+		}
+	}
+	delete(r.pending, hash)
+	return nil
+}
+
+// restore writes the code and storage of a single account into the database and
+// inserts the account itself into the account trie.
+func (r *Restorer) restore(account Account) error {
+	codeHash := emptyCode
+	if len(account.Code) > 0 {
+		codeHash = crypto.Keccak256Hash(account.Code)
+		if err := r.db.Put(codeHash[:], account.Code); err != nil {
+			return err
+		}
+	}
+	storage, err := trie.New(common.Hash{}, r.db)
+	if err != nil {
+		return err
+	}
+	for _, slot := range account.Storage {
+		if err := storage.TryUpdate(slot.Key[:], slot.Value); err != nil {
+			return err
+		}
+	}
+	root, err := storage.Commit()
+	if err != nil {
+		return err
+	}
+	balance := account.Balance
+	if balance == nil {
+		balance = new(big.Int)
+	}
+	blob, err := rlp.EncodeToBytes(&state.Account{
+		Nonce:    account.Nonce,
+		Balance:  balance,
+		Root:     root,
+		CodeHash: codeHash[:],
+	})
+	if err != nil {
+		return err
+	}
+	return r.trie.TryUpdate(account.Hash[:], blob)
+}
+
+// Finalize commits the restored account trie into the database, verifying that
+// all chunks were delivered and that the state matches the manifest.
+func (r *Restorer) Finalize() error {
+	if len(r.pending) > 0 {
+		return errMissingChunks
+	}
+	if root := r.trie.Hash(); root != r.manifest.Root {
+		log.Warn("Restored snapshot state mismatch", "number", r.manifest.Number, "have", root, "want", r.manifest.Root)
+		return errRootMismatch
+	}
+	_, err := r.trie.Commit()
+	return err
+}
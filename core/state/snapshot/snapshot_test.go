@@ -0,0 +1,166 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// makeTestState creates a sample state with plain accounts as well as contracts
+// with code and storage, returning its root.
+func makeTestState(db ethdb.Database) (common.Hash, error) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	for i := byte(0); i < 96; i++ {
+		addr := common.BytesToAddress([]byte{i})
+		statedb.AddBalance(addr, big.NewInt(int64(i)+1))
+		statedb.SetNonce(addr, uint64(i))
+		if i%3 == 0 {
+			statedb.SetCode(addr, []byte{i, i, i})
+		}
+		if i%4 == 0 {
+			for j := byte(0); j < i; j++ {
+				statedb.SetState(addr, common.BytesToHash([]byte{i, j}), common.BytesToHash([]byte{j}))
+			}
+		}
+	}
+	return statedb.CommitTo(db, false)
+}
+
+// generateTestSnapshot creates a sample state and generates a snapshot of it,
+// split into multiple chunks.
+func generateTestSnapshot(t *testing.T) (*Manifest, map[common.Hash][]byte) {
+	db, _ := ethdb.NewMemDatabase()
+	root, err := makeTestState(db)
+	if err != nil {
+		t.Fatalf("failed to create test state: %v", err)
+	}
+	defer func(size int) { ChunkSize = size }(ChunkSize)
+	ChunkSize = 1024
+
+	chunks := make(map[common.Hash][]byte)
+	manifest, err := Generate(state.NewDatabase(db), Epoch, common.Hash{0x01}, root, func(hash common.Hash, blob []byte) error {
+		chunks[hash] = blob
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to generate snapshot: %v", err)
+	}
+	if len(manifest.Chunks) < 2 {
+		t.Fatalf("snapshot not chunked: %d chunks", len(manifest.Chunks))
+	}
+	if manifest.Root != root || manifest.Number != Epoch {
+		t.Fatalf("manifest mismatch: have %x/%d, want %x/%d", manifest.Root, manifest.Number, root, Epoch)
+	}
+	return manifest, chunks
+}
+
+// Tests that a state can be snapshotted and restored from its chunks in any order.
+func TestSnapshotRestore(t *testing.T) {
+	manifest, chunks := generateTestSnapshot(t)
+
+	db, _ := ethdb.NewMemDatabase()
+	restorer, err := NewRestorer(db, manifest)
+	if err != nil {
+		t.Fatalf("failed to create restorer: %v", err)
+	}
+	for i := len(manifest.Chunks) - 1; i >= 0; i-- {
+		if err := restorer.Feed(chunks[manifest.Chunks[i]]); err != nil {
+			t.Fatalf("failed to restore chunk %d: %v", i, err)
+		}
+	}
+	if err := restorer.Finalize(); err != nil {
+		t.Fatalf("failed to finalize restoration: %v", err)
+	}
+	// Verify the restored state in full
+	statedb, err := state.New(manifest.Root, state.NewDatabase(db))
+	if err != nil {
+		t.Fatalf("failed to open restored state: %v", err)
+	}
+	for i := byte(0); i < 96; i++ {
+		addr := common.BytesToAddress([]byte{i})
+		if balance := statedb.GetBalance(addr); balance.Cmp(big.NewInt(int64(i)+1)) != 0 {
+			t.Errorf("account %d: balance mismatch: have %v, want %v", i, balance, i+1)
+		}
+		if i%3 == 0 && len(statedb.GetCode(addr)) != 3 {
+			t.Errorf("account %d: code mismatch: have %x", i, statedb.GetCode(addr))
+		}
+		if i%4 == 0 && i > 0 {
+			if value := statedb.GetState(addr, common.BytesToHash([]byte{i, i - 1})); value != common.BytesToHash([]byte{i - 1}) {
+				t.Errorf("account %d: storage mismatch: have %x, want %x", i, value, []byte{i - 1})
+			}
+		}
+	}
+}
+
+// Tests that unknown, duplicate and missing chunks are detected.
+func TestSnapshotRestoreFailures(t *testing.T) {
+	manifest, chunks := generateTestSnapshot(t)
+
+	db, _ := ethdb.NewMemDatabase()
+	restorer, _ := NewRestorer(db, manifest)
+
+	if err := restorer.Feed([]byte{0xc0}); err != errUnknownChunk {
+		t.Fatalf("unknown chunk error mismatch: have %v, want %v", err, errUnknownChunk)
+	}
+	if err := restorer.Feed(chunks[manifest.Chunks[0]]); err != nil {
+		t.Fatalf("failed to restore chunk: %v", err)
+	}
+	if err := restorer.Feed(chunks[manifest.Chunks[0]]); err != errDuplicateChunk {
+		t.Fatalf("duplicate chunk error mismatch: have %v, want %v", err, errDuplicateChunk)
+	}
+	if err := restorer.Finalize(); err != errMissingChunks {
+		t.Fatalf("missing chunk error mismatch: have %v, want %v", err, errMissingChunks)
+	}
+}
+
+// Tests that a snapshot whose chunks don't add up to the announced state root is
+// rejected.
+func TestSnapshotRootMismatch(t *testing.T) {
+	manifest, chunks := generateTestSnapshot(t)
+
+	// Drop the last chunk from the manifest, the rest should not match the root
+	forged := *manifest
+	forged.Chunks = forged.Chunks[:len(forged.Chunks)-1]
+
+	db, _ := ethdb.NewMemDatabase()
+	restorer, _ := NewRestorer(db, &forged)
+	for _, hash := range forged.Chunks {
+		if err := restorer.Feed(chunks[hash]); err != nil {
+			t.Fatalf("failed to restore chunk: %v", err)
+		}
+	}
+	if err := restorer.Finalize(); err != errRootMismatch {
+		t.Fatalf("root mismatch error mismatch: have %v, want %v", err, errRootMismatch)
+	}
+}
+
+// Tests that snapshot blocks are placed at epoch boundaries.
+func TestEpochBlock(t *testing.T) {
+	tests := []struct{ number, epoch uint64 }{
+		{0, 0}, {1, 0}, {Epoch - 1, 0}, {Epoch, Epoch}, {3*Epoch + 7, 3 * Epoch},
+	}
+	for i, tt := range tests {
+		if epoch := EpochBlock(tt.number); epoch != tt.epoch {
+			t.Errorf("test %d: epoch block mismatch for %d: have %d, want %d", i, tt.number, epoch, tt.epoch)
+		}
+	}
+}
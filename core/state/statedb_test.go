@@ -372,3 +372,37 @@ func (s *StateSuite) TestTouchDelete(c *check.C) {
 		c.Fatal("expected no dirty state object")
 	}
 }
+
+// Benchmarks taking snapshots and reverting a few changes in deeply nested calls.
+// Reverting only undoes the journalled changes since the snapshot, so the cost is
+// independent of the size of the state.
+func BenchmarkSnapshotRevert1K(b *testing.B)   { benchmarkSnapshotRevert(b, 1000) }
+func BenchmarkSnapshotRevert100K(b *testing.B) { benchmarkSnapshotRevert(b, 100000) }
+
+func benchmarkSnapshotRevert(b *testing.B, accounts int) {
+	db, _ := ethdb.NewMemDatabase()
+	state, _ := New(common.Hash{}, NewDatabase(db))
+
+	for i := 0; i < accounts; i++ {
+		addr := common.BigToAddress(big.NewInt(int64(i)))
+		state.SetBalance(addr, big.NewInt(int64(i)))
+		state.SetState(addr, common.Hash{}, common.Hash{0x01})
+	}
+	state.Finalise(false)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Simulate 64 nested calls, each changing some state and reverting
+		var snapshots []int
+		for depth := 0; depth < 64; depth++ {
+			snapshots = append(snapshots, state.Snapshot())
+
+			addr := common.BigToAddress(big.NewInt(int64(depth)))
+			state.AddBalance(addr, big.NewInt(1))
+			state.SetState(addr, common.Hash{}, common.Hash{byte(depth)})
+		}
+		for depth := len(snapshots) - 1; depth >= 0; depth-- {
+			state.RevertToSnapshot(snapshots[depth])
+		}
+	}
+}
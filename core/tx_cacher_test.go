@@ -0,0 +1,81 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// countingSigner wraps a signer, counting the number of sender recoveries.
+type countingSigner struct {
+	types.Signer
+	count *int32
+}
+
+func (s countingSigner) Sender(tx *types.Transaction) (common.Address, error) {
+	atomic.AddInt32(s.count, 1)
+	return s.Signer.Sender(tx)
+}
+
+func (s countingSigner) Equal(other types.Signer) bool {
+	o, ok := other.(countingSigner)
+	return ok && o.count == s.count
+}
+
+// Tests that the background sender cacher recovers every transaction of a batch
+// of blocks exactly once, and that the results are cached into the transactions.
+func TestSenderCacherRecoverFromBlocks(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.NewEIP155Signer(big.NewInt(1))
+
+	var blocks []*types.Block
+	for i := 0; i < 10; i++ {
+		var txs []*types.Transaction
+		for j := 0; j < 25; j++ {
+			tx, _ := types.SignTx(types.NewTransaction(uint64(i*25+j), common.Address{}, big.NewInt(0), big.NewInt(21000), big.NewInt(1), nil), signer, key)
+			txs = append(txs, tx)
+		}
+		blocks = append(blocks, types.NewBlock(&types.Header{Number: big.NewInt(int64(i))}, txs, nil, nil))
+	}
+	counter := countingSigner{Signer: signer, count: new(int32)}
+	senderCacher.recoverFromBlocks(counter, blocks)
+
+	for i := 0; i < 300 && atomic.LoadInt32(counter.count) < 250; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if count := atomic.LoadInt32(counter.count); count != 250 {
+		t.Fatalf("recovery count mismatch: have %d, want %d", count, 250)
+	}
+	want := crypto.PubkeyToAddress(key.PublicKey)
+	for _, block := range blocks {
+		for _, tx := range block.Transactions() {
+			if from, err := types.Sender(counter, tx); err != nil || from != want {
+				t.Fatalf("sender mismatch: have %x, want %x, err %v", from, want, err)
+			}
+		}
+	}
+	if count := atomic.LoadInt32(counter.count); count != 250 {
+		t.Fatalf("senders not cached: recovery count %d, want %d", count, 250)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// maintainTxIndex is responsible for the construction and deletion of the
+// transaction index, keeping only the lookup entries of the most recent
+// TxLookupLimit blocks (or all of them if the limit is zero). Whenever the
+// chain head changes, the index tail is moved accordingly in the background.
+func (bc *BlockChain) maintainTxIndex() {
+	defer bc.wg.Done()
+
+	var (
+		done   chan struct{}
+		headCh = make(chan ChainHeadEvent, 1)
+		sub    = bc.chainHeadFeed.Subscribe(headCh)
+	)
+	defer sub.Unsubscribe()
+
+	// Launch the initial processing if the chain is not empty
+	if head := bc.CurrentBlock(); head.NumberU64() > 0 {
+		done = make(chan struct{})
+		go bc.updateTxIndex(head.NumberU64(), done)
+	}
+	for {
+		select {
+		case head := <-headCh:
+			if done == nil {
+				done = make(chan struct{})
+				go bc.updateTxIndex(head.Block.NumberU64(), done)
+			}
+		case <-done:
+			done = nil
+		case <-bc.quit:
+			if done != nil {
+				log.Info("Waiting background transaction indexer to exit")
+				<-done
+			}
+			return
+		}
+	}
+}
+
+// updateTxIndex moves the transaction index tail to match the configured lookup
+// limit relative to the given head, indexing or unindexing the difference.
+func (bc *BlockChain) updateTxIndex(head uint64, done chan struct{}) {
+	defer close(done)
+
+	target := uint64(0)
+	if bc.cacheConfig.TxLookupLimit != 0 && head >= bc.cacheConfig.TxLookupLimit {
+		target = head - bc.cacheConfig.TxLookupLimit + 1
+	}
+	tail := GetTxIndexTail(bc.chainDb)
+	switch {
+	case tail == nil && target == 0:
+		// Everything indexed, nothing to prune
+	case tail == nil:
+		bc.unindexTransactions(0, target)
+	case target > *tail:
+		bc.unindexTransactions(*tail, target)
+	case target < *tail:
+		bc.indexTransactions(target, *tail)
+	}
+}
+
+// indexTransactions creates the lookup entries for the canonical blocks in the
+// [from, to) range, walking backwards from the current tail so an interrupted
+// run can resume where it left off.
+func (bc *BlockChain) indexTransactions(from, to uint64) {
+	var (
+		start   = time.Now()
+		batch   = bc.chainDb.NewBatch()
+		indexed int
+	)
+	for number := to; number > from; number-- {
+		block := bc.GetBlockByNumber(number - 1)
+		if block == nil {
+			log.Error("Canonical block missing, can't index transactions", "number", number-1)
+			break
+		}
+		if err := WriteTxLookupEntries(batch, block); err != nil {
+			log.Error("Failed to index transactions", "number", number-1, "err", err)
+			break
+		}
+		indexed += len(block.Transactions())
+
+		interrupted := bc.txIndexInterrupted()
+		if batch.ValueSize() >= ethdb.IdealBatchSize || number-1 == from || interrupted {
+			WriteTxIndexTail(batch, number-1)
+			if err := batch.Write(); err != nil {
+				log.Error("Failed to write transaction index", "err", err)
+				return
+			}
+			batch = bc.chainDb.NewBatch()
+		}
+		if interrupted {
+			break
+		}
+	}
+	log.Info("Indexed transactions", "blocks", to-from, "txs", indexed, "tail", from, "elapsed", common.PrettyDuration(time.Since(start)))
+}
+
+// unindexTransactions deletes the lookup entries of the canonical blocks in the
+// [from, to) range, moving the index tail forward as it goes.
+func (bc *BlockChain) unindexTransactions(from, to uint64) {
+	var (
+		start     = time.Now()
+		unindexed int
+	)
+	for number := from; number < to; number++ {
+		block := bc.GetBlockByNumber(number)
+		if block == nil {
+			log.Error("Canonical block missing, can't unindex transactions", "number", number)
+			break
+		}
+		for _, tx := range block.Transactions() {
+			DeleteTxLookupEntry(bc.chainDb, tx.Hash())
+		}
+		unindexed += len(block.Transactions())
+
+		interrupted := bc.txIndexInterrupted()
+		if number%1024 == 0 || number+1 == to || interrupted {
+			WriteTxIndexTail(bc.chainDb, number+1)
+		}
+		if interrupted {
+			break
+		}
+	}
+	log.Info("Unindexed transactions", "blocks", to-from, "txs", unindexed, "tail", to, "elapsed", common.PrettyDuration(time.Since(start)))
+}
+
+// txIndexInterrupted checks whether the blockchain is being shut down.
+func (bc *BlockChain) txIndexInterrupted() bool {
+	select {
+	case <-bc.quit:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the transaction index is limited to the configured number of recent
+// blocks, and that it's rebuilt in the background if the limit is lifted.
+func TestTxLookupLimit(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{address: {Balance: big.NewInt(1000000000)}},
+		}
+		signer = types.NewEIP155Signer(gspec.Config.ChainId)
+	)
+	gendb, _ := ethdb.NewMemDatabase()
+	genesis := gspec.MustCommit(gendb)
+	blocks, _ := GenerateChain(gspec.Config, genesis, gendb, 32, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{0x00}, big.NewInt(1000), bigTxGas, nil, nil), signer, key)
+		if err != nil {
+			panic(err)
+		}
+		block.AddTx(tx)
+	})
+	// Import the chain with a limited transaction index
+	db, _ := ethdb.NewMemDatabase()
+	gspec.MustCommit(db)
+
+	config := &CacheConfig{TrieNodeLimit: 256, TrieTimeLimit: 5 * time.Minute, TxLookupLimit: 8}
+	chain, _ := NewBlockChain(db, config, gspec.Config, ethash.NewFaker(), vm.Config{})
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	waitTxIndexTail(t, db, 25)
+	chain.Stop()
+
+	for _, block := range blocks {
+		tx := block.Transactions()[0]
+		if have, _, _, _ := GetTransaction(db, tx.Hash()); (have != nil) != (block.NumberU64() >= 25) {
+			t.Errorf("block #%d: index presence mismatch: have %v", block.NumberU64(), have != nil)
+		}
+	}
+	// Restart the chain without an index limit and ensure everything is reindexed
+	config = &CacheConfig{TrieNodeLimit: 256, TrieTimeLimit: 5 * time.Minute}
+	chain, _ = NewBlockChain(db, config, gspec.Config, ethash.NewFaker(), vm.Config{})
+	waitTxIndexTail(t, db, 0)
+	chain.Stop()
+
+	for _, block := range blocks {
+		tx := block.Transactions()[0]
+		if have, hash, _, _ := GetTransaction(db, tx.Hash()); have == nil || hash != block.Hash() {
+			t.Errorf("block #%d: transaction not reindexed", block.NumberU64())
+		}
+	}
+}
+
+// waitTxIndexTail waits until the background transaction indexer reaches the
+// expected tail, failing the test on timeout.
+func waitTxIndexTail(t *testing.T, db ethdb.Database, want uint64) {
+	for i := 0; i < 100; i++ {
+		if tail := GetTxIndexTail(db); tail != nil && *tail == want {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	tail := GetTxIndexTail(db)
+	t.Fatalf("transaction index tail mismatch: have %v, want %d", tail, want)
+}
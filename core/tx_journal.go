@@ -135,8 +135,15 @@ func (journal *txJournal) rotate(all map[common.Address]types.Transactions) erro
 		}
 		journaled += len(txs)
 	}
-	replacement.Close()
-
+	// Make sure the replacement hits the disk before swapping it in, otherwise a
+	// crash right after the rename could leave an empty journal behind
+	if err = replacement.Sync(); err != nil {
+		replacement.Close()
+		return err
+	}
+	if err = replacement.Close(); err != nil {
+		return err
+	}
 	// Replace the live journal with the newly generated one
 	if err = os.Rename(journal.path+".new", journal.path); err != nil {
 		return err
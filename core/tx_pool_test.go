@@ -922,6 +922,48 @@ func TestTransactionPendingLimiting(t *testing.T) {
 	}
 }
 
+// Tests that if the per-account executable transaction limit is set, remote
+// transactions above it are dropped while local ones are retained.
+func TestTransactionPendingAccountLimiting(t *testing.T) {
+	t.Parallel()
+
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	blockchain := &testBlockChain{statedb, big.NewInt(1000000), new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.AccountPending = 3
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	remote, _ := crypto.GenerateKey()
+	local, _ := crypto.GenerateKey()
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(remote.PublicKey), big.NewInt(1000000))
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(local.PublicKey), big.NewInt(1000000))
+
+	for i := uint64(0); i < config.AccountPending+2; i++ {
+		if err := pool.AddRemote(transaction(i, big.NewInt(100000), remote)); err != nil {
+			t.Fatalf("tx %d: failed to add remote transaction: %v", i, err)
+		}
+		if err := pool.AddLocal(transaction(i, big.NewInt(100000), local)); err != nil {
+			t.Fatalf("tx %d: failed to add local transaction: %v", i, err)
+		}
+	}
+	if have := pool.pending[crypto.PubkeyToAddress(remote.PublicKey)].Len(); have != int(config.AccountPending) {
+		t.Errorf("remote pending size mismatch: have %d, want %d", have, config.AccountPending)
+	}
+	if have := pool.pending[crypto.PubkeyToAddress(local.PublicKey)].Len(); have != int(config.AccountPending+2) {
+		t.Errorf("local pending size mismatch: have %d, want %d", have, config.AccountPending+2)
+	}
+	if queue := pool.queue[crypto.PubkeyToAddress(local.PublicKey)]; queue != nil {
+		t.Errorf("local queue size mismatch: have %d, want %d", queue.Len(), 0)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // Tests that the transaction limits are enforced the same way irrelevant whether
 // the transactions are added one by one or in batches.
 func TestTransactionQueueLimitingEquivalency(t *testing.T)   { testTransactionLimitingEquivalency(t, 1) }
@@ -1264,6 +1306,52 @@ func TestTransactionPoolRepricingKeepsLocals(t *testing.T) {
 	validate()
 }
 
+// Tests that accounts configured as locals have their transactions exempted from
+// the minimum gas price and from price based eviction, even if the transactions
+// arrive from the network.
+func TestTransactionPoolConfiguredLocals(t *testing.T) {
+	t.Parallel()
+
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	blockchain := &testBlockChain{statedb, big.NewInt(1000000), new(event.Feed)}
+
+	local, _ := crypto.GenerateKey()
+	remote, _ := crypto.GenerateKey()
+
+	config := testTxPoolConfig
+	config.Locals = []common.Address{crypto.PubkeyToAddress(local.PublicKey)}
+	config.PriceLimit = 10
+	config.GlobalSlots = 1
+	config.GlobalQueue = 1
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(local.PublicKey), big.NewInt(1000000000))
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(remote.PublicKey), big.NewInt(1000000000))
+
+	// Cheap network transactions are only accepted from the configured accounts
+	if err := pool.AddRemote(pricedTransaction(0, big.NewInt(100000), big.NewInt(1), remote)); err != ErrUnderpriced {
+		t.Fatalf("cheap remote transaction error mismatch: have %v, want %v", err, ErrUnderpriced)
+	}
+	for nonce := uint64(0); nonce < 2; nonce++ {
+		if err := pool.AddRemote(pricedTransaction(nonce, big.NewInt(100000), big.NewInt(1), local)); err != nil {
+			t.Fatalf("failed to add cheap transaction %d of configured local: %v", nonce, err)
+		}
+	}
+	// Better paying network transactions must not evict the configured accounts'
+	if err := pool.AddRemote(pricedTransaction(0, big.NewInt(100000), big.NewInt(100), remote)); err != nil {
+		t.Fatalf("failed to add well priced remote transaction: %v", err)
+	}
+	if pending := pool.pending[crypto.PubkeyToAddress(local.PublicKey)]; pending == nil || pending.Len() != 2 {
+		t.Fatalf("configured local transactions evicted")
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // Tests that when the pool reaches its global transaction limit, underpriced
 // transactions are gradually shifted out for more expensive ones and any gapped
 // pending transactions are moved into te queue.
@@ -1449,6 +1537,43 @@ func TestTransactionReplacement(t *testing.T) {
 	}
 }
 
+// Tests that the replacement price bump is taken from the pool configuration and
+// that re-adding a pooled transaction is reported separately from an underpriced
+// replacement.
+func TestTransactionReplacementCustomBump(t *testing.T) {
+	t.Parallel()
+
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	blockchain := &testBlockChain{statedb, big.NewInt(1000000), new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.PriceBump = 50
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	key, _ := crypto.GenerateKey()
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	original := pricedTransaction(0, big.NewInt(100000), big.NewInt(100), key)
+	if err := pool.AddRemote(original); err != nil {
+		t.Fatalf("failed to add original transaction: %v", err)
+	}
+	if err := pool.AddRemote(original); err != ErrAlreadyKnown {
+		t.Fatalf("duplicate transaction error mismatch: have %v, want %v", err, ErrAlreadyKnown)
+	}
+	if err := pool.AddRemote(pricedTransaction(0, big.NewInt(100000), big.NewInt(149), key)); err != ErrReplaceUnderpriced {
+		t.Fatalf("underpriced replacement error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	if err := pool.AddRemote(pricedTransaction(0, big.NewInt(100000), big.NewInt(150), key)); err != nil {
+		t.Fatalf("failed to replace transaction with configured bump: %v", err)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // Tests that local transactions are journaled to disk, but remote transactions
 // get discarded between restarts.
 func TestTransactionJournaling(t *testing.T)         { testTransactionJournaling(t, false) }
@@ -19,7 +19,9 @@ package vm
 import (
 	"crypto/sha256"
 	"errors"
+	"fmt"
 	"math/big"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
@@ -59,6 +61,70 @@ var PrecompiledContractsByzantium = map[common.Address]PrecompiledContract{
 	common.BytesToAddress([]byte{8}): &bn256Pairing{},
 }
 
+var (
+	customPrecompiles     = make(map[string]PrecompiledContract) // Native contracts activatable by chain configs
+	customPrecompilesLock sync.RWMutex                           // Protects the custom precompiles
+)
+
+// RegisterPrecompile registers a native contract implementation under the given
+// name. Chain configs of private networks may activate it at a custom address
+// from a given block on, without modifying the interpreter. Registering the same
+// name twice panics.
+func RegisterPrecompile(name string, p PrecompiledContract) {
+	customPrecompilesLock.Lock()
+	defer customPrecompilesLock.Unlock()
+
+	if _, ok := customPrecompiles[name]; ok {
+		panic(fmt.Sprintf("precompile %q registered twice", name))
+	}
+	customPrecompiles[name] = p
+}
+
+// CheckPrecompiles verifies that all the custom precompiled contracts activated by
+// a chain config are registered and don't shadow the default ones.
+func CheckPrecompiles(config *params.ChainConfig) error {
+	customPrecompilesLock.RLock()
+	defer customPrecompilesLock.RUnlock()
+
+	for addr, custom := range config.Precompiles {
+		if custom == nil {
+			return fmt.Errorf("precompile %x not configured", addr)
+		}
+		if PrecompiledContractsByzantium[addr] != nil {
+			return fmt.Errorf("precompile %x shadows a default precompiled contract", addr)
+		}
+		if customPrecompiles[custom.Name] == nil {
+			return fmt.Errorf("precompile %x: unknown implementation %q", addr, custom.Name)
+		}
+	}
+	return nil
+}
+
+// activePrecompiles returns the precompiled contracts active at the given block:
+// the default ones of the current release, along with the activated custom ones.
+func activePrecompiles(config *params.ChainConfig, num *big.Int) map[common.Address]PrecompiledContract {
+	precompiles := PrecompiledContractsHomestead
+	if config.IsByzantium(num) {
+		precompiles = PrecompiledContractsByzantium
+	}
+	if len(config.Precompiles) == 0 {
+		return precompiles
+	}
+	active := make(map[common.Address]PrecompiledContract, len(precompiles)+len(config.Precompiles))
+	for addr, p := range precompiles {
+		active[addr] = p
+	}
+	customPrecompilesLock.RLock()
+	defer customPrecompilesLock.RUnlock()
+
+	for addr, custom := range config.Precompiles {
+		if custom != nil && custom.IsActive(num) && customPrecompiles[custom.Name] != nil {
+			active[addr] = customPrecompiles[custom.Name]
+		}
+	}
+	return active
+}
+
 // RunPrecompiledContract runs and evaluates the output of a precompiled contract.
 func RunPrecompiledContract(p PrecompiledContract, input []byte, contract *Contract) (ret []byte, err error) {
 	gas := p.RequiredGas(input)
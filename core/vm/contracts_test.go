@@ -1,11 +1,13 @@
 package vm
 
 import (
+	"bytes"
 	"fmt"
 	"math/big"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
 )
 
 // precompiledTest defines the input/output pairs for precompiled contract tests.
@@ -465,3 +467,53 @@ func BenchmarkPrecompiledBn256Pairing(bench *testing.B) {
 		benchmarkPrecompiled("08", test, bench)
 	}
 }
+
+// echoContract is a custom precompiled contract returning its input.
+type echoContract struct{}
+
+func (echoContract) RequiredGas(input []byte) uint64  { return uint64(len(input)) }
+func (echoContract) Run(input []byte) ([]byte, error) { return input, nil }
+
+func init() {
+	RegisterPrecompile("echo", echoContract{})
+}
+
+// Tests that custom precompiled contracts are only active from their activation
+// block on, and that invalid configurations are rejected.
+func TestCustomPrecompiles(t *testing.T) {
+	addr := common.Address{0x01, 0x00}
+
+	config := *params.TestChainConfig
+	config.Precompiles = map[common.Address]*params.PrecompileConfig{
+		addr: {Name: "echo", Block: big.NewInt(10)},
+	}
+	if err := CheckPrecompiles(&config); err != nil {
+		t.Fatalf("valid config rejected: %v", err)
+	}
+	if p := activePrecompiles(&config, big.NewInt(9)); p[addr] != nil {
+		t.Errorf("precompile active before its activation block")
+	}
+	p := activePrecompiles(&config, big.NewInt(10))
+	if p[addr] == nil {
+		t.Fatalf("precompile inactive at its activation block")
+	}
+	if p[common.BytesToAddress([]byte{1})] == nil {
+		t.Errorf("default precompiles missing")
+	}
+	if out, _ := p[addr].Run([]byte{0x01, 0x02}); !bytes.Equal(out, []byte{0x01, 0x02}) {
+		t.Errorf("precompile output mismatch: have %x, want %x", out, []byte{0x01, 0x02})
+	}
+	// Unknown implementations and shadowed default precompiles are invalid
+	config.Precompiles = map[common.Address]*params.PrecompileConfig{
+		addr: {Name: "unknown", Block: big.NewInt(0)},
+	}
+	if err := CheckPrecompiles(&config); err == nil {
+		t.Errorf("unknown implementation accepted")
+	}
+	config.Precompiles = map[common.Address]*params.PrecompileConfig{
+		common.BytesToAddress([]byte{1}): {Name: "echo", Block: big.NewInt(0)},
+	}
+	if err := CheckPrecompiles(&config); err == nil {
+		t.Errorf("shadowing default precompile accepted")
+	}
+}
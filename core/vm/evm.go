@@ -17,8 +17,10 @@
 package vm
 
 import (
+	"context"
 	"math/big"
 	"sync/atomic"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -37,17 +39,19 @@ type (
 	GetHashFunc func(uint64) common.Hash
 )
 
-// run runs the given contract and takes care of running precompiles with a fallback to the byte code interpreter.
+// run runs the given contract and takes care of running precompiles and alternative
+// interpreters with a fallback to the byte code interpreter.
 func run(evm *EVM, snapshot int, contract *Contract, input []byte) ([]byte, error) {
 	if contract.CodeAddr != nil {
-		precompiles := PrecompiledContractsHomestead
-		if evm.ChainConfig().IsByzantium(evm.BlockNumber) {
-			precompiles = PrecompiledContractsByzantium
-		}
-		if p := precompiles[*contract.CodeAddr]; p != nil {
+		if p := evm.precompiles[*contract.CodeAddr]; p != nil {
 			return RunPrecompiledContract(p, input, contract)
 		}
 	}
+	for _, interpreter := range evm.interpreters {
+		if interpreter.CanRun(contract.Code) {
+			return interpreter.Run(snapshot, contract, input)
+		}
+	}
 	return evm.interpreter.Run(snapshot, contract, input)
 }
 
@@ -95,15 +99,21 @@ type EVM struct {
 	chainConfig *params.ChainConfig
 	// chain rules contains the chain rules for the current epoch
 	chainRules params.Rules
+	// precompiles contains the precompiled contracts active in the current block
+	precompiles map[common.Address]PrecompiledContract
 	// virtual machine configuration options used to initialise the
 	// evm.
 	vmConfig Config
 	// global (to this context) ethereum virtual machine
 	// used throughout the execution of the tx.
 	interpreter *Interpreter
+	// interpreters are the alternative interpreters active in the current block
+	interpreters []CodeInterpreter
 	// abort is used to abort the EVM calling operations
 	// NOTE: must be set atomically
 	abort int32
+	// profileLabels is the stack of goroutine labels of the entered call frames
+	profileLabels []context.Context
 }
 
 // NewEVM retutrns a new EVM . The returned EVM is not thread safe and should
@@ -115,9 +125,11 @@ func NewEVM(ctx Context, statedb StateDB, chainConfig *params.ChainConfig, vmCon
 		vmConfig:    vmConfig,
 		chainConfig: chainConfig,
 		chainRules:  chainConfig.Rules(ctx.BlockNumber),
+		precompiles: activePrecompiles(chainConfig, ctx.BlockNumber),
 	}
 
 	evm.interpreter = NewInterpreter(evm, vmConfig)
+	evm.interpreters = activeInterpreters(evm, vmConfig, ctx.BlockNumber)
 	return evm
 }
 
@@ -135,6 +147,10 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 	if evm.vmConfig.NoRecursion && evm.depth > 0 {
 		return nil, gas, nil
 	}
+	if evm.vmConfig.Debug || evm.vmConfig.Hooks != nil || evm.vmConfig.ProfileLabels {
+		evm.captureBegin(CALL, caller.Address(), addr, input, gas, value)
+		defer func(start time.Time) { evm.captureEnd(ret, gas-leftOverGas, start, err) }(time.Now())
+	}
 
 	// Fail if we're trying to execute above the call depth limit
 	if evm.depth > int(params.CallCreateDepth) {
@@ -150,11 +166,7 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 		snapshot = evm.StateDB.Snapshot()
 	)
 	if !evm.StateDB.Exist(addr) {
-		precompiles := PrecompiledContractsHomestead
-		if evm.ChainConfig().IsByzantium(evm.BlockNumber) {
-			precompiles = PrecompiledContractsByzantium
-		}
-		if precompiles[addr] == nil && evm.ChainConfig().IsEIP158(evm.BlockNumber) && value.Sign() == 0 {
+		if evm.precompiles[addr] == nil && evm.ChainConfig().IsEIP158(evm.BlockNumber) && value.Sign() == 0 {
 			return nil, gas, nil
 		}
 		evm.StateDB.CreateAccount(addr)
@@ -191,6 +203,10 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 	if evm.vmConfig.NoRecursion && evm.depth > 0 {
 		return nil, gas, nil
 	}
+	if evm.vmConfig.Debug || evm.vmConfig.Hooks != nil || evm.vmConfig.ProfileLabels {
+		evm.captureBegin(CALLCODE, caller.Address(), addr, input, gas, value)
+		defer func(start time.Time) { evm.captureEnd(ret, gas-leftOverGas, start, err) }(time.Now())
+	}
 
 	// Fail if we're trying to execute above the call depth limit
 	if evm.depth > int(params.CallCreateDepth) {
@@ -230,6 +246,10 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 	if evm.vmConfig.NoRecursion && evm.depth > 0 {
 		return nil, gas, nil
 	}
+	if evm.vmConfig.Debug || evm.vmConfig.Hooks != nil || evm.vmConfig.ProfileLabels {
+		evm.captureBegin(DELEGATECALL, caller.Address(), addr, input, gas, nil)
+		defer func(start time.Time) { evm.captureEnd(ret, gas-leftOverGas, start, err) }(time.Now())
+	}
 	// Fail if we're trying to execute above the call depth limit
 	if evm.depth > int(params.CallCreateDepth) {
 		return nil, gas, ErrDepth
@@ -262,6 +282,10 @@ func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte
 	if evm.vmConfig.NoRecursion && evm.depth > 0 {
 		return nil, gas, nil
 	}
+	if evm.vmConfig.Debug || evm.vmConfig.Hooks != nil || evm.vmConfig.ProfileLabels {
+		evm.captureBegin(STATICCALL, caller.Address(), addr, input, gas, nil)
+		defer func(start time.Time) { evm.captureEnd(ret, gas-leftOverGas, start, err) }(time.Now())
+	}
 	// Fail if we're trying to execute above the call depth limit
 	if evm.depth > int(params.CallCreateDepth) {
 		return nil, gas, ErrDepth
@@ -313,6 +337,10 @@ func (evm *EVM) Create(caller ContractRef, code []byte, gas uint64, value *big.I
 	evm.StateDB.SetNonce(caller.Address(), nonce+1)
 
 	contractAddr = crypto.CreateAddress(caller.Address(), nonce)
+	if evm.vmConfig.Debug || evm.vmConfig.Hooks != nil || evm.vmConfig.ProfileLabels {
+		evm.captureBegin(CREATE, caller.Address(), contractAddr, code, gas, value)
+		defer func(start time.Time) { evm.captureEnd(ret, gas-leftOverGas, start, err) }(time.Now())
+	}
 	contractHash := evm.StateDB.GetCodeHash(contractAddr)
 	if evm.StateDB.GetNonce(contractAddr) != 0 || (contractHash != (common.Hash{}) && contractHash != emptyCodeHash) {
 		return nil, common.Address{}, 0, ErrContractAddressCollision
@@ -366,6 +394,44 @@ func (evm *EVM) Create(caller ContractRef, code []byte, gas uint64, value *big.I
 	return ret, contractAddr, contract.Gas, err
 }
 
+// captureBegin notifies the hooks and the tracer of a new call frame. For the
+// tracer, the outermost one is reported as the start of the execution, nested ones
+// as entered sub-calls.
+func (evm *EVM) captureBegin(typ OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	if evm.vmConfig.ProfileLabels {
+		evm.pushProfileLabel(to)
+	}
+	if hooks := evm.vmConfig.Hooks; hooks != nil && hooks.OnEnter != nil {
+		hooks.OnEnter(evm.depth, typ, from, to)
+	}
+	if !evm.vmConfig.Debug {
+		return
+	}
+	if evm.depth == 0 {
+		evm.vmConfig.Tracer.CaptureStart(from, to, typ == CREATE, input, gas, value)
+		return
+	}
+	evm.vmConfig.Tracer.CaptureEnter(typ, from, to, input, gas, value)
+}
+
+// captureEnd notifies the tracer and the hooks that the current call frame has
+// been exited.
+func (evm *EVM) captureEnd(output []byte, gasUsed uint64, start time.Time, err error) {
+	if evm.vmConfig.Debug {
+		if evm.depth == 0 {
+			evm.vmConfig.Tracer.CaptureEnd(output, gasUsed, time.Since(start), err)
+		} else {
+			evm.vmConfig.Tracer.CaptureExit(output, gasUsed, err)
+		}
+	}
+	if hooks := evm.vmConfig.Hooks; hooks != nil && hooks.OnExit != nil {
+		hooks.OnExit(evm.depth, gasUsed, err)
+	}
+	if evm.vmConfig.ProfileLabels {
+		evm.popProfileLabel()
+	}
+}
+
 // ChainConfig returns the evmironment's chain configuration
 func (evm *EVM) ChainConfig() *params.ChainConfig { return evm.chainConfig }
 
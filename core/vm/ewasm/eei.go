@@ -0,0 +1,185 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ewasm
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// hostModule is the name of the module the Ethereum environment interface (EEI)
+// functions are imported from.
+const hostModule = "ethereum"
+
+var (
+	errWriteProtection = errors.New("state modification in static call")
+	errTooManyTopics   = errors.New("too many log topics")
+)
+
+// environment is the context of a contract call exposed to the executed module.
+type environment struct {
+	evm      *vm.EVM
+	contract *vm.Contract
+	input    []byte
+	readOnly bool
+}
+
+// hostFunc is a function of the Ethereum environment interface.
+type hostFunc struct {
+	typ funcType
+	fn  func(e *execution, args []uint64) []uint64
+}
+
+// hostFuncs is the supported subset of the Ethereum environment interface,
+// indexed by name. Calls to other contracts and contract creation are not
+// available yet.
+var hostFuncs = map[string]*hostFunc{
+	"useGas": {typ: signature([]byte{typeI64}, nil), fn: func(e *execution, args []uint64) []uint64 {
+		e.charge(args[0])
+		return nil
+	}},
+	"getGasLeft": {typ: signature(nil, []byte{typeI64}), fn: func(e *execution, args []uint64) []uint64 {
+		e.charge(vm.GasQuickStep)
+		return []uint64{e.env.contract.Gas}
+	}},
+	"getAddress": {typ: signature([]byte{typeI32}, nil), fn: func(e *execution, args []uint64) []uint64 {
+		e.charge(vm.GasQuickStep)
+		copy(e.slice(args[0], common.AddressLength), e.env.contract.Address().Bytes())
+		return nil
+	}},
+	"getCaller": {typ: signature([]byte{typeI32}, nil), fn: func(e *execution, args []uint64) []uint64 {
+		e.charge(vm.GasQuickStep)
+		copy(e.slice(args[0], common.AddressLength), e.env.contract.Caller().Bytes())
+		return nil
+	}},
+	"getTxOrigin": {typ: signature([]byte{typeI32}, nil), fn: func(e *execution, args []uint64) []uint64 {
+		e.charge(vm.GasQuickStep)
+		copy(e.slice(args[0], common.AddressLength), e.env.evm.Origin.Bytes())
+		return nil
+	}},
+	"getCallValue": {typ: signature([]byte{typeI32}, nil), fn: func(e *execution, args []uint64) []uint64 {
+		e.charge(vm.GasQuickStep)
+		putUint128(e.slice(args[0], 16), e.env.contract.Value())
+		return nil
+	}},
+	"getCallDataSize": {typ: signature(nil, []byte{typeI32}), fn: func(e *execution, args []uint64) []uint64 {
+		e.charge(vm.GasQuickStep)
+		return []uint64{uint64(len(e.env.input))}
+	}},
+	"callDataCopy": {typ: signature([]byte{typeI32, typeI32, typeI32}, nil), fn: func(e *execution, args []uint64) []uint64 {
+		e.charge(gasCost(vm.GasFastestStep, words(args[2]), params.CopyGas))
+		dst := e.slice(args[0], args[2])
+		for i := range dst {
+			dst[i] = 0
+		}
+		if args[1] < uint64(len(e.env.input)) {
+			copy(dst, e.env.input[args[1]:])
+		}
+		return nil
+	}},
+	"getBlockNumber": {typ: signature(nil, []byte{typeI64}), fn: func(e *execution, args []uint64) []uint64 {
+		e.charge(vm.GasQuickStep)
+		return []uint64{e.env.evm.BlockNumber.Uint64()}
+	}},
+	"storageLoad": {typ: signature([]byte{typeI32, typeI32}, nil), fn: func(e *execution, args []uint64) []uint64 {
+		e.charge(e.env.evm.ChainConfig().GasTable(e.env.evm.BlockNumber).SLoad)
+		key := common.BytesToHash(e.slice(args[0], common.HashLength))
+		value := e.env.evm.StateDB.GetState(e.env.contract.Address(), key)
+		copy(e.slice(args[1], common.HashLength), value[:])
+		return nil
+	}},
+	"storageStore": {typ: signature([]byte{typeI32, typeI32}, nil), fn: func(e *execution, args []uint64) []uint64 {
+		if e.env.readOnly {
+			throw(errWriteProtection)
+		}
+		var (
+			addr  = e.env.contract.Address()
+			key   = common.BytesToHash(e.slice(args[0], common.HashLength))
+			value = common.BytesToHash(e.slice(args[1], common.HashLength))
+			old   = e.env.evm.StateDB.GetState(addr, key)
+		)
+		// Same pricing as SSTORE
+		switch {
+		case common.EmptyHash(old) && !common.EmptyHash(value):
+			e.charge(params.SstoreSetGas)
+		case !common.EmptyHash(old) && common.EmptyHash(value):
+			e.charge(params.SstoreClearGas)
+			e.env.evm.StateDB.AddRefund(new(big.Int).SetUint64(params.SstoreRefundGas))
+		default:
+			e.charge(params.SstoreResetGas)
+		}
+		e.env.evm.StateDB.SetState(addr, key, value)
+		return nil
+	}},
+	"log": {typ: signature([]byte{typeI32, typeI32, typeI32, typeI32, typeI32, typeI32, typeI32}, nil), fn: func(e *execution, args []uint64) []uint64 {
+		if e.env.readOnly {
+			throw(errWriteProtection)
+		}
+		count := args[2]
+		if count > 4 {
+			throw(errTooManyTopics)
+		}
+		e.charge(gasCost(gasCost(params.LogGas, count, params.LogTopicGas), args[1], params.LogDataGas))
+
+		topics := make([]common.Hash, count)
+		for i := range topics {
+			topics[i] = common.BytesToHash(e.slice(args[3+i], common.HashLength))
+		}
+		e.env.evm.StateDB.AddLog(&types.Log{
+			Address:     e.env.contract.Address(),
+			Topics:      topics,
+			Data:        common.CopyBytes(e.slice(args[0], args[1])),
+			BlockNumber: e.env.evm.BlockNumber.Uint64(),
+		})
+		return nil
+	}},
+	"finish": {typ: signature([]byte{typeI32, typeI32}, nil), fn: func(e *execution, args []uint64) []uint64 {
+		panic(halt{data: common.CopyBytes(e.slice(args[0], args[1]))})
+	}},
+	"revert": {typ: signature([]byte{typeI32, typeI32}, nil), fn: func(e *execution, args []uint64) []uint64 {
+		panic(halt{data: common.CopyBytes(e.slice(args[0], args[1])), reverted: true})
+	}},
+}
+
+// signature creates a function type.
+func signature(params, results []byte) funcType {
+	return funcType{params: params, results: results}
+}
+
+// words returns the number of 32 byte words needed to hold the given size.
+func words(size uint64) uint64 {
+	if size%32 == 0 {
+		return size / 32
+	}
+	return size/32 + 1
+}
+
+// putUint128 writes the lowest 128 bits of a value in little endian order.
+func putUint128(dst []byte, value *big.Int) {
+	for i := range dst {
+		dst[i] = 0
+	}
+	be := value.Bytes()
+	for i := 0; i < len(be) && i < len(dst); i++ {
+		dst[i] = be[len(be)-1-i]
+	}
+}
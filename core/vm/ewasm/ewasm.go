@@ -0,0 +1,124 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package ewasm implements an experimental eWASM interpreter, executing contracts
+// written as WebAssembly modules.
+//
+// The interpreter is meant for research networks and only supports a subset of
+// eWASM: modules may use the integer instructions of WebAssembly 1.0 (floating
+// point arithmetic, tables and indirect calls are rejected) and import a subset of
+// the Ethereum environment interface, which doesn't include calls to other
+// contracts or contract creation. Every executed instruction costs one gas, host
+// functions and memory are priced like their EVM counterparts.
+//
+// A contract's module has to export its linear memory as "memory" and a "main"
+// function without parameters and results. The data passed to finish is returned
+// to the caller (or becomes the code of the contract when deploying), revert
+// additionally reverts the state changes of the call.
+package ewasm
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// Name is the name the interpreter is registered under, used by chain configs to
+// schedule its activation.
+const Name = "ewasm"
+
+var (
+	errNoMain   = errors.New("module doesn't export a main function")
+	errNoMemory = errors.New("module doesn't export its memory")
+)
+
+var registerOnce sync.Once
+
+// Register makes the eWASM interpreter available to chain configs under Name.
+// It is safe to call multiple times.
+func Register() {
+	registerOnce.Do(func() {
+		vm.RegisterInterpreter(Name, func(evm *vm.EVM, cfg vm.Config) vm.CodeInterpreter {
+			return &Interpreter{evm: evm}
+		})
+	})
+}
+
+// Interpreter executes eWASM contracts on behalf of an EVM.
+type Interpreter struct {
+	evm *vm.EVM
+}
+
+// CanRun returns whether the code is a WebAssembly module.
+func (in *Interpreter) CanRun(code []byte) bool {
+	return bytes.HasPrefix(code, Magic)
+}
+
+// Run instantiates the contract's module and executes its main function.
+func (in *Interpreter) Run(snapshot int, contract *vm.Contract, input []byte) (ret []byte, err error) {
+	m, err := decodeModule(contract.Code)
+	if err != nil {
+		return nil, err
+	}
+	main, ok := m.exports["main"]
+	if !ok || main.kind != externalFunction || len(m.funcType(main.index).params) != 0 || len(m.funcType(main.index).results) != 0 {
+		return nil, errNoMain
+	}
+	if mem, ok := m.exports["memory"]; !ok || mem.kind != externalMemory {
+		return nil, errNoMemory
+	}
+	e := &execution{
+		module: m,
+		env: &environment{
+			evm:      in.evm,
+			contract: contract,
+			input:    input,
+			readOnly: in.evm.Interpreter().ReadOnly(),
+		},
+		globals: make([]uint64, len(m.globals)),
+	}
+	defer func() {
+		switch r := recover().(type) {
+		case nil:
+		case trap:
+			ret, err = nil, r.err
+		case halt:
+			ret = r.data
+			if r.reverted {
+				err = vm.ErrExecutionReverted
+			}
+		case runtime.Error:
+			// Bugs of the interpreter mustn't let contracts crash the node
+			ret, err = nil, r
+		default:
+			panic(r)
+		}
+	}()
+	// Allocate and initialize the memory and globals, then run the contract
+	e.charge(uint64(m.memoryMin) * pageGas)
+	e.memory = make([]byte, int(m.memoryMin)*pageSize)
+	for _, seg := range m.data {
+		copy(e.memory[seg.offset:], seg.data)
+	}
+	for i, g := range m.globals {
+		e.globals[i] = g.init
+	}
+	e.call(main.index, nil)
+	return nil, nil
+}
@@ -0,0 +1,284 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ewasm
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	testCaller   = common.HexToAddress("0x01")
+	testContract = common.HexToAddress("0xaa")
+)
+
+// leb encodes an unsigned LEB128 integer.
+func leb(v uint32) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		if v >>= 7; v != 0 {
+			out = append(out, b|0x80)
+			continue
+		}
+		return append(out, b)
+	}
+}
+
+// vector prefixes the concatenated items with their count.
+func vector(items ...[]byte) []byte {
+	out := leb(uint32(len(items)))
+	for _, item := range items {
+		out = append(out, item...)
+	}
+	return out
+}
+
+func section(id byte, payload []byte) []byte {
+	return append(append([]byte{id}, leb(uint32(len(payload)))...), payload...)
+}
+
+func name(s string) []byte {
+	return append(leb(uint32(len(s))), s...)
+}
+
+// testModule assembles a module importing the given host functions and exporting
+// a main function with the given i32 locals and body, as well as one page of
+// memory initialized with data.
+func testModule(imports []string, locals uint32, body []byte, data []byte) []byte {
+	var types, imps [][]byte
+	for i, imp := range imports {
+		typ := hostFuncs[imp].typ
+		types = append(types, append(append([]byte{typeFunc}, vector(bytesOf(typ.params)...)...), vector(bytesOf(typ.results)...)...))
+		imps = append(imps, append(append(name(hostModule), name(imp)...), append([]byte{externalFunction}, leb(uint32(i))...)...))
+	}
+	types = append(types, []byte{typeFunc, 0x00, 0x00})
+
+	code := append(vector([]byte{byte(locals), typeI32}), body...)
+	if locals == 0 {
+		code = append(vector(), body...)
+	}
+	module := append(append([]byte{}, Magic...), moduleVersion...)
+	module = append(module, section(sectionType, vector(types...))...)
+	module = append(module, section(sectionImport, vector(imps...))...)
+	module = append(module, section(sectionFunction, vector(leb(uint32(len(imports)))))...)
+	module = append(module, section(sectionMemory, vector([]byte{0x00, 0x01}))...)
+	module = append(module, section(sectionExport, vector(
+		append(name("main"), append([]byte{externalFunction}, leb(uint32(len(imports)))...)...),
+		append(name("memory"), externalMemory, 0x00),
+	))...)
+	module = append(module, section(sectionCode, vector(append(leb(uint32(len(code))), code...)))...)
+	if data != nil {
+		segment := append([]byte{0x00, opI32Const, 0x00, opEnd}, leb(uint32(len(data)))...)
+		module = append(module, section(sectionData, vector(append(segment, data...)))...)
+	}
+	return module
+}
+
+// bytesOf splits value types into single byte items.
+func bytesOf(types []byte) [][]byte {
+	items := make([][]byte, len(types))
+	for i, typ := range types {
+		items[i] = []byte{typ}
+	}
+	return items
+}
+
+// newTestEVM creates an EVM with the eWASM interpreter active and the given code
+// deployed at testContract.
+func newTestEVM(code []byte) (*vm.EVM, *state.StateDB) {
+	Register()
+
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	statedb.SetCode(testContract, code)
+
+	config := *params.TestChainConfig
+	config.Interpreters = map[string]*big.Int{Name: big.NewInt(0)}
+	ctx := vm.Context{
+		CanTransfer: func(vm.StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(vm.StateDB, common.Address, common.Address, *big.Int) {},
+		Origin:      testCaller,
+		BlockNumber: big.NewInt(1),
+	}
+	return vm.NewEVM(ctx, statedb, &config, vm.Config{}), statedb
+}
+
+// Tests that control flow, locals, arithmetic and memory work, and that the data
+// passed to finish is returned.
+func TestExecution(t *testing.T) {
+	// Sum the numbers up to 10 and return the result
+	body := []byte{
+		opLoop, typeEmpty,
+		opLocalGet, 1, opLocalGet, 0, 0x6a /* i32.add */, opLocalSet, 1,
+		opLocalGet, 0, opI32Const, 1, 0x6a /* i32.add */, opLocalTee, 0,
+		opI32Const, 10, 0x4d /* i32.le_u */, opBrIf, 0,
+		opEnd,
+		opI32Const, 0, opLocalGet, 1, opI32Store, 2, 0,
+		opI32Const, 0, opI32Const, 4, opCall, 0,
+		opEnd,
+	}
+	evm, _ := newTestEVM(testModule([]string{"finish"}, 2, body, nil))
+
+	ret, gas, err := evm.Call(vm.AccountRef(testCaller), testContract, nil, 100000, new(big.Int))
+	if err != nil {
+		t.Fatalf("execution failed: %v", err)
+	}
+	if !bytes.Equal(ret, []byte{55, 0, 0, 0}) {
+		t.Errorf("result mismatch: have %x, want 37000000", ret)
+	}
+	if used := 100000 - gas; used <= pageGas || used > pageGas+200 {
+		t.Errorf("gas used mismatch: have %d", used)
+	}
+}
+
+// Tests that the storage can be written by calls, but not by static calls.
+func TestStorage(t *testing.T) {
+	data := make([]byte, 64)
+	data[31], data[63] = 0x01, 0x02
+
+	body := []byte{opI32Const, 0, opI32Const, 32, opCall, 0, opEnd}
+	evm, statedb := newTestEVM(testModule([]string{"storageStore"}, 0, body, data))
+
+	if _, _, err := evm.StaticCall(vm.AccountRef(testCaller), testContract, nil, 100000); err != errWriteProtection {
+		t.Fatalf("static call error mismatch: have %v, want %v", err, errWriteProtection)
+	}
+	if _, gas, err := evm.Call(vm.AccountRef(testCaller), testContract, nil, 100000, new(big.Int)); err != nil {
+		t.Fatalf("execution failed: %v", err)
+	} else if used := 100000 - gas; used < params.SstoreSetGas {
+		t.Errorf("storage write underpriced: %d gas used", used)
+	}
+	if value := statedb.GetState(testContract, common.BytesToHash([]byte{0x01})); value != common.BytesToHash([]byte{0x02}) {
+		t.Errorf("stored value mismatch: have %x", value)
+	}
+}
+
+// Tests that reverting returns the data and the remaining gas, and that traps and
+// running out of gas consume all of it.
+func TestFailures(t *testing.T) {
+	tests := []struct {
+		imports []string
+		body    []byte
+		data    []byte
+		ret     []byte
+		err     error
+	}{
+		// Revert returns its data
+		{
+			imports: []string{"revert"},
+			body:    []byte{opI32Const, 0, opI32Const, 4, opCall, 0, opEnd},
+			data:    []byte("oops"),
+			ret:     []byte("oops"),
+			err:     vm.ErrExecutionReverted,
+		},
+		// Infinite loops run out of gas
+		{body: []byte{opLoop, typeEmpty, opBr, 0, opEnd, opEnd}, err: vm.ErrOutOfGas},
+		// Runtime errors trap
+		{body: []byte{opUnreachable, opEnd}, err: errUnreachable},
+		{body: []byte{opI32Const, 1, opI32Const, 0, 0x6d /* i32.div_s */, opDrop, opEnd}, err: errDivideByZero},
+		{body: []byte{opI32Const, 0x80, 0x80, 0x04, opI32Load, 2, 0, opDrop, opEnd}, err: errMemoryBounds},
+		{
+			imports: []string{"finish"},
+			body:    []byte{opI32Const, 0, opI32Const, 0x81, 0x80, 0x04, opCall, 0, opEnd},
+			err:     errMemoryBounds,
+		},
+		// Wrapping memory regions and gas costs are caught, also with i64 operands
+		{
+			imports: []string{"finish"},
+			body:    []byte{opI64Const, 1, opI64Const, 0x7f, opCall, 0, opEnd},
+			err:     errMemoryBounds,
+		},
+		{
+			imports: []string{"log"},
+			body: []byte{
+				opI32Const, 0, opI32Const, 0x7f, opI32Const, 0, opI32Const, 0,
+				opI32Const, 0, opI32Const, 0, opI32Const, 0, opCall, 0, opEnd,
+			},
+			err: vm.ErrOutOfGas,
+		},
+	}
+	for i, tt := range tests {
+		evm, _ := newTestEVM(testModule(tt.imports, 0, tt.body, tt.data))
+
+		ret, gas, err := evm.Call(vm.AccountRef(testCaller), testContract, nil, 100000, new(big.Int))
+		if err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+			continue
+		}
+		if !bytes.Equal(ret, tt.ret) {
+			t.Errorf("test %d: result mismatch: have %x, want %x", i, ret, tt.ret)
+		}
+		if reverted := err == vm.ErrExecutionReverted; reverted != (gas > 0) {
+			t.Errorf("test %d: %d gas left", i, gas)
+		}
+	}
+}
+
+// Tests that modules outside of the supported subset are rejected.
+func TestInvalidModules(t *testing.T) {
+	valid := testModule(nil, 0, []byte{opEnd}, nil)
+	if _, err := decodeModule(valid); err != nil {
+		t.Fatalf("valid module rejected: %v", err)
+	}
+	tests := map[string][]byte{
+		"magic":        append([]byte("\x00wsm"), valid[4:]...),
+		"version":      append(append([]byte{}, valid[:4]...), append([]byte{0x02, 0x00, 0x00, 0x00}, valid[8:]...)...),
+		"truncated":    valid[:len(valid)-1],
+		"float":        testModule(nil, 0, []byte{0x43, 0, 0, 0, 0, opDrop, opEnd}, nil),
+		"unknown op":   testModule(nil, 0, []byte{0xff, opEnd}, nil),
+		"branch depth": testModule(nil, 0, []byte{opBr, 1, opEnd}, nil),
+		"local":        testModule(nil, 1, []byte{opLocalGet, 1, opDrop, opEnd}, nil),
+		"unterminated": testModule(nil, 0, []byte{opBlock, typeEmpty, opEnd}, nil),
+		"trailing":     testModule(nil, 0, []byte{opEnd, opNop}, nil),
+		"table":        append(append([]byte{}, valid...), section(sectionTable, vector([]byte{0x70, 0x00, 0x00}))...),
+	}
+	for name, code := range tests {
+		if _, err := decodeModule(code); err == nil {
+			t.Errorf("%s: invalid module accepted", name)
+		}
+	}
+	// Imports must be known host functions with the right signature
+	module := testModule([]string{"finish"}, 0, []byte{opEnd}, nil)
+	if _, err := decodeModule(bytes.Replace(module, []byte("finish"), []byte("fnord!"), 1)); err == nil {
+		t.Errorf("unknown import accepted")
+	}
+	if _, err := decodeModule(bytes.Replace(module, []byte("ethereum"), []byte("ethernet"), 1)); err == nil {
+		t.Errorf("import from unknown module accepted")
+	}
+}
+
+// Tests that EVM bytecode is still executed by the EVM with the interpreter
+// active.
+func TestEVMFallback(t *testing.T) {
+	// PUSH1 0x2a PUSH1 0 MSTORE8 PUSH1 1 PUSH1 0 RETURN
+	evm, _ := newTestEVM(common.Hex2Bytes("602a60005360016000f3"))
+
+	ret, _, err := evm.Call(vm.AccountRef(testCaller), testContract, nil, 100000, new(big.Int))
+	if err != nil {
+		t.Fatalf("execution failed: %v", err)
+	}
+	if !bytes.Equal(ret, []byte{0x2a}) {
+		t.Errorf("result mismatch: have %x, want 2a", ret)
+	}
+}
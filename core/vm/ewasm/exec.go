@@ -0,0 +1,849 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ewasm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// Supported WebAssembly instructions: the MVP instruction set without floating
+// point arithmetic and indirect calls.
+const (
+	opUnreachable = 0x00
+	opNop         = 0x01
+	opBlock       = 0x02
+	opLoop        = 0x03
+	opIf          = 0x04
+	opElse        = 0x05
+	opEnd         = 0x0b
+	opBr          = 0x0c
+	opBrIf        = 0x0d
+	opBrTable     = 0x0e
+	opReturn      = 0x0f
+	opCall        = 0x10
+	opDrop        = 0x1a
+	opSelect      = 0x1b
+	opLocalGet    = 0x20
+	opLocalSet    = 0x21
+	opLocalTee    = 0x22
+	opGlobalGet   = 0x23
+	opGlobalSet   = 0x24
+	opI32Load     = 0x28
+	opI64Load     = 0x29
+	opI32Load8S   = 0x2c
+	opI64Load32U  = 0x35
+	opI32Store    = 0x36
+	opI64Store    = 0x37
+	opI32Store8   = 0x3a
+	opI64Store32  = 0x3e
+	opMemorySize  = 0x3f
+	opMemoryGrow  = 0x40
+	opI32Const    = 0x41
+	opI64Const    = 0x42
+	opI32Eqz      = 0x45
+	opI64Eqz      = 0x50
+	opI64GeU      = 0x5a
+	opI32Clz      = 0x67
+	opI64Rotr     = 0x8a
+	opI32WrapI64  = 0xa7
+	opI64ExtendS  = 0xac
+	opI64ExtendU  = 0xad
+)
+
+const (
+	instructionGas = 1                 // Gas charged for every executed instruction
+	pageGas        = pageSize / 32 * 3 // Gas charged for every allocated memory page (EVM memory word cost)
+	maxCallDepth   = 256               // Maximum depth of nested function calls
+	maxStack       = 16384             // Maximum number of values on the stack of a function
+	maxBrTable     = 1024              // Maximum number of targets of a branch table
+	wordMask32     = uint64(1<<32 - 1) // Mask of the bits of a 32 bit value
+	minInt32       = int32(-1 << 31)   // Smallest 32 bit integer, overflowing when divided by -1
+	minInt64       = int64(-1 << 63)   // Smallest 64 bit integer, overflowing when divided by -1
+)
+
+// trap is a runtime error aborting the execution of a module.
+type trap struct{ err error }
+
+// halt stops the execution of a module by the finish and revert host functions.
+type halt struct {
+	data     []byte
+	reverted bool
+}
+
+var (
+	errUnreachable    = errors.New("unreachable executed")
+	errStackOverflow  = errors.New("stack overflow")
+	errStackUnderflow = errors.New("stack underflow")
+	errCallDepth      = errors.New("call depth exceeded")
+	errMemoryBounds   = errors.New("out of bounds memory access")
+	errDivideByZero   = errors.New("integer divide by zero")
+	errDivideOverflow = errors.New("integer overflow")
+)
+
+// throw aborts the execution with the given error.
+func throw(err error) {
+	panic(trap{err})
+}
+
+// analyse validates the body of a function and locates the ends and elses of its
+// blocks. Branch targets, indices and immediates are checked, the types of the
+// operands are checked at runtime.
+func (m *module) analyse(fn *function) error {
+	var (
+		code   = fn.code
+		r      = &reader{buf: code}
+		blocks = []int{-1} // Positions of the open blocks, -1 being the function body
+		locals = uint32(len(m.types[fn.typ].params) + len(fn.locals))
+		funcs  = uint32(len(m.imports) + len(m.funcs))
+	)
+	fn.ends, fn.elses = make(map[int]int), make(map[int]int)
+
+	for r.pos < len(code) && r.err == nil {
+		pos := r.pos
+		op := r.byte()
+		switch {
+		case op == opBlock || op == opLoop || op == opIf:
+			if typ := r.byte(); typ != typeEmpty && typ != typeI32 && typ != typeI64 {
+				return fmt.Errorf("invalid block type 0x%x at %d", typ, pos)
+			}
+			blocks = append(blocks, pos)
+
+		case op == opElse:
+			start := blocks[len(blocks)-1]
+			if start < 0 || code[start] != opIf {
+				return fmt.Errorf("else without if at %d", pos)
+			}
+			fn.elses[start] = pos
+			blocks[len(blocks)-1] = pos
+
+		case op == opEnd:
+			start := blocks[len(blocks)-1]
+			blocks = blocks[:len(blocks)-1]
+			if start < 0 {
+				if pos != len(code)-1 {
+					return fmt.Errorf("code after function end at %d", pos)
+				}
+				return nil
+			}
+			fn.ends[start] = pos
+			if code[start] == opElse {
+				// Branches out of the if are resolved by the if's position
+				for ifPos, elsePos := range fn.elses {
+					if elsePos == start {
+						fn.ends[ifPos] = pos
+					}
+				}
+			}
+
+		case op == opBr || op == opBrIf:
+			if depth := r.u32(); r.err == nil && depth >= uint32(len(blocks)) {
+				return fmt.Errorf("invalid branch depth %d at %d", depth, pos)
+			}
+
+		case op == opBrTable:
+			count := r.u32()
+			if count > maxBrTable {
+				return fmt.Errorf("branch table too large at %d", pos)
+			}
+			for i := uint32(0); i <= count && r.err == nil; i++ {
+				if depth := r.u32(); r.err == nil && depth >= uint32(len(blocks)) {
+					return fmt.Errorf("invalid branch depth %d at %d", depth, pos)
+				}
+			}
+
+		case op == opCall:
+			if index := r.u32(); r.err == nil && index >= funcs {
+				return fmt.Errorf("call to unknown function %d at %d", index, pos)
+			}
+
+		case op >= opLocalGet && op <= opLocalTee:
+			if index := r.u32(); r.err == nil && index >= locals {
+				return fmt.Errorf("unknown local %d at %d", index, pos)
+			}
+
+		case op == opGlobalGet || op == opGlobalSet:
+			index := r.u32()
+			if r.err == nil && index >= uint32(len(m.globals)) {
+				return fmt.Errorf("unknown global %d at %d", index, pos)
+			}
+			if r.err == nil && op == opGlobalSet && !m.globals[index].mutable {
+				return fmt.Errorf("immutable global %d set at %d", index, pos)
+			}
+
+		case isMemoryAccess(op):
+			if !m.memory {
+				return fmt.Errorf("memory access without memory at %d", pos)
+			}
+			r.u32() // alignment hint
+			r.u32() // offset
+
+		case op == opMemorySize || op == opMemoryGrow:
+			if !m.memory {
+				return fmt.Errorf("memory access without memory at %d", pos)
+			}
+			if index := r.byte(); r.err == nil && index != 0 {
+				return fmt.Errorf("unknown memory %d at %d", index, pos)
+			}
+
+		case op == opI32Const:
+			r.s64(32)
+
+		case op == opI64Const:
+			r.s64(64)
+
+		case op == opUnreachable, op == opNop, op == opReturn, op == opDrop, op == opSelect,
+			op >= opI32Eqz && op <= opI64GeU, op >= opI32Clz && op <= opI64Rotr,
+			op == opI32WrapI64, op == opI64ExtendS, op == opI64ExtendU:
+			// No immediates
+
+		default:
+			return fmt.Errorf("instruction 0x%x not supported at %d", op, pos)
+		}
+	}
+	if r.err != nil {
+		return r.err
+	}
+	return errors.New("missing function end")
+}
+
+// isMemoryAccess returns whether the instruction is a supported load or store.
+func isMemoryAccess(op byte) bool {
+	switch {
+	case op == opI32Load, op == opI64Load:
+		return true
+	case op >= opI32Load8S && op <= opI64Load32U:
+		return true
+	case op == opI32Store, op == opI64Store:
+		return true
+	case op >= opI32Store8 && op <= opI64Store32:
+		return true
+	}
+	return false
+}
+
+// label is an entered block, loop or if.
+type label struct {
+	height int  // Height of the value stack when the block was entered
+	arity  int  // Number of values the block yields
+	loop   bool // Whether branches restart the block instead of leaving it
+	start  int  // Position of the first instruction of a loop's body
+	end    int  // Position of the end instruction of the block
+}
+
+// frame is the execution state of a function invocation.
+type frame struct {
+	code   []byte
+	pc     int
+	stack  []uint64
+	locals []uint64
+	labels []label
+}
+
+func (f *frame) push(v uint64) {
+	if len(f.stack) >= maxStack {
+		throw(errStackOverflow)
+	}
+	f.stack = append(f.stack, v)
+}
+
+func (f *frame) pop() uint64 {
+	if len(f.stack) == 0 || len(f.stack) <= f.labels[len(f.labels)-1].height {
+		throw(errStackUnderflow)
+	}
+	v := f.stack[len(f.stack)-1]
+	f.stack = f.stack[:len(f.stack)-1]
+	return v
+}
+
+func (f *frame) pop32() uint32 {
+	return uint32(f.pop())
+}
+
+// u32 reads an unsigned immediate, which was validated on decoding.
+func (f *frame) u32() uint32 {
+	var result uint32
+	for shift := uint(0); ; shift += 7 {
+		b := f.code[f.pc]
+		f.pc++
+		result |= uint32(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return result
+		}
+	}
+}
+
+// s64 reads a signed immediate, which was validated on decoding.
+func (f *frame) s64() int64 {
+	var (
+		result int64
+		shift  uint
+		b      byte
+	)
+	for {
+		b = f.code[f.pc]
+		f.pc++
+		result |= int64(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	if shift < 64 && b&0x40 != 0 {
+		result |= -1 << shift
+	}
+	return result
+}
+
+// enter pushes the label of the block starting at the current instruction.
+func (f *frame) enter(fn *function, pos int, loop bool) {
+	arity := 0
+	if f.code[f.pc] != typeEmpty {
+		arity = 1
+	}
+	f.pc++
+	f.labels = append(f.labels, label{height: len(f.stack), arity: arity, loop: loop, start: f.pc, end: fn.ends[pos]})
+}
+
+// branch transfers the control to the label at the given depth, unwinding the
+// value stack to the values it yields.
+func (f *frame) branch(depth uint32) {
+	target := f.labels[len(f.labels)-1-int(depth)]
+	arity := target.arity
+	if target.loop {
+		arity = 0
+	}
+	if len(f.stack)-target.height < arity {
+		throw(errStackUnderflow)
+	}
+	copy(f.stack[target.height:], f.stack[len(f.stack)-arity:])
+	f.stack = f.stack[:target.height+arity]
+
+	if target.loop {
+		f.labels = f.labels[:len(f.labels)-int(depth)]
+		f.pc = target.start
+	} else {
+		// Jump to the end of the target block, which leaves it
+		f.labels = f.labels[:len(f.labels)-int(depth)]
+		f.pc = target.end
+	}
+}
+
+// execution is an instantiated module executing a contract call.
+type execution struct {
+	module  *module
+	env     *environment
+	memory  []byte
+	globals []uint64
+	depth   int
+}
+
+// charge consumes gas of the contract, aborting the execution if it runs out.
+func (e *execution) charge(gas uint64) {
+	if !e.env.contract.UseGas(gas) {
+		throw(vm.ErrOutOfGas)
+	}
+}
+
+// slice returns the memory region of the given size at the given address.
+func (e *execution) slice(addr uint64, size uint64) []byte {
+	if size > uint64(len(e.memory)) || addr > uint64(len(e.memory))-size {
+		throw(errMemoryBounds)
+	}
+	return e.memory[addr : addr+size]
+}
+
+// truncate cuts the values of type i32 down to 32 bits.
+func truncate(types []byte, values []uint64) []uint64 {
+	for i, typ := range types {
+		if typ == typeI32 && i < len(values) {
+			values[i] &= wordMask32
+		}
+	}
+	return values
+}
+
+// gasCost returns the cost of an operation consisting of a base cost and a cost
+// per unit, aborting the execution if it overflows.
+func gasCost(base, units, unitGas uint64) uint64 {
+	gas, overflow := math.SafeMul(units, unitGas)
+	if !overflow {
+		gas, overflow = math.SafeAdd(gas, base)
+	}
+	if overflow {
+		throw(vm.ErrOutOfGas)
+	}
+	return gas
+}
+
+// call invokes the function with the given index.
+func (e *execution) call(index uint32, args []uint64) []uint64 {
+	if index < uint32(len(e.module.imports)) {
+		return e.module.imports[index].fn(e, args)
+	}
+	if e.depth >= maxCallDepth {
+		throw(errCallDepth)
+	}
+	e.depth++
+	defer func() { e.depth-- }()
+
+	var (
+		fn  = &e.module.funcs[index-uint32(len(e.module.imports))]
+		typ = &e.module.types[fn.typ]
+		f   = &frame{code: fn.code, locals: make([]uint64, len(typ.params)+len(fn.locals))}
+	)
+	copy(f.locals, args)
+	f.labels = []label{{arity: len(typ.results), end: len(fn.code) - 1}}
+
+	for {
+		e.charge(instructionGas)
+
+		pos, op := f.pc, f.code[f.pc]
+		f.pc++
+
+		switch op {
+		case opUnreachable:
+			throw(errUnreachable)
+
+		case opNop:
+
+		case opBlock:
+			f.enter(fn, pos, false)
+
+		case opLoop:
+			f.enter(fn, pos, true)
+
+		case opIf:
+			cond := f.pop32()
+			f.enter(fn, pos, false)
+			if cond == 0 {
+				if elsePos, ok := fn.elses[pos]; ok {
+					f.pc = elsePos + 1
+				} else {
+					f.pc = fn.ends[pos]
+				}
+			}
+
+		case opElse:
+			// End of the taken branch of an if, skip the other one
+			f.pc = fn.ends[pos]
+
+		case opEnd:
+			top := f.labels[len(f.labels)-1]
+			if len(f.stack)-top.height != top.arity {
+				throw(fmt.Errorf("block yields %d values, want %d", len(f.stack)-top.height, top.arity))
+			}
+			f.labels = f.labels[:len(f.labels)-1]
+			if len(f.labels) == 0 {
+				return f.stack
+			}
+
+		case opBr:
+			f.branch(f.u32())
+
+		case opBrIf:
+			depth := f.u32()
+			if f.pop32() != 0 {
+				f.branch(depth)
+			}
+
+		case opBrTable:
+			count := f.u32()
+			targets := make([]uint32, count+1)
+			for i := range targets {
+				targets[i] = f.u32()
+			}
+			index := f.pop32()
+			if index > count {
+				index = count
+			}
+			f.branch(targets[index])
+
+		case opReturn:
+			f.branch(uint32(len(f.labels) - 1))
+			f.labels = f.labels[:0]
+			return f.stack
+
+		case opCall:
+			callee := f.u32()
+			ctyp := e.module.funcType(callee)
+			args := make([]uint64, len(ctyp.params))
+			for i := len(args) - 1; i >= 0; i-- {
+				args[i] = f.pop()
+			}
+			// Operand types aren't validated, truncate the i32 values passed around
+			results := e.call(callee, truncate(ctyp.params, args))
+			if len(results) != len(ctyp.results) {
+				throw(errStackUnderflow)
+			}
+			for _, result := range truncate(ctyp.results, results) {
+				f.push(result)
+			}
+
+		case opDrop:
+			f.pop()
+
+		case opSelect:
+			cond, b, a := f.pop32(), f.pop(), f.pop()
+			if cond != 0 {
+				f.push(a)
+			} else {
+				f.push(b)
+			}
+
+		case opLocalGet:
+			f.push(f.locals[f.u32()])
+
+		case opLocalSet:
+			index := f.u32()
+			f.locals[index] = f.pop()
+
+		case opLocalTee:
+			index := f.u32()
+			v := f.pop()
+			f.locals[index] = v
+			f.push(v)
+
+		case opGlobalGet:
+			f.push(e.globals[f.u32()])
+
+		case opGlobalSet:
+			index := f.u32()
+			e.globals[index] = f.pop()
+
+		case opMemorySize:
+			f.pc++
+			f.push(uint64(len(e.memory) / pageSize))
+
+		case opMemoryGrow:
+			f.pc++
+			f.push(e.grow(f.pop32()))
+
+		case opI32Const:
+			f.push(uint64(uint32(f.s64())))
+
+		case opI64Const:
+			f.push(uint64(f.s64()))
+
+		case opI32WrapI64:
+			f.push(f.pop() & wordMask32)
+
+		case opI64ExtendS:
+			f.push(uint64(int64(int32(f.pop32()))))
+
+		case opI64ExtendU:
+			f.push(uint64(f.pop32()))
+
+		default:
+			switch {
+			case isMemoryAccess(op):
+				f.u32() // alignment hint
+				offset := f.u32()
+				e.access(f, op, offset)
+
+			case op >= opI32Eqz && op <= opI64GeU:
+				compare(f, op)
+
+			case op >= opI32Clz && op <= opI64Rotr:
+				arithmetic(f, op)
+
+			default:
+				throw(fmt.Errorf("instruction 0x%x not supported", op))
+			}
+		}
+	}
+}
+
+// grow extends the linear memory by the given number of pages, returning the old
+// size in pages or -1 if the memory can't be grown.
+func (e *execution) grow(pages uint32) uint64 {
+	old := uint32(len(e.memory) / pageSize)
+	if uint64(old)+uint64(pages) > uint64(e.module.memoryMax) {
+		return uint64(uint32(0xffffffff))
+	}
+	e.charge(uint64(pages) * pageGas)
+	e.memory = append(e.memory, make([]byte, int(pages)*pageSize)...)
+	return uint64(old)
+}
+
+// access executes a load or store instruction.
+func (e *execution) access(f *frame, op byte, offset uint32) {
+	var value uint64
+	store := op >= opI32Store
+	if store {
+		value = f.pop()
+	}
+	addr := uint64(f.pop32()) + uint64(offset)
+
+	switch op {
+	case opI32Load:
+		f.push(uint64(binary.LittleEndian.Uint32(e.slice(addr, 4))))
+	case opI64Load:
+		f.push(binary.LittleEndian.Uint64(e.slice(addr, 8)))
+	case opI32Load8S:
+		f.push(uint64(uint32(int32(int8(e.slice(addr, 1)[0])))))
+	case opI32Load8S + 1: // i32.load8_u
+		f.push(uint64(e.slice(addr, 1)[0]))
+	case opI32Load8S + 2: // i32.load16_s
+		f.push(uint64(uint32(int32(int16(binary.LittleEndian.Uint16(e.slice(addr, 2)))))))
+	case opI32Load8S + 3: // i32.load16_u
+		f.push(uint64(binary.LittleEndian.Uint16(e.slice(addr, 2))))
+	case opI32Load8S + 4: // i64.load8_s
+		f.push(uint64(int64(int8(e.slice(addr, 1)[0]))))
+	case opI32Load8S + 5: // i64.load8_u
+		f.push(uint64(e.slice(addr, 1)[0]))
+	case opI32Load8S + 6: // i64.load16_s
+		f.push(uint64(int64(int16(binary.LittleEndian.Uint16(e.slice(addr, 2))))))
+	case opI32Load8S + 7: // i64.load16_u
+		f.push(uint64(binary.LittleEndian.Uint16(e.slice(addr, 2))))
+	case opI32Load8S + 8: // i64.load32_s
+		f.push(uint64(int64(int32(binary.LittleEndian.Uint32(e.slice(addr, 4))))))
+	case opI64Load32U:
+		f.push(uint64(binary.LittleEndian.Uint32(e.slice(addr, 4))))
+
+	case opI32Store:
+		binary.LittleEndian.PutUint32(e.slice(addr, 4), uint32(value))
+	case opI64Store:
+		binary.LittleEndian.PutUint64(e.slice(addr, 8), value)
+	case opI32Store8, opI32Store8 + 2: // i32.store8, i64.store8
+		e.slice(addr, 1)[0] = byte(value)
+	case opI32Store8 + 1, opI32Store8 + 3: // i32.store16, i64.store16
+		binary.LittleEndian.PutUint16(e.slice(addr, 2), uint16(value))
+	case opI64Store32:
+		binary.LittleEndian.PutUint32(e.slice(addr, 4), uint32(value))
+	}
+}
+
+// compare executes a test or comparison instruction.
+func compare(f *frame, op byte) {
+	wide := op >= opI64Eqz
+	if op == opI32Eqz || op == opI64Eqz {
+		v := f.pop()
+		if !wide {
+			v &= wordMask32
+		}
+		f.push(bool32(v == 0))
+		return
+	}
+	b, a := f.pop(), f.pop()
+	sa, sb := int64(a), int64(b)
+	if !wide {
+		a, b = a&wordMask32, b&wordMask32
+		sa, sb = int64(int32(a)), int64(int32(b))
+		op += opI64Eqz - opI32Eqz
+	}
+	var result bool
+	switch op - opI64Eqz {
+	case 1: // eq
+		result = a == b
+	case 2: // ne
+		result = a != b
+	case 3: // lt_s
+		result = sa < sb
+	case 4: // lt_u
+		result = a < b
+	case 5: // gt_s
+		result = sa > sb
+	case 6: // gt_u
+		result = a > b
+	case 7: // le_s
+		result = sa <= sb
+	case 8: // le_u
+		result = a <= b
+	case 9: // ge_s
+		result = sa >= sb
+	case 10: // ge_u
+		result = a >= b
+	}
+	f.push(bool32(result))
+}
+
+// bool32 converts a boolean to an i32 value.
+func bool32(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// arithmetic executes a numeric instruction.
+func arithmetic(f *frame, op byte) {
+	if op < opI32Clz+18 {
+		f.push(uint64(arithmetic32(f, op-opI32Clz)))
+	} else {
+		f.push(arithmetic64(f, op-opI32Clz-18))
+	}
+}
+
+func arithmetic32(f *frame, op byte) uint32 {
+	switch op {
+	case 0: // clz
+		return uint32(leadingZeros(uint64(f.pop32())) - 32)
+	case 1: // ctz
+		return uint32(trailingZeros(uint64(f.pop32()), 32))
+	case 2: // popcnt
+		return uint32(onesCount(uint64(f.pop32())))
+	}
+	b, a := f.pop32(), f.pop32()
+	switch op {
+	case 3:
+		return a + b
+	case 4:
+		return a - b
+	case 5:
+		return a * b
+	case 6: // div_s
+		if b == 0 {
+			throw(errDivideByZero)
+		}
+		if int32(a) == minInt32 && int32(b) == -1 {
+			throw(errDivideOverflow)
+		}
+		return uint32(int32(a) / int32(b))
+	case 7: // div_u
+		if b == 0 {
+			throw(errDivideByZero)
+		}
+		return a / b
+	case 8: // rem_s
+		if b == 0 {
+			throw(errDivideByZero)
+		}
+		if int32(b) == -1 {
+			return 0
+		}
+		return uint32(int32(a) % int32(b))
+	case 9: // rem_u
+		if b == 0 {
+			throw(errDivideByZero)
+		}
+		return a % b
+	case 10:
+		return a & b
+	case 11:
+		return a | b
+	case 12:
+		return a ^ b
+	case 13:
+		return a << (b & 31)
+	case 14: // shr_s
+		return uint32(int32(a) >> (b & 31))
+	case 15: // shr_u
+		return a >> (b & 31)
+	case 16:
+		return a<<(b&31) | a>>(32-b&31)
+	default: // rotr
+		return a>>(b&31) | a<<(32-b&31)
+	}
+}
+
+func arithmetic64(f *frame, op byte) uint64 {
+	switch op {
+	case 0: // clz
+		return uint64(leadingZeros(f.pop()))
+	case 1: // ctz
+		return uint64(trailingZeros(f.pop(), 64))
+	case 2: // popcnt
+		return uint64(onesCount(f.pop()))
+	}
+	b, a := f.pop(), f.pop()
+	switch op {
+	case 3:
+		return a + b
+	case 4:
+		return a - b
+	case 5:
+		return a * b
+	case 6: // div_s
+		if b == 0 {
+			throw(errDivideByZero)
+		}
+		if int64(a) == minInt64 && int64(b) == -1 {
+			throw(errDivideOverflow)
+		}
+		return uint64(int64(a) / int64(b))
+	case 7: // div_u
+		if b == 0 {
+			throw(errDivideByZero)
+		}
+		return a / b
+	case 8: // rem_s
+		if b == 0 {
+			throw(errDivideByZero)
+		}
+		if int64(b) == -1 {
+			return 0
+		}
+		return uint64(int64(a) % int64(b))
+	case 9: // rem_u
+		if b == 0 {
+			throw(errDivideByZero)
+		}
+		return a % b
+	case 10:
+		return a & b
+	case 11:
+		return a | b
+	case 12:
+		return a ^ b
+	case 13:
+		return a << (b & 63)
+	case 14: // shr_s
+		return uint64(int64(a) >> (b & 63))
+	case 15: // shr_u
+		return a >> (b & 63)
+	case 16:
+		return a<<(b&63) | a>>(64-b&63)
+	default: // rotr
+		return a>>(b&63) | a<<(64-b&63)
+	}
+}
+
+// leadingZeros returns the number of leading zero bits of a 64 bit value.
+func leadingZeros(x uint64) int {
+	n := 0
+	for ; n < 64 && x&(1<<63) == 0; n++ {
+		x <<= 1
+	}
+	return n
+}
+
+// trailingZeros returns the number of trailing zero bits of a value of the given
+// bit size.
+func trailingZeros(x uint64, size int) int {
+	if x == 0 {
+		return size
+	}
+	n := 0
+	for ; x&1 == 0; n++ {
+		x >>= 1
+	}
+	return n
+}
+
+// onesCount returns the number of set bits of a value.
+func onesCount(x uint64) int {
+	n := 0
+	for ; x != 0; n++ {
+		x &= x - 1
+	}
+	return n
+}
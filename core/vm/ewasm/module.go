@@ -0,0 +1,507 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ewasm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// Magic is the prefix of WebAssembly modules, used to tell eWASM contracts apart
+// from EVM bytecode.
+var Magic = []byte("\x00asm")
+
+// moduleVersion is the only supported version of the WebAssembly binary format.
+var moduleVersion = []byte{0x01, 0x00, 0x00, 0x00}
+
+// Section identifiers of the WebAssembly binary format.
+const (
+	sectionCustom   = 0
+	sectionType     = 1
+	sectionImport   = 2
+	sectionFunction = 3
+	sectionTable    = 4
+	sectionMemory   = 5
+	sectionGlobal   = 6
+	sectionExport   = 7
+	sectionStart    = 8
+	sectionElement  = 9
+	sectionCode     = 10
+	sectionData     = 11
+)
+
+// Value and block types. Floating point types are rejected, their results are not
+// guaranteed to be deterministic across platforms.
+const (
+	typeI32   = 0x7f
+	typeI64   = 0x7e
+	typeEmpty = 0x40 // Block type of blocks not yielding a value
+	typeFunc  = 0x60 // Form of function types
+)
+
+// External kinds of imports and exports.
+const (
+	externalFunction = 0
+	externalMemory   = 2
+)
+
+const (
+	maxLocals = 1024 // Maximum number of locals (including parameters) of a function
+	pageSize  = 65536
+	maxPages  = 64 // Maximum size of the linear memory (4MB)
+)
+
+var (
+	errInvalidMagic   = errors.New("invalid module magic")
+	errInvalidVersion = errors.New("unsupported module version")
+	errTruncated      = errors.New("truncated module")
+	errOverflow       = errors.New("integer overflow")
+)
+
+// funcType is the signature of a function.
+type funcType struct {
+	params  []byte
+	results []byte // At most one result
+}
+
+// equal returns whether two function types are identical.
+func (t *funcType) equal(other *funcType) bool {
+	return bytes.Equal(t.params, other.params) && bytes.Equal(t.results, other.results)
+}
+
+// function is a function defined by the module.
+type function struct {
+	typ    uint32
+	locals []byte // Types of the locals, excluding the parameters
+	code   []byte // Body of the function, ending with the final end instruction
+
+	ends  map[int]int // Positions of the ends of blocks, loops, ifs and elses
+	elses map[int]int // Positions of the elses of ifs
+}
+
+// global is a global variable of the module.
+type global struct {
+	typ     byte
+	mutable bool
+	init    uint64
+}
+
+// export is an item exported by the module.
+type export struct {
+	kind  byte
+	index uint32
+}
+
+// segment is a data segment initializing the linear memory.
+type segment struct {
+	offset uint32
+	data   []byte
+}
+
+// module is a decoded and validated WebAssembly module.
+type module struct {
+	types   []funcType
+	imports []*hostFunc // Imported functions, preceding the defined ones in the index space
+	funcs   []function
+	globals []global
+	exports map[string]export
+	data    []segment
+
+	memory    bool   // Whether the module defines a linear memory
+	memoryMin uint32 // Initial number of memory pages
+	memoryMax uint32 // Maximum number of memory pages
+}
+
+// funcType returns the signature of the function with the given index.
+func (m *module) funcType(index uint32) *funcType {
+	if index < uint32(len(m.imports)) {
+		return &m.imports[index].typ
+	}
+	return &m.types[m.funcs[index-uint32(len(m.imports))].typ]
+}
+
+// decodeModule parses a WebAssembly module, validating that it only uses the
+// supported subset of the format and imports known host functions only.
+func decodeModule(code []byte) (*module, error) {
+	if !bytes.HasPrefix(code, Magic) {
+		return nil, errInvalidMagic
+	}
+	if len(code) < 8 || !bytes.Equal(code[4:8], moduleVersion) {
+		return nil, errInvalidVersion
+	}
+	m := &module{exports: make(map[string]export)}
+
+	r := &reader{buf: code, pos: 8}
+	last := byte(0)
+	for r.pos < len(r.buf) {
+		id := r.byte()
+		size := r.u32()
+		payload := r.bytes(size)
+		if r.err != nil {
+			return nil, r.err
+		}
+		if id != sectionCustom {
+			if id <= last {
+				return nil, fmt.Errorf("section %d out of order", id)
+			}
+			last = id
+		}
+		s := &reader{buf: payload}
+		var err error
+		switch id {
+		case sectionCustom:
+			continue
+		case sectionType:
+			err = m.decodeTypes(s)
+		case sectionImport:
+			err = m.decodeImports(s)
+		case sectionFunction:
+			err = m.decodeFunctions(s)
+		case sectionMemory:
+			err = m.decodeMemory(s)
+		case sectionGlobal:
+			err = m.decodeGlobals(s)
+		case sectionExport:
+			err = m.decodeExports(s)
+		case sectionCode:
+			err = m.decodeCode(s)
+		case sectionData:
+			err = m.decodeData(s)
+		case sectionTable, sectionStart, sectionElement:
+			err = fmt.Errorf("section %d not supported", id)
+		default:
+			err = fmt.Errorf("unknown section %d", id)
+		}
+		if err == nil {
+			err = s.err
+		}
+		if err == nil && s.pos != len(s.buf) {
+			err = fmt.Errorf("section %d size mismatch", id)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i := range m.funcs {
+		if m.funcs[i].code == nil {
+			return nil, errors.New("function without body")
+		}
+	}
+	for name, exp := range m.exports {
+		switch {
+		case exp.kind == externalFunction && exp.index >= uint32(len(m.imports)+len(m.funcs)):
+			return nil, fmt.Errorf("export %q of unknown function", name)
+		case exp.kind == externalMemory && (!m.memory || exp.index != 0):
+			return nil, fmt.Errorf("export %q of unknown memory", name)
+		}
+	}
+	// All function signatures are known, validate the bodies
+	for i := range m.funcs {
+		if err := m.analyse(&m.funcs[i]); err != nil {
+			return nil, fmt.Errorf("function %d: %v", len(m.imports)+i, err)
+		}
+	}
+	return m, nil
+}
+
+func (m *module) decodeTypes(r *reader) error {
+	count := r.u32()
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		if form := r.byte(); form != typeFunc {
+			return fmt.Errorf("invalid function type form 0x%x", form)
+		}
+		var typ funcType
+		typ.params = r.valueTypes()
+		typ.results = r.valueTypes()
+		if len(typ.results) > 1 {
+			return errors.New("multiple results not supported")
+		}
+		m.types = append(m.types, typ)
+	}
+	return r.err
+}
+
+func (m *module) decodeImports(r *reader) error {
+	count := r.u32()
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		module, name, kind := r.name(), r.name(), r.byte()
+		if kind != externalFunction {
+			return fmt.Errorf("import %s.%s: only functions can be imported", module, name)
+		}
+		index := r.u32()
+		if r.err != nil {
+			break
+		}
+		if index >= uint32(len(m.types)) {
+			return fmt.Errorf("import %s.%s: unknown type %d", module, name, index)
+		}
+		host := hostFuncs[name]
+		if module != hostModule || host == nil {
+			return fmt.Errorf("unknown import %s.%s", module, name)
+		}
+		if !host.typ.equal(&m.types[index]) {
+			return fmt.Errorf("import %s.%s: signature mismatch", module, name)
+		}
+		m.imports = append(m.imports, host)
+	}
+	return r.err
+}
+
+func (m *module) decodeFunctions(r *reader) error {
+	count := r.u32()
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		index := r.u32()
+		if r.err == nil && index >= uint32(len(m.types)) {
+			return fmt.Errorf("function %d: unknown type %d", i, index)
+		}
+		m.funcs = append(m.funcs, function{typ: index})
+	}
+	return r.err
+}
+
+func (m *module) decodeMemory(r *reader) error {
+	if count := r.u32(); count > 1 {
+		return errors.New("multiple memories not supported")
+	} else if count == 0 {
+		return r.err
+	}
+	m.memory, m.memoryMax = true, maxPages
+	switch flags := r.byte(); flags {
+	case 0:
+		m.memoryMin = r.u32()
+	case 1:
+		m.memoryMin, m.memoryMax = r.u32(), r.u32()
+	default:
+		return fmt.Errorf("invalid memory limits flags 0x%x", flags)
+	}
+	if m.memoryMin > m.memoryMax || m.memoryMin > maxPages {
+		return fmt.Errorf("memory limits %d-%d exceed %d pages", m.memoryMin, m.memoryMax, maxPages)
+	}
+	if m.memoryMax > maxPages {
+		m.memoryMax = maxPages
+	}
+	return r.err
+}
+
+func (m *module) decodeGlobals(r *reader) error {
+	count := r.u32()
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		var g global
+		g.typ = r.valueType()
+		switch mut := r.byte(); mut {
+		case 0, 1:
+			g.mutable = mut == 1
+		default:
+			return fmt.Errorf("global %d: invalid mutability 0x%x", i, mut)
+		}
+		g.init = r.constExpr(g.typ)
+		m.globals = append(m.globals, g)
+	}
+	return r.err
+}
+
+func (m *module) decodeExports(r *reader) error {
+	count := r.u32()
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		name, kind, index := r.name(), r.byte(), r.u32()
+		if kind != externalFunction && kind != externalMemory {
+			return fmt.Errorf("export %q: only functions and memories can be exported", name)
+		}
+		if _, ok := m.exports[name]; ok {
+			return fmt.Errorf("duplicate export %q", name)
+		}
+		m.exports[name] = export{kind: kind, index: index}
+	}
+	return r.err
+}
+
+func (m *module) decodeCode(r *reader) error {
+	count := r.u32()
+	if r.err == nil && count != uint32(len(m.funcs)) {
+		return errors.New("function and code section mismatch")
+	}
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		size := r.u32()
+		body := &reader{buf: r.bytes(size)}
+
+		fn := &m.funcs[i]
+		total := uint32(len(m.types[fn.typ].params))
+		groups := body.u32()
+		for j := uint32(0); j < groups && body.err == nil; j++ {
+			n, typ := body.u32(), body.valueType()
+			if total += n; n > maxLocals || total > maxLocals {
+				return fmt.Errorf("function %d: too many locals", i)
+			}
+			fn.locals = append(fn.locals, bytes.Repeat([]byte{typ}, int(n))...)
+		}
+		if body.err != nil {
+			return body.err
+		}
+		if fn.code = body.buf[body.pos:]; len(fn.code) == 0 {
+			return errTruncated
+		}
+	}
+	return r.err
+}
+
+func (m *module) decodeData(r *reader) error {
+	count := r.u32()
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		if index := r.u32(); index != 0 || !m.memory {
+			return fmt.Errorf("data segment %d: unknown memory", i)
+		}
+		offset := uint32(r.constExpr(typeI32))
+		size := r.u32()
+		data := r.bytes(size)
+		if r.err == nil && uint64(offset)+uint64(size) > uint64(m.memoryMin)*pageSize {
+			return fmt.Errorf("data segment %d out of bounds", i)
+		}
+		m.data = append(m.data, segment{offset: offset, data: data})
+	}
+	return r.err
+}
+
+// reader decodes the primitives of the WebAssembly binary format. Decoding errors
+// are sticky, all subsequent reads return zero values.
+type reader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (r *reader) byte() byte {
+	if r.err != nil {
+		return 0
+	}
+	if r.pos >= len(r.buf) {
+		r.err = errTruncated
+		return 0
+	}
+	b := r.buf[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *reader) bytes(n uint32) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if uint64(r.pos)+uint64(n) > uint64(len(r.buf)) {
+		r.err = errTruncated
+		return nil
+	}
+	b := r.buf[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b
+}
+
+func (r *reader) name() string {
+	return string(r.bytes(r.u32()))
+}
+
+// u32 decodes an unsigned LEB128 integer of at most 32 bits.
+func (r *reader) u32() uint32 {
+	var result uint64
+	for shift := uint(0); ; shift += 7 {
+		b := r.byte()
+		if r.err != nil {
+			return 0
+		}
+		result |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		if shift >= 28 {
+			r.err = errOverflow
+			return 0
+		}
+	}
+	if result > 1<<32-1 {
+		r.err = errOverflow
+		return 0
+	}
+	return uint32(result)
+}
+
+// s64 decodes a signed LEB128 integer of at most the given number of bits.
+func (r *reader) s64(bits uint) int64 {
+	var (
+		result int64
+		shift  uint
+		b      byte
+	)
+	for {
+		b = r.byte()
+		if r.err != nil {
+			return 0
+		}
+		result |= int64(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			break
+		}
+		if shift >= bits {
+			r.err = errOverflow
+			return 0
+		}
+	}
+	if shift < 64 && b&0x40 != 0 {
+		result |= -1 << shift
+	}
+	return result
+}
+
+func (r *reader) valueType() byte {
+	typ := r.byte()
+	if r.err == nil && typ != typeI32 && typ != typeI64 {
+		r.err = fmt.Errorf("value type 0x%x not supported", typ)
+	}
+	return typ
+}
+
+func (r *reader) valueTypes() []byte {
+	count := r.u32()
+	if r.err == nil && count > maxLocals {
+		r.err = errors.New("too many values")
+	}
+	types := make([]byte, 0, count)
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		types = append(types, r.valueType())
+	}
+	return types
+}
+
+// constExpr decodes an initializer expression, which is limited to a constant of
+// the given type.
+func (r *reader) constExpr(typ byte) uint64 {
+	var value uint64
+	switch op := r.byte(); {
+	case op == opI32Const && typ == typeI32:
+		value = uint64(uint32(r.s64(32)))
+	case op == opI64Const && typ == typeI64:
+		value = uint64(r.s64(64))
+	default:
+		if r.err == nil {
+			r.err = fmt.Errorf("initializer 0x%x not supported", op)
+		}
+	}
+	if end := r.byte(); r.err == nil && end != opEnd {
+		r.err = errors.New("unterminated initializer")
+	}
+	return value
+}
@@ -16,7 +16,12 @@
 
 package vm
 
-import "testing"
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/params"
+)
 
 func TestMemoryGasCost(t *testing.T) {
 	//size := uint64(math.MaxUint64 - 64)
@@ -34,3 +39,39 @@ func TestMemoryGasCost(t *testing.T) {
 		t.Error("expected error")
 	}
 }
+
+// Tests that chain configs can reprice opcodes with fixed costs from the repricing
+// block on, and only those.
+func TestOpcodeRepricing(t *testing.T) {
+	config := *params.TestChainConfig
+	config.GasRepricings = []*params.GasRepricing{{
+		Block:   big.NewInt(5),
+		Opcodes: map[string]uint64{"ADD": 10},
+	}}
+	if err := CheckGasRepricings(&config); err != nil {
+		t.Fatalf("valid repricing rejected: %v", err)
+	}
+	used := func(num int64) uint64 {
+		env := NewEVM(Context{BlockNumber: big.NewInt(num)}, nil, &config, Config{})
+		contract := NewContract(&dummyContractRef{}, &dummyContractRef{}, new(big.Int), 100)
+		contract.Code = []byte{byte(PUSH1), 1, byte(PUSH1), 2, byte(ADD), byte(STOP)}
+
+		if _, err := run(env, 0, contract, nil); err != nil {
+			t.Fatalf("failed to execute code: %v", err)
+		}
+		return 100 - contract.Gas
+	}
+	if gas := used(4); gas != 3*GasFastestStep {
+		t.Errorf("gas used before repricing mismatch: have %d, want %d", gas, 3*GasFastestStep)
+	}
+	if gas := used(5); gas != 2*GasFastestStep+10 {
+		t.Errorf("gas used after repricing mismatch: have %d, want %d", gas, 2*GasFastestStep+10)
+	}
+	// Opcodes with dynamic costs and unknown ones can't be repriced
+	for _, name := range []string{"SSTORE", "CALL", "FOO"} {
+		config.GasRepricings[0].Opcodes = map[string]uint64{name: 10}
+		if err := CheckGasRepricings(&config); err == nil {
+			t.Errorf("repricing of %s accepted", name)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Hooks are lightweight callbacks external profilers can attach to the execution
+// without the overhead of a tracer, which is invoked on every single step. All
+// the callbacks are optional; when no hooks are configured, the cost is a nil
+// check per call frame.
+type Hooks struct {
+	OnBlockStart func(header *types.Header)                                          // Called before a block's transactions are executed
+	OnBlockEnd   func(header *types.Header, err error)                               // Called after a block was processed (or failed to)
+	OnEnter      func(depth int, typ OpCode, from common.Address, to common.Address) // Called when a call frame is entered
+	OnExit       func(depth int, gasUsed uint64, err error)                          // Called when a call frame is exited
+}
+
+// BlockStart invokes the block start hook, if configured.
+func (h *Hooks) BlockStart(header *types.Header) {
+	if h != nil && h.OnBlockStart != nil {
+		h.OnBlockStart(header)
+	}
+}
+
+// BlockEnd invokes the block end hook, if configured.
+func (h *Hooks) BlockEnd(header *types.Header, err error) {
+	if h != nil && h.OnBlockEnd != nil {
+		h.OnBlockEnd(header, err)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the hooks are notified of call frames without a tracer, and that
+// unset hooks are safe to invoke.
+func TestHooks(t *testing.T) {
+	var entered, exited []int
+
+	ctx := Context{
+		CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+	}
+	hooks := &Hooks{
+		OnEnter: func(depth int, typ OpCode, from common.Address, to common.Address) {
+			if typ != CALL || to != (common.Address{0x02}) {
+				t.Errorf("entered frame mismatch: have %v to %x", typ, to)
+			}
+			entered = append(entered, depth)
+		},
+		OnExit: func(depth int, gasUsed uint64, err error) { exited = append(exited, depth) },
+	}
+	env := NewEVM(ctx, NoopStateDB{}, params.TestChainConfig, Config{Hooks: hooks, ProfileLabels: true})
+	if _, _, err := env.Call(AccountRef(common.Address{0x01}), common.Address{0x02}, nil, 100000, new(big.Int)); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if len(entered) != 1 || len(exited) != 1 || entered[0] != 0 || exited[0] != 0 {
+		t.Errorf("hook invocations mismatch: entered %v, exited %v", entered, exited)
+	}
+	if len(env.profileLabels) != 0 {
+		t.Errorf("profile labels left on the stack: %d", len(env.profileLabels))
+	}
+	hooks.BlockStart(new(types.Header))
+	(*Hooks)(nil).BlockEnd(new(types.Header), nil)
+}
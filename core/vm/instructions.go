@@ -518,6 +518,10 @@ func opSstore(pc *uint64, evm *EVM, contract *Contract, memory *Memory, stack *S
 	val := stack.pop()
 	evm.StateDB.SetState(contract.Address(), loc, common.BigToHash(val))
 
+	if evm.vmConfig.Debug {
+		evm.vmConfig.Tracer.CaptureStorageChange(evm, contract.Address(), loc, common.BigToHash(val))
+	}
+
 	evm.interpreter.intPool.put(val)
 	return nil, nil
 }
@@ -19,6 +19,7 @@ package vm
 import (
 	"fmt"
 	"sync/atomic"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
@@ -43,6 +44,12 @@ type Config struct {
 	DisableGasMetering bool
 	// Enable recording of SHA3/keccak preimages
 	EnablePreimageRecording bool
+	// Stats collects execution statistics if set
+	Stats *Stats
+	// Hooks are notified of block and call frame boundaries if set
+	Hooks *Hooks
+	// ProfileLabels labels CPU profiles with the executing contracts
+	ProfileLabels bool
 	// JumpTable contains the EVM instruction table. This
 	// may be left uninitialised and will be set to the default
 	// table.
@@ -77,6 +84,7 @@ func NewInterpreter(evm *EVM, cfg Config) *Interpreter {
 		default:
 			cfg.JumpTable = frontierInstructionSet
 		}
+		repriceInstructionSet(&cfg.JumpTable, evm.ChainConfig(), evm.BlockNumber)
 	}
 
 	return &Interpreter{
@@ -87,6 +95,12 @@ func NewInterpreter(evm *EVM, cfg Config) *Interpreter {
 	}
 }
 
+// ReadOnly reports whether the interpreter is executing a static call, during which
+// state modifications are forbidden.
+func (in *Interpreter) ReadOnly() bool {
+	return in.readOnly
+}
+
 func (in *Interpreter) enforceRestrictions(op OpCode, operation operation, stack *Stack) error {
 	if in.evm.chainRules.IsByzantium {
 		if in.readOnly {
@@ -145,9 +159,13 @@ func (in *Interpreter) Run(snapshot int, contract *Contract, input []byte) (ret
 	)
 	contract.Input = input
 
+	if in.cfg.Stats != nil {
+		start := time.Now()
+		defer func() { in.cfg.Stats.recordContract(contract.Address(), time.Since(start)) }()
+	}
 	defer func() {
 		if err != nil && !logged && in.cfg.Debug {
-			in.cfg.Tracer.CaptureState(in.evm, pcCopy, op, gasCopy, cost, mem, stackCopy, contract, in.evm.depth, err)
+			in.cfg.Tracer.CaptureFault(in.evm, pcCopy, op, gasCopy, cost, mem, stackCopy, contract, in.evm.depth, err)
 		}
 	}()
 
@@ -201,14 +219,24 @@ func (in *Interpreter) Run(snapshot int, contract *Contract, input []byte) (ret
 		if !in.cfg.DisableGasMetering {
 			// consume the gas and return an error if not enough gas is available.
 			// cost is explicitly set so that the capture state defer method cas get the proper cost
-			cost, err = operation.gasCost(in.gasTable, in.evm, contract, stack, mem, memorySize)
-			if err != nil || !contract.UseGas(cost) {
+			cost = operation.constantGas
+			if operation.gasCost != nil {
+				var dynamicCost uint64
+				dynamicCost, err = operation.gasCost(in.gasTable, in.evm, contract, stack, mem, memorySize)
+				if cost += dynamicCost; err != nil || cost < dynamicCost {
+					return nil, ErrOutOfGas
+				}
+			}
+			if !contract.UseGas(cost) {
 				return nil, ErrOutOfGas
 			}
 		}
 		if memorySize > 0 {
 			mem.Resize(memorySize)
 		}
+		if in.cfg.Stats != nil {
+			in.cfg.Stats.recordOp(op, cost, stack)
+		}
 
 		if in.cfg.Debug {
 			in.cfg.Tracer.CaptureState(in.evm, pc, op, gasCopy, cost, mem, stackCopy, contract, in.evm.depth, err)
@@ -0,0 +1,109 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// CodeInterpreter is an alternative interpreter executing contract code in a
+// format other than EVM bytecode (e.g. eWASM modules).
+type CodeInterpreter interface {
+	// CanRun returns whether the code is in the format understood by the
+	// interpreter, usually decided by a magic prefix.
+	CanRun(code []byte) bool
+
+	// Run executes the contract's code with the given input, consuming the gas of
+	// the contract. Errors are handled the same way as the EVM's: the state is
+	// reverted to the snapshot and, unless ErrExecutionReverted, all gas is spent.
+	Run(snapshot int, contract *Contract, input []byte) ([]byte, error)
+}
+
+// ErrExecutionReverted is returned by alternative interpreters to revert the state
+// changes of an execution without consuming the remaining gas.
+var ErrExecutionReverted = errExecutionReverted
+
+// InterpreterFactory creates an alternative interpreter bound to an EVM.
+type InterpreterFactory func(evm *EVM, cfg Config) CodeInterpreter
+
+var (
+	customInterpreters     = make(map[string]InterpreterFactory) // Interpreters activatable by chain configs
+	customInterpretersLock sync.RWMutex                          // Protects the custom interpreters
+)
+
+// RegisterInterpreter registers an alternative interpreter under the given name.
+// Chain configs may activate it from a given block on, after which contracts with
+// code it can run are executed by it instead of the EVM. Registering the same name
+// twice panics.
+func RegisterInterpreter(name string, factory InterpreterFactory) {
+	customInterpretersLock.Lock()
+	defer customInterpretersLock.Unlock()
+
+	if _, ok := customInterpreters[name]; ok {
+		panic(fmt.Sprintf("interpreter %q registered twice", name))
+	}
+	customInterpreters[name] = factory
+}
+
+// CheckInterpreters verifies that all the alternative interpreters activated by a
+// chain config are registered.
+func CheckInterpreters(config *params.ChainConfig) error {
+	customInterpretersLock.RLock()
+	defer customInterpretersLock.RUnlock()
+
+	for name, block := range config.Interpreters {
+		if block == nil {
+			return fmt.Errorf("interpreter %q not scheduled", name)
+		}
+		if customInterpreters[name] == nil {
+			return fmt.Errorf("unknown interpreter %q", name)
+		}
+	}
+	return nil
+}
+
+// activeInterpreters creates the alternative interpreters active at the given
+// block, ordered by name to keep the selection deterministic.
+func activeInterpreters(evm *EVM, cfg Config, num *big.Int) []CodeInterpreter {
+	config := evm.chainConfig
+	if len(config.Interpreters) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(config.Interpreters))
+	for name, block := range config.Interpreters {
+		if block != nil && block.Cmp(num) <= 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	customInterpretersLock.RLock()
+	defer customInterpretersLock.RUnlock()
+
+	var interpreters []CodeInterpreter
+	for _, name := range names {
+		if factory := customInterpreters[name]; factory != nil {
+			interpreters = append(interpreters, factory(evm, cfg))
+		}
+	}
+	return interpreters
+}
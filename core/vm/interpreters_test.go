@@ -0,0 +1,87 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// echoMagic is the code prefix recognized by the echo interpreter. It starts with
+// STOP, so the EVM executes such code as a no-op.
+var echoMagic = []byte("\x00echo")
+
+// echoInterpreter is an alternative interpreter returning its input, used to test
+// the interpreter selection.
+type echoInterpreter struct{}
+
+func (echoInterpreter) CanRun(code []byte) bool { return bytes.HasPrefix(code, echoMagic) }
+
+func (echoInterpreter) Run(snapshot int, contract *Contract, input []byte) ([]byte, error) {
+	if !contract.UseGas(10) {
+		return nil, ErrOutOfGas
+	}
+	return input, nil
+}
+
+func init() {
+	RegisterInterpreter("echo", func(evm *EVM, cfg Config) CodeInterpreter { return echoInterpreter{} })
+}
+
+// Tests that alternative interpreters only run code they understand, and only
+// from their activation block on.
+func TestAlternativeInterpreters(t *testing.T) {
+	config := *params.TestChainConfig
+	config.Interpreters = map[string]*big.Int{"echo": big.NewInt(10)}
+	if err := CheckInterpreters(&config); err != nil {
+		t.Fatalf("valid config rejected: %v", err)
+	}
+	execute := func(num int64, code []byte) ([]byte, uint64) {
+		env := NewEVM(Context{BlockNumber: big.NewInt(num)}, nil, &config, Config{})
+		contract := NewContract(&dummyContractRef{}, &dummyContractRef{}, new(big.Int), 100)
+		contract.Code = code
+
+		out, err := run(env, 0, contract, []byte{0x01, 0x02})
+		if err != nil {
+			t.Fatalf("failed to execute code: %v", err)
+		}
+		return out, contract.Gas
+	}
+	// Before the activation, the EVM executes the code
+	if out, gas := execute(9, echoMagic); out != nil || gas != 100 {
+		t.Errorf("inactive interpreter used: output %x, gas %d", out, gas)
+	}
+	// Afterwards the interpreter takes over, but only for code it understands
+	if out, gas := execute(10, echoMagic); !bytes.Equal(out, []byte{0x01, 0x02}) || gas != 90 {
+		t.Errorf("interpreter result mismatch: output %x, gas %d", out, gas)
+	}
+	if out, gas := execute(10, []byte{byte(STOP)}); out != nil || gas != 100 {
+		t.Errorf("interpreter ran foreign code: output %x, gas %d", out, gas)
+	}
+	// Unknown and unscheduled interpreters are invalid
+	config.Interpreters = map[string]*big.Int{"unknown": big.NewInt(0)}
+	if err := CheckInterpreters(&config); err == nil {
+		t.Errorf("unknown interpreter accepted")
+	}
+	config.Interpreters = map[string]*big.Int{"echo": nil}
+	if err := CheckInterpreters(&config); err == nil {
+		t.Errorf("unscheduled interpreter accepted")
+	}
+}
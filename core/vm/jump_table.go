@@ -18,6 +18,7 @@ package vm
 
 import (
 	"errors"
+	"fmt"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/params"
@@ -35,7 +36,11 @@ var errGasUintOverflow = errors.New("gas uint64 overflow")
 type operation struct {
 	// op is the operation function
 	execute executionFunc
-	// gasCost is the gas function and returns the gas required for execution
+	// constantGas is the fixed gas required for execution, which chain configs
+	// may reprice
+	constantGas uint64
+	// gasCost is the gas function and returns the dynamic gas required for
+	// execution on top of constantGas (nil if there's none)
 	gasCost gasFunc
 	// validateStack validates the stack (size) for the operation
 	validateStack stackValidationFunc
@@ -50,6 +55,36 @@ type operation struct {
 	returns bool // determines whether the operations sets the return data content
 }
 
+// CheckGasRepricings verifies that all the opcodes repriced by a chain config exist
+// and have a fixed cost.
+func CheckGasRepricings(config *params.ChainConfig) error {
+	for i, repricing := range config.GasRepricings {
+		for name := range repricing.Opcodes {
+			if !repriceable(name) {
+				return fmt.Errorf("gas repricing #%d: opcode %q has no fixed cost", i, name)
+			}
+		}
+	}
+	return nil
+}
+
+// repriceable returns whether the opcode with the given name has a fixed cost,
+// which chain configs may reprice.
+func repriceable(name string) bool {
+	op := StringToOp(name)
+	return op.String() == name && byzantiumInstructionSet[op].valid && byzantiumInstructionSet[op].gasCost == nil
+}
+
+// repriceInstructionSet replaces the fixed costs of the opcodes repriced by the
+// chain config at the given block.
+func repriceInstructionSet(instructionSet *[256]operation, config *params.ChainConfig, num *big.Int) {
+	for name, gas := range config.OpcodeCosts(num) {
+		if op := StringToOp(name); repriceable(name) && instructionSet[op].valid {
+			instructionSet[op].constantGas = gas
+		}
+	}
+}
+
 var (
 	frontierInstructionSet  = NewFrontierInstructionSet()
 	homesteadInstructionSet = NewHomesteadInstructionSet()
@@ -71,7 +106,7 @@ func NewByzantiumInstructionSet() [256]operation {
 	}
 	instructionSet[RETURNDATASIZE] = operation{
 		execute:       opReturnDataSize,
-		gasCost:       constGasFunc(GasQuickStep),
+		constantGas:   GasQuickStep,
 		validateStack: makeStackFunc(0, 1),
 		valid:         true,
 	}
@@ -115,62 +150,62 @@ func NewFrontierInstructionSet() [256]operation {
 	return [256]operation{
 		STOP: {
 			execute:       opStop,
-			gasCost:       constGasFunc(0),
+			constantGas:   0,
 			validateStack: makeStackFunc(0, 0),
 			halts:         true,
 			valid:         true,
 		},
 		ADD: {
 			execute:       opAdd,
-			gasCost:       constGasFunc(GasFastestStep),
+			constantGas:   GasFastestStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		MUL: {
 			execute:       opMul,
-			gasCost:       constGasFunc(GasFastStep),
+			constantGas:   GasFastStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		SUB: {
 			execute:       opSub,
-			gasCost:       constGasFunc(GasFastestStep),
+			constantGas:   GasFastestStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		DIV: {
 			execute:       opDiv,
-			gasCost:       constGasFunc(GasFastStep),
+			constantGas:   GasFastStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		SDIV: {
 			execute:       opSdiv,
-			gasCost:       constGasFunc(GasFastStep),
+			constantGas:   GasFastStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		MOD: {
 			execute:       opMod,
-			gasCost:       constGasFunc(GasFastStep),
+			constantGas:   GasFastStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		SMOD: {
 			execute:       opSmod,
-			gasCost:       constGasFunc(GasFastStep),
+			constantGas:   GasFastStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		ADDMOD: {
 			execute:       opAddmod,
-			gasCost:       constGasFunc(GasMidStep),
+			constantGas:   GasMidStep,
 			validateStack: makeStackFunc(3, 1),
 			valid:         true,
 		},
 		MULMOD: {
 			execute:       opMulmod,
-			gasCost:       constGasFunc(GasMidStep),
+			constantGas:   GasMidStep,
 			validateStack: makeStackFunc(3, 1),
 			valid:         true,
 		},
@@ -182,73 +217,73 @@ func NewFrontierInstructionSet() [256]operation {
 		},
 		SIGNEXTEND: {
 			execute:       opSignExtend,
-			gasCost:       constGasFunc(GasFastStep),
+			constantGas:   GasFastStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		LT: {
 			execute:       opLt,
-			gasCost:       constGasFunc(GasFastestStep),
+			constantGas:   GasFastestStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		GT: {
 			execute:       opGt,
-			gasCost:       constGasFunc(GasFastestStep),
+			constantGas:   GasFastestStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		SLT: {
 			execute:       opSlt,
-			gasCost:       constGasFunc(GasFastestStep),
+			constantGas:   GasFastestStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		SGT: {
 			execute:       opSgt,
-			gasCost:       constGasFunc(GasFastestStep),
+			constantGas:   GasFastestStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		EQ: {
 			execute:       opEq,
-			gasCost:       constGasFunc(GasFastestStep),
+			constantGas:   GasFastestStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		ISZERO: {
 			execute:       opIszero,
-			gasCost:       constGasFunc(GasFastestStep),
+			constantGas:   GasFastestStep,
 			validateStack: makeStackFunc(1, 1),
 			valid:         true,
 		},
 		AND: {
 			execute:       opAnd,
-			gasCost:       constGasFunc(GasFastestStep),
+			constantGas:   GasFastestStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		XOR: {
 			execute:       opXor,
-			gasCost:       constGasFunc(GasFastestStep),
+			constantGas:   GasFastestStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		OR: {
 			execute:       opOr,
-			gasCost:       constGasFunc(GasFastestStep),
+			constantGas:   GasFastestStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		NOT: {
 			execute:       opNot,
-			gasCost:       constGasFunc(GasFastestStep),
+			constantGas:   GasFastestStep,
 			validateStack: makeStackFunc(1, 1),
 			valid:         true,
 		},
 		BYTE: {
 			execute:       opByte,
-			gasCost:       constGasFunc(GasFastestStep),
+			constantGas:   GasFastestStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
@@ -261,7 +296,7 @@ func NewFrontierInstructionSet() [256]operation {
 		},
 		ADDRESS: {
 			execute:       opAddress,
-			gasCost:       constGasFunc(GasQuickStep),
+			constantGas:   GasQuickStep,
 			validateStack: makeStackFunc(0, 1),
 			valid:         true,
 		},
@@ -273,31 +308,31 @@ func NewFrontierInstructionSet() [256]operation {
 		},
 		ORIGIN: {
 			execute:       opOrigin,
-			gasCost:       constGasFunc(GasQuickStep),
+			constantGas:   GasQuickStep,
 			validateStack: makeStackFunc(0, 1),
 			valid:         true,
 		},
 		CALLER: {
 			execute:       opCaller,
-			gasCost:       constGasFunc(GasQuickStep),
+			constantGas:   GasQuickStep,
 			validateStack: makeStackFunc(0, 1),
 			valid:         true,
 		},
 		CALLVALUE: {
 			execute:       opCallValue,
-			gasCost:       constGasFunc(GasQuickStep),
+			constantGas:   GasQuickStep,
 			validateStack: makeStackFunc(0, 1),
 			valid:         true,
 		},
 		CALLDATALOAD: {
 			execute:       opCallDataLoad,
-			gasCost:       constGasFunc(GasFastestStep),
+			constantGas:   GasFastestStep,
 			validateStack: makeStackFunc(1, 1),
 			valid:         true,
 		},
 		CALLDATASIZE: {
 			execute:       opCallDataSize,
-			gasCost:       constGasFunc(GasQuickStep),
+			constantGas:   GasQuickStep,
 			validateStack: makeStackFunc(0, 1),
 			valid:         true,
 		},
@@ -310,7 +345,7 @@ func NewFrontierInstructionSet() [256]operation {
 		},
 		CODESIZE: {
 			execute:       opCodeSize,
-			gasCost:       constGasFunc(GasQuickStep),
+			constantGas:   GasQuickStep,
 			validateStack: makeStackFunc(0, 1),
 			valid:         true,
 		},
@@ -323,7 +358,7 @@ func NewFrontierInstructionSet() [256]operation {
 		},
 		GASPRICE: {
 			execute:       opGasprice,
-			gasCost:       constGasFunc(GasQuickStep),
+			constantGas:   GasQuickStep,
 			validateStack: makeStackFunc(0, 1),
 			valid:         true,
 		},
@@ -342,43 +377,43 @@ func NewFrontierInstructionSet() [256]operation {
 		},
 		BLOCKHASH: {
 			execute:       opBlockhash,
-			gasCost:       constGasFunc(GasExtStep),
+			constantGas:   GasExtStep,
 			validateStack: makeStackFunc(1, 1),
 			valid:         true,
 		},
 		COINBASE: {
 			execute:       opCoinbase,
-			gasCost:       constGasFunc(GasQuickStep),
+			constantGas:   GasQuickStep,
 			validateStack: makeStackFunc(0, 1),
 			valid:         true,
 		},
 		TIMESTAMP: {
 			execute:       opTimestamp,
-			gasCost:       constGasFunc(GasQuickStep),
+			constantGas:   GasQuickStep,
 			validateStack: makeStackFunc(0, 1),
 			valid:         true,
 		},
 		NUMBER: {
 			execute:       opNumber,
-			gasCost:       constGasFunc(GasQuickStep),
+			constantGas:   GasQuickStep,
 			validateStack: makeStackFunc(0, 1),
 			valid:         true,
 		},
 		DIFFICULTY: {
 			execute:       opDifficulty,
-			gasCost:       constGasFunc(GasQuickStep),
+			constantGas:   GasQuickStep,
 			validateStack: makeStackFunc(0, 1),
 			valid:         true,
 		},
 		GASLIMIT: {
 			execute:       opGasLimit,
-			gasCost:       constGasFunc(GasQuickStep),
+			constantGas:   GasQuickStep,
 			validateStack: makeStackFunc(0, 1),
 			valid:         true,
 		},
 		POP: {
 			execute:       opPop,
-			gasCost:       constGasFunc(GasQuickStep),
+			constantGas:   GasQuickStep,
 			validateStack: makeStackFunc(1, 0),
 			valid:         true,
 		},
@@ -419,39 +454,39 @@ func NewFrontierInstructionSet() [256]operation {
 		},
 		JUMP: {
 			execute:       opJump,
-			gasCost:       constGasFunc(GasMidStep),
+			constantGas:   GasMidStep,
 			validateStack: makeStackFunc(1, 0),
 			jumps:         true,
 			valid:         true,
 		},
 		JUMPI: {
 			execute:       opJumpi,
-			gasCost:       constGasFunc(GasSlowStep),
+			constantGas:   GasSlowStep,
 			validateStack: makeStackFunc(2, 0),
 			jumps:         true,
 			valid:         true,
 		},
 		PC: {
 			execute:       opPc,
-			gasCost:       constGasFunc(GasQuickStep),
+			constantGas:   GasQuickStep,
 			validateStack: makeStackFunc(0, 1),
 			valid:         true,
 		},
 		MSIZE: {
 			execute:       opMsize,
-			gasCost:       constGasFunc(GasQuickStep),
+			constantGas:   GasQuickStep,
 			validateStack: makeStackFunc(0, 1),
 			valid:         true,
 		},
 		GAS: {
 			execute:       opGas,
-			gasCost:       constGasFunc(GasQuickStep),
+			constantGas:   GasQuickStep,
 			validateStack: makeStackFunc(0, 1),
 			valid:         true,
 		},
 		JUMPDEST: {
 			execute:       opJumpdest,
-			gasCost:       constGasFunc(params.JumpdestGas),
+			constantGas:   params.JumpdestGas,
 			validateStack: makeStackFunc(0, 0),
 			valid:         true,
 		},
@@ -79,12 +79,21 @@ func (s *StructLog) OpName() string {
 }
 
 // Tracer is used to collect execution traces from an EVM transaction
-// execution. CaptureState is called for each step of the VM with the
-// current VM state.
+// execution. CaptureStart and CaptureEnd are called around the outermost call
+// frame, CaptureEnter and CaptureExit around every nested one (with the opcode
+// that spawned it). CaptureState is called for each step of the VM with the
+// current VM state, CaptureFault instead if the step failed, and
+// CaptureStorageChange whenever a contract writes to its storage. The value of
+// DELEGATECALL and STATICCALL frames is nil as they don't transfer any.
 // Note that reference types are actual VM data structures; make copies
 // if you need to retain them beyond the current call.
 type Tracer interface {
+	CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error
 	CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error
+	CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error
+	CaptureEnter(typ OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) error
+	CaptureExit(output []byte, gasUsed uint64, err error) error
+	CaptureStorageChange(env *EVM, addr common.Address, key, value common.Hash) error
 	CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error
 }
 
@@ -97,7 +106,14 @@ type StructLogger struct {
 	cfg LogConfig
 
 	logs          []StructLog
+	count         int // Number of steps captured, including the streamed ones
 	changedValues map[common.Address]Storage
+
+	chunk int               // Number of logs to accumulate before streaming them
+	sink  func([]StructLog) // Receiver of the streamed logs, nil if retained
+
+	output []byte
+	err    error
 }
 
 // NewStructLogger returns a new logger
@@ -111,12 +127,23 @@ func NewStructLogger(cfg *LogConfig) *StructLogger {
 	return logger
 }
 
+// NewStreamingStructLogger returns a logger that passes the captured logs to the
+// sink in chunks of the given size instead of retaining them all, so arbitrarily
+// long traces can be produced. Flush must be called after the execution to pass
+// on the last, partial chunk.
+func NewStreamingStructLogger(cfg *LogConfig, chunk int, sink func([]StructLog)) *StructLogger {
+	logger := NewStructLogger(cfg)
+	logger.chunk = chunk
+	logger.sink = sink
+	return logger
+}
+
 // CaptureState logs a new structured log message and pushes it out to the environment
 //
 // CaptureState also tracks SSTORE ops to track dirty values.
 func (l *StructLogger) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
 	// check if already accumulated the specified number of logs
-	if l.cfg.Limit != 0 && l.cfg.Limit <= len(l.logs) {
+	if l.cfg.Limit != 0 && l.cfg.Limit <= l.count {
 		return ErrTraceLimitReached
 	}
 
@@ -158,22 +185,75 @@ func (l *StructLogger) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost ui
 	log := StructLog{pc, op, gas, cost, mem, memory.Len(), stck, storage, depth, err}
 
 	l.logs = append(l.logs, log)
+	l.count++
+
+	if l.sink != nil && len(l.logs) >= l.chunk {
+		l.Flush()
+	}
 	return nil
 }
 
-func (l *StructLogger) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
-	fmt.Printf("0x%x", output)
-	if err != nil {
-		fmt.Printf(" error: %v\n", err)
+// Flush passes the logs captured since the last chunk to the sink of a streaming
+// logger. It is a noop for loggers retaining their logs.
+func (l *StructLogger) Flush() {
+	if l.sink != nil && len(l.logs) > 0 {
+		l.sink(l.logs)
+		l.logs = nil
 	}
+}
+
+// CaptureStart implements Tracer, the struct logger only captures VM steps.
+func (l *StructLogger) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	return nil
+}
+
+// CaptureFault logs the failed step the same way as a successful one, with the
+// error attached.
+func (l *StructLogger) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	return l.CaptureState(env, pc, op, gas, cost, memory, stack, contract, depth, err)
+}
+
+// CaptureEnter implements Tracer, nested frames are visible through the depth
+// of the captured steps.
+func (l *StructLogger) CaptureEnter(typ OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) error {
 	return nil
 }
 
-// StructLogs returns a list of captured log entries
+// CaptureExit implements Tracer, nested frames are visible through the depth
+// of the captured steps.
+func (l *StructLogger) CaptureExit(output []byte, gasUsed uint64, err error) error {
+	return nil
+}
+
+// CaptureStorageChange implements Tracer, storage writes are already tracked
+// from the SSTORE steps so they can be included in the step's own snapshot.
+func (l *StructLogger) CaptureStorageChange(env *EVM, addr common.Address, key, value common.Hash) error {
+	return nil
+}
+
+// CaptureEnd records the result of the outermost call frame.
+func (l *StructLogger) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
+	l.output = output
+	l.err = err
+	return nil
+}
+
+// StructLogs returns a list of captured log entries. For streaming loggers only
+// the ones not yet flushed are returned.
 func (l *StructLogger) StructLogs() []StructLog {
 	return l.logs
 }
 
+// Output returns the return data of the traced execution.
+func (l *StructLogger) Output() []byte {
+	return l.output
+}
+
+// Error returns the VM error of the traced execution, if any.
+func (l *StructLogger) Error() error {
+	return l.err
+}
+
 // WriteTrace writes a formatted trace to the given writer
 func WriteTrace(writer io.Writer, logs []StructLog) {
 	for _, log := range logs {
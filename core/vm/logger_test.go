@@ -18,6 +18,7 @@ package vm
 
 import (
 	"math/big"
+	"reflect"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -68,3 +69,30 @@ func TestStoreCapture(t *testing.T) {
 		t.Errorf("expected %x, got %x", exp, logger.changedValues[contract.Address()][index])
 	}
 }
+
+// Tests that the streaming logger passes on the captured logs in chunks, while
+// still enforcing the step limit across them.
+func TestStreamingCapture(t *testing.T) {
+	var (
+		env      = NewEVM(Context{}, nil, params.TestChainConfig, Config{})
+		chunks   []int
+		logger   = NewStreamingStructLogger(&LogConfig{Limit: 5}, 2, func(logs []StructLog) { chunks = append(chunks, len(logs)) })
+		contract = NewContract(&dummyContractRef{}, &dummyContractRef{}, new(big.Int), 0)
+	)
+	for i := 0; i < 6; i++ {
+		err := logger.CaptureState(env, uint64(i), STOP, 0, 0, NewMemory(), newstack(), contract, 0, nil)
+		if i < 5 && err != nil {
+			t.Fatalf("step %d: failed to capture: %v", i, err)
+		}
+		if i == 5 && err != ErrTraceLimitReached {
+			t.Fatalf("limit error mismatch: have %v, want %v", err, ErrTraceLimitReached)
+		}
+	}
+	logger.Flush()
+	if want := []int{2, 2, 1}; !reflect.DeepEqual(chunks, want) {
+		t.Errorf("chunk sizes mismatch: have %v, want %v", chunks, want)
+	}
+	if len(logger.StructLogs()) != 0 {
+		t.Errorf("streamed logs retained: %d", len(logger.StructLogs()))
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build go1.9
+
+package vm
+
+import (
+	"context"
+	"runtime/pprof"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// pushProfileLabel labels the current goroutine with the contract being entered,
+// so CPU profiles attribute the execution time to it.
+func (evm *EVM) pushProfileLabel(addr common.Address) {
+	parent := context.Background()
+	if n := len(evm.profileLabels); n > 0 {
+		parent = evm.profileLabels[n-1]
+	}
+	ctx := pprof.WithLabels(parent, pprof.Labels("contract", addr.Hex()))
+	evm.profileLabels = append(evm.profileLabels, ctx)
+	pprof.SetGoroutineLabels(ctx)
+}
+
+// popProfileLabel restores the goroutine label of the calling contract after a
+// call frame was exited.
+func (evm *EVM) popProfileLabel() {
+	evm.profileLabels = evm.profileLabels[:len(evm.profileLabels)-1]
+	if n := len(evm.profileLabels); n > 0 {
+		pprof.SetGoroutineLabels(evm.profileLabels[n-1])
+	} else {
+		pprof.SetGoroutineLabels(context.Background())
+	}
+}
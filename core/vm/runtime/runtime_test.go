@@ -17,9 +17,12 @@
 package runtime
 
 import (
+	"fmt"
 	"math/big"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
@@ -117,6 +120,73 @@ func TestCall(t *testing.T) {
 	}
 }
 
+// eventTracer is a vm.Tracer recording the sequence of call frame and storage
+// events emitted during execution.
+type eventTracer struct {
+	*vm.StructLogger
+	events []string
+}
+
+func (t *eventTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.events = append(t.events, fmt.Sprintf("start %x", to[19:]))
+	return nil
+}
+
+func (t *eventTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) error {
+	t.events = append(t.events, fmt.Sprintf("enter %v %x", typ, to[19:]))
+	return nil
+}
+
+func (t *eventTracer) CaptureExit(output []byte, gasUsed uint64, err error) error {
+	t.events = append(t.events, "exit")
+	return nil
+}
+
+func (t *eventTracer) CaptureStorageChange(env *vm.EVM, addr common.Address, key, value common.Hash) error {
+	t.events = append(t.events, fmt.Sprintf("store %x %x=%x", addr[19:], key[31:], value[31:]))
+	return nil
+}
+
+func (t *eventTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	t.events = append(t.events, "end")
+	return t.StructLogger.CaptureEnd(output, gasUsed, d, err)
+}
+
+// Tests that the tracer hooks are invoked for the outer and nested call frames,
+// and for storage modifications.
+func TestTracerHooks(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	state, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+	callee := common.HexToAddress("0x0b")
+	state.SetCode(callee, []byte{
+		byte(vm.PUSH1), 1,
+		byte(vm.PUSH1), 2,
+		byte(vm.SSTORE),
+		byte(vm.STOP),
+	})
+	caller := common.HexToAddress("0x0a")
+	code := []byte{
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+		byte(vm.PUSH20),
+	}
+	code = append(code, callee.Bytes()...)
+	code = append(code, byte(vm.GAS), byte(vm.CALL), byte(vm.STOP))
+	state.SetCode(caller, code)
+
+	tracer := &eventTracer{StructLogger: vm.NewStructLogger(nil)}
+	if _, _, err := Call(caller, nil, &Config{State: state, EVMConfig: vm.Config{Debug: true, Tracer: tracer}}); err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+	want := []string{"start 0a", "enter CALL 0b", "store 0b 02=01", "exit", "end"}
+	if !reflect.DeepEqual(tracer.events, want) {
+		t.Errorf("tracer events mismatch:\nhave %v\nwant %v", tracer.events, want)
+	}
+	if logs := tracer.StructLogs(); len(logs) != 13 {
+		t.Errorf("step count mismatch: have %d, want %d", len(logs), 13)
+	}
+}
+
 func BenchmarkCall(b *testing.B) {
 	var definition = `[{"constant":true,"inputs":[],"name":"seller","outputs":[{"name":"","type":"address"}],"type":"function"},{"constant":false,"inputs":[],"name":"abort","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"value","outputs":[{"name":"","type":"uint256"}],"type":"function"},{"constant":false,"inputs":[],"name":"refund","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"buyer","outputs":[{"name":"","type":"address"}],"type":"function"},{"constant":false,"inputs":[],"name":"confirmReceived","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"state","outputs":[{"name":"","type":"uint8"}],"type":"function"},{"constant":false,"inputs":[],"name":"confirmPurchase","outputs":[],"type":"function"},{"inputs":[],"type":"constructor"},{"anonymous":false,"inputs":[],"name":"Aborted","type":"event"},{"anonymous":false,"inputs":[],"name":"PurchaseConfirmed","type":"event"},{"anonymous":false,"inputs":[],"name":"ItemReceived","type":"event"},{"anonymous":false,"inputs":[],"name":"Refunded","type":"event"}]`
 
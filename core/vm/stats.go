@@ -0,0 +1,167 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/hashicorp/golang-lru"
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+// statsContractLimit is the maximum number of contracts whose execution time is
+// tracked, the least recently executed ones being dropped beyond it.
+const statsContractLimit = 4096
+
+// opClasses names the groups of opcodes the gas usage is aggregated by, indexed
+// by the high nibble of the opcode.
+var opClasses = [16]string{
+	"arithmetic", "comparison", "sha3", "environment", "block", "storage", "push", "push",
+	"dup", "swap", "log", "unknown", "unknown", "unknown", "unknown", "system",
+}
+
+// opClassMeters are the metrics the opcode executions and gas usage of each class
+// are reported to.
+var opClassMeters = func() map[string][2]gometrics.Meter {
+	meters := make(map[string][2]gometrics.Meter)
+	for _, class := range opClasses {
+		meters[class] = [2]gometrics.Meter{
+			metrics.NewMeter("vm/ops/" + class),
+			metrics.NewMeter("vm/gas/" + class),
+		}
+	}
+	return meters
+}()
+
+// Stats collects statistics about the executed code: the number of times each
+// opcode was executed, the gas spent on them and the execution time spent in
+// the most recently executed contracts. It is safe for concurrent use by
+// multiple EVMs.
+type Stats struct {
+	ops [256]uint64 // Number of executions of each opcode
+	gas [256]uint64 // Gas spent on each opcode, excluding gas forwarded to calls
+
+	contracts *lru.Cache // Execution time spent per contract
+	lock      sync.Mutex // Serializes updates of the contract statistics
+}
+
+// ContractStats is the execution time spent in a single contract.
+type ContractStats struct {
+	Address common.Address `json:"address"`
+	Calls   uint64         `json:"calls"`
+	Time    time.Duration  `json:"time"` // Nanoseconds, including nested calls
+}
+
+// StatsSummary is a snapshot of the collected statistics.
+type StatsSummary struct {
+	Ops       map[string]uint64 `json:"ops"`       // Number of executions by opcode
+	Gas       map[string]uint64 `json:"gas"`       // Gas spent by opcode class
+	Contracts []*ContractStats  `json:"contracts"` // Contracts ordered by execution time
+}
+
+// NewStats creates an empty statistics collector.
+func NewStats() *Stats {
+	contracts, _ := lru.New(statsContractLimit)
+	return &Stats{contracts: contracts}
+}
+
+// recordOp accounts for the execution of a single opcode. The gas forwarded by
+// calls (left on the stack by their gas calculation) is spent, and accounted
+// for, by the callee, so it's excluded from the cost of the call itself.
+func (s *Stats) recordOp(op OpCode, cost uint64, stack *Stack) {
+	switch op {
+	case CALL, CALLCODE, DELEGATECALL, STATICCALL:
+		if forwarded := stack.Back(0); forwarded.IsUint64() && forwarded.Uint64() <= cost {
+			cost -= forwarded.Uint64()
+		}
+	}
+	atomic.AddUint64(&s.ops[op], 1)
+	atomic.AddUint64(&s.gas[op], cost)
+
+	meters := opClassMeters[opClasses[op>>4]]
+	meters[0].Mark(1)
+	meters[1].Mark(int64(cost))
+}
+
+// recordContract accounts for the time spent executing a contract's code.
+func (s *Stats) recordContract(addr common.Address, elapsed time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var stats *ContractStats
+	if cached, ok := s.contracts.Get(addr); ok {
+		stats = cached.(*ContractStats)
+	} else {
+		stats = &ContractStats{Address: addr}
+		s.contracts.Add(addr, stats)
+	}
+	stats.Calls++
+	stats.Time += elapsed
+}
+
+// Summary returns a snapshot of the statistics, listing at most limit contracts
+// (or all if zero), the slowest first.
+func (s *Stats) Summary(limit int) *StatsSummary {
+	summary := &StatsSummary{
+		Ops: make(map[string]uint64),
+		Gas: make(map[string]uint64),
+	}
+	for op := range s.ops {
+		if count := atomic.LoadUint64(&s.ops[op]); count > 0 {
+			summary.Ops[OpCode(op).String()] = count
+			summary.Gas[opClasses[op>>4]] += atomic.LoadUint64(&s.gas[op])
+		}
+	}
+	s.lock.Lock()
+	for _, addr := range s.contracts.Keys() {
+		if stats, ok := s.contracts.Peek(addr); ok {
+			cpy := *stats.(*ContractStats)
+			summary.Contracts = append(summary.Contracts, &cpy)
+		}
+	}
+	s.lock.Unlock()
+
+	sort.Sort(contractsByTime(summary.Contracts))
+	if limit > 0 && len(summary.Contracts) > limit {
+		summary.Contracts = summary.Contracts[:limit]
+	}
+	return summary
+}
+
+// Reset clears all the collected statistics.
+func (s *Stats) Reset() {
+	for op := range s.ops {
+		atomic.StoreUint64(&s.ops[op], 0)
+		atomic.StoreUint64(&s.gas[op], 0)
+	}
+	s.lock.Lock()
+	s.contracts.Purge()
+	s.lock.Unlock()
+}
+
+// contractsByTime implements sort.Interface to order contracts by decreasing
+// execution time.
+type contractsByTime []*ContractStats
+
+func (c contractsByTime) Len() int           { return len(c) }
+func (c contractsByTime) Less(i, j int) bool { return c[i].Time > c[j].Time }
+func (c contractsByTime) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
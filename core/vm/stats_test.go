@@ -0,0 +1,123 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the executed opcodes, their gas and the executed contracts are
+// accounted for in the statistics.
+func TestStats(t *testing.T) {
+	stats := NewStats()
+	env := NewEVM(Context{}, nil, params.TestChainConfig, Config{Stats: stats})
+
+	for i := 0; i < 2; i++ {
+		contract := NewContract(&dummyContractRef{}, &dummyContractRef{}, new(big.Int), 100)
+		contract.Code = []byte{byte(PUSH1), 0x01, byte(PUSH1), 0x01, byte(ADD), byte(STOP)}
+
+		if _, err := env.Interpreter().Run(0, contract, nil); err != nil {
+			t.Fatalf("failed to execute code: %v", err)
+		}
+	}
+	summary := stats.Summary(0)
+	if want := map[string]uint64{"PUSH1": 4, "ADD": 2, "STOP": 2}; !reflect.DeepEqual(summary.Ops, want) {
+		t.Errorf("opcode counts mismatch: have %v, want %v", summary.Ops, want)
+	}
+	if want := map[string]uint64{"push": 12, "arithmetic": 6}; !reflect.DeepEqual(summary.Gas, want) {
+		t.Errorf("class gas mismatch: have %v, want %v", summary.Gas, want)
+	}
+	if len(summary.Contracts) != 1 || summary.Contracts[0].Calls != 2 {
+		t.Errorf("contract stats mismatch: %v", summary.Contracts)
+	}
+	stats.Reset()
+	if summary := stats.Summary(0); len(summary.Ops) != 0 || len(summary.Contracts) != 0 {
+		t.Errorf("statistics not reset: %v", summary)
+	}
+}
+
+// codeStateDB is a state database holding only contract code.
+type codeStateDB struct {
+	NoopStateDB
+	code map[common.Address][]byte
+}
+
+func (db codeStateDB) Exist(addr common.Address) bool      { return true }
+func (db codeStateDB) GetCode(addr common.Address) []byte  { return db.code[addr] }
+func (db codeStateDB) GetCodeSize(addr common.Address) int { return len(db.code[addr]) }
+
+// Tests that the gas forwarded by calls is only accounted for in the callee.
+func TestStatsCallGas(t *testing.T) {
+	statedb := codeStateDB{code: map[common.Address][]byte{
+		// Call 0xaa with 10000 gas
+		common.Address{0x01}: {
+			byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0,
+			byte(PUSH1), 0xaa, byte(PUSH2), 0x27, 0x10, byte(CALL), byte(STOP),
+		},
+		// Spend 9 gas
+		common.BytesToAddress([]byte{0xaa}): {byte(PUSH1), 0x01, byte(PUSH1), 0x01, byte(ADD), byte(STOP)},
+	}}
+	ctx := Context{
+		CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+		BlockNumber: new(big.Int),
+	}
+	stats := NewStats()
+	env := NewEVM(ctx, statedb, params.TestChainConfig, Config{Stats: stats})
+
+	_, left, err := env.Call(AccountRef(common.Address{}), common.Address{0x01}, nil, 100000, new(big.Int))
+	if err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	var total uint64
+	for _, gas := range stats.Summary(0).Gas {
+		total += gas
+	}
+	if used := 100000 - left; total != used {
+		t.Errorf("accounted gas mismatch: have %d, want %d", total, used)
+	}
+	if want := env.ChainConfig().GasTable(ctx.BlockNumber).Calls; stats.gas[CALL] != want {
+		t.Errorf("call gas mismatch: have %d, want %d", stats.gas[CALL], want)
+	}
+}
+
+// Tests that the number of tracked contracts is bounded, dropping the least
+// recently executed ones.
+func TestStatsContractLimit(t *testing.T) {
+	stats := NewStats()
+	for i := 0; i < statsContractLimit+10; i++ {
+		stats.recordContract(common.BigToAddress(big.NewInt(int64(i))), time.Duration(i+1))
+	}
+	summary := stats.Summary(0)
+	if len(summary.Contracts) != statsContractLimit {
+		t.Fatalf("tracked contracts mismatch: have %d, want %d", len(summary.Contracts), statsContractLimit)
+	}
+	if slowest := summary.Contracts[0].Address; slowest != common.BigToAddress(big.NewInt(statsContractLimit+9)) {
+		t.Errorf("slowest contract mismatch: have %x", slowest)
+	}
+	for _, contract := range summary.Contracts {
+		if contract.Address == (common.Address{}) {
+			t.Errorf("least recently executed contract not dropped")
+		}
+	}
+}
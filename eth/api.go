@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -43,7 +44,13 @@ import (
 	"github.com/ethereum/go-ethereum/trie"
 )
 
-const defaultTraceTimeout = 5 * time.Second
+const (
+	defaultTraceTimeout = 5 * time.Second
+
+	// traceChunkSize is the number of structured logs sent in a single notification
+	// when streaming a transaction trace.
+	traceChunkSize = 1000
+)
 
 // PublicEthereumAPI provides an API to access Ethereum full node-related
 // information.
@@ -71,6 +78,26 @@ func (api *PublicEthereumAPI) Hashrate() hexutil.Uint64 {
 	return hexutil.Uint64(api.e.Miner().HashRate())
 }
 
+// BloomStatus describes the progress of the bloombits log filtering index.
+type BloomStatus struct {
+	SectionSize   hexutil.Uint64 `json:"sectionSize"`   // Number of blocks in a single index section
+	Sections      hexutil.Uint64 `json:"sections"`      // Number of sections indexed
+	KnownSections hexutil.Uint64 `json:"knownSections"` // Number of sections available for indexing
+	IndexedBlocks hexutil.Uint64 `json:"indexedBlocks"` // Number of blocks covered by the index
+}
+
+// BloomStatus returns the progress of the background bloombits indexer, which
+// accelerates log filtering over indexed block ranges.
+func (api *PublicEthereumAPI) BloomStatus() BloomStatus {
+	stored, known := api.e.bloomIndexer.Progress()
+	return BloomStatus{
+		SectionSize:   hexutil.Uint64(params.BloomBitsBlocks),
+		Sections:      hexutil.Uint64(stored),
+		KnownSections: hexutil.Uint64(known),
+		IndexedBlocks: hexutil.Uint64(stored * params.BloomBitsBlocks),
+	}
+}
+
 // PublicMinerAPI provides an API to control the miner.
 // It offers only methods that operate on data that pose no security risk when it is publicly accessible.
 type PublicMinerAPI struct {
@@ -80,10 +107,7 @@ type PublicMinerAPI struct {
 
 // NewPublicMinerAPI create a new PublicMinerAPI instance.
 func NewPublicMinerAPI(e *Ethereum) *PublicMinerAPI {
-	agent := miner.NewRemoteAgent(e.BlockChain(), e.Engine())
-	e.Miner().Register(agent)
-
-	return &PublicMinerAPI{e, agent}
+	return &PublicMinerAPI{e, e.remote}
 }
 
 // Mining returns an indication if this node is currently mining.
@@ -92,22 +116,24 @@ func (api *PublicMinerAPI) Mining() bool {
 }
 
 // SubmitWork can be used by external miner to submit their POW solution. It returns an indication if the work was
-// accepted. Note, this is not an indication if the provided work was valid!
-func (api *PublicMinerAPI) SubmitWork(nonce types.BlockNonce, solution, digest common.Hash) bool {
-	return api.agent.SubmitWork(nonce, digest, solution)
+// accepted. Note, this is not an indication if the provided work was valid! An optional identifier may be given
+// to track the statistics of the miner.
+func (api *PublicMinerAPI) SubmitWork(nonce types.BlockNonce, solution, digest common.Hash, id *common.Hash) bool {
+	return api.agent.SubmitWork(minerID(id), nonce, digest, solution)
 }
 
 // GetWork returns a work package for external miner. The work package consists of 3 strings
 // result[0], 32 bytes hex encoded current block header pow-hash
 // result[1], 32 bytes hex encoded seed hash used for DAG
 // result[2], 32 bytes hex encoded boundary condition ("target"), 2^256/difficulty
-func (api *PublicMinerAPI) GetWork() ([3]string, error) {
+// An optional identifier may be given to track the statistics of the miner.
+func (api *PublicMinerAPI) GetWork(id *common.Hash) ([3]string, error) {
 	if !api.e.IsMining() {
 		if err := api.e.StartMining(false); err != nil {
 			return [3]string{}, err
 		}
 	}
-	work, err := api.agent.GetWork()
+	work, err := api.agent.GetWork(minerID(id))
 	if err != nil {
 		return work, fmt.Errorf("mining not ready: %v", err)
 	}
@@ -118,10 +144,19 @@ func (api *PublicMinerAPI) GetWork() ([3]string, error) {
 // hash rate of all miners which submit work through this node. It accepts the miner hash rate and an identifier which
 // must be unique between nodes.
 func (api *PublicMinerAPI) SubmitHashrate(hashrate hexutil.Uint64, id common.Hash) bool {
-	api.agent.SubmitHashrate(id, uint64(hashrate))
+	api.agent.SubmitHashrate(id.Hex(), uint64(hashrate))
 	return true
 }
 
+// minerID converts an optional remote miner identifier into the one tracked by
+// the remote agent, anonymous miners being tracked together.
+func minerID(id *common.Hash) string {
+	if id == nil {
+		return ""
+	}
+	return id.Hex()
+}
+
 // PrivateMinerAPI provides private RPC methods to control the miner.
 // These methods can be abused by external users and must be considered insecure for use by untrusted users.
 type PrivateMinerAPI struct {
@@ -205,6 +240,65 @@ func (api *PrivateMinerAPI) GetHashrate() uint64 {
 	return uint64(api.e.miner.HashRate())
 }
 
+// RemoteMiners returns the statistics of the recently active external miners
+// fetching work from this node.
+func (api *PrivateMinerAPI) RemoteMiners() []*miner.RemoteMiner {
+	return api.e.remote.Miners()
+}
+
+// GetPolicy returns the block assembly rules currently followed by the miner.
+func (api *PrivateMinerAPI) GetPolicy() map[string]interface{} {
+	policy := api.e.Miner().Policy()
+	return map[string]interface{}{
+		"includeUncles": policy.IncludeUncles,
+		"maxUncleDepth": policy.MaxUncleDepth,
+		"txOrdering":    policy.TxOrdering.String(),
+		"minGasPrice":   (*hexutil.Big)(policy.MinGasPrice),
+	}
+}
+
+// SetIncludeUncles sets whether the miner should include uncles in its blocks.
+func (api *PrivateMinerAPI) SetIncludeUncles(include bool) (bool, error) {
+	policy := api.e.Miner().Policy()
+	policy.IncludeUncles = include
+	if err := api.e.Miner().SetPolicy(policy); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SetUncleDepth sets the maximum distance of the uncles the miner includes.
+func (api *PrivateMinerAPI) SetUncleDepth(depth int) (bool, error) {
+	policy := api.e.Miner().Policy()
+	policy.MaxUncleDepth = depth
+	if err := api.e.Miner().SetPolicy(policy); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SetTxOrdering sets the strategy the miner orders pending transactions with,
+// which may be "price+nonce", "price" or "fifo".
+func (api *PrivateMinerAPI) SetTxOrdering(ordering miner.TxOrdering) (bool, error) {
+	policy := api.e.Miner().Policy()
+	policy.TxOrdering = ordering
+	if err := api.e.Miner().SetPolicy(policy); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SetMinGasPrice sets the minimum gas price of the transactions the miner includes
+// in its blocks, regardless of the transaction pool acceptance rules.
+func (api *PrivateMinerAPI) SetMinGasPrice(gasPrice hexutil.Big) (bool, error) {
+	policy := api.e.Miner().Policy()
+	policy.MinGasPrice = new(big.Int).Set((*big.Int)(&gasPrice))
+	if err := api.e.Miner().SetPolicy(policy); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // PrivateAdminAPI is the collection of Ethereum full node-related APIs
 // exposed over the private admin endpoint.
 type PrivateAdminAPI struct {
@@ -239,17 +333,23 @@ func (api *PrivateAdminAPI) ExportChain(file string) (bool, error) {
 	return true, nil
 }
 
-func hasAllBlocks(chain *core.BlockChain, bs []*types.Block) bool {
-	for _, b := range bs {
-		if !chain.HasBlock(b.Hash(), b.NumberU64()) {
-			return false
+// knownBlocks returns the number of leading blocks in bs that are already part of
+// the canonical chain, and as such don't need to be imported again.
+func knownBlocks(chain *core.BlockChain, bs []*types.Block) int {
+	head := chain.CurrentBlock().NumberU64()
+	for i, b := range bs {
+		if b.NumberU64() > head {
+			return i
+		}
+		if canon := chain.GetHeaderByNumber(b.NumberU64()); canon == nil || canon.Hash() != b.Hash() {
+			return i
 		}
 	}
-
-	return true
+	return len(bs)
 }
 
-// ImportChain imports a blockchain from a local file.
+// ImportChain imports a blockchain from a local file. Blocks already present in
+// the canonical chain are skipped, allowing an interrupted import to be resumed.
 func (api *PrivateAdminAPI) ImportChain(file string) (bool, error) {
 	// Make sure the can access the file to import
 	in, err := os.Open(file)
@@ -285,13 +385,11 @@ func (api *PrivateAdminAPI) ImportChain(file string) (bool, error) {
 			break
 		}
 
-		if hasAllBlocks(api.eth.BlockChain(), blocks) {
-			blocks = blocks[:0]
-			continue
-		}
-		// Import the batch and reset the buffer
-		if _, err := api.eth.BlockChain().InsertChain(blocks); err != nil {
-			return false, fmt.Errorf("batch %d: failed to insert: %v", batch, err)
+		// Import the unknown part of the batch and reset the buffer
+		if known := knownBlocks(api.eth.BlockChain(), blocks); known < len(blocks) {
+			if _, err := api.eth.BlockChain().InsertChain(blocks[known:]); err != nil {
+				return false, fmt.Errorf("batch %d: failed to insert: %v", batch, err)
+			}
 		}
 		blocks = blocks[:0]
 	}
@@ -320,9 +418,12 @@ func (api *PublicDebugAPI) DumpBlock(blockNr rpc.BlockNumber) (state.Dump, error
 		return stateDb.RawDump(), nil
 	}
 	var block *types.Block
-	if blockNr == rpc.LatestBlockNumber {
+	switch blockNr {
+	case rpc.LatestBlockNumber:
 		block = api.eth.blockchain.CurrentBlock()
-	} else {
+	case rpc.SafeBlockNumber:
+		block = api.eth.blockchain.SafeBlock()
+	default:
 		block = api.eth.blockchain.GetBlockByNumber(uint64(blockNr))
 	}
 	if block == nil {
@@ -348,6 +449,11 @@ func NewPrivateDebugAPI(config *params.ChainConfig, eth *Ethereum) *PrivateDebug
 	return &PrivateDebugAPI{config: config, eth: eth}
 }
 
+// CompactDatabase flattens the entire chain database, blocking until done.
+func (api *PrivateDebugAPI) CompactDatabase() error {
+	return api.eth.CompactDatabase()
+}
+
 // BlockTraceResult is the returned value when replaying a block to check for
 // consensus results and full VM trace logs for all included transactions.
 type BlockTraceResult struct {
@@ -400,6 +506,8 @@ func (api *PrivateDebugAPI) TraceBlockByNumber(blockNr rpc.BlockNumber, config *
 		block = api.eth.miner.PendingBlock()
 	case rpc.LatestBlockNumber:
 		block = api.eth.blockchain.CurrentBlock()
+	case rpc.SafeBlockNumber:
+		block = api.eth.blockchain.SafeBlock()
 	default:
 		block = api.eth.blockchain.GetBlockByNumber(uint64(blockNr))
 	}
@@ -483,33 +591,11 @@ func (t *timeoutError) Error() string {
 // TraceTransaction returns the structured logs created during the execution of EVM
 // and returns them as a JSON object.
 func (api *PrivateDebugAPI) TraceTransaction(ctx context.Context, txHash common.Hash, config *TraceArgs) (interface{}, error) {
-	var tracer vm.Tracer
-	if config != nil && config.Tracer != nil {
-		timeout := defaultTraceTimeout
-		if config.Timeout != nil {
-			var err error
-			if timeout, err = time.ParseDuration(*config.Timeout); err != nil {
-				return nil, err
-			}
-		}
-
-		var err error
-		if tracer, err = ethapi.NewJavascriptTracer(*config.Tracer); err != nil {
-			return nil, err
-		}
-
-		// Handle timeouts and RPC cancellations
-		deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
-		go func() {
-			<-deadlineCtx.Done()
-			tracer.(*ethapi.JavascriptTracer).Stop(&timeoutError{})
-		}()
-		defer cancel()
-	} else if config == nil {
-		tracer = vm.NewStructLogger(nil)
-	} else {
-		tracer = vm.NewStructLogger(config.LogConfig)
+	tracer, cancel, err := newTracer(ctx, config)
+	if err != nil {
+		return nil, err
 	}
+	defer cancel()
 
 	// Retrieve the tx from the chain and the containing block
 	tx, blockHash, _, txIndex := core.GetTransaction(api.eth.ChainDb(), txHash)
@@ -520,6 +606,9 @@ func (api *PrivateDebugAPI) TraceTransaction(ctx context.Context, txHash common.
 	if err != nil {
 		return nil, err
 	}
+	if tracer, ok := tracer.(*ethapi.JavascriptTracer); ok {
+		tracer.SetStateDB(statedb.Copy())
+	}
 
 	// Run the transaction with tracing enabled.
 	vmenv := vm.NewEVM(context, statedb, api.config, vm.Config{Debug: true, Tracer: tracer})
@@ -527,6 +616,144 @@ func (api *PrivateDebugAPI) TraceTransaction(ctx context.Context, txHash common.
 	if err != nil {
 		return nil, fmt.Errorf("tracing failed: %v", err)
 	}
+	return traceResult(tracer, ret, gas, failed)
+}
+
+// VMStats returns the execution statistics collected during block processing:
+// the number of executions of each opcode, the gas spent by opcode class and the
+// contracts taking the most time to execute (at most limit, 100 by default).
+func (api *PrivateDebugAPI) VMStats(limit *int) (*vm.StatsSummary, error) {
+	if api.eth.vmStats == nil {
+		return nil, errors.New("vm statistics not enabled (--vmstats)")
+	}
+	if limit == nil {
+		return api.eth.vmStats.Summary(100), nil
+	}
+	return api.eth.vmStats.Summary(*limit), nil
+}
+
+// ResetVMStats clears the execution statistics collected during block processing.
+func (api *PrivateDebugAPI) ResetVMStats() error {
+	if api.eth.vmStats == nil {
+		return errors.New("vm statistics not enabled (--vmstats)")
+	}
+	api.eth.vmStats.Reset()
+	return nil
+}
+
+// TraceChunk is a single notification of a streamed transaction trace. Chunks of
+// structured logs are sent as the transaction executes, followed by a last one
+// containing either the result of the execution or the error aborting it.
+type TraceChunk struct {
+	StructLogs []ethapi.StructLogRes   `json:"structLogs,omitempty"`
+	Result     *ethapi.ExecutionResult `json:"result,omitempty"`
+	Error      string                  `json:"error,omitempty"`
+}
+
+// TraceTransactionStream creates a subscription streaming the structured logs of
+// a transaction's execution in chunks as they are produced, instead of buffering
+// the entire trace in memory. Unsubscribing aborts the execution.
+func (api *PrivateDebugAPI) TraceTransactionStream(ctx context.Context, txHash common.Hash, config *vm.LogConfig) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	// Retrieve the tx from the chain and the containing block
+	tx, blockHash, _, txIndex := core.GetTransaction(api.eth.ChainDb(), txHash)
+	if tx == nil {
+		return nil, fmt.Errorf("transaction %x not found", txHash)
+	}
+	msg, context, statedb, err := api.computeTxEnv(blockHash, int(txIndex))
+	if err != nil {
+		return nil, err
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		logger := vm.NewStreamingStructLogger(config, traceChunkSize, func(logs []vm.StructLog) {
+			notifier.Notify(rpcSub.ID, &TraceChunk{StructLogs: ethapi.FormatLogs(logs)})
+		})
+		vmenv := vm.NewEVM(context, statedb, api.config, vm.Config{Debug: true, Tracer: logger})
+
+		// Abort the execution if the subscriber goes away
+		done := make(chan struct{})
+		defer close(done)
+
+		go func() {
+			select {
+			case <-rpcSub.Err():
+				vmenv.Cancel()
+			case <-notifier.Closed():
+				vmenv.Cancel()
+			case <-done:
+			}
+		}()
+		// Run the transaction, streaming the logs
+		ret, gas, failed, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(tx.Gas()))
+		logger.Flush()
+
+		if err != nil {
+			notifier.Notify(rpcSub.ID, &TraceChunk{Error: fmt.Sprintf("tracing failed: %v", err)})
+			return
+		}
+		notifier.Notify(rpcSub.ID, &TraceChunk{Result: &ethapi.ExecutionResult{
+			Gas:         gas,
+			Failed:      failed,
+			ReturnValue: fmt.Sprintf("%x", ret),
+		}})
+	}()
+	return rpcSub, nil
+}
+
+// TraceCall executes an eth_call style message on top of the requested block's
+// state with tracing enabled, returning the structured logs (or the result of
+// the custom tracer) without creating a transaction.
+func (api *PrivateDebugAPI) TraceCall(ctx context.Context, args ethapi.CallArgs, blockNr rpc.BlockNumber, config *TraceArgs) (interface{}, error) {
+	tracer, cancel, err := newTracer(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	ret, gas, failed, err := ethapi.DoCall(ctx, api.eth.ApiBackend, args, blockNr, vm.Config{Debug: true, Tracer: tracer}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("tracing failed: %v", err)
+	}
+	return traceResult(tracer, ret, gas, failed)
+}
+
+// newTracer creates the tracer requested by the trace config: a Javascript one if
+// tracer code is given (stopped on timeout or when the returned cancel function is
+// called), or the default struct logger otherwise.
+func newTracer(ctx context.Context, config *TraceArgs) (vm.Tracer, context.CancelFunc, error) {
+	if config == nil {
+		return vm.NewStructLogger(nil), func() {}, nil
+	}
+	if config.Tracer == nil {
+		return vm.NewStructLogger(config.LogConfig), func() {}, nil
+	}
+	timeout := defaultTraceTimeout
+	if config.Timeout != nil {
+		var err error
+		if timeout, err = time.ParseDuration(*config.Timeout); err != nil {
+			return nil, nil, err
+		}
+	}
+	tracer, err := ethapi.NewJavascriptTracer(*config.Tracer)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Handle timeouts and RPC cancellations
+	deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
+	go func() {
+		<-deadlineCtx.Done()
+		tracer.Stop(&timeoutError{})
+	}()
+	return tracer, cancel, nil
+}
+
+// traceResult assembles the RPC result of a traced execution.
+func traceResult(tracer vm.Tracer, ret []byte, gas *big.Int, failed bool) (interface{}, error) {
 	switch tracer := tracer.(type) {
 	case *vm.StructLogger:
 		return &ethapi.ExecutionResult{
@@ -586,8 +813,8 @@ func (api *PrivateDebugAPI) Preimage(ctx context.Context, hash common.Hash) (hex
 	return db.Get(hash.Bytes())
 }
 
-// GetBadBLocks returns a list of the last 'bad blocks' that the client has seen on the network
-// and returns them as a JSON list of block-hashes
+// GetBadBlocks returns a list of the last 'bad blocks' that the client has seen on the network
+// along with their RLP encoding, the locally computed receipts and the rejection reason.
 func (api *PrivateDebugAPI) GetBadBlocks(ctx context.Context) ([]core.BadBlockArgs, error) {
 	return api.eth.BlockChain().BadBlocks()
 }
@@ -697,11 +924,11 @@ func (api *PrivateDebugAPI) getModifiedAccounts(startBlock, endBlock *types.Bloc
 		return nil, fmt.Errorf("start block height (%d) must be less than end block height (%d)", startBlock.Number().Uint64(), endBlock.Number().Uint64())
 	}
 
-	oldTrie, err := trie.NewSecure(startBlock.Root(), api.eth.chainDb, 0)
+	oldTrie, err := trie.NewSecure(startBlock.Root(), api.eth.blockchain.TrieDB(), 0)
 	if err != nil {
 		return nil, err
 	}
-	newTrie, err := trie.NewSecure(endBlock.Root(), api.eth.chainDb, 0)
+	newTrie, err := trie.NewSecure(endBlock.Root(), api.eth.blockchain.TrieDB(), 0)
 	if err != nil {
 		return nil, err
 	}
@@ -17,13 +17,23 @@
 package eth
 
 import (
+	"context"
+	"math/big"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 var dumper = spew.ConfigState{Indent: "    "}
@@ -86,3 +96,68 @@ func TestStorageRangeAt(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the streamed trace of a transaction executing a single opcode arrives
+// in full, even though the trace is done before the subscription is activated.
+func TestTraceTransactionStream(t *testing.T) {
+	var (
+		db, _    = ethdb.NewMemDatabase()
+		contract = common.Address{0xc0}
+		gspec    = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				testBank: {Balance: big.NewInt(1000000)},
+				contract: {Code: []byte{byte(vm.STOP)}, Balance: new(big.Int)},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+	)
+	tx, _ := types.SignTx(types.NewTransaction(0, contract, new(big.Int), big.NewInt(30000), big.NewInt(1), nil), types.HomesteadSigner{}, testBankKey)
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, db, 1, func(i int, block *core.BlockGen) {
+		block.AddTx(tx)
+	})
+	blockchain, _ := core.NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer blockchain.Stop()
+	if _, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("debug", NewPrivateDebugAPI(gspec.Config, &Ethereum{chainDb: db, blockchain: blockchain})); err != nil {
+		t.Fatalf("failed to register debug API: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	chunks := make(chan *TraceChunk)
+	sub, err := client.Subscribe(context.Background(), "debug", chunks, "traceTransactionStream", tx.Hash())
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	var logs []ethapi.StructLogRes
+	for {
+		select {
+		case chunk := <-chunks:
+			if chunk.Error != "" {
+				t.Fatalf("tracing failed: %s", chunk.Error)
+			}
+			logs = append(logs, chunk.StructLogs...)
+			if chunk.Result == nil {
+				continue
+			}
+			if len(logs) != 1 || logs[0].Op != vm.STOP.String() {
+				t.Fatalf("streamed logs mismatch: have %v, want a single STOP", logs)
+			}
+			if chunk.Result.Failed {
+				t.Errorf("execution reported as failed")
+			}
+			return
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-time.After(time.Second):
+			t.Fatalf("trace stream incomplete, %d logs received", len(logs))
+		}
+	}
+}
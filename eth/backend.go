@@ -21,9 +21,11 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"path/filepath"
 	"runtime"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
@@ -41,6 +43,7 @@ import (
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/light"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/node"
@@ -55,6 +58,7 @@ type LesServer interface {
 	Stop()
 	Protocols() []p2p.Protocol
 	SetBloomBitsIndexer(bbIndexer *core.ChainIndexer)
+	APIs() []rpc.API
 }
 
 // Ethereum implements the Ethereum full node service.
@@ -69,11 +73,14 @@ type Ethereum struct {
 	// Handlers
 	txPool          *core.TxPool
 	blockchain      *core.BlockChain
+	vmStats         *vm.Stats // Execution statistics of block processing (nil = disabled)
 	protocolManager *ProtocolManager
 	lesServer       LesServer
 
 	// DB interfaces
-	chainDb ethdb.Database // Block chain database
+	chainDb       ethdb.Database    // Block chain database
+	compactWindow *compactionWindow // Daily window to compact the database in (nil = unscheduled)
+	compacting    int32             // Flag whether a database compaction is running (atomic)
 
 	eventMux       *event.TypeMux
 	engine         consensus.Engine
@@ -82,9 +89,13 @@ type Ethereum struct {
 	bloomRequests chan chan *bloombits.Retrieval // Channel receiving bloom data retrieval requests
 	bloomIndexer  *core.ChainIndexer             // Bloom indexer operating during block imports
 
+	chtIndexer, bloomTrieIndexer *core.ChainIndexer // Light client helper trie indexers of non-serving nodes (nil = disabled)
+
 	ApiBackend *EthApiBackend
 
 	miner     *miner.Miner
+	remote    *miner.RemoteAgent   // Sealer handing out work to external miners
+	stratum   *miner.StratumServer // Push based work distribution to mining farms (nil = disabled)
 	gasPrice  *big.Int
 	etherbase common.Address
 
@@ -108,12 +119,23 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	if !config.SyncMode.IsValid() {
 		return nil, fmt.Errorf("invalid sync mode %d", config.SyncMode)
 	}
+	var compactWindow *compactionWindow
+	if config.DatabaseCompaction != "" {
+		window, err := parseCompactionWindow(config.DatabaseCompaction)
+		if err != nil {
+			return nil, err
+		}
+		compactWindow = window
+	}
 	chainDb, err := CreateDB(ctx, config, "chaindata")
 	if err != nil {
 		return nil, err
 	}
 	stopDbUpgrade := upgradeDeduplicateData(chainDb)
-	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlock(chainDb, config.Genesis)
+	if err := core.CheckNetworkId(chainDb, config.Genesis, config.NetworkId); err != nil {
+		return nil, err
+	}
+	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlockWithOverride(chainDb, config.Genesis, config.OverrideByzantium)
 	if _, ok := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !ok {
 		return nil, genesisErr
 	}
@@ -133,6 +155,7 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		etherbase:      config.Etherbase,
 		bloomRequests:  make(chan chan *bloombits.Retrieval),
 		bloomIndexer:   NewBloomIndexer(chainDb, params.BloomBitsBlocks),
+		compactWindow:  compactWindow,
 	}
 
 	log.Info("Initialising Ethereum protocol", "versions", ProtocolVersions, "network", config.NetworkId)
@@ -145,11 +168,19 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		core.WriteBlockChainVersion(chainDb, core.BlockChainVersion)
 	}
 
-	vmConfig := vm.Config{EnablePreimageRecording: config.EnablePreimageRecording}
-	eth.blockchain, err = core.NewBlockChain(chainDb, eth.chainConfig, eth.engine, vmConfig)
+	var (
+		vmConfig    = vm.Config{EnablePreimageRecording: config.EnablePreimageRecording, ProfileLabels: config.VMProfileLabels}
+		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, Preimages: config.Preimages, TrieNodeLimit: config.TrieCache, TrieTimeLimit: 5 * time.Minute, TxLookupLimit: config.TxLookupLimit, CodeCache: config.CodeCache, BlockCache: config.BlockCache, Snapshots: config.StateSnapshots}
+	)
+	if config.VMStats {
+		eth.vmStats = vm.NewStats()
+		vmConfig.Stats = eth.vmStats
+	}
+	eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, eth.chainConfig, eth.engine, vmConfig)
 	if err != nil {
 		return nil, err
 	}
+	eth.blockchain.SetSafeDepth(config.SafeDepth)
 	// Rewind the chain in case of an incompatible config upgrade.
 	if compat, ok := genesisErr.(*params.ConfigCompatError); ok {
 		log.Warn("Rewinding chain to upgrade configuration", "err", compat)
@@ -158,6 +189,15 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	}
 	eth.bloomIndexer.Start(eth.blockchain)
 
+	// Generate the light client helper tries if requested. LES servers run their
+	// own indexers over the same tables, so only start them if not serving.
+	if config.LightIndex && config.LightServ == 0 {
+		eth.chtIndexer = light.NewChtIndexer(chainDb, false)
+		eth.bloomTrieIndexer = light.NewBloomTrieIndexer(chainDb, false)
+		eth.bloomIndexer.AddChildIndexer(eth.bloomTrieIndexer)
+		eth.chtIndexer.Start(eth.blockchain)
+	}
+
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = ctx.ResolvePath(config.TxPool.Journal)
 	}
@@ -166,10 +206,16 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	if eth.protocolManager, err = NewProtocolManager(eth.chainConfig, config.SyncMode, config.NetworkId, eth.eventMux, eth.txPool, eth.engine, eth.blockchain, chainDb); err != nil {
 		return nil, err
 	}
+	eth.protocolManager.serveLimit = newServeLimiter(config.ServeLimit)
+	eth.protocolManager.noServeState = config.NoServeState
+	eth.protocolManager.downloader.SetWhitelist(config.Whitelist)
+	eth.blockchain.SetWhitelist(config.Whitelist)
 	eth.miner = miner.New(eth, eth.chainConfig, eth.EventMux(), eth.engine)
 	eth.miner.SetExtra(makeExtraData(config.ExtraData))
+	eth.remote = miner.NewRemoteAgent(eth.blockchain, eth.engine)
+	eth.miner.Register(eth.remote)
 
-	eth.ApiBackend = &EthApiBackend{eth, nil, nil}
+	eth.ApiBackend = &EthApiBackend{eth, nil, nil, ethapi.NewCallPool(config.RPCCallWorkers)}
 	gpoParams := config.GPO
 	if gpoParams.Default == nil {
 		gpoParams.Default = config.GasPrice
@@ -205,7 +251,26 @@ func CreateDB(ctx *node.ServiceContext, config *Config, name string) (ethdb.Data
 	if db, ok := db.(*ethdb.LDBDatabase); ok {
 		db.Meter("eth/db/chaindata/")
 	}
-	return db, nil
+	// Move ancient chain segments into the freezer, unless running ephemerally or
+	// as a light client (no bodies and receipts to freeze)
+	if config.SyncMode == downloader.LightSync {
+		return db, nil
+	}
+	freezer := config.DatabaseFreezer
+	if freezer == "" {
+		freezer = ctx.ResolvePath(filepath.Join(name, "ancient"))
+	} else if !filepath.IsAbs(freezer) {
+		freezer = ctx.ResolvePath(freezer)
+	}
+	if freezer == "" {
+		return db, nil
+	}
+	frdb, err := core.NewDatabaseWithFreezer(db, freezer)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return frdb, nil
 }
 
 // CreateConsensusEngine creates the required type of consensus engine instance for an Ethereum service
@@ -241,6 +306,11 @@ func (s *Ethereum) APIs() []rpc.API {
 	// Append any APIs exposed explicitly by the consensus engine
 	apis = append(apis, s.engine.APIs(s.BlockChain())...)
 
+	// Append the APIs of the light server, if running
+	if s.lesServer != nil {
+		apis = append(apis, s.lesServer.APIs()...)
+	}
+
 	// Append all the local APIs and return
 	return append(apis, []rpc.API{
 		{
@@ -331,7 +401,16 @@ func (s *Ethereum) StartMining(local bool) error {
 			log.Error("Etherbase account unavailable locally", "err", err)
 			return fmt.Errorf("signer missing: %v", err)
 		}
-		clique.Authorize(eb, wallet.SignHash)
+		clique.Authorize(eb, func(account accounts.Account, hash []byte) ([]byte, error) {
+			sig, err := wallet.SignHash(account, hash)
+			if err != nil {
+				return nil, err
+			}
+			if err := s.accountManager.Audit(account.Address, "clique", accounts.AuditHash, common.BytesToHash(hash)); err != nil {
+				return nil, err
+			}
+			return sig, nil
+		})
 	}
 	if local {
 		// If local (CPU) mining is started, we can disable the transaction rejection
@@ -368,12 +447,52 @@ func (s *Ethereum) Protocols() []p2p.Protocol {
 	return append(s.protocolManager.SubProtocols, s.lesServer.Protocols()...)
 }
 
+// HealthStatus is the health of the Ethereum protocol, reported on the node's
+// health endpoints.
+type HealthStatus struct {
+	Syncing      bool   `json:"syncing"`
+	CurrentBlock uint64 `json:"currentBlock"`
+	HighestBlock uint64 `json:"highestBlock"`
+	BlockAge     uint64 `json:"blockAge"` // Seconds since the head block was sealed
+	Peers        int    `json:"peers"`
+}
+
+// NewHealthStatus assembles the health of a chain with the given head, synced by
+// the given downloader.
+func NewHealthStatus(d *downloader.Downloader, head *types.Header, peers int) *HealthStatus {
+	status := &HealthStatus{
+		Syncing:      d.Synchronising(),
+		CurrentBlock: head.Number.Uint64(),
+		HighestBlock: d.Progress().HighestBlock,
+		Peers:        peers,
+	}
+	if age := time.Now().Unix() - head.Time.Int64(); age > 0 {
+		status.BlockAge = uint64(age)
+	}
+	if status.HighestBlock < status.CurrentBlock {
+		status.HighestBlock = status.CurrentBlock
+	}
+	return status
+}
+
+// Health implements node.HealthReporter. The protocol is ready once the initial
+// sync finished and there are peers to keep up with the network.
+func (s *Ethereum) Health() (interface{}, bool) {
+	status := NewHealthStatus(s.protocolManager.downloader, s.blockchain.CurrentBlock().Header(), s.protocolManager.peers.Len())
+	return status, atomic.LoadUint32(&s.protocolManager.acceptTxs) == 1 && status.Peers > 0
+}
+
 // Start implements node.Service, starting all internal goroutines needed by the
 // Ethereum protocol implementation.
 func (s *Ethereum) Start(srvr *p2p.Server) error {
 	// Start the bloom bits servicing goroutines
 	s.startBloomHandlers()
 
+	// Start the database maintenance scheduler if requested
+	if s.compactWindow != nil {
+		go s.scheduleCompactions(s.compactWindow)
+	}
+
 	// Start the RPC service
 	s.netRPCService = ethapi.NewPublicNetAPI(srvr, s.NetVersion())
 
@@ -390,6 +509,14 @@ func (s *Ethereum) Start(srvr *p2p.Server) error {
 	if s.lesServer != nil {
 		s.lesServer.Start(srvr)
 	}
+	// Start serving work to external mining farms if requested
+	if s.config.MinerStratum != "" {
+		stratum, err := miner.NewStratumServer(s.remote, s.config.MinerStratum)
+		if err != nil {
+			return err
+		}
+		s.stratum = stratum
+	}
 	return nil
 }
 
@@ -399,14 +526,23 @@ func (s *Ethereum) Stop() error {
 	if s.stopDbUpgrade != nil {
 		s.stopDbUpgrade()
 	}
-	s.bloomIndexer.Close()
-	s.blockchain.Stop()
+	// Stop the inflow of blocks and transactions from the network and the miner
 	s.protocolManager.Stop()
 	if s.lesServer != nil {
 		s.lesServer.Stop()
 	}
-	s.txPool.Stop()
+	if s.stratum != nil {
+		s.stratum.Close()
+	}
 	s.miner.Stop()
+
+	// Flush the indexes, the state caches and the transaction journal to disk
+	s.bloomIndexer.Close()
+	if s.chtIndexer != nil {
+		s.chtIndexer.Close() // bloom trie indexer is closed by parent bloombits indexer
+	}
+	s.blockchain.Stop()
+	s.txPool.Stop()
 	s.eventMux.Stop()
 
 	s.chainDb.Close()
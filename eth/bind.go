@@ -18,12 +18,16 @@ package eth
 
 import (
 	"context"
+	"errors"
 	"math/big"
+	"sync"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -40,16 +44,30 @@ type ContractBackend struct {
 	eapi  *ethapi.PublicEthereumAPI        // Wrapper around the Ethereum object to access metadata
 	bcapi *ethapi.PublicBlockChainAPI      // Wrapper around the blockchain to access chain data
 	txapi *ethapi.PublicTransactionPoolAPI // Wrapper around the transaction pool to access transaction data
+
+	fbackend filters.Backend      // Backend to filter logs with, nil if unsupported
+	light    bool                 // Whether the backend is a light client's
+	events   *filters.EventSystem // Event system for live log filtering, created on first use
+	once     sync.Once            // Ensures the event system is created only once
 }
 
+// errLogFilteringUnsupported is returned if log filtering is requested from a
+// backend that cannot filter logs.
+var errLogFilteringUnsupported = errors.New("log filtering not supported by backend")
+
 // NewContractBackend creates a new native contract backend using an existing
 // Ethereum object.
 func NewContractBackend(apiBackend ethapi.Backend) *ContractBackend {
-	return &ContractBackend{
+	backend := &ContractBackend{
 		eapi:  ethapi.NewPublicEthereumAPI(apiBackend),
 		bcapi: ethapi.NewPublicBlockChainAPI(apiBackend),
 		txapi: ethapi.NewPublicTransactionPoolAPI(apiBackend, new(ethapi.AddrLocker)),
 	}
+	if fbackend, ok := apiBackend.(filters.Backend); ok {
+		_, full := apiBackend.(*EthApiBackend)
+		backend.fbackend, backend.light = fbackend, !full
+	}
+	return backend
 }
 
 // CodeAt retrieves any code associated with the contract from the local API.
@@ -66,7 +84,7 @@ func (b *ContractBackend) PendingCodeAt(ctx context.Context, contract common.Add
 // call with the specified data as the input. The pending flag requests execution
 // against the pending block, not the stable head of the chain.
 func (b *ContractBackend) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNum *big.Int) ([]byte, error) {
-	out, err := b.bcapi.Call(ctx, toCallArgs(msg), toBlockNumber(blockNum))
+	out, err := b.bcapi.Call(ctx, toCallArgs(msg), toBlockNumber(blockNum), nil)
 	return out, err
 }
 
@@ -74,7 +92,7 @@ func (b *ContractBackend) CallContract(ctx context.Context, msg ethereum.CallMsg
 // call with the specified data as the input. The pending flag requests execution
 // against the pending block, not the stable head of the chain.
 func (b *ContractBackend) PendingCallContract(ctx context.Context, msg ethereum.CallMsg) ([]byte, error) {
-	out, err := b.bcapi.Call(ctx, toCallArgs(msg), rpc.PendingBlockNumber)
+	out, err := b.bcapi.Call(ctx, toCallArgs(msg), rpc.PendingBlockNumber, nil)
 	return out, err
 }
 
@@ -125,7 +143,7 @@ func (b *ContractBackend) SuggestGasPrice(ctx context.Context) (*big.Int, error)
 // requirement as other transactions may be added or removed by miners, but it
 // should provide a basis for setting a reasonable default.
 func (b *ContractBackend) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (*big.Int, error) {
-	out, err := b.bcapi.EstimateGas(ctx, toCallArgs(msg))
+	out, err := b.bcapi.EstimateGas(ctx, toCallArgs(msg), nil)
 	return out.ToInt(), err
 }
 
@@ -136,3 +154,67 @@ func (b *ContractBackend) SendTransaction(ctx context.Context, tx *types.Transac
 	_, err := b.txapi.SendRawTransaction(ctx, raw)
 	return err
 }
+
+// FilterLogs implements bind.ContractFilterer executing a log filter operation,
+// blocking during execution and returning all the results in one batch.
+func (b *ContractBackend) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	if b.fbackend == nil {
+		return nil, errLogFilteringUnsupported
+	}
+	// Unset filter boundaries run from genesis to the chain head
+	from, to := int64(0), int64(rpc.LatestBlockNumber)
+	if query.FromBlock != nil {
+		from = query.FromBlock.Int64()
+	}
+	if query.ToBlock != nil {
+		to = query.ToBlock.Int64()
+	}
+	logs, err := filters.New(b.fbackend, from, to, query.Addresses, query.Topics).Logs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]types.Log, len(logs))
+	for i, log := range logs {
+		res[i] = *log
+	}
+	return res, nil
+}
+
+// SubscribeFilterLogs implements bind.ContractFilterer creating a background log
+// filtering operation, streaming the newly found logs into the given channel.
+func (b *ContractBackend) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	if b.fbackend == nil {
+		return nil, errLogFilteringUnsupported
+	}
+	b.once.Do(func() {
+		b.events = filters.NewEventSystem(b.fbackend.EventMux(), b.fbackend, b.light)
+	})
+	sink := make(chan []*types.Log)
+
+	sub, err := b.events.SubscribeLogs(filters.FilterCriteria(query), sink)
+	if err != nil {
+		return nil, err
+	}
+	// Logs arrive in batches, flatten them into a plain stream
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case logs := <-sink:
+				for _, log := range logs {
+					select {
+					case ch <- *log:
+					case err := <-sub.Err():
+						return err
+					case <-quit:
+						return nil
+					}
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
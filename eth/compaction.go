@@ -0,0 +1,165 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+const (
+	// compactionChunks is the number of key ranges (by leading key byte) a full
+	// database compaction is split into, allowing progress to be reported.
+	compactionChunks = 256
+
+	// compactionReportInterval is the time interval to report compaction progress.
+	compactionReportInterval = 8 * time.Second
+
+	// compactionCheckInterval is the time interval to check whether the database
+	// maintenance window opened.
+	compactionCheckInterval = time.Minute
+)
+
+var (
+	compactionTimer      = metrics.NewTimer("eth/db/compaction/time")
+	compactionChunkMeter = metrics.NewMeter("eth/db/compaction/chunks")
+
+	// errCompactionRunning is returned if a compaction is requested while another
+	// one is still in progress.
+	errCompactionRunning = errors.New("database compaction already running")
+
+	// errCompactionUnsupported is returned if the chain database cannot be compacted.
+	errCompactionUnsupported = errors.New("database does not support compaction")
+)
+
+// compactionWindow is a daily time window in local time during which scheduled
+// database compactions may be started. Windows may wrap around midnight.
+type compactionWindow struct {
+	start, end time.Duration // Offsets of the window boundaries from midnight
+}
+
+// parseCompactionWindow parses a daily time window in the form of HH:MM-HH:MM.
+func parseCompactionWindow(spec string) (*compactionWindow, error) {
+	var sh, sm, eh, em int
+	if n, err := fmt.Sscanf(spec, "%d:%d-%d:%d", &sh, &sm, &eh, &em); n != 4 || err != nil {
+		return nil, fmt.Errorf("invalid compaction window %q, want HH:MM-HH:MM", spec)
+	}
+	for _, hm := range [][2]int{{sh, sm}, {eh, em}} {
+		if hm[0] < 0 || hm[0] > 23 || hm[1] < 0 || hm[1] > 59 {
+			return nil, fmt.Errorf("invalid time of day %02d:%02d in compaction window", hm[0], hm[1])
+		}
+	}
+	window := &compactionWindow{
+		start: time.Duration(sh)*time.Hour + time.Duration(sm)*time.Minute,
+		end:   time.Duration(eh)*time.Hour + time.Duration(em)*time.Minute,
+	}
+	if window.start == window.end {
+		return nil, fmt.Errorf("empty compaction window %q", spec)
+	}
+	return window, nil
+}
+
+// opened returns the time the window most recently opened at, if the given time
+// is inside the window.
+func (w *compactionWindow) opened(now time.Time) (time.Time, bool) {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	offset := now.Sub(midnight)
+
+	switch {
+	case w.start < w.end && offset >= w.start && offset < w.end:
+		return midnight.Add(w.start), true
+	case w.start > w.end && offset >= w.start:
+		return midnight.Add(w.start), true
+	case w.start > w.end && offset < w.end:
+		return midnight.AddDate(0, 0, -1).Add(w.start), true
+	}
+	return time.Time{}, false
+}
+
+// CompactDatabase flattens the entire chain database, blocking until done. Only
+// one compaction may run at any point in time.
+func (s *Ethereum) CompactDatabase() error {
+	compacter, ok := s.chainDb.(ethdb.Compacter)
+	if !ok {
+		return errCompactionUnsupported
+	}
+	if !atomic.CompareAndSwapInt32(&s.compacting, 0, 1) {
+		return errCompactionRunning
+	}
+	defer atomic.StoreInt32(&s.compacting, 0)
+
+	var (
+		start  = time.Now()
+		report = time.Now()
+	)
+	log.Info("Compacting chain database")
+	for i := 0; i < compactionChunks; i++ {
+		// Compact the next key range, the last one being open ended
+		from, to := []byte{byte(i)}, []byte{byte(i + 1)}
+		if i == 0 {
+			from = nil
+		}
+		if i == compactionChunks-1 {
+			to = nil
+		}
+		if err := compacter.Compact(from, to); err != nil {
+			log.Error("Database compaction failed", "err", err)
+			return err
+		}
+		compactionChunkMeter.Mark(1)
+
+		if time.Since(report) > compactionReportInterval {
+			log.Info("Compacting chain database", "progress", fmt.Sprintf("%.2f%%", float64(i+1)*100/compactionChunks), "elapsed", common.PrettyDuration(time.Since(start)))
+			report = time.Now()
+		}
+	}
+	compactionTimer.UpdateSince(start)
+	log.Info("Compacted chain database", "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// scheduleCompactions periodically checks whether the configured maintenance
+// window opened and compacts the database once within each window.
+func (s *Ethereum) scheduleCompactions(window *compactionWindow) {
+	ticker := time.NewTicker(compactionCheckInterval)
+	defer ticker.Stop()
+
+	var last time.Time // Time of the last scheduled compaction
+	for {
+		select {
+		case now := <-ticker.C:
+			opened, ok := window.opened(now)
+			if !ok || last.After(opened) {
+				continue
+			}
+			last = now
+			if err := s.CompactDatabase(); err != nil {
+				log.Warn("Scheduled database compaction failed", "err", err)
+			}
+
+		case <-s.shutdownChan:
+			return
+		}
+	}
+}
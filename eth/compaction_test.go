@@ -0,0 +1,104 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// Tests that compaction windows are parsed correctly and invalid ones rejected.
+func TestCompactionWindowParsing(t *testing.T) {
+	tests := []struct {
+		spec       string
+		start, end time.Duration
+		fail       bool
+	}{
+		{spec: "03:00-05:30", start: 3 * time.Hour, end: 5*time.Hour + 30*time.Minute},
+		{spec: "23:00-01:00", start: 23 * time.Hour, end: time.Hour},
+		{spec: "3:00-5:00", start: 3 * time.Hour, end: 5 * time.Hour},
+		{spec: "", fail: true},
+		{spec: "03:00", fail: true},
+		{spec: "24:00-01:00", fail: true},
+		{spec: "03:60-04:00", fail: true},
+		{spec: "03:00-03:00", fail: true},
+	}
+	for i, tt := range tests {
+		window, err := parseCompactionWindow(tt.spec)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("test %d: expected failure for %q, got %v", i, tt.spec, window)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: failed to parse %q: %v", i, tt.spec, err)
+			continue
+		}
+		if window.start != tt.start || window.end != tt.end {
+			t.Errorf("test %d: window mismatch: have %v-%v, want %v-%v", i, window.start, window.end, tt.start, tt.end)
+		}
+	}
+}
+
+// Tests that compaction windows report being open at the correct times, also
+// if they wrap around midnight.
+func TestCompactionWindowOpened(t *testing.T) {
+	day := func(d, h, m int) time.Time { return time.Date(2017, 12, d, h, m, 0, 0, time.UTC) }
+
+	tests := []struct {
+		spec   string
+		now    time.Time
+		opened time.Time
+		open   bool
+	}{
+		{spec: "03:00-05:00", now: day(10, 2, 59)},
+		{spec: "03:00-05:00", now: day(10, 3, 0), opened: day(10, 3, 0), open: true},
+		{spec: "03:00-05:00", now: day(10, 4, 59), opened: day(10, 3, 0), open: true},
+		{spec: "03:00-05:00", now: day(10, 5, 0)},
+		{spec: "23:00-01:00", now: day(10, 22, 59)},
+		{spec: "23:00-01:00", now: day(10, 23, 30), opened: day(10, 23, 0), open: true},
+		{spec: "23:00-01:00", now: day(11, 0, 30), opened: day(10, 23, 0), open: true},
+		{spec: "23:00-01:00", now: day(11, 1, 0)},
+	}
+	for i, tt := range tests {
+		window, err := parseCompactionWindow(tt.spec)
+		if err != nil {
+			t.Fatalf("test %d: failed to parse %q: %v", i, tt.spec, err)
+		}
+		opened, open := window.opened(tt.now)
+		if open != tt.open || !opened.Equal(tt.opened) {
+			t.Errorf("test %d: %q at %v: have (%v, %v), want (%v, %v)", i, tt.spec, tt.now, opened, open, tt.opened, tt.open)
+		}
+	}
+}
+
+// Tests that concurrent database compactions are rejected.
+func TestCompactDatabaseExclusive(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	eth := &Ethereum{chainDb: db}
+
+	if err := eth.CompactDatabase(); err != nil {
+		t.Fatalf("failed to compact database: %v", err)
+	}
+	eth.compacting = 1
+	if err := eth.CompactDatabase(); err != errCompactionRunning {
+		t.Fatalf("concurrent compaction error mismatch: have %v, want %v", err, errCompactionRunning)
+	}
+}
@@ -40,8 +40,11 @@ var DefaultConfig = Config{
 	EthashDatasetsInMem:  1,
 	EthashDatasetsOnDisk: 2,
 	NetworkId:            1,
+	SafeDepth:            params.MainnetSafeDepth,
 	LightPeers:           20,
+	LightCache:           256,
 	DatabaseCache:        128,
+	TrieCache:            256,
 	GasPrice:             big.NewInt(18 * params.Shannon),
 
 	TxPool: core.DefaultTxPoolConfig,
@@ -72,24 +75,70 @@ type Config struct {
 	// If nil, the Ethereum main net block is used.
 	Genesis *core.Genesis `toml:",omitempty"`
 
+	// Fork block overrides, mostly for testing upcoming fork transitions
+	OverrideByzantium *big.Int `toml:",omitempty"`
+
 	// Protocol options
 	NetworkId uint64 // Network ID to use for selecting peers to connect to
 	SyncMode  downloader.SyncMode
 
+	// Garbage collection options
+	NoPruning bool // Whether to disable pruning and flush everything to disk (archive mode)
+	Preimages bool // Whether to record trie key preimages (always on in archive mode)
+
+	// Number of blocks below the head after which blocks are considered safe
+	SafeDepth uint64
+
+	// Transaction index options
+	TxLookupLimit uint64 `toml:",omitempty"` // Number of recent blocks to maintain transaction lookup entries for (0 = all)
+
+	// Whether to generate flat state snapshots at epoch blocks for serving
+	StateSnapshots bool `toml:",omitempty"`
+
 	// Light client options
 	LightServ  int `toml:",omitempty"` // Maximum percentage of time allowed for serving LES requests
 	LightPeers int `toml:",omitempty"` // Maximum number of LES client peers
+	LightCache int `toml:",omitempty"` // Megabytes of retrieved ODR data kept on disk by light clients (0 = unlimited)
+
+	// Generate the light client helper tries (CHT, bloom trie) even if not serving LES
+	LightIndex bool `toml:",omitempty"`
+
+	// Node IDs of the LES clients served with priority
+	LightPriority []string `toml:",omitempty"`
+
+	// Request cost served to each LES client before it needs to deposit credit (0 = no accounting)
+	LightQuota uint64 `toml:",omitempty"`
+
+	// Address LES clients deposit their payments to, and the wei to deposit per unit of request cost
+	LightPayee common.Address `toml:",omitempty"`
+	LightPrice *big.Int       `toml:",omitempty"`
+
+	// Chain data serving options
+	ServeLimit   int  `toml:",omitempty"` // Maximum number of headers, bodies, receipts and state entries served to each peer per second (0 = unlimited)
+	NoServeState bool `toml:",omitempty"` // Refuse serving state trie data (GetNodeData) to peers
+
+	// Trusted checkpoint to bootstrap light client header sync from (nil = network default)
+	Checkpoint *params.TrustedCheckpoint `toml:",omitempty"`
+
+	// Block hashes the synced chain is required to contain, keyed by block number
+	Whitelist map[uint64]common.Hash `toml:",omitempty"`
 
 	// Database options
 	SkipBcVersionCheck bool `toml:"-"`
 	DatabaseHandles    int  `toml:"-"`
 	DatabaseCache      int
+	DatabaseFreezer    string `toml:",omitempty"` // Directory for ancient chain data (default = chaindata/ancient)
+	DatabaseCompaction string `toml:",omitempty"` // Daily local time window to compact the database in (HH:MM-HH:MM)
+	TrieCache          int    // Memory allowance (MB) for in-memory trie nodes before flushing to disk
+	CodeCache          int    // Memory allowance (MB) for caching contract code (0 = disabled)
+	BlockCache         int    // Memory allowance (MB) for caching recent blocks, bodies and receipts (0 = default)
 
 	// Mining-related options
 	Etherbase    common.Address `toml:",omitempty"`
 	MinerThreads int            `toml:",omitempty"`
 	ExtraData    []byte         `toml:",omitempty"`
 	GasPrice     *big.Int
+	MinerStratum string `toml:",omitempty"` // Listening address of the stratum work server (empty = disabled)
 
 	// Ethash options
 	EthashCacheDir       string
@@ -108,6 +157,15 @@ type Config struct {
 	// Enables tracking of SHA3 preimages in the VM
 	EnablePreimageRecording bool
 
+	// Enables collecting VM execution statistics during block processing
+	VMStats bool
+
+	// Enables labelling CPU profiles with the executing contracts
+	VMProfileLabels bool
+
+	// Maximum number of RPC calls (eth_call, eth_estimateGas) executing at once (0 = number of CPUs)
+	RPCCallWorkers int `toml:",omitempty"`
+
 	// Miscellaneous options
 	DocRoot   string `toml:"-"`
 	PowFake   bool   `toml:"-"`
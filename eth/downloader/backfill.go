@@ -0,0 +1,222 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// headerBackfill is the state of the reverse header retrieval filling in the
+// chain history below a trusted checkpoint.
+type headerBackfill struct {
+	running int32 // Flag whether a backfill is running (atomic access)
+
+	pending   *backfillRequest     // Header request currently awaiting a response
+	deliverCh chan []*types.Header // Channel receiving the response to the pending request
+	lock      sync.Mutex           // Lock protecting the pending request
+}
+
+// backfillRequest identifies a reverse header request sent to a remote peer.
+type backfillRequest struct {
+	peer   string      // Identifier of the peer the request was sent to
+	origin common.Hash // Hash of the topmost header requested
+}
+
+// newHeaderBackfill creates the idle state of a reverse header backfill.
+func newHeaderBackfill() *headerBackfill {
+	return &headerBackfill{
+		deliverCh: make(chan []*types.Header, 1),
+	}
+}
+
+// Backfill starts retrieving the headers below the given trusted anchor in the
+// background, walking the chain in reverse down to the genesis block while forward
+// synchronisation proceeds from the anchor onwards. Headers are verified solely
+// by their hash links to the anchor, and their total difficulties are derived
+// from the one of the anchor. Progress is persisted, so an interrupted backfill
+// resumes from its lowest stored header. Calling it while a backfill is running
+// is a noop.
+func (d *Downloader) Backfill(anchor *types.Header) {
+	if !atomic.CompareAndSwapInt32(&d.backfill.running, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&d.backfill.running, 0)
+
+		if err := d.backfillHeaders(anchor); err != nil {
+			log.Warn("Header backfill failed", "err", err)
+		}
+	}()
+}
+
+// backfillHeaders retrieves and stores batches of headers in reverse, starting
+// from the parent of the anchor (or the lowest header of an interrupted backfill)
+// until the genesis block is reached.
+func (d *Downloader) backfillHeaders(anchor *types.Header) error {
+	// Resume from an interrupted backfill if it's below the anchor
+	tail := anchor
+	if number := core.GetHeaderBackfillTail(d.stateDB); number > 0 && number < anchor.Number.Uint64() {
+		if header := core.GetHeader(d.stateDB, core.GetCanonicalHash(d.stateDB, number), number); header != nil {
+			tail = header
+		}
+	}
+	td := core.GetTd(d.stateDB, tail.Hash(), tail.Number.Uint64())
+	if td == nil {
+		return fmt.Errorf("unknown total difficulty of header #%d [%x…]", tail.Number, tail.Hash().Bytes()[:4])
+	}
+	log.Info("Backfilling header history", "number", tail.Number, "hash", tail.Hash())
+
+	var (
+		failed = make(map[string]bool) // Peers failing to extend the current tail
+		start  = time.Now()
+		logged = time.Now()
+	)
+	for tail.Number.Sign() > 0 {
+		// Pick a peer not yet failing to extend the current tail
+		p := d.backfillPeer(failed)
+		if p == nil {
+			failed = make(map[string]bool)
+			select {
+			case <-time.After(time.Second):
+				continue
+			case <-d.quitCh:
+				return errCancelHeaderFetch
+			}
+		}
+		headers, err := d.requestBackfill(p, tail.ParentHash)
+		if err == errCancelHeaderFetch {
+			return err
+		}
+		if err != nil {
+			p.log.Debug("Header backfill request failed", "err", err)
+			failed[p.id] = true
+			continue
+		}
+		if len(headers) == 0 {
+			p.log.Debug("Peer doesn't have the backfill headers", "number", tail.Number.Uint64()-1, "hash", tail.ParentHash)
+			failed[p.id] = true
+			continue
+		}
+		// Verify the headers link up to the tail and derive their total difficulties
+		batch := d.stateDB.NewBatch()
+		for _, header := range headers {
+			if header.Hash() != tail.ParentHash || header.Number.Uint64()+1 != tail.Number.Uint64() {
+				break
+			}
+			if td = new(big.Int).Sub(td, tail.Difficulty); td.Cmp(header.Difficulty) < 0 {
+				return fmt.Errorf("invalid total difficulty %v for header #%d", td, header.Number)
+			}
+			core.WriteHeader(batch, header)
+			core.WriteTd(batch, header.Hash(), header.Number.Uint64(), td)
+			core.WriteCanonicalHash(batch, header.Hash(), header.Number.Uint64())
+			tail = header
+		}
+		if batch.ValueSize() == 0 {
+			p.log.Debug("Peer delivered unlinked backfill headers")
+			p.MarkJunk()
+			failed[p.id] = true
+			continue
+		}
+		core.WriteHeaderBackfillTail(batch, tail.Number.Uint64())
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		failed = make(map[string]bool)
+
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Backfilling header history", "number", tail.Number, "hash", tail.Hash(), "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	// Make sure the history ends in our genesis block
+	if !d.lightchain.HasHeader(tail.Hash(), 0) {
+		return fmt.Errorf("backfilled history doesn't end in local genesis: have %x", tail.Hash())
+	}
+	core.DeleteHeaderBackfillTail(d.stateDB)
+	log.Info("Header history backfilled", "anchor", anchor.Number, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// backfillPeer picks a peer to request backfill headers from, skipping the ones
+// that already failed.
+func (d *Downloader) backfillPeer(failed map[string]bool) *peerConnection {
+	for _, p := range d.peers.AllPeers() {
+		if !failed[p.id] {
+			return p
+		}
+	}
+	return nil
+}
+
+// requestBackfill requests a batch of headers in reverse from the given origin and
+// waits for the peer to deliver them.
+func (d *Downloader) requestBackfill(p *peerConnection, origin common.Hash) ([]*types.Header, error) {
+	d.backfill.lock.Lock()
+	d.backfill.pending = &backfillRequest{peer: p.id, origin: origin}
+	select {
+	case <-d.backfill.deliverCh: // Drop any response arriving after a previous timeout
+	default:
+	}
+	d.backfill.lock.Unlock()
+
+	defer func() {
+		d.backfill.lock.Lock()
+		d.backfill.pending = nil
+		d.backfill.lock.Unlock()
+	}()
+	atomic.AddUint32(&p.requests, 1)
+	go p.peer.RequestHeadersByHash(origin, MaxHeaderFetch, 0, true)
+
+	timeout := time.NewTimer(d.requestTTL())
+	defer timeout.Stop()
+
+	select {
+	case headers := <-d.backfill.deliverCh:
+		return headers, nil
+	case <-timeout.C:
+		p.MarkTimeout()
+		return nil, errTimeout
+	case <-d.quitCh:
+		return nil, errCancelHeaderFetch
+	}
+}
+
+// deliverBackfill hands a batch of headers over to the header backfill if they
+// answer its pending request, reporting whether they were consumed. Empty batches
+// from the requested peer are consumed too, as that's how peers not having the
+// requested headers reply.
+func (d *Downloader) deliverBackfill(id string, headers []*types.Header) bool {
+	d.backfill.lock.Lock()
+	defer d.backfill.lock.Unlock()
+
+	req := d.backfill.pending
+	if req == nil || req.peer != id || (len(headers) > 0 && headers[0].Hash() != req.origin) {
+		return false
+	}
+	d.backfill.pending = nil
+	d.backfill.deliverCh <- headers
+	return true
+}
@@ -29,11 +29,14 @@ import (
 
 	ethereum "github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie"
 	"github.com/rcrowley/go-metrics"
 )
 
@@ -46,6 +49,8 @@ var (
 	MaxReceiptFetch = 256 // Amount of transaction receipts to allow fetching per request
 	MaxStateFetch   = 384 // Amount of node state values to allow fetching per request
 
+	MaxSnapshotChunkFetch = 4 // Amount of state snapshot chunks to allow fetching per request
+
 	MaxForkAncestry  = 3 * params.EpochDuration // Maximum chain reorganisation
 	rttMinEstimate   = 2 * time.Second          // Minimum round-trip time to target for download requests
 	rttMaxEstimate   = 20 * time.Second         // Maximum rount-trip time to target for download requests
@@ -67,6 +72,7 @@ var (
 	fsPivotInterval        = 256        // Number of headers out of which to randomize the pivot point
 	fsMinFullBlocks        = 64         // Number of blocks to retrieve fully even in fast sync
 	fsCriticalTrials       = uint32(32) // Number of times to retry in the cricical section before bailing
+	fsStateTrials          = uint32(3)  // Number of times to re-pivot on unavailable state before full syncing
 )
 
 var (
@@ -78,16 +84,20 @@ var (
 	errTimeout                 = errors.New("timeout")
 	errEmptyHeaderSet          = errors.New("empty header set by peer")
 	errPeersUnavailable        = errors.New("no peers available or all tried for download")
+	errStateUnavailable        = errors.New("pivot state unavailable from all peers")
+	errSnapshotUnavailable     = errors.New("state snapshot unavailable from peer")
 	errInvalidAncestor         = errors.New("retrieved ancestor is invalid")
 	errInvalidChain            = errors.New("retrieved hash chain is invalid")
 	errInvalidBlock            = errors.New("retrieved block is invalid")
 	errInvalidBody             = errors.New("retrieved block body is invalid")
 	errInvalidReceipt          = errors.New("retrieved receipt is invalid")
+	errInvalidSnapshot         = errors.New("retrieved state snapshot is invalid")
 	errCancelBlockFetch        = errors.New("block download canceled (requested)")
 	errCancelHeaderFetch       = errors.New("block header download canceled (requested)")
 	errCancelBodyFetch         = errors.New("block body download canceled (requested)")
 	errCancelReceiptFetch      = errors.New("receipt download canceled (requested)")
 	errCancelStateFetch        = errors.New("state data download canceled (requested)")
+	errCancelSnapshotFetch     = errors.New("state snapshot download canceled (requested)")
 	errCancelHeaderProcessing  = errors.New("header processing canceled (requested)")
 	errCancelContentProcessing = errors.New("content processing canceled (requested)")
 	errNoSyncActive            = errors.New("no sync active")
@@ -104,19 +114,25 @@ type Downloader struct {
 
 	fsPivotLock  *types.Header // Pivot header on critical section entry (cannot change between retries)
 	fsPivotFails uint32        // Number of subsequent fast sync failures in the critical section
+	fsStateFails uint32        // Number of fast sync pivots whose state no peer could serve
 
 	rttEstimate   uint64 // Round trip time to target for download requests
 	rttConfidence uint64 // Confidence in the estimated RTT (unit: millionths to allow atomic ops)
 
 	// Statistics
-	syncStatsChainOrigin uint64 // Origin block number where syncing started at
-	syncStatsChainHeight uint64 // Highest block number known when syncing started
+	syncStatsChainOrigin uint64    // Origin block number where syncing started at
+	syncStatsChainHeight uint64    // Highest block number known when syncing started
+	syncStatsChainHeader uint64    // Number of the last header scheduled for content retrieval
+	syncStatsChainStart  time.Time // Time when syncing from the origin block started
 	syncStatsState       stateSyncStats
 	syncStatsLock        sync.RWMutex // Lock protecting the sync stats fields
 
 	lightchain LightChain
 	blockchain BlockChain
 
+	whitelist map[uint64]common.Hash // Operator pinned block hashes the synced chain must contain
+	backfill  *headerBackfill        // Reverse header retrieval below a trusted checkpoint
+
 	// Callbacks
 	dropPeer peerDropFn // Drops a peer for misbehaving
 
@@ -138,6 +154,9 @@ type Downloader struct {
 	trackStateReq  chan *stateReq
 	stateCh        chan dataPack // [eth/63] Channel receiving inbound node state data
 
+	snapshotManifestCh chan dataPack // [eth/64] Channel receiving inbound state snapshot manifests
+	snapshotChunkCh    chan dataPack // [eth/64] Channel receiving inbound state snapshot chunks
+
 	// Cancellation and termination
 	cancelPeer string        // Identifier of the peer currently being used as the master (cancel on drop)
 	cancelCh   chan struct{} // Channel to cancel mid-flight syncs
@@ -226,13 +245,27 @@ func New(mode SyncMode, stateDb ethdb.Database, mux *event.TypeMux, chain BlockC
 		quitCh:         make(chan struct{}),
 		stateCh:        make(chan dataPack),
 		stateSyncStart: make(chan *stateSync),
-		trackStateReq:  make(chan *stateReq),
+
+		snapshotManifestCh: make(chan dataPack, 1),
+		snapshotChunkCh:    make(chan dataPack, 1),
+		syncStatsState: stateSyncStats{
+			processed: core.GetFastTrieProgress(stateDb),
+		},
+		trackStateReq: make(chan *stateReq),
+		backfill:      newHeaderBackfill(),
 	}
 	go dl.qosTuner()
 	go dl.stateFetcher()
 	return dl
 }
 
+// SetWhitelist pins the canonical hashes of a set of block numbers. Any remote
+// chain conflicting with a pinned hash is rejected during header sync and the
+// peer serving it dropped. It must be called before synchronisation starts.
+func (d *Downloader) SetWhitelist(whitelist map[uint64]common.Hash) {
+	d.whitelist = whitelist
+}
+
 // Progress retrieves the synchronisation boundaries, specifically the origin
 // block where synchronisation started at (may have failed/suspended); the block
 // or header sync is currently at; and the latest known block which the sync targets.
@@ -254,15 +287,44 @@ func (d *Downloader) Progress() ethereum.SyncProgress {
 	case LightSync:
 		current = d.lightchain.CurrentHeader().Number.Uint64()
 	}
+	// Gather the progress of the individual download stages
+	headers, bodies, receipts := d.syncStatsChainHeader, uint64(0), uint64(0)
+	switch d.mode {
+	case FullSync:
+		bodies = current
+	case FastSync:
+		bodies, receipts = current, current
+	case LightSync:
+		headers = current
+	}
+	// Estimate the remaining time based on the average import rate so far
+	var eta time.Duration
+	if origin, height := d.syncStatsChainOrigin, d.syncStatsChainHeight; current > origin && height > current && !d.syncStatsChainStart.IsZero() {
+		elapsed := time.Since(d.syncStatsChainStart)
+		eta = time.Duration(float64(elapsed) * float64(height-current) / float64(current-origin))
+	}
 	return ethereum.SyncProgress{
-		StartingBlock: d.syncStatsChainOrigin,
-		CurrentBlock:  current,
-		HighestBlock:  d.syncStatsChainHeight,
-		PulledStates:  d.syncStatsState.processed,
-		KnownStates:   d.syncStatsState.processed + d.syncStatsState.pending,
+		StartingBlock:  d.syncStatsChainOrigin,
+		CurrentBlock:   current,
+		HighestBlock:   d.syncStatsChainHeight,
+		PulledStates:   d.syncStatsState.processed,
+		KnownStates:    d.syncStatsState.processed + d.syncStatsState.pending,
+		PulledHeaders:  headers,
+		PulledBodies:   bodies,
+		PulledReceipts: receipts,
+		EstimatedTime:  eta,
 	}
 }
 
+// PeerStats retrieves the data retrieval statistics of a registered peer, or nil
+// if the peer is unknown.
+func (d *Downloader) PeerStats(id string) *PeerStats {
+	if p := d.peers.Peer(id); p != nil {
+		return p.Stats()
+	}
+	return nil
+}
+
 // Synchronising returns whether the downloader is currently retrieving blocks.
 func (d *Downloader) Synchronising() bool {
 	return atomic.LoadInt32(&d.synchronising) > 0
@@ -389,6 +451,10 @@ func (d *Downloader) synchronise(id string, hash common.Hash, td *big.Int, mode
 	if d.mode == FastSync && atomic.LoadUint32(&d.fsPivotFails) >= fsCriticalTrials {
 		d.mode = FullSync
 	}
+	if d.mode == FastSync && atomic.LoadUint32(&d.fsStateFails) >= fsStateTrials {
+		log.Warn("Pivot state repeatedly unavailable, falling back to full sync")
+		d.mode = FullSync
+	}
 	// Retrieve the origin peer and initiate the downloading process
 	p := d.peers.Peer(id)
 	if p == nil {
@@ -432,6 +498,8 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 	d.syncStatsLock.Lock()
 	if d.syncStatsChainHeight <= origin || d.syncStatsChainOrigin > origin {
 		d.syncStatsChainOrigin = origin
+		d.syncStatsChainHeader = origin
+		d.syncStatsChainStart = time.Now()
 	}
 	d.syncStatsChainHeight = height
 	d.syncStatsLock.Unlock()
@@ -444,12 +512,24 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 	case FastSync:
 		// Calculate the new fast/slow sync pivot point
 		if d.fsPivotLock == nil {
-			pivotOffset, err := rand.Int(rand.Reader, big.NewInt(int64(fsPivotInterval)))
-			if err != nil {
-				panic(fmt.Sprintf("Failed to access crypto random source: %v", err))
-			}
-			if height > uint64(fsMinFullBlocks)+pivotOffset.Uint64() {
-				pivot = height - uint64(fsMinFullBlocks) - pivotOffset.Uint64()
+			// If an interrupted sync's pivot is still recent enough to be served, resume it.
+			// If the state of a previous pivot was unavailable, the peers are pruning old
+			// state, so pick the most recent pivot possible instead of a random one.
+			if atomic.LoadUint32(&d.fsStateFails) > 0 {
+				if height > uint64(fsMinFullBlocks) {
+					pivot = height - uint64(fsMinFullBlocks)
+				}
+			} else if stored := core.GetFastSyncPivot(d.stateDB); stored > origin && stored <= height && stored+uint64(fsMinFullBlocks+fsPivotInterval) > height {
+				log.Info("Resuming fast sync from persisted pivot", "pivot", stored, "head", height)
+				pivot = stored
+			} else {
+				pivotOffset, err := rand.Int(rand.Reader, big.NewInt(int64(fsPivotInterval)))
+				if err != nil {
+					panic(fmt.Sprintf("Failed to access crypto random source: %v", err))
+				}
+				if height > uint64(fsMinFullBlocks)+pivotOffset.Uint64() {
+					pivot = height - uint64(fsMinFullBlocks) - pivotOffset.Uint64()
+				}
 			}
 		} else {
 			// Pivot point locked in, use this and do not pick a new one!
@@ -463,6 +543,9 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 				origin = 0
 			}
 		}
+		if pivot > 0 {
+			core.WriteFastSyncPivot(d.stateDB, pivot)
+		}
 		log.Debug("Fast syncing until pivot block", "pivot", pivot)
 	}
 	d.queue.Prepare(origin+1, d.mode, pivot, latest)
@@ -481,7 +564,33 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 	} else if d.mode == FullSync {
 		fetchers = append(fetchers, d.processFullSyncContent)
 	}
+	locked := d.fsPivotLock != nil
+
+	// Restore the latest state snapshot of the peer, if any, next to the sync. The
+	// pivot state sync skips any trie nodes already restored in the meantime.
+	var snapshotDone chan struct{}
+	if d.mode == FastSync {
+		snapshotDone = make(chan struct{})
+		go func() {
+			defer close(snapshotDone)
+			d.syncSnapshot(p)
+		}()
+	}
 	err = d.spawnSync(fetchers)
+	if snapshotDone != nil {
+		<-snapshotDone
+	}
+	if err == errStateUnavailable && d.mode == FastSync && !locked {
+		// No peer could serve the state of a fresh pivot, most likely because it was
+		// already pruned. The headers themselves aren't suspicious, so release the
+		// pivot to pick a more recent one, a limited number of times before falling
+		// back to full sync. Pivots locked by earlier critical failures never move.
+		log.Warn("Fast sync pivot state unavailable, re-pivoting", "pivot", pivot)
+		atomic.AddUint32(&d.fsStateFails, 1)
+		d.fsPivotLock = nil
+		core.DeleteFastSyncPivot(d.stateDB)
+		return err
+	}
 	if err != nil && d.mode == FastSync && d.fsPivotLock != nil {
 		// If sync failed in the critical section, bump the fail counter.
 		atomic.AddUint32(&d.fsPivotFails, 1)
@@ -1017,6 +1126,9 @@ func (d *Downloader) fetchParts(errCancel error, deliveryCh chan dataPack, deliv
 				if err != errStaleDelivery {
 					setIdle(peer, accepted)
 				}
+				if err != nil {
+					peer.MarkJunk()
+				}
 				// Issue a log to the user to see what's going on
 				switch {
 				case err == nil && packet.Items() == 0:
@@ -1066,6 +1178,7 @@ func (d *Downloader) fetchParts(errCancel error, deliveryCh chan dataPack, deliv
 					// The reason the minimum threshold is 2 is because the downloader tries to estimate the bandwidth
 					// and latency of a peer separately, which requires pushing the measures capacity a bit and seeing
 					// how response times reacts, to it always requests one more than the minimum (i.e. min 2).
+					peer.MarkTimeout()
 					if fails > 2 {
 						peer.log.Trace("Data delivery timed out", "type", kind)
 						setIdle(peer, 0)
@@ -1255,6 +1368,13 @@ func (d *Downloader) processHeaders(origin uint64, td *big.Int) error {
 				}
 				chunk := headers[:limit]
 
+				// Reject the chain outright if it conflicts with any pinned block
+				for _, header := range chunk {
+					if want, ok := d.whitelist[header.Number.Uint64()]; ok && header.Hash() != want {
+						log.Warn("Whitelisted block mismatch, rejecting chain", "number", header.Number, "have", header.Hash(), "want", want)
+						return errInvalidChain
+					}
+				}
 				// In case of header only syncing, validate the chunk immediately
 				if d.mode == FastSync || d.mode == LightSync {
 					// Collect the yet unknown headers to mark them as uncertain
@@ -1309,6 +1429,10 @@ func (d *Downloader) processHeaders(origin uint64, td *big.Int) error {
 				}
 				headers = headers[limit:]
 				origin += uint64(limit)
+
+				d.syncStatsLock.Lock()
+				d.syncStatsChainHeader = origin - 1
+				d.syncStatsLock.Unlock()
 			}
 			// Signal the content downloaders of the availablility of new tasks
 			for _, ch := range []chan bool{d.bodyWakeCh, d.receiptWakeCh} {
@@ -1461,16 +1585,28 @@ func (d *Downloader) commitPivotBlock(result *fetchResult) error {
 	if err := d.syncState(b.Root()).Wait(); err != nil {
 		return err
 	}
+	// Make sure the synced state is rooted at the pivot header before committing
+	if _, err := trie.New(b.Root(), d.stateDB); err != nil {
+		log.Warn("Synced pivot state incomplete", "number", b.Number(), "root", b.Root(), "err", err)
+		return errStateUnavailable
+	}
 	log.Debug("Committing fast sync pivot as new head", "number", b.Number(), "hash", b.Hash())
 	if _, err := d.blockchain.InsertReceiptChain([]*types.Block{b}, []types.Receipts{result.Receipts}); err != nil {
 		return err
 	}
-	return d.blockchain.FastSyncCommitHead(b.Hash())
+	if err := d.blockchain.FastSyncCommitHead(b.Hash()); err != nil {
+		return err
+	}
+	core.DeleteFastSyncPivot(d.stateDB)
+	return nil
 }
 
 // DeliverHeaders injects a new batch of block headers received from a remote
 // node into the download schedule.
 func (d *Downloader) DeliverHeaders(id string, headers []*types.Header) (err error) {
+	if d.deliverBackfill(id, headers) {
+		return nil
+	}
 	return d.deliver(id, d.headerCh, &headerPack{id, headers}, headerInMeter, headerDropMeter)
 }
 
@@ -1489,6 +1625,18 @@ func (d *Downloader) DeliverNodeData(id string, data [][]byte) (err error) {
 	return d.deliver(id, d.stateCh, &statePack{id, data}, stateInMeter, stateDropMeter)
 }
 
+// DeliverSnapshotManifest injects a state snapshot manifest received from a
+// remote node, or none if the node doesn't have any snapshot to serve.
+func (d *Downloader) DeliverSnapshotManifest(id string, manifests []*snapshot.Manifest) (err error) {
+	return d.deliver(id, d.snapshotManifestCh, &snapshotManifestPack{id, manifests}, snapshotInMeter, snapshotDropMeter)
+}
+
+// DeliverSnapshotChunks injects a batch of state snapshot chunks received from
+// a remote node.
+func (d *Downloader) DeliverSnapshotChunks(id string, chunks [][]byte) (err error) {
+	return d.deliver(id, d.snapshotChunkCh, &snapshotChunkPack{id, chunks}, snapshotInMeter, snapshotDropMeter)
+}
+
 // deliver injects a new batch of data received from a remote node.
 func (d *Downloader) deliver(id string, destCh chan dataPack, packet dataPack, inMeter, dropMeter metrics.Meter) (err error) {
 	// Update the delivery metrics for both good and failed deliveries
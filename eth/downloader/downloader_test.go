@@ -28,10 +28,12 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/trie"
 )
@@ -69,6 +71,7 @@ type downloadTester struct {
 	peerChainTds map[string]map[common.Hash]*big.Int       // Total difficulties of the blocks in the peer chains
 
 	peerMissingStates map[string]map[common.Hash]bool // State entries that fast sync should not return
+	peerSnapshots     map[string]*snapshot.Manifest   // State snapshots served by different test peers
 
 	lock sync.RWMutex
 }
@@ -92,6 +95,7 @@ func newTester() *downloadTester {
 		peerReceipts:      make(map[string]map[common.Hash]types.Receipts),
 		peerChainTds:      make(map[string]map[common.Hash]*big.Int),
 		peerMissingStates: make(map[string]map[common.Hash]bool),
+		peerSnapshots:     make(map[string]*snapshot.Manifest),
 	}
 	tester.stateDb, _ = ethdb.NewMemDatabase()
 	tester.stateDb.Put(genesis.Root().Bytes(), []byte{0x00})
@@ -391,6 +395,16 @@ func (dl *downloadTester) Rollback(hashes []common.Hash) {
 	}
 }
 
+// NewSnapshotRestorer creates a restorer for a state snapshot whose header is
+// part of the testers canonical chain.
+func (dl *downloadTester) NewSnapshotRestorer(manifest *snapshot.Manifest) (*snapshot.Restorer, error) {
+	header := dl.GetHeaderByHash(manifest.Block)
+	if header == nil || header.Root != manifest.Root {
+		return nil, fmt.Errorf("unknown snapshot block: %x", manifest.Block[:4])
+	}
+	return snapshot.NewRestorer(dl.stateDb, manifest)
+}
+
 // newPeer registers a new block download source into the downloader.
 func (dl *downloadTester) newPeer(id string, version int, hashes []common.Hash, headers map[common.Hash]*types.Header, blocks map[common.Hash]*types.Block, receipts map[common.Hash]types.Receipts) error {
 	return dl.newSlowPeer(id, version, hashes, headers, blocks, receipts, 0)
@@ -526,9 +540,17 @@ func (dlp *downloadTesterPeer) RequestHeadersByNumber(origin uint64, amount int,
 	hashes := dlp.dl.peerHashes[dlp.id]
 	headers := dlp.dl.peerHeaders[dlp.id]
 	result := make([]*types.Header, 0, amount)
-	for i := 0; i < amount && len(hashes)-int(origin)-1-i*(skip+1) >= 0; i++ {
-		if header, ok := headers[hashes[len(hashes)-int(origin)-1-i*(skip+1)]]; ok {
-			result = append(result, header)
+	if reverse {
+		for i := 0; i < amount && int(origin)-i*(skip+1) >= 0; i++ {
+			if header, ok := headers[hashes[len(hashes)-int(origin)-1+i*(skip+1)]]; ok {
+				result = append(result, header)
+			}
+		}
+	} else {
+		for i := 0; i < amount && len(hashes)-int(origin)-1-i*(skip+1) >= 0; i++ {
+			if header, ok := headers[hashes[len(hashes)-int(origin)-1-i*(skip+1)]]; ok {
+				result = append(result, header)
+			}
 		}
 	}
 	// Delay delivery a bit to allow attacks to unfold
@@ -569,6 +591,7 @@ func (dlp *downloadTesterPeer) RequestBodies(hashes []common.Hash) error {
 // batches of block receipts from the particularly requested peer.
 func (dlp *downloadTesterPeer) RequestReceipts(hashes []common.Hash) error {
 	dlp.waitDelay()
+	dlp.waitSnapshot()
 
 	dlp.dl.lock.RLock()
 	defer dlp.dl.lock.RUnlock()
@@ -608,6 +631,61 @@ func (dlp *downloadTesterPeer) RequestNodeData(hashes []common.Hash) error {
 	return nil
 }
 
+// RequestSnapshotManifest constructs a getSnapshotManifest method associated with
+// a particular peer in the download tester, delivering its state snapshot, if any.
+func (dlp *downloadTesterPeer) RequestSnapshotManifest() error {
+	dlp.waitDelay()
+
+	dlp.dl.lock.RLock()
+	defer dlp.dl.lock.RUnlock()
+
+	var manifests []*snapshot.Manifest
+	if manifest := dlp.dl.peerSnapshots[dlp.id]; manifest != nil {
+		manifests = append(manifests, manifest)
+	}
+	go dlp.dl.downloader.DeliverSnapshotManifest(dlp.id, manifests)
+
+	return nil
+}
+
+// RequestSnapshotChunks constructs a getSnapshotChunks method associated with a
+// particular peer in the download tester. The returned function can be used to
+// retrieve batches of state snapshot chunks from the particularly requested peer.
+func (dlp *downloadTesterPeer) RequestSnapshotChunks(hashes []common.Hash) error {
+	dlp.waitDelay()
+
+	dlp.dl.lock.RLock()
+	defer dlp.dl.lock.RUnlock()
+
+	results := make([][]byte, 0, len(hashes))
+	for _, hash := range hashes {
+		if chunk := snapshot.ReadChunk(dlp.dl.peerDb, hash); chunk != nil {
+			results = append(results, chunk)
+		}
+	}
+	go dlp.dl.downloader.DeliverSnapshotChunks(dlp.id, results)
+
+	return nil
+}
+
+// waitSnapshot holds back the receipts served by peers with a state snapshot
+// until it's restored by the tester (or a second passes), so the sync can't
+// complete before the restoration.
+func (dlp *downloadTesterPeer) waitSnapshot() {
+	dlp.dl.lock.RLock()
+	manifest := dlp.dl.peerSnapshots[dlp.id]
+	dlp.dl.lock.RUnlock()
+
+	if manifest == nil {
+		return
+	}
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(10 * time.Millisecond) {
+		if ok, _ := dlp.dl.stateDb.Has(manifest.Root[:]); ok {
+			return
+		}
+	}
+}
+
 // assertOwnChain checks if the local chain contains the correct number of items
 // of the various chain components.
 func assertOwnChain(t *testing.T, tester *downloadTester, length int) {
@@ -695,6 +773,169 @@ func testCanonicalSynchronisation(t *testing.T, protocol int, mode SyncMode) {
 	assertOwnChain(t, tester, targetBlocks+1)
 }
 
+// Tests that fast sync selects a pivot block recent enough for its state to be
+// served by pruning full nodes, which only retain the state of the last 128 blocks.
+func TestFastSyncPrunedPeer63(t *testing.T) { testFastSyncPrunedPeer(t, 63) }
+func TestFastSyncPrunedPeer64(t *testing.T) { testFastSyncPrunedPeer(t, 64) }
+
+func testFastSyncPrunedPeer(t *testing.T, protocol int) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	// Create a chain and a peer which has pruned all but the most recent states
+	targetBlocks := blockCacheLimit - 15
+	hashes, headers, blocks, receipts := tester.makeChain(targetBlocks, 0, tester.genesis, nil, false)
+
+	tester.newPeer("peer", protocol, hashes, headers, blocks, receipts)
+	for _, header := range headers {
+		if header.Number.Uint64()+128 <= uint64(targetBlocks) {
+			tester.peerMissingStates["peer"][header.Root] = true
+		}
+	}
+	// Synchronise with the peer. If the random pivot was already pruned, the sync
+	// should fail gracefully and the next one re-pivot to a recent enough block.
+	if err := tester.sync("peer", nil, FastSync); err != nil {
+		if err != errStateUnavailable {
+			t.Fatalf("stale pivot error mismatch: have %v, want %v", err, errStateUnavailable)
+		}
+		time.Sleep(150 * time.Millisecond) // Make sure no in-flight requests remain
+
+		if err := tester.sync("peer", nil, FastSync); err != nil {
+			t.Fatalf("failed to synchronise blocks after re-pivoting: %v", err)
+		}
+	}
+	if pivot := tester.downloader.queue.FastSyncPivot(); pivot+128 <= uint64(targetBlocks) {
+		t.Fatalf("pivot too old: have #%d, head #%d", pivot, targetBlocks)
+	}
+	assertOwnChain(t, tester, targetBlocks+1)
+}
+
+// Tests that if no peer can serve the state of any pivot, fast sync gives up after
+// a few trials and falls back to full sync.
+func TestFastSyncStateFallback63(t *testing.T) { testFastSyncStateFallback(t, 63) }
+func TestFastSyncStateFallback64(t *testing.T) { testFastSyncStateFallback(t, 64) }
+
+func testFastSyncStateFallback(t *testing.T, protocol int) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	// Create a chain and a peer which doesn't serve any state at all
+	targetBlocks := blockCacheLimit - 15
+	hashes, headers, blocks, receipts := tester.makeChain(targetBlocks, 0, tester.genesis, nil, false)
+
+	tester.newPeer("peer", protocol, hashes, headers, blocks, receipts)
+	for _, header := range headers {
+		tester.peerMissingStates["peer"][header.Root] = true
+	}
+	// Fast sync should fail on every trial, then switch to full sync
+	for i := uint32(0); i < fsStateTrials; i++ {
+		if err := tester.sync("peer", nil, FastSync); err != errStateUnavailable {
+			t.Fatalf("trial %d: error mismatch: have %v, want %v", i, err, errStateUnavailable)
+		}
+		time.Sleep(150 * time.Millisecond) // Make sure no in-flight requests remain
+	}
+	if err := tester.sync("peer", nil, FastSync); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	if tester.downloader.mode != FullSync {
+		t.Fatalf("sync mode mismatch: have %v, want %v", tester.downloader.mode, FullSync)
+	}
+	if head := tester.CurrentBlock().NumberU64(); head != uint64(targetBlocks) {
+		t.Fatalf("head block mismatch: have #%d, want #%d", head, targetBlocks)
+	}
+}
+
+// Tests that fast sync resumes the pivot persisted by an interrupted sync if it's
+// still recent enough, and that the pivot marker is dropped once sync completes.
+func TestFastSyncResumePivot63(t *testing.T) { testFastSyncResumePivot(t, 63) }
+func TestFastSyncResumePivot64(t *testing.T) { testFastSyncResumePivot(t, 64) }
+
+func testFastSyncResumePivot(t *testing.T, protocol int) {
+	t.Parallel()
+
+	targetBlocks := blockCacheLimit - 15
+	for i, tt := range []struct {
+		stored uint64 // Pivot persisted by the interrupted sync
+		resume bool   // Whether the persisted pivot should be resumed
+	}{
+		{uint64(targetBlocks - fsMinFullBlocks - 10), true},
+		{uint64(targetBlocks - fsMinFullBlocks - fsPivotInterval), false},
+	} {
+		tester := newTester()
+
+		hashes, headers, blocks, receipts := tester.makeChain(targetBlocks, 0, tester.genesis, nil, false)
+		tester.newPeer("peer", protocol, hashes, headers, blocks, receipts)
+		core.WriteFastSyncPivot(tester.stateDb, tt.stored)
+
+		var persisted uint64
+		tester.downloader.syncInitHook = func(uint64, uint64) {
+			persisted = core.GetFastSyncPivot(tester.stateDb)
+		}
+		if err := tester.sync("peer", nil, FastSync); err != nil {
+			t.Fatalf("test %d: failed to synchronise blocks: %v", i, err)
+		}
+		pivot := tester.downloader.queue.FastSyncPivot()
+		if (pivot == tt.stored) != tt.resume {
+			t.Errorf("test %d: pivot mismatch: have #%d, stored #%d, resume %v", i, pivot, tt.stored, tt.resume)
+		}
+		if persisted != pivot {
+			t.Errorf("test %d: persisted pivot mismatch: have #%d, want #%d", i, persisted, pivot)
+		}
+		if stored := core.GetFastSyncPivot(tester.stateDb); stored != 0 {
+			t.Errorf("test %d: pivot marker not cleared: #%d", i, stored)
+		}
+		assertOwnChain(t, tester, targetBlocks+1)
+		tester.terminate()
+	}
+}
+
+// Tests that fast sync restores the state snapshot of eth/64 peers, and drops
+// the downloaded chunks afterwards.
+func TestFastSyncSnapshot64(t *testing.T) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	targetBlocks := blockCacheLimit - 15
+	hashes, headers, blocks, receipts := tester.makeChain(targetBlocks, 0, tester.genesis, nil, false)
+	tester.newPeer("peer", 64, hashes, headers, blocks, receipts)
+
+	// Snapshot the state of an early block, which fast sync doesn't retrieve otherwise
+	block := blocks[hashes[len(hashes)-11]]
+	manifest, err := snapshot.Generate(state.NewDatabase(tester.peerDb), block.NumberU64(), block.Hash(), block.Root(), func(hash common.Hash, blob []byte) error {
+		return snapshot.WriteChunk(tester.peerDb, hash, blob)
+	})
+	if err != nil {
+		t.Fatalf("failed to generate snapshot: %v", err)
+	}
+	tester.peerSnapshots["peer"] = manifest
+
+	if err := tester.sync("peer", nil, FastSync); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	assertOwnChain(t, tester, targetBlocks+1)
+
+	// Verify the snapshot state was restored and its chunks dropped
+	want, _ := state.New(block.Root(), state.NewDatabase(tester.peerDb))
+	have, err := state.New(block.Root(), state.NewDatabase(tester.stateDb))
+	if err != nil {
+		t.Fatalf("snapshot state not restored: %v", err)
+	}
+	if balance := have.GetBalance(testAddress); balance.Cmp(want.GetBalance(testAddress)) != 0 {
+		t.Errorf("balance mismatch: have %v, want %v", balance, want.GetBalance(testAddress))
+	}
+	for _, hash := range manifest.Chunks {
+		if snapshot.ReadChunk(tester.stateDb, hash) != nil {
+			t.Errorf("chunk %x not dropped", hash)
+		}
+	}
+}
+
 // Tests that if a large batch of blocks are being downloaded, it is throttled
 // until the cached blocks are retrieved.
 func TestThrottling62(t *testing.T)     { testThrottling(t, 62, FullSync) }
@@ -1429,6 +1670,17 @@ func testSyncProgress(t *testing.T, protocol int, mode SyncMode) {
 	if progress := tester.downloader.Progress(); progress.StartingBlock != uint64(targetBlocks/2+1) || progress.CurrentBlock != uint64(targetBlocks) || progress.HighestBlock != uint64(targetBlocks) {
 		t.Fatalf("Final progress mismatch: have %v/%v/%v, want %v/%v/%v", progress.StartingBlock, progress.CurrentBlock, progress.HighestBlock, targetBlocks/2+1, targetBlocks, targetBlocks)
 	}
+	// Check the per stage progress after successful sync
+	wantBodies, wantReceipts := uint64(targetBlocks), uint64(0)
+	switch mode {
+	case FastSync:
+		wantReceipts = uint64(targetBlocks)
+	case LightSync:
+		wantBodies = 0
+	}
+	if progress := tester.downloader.Progress(); progress.PulledHeaders != uint64(targetBlocks) || progress.PulledBodies != wantBodies || progress.PulledReceipts != wantReceipts {
+		t.Fatalf("Final stage progress mismatch: have %v/%v/%v, want %v/%v/%v", progress.PulledHeaders, progress.PulledBodies, progress.PulledReceipts, targetBlocks, wantBodies, wantReceipts)
+	}
 }
 
 // Tests that synchronisation progress (origin block number and highest block
@@ -1820,3 +2072,119 @@ func testFastCriticalRestarts(t *testing.T, protocol int, progress bool) {
 	// completed using a single mode of operation, whereas fast-then-slow can result
 	// in arbitrary intermediate state that's not cleanly verifiable.
 }
+
+// Tests that chains conflicting with a whitelisted block hash are rejected, while
+// chains containing it are synchronised.
+func TestWhitelistedSync62(t *testing.T)      { testWhitelistedSync(t, 62, FullSync) }
+func TestWhitelistedSync63Full(t *testing.T)  { testWhitelistedSync(t, 63, FullSync) }
+func TestWhitelistedSync63Fast(t *testing.T)  { testWhitelistedSync(t, 63, FastSync) }
+func TestWhitelistedSync64Full(t *testing.T)  { testWhitelistedSync(t, 64, FullSync) }
+func TestWhitelistedSync64Fast(t *testing.T)  { testWhitelistedSync(t, 64, FastSync) }
+func TestWhitelistedSync64Light(t *testing.T) { testWhitelistedSync(t, 64, LightSync) }
+
+func testWhitelistedSync(t *testing.T, protocol int, mode SyncMode) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	// Create a good and a conflicting chain, forking before the pinned block
+	shared, fork := MaxHashFetch, 2*MaxHashFetch
+	hashesA, hashesB, headersA, headersB, blocksA, blocksB, receiptsA, receiptsB := tester.makeChainFork(shared+fork, fork, tester.genesis, nil, true)
+
+	pinned := uint64(shared + 10)
+	tester.downloader.SetWhitelist(map[uint64]common.Hash{pinned: hashesA[len(hashesA)-1-int(pinned)]})
+
+	tester.newPeer("bad", protocol, hashesB, headersB, blocksB, receiptsB)
+	if err := tester.sync("bad", nil, mode); err != errInvalidChain {
+		t.Fatalf("conflicting chain error mismatch: have %v, want %v", err, errInvalidChain)
+	}
+	tester.newPeer("good", protocol, hashesA, headersA, blocksA, receiptsA)
+	if err := tester.sync("good", nil, mode); err != nil {
+		t.Fatalf("failed to synchronise whitelisted chain: %v", err)
+	}
+	assertOwnChain(t, tester, shared+fork+1)
+}
+
+// Tests that the headers below a trusted anchor are backfilled in reverse, linked
+// up to the genesis block and stored along with their total difficulties, even if
+// some peers don't have the requested headers.
+func TestHeaderBackfill62(t *testing.T) { testHeaderBackfill(t, 62) }
+func TestHeaderBackfill63(t *testing.T) { testHeaderBackfill(t, 63) }
+func TestHeaderBackfill64(t *testing.T) { testHeaderBackfill(t, 64) }
+
+func testHeaderBackfill(t *testing.T, protocol int) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	// Create a chain long enough to need multiple batches, and a peer missing its headers
+	targetBlocks := 3*MaxHeaderFetch + 15
+	hashesA, headersA, blocksA, receiptsA := tester.makeChain(targetBlocks, 0, tester.genesis, nil, false)
+
+	tester.newPeer("good", protocol, hashesA, headersA, blocksA, receiptsA)
+	tester.newPeer("empty", protocol, hashesA, nil, blocksA, receiptsA)
+
+	// Store the trusted anchor with its total difficulty and backfill below it
+	tds := make(map[uint64]*big.Int)
+	td := tester.genesis.Difficulty()
+	for i := len(hashesA) - 2; i >= 0; i-- {
+		header := headersA[hashesA[i]]
+		td = new(big.Int).Add(td, header.Difficulty)
+		tds[header.Number.Uint64()] = td
+	}
+	anchor := headersA[hashesA[0]]
+	core.WriteHeader(tester.stateDb, anchor)
+	core.WriteTd(tester.stateDb, anchor.Hash(), anchor.Number.Uint64(), tds[anchor.Number.Uint64()])
+	core.WriteCanonicalHash(tester.stateDb, anchor.Hash(), anchor.Number.Uint64())
+
+	tester.downloader.Backfill(anchor)
+	for start := time.Now(); atomic.LoadInt32(&tester.downloader.backfill.running) == 1; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 10*time.Second {
+			t.Fatalf("backfill timed out")
+		}
+	}
+	for number := uint64(1); number < anchor.Number.Uint64(); number++ {
+		hash := hashesA[len(hashesA)-int(number)-1]
+		if have := core.GetCanonicalHash(tester.stateDb, number); have != hash {
+			t.Fatalf("header #%d: canonical hash mismatch: have %x, want %x", number, have, hash)
+		}
+		if core.GetHeader(tester.stateDb, hash, number) == nil {
+			t.Fatalf("header #%d: missing", number)
+		}
+		if have := core.GetTd(tester.stateDb, hash, number); have == nil || have.Cmp(tds[number]) != 0 {
+			t.Fatalf("header #%d: total difficulty mismatch: have %v, want %v", number, have, tds[number])
+		}
+	}
+	if tail := core.GetHeaderBackfillTail(tester.stateDb); tail != 0 {
+		t.Errorf("backfill marker not cleared: #%d", tail)
+	}
+}
+
+// Tests that peers failing to answer requests are ranked below otherwise equally
+// fast peers, and that their statistics are reported.
+func TestUnreliablePeerRanking(t *testing.T) {
+	peers := newPeerSet()
+
+	reliable := newPeerConnection("reliable", 63, nil, log.New())
+	unreliable := newPeerConnection("unreliable", 63, nil, log.New())
+	for _, p := range []*peerConnection{unreliable, reliable} {
+		if err := peers.Register(p); err != nil {
+			t.Fatalf("failed to register peer %s: %v", p.id, err)
+		}
+		p.headerThroughput = 100
+		p.requests = 10
+	}
+	unreliable.MarkTimeout()
+	unreliable.MarkJunk()
+
+	idle, _ := peers.HeaderIdlePeers()
+	if len(idle) != 2 || idle[0] != reliable {
+		t.Fatalf("peer ranking mismatch: have %v first, want %v", idle[0].id, reliable.id)
+	}
+	stats := unreliable.Stats()
+	if stats.Requests != 10 || stats.Timeouts != 1 || stats.Junk != 1 || stats.HeaderThroughput != 100 {
+		t.Fatalf("peer stats mismatch: have %+v", stats)
+	}
+}
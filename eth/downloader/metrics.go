@@ -40,4 +40,8 @@ var (
 
 	stateInMeter   = metrics.NewMeter("eth/downloader/states/in")
 	stateDropMeter = metrics.NewMeter("eth/downloader/states/drop")
+	stateDupMeter  = metrics.NewMeter("eth/downloader/states/dup")
+
+	snapshotInMeter   = metrics.NewMeter("eth/downloader/snapshots/in")
+	snapshotDropMeter = metrics.NewMeter("eth/downloader/snapshots/drop")
 )
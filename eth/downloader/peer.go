@@ -37,6 +37,7 @@ import (
 const (
 	maxLackingHashes  = 4096 // Maximum number of entries allowed on the list or lacking items
 	measurementImpact = 0.1  // The impact a single measurement has on a peer's final throughput value.
+	minReliability    = 0.1  // Minimum weight of a peer's throughput, irrelevant of its failures
 )
 
 var (
@@ -61,6 +62,10 @@ type peerConnection struct {
 
 	rtt time.Duration // Request round trip time to track responsiveness (QoS)
 
+	requests uint32 // Number of data retrieval requests sent to the peer (atomic)
+	timeouts uint32 // Number of retrieval requests the peer failed to answer in time (atomic)
+	junk     uint32 // Number of stale, unrequested or invalid deliveries of the peer (atomic)
+
 	headerStarted  time.Time // Time instance when the last header fetch was started
 	blockStarted   time.Time // Time instance when the last block (body) fetch was started
 	receiptStarted time.Time // Time instance when the last receipt fetch was started
@@ -90,6 +95,14 @@ type Peer interface {
 	RequestNodeData([]common.Hash) error
 }
 
+// SnapshotPeer encapsulates the methods required to download a state snapshot
+// from a remote [eth/64] peer. It's optional, peers not implementing it are not
+// asked for snapshots.
+type SnapshotPeer interface {
+	RequestSnapshotManifest() error
+	RequestSnapshotChunks([]common.Hash) error
+}
+
 // lightPeerWrapper wraps a LightPeer struct, stubbing out the Peer-only methods.
 type lightPeerWrapper struct {
 	peer LightPeer
@@ -140,9 +153,62 @@ func (p *peerConnection) Reset() {
 	p.receiptThroughput = 0
 	p.stateThroughput = 0
 
+	atomic.StoreUint32(&p.requests, 0)
+	atomic.StoreUint32(&p.timeouts, 0)
+	atomic.StoreUint32(&p.junk, 0)
+
 	p.lacking = make(map[common.Hash]struct{})
 }
 
+// PeerStats is a summary of the data retrieval performance of a peer.
+type PeerStats struct {
+	HeaderThroughput  float64       `json:"headerThroughput"`  // Headers retrievable per second
+	BlockThroughput   float64       `json:"blockThroughput"`   // Block bodies retrievable per second
+	ReceiptThroughput float64       `json:"receiptThroughput"` // Receipts retrievable per second
+	StateThroughput   float64       `json:"stateThroughput"`   // State trie nodes retrievable per second
+	RTT               time.Duration `json:"rtt"`               // Estimated request round trip time
+	Requests          uint32        `json:"requests"`          // Data retrieval requests sent
+	Timeouts          uint32        `json:"timeouts"`          // Requests not answered in time
+	Junk              uint32        `json:"junk"`              // Stale, unrequested or invalid deliveries
+}
+
+// Stats retrieves a summary of the data retrieval performance of the peer.
+func (p *peerConnection) Stats() *PeerStats {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return &PeerStats{
+		HeaderThroughput:  p.headerThroughput,
+		BlockThroughput:   p.blockThroughput,
+		ReceiptThroughput: p.receiptThroughput,
+		StateThroughput:   p.stateThroughput,
+		RTT:               p.rtt,
+		Requests:          atomic.LoadUint32(&p.requests),
+		Timeouts:          atomic.LoadUint32(&p.timeouts),
+		Junk:              atomic.LoadUint32(&p.junk),
+	}
+}
+
+// MarkTimeout records that the peer failed to answer a retrieval request in time.
+func (p *peerConnection) MarkTimeout() {
+	atomic.AddUint32(&p.timeouts, 1)
+}
+
+// MarkJunk records that the peer delivered stale, unrequested or invalid data.
+func (p *peerConnection) MarkJunk() {
+	atomic.AddUint32(&p.junk, 1)
+}
+
+// reliability returns the weight of the peer's measured throughputs when ranking
+// it against others, based on the ratio of its failed and total requests.
+func (p *peerConnection) reliability() float64 {
+	var (
+		requests = float64(atomic.LoadUint32(&p.requests))
+		failures = float64(atomic.LoadUint32(&p.timeouts) + atomic.LoadUint32(&p.junk))
+	)
+	return math.Max(minReliability, 1-failures/(requests+1))
+}
+
 // FetchHeaders sends a header retrieval request to the remote peer.
 func (p *peerConnection) FetchHeaders(from uint64, count int) error {
 	// Sanity check the protocol version
@@ -154,6 +220,7 @@ func (p *peerConnection) FetchHeaders(from uint64, count int) error {
 		return errAlreadyFetching
 	}
 	p.headerStarted = time.Now()
+	atomic.AddUint32(&p.requests, 1)
 
 	// Issue the header retrieval request (absolut upwards without gaps)
 	go p.peer.RequestHeadersByNumber(from, count, 0, false)
@@ -172,6 +239,7 @@ func (p *peerConnection) FetchBodies(request *fetchRequest) error {
 		return errAlreadyFetching
 	}
 	p.blockStarted = time.Now()
+	atomic.AddUint32(&p.requests, 1)
 
 	// Convert the header set to a retrievable slice
 	hashes := make([]common.Hash, 0, len(request.Headers))
@@ -194,6 +262,7 @@ func (p *peerConnection) FetchReceipts(request *fetchRequest) error {
 		return errAlreadyFetching
 	}
 	p.receiptStarted = time.Now()
+	atomic.AddUint32(&p.requests, 1)
 
 	// Convert the header set to a retrievable slice
 	hashes := make([]common.Hash, 0, len(request.Headers))
@@ -216,6 +285,7 @@ func (p *peerConnection) FetchNodeData(hashes []common.Hash) error {
 		return errAlreadyFetching
 	}
 	p.stateStarted = time.Now()
+	atomic.AddUint32(&p.requests, 1)
 
 	go p.peer.RequestNodeData(hashes)
 
@@ -524,7 +594,8 @@ func (ps *peerSet) NodeDataIdlePeers() ([]*peerConnection, int) {
 
 // idlePeers retrieves a flat list of all currently idle peers satisfying the
 // protocol version constraints, using the provided function to check idleness.
-// The resulting set of peers are sorted by their measure throughput.
+// The resulting set of peers are sorted by their measured throughput, weighted
+// by their reliability in answering requests.
 func (ps *peerSet) idlePeers(minProtocol, maxProtocol int, idleCheck func(*peerConnection) bool, throughput func(*peerConnection) float64) ([]*peerConnection, int) {
 	ps.lock.RLock()
 	defer ps.lock.RUnlock()
@@ -538,9 +609,13 @@ func (ps *peerSet) idlePeers(minProtocol, maxProtocol int, idleCheck func(*peerC
 			total++
 		}
 	}
+	weights := make(map[*peerConnection]float64, len(idle))
+	for _, p := range idle {
+		weights[p] = throughput(p) * p.reliability()
+	}
 	for i := 0; i < len(idle); i++ {
 		for j := i + 1; j < len(idle); j++ {
-			if throughput(idle[i]) < throughput(idle[j]) {
+			if weights[idle[i]] < weights[idle[j]] {
 				idle[i], idle[j] = idle[j], idle[i]
 			}
 		}
@@ -0,0 +1,228 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// snapshotHeaderPoll is the interval at which the header chain is checked for
+// the block of a downloaded snapshot.
+var snapshotHeaderPoll = 100 * time.Millisecond
+
+// snapshotChain is implemented by chains able to restore the state of snapshots
+// into their database.
+type snapshotChain interface {
+	// NewSnapshotRestorer creates a restorer for the state of a snapshot whose
+	// block header is already known.
+	NewSnapshotRestorer(*snapshot.Manifest) (*snapshot.Restorer, error)
+}
+
+// syncSnapshot downloads and restores the latest state snapshot of the sync peer.
+// Failures are not fatal, the pivot state sync retrieves any missing state, so
+// they're only logged. Once done, late deliveries are discarded until the sync
+// cycle terminates.
+func (d *Downloader) syncSnapshot(p *peerConnection) {
+	if err := d.fetchSnapshot(p); err != nil && err != errCancelSnapshotFetch {
+		p.log.Debug("State snapshot sync failed", "err", err)
+	}
+	for {
+		select {
+		case <-d.cancelCh:
+			return
+		case <-d.snapshotManifestCh:
+		case <-d.snapshotChunkCh:
+			// Out of bounds delivery, ignore
+		}
+	}
+}
+
+// fetchSnapshot retrieves the manifest of the latest state snapshot of an eth/64
+// peer and all of its chunks, then waits for the header of the snapshot block to
+// be imported by the header sync to authenticate and restore the state.
+func (d *Downloader) fetchSnapshot(p *peerConnection) error {
+	chain, ok := d.blockchain.(snapshotChain)
+	if !ok {
+		return nil
+	}
+	peer, ok := p.peer.(SnapshotPeer)
+	if !ok || p.version < 64 {
+		return nil
+	}
+	manifest, err := d.fetchSnapshotManifest(p, peer)
+	if err != nil || manifest == nil {
+		return err
+	}
+	if ok, _ := d.stateDB.Has(manifest.Root[:]); ok {
+		return nil
+	}
+	p.log.Debug("Downloading state snapshot", "number", manifest.Number, "hash", manifest.Block, "chunks", len(manifest.Chunks))
+
+	// Drop the downloaded chunks when done, unless shared with our own snapshot
+	defer func() {
+		if err := snapshot.DeleteChunks(d.stateDB, manifest.Chunks, snapshot.ReadLatest(d.stateDB)); err != nil {
+			p.log.Warn("Failed to delete state snapshot chunks", "err", err)
+		}
+	}()
+	if err := d.fetchSnapshotChunks(p, peer, manifest); err != nil {
+		return err
+	}
+	// Wait until the snapshot block is authenticated by the header chain
+	for !d.lightchain.HasHeader(manifest.Block, manifest.Number) {
+		if d.lightchain.CurrentHeader().Number.Uint64() >= manifest.Number {
+			return errInvalidSnapshot
+		}
+		select {
+		case <-d.cancelCh:
+			return errCancelSnapshotFetch
+		case <-time.After(snapshotHeaderPoll):
+		}
+	}
+	restorer, err := chain.NewSnapshotRestorer(manifest)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	for _, hash := range manifest.Chunks {
+		select {
+		case <-d.cancelCh:
+			return errCancelSnapshotFetch
+		default:
+		}
+		if err := restorer.Feed(snapshot.ReadChunk(d.stateDB, hash)); err != nil {
+			return err
+		}
+	}
+	if err := restorer.Finalize(); err != nil {
+		return err
+	}
+	p.log.Info("Restored state snapshot", "number", manifest.Number, "hash", manifest.Block, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// fetchSnapshotManifest retrieves the manifest of the latest state snapshot of a
+// peer, or nil if it doesn't have any.
+func (d *Downloader) fetchSnapshotManifest(p *peerConnection, peer SnapshotPeer) (*snapshot.Manifest, error) {
+	go peer.RequestSnapshotManifest()
+
+	ttl := d.requestTTL()
+	timeout := time.After(ttl)
+	for {
+		select {
+		case <-d.cancelCh:
+			return nil, errCancelSnapshotFetch
+
+		case packet := <-d.snapshotManifestCh:
+			// Discard anything not from the origin peer
+			if packet.PeerId() != p.id {
+				log.Debug("Received snapshot manifest from incorrect peer", "peer", packet.PeerId())
+				break
+			}
+			manifests := packet.(*snapshotManifestPack).manifests
+			switch {
+			case len(manifests) == 0 || manifests[0] == nil || len(manifests[0].Chunks) == 0:
+				return nil, nil
+			case len(manifests) > 1:
+				return nil, errBadPeer
+			}
+			return manifests[0], nil
+
+		case <-timeout:
+			p.log.Debug("Waiting for snapshot manifest timed out", "elapsed", ttl)
+			return nil, errTimeout
+
+		case <-d.snapshotChunkCh:
+			// Out of bounds delivery, ignore
+		}
+	}
+}
+
+// fetchSnapshotChunks retrieves all the chunks of a snapshot not stored yet from
+// a peer, verifying them against the manifest before storing them.
+func (d *Downloader) fetchSnapshotChunks(p *peerConnection, peer SnapshotPeer, manifest *snapshot.Manifest) error {
+	var queue []common.Hash
+	for _, hash := range manifest.Chunks {
+		if snapshot.ReadChunk(d.stateDB, hash) == nil {
+			queue = append(queue, hash)
+		}
+	}
+	for len(queue) > 0 {
+		// Request the next batch of chunks
+		batch := queue
+		if len(batch) > MaxSnapshotChunkFetch {
+			batch = batch[:MaxSnapshotChunkFetch]
+		}
+		requested := make(map[common.Hash]struct{}, len(batch))
+		for _, hash := range batch {
+			requested[hash] = struct{}{}
+		}
+		go peer.RequestSnapshotChunks(batch)
+
+		ttl := d.requestTTL()
+		timeout := time.After(ttl)
+		for delivered := false; !delivered; {
+			select {
+			case <-d.cancelCh:
+				return errCancelSnapshotFetch
+
+			case packet := <-d.snapshotChunkCh:
+				// Discard anything not from the origin peer
+				if packet.PeerId() != p.id {
+					log.Debug("Received snapshot chunks from incorrect peer", "peer", packet.PeerId())
+					break
+				}
+				// Store the requested chunks, the peer must deliver at least one
+				stored := 0
+				for _, blob := range packet.(*snapshotChunkPack).chunks {
+					hash := crypto.Keccak256Hash(blob)
+					if _, ok := requested[hash]; !ok {
+						continue
+					}
+					if err := snapshot.WriteChunk(d.stateDB, hash, blob); err != nil {
+						return err
+					}
+					delete(requested, hash)
+					stored++
+				}
+				if stored == 0 {
+					return errSnapshotUnavailable
+				}
+				delivered = true
+
+			case <-timeout:
+				p.log.Debug("Waiting for snapshot chunks timed out", "elapsed", ttl)
+				return errTimeout
+
+			case <-d.snapshotManifestCh:
+				// Out of bounds delivery, ignore
+			}
+		}
+		// Reschedule the undelivered chunks of the batch
+		queue = queue[len(batch):]
+		for _, hash := range batch {
+			if _, ok := requested[hash]; ok {
+				queue = append(queue, hash)
+			}
+		}
+	}
+	return nil
+}
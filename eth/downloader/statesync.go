@@ -24,6 +24,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/crypto/sha3"
 	"github.com/ethereum/go-ethereum/ethdb"
@@ -294,6 +295,9 @@ func (s *stateSync) loop() error {
 		case req := <-s.deliver:
 			// Response, disconnect or timeout triggered, drop the peer if stalling
 			log.Trace("Received node data response", "peer", req.peer.id, "count", len(req.response), "dropped", req.dropped, "timeout", !req.dropped && req.timedOut())
+			if !req.dropped && req.timedOut() {
+				req.peer.MarkTimeout()
+			}
 			if len(req.items) <= 2 && !req.dropped && req.timedOut() {
 				// 2 items are the minimum requested, if even that times out, we've no use of
 				// this peer at the moment.
@@ -315,6 +319,8 @@ func (s *stateSync) loop() error {
 	return s.commit(true)
 }
 
+// commit flushes the trie nodes processed so far into the database, along with
+// the number of entries synced, allowing progress to be reported across restarts.
 func (s *stateSync) commit(force bool) error {
 	if !force && s.bytesUncommitted < ethdb.IdealBatchSize {
 		return nil
@@ -322,6 +328,11 @@ func (s *stateSync) commit(force bool) error {
 	start := time.Now()
 	b := s.d.stateDB.NewBatch()
 	s.sched.Commit(b)
+
+	s.d.syncStatsLock.RLock()
+	core.WriteFastTrieProgress(b, s.d.syncStatsState.processed+uint64(s.numUncommitted))
+	s.d.syncStatsLock.RUnlock()
+
 	if err := b.Write(); err != nil {
 		return fmt.Errorf("DB write error: %v", err)
 	}
@@ -395,6 +406,9 @@ func (s *stateSync) process(req *stateReq) (bool, error) {
 		if duplicate > 0 || unexpected > 0 {
 			s.updateStats(0, duplicate, unexpected, time.Since(start))
 		}
+		if unexpected > 0 {
+			req.peer.MarkJunk()
+		}
 	}(time.Now())
 
 	// Iterate over all the delivered data and inject one-by-one into the trie
@@ -411,6 +425,7 @@ func (s *stateSync) process(req *stateReq) (bool, error) {
 			unexpected++
 		case trie.ErrAlreadyProcessed:
 			duplicate++
+			stateDupMeter.Mark(1)
 		default:
 			return stale, fmt.Errorf("invalid state node %s: %v", hash.TerminalString(), err)
 		}
@@ -438,7 +453,8 @@ func (s *stateSync) process(req *stateReq) (bool, error) {
 		// If we've requested the node too many times already, it may be a malicious
 		// sync where nobody has the right data. Abort.
 		if len(task.attempts) >= npeers {
-			return stale, fmt.Errorf("state node %s failed with all peers (%d tries, %d peers)", hash.TerminalString(), len(task.attempts), npeers)
+			log.Warn("State node failed with all peers", "hash", hash, "tries", len(task.attempts), "peers", npeers)
+			return stale, errStateUnavailable
 		}
 		// Missing item, place into the retry queue.
 		s.tasks[hash] = task
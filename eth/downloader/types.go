@@ -19,6 +19,7 @@ package downloader
 import (
 	"fmt"
 
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
@@ -77,3 +78,23 @@ type statePack struct {
 func (p *statePack) PeerId() string { return p.peerId }
 func (p *statePack) Items() int     { return len(p.states) }
 func (p *statePack) Stats() string  { return fmt.Sprintf("%d", len(p.states)) }
+
+// snapshotManifestPack is a state snapshot manifest returned by a peer, if any.
+type snapshotManifestPack struct {
+	peerId    string
+	manifests []*snapshot.Manifest
+}
+
+func (p *snapshotManifestPack) PeerId() string { return p.peerId }
+func (p *snapshotManifestPack) Items() int     { return len(p.manifests) }
+func (p *snapshotManifestPack) Stats() string  { return fmt.Sprintf("%d", len(p.manifests)) }
+
+// snapshotChunkPack is a batch of state snapshot chunks returned by a peer.
+type snapshotChunkPack struct {
+	peerId string
+	chunks [][]byte
+}
+
+func (p *snapshotChunkPack) PeerId() string { return p.peerId }
+func (p *snapshotChunkPack) Items() int     { return len(p.chunks) }
+func (p *snapshotChunkPack) Stats() string  { return fmt.Sprintf("%d", len(p.chunks)) }
@@ -37,6 +37,7 @@ const (
 	maxQueueDist  = 32                     // Maximum allowed distance from the chain head to queue
 	hashLimit     = 256                    // Maximum number of unique blocks a peer may have announced
 	blockLimit    = 64                     // Maximum number of unique blocks a peer may have delivered
+	latencyTTL    = 10 * time.Minute       // Time after which an unrefreshed peer latency estimate is discarded
 )
 
 var (
@@ -75,7 +76,10 @@ type announce struct {
 	header *types.Header // Header of the block partially reassembled (new protocol)
 	time   time.Time     // Timestamp of the announcement
 
-	origin string // Identifier of the peer originating the notification
+	origin     string      // Identifier of the peer originating the notification
+	first      time.Time   // Timestamp of the first announcement of the block by any peer
+	fetched    time.Time   // Timestamp of the header retrieval request
+	alternates []*announce // Announcements of other peers to fall back to if the fetch stalls
 
 	fetchHeader headerRequesterFn // Fetcher function to retrieve the header of an announced block
 	fetchBodies bodyRequesterFn   // Fetcher function to retrieve the body of an announced block
@@ -99,8 +103,15 @@ type bodyFilterTask struct {
 
 // inject represents a schedules import operation.
 type inject struct {
-	origin string
-	block  *types.Block
+	origin    string
+	block     *types.Block
+	announced time.Time // Timestamp of the first announcement (zero if propagated directly)
+}
+
+// peerLatency is the estimated header retrieval latency of a remote peer.
+type peerLatency struct {
+	rtt     time.Duration // Moving average of the measured round trip times
+	updated time.Time     // Timestamp of the last measurement
 }
 
 // Fetcher is responsible for accumulating block announcements from various peers
@@ -123,6 +134,7 @@ type Fetcher struct {
 	fetching   map[common.Hash]*announce   // Announced blocks, currently fetching
 	fetched    map[common.Hash][]*announce // Blocks with headers fetched, scheduled for body retrieval
 	completing map[common.Hash]*announce   // Blocks with headers, currently body-completing
+	latencies  map[string]*peerLatency     // Per peer header retrieval latency estimates
 
 	// Block cache
 	queue  *prque.Prque            // Queue containing the import operations (block number sorted)
@@ -160,6 +172,7 @@ func New(getBlock blockRetrievalFn, verifyHeader headerVerifierFn, broadcastBloc
 		fetching:       make(map[common.Hash]*announce),
 		fetched:        make(map[common.Hash][]*announce),
 		completing:     make(map[common.Hash]*announce),
+		latencies:      make(map[string]*peerLatency),
 		queue:          prque.New(),
 		queues:         make(map[string]int),
 		queued:         make(map[common.Hash]*inject),
@@ -282,10 +295,21 @@ func (f *Fetcher) loop() {
 	completeTimer := time.NewTimer(0)
 
 	for {
-		// Clean up any expired block fetches
+		// Clean up any expired block fetches, falling back to other announcers
 		for hash, announce := range f.fetching {
 			if time.Since(announce.time) > fetchTimeout {
 				f.forgetHash(hash)
+				f.updateLatency(announce.origin, fetchTimeout)
+
+				if len(announce.alternates) > 0 && f.getBlock(hash) == nil {
+					f.refetch(hash, announce)
+				}
+			}
+		}
+		// Forget the latencies of peers not serving us for a long time
+		for peer, latency := range f.latencies {
+			if time.Since(latency.updated) > latencyTTL {
+				delete(f.latencies, peer)
 			}
 		}
 		// Import any queued blocks that could potentially fit
@@ -310,7 +334,7 @@ func (f *Fetcher) loop() {
 				f.forgetBlock(hash)
 				continue
 			}
-			f.insert(op.origin, op.block)
+			f.insert(op.origin, op.block, op.announced)
 		}
 		// Wait for an outside event to occur
 		select {
@@ -336,13 +360,22 @@ func (f *Fetcher) loop() {
 					break
 				}
 			}
-			// All is well, schedule the announce if block's not yet downloading
-			if _, ok := f.fetching[notification.hash]; ok {
+			// All is well, schedule the announce if block's not yet downloading, but
+			// remember the announcer as a fallback in case the running fetch stalls
+			if announce, ok := f.fetching[notification.hash]; ok {
+				if announce.origin != notification.origin && !announcedBy(announce.alternates, notification.origin) {
+					announce.alternates = append(announce.alternates, notification)
+				}
 				break
 			}
 			if _, ok := f.completing[notification.hash]; ok {
 				break
 			}
+			// Only track a single announcement of a block from each peer
+			if announcedBy(f.announced[notification.hash], notification.origin) {
+				propAnnounceDupMeter.Mark(1)
+				break
+			}
 			f.announces[notification.origin] = count
 			f.announced[notification.hash] = append(f.announced[notification.hash], notification)
 			if f.announceChangeHook != nil && len(f.announced[notification.hash]) == 1 {
@@ -355,7 +388,7 @@ func (f *Fetcher) loop() {
 		case op := <-f.inject:
 			// A direct block insertion was requested, try and fill any pending gaps
 			propBroadcastInMeter.Mark(1)
-			f.enqueue(op.origin, op.block)
+			f.enqueue(op.origin, op.block, time.Time{})
 
 		case hash := <-f.done:
 			// A pending import finished, remove all traces of the notification
@@ -368,13 +401,15 @@ func (f *Fetcher) loop() {
 
 			for hash, announces := range f.announced {
 				if time.Since(announces[0].time) > arriveTimeout-gatherSlack {
-					// Pick a random peer to retrieve from, reset all others
-					announce := announces[rand.Intn(len(announces))]
+					// Pick the fastest peer to retrieve from, keep all others as fallbacks
+					announce, alternates := f.pickAnnounce(announces)
+					announce.first, announce.alternates = announces[0].time, alternates
 					f.forgetHash(hash)
 
 					// If the block still didn't arrive, queue for fetching
 					if f.getBlock(hash) == nil {
 						request[announce.origin] = append(request[announce.origin], hash)
+						announce.fetched = time.Now()
 						f.fetching[hash] = announce
 					}
 				}
@@ -403,8 +438,8 @@ func (f *Fetcher) loop() {
 			request := make(map[string][]common.Hash)
 
 			for hash, announces := range f.fetched {
-				// Pick a random peer to retrieve from, reset all others
-				announce := announces[rand.Intn(len(announces))]
+				// Pick the fastest peer to retrieve from, reset all others
+				announce, _ := f.pickAnnounce(announces)
 				f.forgetHash(hash)
 
 				// If the block still didn't arrive, queue for completion
@@ -454,6 +489,8 @@ func (f *Fetcher) loop() {
 						f.forgetHash(hash)
 						continue
 					}
+					f.updateLatency(announce.origin, task.time.Sub(announce.fetched))
+
 					// Only keep if not imported by other means
 					if f.getBlock(hash) == nil {
 						announce.header = header
@@ -501,7 +538,7 @@ func (f *Fetcher) loop() {
 			// Schedule the header-only blocks for import
 			for _, block := range complete {
 				if announce := f.completing[block.Hash()]; announce != nil {
-					f.enqueue(announce.origin, block)
+					f.enqueue(announce.origin, block, announce.first)
 				}
 			}
 
@@ -557,13 +594,92 @@ func (f *Fetcher) loop() {
 			// Schedule the retrieved blocks for ordered import
 			for _, block := range blocks {
 				if announce := f.completing[block.Hash()]; announce != nil {
-					f.enqueue(announce.origin, block)
+					f.enqueue(announce.origin, block, announce.first)
 				}
 			}
 		}
 	}
 }
 
+// announcedBy checks whether any of the announcements originates from the given peer.
+func announcedBy(announces []*announce, peer string) bool {
+	for _, announce := range announces {
+		if announce.origin == peer {
+			return true
+		}
+	}
+	return false
+}
+
+// pickAnnounce selects the announcement of the peer with the lowest estimated
+// header retrieval latency, returning all the others separately. Peers without
+// a latency estimate are preferred to measure them, ties are broken randomly.
+func (f *Fetcher) pickAnnounce(announces []*announce) (*announce, []*announce) {
+	var (
+		offset = rand.Intn(len(announces))
+		best   = -1
+		rtt    time.Duration
+	)
+	for i := range announces {
+		idx := (offset + i) % len(announces)
+
+		var latency time.Duration
+		if measured := f.latencies[announces[idx].origin]; measured != nil {
+			latency = measured.rtt
+		}
+		if best == -1 || latency < rtt {
+			best, rtt = idx, latency
+		}
+	}
+	others := make([]*announce, 0, len(announces)-1)
+	others = append(others, announces[:best]...)
+	others = append(others, announces[best+1:]...)
+
+	return announces[best], others
+}
+
+// updateLatency folds a new header retrieval round trip measurement into the
+// latency estimate of a peer.
+func (f *Fetcher) updateLatency(peer string, rtt time.Duration) {
+	if rtt < 0 {
+		rtt = 0
+	}
+	if latency := f.latencies[peer]; latency != nil {
+		latency.rtt = (3*latency.rtt + rtt) / 4
+		latency.updated = time.Now()
+		return
+	}
+	f.latencies[peer] = &peerLatency{rtt: rtt, updated: time.Now()}
+}
+
+// refetch directly requests the header of a block whose retrieval stalled from
+// the next best peer that announced it too.
+func (f *Fetcher) refetch(hash common.Hash, stalled *announce) {
+	announce, alternates := f.pickAnnounce(stalled.alternates)
+
+	// Ensure the fallback peer doesn't exceed its announce allowance
+	count := f.announces[announce.origin] + 1
+	if count > hashLimit {
+		return
+	}
+	f.announces[announce.origin] = count
+
+	announce.first, announce.alternates = stalled.first, alternates
+	announce.time, announce.fetched = time.Now(), time.Now()
+	f.fetching[hash] = announce
+
+	log.Debug("Block propagation stalled, refetching", "stalled", stalled.origin, "peer", announce.origin, "number", announce.number, "hash", hash)
+	headerRefetchMeter.Mark(1)
+
+	go func() {
+		if f.fetchingHook != nil {
+			f.fetchingHook([]common.Hash{hash})
+		}
+		headerFetchMeter.Mark(1)
+		announce.fetchHeader(hash)
+	}()
+}
+
 // rescheduleFetch resets the specified fetch timer to the next announce timeout.
 func (f *Fetcher) rescheduleFetch(fetch *time.Timer) {
 	// Short circuit if no blocks are announced
@@ -598,7 +714,7 @@ func (f *Fetcher) rescheduleComplete(complete *time.Timer) {
 
 // enqueue schedules a new future import operation, if the block to be imported
 // has not yet been seen.
-func (f *Fetcher) enqueue(peer string, block *types.Block) {
+func (f *Fetcher) enqueue(peer string, block *types.Block, announced time.Time) {
 	hash := block.Hash()
 
 	// Ensure the peer isn't DOSing us
@@ -619,8 +735,9 @@ func (f *Fetcher) enqueue(peer string, block *types.Block) {
 	// Schedule the block for future importing
 	if _, ok := f.queued[hash]; !ok {
 		op := &inject{
-			origin: peer,
-			block:  block,
+			origin:    peer,
+			block:     block,
+			announced: announced,
 		}
 		f.queues[peer] = count
 		f.queued[hash] = op
@@ -635,7 +752,7 @@ func (f *Fetcher) enqueue(peer string, block *types.Block) {
 // insert spawns a new goroutine to run a block insertion into the chain. If the
 // block's number is at the same height as the current import phase, if updates
 // the phase states accordingly.
-func (f *Fetcher) insert(peer string, block *types.Block) {
+func (f *Fetcher) insert(peer string, block *types.Block, announced time.Time) {
 	hash := block.Hash()
 
 	// Run the import on a new thread
@@ -672,6 +789,9 @@ func (f *Fetcher) insert(peer string, block *types.Block) {
 		}
 		// If import succeeded, broadcast the block
 		propAnnounceOutTimer.UpdateSince(block.ReceivedAt)
+		if !announced.IsZero() {
+			propAnnounceImportTimer.UpdateSince(announced)
+		}
 		go f.broadcastBlock(block, false)
 
 		// Invoke the testing hook if needed
@@ -418,6 +418,76 @@ func testPendingDeduplication(t *testing.T, protocol int) {
 	}
 }
 
+// Tests that if a block is announced by multiple peers, it is retrieved from the
+// one with the lowest measured latency.
+func TestFastestAnnouncerPreferred(t *testing.T) {
+	hashes, _ := makeChain(1, 0, genesis)
+
+	fetcher := New(nil, nil, nil, nil, nil, nil)
+	fetcher.updateLatency("slow", 200*time.Millisecond)
+	fetcher.updateLatency("fast", 10*time.Millisecond)
+
+	announces := []*announce{
+		{hash: hashes[0], number: 1, origin: "slow"},
+		{hash: hashes[0], number: 1, origin: "fast"},
+		{hash: hashes[0], number: 1, origin: "slow"},
+	}
+	for i := 0; i < 16; i++ {
+		best, others := fetcher.pickAnnounce(announces)
+		if best.origin != "fast" {
+			t.Fatalf("attempt %d: picked announcer mismatch: have %s, want %s", i, best.origin, "fast")
+		}
+		if len(others) != len(announces)-1 {
+			t.Fatalf("attempt %d: alternate count mismatch: have %d, want %d", i, len(others), len(announces)-1)
+		}
+	}
+	// Unmeasured peers should be tried before measured ones
+	announces = append(announces, &announce{hash: hashes[0], number: 1, origin: "new"})
+	if best, _ := fetcher.pickAnnounce(announces); best.origin != "new" {
+		t.Fatalf("picked announcer mismatch: have %s, want %s", best.origin, "new")
+	}
+}
+
+// Tests that if the retrieval of an announced block stalls, the fetcher falls
+// back to requesting it directly from another peer that announced it.
+func TestStalledFetchFallback(t *testing.T) {
+	hashes, blocks := makeChain(1, 0, genesis)
+
+	tester := newTester()
+	tester.fetcher.Stop()
+
+	// Recreate the fetcher, preferring the stalling peer over the valid one
+	tester.fetcher = New(tester.getBlock, tester.verifyHeader, tester.broadcastBlock, tester.chainHeight, tester.insertChain, tester.dropPeer)
+	tester.fetcher.updateLatency("valid", time.Second)
+
+	stallingHeaders := tester.makeHeaderFetcher("stalling", nil, -gatherSlack)
+	stallingBodies := tester.makeBodyFetcher("stalling", nil, 0)
+	validHeaders := tester.makeHeaderFetcher("valid", blocks, -gatherSlack)
+	validBodies := tester.makeBodyFetcher("valid", blocks, 0)
+
+	fetching := make(chan []common.Hash, 2)
+	tester.fetcher.fetchingHook = func(hashes []common.Hash) { fetching <- hashes }
+	imported := make(chan *types.Block)
+	tester.fetcher.importedHook = func(block *types.Block) { imported <- block }
+	tester.fetcher.Start()
+	defer tester.fetcher.Stop()
+
+	// Announce the block from both peers, almost at the fetch timeout already
+	announced := time.Now().Add(-fetchTimeout + 250*time.Millisecond)
+	tester.fetcher.Notify("stalling", hashes[0], 1, announced, stallingHeaders, stallingBodies)
+	tester.fetcher.Notify("valid", hashes[0], 1, announced, validHeaders, validBodies)
+
+	verifyFetchingEvent(t, fetching, true)
+	verifyImportEvent(t, imported, false)
+
+	// Wait for the fetch to expire and wake the fetcher up to notice it
+	time.Sleep(300 * time.Millisecond)
+	tester.fetcher.Notify("stalling", unknownBlock.Hash(), 0, time.Now(), stallingHeaders, stallingBodies)
+
+	verifyFetchingEvent(t, fetching, true)
+	verifyImportEvent(t, imported, true)
+}
+
 // Tests that announcements retrieved in a random order are cached and eventually
 // imported when all the gaps are filled in.
 func TestRandomArrivalImport62(t *testing.T) { testRandomArrivalImport(t, 62) }
@@ -23,18 +23,21 @@ import (
 )
 
 var (
-	propAnnounceInMeter   = metrics.NewMeter("eth/fetcher/prop/announces/in")
-	propAnnounceOutTimer  = metrics.NewTimer("eth/fetcher/prop/announces/out")
-	propAnnounceDropMeter = metrics.NewMeter("eth/fetcher/prop/announces/drop")
-	propAnnounceDOSMeter  = metrics.NewMeter("eth/fetcher/prop/announces/dos")
+	propAnnounceInMeter     = metrics.NewMeter("eth/fetcher/prop/announces/in")
+	propAnnounceOutTimer    = metrics.NewTimer("eth/fetcher/prop/announces/out")
+	propAnnounceImportTimer = metrics.NewTimer("eth/fetcher/prop/announces/import")
+	propAnnounceDropMeter   = metrics.NewMeter("eth/fetcher/prop/announces/drop")
+	propAnnounceDupMeter    = metrics.NewMeter("eth/fetcher/prop/announces/dup")
+	propAnnounceDOSMeter    = metrics.NewMeter("eth/fetcher/prop/announces/dos")
 
 	propBroadcastInMeter   = metrics.NewMeter("eth/fetcher/prop/broadcasts/in")
 	propBroadcastOutTimer  = metrics.NewTimer("eth/fetcher/prop/broadcasts/out")
 	propBroadcastDropMeter = metrics.NewMeter("eth/fetcher/prop/broadcasts/drop")
 	propBroadcastDOSMeter  = metrics.NewMeter("eth/fetcher/prop/broadcasts/dos")
 
-	headerFetchMeter = metrics.NewMeter("eth/fetcher/fetch/headers")
-	bodyFetchMeter   = metrics.NewMeter("eth/fetcher/fetch/bodies")
+	headerFetchMeter   = metrics.NewMeter("eth/fetcher/fetch/headers")
+	headerRefetchMeter = metrics.NewMeter("eth/fetcher/fetch/refetches")
+	bodyFetchMeter     = metrics.NewMeter("eth/fetcher/fetch/bodies")
 
 	headerFilterInMeter  = metrics.NewMeter("eth/fetcher/filter/headers/in")
 	headerFilterOutMeter = metrics.NewMeter("eth/fetcher/filter/headers/out")
@@ -27,6 +27,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
@@ -228,6 +229,54 @@ func (api *PublicFilterAPI) NewHeads(ctx context.Context) (*rpc.Subscription, er
 	return rpcSub, nil
 }
 
+// reorgNotification is the payload of a reorgs subscription, carrying the headers
+// of the dropped and adopted blocks in the order they are rolled back and applied.
+type reorgNotification struct {
+	Dropped []*types.Header `json:"dropped"`
+	Adopted []*types.Header `json:"adopted"`
+}
+
+// Reorgs send a notification each time the canonical chain is reorganised, so
+// that clients tracking chain state can roll back the dropped blocks.
+func (api *PublicFilterAPI) Reorgs(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		reorgs := make(chan core.ReorgEvent)
+		reorgsSub := api.events.SubscribeReorgs(reorgs)
+
+		for {
+			select {
+			case ev := <-reorgs:
+				notification := &reorgNotification{
+					Dropped: make([]*types.Header, len(ev.Dropped)),
+					Adopted: make([]*types.Header, len(ev.Adopted)),
+				}
+				for i, block := range ev.Dropped {
+					notification.Dropped[i] = block.Header()
+				}
+				for i, block := range ev.Adopted {
+					notification.Adopted[i] = block.Header()
+				}
+				notifier.Notify(rpcSub.ID, notification)
+			case <-rpcSub.Err():
+				reorgsSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				reorgsSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // Logs creates a subscription that fires for all new log that match the given filter criteria.
 func (api *PublicFilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
@@ -39,6 +39,7 @@ type Backend interface {
 	SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription
 	SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription
 	SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription
+	SubscribeReorgEvent(ch chan<- core.ReorgEvent) event.Subscription
 
 	BloomStatus() (uint64, uint64)
 	ServiceFilter(ctx context.Context, session *bloombits.MatcherSession)
@@ -101,6 +102,19 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	}
 	head := header.Number.Uint64()
 
+	// Resolve any safe block limits against the current safe head
+	if safe := rpc.SafeBlockNumber.Int64(); f.begin == safe || f.end == safe {
+		header, _ := f.backend.HeaderByNumber(ctx, rpc.SafeBlockNumber)
+		if header == nil {
+			return nil, nil
+		}
+		if f.begin == safe {
+			f.begin = header.Number.Int64()
+		}
+		if f.end == safe {
+			f.end = header.Number.Int64()
+		}
+	}
 	if f.begin == -1 {
 		f.begin = int64(head)
 	}
@@ -10,23 +10,46 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/gasprice"
+	"github.com/ethereum/go-ethereum/params"
 )
 
 func (c Config) MarshalTOML() (interface{}, error) {
 	type Config struct {
 		Genesis                 *core.Genesis `toml:",omitempty"`
+		OverrideByzantium       *big.Int      `toml:",omitempty"`
 		NetworkId               uint64
 		SyncMode                downloader.SyncMode
-		LightServ               int  `toml:",omitempty"`
-		LightPeers              int  `toml:",omitempty"`
-		MaxPeers                int  `toml:"-"`
-		SkipBcVersionCheck      bool `toml:"-"`
-		DatabaseHandles         int  `toml:"-"`
+		NoPruning               bool
+		Preimages               bool
+		SafeDepth               uint64
+		TxLookupLimit           uint64                    `toml:",omitempty"`
+		StateSnapshots          bool                      `toml:",omitempty"`
+		LightServ               int                       `toml:",omitempty"`
+		LightPeers              int                       `toml:",omitempty"`
+		LightCache              int                       `toml:",omitempty"`
+		LightIndex              bool                      `toml:",omitempty"`
+		LightPriority           []string                  `toml:",omitempty"`
+		LightQuota              uint64                    `toml:",omitempty"`
+		LightPayee              common.Address            `toml:",omitempty"`
+		LightPrice              *big.Int                  `toml:",omitempty"`
+		ServeLimit              int                       `toml:",omitempty"`
+		NoServeState            bool                      `toml:",omitempty"`
+		Checkpoint              *params.TrustedCheckpoint `toml:",omitempty"`
+		Whitelist               map[uint64]common.Hash    `toml:",omitempty"`
+		MaxPeers                int                       `toml:"-"`
+		SkipBcVersionCheck      bool                      `toml:"-"`
+		DatabaseHandles         int                       `toml:"-"`
 		DatabaseCache           int
+		TrieCache               int
+		CodeCache               int
+		BlockCache              int
+		DatabaseFreezer         string         `toml:",omitempty"`
+		DatabaseCompaction      string         `toml:",omitempty"`
 		Etherbase               common.Address `toml:",omitempty"`
 		MinerThreads            int            `toml:",omitempty"`
 		ExtraData               hexutil.Bytes  `toml:",omitempty"`
 		GasPrice                *big.Int
+		MinerStratum            string `toml:",omitempty"`
 		EthashCacheDir          string
 		EthashCachesInMem       int
 		EthashCachesOnDisk      int
@@ -36,6 +59,9 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		TxPool                  core.TxPoolConfig
 		GPO                     gasprice.Config
 		EnablePreimageRecording bool
+		VMStats                 bool
+		VMProfileLabels         bool
+		RPCCallWorkers          int    `toml:",omitempty"`
 		DocRoot                 string `toml:"-"`
 		PowFake                 bool   `toml:"-"`
 		PowTest                 bool   `toml:"-"`
@@ -43,17 +69,39 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	}
 	var enc Config
 	enc.Genesis = c.Genesis
+	enc.OverrideByzantium = c.OverrideByzantium
 	enc.NetworkId = c.NetworkId
 	enc.SyncMode = c.SyncMode
+	enc.NoPruning = c.NoPruning
+	enc.Preimages = c.Preimages
+	enc.SafeDepth = c.SafeDepth
+	enc.TxLookupLimit = c.TxLookupLimit
+	enc.StateSnapshots = c.StateSnapshots
 	enc.LightServ = c.LightServ
 	enc.LightPeers = c.LightPeers
+	enc.LightCache = c.LightCache
+	enc.LightIndex = c.LightIndex
+	enc.LightPriority = c.LightPriority
+	enc.LightQuota = c.LightQuota
+	enc.LightPayee = c.LightPayee
+	enc.LightPrice = c.LightPrice
+	enc.ServeLimit = c.ServeLimit
+	enc.NoServeState = c.NoServeState
+	enc.Checkpoint = c.Checkpoint
+	enc.Whitelist = c.Whitelist
 	enc.SkipBcVersionCheck = c.SkipBcVersionCheck
 	enc.DatabaseHandles = c.DatabaseHandles
 	enc.DatabaseCache = c.DatabaseCache
+	enc.TrieCache = c.TrieCache
+	enc.CodeCache = c.CodeCache
+	enc.BlockCache = c.BlockCache
+	enc.DatabaseFreezer = c.DatabaseFreezer
+	enc.DatabaseCompaction = c.DatabaseCompaction
 	enc.Etherbase = c.Etherbase
 	enc.MinerThreads = c.MinerThreads
 	enc.ExtraData = c.ExtraData
 	enc.GasPrice = c.GasPrice
+	enc.MinerStratum = c.MinerStratum
 	enc.EthashCacheDir = c.EthashCacheDir
 	enc.EthashCachesInMem = c.EthashCachesInMem
 	enc.EthashCachesOnDisk = c.EthashCachesOnDisk
@@ -63,6 +111,9 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.TxPool = c.TxPool
 	enc.GPO = c.GPO
 	enc.EnablePreimageRecording = c.EnablePreimageRecording
+	enc.VMStats = c.VMStats
+	enc.VMProfileLabels = c.VMProfileLabels
+	enc.RPCCallWorkers = c.RPCCallWorkers
 	enc.DocRoot = c.DocRoot
 	enc.PowFake = c.PowFake
 	enc.PowTest = c.PowTest
@@ -73,18 +124,40 @@ func (c Config) MarshalTOML() (interface{}, error) {
 func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	type Config struct {
 		Genesis                 *core.Genesis `toml:",omitempty"`
+		OverrideByzantium       *big.Int      `toml:",omitempty"`
 		NetworkId               *uint64
 		SyncMode                *downloader.SyncMode
-		LightServ               *int  `toml:",omitempty"`
-		LightPeers              *int  `toml:",omitempty"`
-		MaxPeers                *int  `toml:"-"`
-		SkipBcVersionCheck      *bool `toml:"-"`
-		DatabaseHandles         *int  `toml:"-"`
+		NoPruning               *bool
+		Preimages               *bool
+		SafeDepth               *uint64
+		TxLookupLimit           *uint64                   `toml:",omitempty"`
+		StateSnapshots          *bool                     `toml:",omitempty"`
+		LightServ               *int                      `toml:",omitempty"`
+		LightPeers              *int                      `toml:",omitempty"`
+		LightCache              *int                      `toml:",omitempty"`
+		LightIndex              *bool                     `toml:",omitempty"`
+		LightPriority           []string                  `toml:",omitempty"`
+		LightQuota              *uint64                   `toml:",omitempty"`
+		LightPayee              *common.Address           `toml:",omitempty"`
+		LightPrice              *big.Int                  `toml:",omitempty"`
+		ServeLimit              *int                      `toml:",omitempty"`
+		NoServeState            *bool                     `toml:",omitempty"`
+		Checkpoint              *params.TrustedCheckpoint `toml:",omitempty"`
+		Whitelist               map[uint64]common.Hash    `toml:",omitempty"`
+		MaxPeers                *int                      `toml:"-"`
+		SkipBcVersionCheck      *bool                     `toml:"-"`
+		DatabaseHandles         *int                      `toml:"-"`
 		DatabaseCache           *int
+		TrieCache               *int
+		CodeCache               *int
+		BlockCache              *int
+		DatabaseFreezer         *string         `toml:",omitempty"`
+		DatabaseCompaction      *string         `toml:",omitempty"`
 		Etherbase               *common.Address `toml:",omitempty"`
 		MinerThreads            *int            `toml:",omitempty"`
 		ExtraData               hexutil.Bytes   `toml:",omitempty"`
 		GasPrice                *big.Int
+		MinerStratum            *string `toml:",omitempty"`
 		EthashCacheDir          *string
 		EthashCachesInMem       *int
 		EthashCachesOnDisk      *int
@@ -94,6 +167,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		TxPool                  *core.TxPoolConfig
 		GPO                     *gasprice.Config
 		EnablePreimageRecording *bool
+		VMStats                 *bool
+		VMProfileLabels         *bool
+		RPCCallWorkers          *int    `toml:",omitempty"`
 		DocRoot                 *string `toml:"-"`
 		PowFake                 *bool   `toml:"-"`
 		PowTest                 *bool   `toml:"-"`
@@ -106,18 +182,66 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.Genesis != nil {
 		c.Genesis = dec.Genesis
 	}
+	if dec.OverrideByzantium != nil {
+		c.OverrideByzantium = dec.OverrideByzantium
+	}
 	if dec.NetworkId != nil {
 		c.NetworkId = *dec.NetworkId
 	}
 	if dec.SyncMode != nil {
 		c.SyncMode = *dec.SyncMode
 	}
+	if dec.NoPruning != nil {
+		c.NoPruning = *dec.NoPruning
+	}
+	if dec.Preimages != nil {
+		c.Preimages = *dec.Preimages
+	}
+	if dec.SafeDepth != nil {
+		c.SafeDepth = *dec.SafeDepth
+	}
+	if dec.TxLookupLimit != nil {
+		c.TxLookupLimit = *dec.TxLookupLimit
+	}
+	if dec.StateSnapshots != nil {
+		c.StateSnapshots = *dec.StateSnapshots
+	}
 	if dec.LightServ != nil {
 		c.LightServ = *dec.LightServ
 	}
 	if dec.LightPeers != nil {
 		c.LightPeers = *dec.LightPeers
 	}
+	if dec.LightCache != nil {
+		c.LightCache = *dec.LightCache
+	}
+	if dec.LightIndex != nil {
+		c.LightIndex = *dec.LightIndex
+	}
+	if dec.LightPriority != nil {
+		c.LightPriority = dec.LightPriority
+	}
+	if dec.LightQuota != nil {
+		c.LightQuota = *dec.LightQuota
+	}
+	if dec.LightPayee != nil {
+		c.LightPayee = *dec.LightPayee
+	}
+	if dec.LightPrice != nil {
+		c.LightPrice = dec.LightPrice
+	}
+	if dec.ServeLimit != nil {
+		c.ServeLimit = *dec.ServeLimit
+	}
+	if dec.NoServeState != nil {
+		c.NoServeState = *dec.NoServeState
+	}
+	if dec.Checkpoint != nil {
+		c.Checkpoint = dec.Checkpoint
+	}
+	if dec.Whitelist != nil {
+		c.Whitelist = dec.Whitelist
+	}
 	if dec.SkipBcVersionCheck != nil {
 		c.SkipBcVersionCheck = *dec.SkipBcVersionCheck
 	}
@@ -127,6 +251,21 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.DatabaseCache != nil {
 		c.DatabaseCache = *dec.DatabaseCache
 	}
+	if dec.TrieCache != nil {
+		c.TrieCache = *dec.TrieCache
+	}
+	if dec.CodeCache != nil {
+		c.CodeCache = *dec.CodeCache
+	}
+	if dec.BlockCache != nil {
+		c.BlockCache = *dec.BlockCache
+	}
+	if dec.DatabaseFreezer != nil {
+		c.DatabaseFreezer = *dec.DatabaseFreezer
+	}
+	if dec.DatabaseCompaction != nil {
+		c.DatabaseCompaction = *dec.DatabaseCompaction
+	}
 	if dec.Etherbase != nil {
 		c.Etherbase = *dec.Etherbase
 	}
@@ -139,6 +278,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.GasPrice != nil {
 		c.GasPrice = dec.GasPrice
 	}
+	if dec.MinerStratum != nil {
+		c.MinerStratum = *dec.MinerStratum
+	}
 	if dec.EthashCacheDir != nil {
 		c.EthashCacheDir = *dec.EthashCacheDir
 	}
@@ -166,6 +308,15 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.EnablePreimageRecording != nil {
 		c.EnablePreimageRecording = *dec.EnablePreimageRecording
 	}
+	if dec.VMStats != nil {
+		c.VMStats = *dec.VMStats
+	}
+	if dec.VMProfileLabels != nil {
+		c.VMProfileLabels = *dec.VMProfileLabels
+	}
+	if dec.RPCCallWorkers != nil {
+		c.RPCCallWorkers = *dec.RPCCallWorkers
+	}
 	if dec.DocRoot != nil {
 		c.DocRoot = *dec.DocRoot
 	}
@@ -30,6 +30,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/misc"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/fetcher"
@@ -46,6 +47,12 @@ const (
 	softResponseLimit = 2 * 1024 * 1024 // Target maximum size of returned blocks, headers or node data.
 	estHeaderRlpSize  = 500             // Approximate size of an RLP encoded block header
 
+	// maxServeRequests is the number of chain data requests served concurrently
+	// across all peers. As a single peer's messages are handled sequentially and
+	// every response is capped at softResponseLimit, this also bounds the memory
+	// held by responses being assembled.
+	maxServeRequests = 16
+
 	// txChanSize is the size of channel listening to TxPreEvent.
 	// The number is referenced from the size of tx pool.
 	txChanSize = 4096
@@ -79,6 +86,10 @@ type ProtocolManager struct {
 	fetcher    *fetcher.Fetcher
 	peers      *peerSet
 
+	serveLimit   *serveLimiter // Limiter of the chain data served to each peer (nil = unlimited)
+	serveSlots   chan struct{} // Semaphore bounding the chain data requests served concurrently
+	noServeState bool          // Flag whether to refuse serving state trie data
+
 	SubProtocols []p2p.Protocol
 
 	eventMux      *event.TypeMux
@@ -113,6 +124,7 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		noMorePeers: make(chan struct{}),
 		txsyncCh:    make(chan *txsync),
 		quitSync:    make(chan struct{}),
+		serveSlots:  make(chan struct{}, maxServeRequests),
 	}
 	// Figure out whether to allow fast sync or not
 	if mode == downloader.FastSync && blockchain.CurrentBlock().NumberU64() > 0 {
@@ -132,9 +144,10 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		// Compatible; initialise the sub-protocol
 		version := version // Closure for the run
 		manager.SubProtocols = append(manager.SubProtocols, p2p.Protocol{
-			Name:    ProtocolName,
-			Version: version,
-			Length:  ProtocolLengths[i],
+			Name:     ProtocolName,
+			Version:  version,
+			Length:   ProtocolLengths[i],
+			Priority: msgPriority,
 			Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
 				peer := manager.newPeer(int(version), p, rw)
 				select {
@@ -151,7 +164,9 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 			},
 			PeerInfo: func(id discover.NodeID) interface{} {
 				if p := manager.peers.Peer(fmt.Sprintf("%x", id[:8])); p != nil {
-					return p.Info()
+					info := p.Info()
+					info.Sync = manager.downloader.PeerStats(p.id)
+					return info
 				}
 				return nil
 			},
@@ -193,6 +208,7 @@ func (pm *ProtocolManager) removePeer(id string) {
 
 	// Unregister the peer from the downloader and Ethereum peer set
 	pm.downloader.UnregisterPeer(id)
+	pm.serveLimit.remove(id)
 	if err := pm.peers.Unregister(id); err != nil {
 		log.Error("Peer removal failed", "peer", id, "err", err)
 	}
@@ -321,6 +337,14 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 	}
 	defer msg.Discard()
 
+	// Wait for a free serving slot if the message is a chain data request
+	switch msg.Code {
+	case GetBlockHeadersMsg, GetBlockBodiesMsg, GetNodeDataMsg, GetReceiptsMsg, GetSnapshotChunksMsg:
+		if !pm.acquireServeSlot() {
+			return p2p.DiscQuitting
+		}
+		defer pm.releaseServeSlot()
+	}
 	// Handle the message depending on its contents
 	switch {
 	case msg.Code == StatusMsg:
@@ -342,7 +366,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			headers []*types.Header
 			unknown bool
 		)
-		for !unknown && len(headers) < int(query.Amount) && bytes < softResponseLimit && len(headers) < downloader.MaxHeaderFetch {
+		for !unknown && len(headers) < int(query.Amount) && bytes < softResponseLimit && len(headers) < downloader.MaxHeaderFetch && pm.serveLimit.allow(p.id) {
 			// Retrieve the next header satisfying the query
 			var origin *types.Header
 			if hashMode {
@@ -356,6 +380,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			number := origin.Number.Uint64()
 			headers = append(headers, origin)
 			bytes += estHeaderRlpSize
+			pm.serveLimit.charge(p.id)
 
 			// Advance to the next header of the query
 			switch {
@@ -471,7 +496,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			bytes  int
 			bodies []rlp.RawValue
 		)
-		for bytes < softResponseLimit && len(bodies) < downloader.MaxBlockFetch {
+		for bytes < softResponseLimit && len(bodies) < downloader.MaxBlockFetch && pm.serveLimit.allow(p.id) {
 			// Retrieve the hash of the next block
 			if err := msgStream.Decode(&hash); err == rlp.EOL {
 				break
@@ -482,6 +507,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			if data := pm.blockchain.GetBodyRLP(hash); len(data) != 0 {
 				bodies = append(bodies, data)
 				bytes += len(data)
+				pm.serveLimit.charge(p.id)
 			}
 		}
 		return p.SendBlockBodiesRLP(bodies)
@@ -513,6 +539,11 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		}
 
 	case p.version >= eth63 && msg.Code == GetNodeDataMsg:
+		// Answer with an empty response if we're not serving state
+		if pm.noServeState {
+			msg.Discard()
+			return p.SendNodeData(nil)
+		}
 		// Decode the retrieval message
 		msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
 		if _, err := msgStream.List(); err != nil {
@@ -524,7 +555,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			bytes int
 			data  [][]byte
 		)
-		for bytes < softResponseLimit && len(data) < downloader.MaxStateFetch {
+		for bytes < softResponseLimit && len(data) < downloader.MaxStateFetch && pm.serveLimit.allow(p.id) {
 			// Retrieve the hash of the next state entry
 			if err := msgStream.Decode(&hash); err == rlp.EOL {
 				break
@@ -532,9 +563,10 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				return errResp(ErrDecode, "msg %v: %v", msg, err)
 			}
 			// Retrieve the requested state entry, stopping if enough was found
-			if entry, err := pm.chaindb.Get(hash.Bytes()); err == nil {
+			if entry, err := pm.blockchain.TrieNode(hash); err == nil {
 				data = append(data, entry)
 				bytes += len(entry)
+				pm.serveLimit.charge(p.id)
 			}
 		}
 		return p.SendNodeData(data)
@@ -562,7 +594,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			bytes    int
 			receipts []rlp.RawValue
 		)
-		for bytes < softResponseLimit && len(receipts) < downloader.MaxReceiptFetch {
+		for bytes < softResponseLimit && len(receipts) < downloader.MaxReceiptFetch && pm.serveLimit.allow(p.id) {
 			// Retrieve the hash of the next block
 			if err := msgStream.Decode(&hash); err == rlp.EOL {
 				break
@@ -582,6 +614,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			} else {
 				receipts = append(receipts, encoded)
 				bytes += len(encoded)
+				pm.serveLimit.charge(p.id)
 			}
 		}
 		return p.SendReceiptsRLP(receipts)
@@ -597,6 +630,69 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			log.Debug("Failed to deliver receipts", "err", err)
 		}
 
+	case p.version >= eth64 && msg.Code == GetSnapshotManifestMsg:
+		// Answer with the latest state snapshot, unless we're not serving state
+		msg.Discard()
+		if pm.noServeState {
+			return p.SendSnapshotManifest(nil)
+		}
+		return p.SendSnapshotManifest(pm.blockchain.Snapshot())
+
+	case p.version >= eth64 && msg.Code == SnapshotManifestMsg:
+		// A state snapshot manifest arrived to one of our previous requests
+		var manifests []*snapshot.Manifest
+		if err := msg.Decode(&manifests); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		// Deliver it to the downloader
+		if err := pm.downloader.DeliverSnapshotManifest(p.id, manifests); err != nil {
+			log.Debug("Failed to deliver snapshot manifest", "err", err)
+		}
+
+	case p.version >= eth64 && msg.Code == GetSnapshotChunksMsg:
+		// Answer with an empty response if we're not serving state
+		if pm.noServeState {
+			msg.Discard()
+			return p.SendSnapshotChunks(nil)
+		}
+		// Decode the retrieval message
+		msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
+		if _, err := msgStream.List(); err != nil {
+			return err
+		}
+		// Gather snapshot chunks until the fetch or network limits is reached
+		var (
+			hash   common.Hash
+			bytes  int
+			chunks [][]byte
+		)
+		for bytes < softResponseLimit && len(chunks) < downloader.MaxSnapshotChunkFetch && pm.serveLimit.allow(p.id) {
+			// Retrieve the hash of the next chunk
+			if err := msgStream.Decode(&hash); err == rlp.EOL {
+				break
+			} else if err != nil {
+				return errResp(ErrDecode, "msg %v: %v", msg, err)
+			}
+			// Retrieve the requested chunk, skipping if unknown to us
+			if chunk := pm.blockchain.SnapshotChunk(hash); chunk != nil {
+				chunks = append(chunks, chunk)
+				bytes += len(chunk)
+				pm.serveLimit.charge(p.id)
+			}
+		}
+		return p.SendSnapshotChunks(chunks)
+
+	case p.version >= eth64 && msg.Code == SnapshotChunksMsg:
+		// A batch of state snapshot chunks arrived to one of our previous requests
+		var chunks [][]byte
+		if err := msg.Decode(&chunks); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		// Deliver all to the downloader
+		if err := pm.downloader.DeliverSnapshotChunks(p.id, chunks); err != nil {
+			log.Debug("Failed to deliver snapshot chunks", "err", err)
+		}
+
 	case msg.Code == NewBlockHashesMsg:
 		var announces newBlockHashesData
 		if err := msg.Decode(&announces); err != nil {
@@ -674,6 +770,30 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 	return nil
 }
 
+// acquireServeSlot blocks until one of the chain data serving slots frees up,
+// returning false if the protocol manager is shutting down in the meantime.
+func (pm *ProtocolManager) acquireServeSlot() bool {
+	select {
+	case pm.serveSlots <- struct{}{}:
+		return true
+	default:
+	}
+	start := time.Now()
+	defer reqQueueTimer.UpdateSince(start)
+
+	select {
+	case pm.serveSlots <- struct{}{}:
+		return true
+	case <-pm.quitSync:
+		return false
+	}
+}
+
+// releaseServeSlot returns a chain data serving slot acquired previously.
+func (pm *ProtocolManager) releaseServeSlot() {
+	<-pm.serveSlots
+}
+
 // BroadcastBlock will either propagate a block to a subset of it's peers, or
 // will only announce it's availability (depending what's requested).
 func (pm *ProtocolManager) BroadcastBlock(block *types.Block, propagate bool) {
@@ -27,6 +27,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -391,6 +392,172 @@ func testGetNodeData(t *testing.T, protocol int) {
 	}
 }
 
+// Tests that state retrievals are answered empty if state serving is disabled.
+func TestGetNodeDataNoServe63(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, downloader.FullSync, 4, nil, nil)
+	pm.noServeState = true
+
+	peer, _ := newTestPeer("peer", 63, pm, true)
+	defer peer.close()
+
+	p2p.Send(peer.app, 0x0d, []common.Hash{pm.blockchain.CurrentBlock().Root()})
+	if err := p2p.ExpectMsg(peer.app, 0x0e, [][]byte{}); err != nil {
+		t.Errorf("node data mismatch: %v", err)
+	}
+}
+
+// Tests that the latest state snapshot and its chunks can be retrieved.
+func TestGetSnapshot64(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, downloader.FullSync, 4, nil, nil)
+	peer, _ := newTestPeer("peer", 64, pm, true)
+	defer peer.close()
+
+	// No snapshot was generated yet, so none should be served
+	p2p.Send(peer.app, 0x11, []interface{}{})
+	if err := p2p.ExpectMsg(peer.app, 0x12, []*snapshot.Manifest{}); err != nil {
+		t.Errorf("missing manifest mismatch: %v", err)
+	}
+	// Snapshot the state of a canonical block and retrieve it
+	manifest := makeTestSnapshot(t, pm, 2)
+
+	p2p.Send(peer.app, 0x11, []interface{}{})
+	if err := p2p.ExpectMsg(peer.app, 0x12, []*snapshot.Manifest{manifest}); err != nil {
+		t.Errorf("manifest mismatch: %v", err)
+	}
+	// Request the chunks along with an unknown one, which should be skipped
+	chunks := make([][]byte, len(manifest.Chunks))
+	for i, hash := range manifest.Chunks {
+		chunks[i] = snapshot.ReadChunk(pm.chaindb, hash)
+	}
+	p2p.Send(peer.app, 0x13, append([]common.Hash{{0x01}}, manifest.Chunks...))
+	if err := p2p.ExpectMsg(peer.app, 0x14, chunks); err != nil {
+		t.Errorf("chunks mismatch: %v", err)
+	}
+}
+
+// Tests that state snapshots are not served if state serving is disabled.
+func TestGetSnapshotNoServe64(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, downloader.FullSync, 4, nil, nil)
+	pm.noServeState = true
+	manifest := makeTestSnapshot(t, pm, 2)
+
+	peer, _ := newTestPeer("peer", 64, pm, true)
+	defer peer.close()
+
+	p2p.Send(peer.app, 0x11, []interface{}{})
+	if err := p2p.ExpectMsg(peer.app, 0x12, []*snapshot.Manifest{}); err != nil {
+		t.Errorf("manifest mismatch: %v", err)
+	}
+	p2p.Send(peer.app, 0x13, manifest.Chunks)
+	if err := p2p.ExpectMsg(peer.app, 0x14, [][]byte{}); err != nil {
+		t.Errorf("chunks mismatch: %v", err)
+	}
+}
+
+// makeTestSnapshot generates and stores a state snapshot of a canonical block.
+func makeTestSnapshot(t *testing.T, pm *ProtocolManager, number uint64) *snapshot.Manifest {
+	block := pm.blockchain.GetBlockByNumber(number)
+	manifest, err := snapshot.Generate(state.NewDatabase(pm.chaindb), number, block.Hash(), block.Root(), func(hash common.Hash, blob []byte) error {
+		return snapshot.WriteChunk(pm.chaindb, hash, blob)
+	})
+	if err != nil {
+		t.Fatalf("failed to generate snapshot: %v", err)
+	}
+	if err := snapshot.WriteManifest(pm.chaindb, manifest); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+	return manifest
+}
+
+// Tests that the number of headers served is capped by the serving limit.
+func TestGetBlockHeadersServeLimit(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, downloader.FullSync, 32, nil, nil)
+	pm.serveLimit = newServeLimiter(8)
+
+	peer, _ := newTestPeer("peer", 63, pm, true)
+	defer peer.close()
+
+	headers := make([]*types.Header, 8)
+	for i := range headers {
+		headers[i] = pm.blockchain.GetHeaderByNumber(uint64(i))
+	}
+	p2p.Send(peer.app, 0x03, &getBlockHeadersData{Origin: hashOrNumber{Number: 0}, Amount: 16})
+	if err := p2p.ExpectMsg(peer.app, 0x04, headers); err != nil {
+		t.Errorf("headers mismatch: %v", err)
+	}
+}
+
+// Tests that the serving limit is tracked separately for each peer and that only
+// items actually served count towards it.
+func TestServeLimitPerPeer(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, downloader.FullSync, 32, nil, nil)
+	pm.serveLimit = newServeLimiter(8)
+
+	headers := make([]*types.Header, 8)
+	for i := range headers {
+		headers[i] = pm.blockchain.GetHeaderByNumber(uint64(i))
+	}
+	// Request unknown bodies, which must not consume any allowance
+	greedy, _ := newTestPeer("greedy", 63, pm, true)
+	defer greedy.close()
+
+	unknown := make([]common.Hash, 8)
+	for i := range unknown {
+		unknown[i][0] = byte(i + 1)
+	}
+	p2p.Send(greedy.app, 0x05, unknown)
+	if err := p2p.ExpectMsg(greedy.app, 0x06, blockBodiesData{}); err != nil {
+		t.Errorf("unknown bodies mismatch: %v", err)
+	}
+	// Exhaust the allowance of the first peer, ensuring a second one is unaffected
+	for _, peer := range []string{"greedy", "other"} {
+		p := greedy
+		if peer != "greedy" {
+			p, _ = newTestPeer(peer, 63, pm, true)
+			defer p.close()
+		}
+		p2p.Send(p.app, 0x03, &getBlockHeadersData{Origin: hashOrNumber{Number: 0}, Amount: 16})
+		if err := p2p.ExpectMsg(p.app, 0x04, headers); err != nil {
+			t.Errorf("peer %s: headers mismatch: %v", peer, err)
+		}
+	}
+}
+
+// Tests that chain data requests wait for a free serving slot if all of them are
+// taken by other requests.
+func TestServeSlotsExhausted(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, downloader.FullSync, 4, nil, nil)
+	peer, _ := newTestPeer("peer", 63, pm, true)
+	defer peer.close()
+
+	// Take all the serving slots and issue a request
+	for i := 0; i < maxServeRequests; i++ {
+		pm.serveSlots <- struct{}{}
+	}
+	headers := []*types.Header{pm.blockchain.GetHeaderByNumber(0)}
+
+	errc := make(chan error, 1)
+	go func() {
+		p2p.Send(peer.app, 0x03, &getBlockHeadersData{Origin: hashOrNumber{Number: 0}, Amount: 1})
+		errc <- p2p.ExpectMsg(peer.app, 0x04, headers)
+	}()
+	select {
+	case err := <-errc:
+		t.Fatalf("request served without a free slot: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	// Free up a slot and ensure the request is served
+	pm.releaseServeSlot()
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Errorf("headers mismatch: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("request not served after freeing a slot")
+	}
+}
+
 // Tests that the transaction receipts can be retrieved based on hashes.
 func TestGetReceipt63(t *testing.T) { testGetReceipt(t, 63) }
 
@@ -474,7 +641,7 @@ func testDAOChallenge(t *testing.T, localForked, remoteForked bool, timeout bool
 		config        = &params.ChainConfig{DAOForkBlock: big.NewInt(1), DAOForkSupport: localForked}
 		gspec         = &core.Genesis{Config: config}
 		genesis       = gspec.MustCommit(db)
-		blockchain, _ = core.NewBlockChain(db, config, pow, vm.Config{})
+		blockchain, _ = core.NewBlockChain(db, nil, config, pow, vm.Config{})
 	)
 	pm, err := NewProtocolManager(config, downloader.FullSync, DefaultConfig.NetworkId, evmux, new(testTxPool), pow, blockchain, db)
 	if err != nil {
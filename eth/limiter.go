@@ -0,0 +1,111 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"sync"
+	"time"
+)
+
+// serveLimiter caps the number of chain data items (headers, bodies, receipts and
+// state entries) served to each remote peer per second. Every peer has its own
+// token bucket, so a single greedy peer cannot starve the others, and tokens are
+// only consumed for items actually served. A nil limiter permits everything.
+type serveLimiter struct {
+	rate    float64                 // Number of items permitted per peer per second
+	buckets map[string]*serveBucket // Token buckets of the peers served so far
+	lock    sync.Mutex
+}
+
+// serveBucket is the token bucket tracking the allowance of a single peer.
+type serveBucket struct {
+	tokens float64   // Number of items currently permitted
+	last   time.Time // Time the token bucket was last refilled
+}
+
+// newServeLimiter creates a limiter permitting the given number of items to be
+// served to each peer per second, or nil if serving is unlimited.
+func newServeLimiter(rate int) *serveLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &serveLimiter{
+		rate:    float64(rate),
+		buckets: make(map[string]*serveBucket),
+	}
+}
+
+// bucket returns the token bucket of a peer refilled with the allowance accumulated
+// since it was last used, creating a full one for new peers. The lock is assumed
+// to be held.
+func (l *serveLimiter) bucket(id string) *serveBucket {
+	now := time.Now()
+
+	b := l.buckets[id]
+	if b == nil {
+		b = &serveBucket{tokens: l.rate, last: now}
+		l.buckets[id] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.rate {
+		b.tokens = l.rate
+	}
+	b.last = now
+	return b
+}
+
+// allow checks whether another item may be served to the given peer, without
+// consuming any of its allowance.
+func (l *serveLimiter) allow(id string) bool {
+	if l == nil {
+		return true
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.bucket(id).tokens < 1 {
+		reqThrottledMeter.Mark(1)
+		return false
+	}
+	return true
+}
+
+// charge consumes the allowance of an item served to the given peer.
+func (l *serveLimiter) charge(id string) {
+	if l == nil {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if b := l.bucket(id); b.tokens >= 1 {
+		b.tokens--
+	} else {
+		b.tokens = 0
+	}
+}
+
+// remove drops the token bucket of a disconnected peer.
+func (l *serveLimiter) remove(id string) {
+	if l == nil {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	delete(l.buckets, id)
+}
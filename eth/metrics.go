@@ -22,38 +22,44 @@ import (
 )
 
 var (
-	propTxnInPacketsMeter     = metrics.NewMeter("eth/prop/txns/in/packets")
-	propTxnInTrafficMeter     = metrics.NewMeter("eth/prop/txns/in/traffic")
-	propTxnOutPacketsMeter    = metrics.NewMeter("eth/prop/txns/out/packets")
-	propTxnOutTrafficMeter    = metrics.NewMeter("eth/prop/txns/out/traffic")
-	propHashInPacketsMeter    = metrics.NewMeter("eth/prop/hashes/in/packets")
-	propHashInTrafficMeter    = metrics.NewMeter("eth/prop/hashes/in/traffic")
-	propHashOutPacketsMeter   = metrics.NewMeter("eth/prop/hashes/out/packets")
-	propHashOutTrafficMeter   = metrics.NewMeter("eth/prop/hashes/out/traffic")
-	propBlockInPacketsMeter   = metrics.NewMeter("eth/prop/blocks/in/packets")
-	propBlockInTrafficMeter   = metrics.NewMeter("eth/prop/blocks/in/traffic")
-	propBlockOutPacketsMeter  = metrics.NewMeter("eth/prop/blocks/out/packets")
-	propBlockOutTrafficMeter  = metrics.NewMeter("eth/prop/blocks/out/traffic")
-	reqHeaderInPacketsMeter   = metrics.NewMeter("eth/req/headers/in/packets")
-	reqHeaderInTrafficMeter   = metrics.NewMeter("eth/req/headers/in/traffic")
-	reqHeaderOutPacketsMeter  = metrics.NewMeter("eth/req/headers/out/packets")
-	reqHeaderOutTrafficMeter  = metrics.NewMeter("eth/req/headers/out/traffic")
-	reqBodyInPacketsMeter     = metrics.NewMeter("eth/req/bodies/in/packets")
-	reqBodyInTrafficMeter     = metrics.NewMeter("eth/req/bodies/in/traffic")
-	reqBodyOutPacketsMeter    = metrics.NewMeter("eth/req/bodies/out/packets")
-	reqBodyOutTrafficMeter    = metrics.NewMeter("eth/req/bodies/out/traffic")
-	reqStateInPacketsMeter    = metrics.NewMeter("eth/req/states/in/packets")
-	reqStateInTrafficMeter    = metrics.NewMeter("eth/req/states/in/traffic")
-	reqStateOutPacketsMeter   = metrics.NewMeter("eth/req/states/out/packets")
-	reqStateOutTrafficMeter   = metrics.NewMeter("eth/req/states/out/traffic")
-	reqReceiptInPacketsMeter  = metrics.NewMeter("eth/req/receipts/in/packets")
-	reqReceiptInTrafficMeter  = metrics.NewMeter("eth/req/receipts/in/traffic")
-	reqReceiptOutPacketsMeter = metrics.NewMeter("eth/req/receipts/out/packets")
-	reqReceiptOutTrafficMeter = metrics.NewMeter("eth/req/receipts/out/traffic")
-	miscInPacketsMeter        = metrics.NewMeter("eth/misc/in/packets")
-	miscInTrafficMeter        = metrics.NewMeter("eth/misc/in/traffic")
-	miscOutPacketsMeter       = metrics.NewMeter("eth/misc/out/packets")
-	miscOutTrafficMeter       = metrics.NewMeter("eth/misc/out/traffic")
+	propTxnInPacketsMeter      = metrics.NewMeter("eth/prop/txns/in/packets")
+	propTxnInTrafficMeter      = metrics.NewMeter("eth/prop/txns/in/traffic")
+	propTxnOutPacketsMeter     = metrics.NewMeter("eth/prop/txns/out/packets")
+	propTxnOutTrafficMeter     = metrics.NewMeter("eth/prop/txns/out/traffic")
+	propHashInPacketsMeter     = metrics.NewMeter("eth/prop/hashes/in/packets")
+	propHashInTrafficMeter     = metrics.NewMeter("eth/prop/hashes/in/traffic")
+	propHashOutPacketsMeter    = metrics.NewMeter("eth/prop/hashes/out/packets")
+	propHashOutTrafficMeter    = metrics.NewMeter("eth/prop/hashes/out/traffic")
+	propBlockInPacketsMeter    = metrics.NewMeter("eth/prop/blocks/in/packets")
+	propBlockInTrafficMeter    = metrics.NewMeter("eth/prop/blocks/in/traffic")
+	propBlockOutPacketsMeter   = metrics.NewMeter("eth/prop/blocks/out/packets")
+	propBlockOutTrafficMeter   = metrics.NewMeter("eth/prop/blocks/out/traffic")
+	reqHeaderInPacketsMeter    = metrics.NewMeter("eth/req/headers/in/packets")
+	reqHeaderInTrafficMeter    = metrics.NewMeter("eth/req/headers/in/traffic")
+	reqHeaderOutPacketsMeter   = metrics.NewMeter("eth/req/headers/out/packets")
+	reqHeaderOutTrafficMeter   = metrics.NewMeter("eth/req/headers/out/traffic")
+	reqBodyInPacketsMeter      = metrics.NewMeter("eth/req/bodies/in/packets")
+	reqBodyInTrafficMeter      = metrics.NewMeter("eth/req/bodies/in/traffic")
+	reqBodyOutPacketsMeter     = metrics.NewMeter("eth/req/bodies/out/packets")
+	reqBodyOutTrafficMeter     = metrics.NewMeter("eth/req/bodies/out/traffic")
+	reqStateInPacketsMeter     = metrics.NewMeter("eth/req/states/in/packets")
+	reqStateInTrafficMeter     = metrics.NewMeter("eth/req/states/in/traffic")
+	reqStateOutPacketsMeter    = metrics.NewMeter("eth/req/states/out/packets")
+	reqStateOutTrafficMeter    = metrics.NewMeter("eth/req/states/out/traffic")
+	reqReceiptInPacketsMeter   = metrics.NewMeter("eth/req/receipts/in/packets")
+	reqReceiptInTrafficMeter   = metrics.NewMeter("eth/req/receipts/in/traffic")
+	reqReceiptOutPacketsMeter  = metrics.NewMeter("eth/req/receipts/out/packets")
+	reqReceiptOutTrafficMeter  = metrics.NewMeter("eth/req/receipts/out/traffic")
+	reqSnapshotInPacketsMeter  = metrics.NewMeter("eth/req/snapshots/in/packets")
+	reqSnapshotInTrafficMeter  = metrics.NewMeter("eth/req/snapshots/in/traffic")
+	reqSnapshotOutPacketsMeter = metrics.NewMeter("eth/req/snapshots/out/packets")
+	reqSnapshotOutTrafficMeter = metrics.NewMeter("eth/req/snapshots/out/traffic")
+	miscInPacketsMeter         = metrics.NewMeter("eth/misc/in/packets")
+	miscInTrafficMeter         = metrics.NewMeter("eth/misc/in/traffic")
+	miscOutPacketsMeter        = metrics.NewMeter("eth/misc/out/packets")
+	miscOutTrafficMeter        = metrics.NewMeter("eth/misc/out/traffic")
+	reqThrottledMeter          = metrics.NewMeter("eth/req/throttled")
+	reqQueueTimer              = metrics.NewTimer("eth/req/queue")
 )
 
 // meteredMsgReadWriter is a wrapper around a p2p.MsgReadWriter, capable of
@@ -97,6 +103,9 @@ func (rw *meteredMsgReadWriter) ReadMsg() (p2p.Msg, error) {
 	case rw.version >= eth63 && msg.Code == ReceiptsMsg:
 		packets, traffic = reqReceiptInPacketsMeter, reqReceiptInTrafficMeter
 
+	case rw.version >= eth64 && (msg.Code == SnapshotManifestMsg || msg.Code == SnapshotChunksMsg):
+		packets, traffic = reqSnapshotInPacketsMeter, reqSnapshotInTrafficMeter
+
 	case msg.Code == NewBlockHashesMsg:
 		packets, traffic = propHashInPacketsMeter, propHashInTrafficMeter
 	case msg.Code == NewBlockMsg:
@@ -124,6 +133,9 @@ func (rw *meteredMsgReadWriter) WriteMsg(msg p2p.Msg) error {
 	case rw.version >= eth63 && msg.Code == ReceiptsMsg:
 		packets, traffic = reqReceiptOutPacketsMeter, reqReceiptOutTrafficMeter
 
+	case rw.version >= eth64 && (msg.Code == SnapshotManifestMsg || msg.Code == SnapshotChunksMsg):
+		packets, traffic = reqSnapshotOutPacketsMeter, reqSnapshotOutTrafficMeter
+
 	case msg.Code == NewBlockHashesMsg:
 		packets, traffic = propHashOutPacketsMeter, propHashOutTrafficMeter
 	case msg.Code == NewBlockMsg:
@@ -24,7 +24,9 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/rlp"
 	"gopkg.in/fatih/set.v0"
@@ -45,9 +47,10 @@ const (
 // PeerInfo represents a short summary of the Ethereum sub-protocol metadata known
 // about a connected peer.
 type PeerInfo struct {
-	Version    int      `json:"version"`    // Ethereum protocol version negotiated
-	Difficulty *big.Int `json:"difficulty"` // Total difficulty of the peer's blockchain
-	Head       string   `json:"head"`       // SHA3 hash of the peer's best owned block
+	Version    int                   `json:"version"`        // Ethereum protocol version negotiated
+	Difficulty *big.Int              `json:"difficulty"`     // Total difficulty of the peer's blockchain
+	Head       string                `json:"head"`           // SHA3 hash of the peer's best owned block
+	Sync       *downloader.PeerStats `json:"sync,omitempty"` // Data retrieval statistics of the peer
 }
 
 type peer struct {
@@ -187,6 +190,22 @@ func (p *peer) SendReceiptsRLP(receipts []rlp.RawValue) error {
 	return p2p.Send(p.rw, ReceiptsMsg, receipts)
 }
 
+// SendSnapshotManifest sends the manifest of the latest state snapshot, or an
+// empty list if there is none.
+func (p *peer) SendSnapshotManifest(manifest *snapshot.Manifest) error {
+	manifests := []*snapshot.Manifest{}
+	if manifest != nil {
+		manifests = append(manifests, manifest)
+	}
+	return p2p.Send(p.rw, SnapshotManifestMsg, manifests)
+}
+
+// SendSnapshotChunks sends a batch of state snapshot chunks, corresponding to
+// the hashes requested.
+func (p *peer) SendSnapshotChunks(chunks [][]byte) error {
+	return p2p.Send(p.rw, SnapshotChunksMsg, chunks)
+}
+
 // RequestOneHeader is a wrapper around the header query functions to fetch a
 // single header. It is used solely by the fetcher.
 func (p *peer) RequestOneHeader(hash common.Hash) error {
@@ -228,6 +247,20 @@ func (p *peer) RequestReceipts(hashes []common.Hash) error {
 	return p2p.Send(p.rw, GetReceiptsMsg, hashes)
 }
 
+// RequestSnapshotManifest fetches the manifest of the latest state snapshot of
+// a remote node.
+func (p *peer) RequestSnapshotManifest() error {
+	p.Log().Debug("Fetching state snapshot manifest")
+	return p2p.Send(p.rw, GetSnapshotManifestMsg, []interface{}{})
+}
+
+// RequestSnapshotChunks fetches a batch of state snapshot chunks from a remote
+// node, corresponding to the specified hashes.
+func (p *peer) RequestSnapshotChunks(hashes []common.Hash) error {
+	p.Log().Debug("Fetching batch of state snapshot chunks", "count", len(hashes))
+	return p2p.Send(p.rw, GetSnapshotChunksMsg, hashes)
+}
+
 // Handshake executes the eth protocol handshake, negotiating version number,
 // network IDs, difficulties, head and genesis blocks.
 func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash) error {
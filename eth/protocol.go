@@ -25,6 +25,7 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
@@ -32,16 +33,17 @@ import (
 const (
 	eth62 = 62
 	eth63 = 63
+	eth64 = 64
 )
 
 // Official short name of the protocol used during capability negotiation.
 var ProtocolName = "eth"
 
 // Supported versions of the eth protocol (first is primary).
-var ProtocolVersions = []uint{eth63, eth62}
+var ProtocolVersions = []uint{eth64, eth63, eth62}
 
 // Number of implemented message corresponding to different protocol versions.
-var ProtocolLengths = []uint64{17, 8}
+var ProtocolLengths = []uint64{21, 17, 8}
 
 const ProtocolMaxMsgSize = 10 * 1024 * 1024 // Maximum cap on the size of a protocol message
 
@@ -62,8 +64,27 @@ const (
 	NodeDataMsg    = 0x0e
 	GetReceiptsMsg = 0x0f
 	ReceiptsMsg    = 0x10
+
+	// Protocol messages belonging to eth/64
+	GetSnapshotManifestMsg = 0x11
+	SnapshotManifestMsg    = 0x12
+	GetSnapshotChunksMsg   = 0x13
+	SnapshotChunksMsg      = 0x14
 )
 
+// msgPriority classifies eth messages for the p2p send queue, so block and
+// transaction propagation isn't held up by bulk sync data on congested links.
+func msgPriority(code uint64) p2p.MsgPriority {
+	switch code {
+	case NewBlockHashesMsg, NewBlockMsg, TxMsg:
+		return p2p.PriorityHigh
+	case NodeDataMsg, ReceiptsMsg, BlockBodiesMsg, SnapshotChunksMsg:
+		return p2p.PriorityBulk
+	default:
+		return p2p.PriorityNormal
+	}
+}
+
 type errCode int
 
 const (
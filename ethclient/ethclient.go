@@ -23,6 +23,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
@@ -266,6 +267,12 @@ type rpcProgress struct {
 	HighestBlock  hexutil.Uint64
 	PulledStates  hexutil.Uint64
 	KnownStates   hexutil.Uint64
+	Stages        struct {
+		Headers  hexutil.Uint64
+		Bodies   hexutil.Uint64
+		Receipts hexutil.Uint64
+	}
+	ETA hexutil.Uint64
 }
 
 // SyncProgress retrieves the current progress of the sync algorithm. If there's
@@ -285,11 +292,15 @@ func (ec *Client) SyncProgress(ctx context.Context) (*ethereum.SyncProgress, err
 		return nil, err
 	}
 	return &ethereum.SyncProgress{
-		StartingBlock: uint64(progress.StartingBlock),
-		CurrentBlock:  uint64(progress.CurrentBlock),
-		HighestBlock:  uint64(progress.HighestBlock),
-		PulledStates:  uint64(progress.PulledStates),
-		KnownStates:   uint64(progress.KnownStates),
+		StartingBlock:  uint64(progress.StartingBlock),
+		CurrentBlock:   uint64(progress.CurrentBlock),
+		HighestBlock:   uint64(progress.HighestBlock),
+		PulledStates:   uint64(progress.PulledStates),
+		KnownStates:    uint64(progress.KnownStates),
+		PulledHeaders:  uint64(progress.Stages.Headers),
+		PulledBodies:   uint64(progress.Stages.Bodies),
+		PulledReceipts: uint64(progress.Stages.Receipts),
+		EstimatedTime:  time.Duration(progress.ETA) * time.Second,
 	}, nil
 }
 
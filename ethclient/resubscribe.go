@@ -0,0 +1,234 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethclient
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// resubscribeBuffer is the number of notifications buffered from the underlying
+// subscription while previously missed ones are being delivered.
+const resubscribeBuffer = 128
+
+// ResubscribeNewHead subscribes to notifications about the current blockchain head
+// like SubscribeNewHead, but transparently re-establishes the subscription if it
+// fails (e.g. the websocket connection dropped), waiting at most backoffMax between
+// attempts. After reconnecting, the current head is delivered if it changed while
+// disconnected, even if no head was delivered before. Heads already delivered are
+// not delivered again.
+//
+// The returned subscription only ends when unsubscribed.
+func (ec *Client) ResubscribeNewHead(backoffMax time.Duration, ch chan<- *types.Header) ethereum.Subscription {
+	var (
+		started bool        // Whether a subscription was already established
+		last    common.Hash // Hash of the last delivered head, shared by the successive subscriptions
+	)
+
+	return event.Resubscribe(backoffMax, func(ctx context.Context) (event.Subscription, error) {
+		heads := make(chan *types.Header, resubscribeBuffer)
+		sub, err := ec.SubscribeNewHead(ctx, heads)
+		if err != nil {
+			log.Debug("Failed to subscribe to chain heads", "err", err)
+			return nil, err
+		}
+		// Subscription established, catch up with the head missed while disconnected
+		var missed *types.Header
+		if started {
+			if missed, err = ec.HeaderByNumber(ctx, nil); err != nil {
+				sub.Unsubscribe()
+				return nil, err
+			}
+		}
+		started = true
+
+		return event.NewSubscription(func(quit <-chan struct{}) error {
+			defer sub.Unsubscribe()
+
+			deliver := func(head *types.Header) bool {
+				if hash := head.Hash(); hash != last {
+					select {
+					case ch <- head:
+						last = hash
+					case <-quit:
+						return false
+					}
+				}
+				return true
+			}
+			if missed != nil && !deliver(missed) {
+				return nil
+			}
+			for {
+				select {
+				case head := <-heads:
+					if !deliver(head) {
+						return nil
+					}
+				case err := <-sub.Err():
+					log.Debug("Chain head subscription failed", "err", err)
+					return err
+				case <-quit:
+					return nil
+				}
+			}
+		}), nil
+	})
+}
+
+// ResubscribeFilterLogs subscribes to the results of a streaming filter query like
+// SubscribeFilterLogs, but transparently re-establishes the subscription if it fails
+// (e.g. the websocket connection dropped), waiting at most backoffMax between
+// attempts. After reconnecting, the logs matching the query since the last seen
+// block are retrieved, so that none are missed while disconnected. Until a log is
+// seen, they are retrieved since the block following the head at the time of the
+// first subscription, or since q.FromBlock if that is later. Logs already
+// delivered are not delivered again. Note, logs removed by a reorg while being
+// disconnected are not reported.
+//
+// The returned subscription only ends when unsubscribed.
+func (ec *Client) ResubscribeFilterLogs(backoffMax time.Duration, q ethereum.FilterQuery, ch chan<- types.Log) ethereum.Subscription {
+	tracker := newLogTracker()
+
+	return event.Resubscribe(backoffMax, func(ctx context.Context) (event.Subscription, error) {
+		logs := make(chan types.Log, resubscribeBuffer)
+		sub, err := ec.SubscribeFilterLogs(ctx, q, logs)
+		if err != nil {
+			log.Debug("Failed to subscribe to logs", "err", err)
+			return nil, err
+		}
+		// Subscription established, record the initial position on the first one or
+		// retrieve the logs missed while disconnected on the successive ones
+		var missed []types.Log
+		if !tracker.positioned {
+			head, err := ec.HeaderByNumber(ctx, nil)
+			if err != nil {
+				sub.Unsubscribe()
+				return nil, err
+			}
+			from := head.Number.Uint64() + 1
+			if q.FromBlock != nil && q.FromBlock.Uint64() > from {
+				from = q.FromBlock.Uint64()
+			}
+			tracker.position(from)
+		} else {
+			query := q
+			query.FromBlock, query.ToBlock = new(big.Int).SetUint64(tracker.block), nil
+			if missed, err = ec.FilterLogs(ctx, query); err != nil {
+				sub.Unsubscribe()
+				return nil, err
+			}
+		}
+		return event.NewSubscription(func(quit <-chan struct{}) error {
+			defer sub.Unsubscribe()
+
+			deliver := func(l types.Log) bool {
+				if !tracker.fresh(l) {
+					return true
+				}
+				select {
+				case ch <- l:
+					tracker.mark(l)
+					return true
+				case <-quit:
+					return false
+				}
+			}
+			for _, l := range missed {
+				if !deliver(l) {
+					return nil
+				}
+			}
+			for {
+				select {
+				case l := <-logs:
+					if !deliver(l) {
+						return nil
+					}
+				case err := <-sub.Err():
+					log.Debug("Log subscription failed", "err", err)
+					return err
+				case <-quit:
+					return nil
+				}
+			}
+		}), nil
+	})
+}
+
+// logKey uniquely identifies a log within a block.
+type logKey struct {
+	block common.Hash
+	index uint
+}
+
+// logTracker keeps track of the position of the last delivered log, to filter out
+// the ones replayed when resubscribing.
+type logTracker struct {
+	positioned bool                // Whether the initial position was recorded
+	started    bool                // Whether any log was delivered yet
+	block      uint64              // Number of the block the last delivered log is in (or to catch up from)
+	seen       map[logKey]struct{} // Logs delivered from the last block
+}
+
+// newLogTracker creates a tracker with no logs delivered yet.
+func newLogTracker() *logTracker {
+	return &logTracker{seen: make(map[logKey]struct{})}
+}
+
+// position records the block to catch up from if the subscription fails before
+// any log is delivered.
+func (t *logTracker) position(block uint64) {
+	t.positioned, t.block = true, block
+}
+
+// fresh reports whether a log wasn't delivered yet. Logs removed by a reorg are
+// always delivered.
+func (t *logTracker) fresh(l types.Log) bool {
+	if l.Removed || !t.started || l.BlockNumber > t.block {
+		return true
+	}
+	if l.BlockNumber < t.block {
+		return false
+	}
+	_, seen := t.seen[logKey{l.BlockHash, l.Index}]
+	return !seen
+}
+
+// mark records the delivery of a log. Removed logs rewind the tracker to their
+// block, allowing the logs of the new chain to pass.
+func (t *logTracker) mark(l types.Log) {
+	if l.Removed {
+		if t.started && l.BlockNumber <= t.block {
+			t.block = l.BlockNumber
+			t.seen = make(map[logKey]struct{})
+		}
+		return
+	}
+	if !t.started || l.BlockNumber > t.block {
+		t.started, t.block = true, l.BlockNumber
+		t.seen = make(map[logKey]struct{})
+	}
+	t.seen[logKey{l.BlockHash, l.Index}] = struct{}{}
+}
@@ -0,0 +1,264 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethclient
+
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ResubTestService is a minimal eth API serving chain head and log subscriptions.
+type ResubTestService struct {
+	lock    sync.Mutex
+	head    *types.Header           // Current head returned by eth_getBlockByNumber
+	subs    chan chan *types.Header // Notified of every new head subscription
+	logSubs chan struct{}           // Notified of every new log subscription
+	logs    []types.Log             // Logs returned by eth_getLogs
+	queries []interface{}           // Starting blocks of the eth_getLogs queries
+}
+
+func (s *ResubTestService) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, _ := rpc.NotifierFromContext(ctx)
+	sub := notifier.CreateSubscription()
+
+	heads := make(chan *types.Header)
+	go func() {
+		for {
+			select {
+			case head := <-heads:
+				notifier.Notify(sub.ID, head)
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+	s.subs <- heads
+	return sub, nil
+}
+
+func (s *ResubTestService) Logs(ctx context.Context, crit map[string]interface{}) (*rpc.Subscription, error) {
+	notifier, _ := rpc.NotifierFromContext(ctx)
+	sub := notifier.CreateSubscription()
+	s.logSubs <- struct{}{}
+	return sub, nil
+}
+
+func (s *ResubTestService) GetLogs(crit map[string]interface{}) ([]types.Log, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.queries = append(s.queries, crit["fromBlock"])
+	return s.logs, nil
+}
+
+func (s *ResubTestService) GetBlockByNumber(number string, full bool) (*types.Header, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.head, nil
+}
+
+// newResubTestHeader creates a chain head with the given number.
+func newResubTestHeader(number int64) *types.Header {
+	return &types.Header{
+		Number:     big.NewInt(number),
+		Difficulty: big.NewInt(1),
+		GasLimit:   big.NewInt(0),
+		GasUsed:    big.NewInt(0),
+		Time:       big.NewInt(number),
+	}
+}
+
+// startResubTestServer serves the test API on the given IPC endpoint.
+func startResubTestServer(t *testing.T, endpoint string, service *ResubTestService) (*rpc.Server, net.Listener) {
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", service); err != nil {
+		t.Fatalf("failed to register test service: %v", err)
+	}
+	listener, err := rpc.CreateIPCListener(endpoint)
+	if err != nil {
+		t.Fatalf("failed to create IPC listener: %v", err)
+	}
+	go server.ServeListener(listener)
+	return server, listener
+}
+
+// Tests that head subscriptions are re-established after the connection drops,
+// catching up with the missed head without delivering any twice.
+func TestResubscribeNewHead(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethclient-resub-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	endpoint := filepath.Join(dir, "test.ipc")
+
+	service := &ResubTestService{head: newResubTestHeader(1), subs: make(chan chan *types.Header, 2)}
+	server, listener := startResubTestServer(t, endpoint, service)
+
+	client, err := rpc.DialIPC(context.Background(), endpoint)
+	if err != nil {
+		t.Fatalf("failed to dial test server: %v", err)
+	}
+	defer client.Close()
+
+	heads := make(chan *types.Header)
+	sub := NewClient(client).ResubscribeNewHead(100*time.Millisecond, heads)
+	defer sub.Unsubscribe()
+
+	expect := func(number int64) {
+		select {
+		case head := <-heads:
+			if head.Number.Int64() != number {
+				t.Fatalf("head mismatch: have %d, want %d", head.Number, number)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for head %d", number)
+		}
+	}
+	// Deliver a head through the original subscription
+	notify := <-service.subs
+	notify <- newResubTestHeader(1)
+	expect(1)
+
+	// Drop the connection and advance the chain while disconnected
+	listener.Close()
+	server.Stop()
+
+	service.lock.Lock()
+	service.head = newResubTestHeader(2)
+	service.lock.Unlock()
+
+	server, listener = startResubTestServer(t, endpoint, service)
+	defer server.Stop()
+	defer listener.Close()
+
+	// The missed head should be delivered after resubscribing, but only once
+	notify = <-service.subs
+	expect(2)
+
+	notify <- newResubTestHeader(2)
+	notify <- newResubTestHeader(3)
+	expect(3)
+}
+
+// Tests that a log subscription failing before any log was delivered catches up
+// from the head at the time it was first established.
+func TestResubscribeFilterLogsNoneSeen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethclient-resub-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	endpoint := filepath.Join(dir, "test.ipc")
+
+	service := &ResubTestService{head: newResubTestHeader(5), logSubs: make(chan struct{}, 2)}
+	server, listener := startResubTestServer(t, endpoint, service)
+
+	client, err := rpc.DialIPC(context.Background(), endpoint)
+	if err != nil {
+		t.Fatalf("failed to dial test server: %v", err)
+	}
+	defer client.Close()
+
+	logs := make(chan types.Log)
+	sub := NewClient(client).ResubscribeFilterLogs(100*time.Millisecond, ethereum.FilterQuery{}, logs)
+	defer sub.Unsubscribe()
+
+	// Drop the connection before any log is delivered, emitting one while disconnected
+	<-service.logSubs
+	time.Sleep(100 * time.Millisecond) // Make sure the initial head was retrieved
+
+	listener.Close()
+	server.Stop()
+
+	missed := types.Log{BlockNumber: 7, BlockHash: common.Hash{7}, Topics: []common.Hash{}, Data: []byte{}}
+	service.lock.Lock()
+	service.logs = []types.Log{missed}
+	service.lock.Unlock()
+
+	server, listener = startResubTestServer(t, endpoint, service)
+	defer server.Stop()
+	defer listener.Close()
+
+	// The missed log should be retrieved from the block after the original head
+	<-service.logSubs
+	select {
+	case l := <-logs:
+		if l.BlockNumber != missed.BlockNumber || l.BlockHash != missed.BlockHash {
+			t.Fatalf("log mismatch: have #%d %x, want #%d %x", l.BlockNumber, l.BlockHash, missed.BlockNumber, missed.BlockHash)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for the missed log")
+	}
+	service.lock.Lock()
+	defer service.lock.Unlock()
+	if len(service.queries) != 1 || service.queries[0] != "0x6" {
+		t.Errorf("catch-up queries mismatch: have %v, want [0x6]", service.queries)
+	}
+}
+
+// Tests that the log tracker filters out replayed logs, while passing the ones of
+// a new chain after a reorg.
+func TestLogTracker(t *testing.T) {
+	tracker := newLogTracker()
+
+	logs := []types.Log{
+		{BlockNumber: 1, BlockHash: [32]byte{1}, Index: 0},
+		{BlockNumber: 2, BlockHash: [32]byte{2}, Index: 1},
+		{BlockNumber: 2, BlockHash: [32]byte{2}, Index: 2},
+	}
+	for i, l := range logs {
+		if !tracker.fresh(l) {
+			t.Fatalf("log %d: new log reported as seen", i)
+		}
+		tracker.mark(l)
+	}
+	// Replayed logs are filtered out, new ones are not
+	for i, l := range logs {
+		if tracker.fresh(l) {
+			t.Errorf("log %d: replayed log reported as new", i)
+		}
+	}
+	if !tracker.fresh(types.Log{BlockNumber: 2, BlockHash: [32]byte{2}, Index: 3}) {
+		t.Errorf("new log in the last block reported as seen")
+	}
+	// Removed logs are always delivered and let the new chain pass
+	removed := logs[1]
+	removed.Removed = true
+	if !tracker.fresh(removed) {
+		t.Fatalf("removed log reported as seen")
+	}
+	tracker.mark(removed)
+
+	if !tracker.fresh(types.Log{BlockNumber: 2, BlockHash: [32]byte{0x22}, Index: 1}) {
+		t.Errorf("log of the new chain reported as seen")
+	}
+	if tracker.fresh(logs[0]) {
+		t.Errorf("log below the reorg reported as new")
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/syndtr/goleveldb/leveldb/filter"
 	"github.com/syndtr/goleveldb/leveldb/iterator"
 	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
 
 	gometrics "github.com/rcrowley/go-metrics"
 )
@@ -42,9 +43,11 @@ type LDBDatabase struct {
 	getTimer       gometrics.Timer // Timer for measuring the database get request counts and latencies
 	putTimer       gometrics.Timer // Timer for measuring the database put request counts and latencies
 	delTimer       gometrics.Timer // Timer for measuring the database delete request counts and latencies
+	batchTimer     gometrics.Timer // Timer for measuring the database batch write counts and latencies
 	missMeter      gometrics.Meter // Meter for measuring the missed database get requests
 	readMeter      gometrics.Meter // Meter for measuring the database get request data usage
 	writeMeter     gometrics.Meter // Meter for measuring the database put request data usage
+	deleteMeter    gometrics.Meter // Meter for measuring the database delete request key usage
 	compTimeMeter  gometrics.Meter // Meter for measuring the total time spent in database compaction
 	compReadMeter  gometrics.Meter // Meter for measuring the data read during compaction
 	compWriteMeter gometrics.Meter // Meter for measuring the data written during compaction
@@ -141,6 +144,9 @@ func (db *LDBDatabase) Delete(key []byte) error {
 	if db.delTimer != nil {
 		defer db.delTimer.UpdateSince(time.Now())
 	}
+	if db.deleteMeter != nil {
+		db.deleteMeter.Mark(int64(len(key)))
+	}
 	// Execute the actual operation
 	return db.db.Delete(key, nil)
 }
@@ -149,6 +155,28 @@ func (db *LDBDatabase) NewIterator() iterator.Iterator {
 	return db.db.NewIterator(nil, nil)
 }
 
+// NewIteratorWithPrefix returns an iterator over the subset of the database's
+// content with a particular key prefix.
+func (db *LDBDatabase) NewIteratorWithPrefix(prefix []byte) Iterator {
+	return db.db.NewIterator(util.BytesPrefix(prefix), nil)
+}
+
+// NewIteratorWithRange returns an iterator over the subset of the database's
+// content with keys within [start, limit).
+func (db *LDBDatabase) NewIteratorWithRange(start []byte, limit []byte) Iterator {
+	return db.db.NewIterator(&util.Range{Start: start, Limit: limit}, nil)
+}
+
+// SizeOfPrefix returns the approximate disk usage of the database's content with
+// a particular key prefix.
+func (db *LDBDatabase) SizeOfPrefix(prefix []byte) (uint64, error) {
+	sizes, err := db.db.SizeOf([]util.Range{*util.BytesPrefix(prefix)})
+	if err != nil {
+		return 0, err
+	}
+	return uint64(sizes.Sum()), nil
+}
+
 func (db *LDBDatabase) Close() {
 	// Stop the metrics collection to avoid internal database races
 	db.quitLock.Lock()
@@ -169,6 +197,12 @@ func (db *LDBDatabase) Close() {
 	}
 }
 
+// Compact flattens the underlying data store for the given key range, see the
+// Compacter interface for details.
+func (db *LDBDatabase) Compact(start []byte, limit []byte) error {
+	return db.db.CompactRange(util.Range{Start: start, Limit: limit})
+}
+
 func (db *LDBDatabase) LDB() *leveldb.DB {
 	return db.db
 }
@@ -183,9 +217,11 @@ func (db *LDBDatabase) Meter(prefix string) {
 	db.getTimer = metrics.NewTimer(prefix + "user/gets")
 	db.putTimer = metrics.NewTimer(prefix + "user/puts")
 	db.delTimer = metrics.NewTimer(prefix + "user/dels")
+	db.batchTimer = metrics.NewTimer(prefix + "user/batches")
 	db.missMeter = metrics.NewMeter(prefix + "user/misses")
 	db.readMeter = metrics.NewMeter(prefix + "user/reads")
 	db.writeMeter = metrics.NewMeter(prefix + "user/writes")
+	db.deleteMeter = metrics.NewMeter(prefix + "user/deletes")
 	db.compTimeMeter = metrics.NewMeter(prefix + "compact/time")
 	db.compReadMeter = metrics.NewMeter(prefix + "compact/input")
 	db.compWriteMeter = metrics.NewMeter(prefix + "compact/output")
@@ -276,11 +312,11 @@ func (db *LDBDatabase) meter(refresh time.Duration) {
 }
 
 func (db *LDBDatabase) NewBatch() Batch {
-	return &ldbBatch{db: db.db, b: new(leveldb.Batch)}
+	return &ldbBatch{db: db, b: new(leveldb.Batch)}
 }
 
 type ldbBatch struct {
-	db   *leveldb.DB
+	db   *LDBDatabase
 	b    *leveldb.Batch
 	size int
 }
@@ -291,14 +327,32 @@ func (b *ldbBatch) Put(key, value []byte) error {
 	return nil
 }
 
+func (b *ldbBatch) Delete(key []byte) error {
+	b.b.Delete(key)
+	b.size += len(key)
+	return nil
+}
+
 func (b *ldbBatch) Write() error {
-	return b.db.Write(b.b, nil)
+	// Measure the database batch write latency, if requested
+	if b.db.batchTimer != nil {
+		defer b.db.batchTimer.UpdateSince(time.Now())
+	}
+	if b.db.writeMeter != nil {
+		b.db.writeMeter.Mark(int64(b.size))
+	}
+	return b.db.db.Write(b.b, nil)
 }
 
 func (b *ldbBatch) ValueSize() int {
 	return b.size
 }
 
+func (b *ldbBatch) Reset() {
+	b.b.Reset()
+	b.size = 0
+}
+
 type table struct {
 	db     Database
 	prefix string
@@ -333,6 +387,60 @@ func (dt *table) Close() {
 	// Do nothing; don't close the underlying DB.
 }
 
+// NewIteratorWithPrefix returns an iterator over the subset of the table's
+// content with a particular key prefix. The table prefix is stripped from the
+// iterated keys.
+func (dt *table) NewIteratorWithPrefix(prefix []byte) Iterator {
+	return &tableIterator{
+		it:     dt.db.NewIteratorWithPrefix(append([]byte(dt.prefix), prefix...)),
+		prefix: dt.prefix,
+	}
+}
+
+// NewIteratorWithRange returns an iterator over the subset of the table's content
+// with keys within [start, limit). The table prefix is stripped from the iterated
+// keys.
+func (dt *table) NewIteratorWithRange(start []byte, limit []byte) Iterator {
+	// Map the range into the table's key space, nil limits ending at the table
+	limitKey := append([]byte(dt.prefix), limit...)
+	if limit == nil {
+		limitKey = util.BytesPrefix([]byte(dt.prefix)).Limit
+	}
+	return &tableIterator{
+		it:     dt.db.NewIteratorWithRange(append([]byte(dt.prefix), start...), limitKey),
+		prefix: dt.prefix,
+	}
+}
+
+// SizeOfPrefix returns the approximate storage space used by the table's content
+// with a particular key prefix, if the underlying database can measure it.
+func (dt *table) SizeOfPrefix(prefix []byte) (uint64, error) {
+	measurer, ok := dt.db.(Measurer)
+	if !ok {
+		return 0, errNotMeasurable
+	}
+	return measurer.SizeOfPrefix(append([]byte(dt.prefix), prefix...))
+}
+
+// tableIterator wraps an iterator over the underlying database, stripping the
+// table prefix from the keys.
+type tableIterator struct {
+	it     Iterator
+	prefix string
+}
+
+func (it *tableIterator) Next() bool    { return it.it.Next() }
+func (it *tableIterator) Value() []byte { return it.it.Value() }
+func (it *tableIterator) Release()      { it.it.Release() }
+func (it *tableIterator) Error() error  { return it.it.Error() }
+
+func (it *tableIterator) Key() []byte {
+	if key := it.it.Key(); key != nil {
+		return key[len(it.prefix):]
+	}
+	return nil
+}
+
 type tableBatch struct {
 	batch  Batch
 	prefix string
@@ -351,6 +459,10 @@ func (tb *tableBatch) Put(key, value []byte) error {
 	return tb.batch.Put(append([]byte(tb.prefix), key...), value)
 }
 
+func (tb *tableBatch) Delete(key []byte) error {
+	return tb.batch.Delete(append([]byte(tb.prefix), key...))
+}
+
 func (tb *tableBatch) Write() error {
 	return tb.batch.Write()
 }
@@ -358,3 +470,7 @@ func (tb *tableBatch) Write() error {
 func (tb *tableBatch) ValueSize() int {
 	return tb.batch.ValueSize()
 }
+
+func (tb *tableBatch) Reset() {
+	tb.batch.Reset()
+}
@@ -135,6 +135,18 @@ func TestMemoryDB_ParallelPutGet(t *testing.T) {
 	testParallelPutGet(db, t)
 }
 
+func TestPersistentMemDB_PutGet(t *testing.T) {
+	db, remove := newTestPersistentMemDB()
+	defer remove()
+	testPutGet(db, t)
+}
+
+func TestPersistentMemDB_ParallelPutGet(t *testing.T) {
+	db, remove := newTestPersistentMemDB()
+	defer remove()
+	testParallelPutGet(db, t)
+}
+
 func testParallelPutGet(db ethdb.Database, t *testing.T) {
 	const n = 8
 	var pending sync.WaitGroup
@@ -190,3 +202,185 @@ func testParallelPutGet(db ethdb.Database, t *testing.T) {
 	}
 	pending.Wait()
 }
+
+func newTestPersistentMemDB() (*ethdb.PersistentMemDatabase, func()) {
+	dirname, err := ioutil.TempDir(os.TempDir(), "ethdb_test_")
+	if err != nil {
+		panic("failed to create test file: " + err.Error())
+	}
+	db, err := ethdb.NewPersistentMemDatabase(dirname)
+	if err != nil {
+		panic("failed to create test database: " + err.Error())
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dirname)
+	}
+}
+
+func TestLDB_IteratorWithPrefix(t *testing.T) {
+	db, remove := newTestLDB()
+	defer remove()
+	testIteratorWithPrefix(db, t)
+}
+
+func TestMemoryDB_IteratorWithPrefix(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	testIteratorWithPrefix(db, t)
+}
+
+func testIteratorWithPrefix(db ethdb.Database, t *testing.T) {
+	for _, k := range []string{"b2", "a", "b1", "c", "b"} {
+		if err := db.Put([]byte(k), []byte("v"+k)); err != nil {
+			t.Fatalf("put failed: %v", err)
+		}
+	}
+	tests := []struct {
+		prefix string
+		keys   []string
+	}{
+		{"", []string{"a", "b", "b1", "b2", "c"}},
+		{"b", []string{"b", "b1", "b2"}},
+		{"b1", []string{"b1"}},
+		{"d", nil},
+	}
+	for _, tt := range tests {
+		var keys []string
+
+		it := db.NewIteratorWithPrefix([]byte(tt.prefix))
+		for it.Next() {
+			if want := "v" + string(it.Key()); string(it.Value()) != want {
+				t.Errorf("prefix %q: value mismatch for key %q: have %q, want %q", tt.prefix, it.Key(), it.Value(), want)
+			}
+			keys = append(keys, string(it.Key()))
+		}
+		if err := it.Error(); err != nil {
+			t.Errorf("prefix %q: iteration failed: %v", tt.prefix, err)
+		}
+		it.Release()
+
+		if fmt.Sprint(keys) != fmt.Sprint(tt.keys) {
+			t.Errorf("prefix %q: keys mismatch: have %v, want %v", tt.prefix, keys, tt.keys)
+		}
+	}
+}
+
+func TestLDB_IteratorWithRange(t *testing.T) {
+	db, remove := newTestLDB()
+	defer remove()
+	testIteratorWithRange(db, t)
+}
+
+func TestMemoryDB_IteratorWithRange(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	testIteratorWithRange(db, t)
+}
+
+func TestTable_IteratorWithRange(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+
+	// Surround the table with entries that must not be iterated over
+	db.Put([]byte("s"), []byte("outside"))
+	db.Put([]byte("u"), []byte("outside"))
+
+	testIteratorWithRange(ethdb.NewTable(db, "t"), t)
+}
+
+func testIteratorWithRange(db ethdb.Database, t *testing.T) {
+	for _, k := range []string{"b2", "a", "b1", "c", "b"} {
+		if err := db.Put([]byte(k), []byte("v"+k)); err != nil {
+			t.Fatalf("put failed: %v", err)
+		}
+	}
+	tests := []struct {
+		start, limit []byte
+		keys         []string
+	}{
+		{nil, nil, []string{"a", "b", "b1", "b2", "c"}},
+		{[]byte("b"), nil, []string{"b", "b1", "b2", "c"}},
+		{nil, []byte("b1"), []string{"a", "b"}},
+		{[]byte("b0"), []byte("c"), []string{"b1", "b2"}},
+		{[]byte("d"), nil, nil},
+	}
+	for _, tt := range tests {
+		var keys []string
+
+		it := db.NewIteratorWithRange(tt.start, tt.limit)
+		for it.Next() {
+			if want := "v" + string(it.Key()); string(it.Value()) != want {
+				t.Errorf("range [%q, %q): value mismatch for key %q: have %q, want %q", tt.start, tt.limit, it.Key(), it.Value(), want)
+			}
+			keys = append(keys, string(it.Key()))
+		}
+		if err := it.Error(); err != nil {
+			t.Errorf("range [%q, %q): iteration failed: %v", tt.start, tt.limit, err)
+		}
+		it.Release()
+
+		if fmt.Sprint(keys) != fmt.Sprint(tt.keys) {
+			t.Errorf("range [%q, %q): keys mismatch: have %v, want %v", tt.start, tt.limit, keys, tt.keys)
+		}
+	}
+}
+
+func TestLDB_Batch(t *testing.T) {
+	db, remove := newTestLDB()
+	defer remove()
+	testBatch(db, 0, t)
+}
+
+func TestMemoryDB_Batch(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	testBatch(db, 0, t)
+}
+
+func TestTable_Batch(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	testBatch(ethdb.NewTable(db, "t"), 1, t)
+}
+
+// testBatch checks batch writes, deletions and size accounting. Deleted keys are
+// accounted including the given key prefix added by the database.
+func testBatch(db ethdb.Database, keyPrefix int, t *testing.T) {
+	db.Put([]byte("deleted"), []byte("value"))
+
+	batch := db.NewBatch()
+	batch.Put([]byte("discarded"), []byte("value"))
+	if size := batch.ValueSize(); size != 5 {
+		t.Errorf("batch size mismatch: have %d, want %d", size, 5)
+	}
+	batch.Reset()
+	if size := batch.ValueSize(); size != 0 {
+		t.Errorf("reset batch size mismatch: have %d, want %d", size, 0)
+	}
+	batch.Put([]byte("added"), []byte("value"))
+	batch.Delete([]byte("deleted"))
+	if size, want := batch.ValueSize(), 12+keyPrefix; size != want {
+		t.Errorf("batch size mismatch: have %d, want %d", size, want)
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatalf("batch write failed: %v", err)
+	}
+	for key, want := range map[string]bool{"added": true, "deleted": false, "discarded": false} {
+		if have, _ := db.Has([]byte(key)); have != want {
+			t.Errorf("key %q presence mismatch: have %v, want %v", key, have, want)
+		}
+	}
+}
+
+func TestMemoryDB_SizeOfPrefix(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	db.Put([]byte("a1"), []byte("value"))
+	db.Put([]byte("a2"), []byte("value"))
+	db.Put([]byte("b1"), []byte("value"))
+
+	table := ethdb.NewTable(db, "a").(ethdb.Measurer)
+	for prefix, want := range map[string]uint64{"": 14, "1": 7, "3": 0} {
+		if size, err := table.SizeOfPrefix([]byte(prefix)); err != nil || size != want {
+			t.Errorf("prefix %q: size mismatch: have %d (%v), want %d", prefix, size, err, want)
+		}
+	}
+	if size, _ := db.SizeOfPrefix(nil); size != 21 {
+		t.Errorf("total size mismatch: have %d, want %d", size, 21)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Database engines a node can store its data with.
+const (
+	EngineLevelDB = "leveldb" // LevelDB key-value store (default)
+	EngineMemory  = "memory"  // Memory database persisted as a snapshot file
+)
+
+// Engines is the list of supported database engines.
+var Engines = []string{EngineLevelDB, EngineMemory}
+
+// DetectEngine returns the engine of the database stored at path, or an empty
+// string if there is none.
+func DetectEngine(path string) string {
+	if _, err := os.Stat(filepath.Join(path, "CURRENT")); err == nil {
+		return EngineLevelDB
+	}
+	if _, err := os.Stat(filepath.Join(path, snapshotFile)); err == nil {
+		return EngineMemory
+	}
+	return ""
+}
+
+// Open opens the database stored at path with the given engine, creating it if
+// it doesn't exist yet. An empty engine opens an existing database with whatever
+// engine it was created with, and creates new ones with LevelDB. The cache and
+// handles allowances only apply to LevelDB.
+func Open(engine string, path string, cache int, handles int) (Database, error) {
+	existing := DetectEngine(path)
+	if engine == "" {
+		engine = existing
+	}
+	if existing != "" && existing != engine {
+		return nil, fmt.Errorf("database %s uses the %s engine, %s requested", path, existing, engine)
+	}
+	switch engine {
+	case "", EngineLevelDB:
+		return NewLDBDatabase(path, cache, handles)
+	case EngineMemory:
+		return NewPersistentMemDatabase(path)
+	default:
+		return nil, fmt.Errorf("unknown database engine %q", engine)
+	}
+}
+
+// Migrate copies the entire content of the src database into dst, returning the
+// number of entries copied.
+func Migrate(dst Database, src Iteratee) (int, error) {
+	it := src.NewIteratorWithPrefix(nil)
+	defer it.Release()
+
+	var (
+		batch = dst.NewBatch()
+		count int
+	)
+	for it.Next() {
+		if err := batch.Put(it.Key(), it.Value()); err != nil {
+			return count, err
+		}
+		if batch.ValueSize() >= IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return count, err
+			}
+			batch = dst.NewBatch()
+		}
+		count++
+	}
+	if err := it.Error(); err != nil {
+		return count, err
+	}
+	return count, batch.Write()
+}
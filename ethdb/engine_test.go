@@ -0,0 +1,145 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethdb_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// Tests that a persistent memory database retains its content across restarts.
+func TestPersistentMemDBReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethdb_test_")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := ethdb.NewPersistentMemDatabase(dir)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		db.Put([]byte(fmt.Sprintf("key-%d", i)), bytes.Repeat([]byte{byte(i)}, i))
+	}
+	db.Delete([]byte("key-0"))
+	db.Close()
+
+	if db, err = ethdb.NewPersistentMemDatabase(dir); err != nil {
+		t.Fatalf("failed to reopen database: %v", err)
+	}
+	defer db.Close()
+
+	if ok, _ := db.Has([]byte("key-0")); ok {
+		t.Errorf("deleted entry resurrected")
+	}
+	for i := 1; i < 100; i++ {
+		value, err := db.Get([]byte(fmt.Sprintf("key-%d", i)))
+		if err != nil {
+			t.Fatalf("entry %d missing after reopen: %v", i, err)
+		}
+		if !bytes.Equal(value, bytes.Repeat([]byte{byte(i)}, i)) {
+			t.Errorf("entry %d mismatch after reopen: have %x", i, value)
+		}
+	}
+}
+
+// Tests that truncated database snapshots are rejected.
+func TestPersistentMemDBCorruption(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethdb_test_")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "memdb.snapshot"), []byte{0x03, 'k', 'e', 'y', 0x05, 'v'}, 0600); err != nil {
+		t.Fatalf("failed to write snapshot: %v", err)
+	}
+	if _, err := ethdb.NewPersistentMemDatabase(dir); err == nil {
+		t.Fatalf("truncated snapshot accepted")
+	}
+}
+
+// Tests that databases are opened with the right engine, and that their content
+// can be migrated between engines.
+func TestOpenAndMigrate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethdb_test_")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Create a LevelDB database with some content, and check engine detection
+	ldbdir, memdir := filepath.Join(dir, "leveldb"), filepath.Join(dir, "memory")
+	if engine := ethdb.DetectEngine(ldbdir); engine != "" {
+		t.Fatalf("engine detected for missing database: %s", engine)
+	}
+	src, err := ethdb.Open("", ldbdir, 0, 0)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		src.Put([]byte(fmt.Sprintf("key-%d", i)), bytes.Repeat([]byte{byte(i)}, 512))
+	}
+	if engine := ethdb.DetectEngine(ldbdir); engine != ethdb.EngineLevelDB {
+		t.Fatalf("engine mismatch: have %q, want %q", engine, ethdb.EngineLevelDB)
+	}
+	if _, err := ethdb.Open(ethdb.EngineMemory, ldbdir, 0, 0); err == nil {
+		t.Fatalf("database opened with the wrong engine")
+	}
+	if _, err := ethdb.Open("badger", memdir, 0, 0); err == nil {
+		t.Fatalf("unknown engine accepted")
+	}
+	// Migrate the content into a memory database and check it's all there
+	dst, err := ethdb.Open(ethdb.EngineMemory, memdir, 0, 0)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	count, err := ethdb.Migrate(dst, src.(ethdb.Iteratee))
+	if err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	if count != 1000 {
+		t.Errorf("migrated entry count mismatch: have %d, want %d", count, 1000)
+	}
+	src.Close()
+	dst.Close()
+
+	if dst, err = ethdb.Open("", memdir, 0, 0); err != nil {
+		t.Fatalf("failed to reopen migrated database: %v", err)
+	}
+	defer dst.Close()
+
+	if _, ok := dst.(*ethdb.PersistentMemDatabase); !ok {
+		t.Fatalf("engine mismatch: have %T, want memory", dst)
+	}
+	for i := 0; i < 1000; i++ {
+		value, err := dst.Get([]byte(fmt.Sprintf("key-%d", i)))
+		if err != nil {
+			t.Fatalf("entry %d missing after migration: %v", i, err)
+		}
+		if !bytes.Equal(value, bytes.Repeat([]byte{byte(i)}, 512)) {
+			t.Fatalf("entry %d mismatch after migration", i)
+		}
+	}
+}
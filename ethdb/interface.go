@@ -16,6 +16,12 @@
 
 package ethdb
 
+import "errors"
+
+// errNotMeasurable is returned if the storage space used by a database is
+// requested, but its backing data store can't measure it.
+var errNotMeasurable = errors.New("database size not measurable")
+
 // Code using batches should try to add this much data to the batch.
 // The value was determined empirically.
 const IdealBatchSize = 100 * 1024
@@ -33,12 +39,58 @@ type Database interface {
 	Delete(key []byte) error
 	Close()
 	NewBatch() Batch
+	Iteratee
+}
+
+// Compacter wraps the Compact method of a backing data store.
+type Compacter interface {
+	// Compact flattens the underlying data store for the given key range. In
+	// essence, deleted and overwritten versions are discarded, and the data is
+	// rearranged to reduce the cost of operations needed to access them.
+	//
+	// A nil start is treated as a key before all keys in the data store; a nil
+	// limit is treated as a key after all keys in the data store.
+	Compact(start []byte, limit []byte) error
+}
+
+// Iterator iterates over a database's key/value pairs in ascending key order.
+// It must be released after use.
+type Iterator interface {
+	Next() bool
+	Key() []byte
+	Value() []byte
+	Release()
+	Error() error
+}
+
+// Iteratee wraps the iterator constructors of a backing data store.
+type Iteratee interface {
+	// NewIteratorWithPrefix creates an iterator over the subset of the data store's
+	// content whose keys start with the given prefix. A nil prefix iterates over
+	// the entire data store.
+	NewIteratorWithPrefix(prefix []byte) Iterator
+
+	// NewIteratorWithRange creates an iterator over the subset of the data store's
+	// content whose keys are within [start, limit). A nil start is treated as a
+	// key before all keys in the data store; a nil limit is treated as a key after
+	// all keys in the data store.
+	NewIteratorWithRange(start []byte, limit []byte) Iterator
+}
+
+// Measurer wraps the SizeOfPrefix method of a backing data store.
+type Measurer interface {
+	// SizeOfPrefix returns the approximate storage space used by the keys starting
+	// with the given prefix, along with their values. A nil prefix measures the
+	// entire data store.
+	SizeOfPrefix(prefix []byte) (uint64, error)
 }
 
 // Batch is a write-only database that commits changes to its host database
 // when Write is called. Batch cannot be used concurrently.
 type Batch interface {
 	Putter
-	ValueSize() int // amount of data in the batch
+	Delete(key []byte) error
+	ValueSize() int // amount of data in the batch, deleted keys included
 	Write() error
+	Reset() // discards the queued changes, allowing the batch to be reused
 }
@@ -0,0 +1,216 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// snapshotFile is the name of the file within the database directory holding
+// the content of a persistent memory database.
+const snapshotFile = "memdb.snapshot"
+
+// snapshotInterval is the time between periodic snapshots of a persistent memory
+// database, limiting the data lost on a crash.
+var snapshotInterval = 5 * time.Minute
+
+// PersistentMemDatabase is a memory database whose content is loaded from disk
+// on creation and saved back to disk periodically and when closed. It trades
+// memory for avoiding the compaction overhead of LevelDB, so it is only suitable
+// for data sets fitting into memory (e.g. private networks).
+type PersistentMemDatabase struct {
+	*MemDatabase
+
+	dir   string        // Directory containing the database snapshot
+	lock  sync.Mutex    // Serializes snapshot writes
+	quit  chan struct{} // Quit channel to stop the periodic snapshots
+	close sync.Once     // Ensures the database is only closed once
+
+	log log.Logger // Contextual logger tracking the database path
+}
+
+// NewPersistentMemDatabase loads (or creates) a persistent memory database in
+// the given directory.
+func NewPersistentMemDatabase(dir string) (*PersistentMemDatabase, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	mem, _ := NewMemDatabase()
+	if err := loadSnapshot(mem, filepath.Join(dir, snapshotFile)); err != nil {
+		return nil, err
+	}
+	db := &PersistentMemDatabase{
+		MemDatabase: mem,
+		dir:         dir,
+		quit:        make(chan struct{}),
+		log:         log.New("database", dir),
+	}
+	db.log.Info("Loaded memory database", "items", len(mem.db))
+
+	go db.loop()
+	return db, nil
+}
+
+// Path returns the path to the database directory.
+func (db *PersistentMemDatabase) Path() string {
+	return db.dir
+}
+
+// loop periodically saves a snapshot of the database until closed.
+func (db *PersistentMemDatabase) loop() {
+	ticker := time.NewTicker(snapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := db.Flush(); err != nil {
+				db.log.Error("Failed to save memory database", "err", err)
+			}
+		case <-db.quit:
+			return
+		}
+	}
+}
+
+// Flush saves a snapshot of the database content to disk. The content is copied
+// up front, so the database isn't blocked while the snapshot is being written.
+func (db *PersistentMemDatabase) Flush() error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	start := time.Now()
+	path := filepath.Join(db.dir, snapshotFile)
+	tmp := path + ".tmp"
+
+	// Values are never modified in place, so a shallow copy is a consistent snapshot
+	db.MemDatabase.lock.RLock()
+	entries := make(map[string][]byte, len(db.db))
+	for key, value := range db.db {
+		entries[key] = value
+	}
+	db.MemDatabase.lock.RUnlock()
+
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := writeSnapshot(file, entries); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	// Make sure the snapshot hit the disk before it replaces the previous one
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	db.log.Debug("Saved memory database", "elapsed", time.Since(start))
+	return nil
+}
+
+// Close stops the periodic snapshots and saves the database content to disk.
+func (db *PersistentMemDatabase) Close() {
+	db.close.Do(func() {
+		close(db.quit)
+		if err := db.Flush(); err != nil {
+			db.log.Error("Failed to save memory database", "err", err)
+			return
+		}
+		db.log.Info("Memory database closed")
+	})
+}
+
+// writeSnapshot serializes the content of a memory database as a sequence of
+// length prefixed keys and values.
+func writeSnapshot(w io.Writer, entries map[string][]byte) error {
+	buf := bufio.NewWriter(w)
+	size := make([]byte, binary.MaxVarintLen64)
+	for key, value := range entries {
+		for _, blob := range [][]byte{[]byte(key), value} {
+			n := binary.PutUvarint(size, uint64(len(blob)))
+			if _, err := buf.Write(size[:n]); err != nil {
+				return err
+			}
+			if _, err := buf.Write(blob); err != nil {
+				return err
+			}
+		}
+	}
+	return buf.Flush()
+}
+
+// errCorruptSnapshot is returned if a database snapshot ends midway an entry.
+var errCorruptSnapshot = errors.New("corrupt database snapshot")
+
+// loadSnapshot reads a database snapshot into a memory database. A missing
+// snapshot is treated as an empty database.
+func loadSnapshot(db *MemDatabase, path string) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	read := func() ([]byte, error) {
+		size, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		blob := make([]byte, size)
+		if _, err := io.ReadFull(r, blob); err != nil {
+			return nil, errCorruptSnapshot
+		}
+		return blob, nil
+	}
+	for {
+		key, err := read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		value, err := read()
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, errCorruptSnapshot)
+		}
+		db.db[string(key)] = value
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"context"
 	"errors"
 	"math/big"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -103,6 +104,13 @@ type SyncProgress struct {
 	HighestBlock  uint64 // Highest alleged block number in the chain
 	PulledStates  uint64 // Number of state trie entries already downloaded
 	KnownStates   uint64 // Total number of state trie entries known about
+
+	// Per stage progress, each counting up towards HighestBlock
+	PulledHeaders  uint64 // Number of the last header downloaded
+	PulledBodies   uint64 // Number of the last block with its body downloaded
+	PulledReceipts uint64 // Number of the last block with its receipts downloaded (fast sync only)
+
+	EstimatedTime time.Duration // Estimated time to reach the chain head at the current rate (0 = unknown)
 }
 
 // ChainSyncReader wraps access to the node's current sync status. If there's no
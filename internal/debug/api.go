@@ -22,6 +22,7 @@ package debug
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"os/user"
@@ -52,8 +53,12 @@ type HandlerT struct {
 
 // Verbosity sets the log verbosity ceiling. The verbosity of individual packages
 // and source files can be raised using Vmodule.
-func (*HandlerT) Verbosity(level int) {
+func (*HandlerT) Verbosity(level int) error {
+	if level < int(log.LvlCrit) || level > int(log.LvlTrace) {
+		return fmt.Errorf("invalid verbosity %d, want %d-%d", level, log.LvlCrit, log.LvlTrace)
+	}
 	glogger.Verbosity(log.Lvl(level))
+	return nil
 }
 
 // Vmodule sets the log verbosity pattern. See package log for details on the
@@ -17,12 +17,14 @@
 package debug
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"io"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
 	"runtime"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/log/term"
@@ -46,6 +48,24 @@ var (
 		Usage: "Request a stack trace at a specific logging statement (e.g. \"block.go:271\")",
 		Value: "",
 	}
+	logFileFlag = cli.StringFlag{
+		Name:  "logfile",
+		Usage: "Write logs to the given file in addition to the console",
+	}
+	logFileMaxSizeFlag = cli.IntFlag{
+		Name:  "logfile.maxsize",
+		Usage: "Rotate the log file once it exceeds this size in megabytes (0 = no limit)",
+		Value: 100,
+	}
+	logFileMaxAgeFlag = cli.DurationFlag{
+		Name:  "logfile.maxage",
+		Usage: "Rotate the log file once it gets older than this (0 = no limit)",
+	}
+	logFileBackupsFlag = cli.IntFlag{
+		Name:  "logfile.backups",
+		Usage: "Number of rotated log files to keep (0 = keep all)",
+		Value: 10,
+	}
 	debugFlag = cli.BoolFlag{
 		Name:  "debug",
 		Usage: "Prepends log messages with call-site location (file and line number)",
@@ -64,6 +84,10 @@ var (
 		Usage: "pprof HTTP server listening interface",
 		Value: "127.0.0.1",
 	}
+	pprofTokenFlag = cli.StringFlag{
+		Name:  "pproftoken",
+		Usage: "Require this bearer token (Authorization header or token parameter) on pprof HTTP server requests",
+	}
 	memprofilerateFlag = cli.IntFlag{
 		Name:  "memprofilerate",
 		Usage: "Turn on memory profiling with the given rate",
@@ -86,11 +110,15 @@ var (
 // Flags holds all command-line flags required for debugging.
 var Flags = []cli.Flag{
 	verbosityFlag, vmoduleFlag, backtraceAtFlag, debugFlag,
-	pprofFlag, pprofAddrFlag, pprofPortFlag,
+	logFileFlag, logFileMaxSizeFlag, logFileMaxAgeFlag, logFileBackupsFlag,
+	pprofFlag, pprofAddrFlag, pprofPortFlag, pprofTokenFlag,
 	memprofilerateFlag, blockprofilerateFlag, cpuprofileFlag, traceFlag,
 }
 
-var glogger *log.GlogHandler
+var (
+	glogger *log.GlogHandler
+	ostream log.Handler // Console handler the logs are always written to
+)
 
 func init() {
 	usecolor := term.IsTty(os.Stderr.Fd()) && os.Getenv("TERM") != "dumb"
@@ -98,13 +126,25 @@ func init() {
 	if usecolor {
 		output = colorable.NewColorableStderr()
 	}
-	glogger = log.NewGlogHandler(log.StreamHandler(output, log.TerminalFormat(usecolor)))
+	ostream = log.StreamHandler(output, log.TerminalFormat(usecolor))
+	glogger = log.NewGlogHandler(ostream)
 }
 
 // Setup initializes profiling and logging based on the CLI flags.
 // It should be called as early as possible in the program.
 func Setup(ctx *cli.Context) error {
 	// logging
+	if logFile := ctx.GlobalString(logFileFlag.Name); logFile != "" {
+		maxSize := int64(ctx.GlobalInt(logFileMaxSizeFlag.Name)) * 1024 * 1024
+		maxAge := ctx.GlobalDuration(logFileMaxAgeFlag.Name)
+		backups := ctx.GlobalInt(logFileBackupsFlag.Name)
+
+		fstream, err := log.RotatingFileHandler(logFile, maxSize, maxAge, backups, log.TerminalFormat(false))
+		if err != nil {
+			return fmt.Errorf("failed to open log file: %v", err)
+		}
+		glogger = log.NewGlogHandler(log.MultiHandler(ostream, fstream))
+	}
 	log.PrintOrigins(ctx.GlobalBool(debugFlag.Name))
 	glogger.Verbosity(log.Lvl(ctx.GlobalInt(verbosityFlag.Name)))
 	glogger.Vmodule(ctx.GlobalString(vmoduleFlag.Name))
@@ -128,9 +168,16 @@ func Setup(ctx *cli.Context) error {
 	// pprof server
 	if ctx.GlobalBool(pprofFlag.Name) {
 		address := fmt.Sprintf("%s:%d", ctx.GlobalString(pprofAddrFlag.Name), ctx.GlobalInt(pprofPortFlag.Name))
+
+		handler := http.Handler(http.DefaultServeMux)
+		if token := ctx.GlobalString(pprofTokenFlag.Name); token != "" {
+			handler = tokenHandler(token, handler)
+		} else if host := ctx.GlobalString(pprofAddrFlag.Name); host != "127.0.0.1" && host != "localhost" && host != "::1" {
+			log.Warn("Serving pprof on a public interface without a token", "addr", address)
+		}
 		go func() {
 			log.Info("Starting pprof server", "addr", fmt.Sprintf("http://%s/debug/pprof", address))
-			if err := http.ListenAndServe(address, nil); err != nil {
+			if err := http.ListenAndServe(address, handler); err != nil {
 				log.Error("Failure in running pprof server", "err", err)
 			}
 		}()
@@ -138,6 +185,23 @@ func Setup(ctx *cli.Context) error {
 	return nil
 }
 
+// tokenHandler wraps an HTTP handler, rejecting all requests that don't carry the
+// given bearer token either in the Authorization header or the token parameter.
+func tokenHandler(token string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		have := r.URL.Query().Get("token")
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			have = strings.TrimPrefix(auth, "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(have), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
 // Exit stops all running profiles, flushing their output to the
 // respective file.
 func Exit() {
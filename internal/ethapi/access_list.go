@@ -0,0 +1,193 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// AccessTuple is an account accessed by an execution, along with the storage
+// slots of it that were accessed.
+type AccessTuple struct {
+	Address     common.Address `json:"address"`
+	StorageKeys []common.Hash  `json:"storageKeys"`
+}
+
+// AccessListResult is the set of accounts and storage slots accessed by a call,
+// along with the gas it used.
+type AccessListResult struct {
+	AccessList []AccessTuple  `json:"accessList"`
+	GasUsed    hexutil.Uint64 `json:"gasUsed"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// CreateAccessList executes a call on top of the given block's state (pending by
+// default) and returns the accounts and storage slots it accesses. The sender, the
+// recipient (or created contract) and the precompiled contracts are implicitly
+// accessible and not included.
+func (s *PublicBlockChainAPI) CreateAccessList(ctx context.Context, args CallArgs, blockNr *rpc.BlockNumber) (*AccessListResult, error) {
+	number := rpc.PendingBlockNumber
+	if blockNr != nil {
+		number = *blockNr
+	}
+	tracer := newAccessListTracer()
+	_, gas, failed, err := DoCall(ctx, s.b, args, number, vm.Config{Debug: true, Tracer: tracer}, nil)
+	if err != nil {
+		return nil, err
+	}
+	result := &AccessListResult{
+		AccessList: tracer.accessList(),
+		GasUsed:    hexutil.Uint64(gas.Uint64()),
+	}
+	if failed {
+		result.Error = "execution failed"
+	}
+	return result, nil
+}
+
+// accessListTracer is a vm.Tracer collecting the accounts and storage slots an
+// execution accesses.
+type accessListTracer struct {
+	excluded map[common.Address]bool                     // Implicitly accessible accounts
+	accessed map[common.Address]map[common.Hash]struct{} // Accessed accounts and their slots
+}
+
+// newAccessListTracer creates a tracer with the precompiled contracts excluded.
+func newAccessListTracer() *accessListTracer {
+	excluded := make(map[common.Address]bool)
+	for addr := range vm.PrecompiledContractsByzantium {
+		excluded[addr] = true
+	}
+	return &accessListTracer{
+		excluded: excluded,
+		accessed: make(map[common.Address]map[common.Hash]struct{}),
+	}
+}
+
+// addAccount records an accessed account.
+func (t *accessListTracer) addAccount(addr common.Address) {
+	if _, ok := t.accessed[addr]; !ok {
+		t.accessed[addr] = make(map[common.Hash]struct{})
+	}
+}
+
+// addSlot records an accessed storage slot of an account.
+func (t *accessListTracer) addSlot(addr common.Address, slot common.Hash) {
+	t.addAccount(addr)
+	t.accessed[addr][slot] = struct{}{}
+}
+
+// accessList returns the accessed accounts and slots in a deterministic order.
+func (t *accessListTracer) accessList() []AccessTuple {
+	list := make([]AccessTuple, 0, len(t.accessed))
+	for addr, slots := range t.accessed {
+		if t.excluded[addr] && len(slots) == 0 {
+			continue
+		}
+		tuple := AccessTuple{Address: addr, StorageKeys: make([]common.Hash, 0, len(slots))}
+		for slot := range slots {
+			tuple.StorageKeys = append(tuple.StorageKeys, slot)
+		}
+		sort.Sort(hashesByValue(tuple.StorageKeys))
+		list = append(list, tuple)
+	}
+	sort.Sort(tuplesByAddress(list))
+	return list
+}
+
+// CaptureStart implements vm.Tracer, excluding the sender and recipient.
+func (t *accessListTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.excluded[from] = true
+	t.excluded[to] = true
+	return nil
+}
+
+// CaptureState implements vm.Tracer, recording the accounts and slots accessed by
+// the instruction about to be executed.
+func (t *accessListTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	data := stack.Data()
+	if len(data) == 0 {
+		return nil
+	}
+	top := data[len(data)-1]
+
+	switch op {
+	case vm.SLOAD, vm.SSTORE:
+		t.addSlot(contract.Address(), common.BigToHash(top))
+	case vm.BALANCE, vm.EXTCODESIZE, vm.EXTCODECOPY, vm.SELFDESTRUCT:
+		t.addAccount(common.BigToAddress(top))
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		if len(data) >= 2 {
+			t.addAccount(common.BigToAddress(data[len(data)-2]))
+		}
+	}
+	return nil
+}
+
+// CaptureFault implements vm.Tracer, failed instructions access nothing.
+func (t *accessListTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnter implements vm.Tracer, recording contracts created by the execution.
+func (t *accessListTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) error {
+	if typ == vm.CREATE {
+		t.addAccount(to)
+	}
+	return nil
+}
+
+// CaptureExit implements vm.Tracer.
+func (t *accessListTracer) CaptureExit(output []byte, gasUsed uint64, err error) error {
+	return nil
+}
+
+// CaptureStorageChange implements vm.Tracer, writes are recorded from SSTORE.
+func (t *accessListTracer) CaptureStorageChange(env *vm.EVM, addr common.Address, key, value common.Hash) error {
+	return nil
+}
+
+// CaptureEnd implements vm.Tracer.
+func (t *accessListTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	return nil
+}
+
+// hashesByValue implements sort.Interface to order hashes by value.
+type hashesByValue []common.Hash
+
+func (h hashesByValue) Len() int           { return len(h) }
+func (h hashesByValue) Less(i, j int) bool { return bytes.Compare(h[i][:], h[j][:]) < 0 }
+func (h hashesByValue) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+// tuplesByAddress implements sort.Interface to order access tuples by address.
+type tuplesByAddress []AccessTuple
+
+func (t tuplesByAddress) Len() int      { return len(t) }
+func (t tuplesByAddress) Swap(i, j int) { t[i], t[j] = t[j], t[i] }
+
+func (t tuplesByAddress) Less(i, j int) bool {
+	return bytes.Compare(t[i].Address[:], t[j].Address[:]) < 0
+}
@@ -0,0 +1,41 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Tests that the accounts and storage slots accessed by nested calls are
+// collected, without the implicitly accessible sender and recipient.
+func TestAccessListTracer(t *testing.T) {
+	statedb, caller := newCallTraceState()
+
+	tracer := newAccessListTracer()
+	executeCallTrace(t, statedb, caller, tracer)
+
+	want := []AccessTuple{{
+		Address:     common.Address{0xbb},
+		StorageKeys: []common.Hash{common.BytesToHash([]byte{0x01})},
+	}}
+	if have := tracer.accessList(); !reflect.DeepEqual(have, want) {
+		t.Errorf("access list mismatch: have %v, want %v", have, want)
+	}
+}
@@ -31,9 +31,11 @@ import (
 	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/params"
@@ -90,6 +92,12 @@ func (s *PublicEthereumAPI) Syncing() (interface{}, error) {
 		"highestBlock":  hexutil.Uint64(progress.HighestBlock),
 		"pulledStates":  hexutil.Uint64(progress.PulledStates),
 		"knownStates":   hexutil.Uint64(progress.KnownStates),
+		"stages": map[string]interface{}{
+			"headers":  hexutil.Uint64(progress.PulledHeaders),
+			"bodies":   hexutil.Uint64(progress.PulledBodies),
+			"receipts": hexutil.Uint64(progress.PulledReceipts),
+		},
+		"eta": hexutil.Uint64(progress.EstimatedTime / time.Second),
 	}, nil
 }
 
@@ -269,8 +277,8 @@ func (s *PrivateAccountAPI) ListWallets() []rawWallet {
 
 // OpenWallet initiates a hardware wallet opening procedure, establishing a USB
 // connection and attempting to authenticate via the provided passphrase. Note,
-// the method may return an extra challenge requiring a second open (e.g. the
-// Trezor PIN matrix challenge).
+// the method may return extra challenges requiring further opens (e.g. the
+// Trezor PIN matrix challenge or its wallet passphrase request).
 func (s *PrivateAccountAPI) OpenWallet(url string, passphrase *string) error {
 	wallet, err := s.am.Wallet(url)
 	if err != nil {
@@ -325,6 +333,91 @@ func (s *PrivateAccountAPI) ImportRawKey(privkey string, password string) (commo
 	return acc.Address, err
 }
 
+// ImportMnemonic imports the hierarchical deterministic wallet described by a
+// BIP-39 mnemonic and its optional password into the key directory, encrypting
+// its master key with the passphrase. It returns the address of the wallet's main
+// account, which is locked like any other imported key.
+func (s *PrivateAccountAPI) ImportMnemonic(mnemonic string, mnemonicPassword *string, password string) (common.Address, error) {
+	var pass string
+	if mnemonicPassword != nil {
+		pass = *mnemonicPassword
+	}
+	acc, err := fetchKeystore(s.am).ImportMnemonic(mnemonic, pass, password)
+	return acc.Address, err
+}
+
+// ImportMnemonicKey derives the key at the given path (or the default one) of the
+// hierarchical deterministic wallet described by a BIP-39 mnemonic and its optional
+// password, and stores it into the key directory, encrypted with the passphrase.
+func (s *PrivateAccountAPI) ImportMnemonicKey(mnemonic string, mnemonicPassword *string, path *string, password string) (common.Address, error) {
+	derivationPath := accounts.DefaultBaseDerivationPath
+	if path != nil {
+		var err error
+		if derivationPath, err = accounts.ParseDerivationPath(*path); err != nil {
+			return common.Address{}, err
+		}
+	}
+	var pass string
+	if mnemonicPassword != nil {
+		pass = *mnemonicPassword
+	}
+	acc, err := fetchKeystore(s.am).ImportMnemonicKey(mnemonic, pass, derivationPath, password)
+	return acc.Address, err
+}
+
+// AccountDerivation describes the origin of an account's key, so that it can be
+// recovered in or migrated to other wallets.
+type AccountDerivation struct {
+	Address common.Address `json:"address"`
+	Wallet  string         `json:"wallet"`
+	Path    string         `json:"path,omitempty"`
+}
+
+// ExportDerivation returns the wallet holding an account, along with the account's
+// derivation path if it was derived from an imported mnemonic. Mnemonics are not
+// retained after import, so they can't be exported.
+func (s *PrivateAccountAPI) ExportDerivation(addr common.Address) (*AccountDerivation, error) {
+	wallet, err := s.am.Find(accounts.Account{Address: addr})
+	if err != nil {
+		return nil, err
+	}
+	res := &AccountDerivation{Address: addr, Wallet: wallet.URL().String()}
+	if path, ok := fetchKeystore(s.am).DerivationPath(accounts.Account{Address: addr}); ok {
+		res.Path = path.String()
+	}
+	return res, nil
+}
+
+// AuditLog returns the signing operations recorded in the audit log, optionally
+// filtered by account. The integrity of the whole log is verified beforehand.
+func (s *PrivateAccountAPI) AuditLog(addr *common.Address) ([]*accounts.AuditEntry, error) {
+	audit := s.am.AuditLog()
+	if audit == nil {
+		return nil, errors.New("signing audit log not enabled")
+	}
+	return audit.Entries(addr)
+}
+
+// PendingApprovals returns the transaction signing requests violating the signing
+// rules, which are awaiting an interactive decision.
+func (s *PrivateAccountAPI) PendingApprovals() ([]*accounts.SigningApproval, error) {
+	guard := s.am.SigningGuard()
+	if guard == nil {
+		return nil, errors.New("signing rules not enabled")
+	}
+	return guard.Pending(), nil
+}
+
+// ApproveSigning approves or rejects a transaction signing request awaiting an
+// interactive decision.
+func (s *PrivateAccountAPI) ApproveSigning(id uint64, approve bool) error {
+	guard := s.am.SigningGuard()
+	if guard == nil {
+		return errors.New("signing rules not enabled")
+	}
+	return guard.Decide(id, approve)
+}
+
 // UnlockAccount will unlock the account associated with the given address with
 // the given password for duration seconds. If duration is nil it will use a
 // default of 300 seconds. It returns an indication if the account was unlocked.
@@ -377,10 +470,17 @@ func (s *PrivateAccountAPI) SendTransaction(ctx context.Context, args SendTxArgs
 	if config := s.b.ChainConfig(); config.IsEIP155(s.b.CurrentBlock().Number()) {
 		chainID = config.ChainId
 	}
+	if err := s.am.AuthorizeTx(account.Address, "personal_sendTransaction", tx); err != nil {
+		return common.Hash{}, err
+	}
 	signed, err := wallet.SignTxWithPassphrase(account, passwd, tx, chainID)
+	s.am.SettleTx(account.Address, tx, err == nil)
 	if err != nil {
 		return common.Hash{}, err
 	}
+	if err := s.am.Audit(account.Address, "personal_sendTransaction", accounts.AuditTransaction, signed.Hash()); err != nil {
+		return common.Hash{}, err
+	}
 	return submitTransaction(ctx, s.b, signed)
 }
 
@@ -414,10 +514,15 @@ func (s *PrivateAccountAPI) Sign(ctx context.Context, data hexutil.Bytes, addr c
 		return nil, err
 	}
 	// Assemble sign the data with the wallet
-	signature, err := wallet.SignHashWithPassphrase(account, passwd, signHash(data))
+	hash := signHash(data)
+
+	signature, err := wallet.SignHashWithPassphrase(account, passwd, hash)
 	if err != nil {
 		return nil, err
 	}
+	if err := s.am.Audit(account.Address, "personal_sign", accounts.AuditHash, common.BytesToHash(hash)); err != nil {
+		return nil, err
+	}
 	signature[64] += 27 // Transform V from 0/1 to 27/28 according to the yellow paper
 	return signature, nil
 }
@@ -594,22 +699,74 @@ type CallArgs struct {
 	Data     hexutil.Bytes   `json:"data"`
 }
 
-func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, vmCfg vm.Config) ([]byte, *big.Int, bool, error) {
+// OverrideAccount specifies the fields of an account to be replaced during the
+// execution of a message call. Unset fields retain their value from the state.
+type OverrideAccount struct {
+	Nonce   *hexutil.Uint64             `json:"nonce"`
+	Code    *hexutil.Bytes              `json:"code"`
+	Balance *hexutil.Big                `json:"balance"`
+	Storage map[common.Hash]common.Hash `json:"storage"`
+}
+
+// StateOverride is the collection of overridden accounts, keyed by address.
+type StateOverride map[common.Address]OverrideAccount
+
+// Apply overrides the fields of the specified accounts in the given state.
+func (diff StateOverride) Apply(state *state.StateDB) {
+	for addr, account := range diff {
+		if account.Nonce != nil {
+			state.SetNonce(addr, uint64(*account.Nonce))
+		}
+		if account.Code != nil {
+			state.SetCode(addr, *account.Code)
+		}
+		if account.Balance != nil {
+			state.SetBalance(addr, (*big.Int)(account.Balance))
+		}
+		for key, value := range account.Storage {
+			state.SetState(addr, key, value)
+		}
+	}
+}
+
+// callSender returns the sender of a call, defaulting to the first account of
+// the first wallet if none was specified.
+func callSender(b Backend, args CallArgs) common.Address {
+	if args.From != (common.Address{}) {
+		return args.From
+	}
+	if wallets := b.AccountManager().Wallets(); len(wallets) > 0 {
+		if accounts := wallets[0].Accounts(); len(accounts) > 0 {
+			return accounts[0].Address
+		}
+	}
+	return common.Address{}
+}
+
+func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, vmCfg vm.Config, overrides *StateOverride) ([]byte, *big.Int, bool, error) {
+	return DoCall(ctx, s.b, args, blockNr, vmCfg, overrides)
+}
+
+// DoCall executes the given call message on top of the requested state, using
+// the given EVM configuration. It can be used to attach a tracer to a call. The
+// sender is funded with an unlimited balance, and any state overrides are then
+// applied to the ephemeral state the call is executed on.
+func DoCall(ctx context.Context, b Backend, args CallArgs, blockNr rpc.BlockNumber, vmCfg vm.Config, overrides *StateOverride) ([]byte, *big.Int, bool, error) {
+	// Wait for an execution slot, so bursts of calls don't overload the node
+	release, err := b.CallPool().Acquire(ctx)
+	if err != nil {
+		return nil, common.Big0, false, err
+	}
+	defer release()
+
 	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
 
-	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	state, header, err := b.StateAndHeaderByNumber(ctx, blockNr)
 	if state == nil || err != nil {
 		return nil, common.Big0, false, err
 	}
 	// Set sender address or use a default if none specified
-	addr := args.From
-	if addr == (common.Address{}) {
-		if wallets := s.b.AccountManager().Wallets(); len(wallets) > 0 {
-			if accounts := wallets[0].Accounts(); len(accounts) > 0 {
-				addr = accounts[0].Address
-			}
-		}
-	}
+	addr := callSender(b, args)
 	// Set default gas & gas price if none were set
 	gas, gasPrice := args.Gas.ToInt(), args.GasPrice.ToInt()
 	if gas.Sign() == 0 {
@@ -635,10 +792,16 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	defer func() { cancel() }()
 
 	// Get a new instance of the EVM.
-	evm, vmError, err := s.b.GetEVM(ctx, msg, state, header, vmCfg)
+	evm, vmError, err := b.GetEVM(ctx, msg, state, header, vmCfg)
 	if err != nil {
 		return nil, common.Big0, false, err
 	}
+	if overrides != nil {
+		overrides.Apply(state)
+	}
+	if tracer, ok := vmCfg.Tracer.(*JavascriptTracer); ok {
+		tracer.SetStateDB(state.Copy())
+	}
 	// Wait for the context to be done and cancel the evm. Even if the
 	// EVM has finished, cancelling may be done (repeatedly)
 	go func() {
@@ -658,36 +821,155 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 
 // Call executes the given transaction on the state for the given block number.
 // It doesn't make and changes in the state/blockchain and is useful to execute and retrieve values.
-func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
-	result, _, _, err := s.doCall(ctx, args, blockNr, vm.Config{DisableGasMetering: true})
+//
+// Additionally, the caller can specify a batch of accounts to override (balance,
+// nonce, code or storage slots) before the call is executed. The overrides only
+// affect the ephemeral state of this call, allowing simulation of e.g. contracts
+// not yet deployed or accounts not yet funded.
+func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, overrides *StateOverride) (hexutil.Bytes, error) {
+	result, _, _, err := s.doCall(ctx, args, blockNr, vm.Config{DisableGasMetering: true}, overrides)
 	return (hexutil.Bytes)(result), err
 }
 
+// SimulationResult is the outcome of a transaction executed against the pending
+// state without being broadcast.
+type SimulationResult struct {
+	Success    bool          `json:"success"`         // Whether the execution completed without failing
+	Error      string        `json:"error,omitempty"` // Reason the transaction would be rejected from a block
+	ReturnData hexutil.Bytes `json:"returnData"`      // Returned data, or the revert reason if one was given
+	GasUsed    *hexutil.Big  `json:"gasUsed"`         // Gas consumed by the execution (after refunds)
+	Logs       []*types.Log  `json:"logs"`            // Logs emitted during a successful execution
+}
+
+// SimulateTransaction executes an unsigned transaction against the pending state
+// and reports whether it would succeed, how much gas it would use and the logs it
+// would emit, without broadcasting it. Unlike Call, the gas allowance defaults to
+// the pending block's gas limit, the gas is metered and the sender needs to be
+// able to pay for it.
+func (s *PublicBlockChainAPI) SimulateTransaction(ctx context.Context, args CallArgs) (*SimulationResult, error) {
+	gas, gasPrice := args.Gas.ToInt(), args.GasPrice.ToInt()
+	if gas.Sign() == 0 {
+		block, err := s.b.BlockByNumber(ctx, rpc.PendingBlockNumber)
+		if err != nil {
+			return nil, err
+		}
+		gas = block.GasLimit()
+	}
+	if gasPrice.Sign() == 0 {
+		gasPrice = new(big.Int).SetUint64(defaultGasPrice)
+	}
+	msg := types.NewMessage(callSender(s.b, args), args.To, 0, args.Value.ToInt(), gas, gasPrice, args.Data, false)
+	return s.simulate(ctx, msg, common.Hash{})
+}
+
+// SimulateRawTransaction executes a signed transaction against the pending state
+// the same way as SimulateTransaction, additionally verifying the nonce and the
+// sender's funds, so wallets can warn users before sending doomed transactions.
+func (s *PublicBlockChainAPI) SimulateRawTransaction(ctx context.Context, encodedTx hexutil.Bytes) (*SimulationResult, error) {
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(encodedTx, tx); err != nil {
+		return nil, err
+	}
+	// The transaction is executed on the pending state, so validate its signature
+	// against the rules of the pending block too
+	header, err := s.b.HeaderByNumber(ctx, rpc.PendingBlockNumber)
+	if header == nil || err != nil {
+		return nil, err
+	}
+	msg, err := tx.AsMessage(types.MakeSigner(s.b.ChainConfig(), header.Number))
+	if err != nil {
+		return nil, err
+	}
+	return s.simulate(ctx, msg, tx.Hash())
+}
+
+// simulate applies a message on top of the pending state, collecting the logs it
+// emits under the given transaction hash.
+func (s *PublicBlockChainAPI) simulate(ctx context.Context, msg types.Message, hash common.Hash) (*SimulationResult, error) {
+	state, header, err := s.b.StateAndHeaderByNumber(ctx, rpc.PendingBlockNumber)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The EVM is set up with an unlimited sender balance for calls, restore the
+	// real one so unaffordable transactions are reported as such
+	balance := state.GetBalance(msg.From())
+	evm, vmError, err := s.b.GetEVM(ctx, msg, state, header, vm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	state.SetBalance(msg.From(), balance)
+
+	go func() {
+		<-ctx.Done()
+		evm.Cancel()
+	}()
+	state.Prepare(hash, header.Hash(), 0)
+
+	gp := new(core.GasPool).AddGas(header.GasLimit)
+	res, gas, failed, err := core.ApplyMessage(evm, msg, gp)
+	if err := vmError(); err != nil {
+		return nil, err
+	}
+	if err != nil {
+		return &SimulationResult{Error: err.Error(), GasUsed: (*hexutil.Big)(new(big.Int)), Logs: []*types.Log{}}, nil
+	}
+	result := &SimulationResult{
+		Success:    !failed,
+		ReturnData: res,
+		GasUsed:    (*hexutil.Big)(gas),
+		Logs:       []*types.Log{},
+	}
+	if !failed {
+		result.Logs = append(result.Logs, state.GetLogs(hash)...)
+	}
+	return result, nil
+}
+
 // EstimateGas returns an estimate of the amount of gas needed to execute the
-// given transaction against the current pending block.
-func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (*hexutil.Big, error) {
+// given transaction against the current pending block. The estimate is found by
+// binary searching between the intrinsic gas of the transaction and the gas
+// allowance (or the pending block's gas limit if none was given).
+//
+// The sender is funded with an unlimited balance during estimation, unless an
+// explicit balance is requested, allowing e.g. contract wallets and relayers to
+// check whether a call fits within the means of a specific (possibly unfunded)
+// account.
+func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs, balance *hexutil.Big) (*hexutil.Big, error) {
+	// Retrieve the current pending block to act as the gas ceiling
+	block, err := s.b.BlockByNumber(ctx, rpc.PendingBlockNumber)
+	if err != nil {
+		return nil, err
+	}
 	// Determine the lowest and highest possible gas limits to binary search in between
 	var (
-		lo  uint64 = params.TxGas - 1
+		lo  uint64
 		hi  uint64
 		cap uint64
 	)
-	if (*big.Int)(&args.Gas).Uint64() >= params.TxGas {
+	homestead := s.b.ChainConfig().IsHomestead(block.Number())
+	if intrinsic := core.IntrinsicGas(args.Data, args.To == nil, homestead); intrinsic.IsUint64() {
+		lo = intrinsic.Uint64() - 1
+	} else {
+		return nil, fmt.Errorf("intrinsic gas too high")
+	}
+	if (*big.Int)(&args.Gas).Uint64() > lo {
 		hi = (*big.Int)(&args.Gas).Uint64()
 	} else {
-		// Retrieve the current pending block to act as the gas ceiling
-		block, err := s.b.BlockByNumber(ctx, rpc.PendingBlockNumber)
-		if err != nil {
-			return nil, err
-		}
 		hi = block.GasLimit().Uint64()
 	}
 	cap = hi
 
+	var overrides *StateOverride
+	if balance != nil {
+		overrides = &StateOverride{callSender(s.b, args): {Balance: balance}}
+	}
 	// Create a helper to check if a gas allowance results in an executable transaction
 	executable := func(gas uint64) bool {
 		(*big.Int)(&args.Gas).SetUint64(gas)
-		_, _, failed, err := s.doCall(ctx, args, rpc.PendingBlockNumber, vm.Config{})
+		_, _, failed, err := s.doCall(ctx, args, rpc.PendingBlockNumber, vm.Config{}, overrides)
 		if err != nil || failed {
 			return false
 		}
@@ -985,16 +1267,26 @@ func (s *PublicTransactionPoolAPI) GetTransactionCount(ctx context.Context, addr
 }
 
 // GetTransactionByHash returns the transaction for the given hash
-func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, hash common.Hash) *RPCTransaction {
+func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, hash common.Hash) (*RPCTransaction, error) {
 	// Try to return an already finalized transaction
 	if tx, blockHash, blockNumber, index := core.GetTransaction(s.b.ChainDb(), hash); tx != nil {
-		return newRPCTransaction(tx, blockHash, blockNumber, index)
+		return newRPCTransaction(tx, blockHash, blockNumber, index), nil
 	}
 	// No finalized transaction, try to retrieve it from the pool
 	if tx := s.b.GetPoolTransaction(hash); tx != nil {
-		return newRPCPendingTransaction(tx)
+		return newRPCPendingTransaction(tx), nil
 	}
 	// Transaction unknown, return as such
+	return nil, txLookupError(s.b.ChainDb())
+}
+
+// txLookupError returns an error explaining that a transaction might not have
+// been found because it predates the transaction index, or nil if every block
+// is indexed and the transaction is genuinely unknown.
+func txLookupError(db ethdb.Database) error {
+	if tail := core.GetTxIndexTail(db); tail != nil && *tail > 0 {
+		return fmt.Errorf("transaction not found, only transactions in blocks #%d and later are indexed", *tail)
+	}
 	return nil
 }
 
@@ -1006,7 +1298,7 @@ func (s *PublicTransactionPoolAPI) GetRawTransactionByHash(ctx context.Context,
 	if tx, _, _, _ = core.GetTransaction(s.b.ChainDb(), hash); tx == nil {
 		if tx = s.b.GetPoolTransaction(hash); tx == nil {
 			// Transaction not found anywhere, abort
-			return nil, nil
+			return nil, txLookupError(s.b.ChainDb())
 		}
 	}
 	// Serialize to RLP and return
@@ -1017,7 +1309,11 @@ func (s *PublicTransactionPoolAPI) GetRawTransactionByHash(ctx context.Context,
 func (s *PublicTransactionPoolAPI) GetTransactionReceipt(hash common.Hash) (map[string]interface{}, error) {
 	tx, blockHash, blockNumber, index := core.GetTransaction(s.b.ChainDb(), hash)
 	if tx == nil {
-		return nil, nil
+		// Pending transactions simply have no receipt yet
+		if s.b.GetPoolTransaction(hash) != nil {
+			return nil, nil
+		}
+		return nil, txLookupError(s.b.ChainDb())
 	}
 	receipt, _, _, _ := core.GetReceipt(s.b.ChainDb(), hash) // Old receipts don't have the lookup data available
 
@@ -1057,8 +1353,9 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(hash common.Hash) (map[
 	return fields, nil
 }
 
-// sign is a helper function that signs a transaction with the private key of the given address.
-func (s *PublicTransactionPoolAPI) sign(addr common.Address, tx *types.Transaction) (*types.Transaction, error) {
+// sign is a helper function that signs a transaction with the private key of the given address,
+// recording the signature with the given origin into the audit log.
+func (s *PublicTransactionPoolAPI) sign(origin string, addr common.Address, tx *types.Transaction) (*types.Transaction, error) {
 	// Look up the wallet containing the requested signer
 	account := accounts.Account{Address: addr}
 
@@ -1071,7 +1368,18 @@ func (s *PublicTransactionPoolAPI) sign(addr common.Address, tx *types.Transacti
 	if config := s.b.ChainConfig(); config.IsEIP155(s.b.CurrentBlock().Number()) {
 		chainID = config.ChainId
 	}
-	return wallet.SignTx(account, tx, chainID)
+	if err := s.b.AccountManager().AuthorizeTx(addr, origin, tx); err != nil {
+		return nil, err
+	}
+	signed, err := wallet.SignTx(account, tx, chainID)
+	s.b.AccountManager().SettleTx(addr, tx, err == nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.b.AccountManager().Audit(addr, origin, accounts.AuditTransaction, signed.Hash()); err != nil {
+		return nil, err
+	}
+	return signed, nil
 }
 
 // SendTxArgs represents the arguments to sumbit a new transaction into the transaction pool.
@@ -1166,10 +1474,17 @@ func (s *PublicTransactionPoolAPI) SendTransactionWithPassphrase(ctx context.Con
 	if config := s.b.ChainConfig(); config.IsEIP155(s.b.CurrentBlock().Number()) {
 		chainID = config.ChainId
 	}
+	if err := s.b.AccountManager().AuthorizeTx(account.Address, "eth_sendTransactionWithPassphrase", tx); err != nil {
+		return common.Hash{}, err
+	}
 	signed, err := wallet.SignTxWithPassphrase(account, passphrase, tx, chainID)
+	s.b.AccountManager().SettleTx(account.Address, tx, err == nil)
 	if err != nil {
 		return common.Hash{}, err
 	}
+	if err := s.b.AccountManager().Audit(account.Address, "eth_sendTransactionWithPassphrase", accounts.AuditTransaction, signed.Hash()); err != nil {
+		return common.Hash{}, err
+	}
 	return submitTransaction(ctx, s.b, signed)
 }
 
@@ -1202,10 +1517,17 @@ func (s *PublicTransactionPoolAPI) SendTransaction(ctx context.Context, args Sen
 	if config := s.b.ChainConfig(); config.IsEIP155(s.b.CurrentBlock().Number()) {
 		chainID = config.ChainId
 	}
+	if err := s.b.AccountManager().AuthorizeTx(account.Address, "eth_sendTransaction", tx); err != nil {
+		return common.Hash{}, err
+	}
 	signed, err := wallet.SignTx(account, tx, chainID)
+	s.b.AccountManager().SettleTx(account.Address, tx, err == nil)
 	if err != nil {
 		return common.Hash{}, err
 	}
+	if err := s.b.AccountManager().Audit(account.Address, "eth_sendTransaction", accounts.AuditTransaction, signed.Hash()); err != nil {
+		return common.Hash{}, err
+	}
 	return submitTransaction(ctx, s.b, signed)
 }
 
@@ -1237,11 +1559,17 @@ func (s *PublicTransactionPoolAPI) Sign(addr common.Address, data hexutil.Bytes)
 		return nil, err
 	}
 	// Sign the requested hash with the wallet
-	signature, err := wallet.SignHash(account, signHash(data))
-	if err == nil {
-		signature[64] += 27 // Transform V from 0/1 to 27/28 according to the yellow paper
+	hash := signHash(data)
+
+	signature, err := wallet.SignHash(account, hash)
+	if err != nil {
+		return nil, err
 	}
-	return signature, err
+	if err := s.b.AccountManager().Audit(addr, "eth_sign", accounts.AuditHash, common.BytesToHash(hash)); err != nil {
+		return nil, err
+	}
+	signature[64] += 27 // Transform V from 0/1 to 27/28 according to the yellow paper
+	return signature, nil
 }
 
 // SignTransactionResult represents a RLP encoded signed transaction.
@@ -1263,7 +1591,7 @@ func (s *PublicTransactionPoolAPI) SignTransaction(ctx context.Context, args Sen
 	if err := args.setDefaults(ctx, s.b); err != nil {
 		return nil, err
 	}
-	tx, err := s.sign(args.From, args.toTransaction())
+	tx, err := s.sign("eth_signTransaction", args.From, args.toTransaction())
 	if err != nil {
 		return nil, err
 	}
@@ -1326,7 +1654,7 @@ func (s *PublicTransactionPoolAPI) Resend(ctx context.Context, sendArgs SendTxAr
 			if gasLimit != nil {
 				sendArgs.Gas = gasLimit
 			}
-			signedTx, err := s.sign(sendArgs.From, sendArgs.toTransaction())
+			signedTx, err := s.sign("eth_resend", sendArgs.From, sendArgs.toTransaction())
 			if err != nil {
 				return common.Hash{}, err
 			}
@@ -1397,7 +1725,7 @@ func NewPrivateDebugAPI(b Backend) *PrivateDebugAPI {
 
 // ChaindbProperty returns leveldb properties of the chain database.
 func (api *PrivateDebugAPI) ChaindbProperty(property string) (string, error) {
-	ldb, ok := api.b.ChainDb().(interface {
+	ldb, ok := core.KeyValueStore(api.b.ChainDb()).(interface {
 		LDB() *leveldb.DB
 	})
 	if !ok {
@@ -1412,7 +1740,7 @@ func (api *PrivateDebugAPI) ChaindbProperty(property string) (string, error) {
 }
 
 func (api *PrivateDebugAPI) ChaindbCompact() error {
-	ldb, ok := api.b.ChainDb().(interface {
+	ldb, ok := core.KeyValueStore(api.b.ChainDb()).(interface {
 		LDB() *leveldb.DB
 	})
 	if !ok {
@@ -1429,6 +1757,19 @@ func (api *PrivateDebugAPI) ChaindbCompact() error {
 	return nil
 }
 
+// DbStats returns the approximate storage space used by the tables of the chain
+// database.
+func (api *PrivateDebugAPI) DbStats() ([]core.TableUsage, error) {
+	usage, err := core.DatabaseUsage(api.b.ChainDb())
+	if err != nil {
+		return nil, err
+	}
+	if usage == nil {
+		return nil, fmt.Errorf("dbStats does not work for this database")
+	}
+	return usage, nil
+}
+
 // SetHead rewinds the head of the blockchain to a previous block.
 func (api *PrivateDebugAPI) SetHead(number hexutil.Uint64) {
 	api.b.SetHead(uint64(number))
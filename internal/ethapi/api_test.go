@@ -0,0 +1,427 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddress = crypto.PubkeyToAddress(testKey.PublicKey)
+
+	testStorer   = common.HexToAddress("0x0100") // Stores 1 into slot 0 (41006 gas in total)
+	testLogger   = common.HexToAddress("0x0200") // Emits an empty log
+	testReverter = common.HexToAddress("0x0300") // Reverts unconditionally
+	testInvalid  = common.HexToAddress("0x0400") // Executes an invalid opcode
+)
+
+// testBackend is a minimal API backend serving a fixed state as the pending one,
+// on top of a chain whose current block predates the EIP155 fork.
+type testBackend struct {
+	Backend // Unimplemented methods panic
+
+	config  *params.ChainConfig
+	db      state.Database
+	root    common.Hash
+	current *types.Header
+	pending *types.Header
+	pool    *CallPool
+}
+
+func newTestBackend(t *testing.T) *testBackend {
+	db, _ := ethdb.NewMemDatabase()
+	sdb := state.NewDatabase(db)
+	statedb, _ := state.New(common.Hash{}, sdb)
+
+	statedb.SetBalance(testAddress, big.NewInt(params.Ether))
+	statedb.SetNonce(testAddress, 1)
+	statedb.SetCode(testStorer, common.FromHex("600160005500"))
+	statedb.SetCode(testLogger, common.FromHex("60006000a000"))
+	statedb.SetCode(testReverter, common.FromHex("60006000fd"))
+	statedb.SetCode(testInvalid, common.FromHex("fe"))
+
+	root, err := statedb.CommitTo(db, true)
+	if err != nil {
+		t.Fatalf("failed to commit test state: %v", err)
+	}
+	config := &params.ChainConfig{
+		ChainId:        big.NewInt(1),
+		HomesteadBlock: big.NewInt(0),
+		EIP150Block:    big.NewInt(0),
+		EIP155Block:    big.NewInt(1),
+		EIP158Block:    big.NewInt(1),
+		ByzantiumBlock: big.NewInt(0),
+	}
+	current := &types.Header{Number: big.NewInt(0), Root: root, Difficulty: big.NewInt(1), GasLimit: big.NewInt(4712388), Time: big.NewInt(0)}
+	pending := &types.Header{Number: big.NewInt(1), Root: root, ParentHash: current.Hash(), Difficulty: big.NewInt(1), GasLimit: big.NewInt(4712388), Time: big.NewInt(10)}
+
+	return &testBackend{config: config, db: sdb, root: root, current: current, pending: pending, pool: NewCallPool(1)}
+}
+
+func (b *testBackend) ChainConfig() *params.ChainConfig { return b.config }
+func (b *testBackend) CurrentBlock() *types.Block       { return types.NewBlockWithHeader(b.current) }
+func (b *testBackend) CallPool() *CallPool              { return b.pool }
+
+func (b *testBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error) {
+	if blockNr == rpc.PendingBlockNumber {
+		return b.pending, nil
+	}
+	return b.current, nil
+}
+
+func (b *testBackend) BlockByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Block, error) {
+	header, _ := b.HeaderByNumber(ctx, blockNr)
+	return types.NewBlockWithHeader(header), nil
+}
+
+func (b *testBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	header, _ := b.HeaderByNumber(ctx, blockNr)
+	statedb, err := state.New(b.root, b.db)
+	return statedb, header, err
+}
+
+func (b *testBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmCfg vm.Config) (*vm.EVM, func() error, error) {
+	state.SetBalance(msg.From(), math.MaxBig256)
+	context := core.NewEVMContext(msg, header, nil, &header.Coinbase)
+	return vm.NewEVM(context, state, b.config, vmCfg), func() error { return nil }, nil
+}
+
+// Tests that state overrides are decoded from their JSON form and that only the
+// specified fields are replaced in the state.
+func TestStateOverrideApply(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+	funded, fresh := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	statedb.SetBalance(funded, big.NewInt(100))
+	statedb.SetNonce(funded, 7)
+	statedb.SetState(funded, common.Hash{0x01}, common.Hash{0xaa})
+
+	blob := `{
+		"0x0000000000000000000000000000000000000001": {"balance": "0x1", "storage": {"0x0200000000000000000000000000000000000000000000000000000000000000": "0x00000000000000000000000000000000000000000000000000000000000000bb"}},
+		"0x0000000000000000000000000000000000000002": {"nonce": "0x3", "code": "0x6000"}
+	}`
+	var overrides StateOverride
+	if err := json.Unmarshal([]byte(blob), &overrides); err != nil {
+		t.Fatalf("failed to decode overrides: %v", err)
+	}
+	overrides.Apply(statedb)
+
+	if balance := statedb.GetBalance(funded); balance.Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("balance mismatch: have %v, want 1", balance)
+	}
+	if nonce := statedb.GetNonce(funded); nonce != 7 {
+		t.Errorf("nonce mismatch: have %d, want 7", nonce)
+	}
+	if value := statedb.GetState(funded, common.Hash{0x01}); value != (common.Hash{0xaa}) {
+		t.Errorf("untouched slot mismatch: have %x, want %x", value, common.Hash{0xaa})
+	}
+	if value := statedb.GetState(funded, common.Hash{0x02}); value != common.BigToHash(big.NewInt(0xbb)) {
+		t.Errorf("overridden slot mismatch: have %x, want %x", value, common.BigToHash(big.NewInt(0xbb)))
+	}
+	if nonce := statedb.GetNonce(fresh); nonce != 3 {
+		t.Errorf("nonce mismatch: have %d, want 3", nonce)
+	}
+	if code := statedb.GetCode(fresh); !bytes.Equal(code, []byte{0x60, 0x00}) {
+		t.Errorf("code mismatch: have %x, want 6000", code)
+	}
+}
+
+// Tests that unsigned transactions are simulated against the pending state with
+// metered gas, reporting failures, logs and unaffordable transactions.
+func TestSimulateTransaction(t *testing.T) {
+	api := NewPublicBlockChainAPI(newTestBackend(t))
+	poor := common.HexToAddress("0xdead")
+
+	tests := []struct {
+		args    CallArgs
+		success bool
+		gas     uint64
+		logs    int
+		err     string
+	}{
+		{args: CallArgs{From: testAddress, To: &testStorer}, success: true, gas: 41006},
+		{args: CallArgs{From: testAddress, To: &testLogger}, success: true, gas: 21000 + 3 + 3 + 375, logs: 1},
+		{args: CallArgs{From: testAddress, To: &testReverter}, success: false, gas: 21000 + 3 + 3},
+		{args: CallArgs{From: testAddress, To: &testInvalid, Gas: hexutil.Big(*big.NewInt(50000))}, success: false, gas: 50000},
+		{args: CallArgs{From: testAddress, To: &testStorer, Gas: hexutil.Big(*big.NewInt(30000))}, success: false, gas: 30000},
+		{args: CallArgs{From: poor, To: &testStorer}, err: "insufficient balance"},
+	}
+	for i, tt := range tests {
+		res, err := api.SimulateTransaction(context.Background(), tt.args)
+		if err != nil {
+			t.Fatalf("test %d: failed to simulate: %v", i, err)
+		}
+		if tt.err != "" {
+			if !strings.Contains(res.Error, tt.err) || res.Success {
+				t.Errorf("test %d: result mismatch: have %+v, want error %q", i, res, tt.err)
+			}
+			continue
+		}
+		if res.Error != "" {
+			t.Errorf("test %d: unexpected rejection: %s", i, res.Error)
+		}
+		if res.Success != tt.success {
+			t.Errorf("test %d: success mismatch: have %v, want %v", i, res.Success, tt.success)
+		}
+		if gas := res.GasUsed.ToInt().Uint64(); gas != tt.gas {
+			t.Errorf("test %d: gas mismatch: have %d, want %d", i, gas, tt.gas)
+		}
+		if len(res.Logs) != tt.logs {
+			t.Errorf("test %d: log count mismatch: have %d, want %d", i, len(res.Logs), tt.logs)
+		}
+	}
+}
+
+// Tests that signed transactions are validated against the pending block's rules
+// (EIP155 being active there but not yet at the current head) and that nonces are
+// checked during simulation.
+func TestSimulateRawTransaction(t *testing.T) {
+	api := NewPublicBlockChainAPI(newTestBackend(t))
+	signer := types.NewEIP155Signer(big.NewInt(1))
+
+	tests := []struct {
+		nonce   uint64
+		to      common.Address
+		success bool
+		logs    int
+		err     string
+	}{
+		{nonce: 1, to: testLogger, success: true, logs: 1},
+		{nonce: 1, to: testReverter, success: false},
+		{nonce: 0, to: testStorer, err: "nonce too low"},
+		{nonce: 2, to: testStorer, err: "nonce too high"},
+	}
+	for i, tt := range tests {
+		tx, _ := types.SignTx(types.NewTransaction(tt.nonce, tt.to, new(big.Int), big.NewInt(100000), big.NewInt(1), nil), signer, testKey)
+		blob, _ := rlp.EncodeToBytes(tx)
+
+		res, err := api.SimulateRawTransaction(context.Background(), blob)
+		if err != nil {
+			t.Fatalf("test %d: failed to simulate: %v", i, err)
+		}
+		if tt.err != "" {
+			if !strings.Contains(res.Error, tt.err) || res.Success {
+				t.Errorf("test %d: result mismatch: have %+v, want error %q", i, res, tt.err)
+			}
+			continue
+		}
+		if res.Success != tt.success || res.Error != "" {
+			t.Errorf("test %d: result mismatch: have %+v, want success %v", i, res, tt.success)
+		}
+		if len(res.Logs) != tt.logs {
+			t.Errorf("test %d: log count mismatch: have %d, want %d", i, len(res.Logs), tt.logs)
+		}
+		for _, log := range res.Logs {
+			if log.TxHash != tx.Hash() {
+				t.Errorf("test %d: log hash mismatch: have %x, want %x", i, log.TxHash, tx.Hash())
+			}
+		}
+	}
+	// Transactions must be decodable
+	if _, err := api.SimulateRawTransaction(context.Background(), []byte{0x01, 0x02}); err == nil {
+		t.Errorf("garbage transaction accepted")
+	}
+}
+
+// Tests that gas estimation finds the exact gas requirement within the allowed
+// bounds, honours the caller's cap and sender balance and rejects failing calls.
+func TestEstimateGas(t *testing.T) {
+	api := NewPublicBlockChainAPI(newTestBackend(t))
+	recipient := common.HexToAddress("0xdead")
+
+	tests := []struct {
+		args    CallArgs
+		balance *big.Int
+		want    uint64
+		fail    bool
+	}{
+		// Plain transfers need exactly the intrinsic gas (lower search bound)
+		{args: CallArgs{From: testAddress, To: &recipient}, want: params.TxGas},
+		{args: CallArgs{From: testAddress, To: &recipient, Value: hexutil.Big(*big.NewInt(1))}, want: params.TxGas},
+
+		// Calls are searched up to the pending block's gas limit if no cap is given
+		{args: CallArgs{From: testAddress, To: &testStorer}, want: 41006},
+		{args: CallArgs{From: testAddress, To: &testLogger}, want: 21000 + 3 + 3 + 375},
+
+		// Caps are honoured if above the intrinsic gas, ignored otherwise
+		{args: CallArgs{From: testAddress, To: &testStorer, Gas: hexutil.Big(*big.NewInt(41006))}, want: 41006},
+		{args: CallArgs{From: testAddress, To: &testStorer, Gas: hexutil.Big(*big.NewInt(50000))}, want: 41006},
+		{args: CallArgs{From: testAddress, To: &testStorer, Gas: hexutil.Big(*big.NewInt(41005))}, fail: true},
+		{args: CallArgs{From: testAddress, To: &testStorer, Gas: hexutil.Big(*big.NewInt(1000))}, want: 41006},
+
+		// Calls failing at any allowance are rejected
+		{args: CallArgs{From: testAddress, To: &testReverter}, fail: true},
+		{args: CallArgs{From: testAddress, To: &testInvalid}, fail: true},
+
+		// Explicit balances limit what the sender can afford
+		{args: CallArgs{From: recipient, To: &recipient, Value: hexutil.Big(*big.NewInt(1))}, balance: big.NewInt(params.Ether), want: params.TxGas},
+		{args: CallArgs{From: recipient, To: &recipient, Value: hexutil.Big(*big.NewInt(1))}, balance: big.NewInt(0), fail: true},
+	}
+	for i, tt := range tests {
+		var balance *hexutil.Big
+		if tt.balance != nil {
+			balance = (*hexutil.Big)(tt.balance)
+		}
+		gas, err := api.EstimateGas(context.Background(), tt.args, balance)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("test %d: estimation succeeded with %d gas, want failure", i, gas.ToInt())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: failed to estimate gas: %v", i, err)
+			continue
+		}
+		if gas.ToInt().Uint64() != tt.want {
+			t.Errorf("test %d: gas mismatch: have %d, want %d", i, gas.ToInt(), tt.want)
+		}
+	}
+}
+
+// chainDbBackend is an API backend only serving a chain database.
+type chainDbBackend struct {
+	Backend // Unimplemented methods panic
+	db      ethdb.Database
+}
+
+func (b *chainDbBackend) ChainDb() ethdb.Database { return b.db }
+
+// Tests that the chain database debug methods reach the LevelDB store behind the
+// ancient data freezer.
+func TestChaindbFreezer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethapi-chaindb-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ldb, err := ethdb.NewLDBDatabase(filepath.Join(dir, "chaindata"), 16, 16)
+	if err != nil {
+		t.Fatalf("failed to create leveldb: %v", err)
+	}
+	db, err := core.NewDatabaseWithFreezer(ldb, filepath.Join(dir, "ancient"))
+	if err != nil {
+		t.Fatalf("failed to attach freezer: %v", err)
+	}
+	defer db.Close()
+
+	api := NewPrivateDebugAPI(&chainDbBackend{db: db})
+	if stats, err := api.ChaindbProperty(""); err != nil || stats == "" {
+		t.Errorf("failed to retrieve database stats: %q, %v", stats, err)
+	}
+	if err := api.ChaindbCompact(); err != nil {
+		t.Errorf("failed to compact database: %v", err)
+	}
+}
+
+// waitForWallet waits a while for the account manager to pick up the wallet of a
+// newly imported account.
+func waitForWallet(am *accounts.Manager, addr common.Address) {
+	for i := 0; i < 100; i++ {
+		if _, err := am.Find(accounts.Account{Address: addr}); err == nil {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Tests that keys imported from a mnemonic match the BIP-39/44 test vectors, and
+// that their derivation paths are exported for recovery in other wallets.
+func TestMnemonicKeyDerivation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethapi-keystore-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	api := &PrivateAccountAPI{am: accounts.NewManager(keystore.NewKeyStore(dir, keystore.LightScryptN, keystore.LightScryptP))}
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+	str := func(s string) *string { return &s }
+
+	// Protected wallets derive other keys than the ones of the test vectors
+	protected, err := keystore.DeriveKey(mnemonic, "TREZOR", accounts.DerivationPath{0x80000000 + 44, 0x80000000 + 60, 0x80000000, 0, 2})
+	if err != nil {
+		t.Fatalf("failed to derive protected key: %v", err)
+	}
+	tests := []struct {
+		password *string
+		path     *string
+		address  common.Address
+		export   string
+	}{
+		{nil, nil, common.HexToAddress("0x9858EfFD232B4033E47d90003D41EC34EcaEda94"), "m/44'/60'/0'/0/0"},
+		{nil, str("m/44'/60'/0'/0/1"), common.HexToAddress("0x6Fac4D18c912343BF86fa7049364Dd4E424Ab9C0"), "m/44'/60'/0'/0/1"},
+		{str("TREZOR"), str("m/44'/60'/0'/0/2"), crypto.PubkeyToAddress(protected.PublicKey), "m/44'/60'/0'/0/2"},
+	}
+	for i, tt := range tests {
+		addr, err := api.ImportMnemonicKey(mnemonic, tt.password, tt.path, "foo")
+		if err != nil {
+			t.Fatalf("test %d: failed to import key: %v", i, err)
+		}
+		if addr != tt.address {
+			t.Errorf("test %d: address mismatch: have %x, want %x", i, addr, tt.address)
+		}
+		waitForWallet(api.am, addr)
+		export, err := api.ExportDerivation(addr)
+		if err != nil {
+			t.Fatalf("test %d: failed to export derivation: %v", i, err)
+		}
+		if export.Address != addr || export.Path != tt.export {
+			t.Errorf("test %d: export mismatch: have %x at %q, want %x at %q", i, export.Address, export.Path, addr, tt.export)
+		}
+	}
+	if protected := crypto.PubkeyToAddress(protected.PublicKey); protected == common.HexToAddress("0xb6716976A3ebe8D39aCEB04372f22Ff8e6802D7A") {
+		t.Errorf("mnemonic password ignored")
+	}
+	// Raw keys have no derivation path to export
+	addr, err := api.ImportRawKey("289c2857d4598e37fb9647507e47a309d6133539bf21a8b9cb6df88fd5232032", "foo")
+	if err != nil {
+		t.Fatalf("failed to import raw key: %v", err)
+	}
+	waitForWallet(api.am, addr)
+	if export, err := api.ExportDerivation(addr); err != nil || export.Path != "" {
+		t.Errorf("raw key export mismatch: have %+v (%v), want no path", export, err)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"runtime"
+)
+
+// CallPool limits the number of read-only EVM executions (eth_call, gas estimation,
+// tracing calls) running concurrently. Each execution works on its own copy of
+// the requested state, so admitted calls never serialize behind each other or the
+// block import, while a burst of requests can't starve the node of CPU either.
+//
+// A nil pool admits all calls without a limit.
+type CallPool struct {
+	slots chan struct{} // Semaphore of the execution slots
+}
+
+// NewCallPool creates a pool running at most the given number of executions at
+// once, or as many as there are CPUs if zero.
+func NewCallPool(workers int) *CallPool {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	return &CallPool{slots: make(chan struct{}, workers)}
+}
+
+// Acquire waits for a free execution slot, returning a function releasing it once
+// the execution finished. It fails if the context is done before a slot frees up.
+func (p *CallPool) Acquire(ctx context.Context) (func(), error) {
+	if p == nil {
+		return func() {}, nil
+	}
+	select {
+	case p.slots <- struct{}{}:
+		return func() { <-p.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Workers returns the maximum number of concurrent executions.
+func (p *CallPool) Workers() int {
+	if p == nil {
+		return 0
+	}
+	return cap(p.slots)
+}
+
+// Busy returns the number of executions currently running.
+func (p *CallPool) Busy() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.slots)
+}
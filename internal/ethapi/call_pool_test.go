@@ -0,0 +1,67 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Tests that the call pool admits at most its worker count of executions at once,
+// and that waiting for a slot can be aborted.
+func TestCallPool(t *testing.T) {
+	pool := NewCallPool(2)
+
+	var releases []func()
+	for i := 0; i < 2; i++ {
+		release, err := pool.Acquire(context.Background())
+		if err != nil {
+			t.Fatalf("slot %d: failed to acquire: %v", i, err)
+		}
+		releases = append(releases, release)
+	}
+	if busy := pool.Busy(); busy != 2 {
+		t.Errorf("busy workers mismatch: have %d, want %d", busy, 2)
+	}
+	// The pool is exhausted, further executions need to wait
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Acquire(ctx); err != context.DeadlineExceeded {
+		t.Errorf("exhausted pool error mismatch: have %v, want %v", err, context.DeadlineExceeded)
+	}
+	// Releasing a slot admits a waiting execution
+	admitted := make(chan error)
+	go func() {
+		_, err := pool.Acquire(context.Background())
+		admitted <- err
+	}()
+	releases[0]()
+	select {
+	case err := <-admitted:
+		if err != nil {
+			t.Errorf("waiting execution failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("waiting execution not admitted")
+	}
+	// A nil pool doesn't limit anything
+	var unlimited *CallPool
+	if _, err := unlimited.Acquire(context.Background()); err != nil {
+		t.Errorf("nil pool rejected execution: %v", err)
+	}
+}
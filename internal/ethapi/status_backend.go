@@ -84,5 +84,5 @@ func (b *StatusBackend) EstimateGas(ctx context.Context, args status.SendTxArgs)
 		Data:     args.Data,
 	}
 
-	return b.bcapi.EstimateGas(ctx, callArgs)
+	return b.bcapi.EstimateGas(ctx, callArgs, nil)
 }
@@ -29,6 +29,42 @@ import (
 	"github.com/robertkrimen/otto"
 )
 
+// toAddress converts the various representations of an address available to the
+// Javascript tracers (hex strings, byte slices, addresses and stack words) into
+// the byte slice expected by the db object.
+func toAddress(value interface{}) []byte {
+	switch value := value.(type) {
+	case string:
+		return common.HexToAddress(value).Bytes()
+	case []byte:
+		return common.BytesToAddress(value).Bytes()
+	case common.Address:
+		return value.Bytes()
+	case *big.Int:
+		return common.BigToAddress(value).Bytes()
+	default:
+		panic(fmt.Sprintf("cannot convert %T to address", value))
+	}
+}
+
+// toWord converts the various representations of a 32 byte word available to the
+// Javascript tracers (hex strings, byte slices and stack words) into the byte
+// slice expected by the db object for storage keys.
+func toWord(value interface{}) []byte {
+	switch value := value.(type) {
+	case string:
+		return common.HexToHash(value).Bytes()
+	case []byte:
+		return common.BytesToHash(value).Bytes()
+	case common.Hash:
+		return value.Bytes()
+	case *big.Int:
+		return common.BigToHash(value).Bytes()
+	default:
+		panic(fmt.Sprintf("cannot convert %T to word", value))
+	}
+}
+
 // fakeBig is used to provide an interface to Javascript for 'big.NewInt'
 type fakeBig struct{}
 
@@ -144,9 +180,15 @@ func (dw *dbWrapper) getCode(addr []byte) []byte {
 	return dw.db.GetCode(common.BytesToAddress(addr))
 }
 
-// getState retrieves an account's state data for the given hash
-func (dw *dbWrapper) getState(addr []byte, hash common.Hash) common.Hash {
-	return dw.db.GetState(common.BytesToAddress(addr), hash)
+// getState retrieves an account's state data for the given hash. The hash may be
+// given in any form accepted by toWord; if given as a common.Hash, the data is
+// returned as one too, as done originally.
+func (dw *dbWrapper) getState(addr interface{}, hash interface{}) interface{} {
+	value := dw.db.GetState(common.BytesToAddress(toAddress(addr)), common.BytesToHash(toWord(hash)))
+	if _, ok := hash.(common.Hash); ok {
+		return value
+	}
+	return value.Bytes()
 }
 
 // exists returns true iff the account exists
@@ -212,19 +254,29 @@ type JavascriptTracer struct {
 	dbvalue       otto.Value             // JS view of `db`
 	contract      *contractWrapper       // Wrapper around the contract object
 	contractvalue otto.Value             // JS view of `contract`
+	hasFault      bool                   // Whether the tracer handles failed steps in `fault`
+	hasEnter      bool                   // Whether the tracer follows call frames through `enter`
+	hasExit       bool                   // Whether the tracer follows call frames through `exit`
 	err           error                  // Error, if one has occurred
 }
 
 // NewJavascriptTracer instantiates a new JavascriptTracer instance.
 // code specifies a Javascript snippet, which must evaluate to an expression
-// returning an object with 'step' and 'result' functions.
+// returning an object with 'step' and 'result' functions, or the name of one
+// of the built-in tracers. The object may also expose a 'fault' function to
+// handle failed steps, and 'enter' and 'exit' functions to follow call frames.
 func NewJavascriptTracer(code string) (*JavascriptTracer, error) {
+	if builtin, ok := builtinTracers[code]; ok {
+		code = builtin
+	}
 	vm := otto.New()
 	vm.Interrupt = make(chan func(), 1)
 
 	// Set up builtins for this environment
 	vm.Set("big", &fakeBig{})
 	vm.Set("toHex", hexutil.Encode)
+	vm.Set("toAddress", toAddress)
+	vm.Set("toWord", toWord)
 
 	jstracer, err := vm.Object("(" + code + ")")
 	if err != nil {
@@ -247,6 +299,11 @@ func NewJavascriptTracer(code string) (*JavascriptTracer, error) {
 	if !result.IsFunction() {
 		return nil, fmt.Errorf("Trace object must expose a function result()")
 	}
+	// Check which optional functions exist
+	hasFunction := func(name string) bool {
+		fn, err := jstracer.Get(name)
+		return err == nil && fn.IsFunction()
+	}
 
 	// Create the persistent log object
 	log := make(map[string]interface{})
@@ -271,10 +328,22 @@ func NewJavascriptTracer(code string) (*JavascriptTracer, error) {
 		dbvalue:       db.toValue(vm),
 		contract:      contract,
 		contractvalue: contract.toValue(vm),
+		hasFault:      hasFunction("fault"),
+		hasEnter:      hasFunction("enter"),
+		hasExit:       hasFunction("exit"),
 		err:           nil,
 	}, nil
 }
 
+// SetStateDB sets the state the db object reads from until the first step of the
+// execution, allowing the enter function to inspect the outermost call frame.
+// Callers pass a copy of the state from before the message is applied, so that
+// the sender and recipient are seen before the gas purchase, nonce increment and
+// value transfer.
+func (jst *JavascriptTracer) SetStateDB(db vm.StateDB) {
+	jst.db.db = db
+}
+
 // Stop terminates execution of any JavaScript
 func (jst *JavascriptTracer) Stop(err error) {
 	jst.vm.Interrupt <- func() {
@@ -318,6 +387,12 @@ func wrapError(context string, err error) error {
 
 // CaptureState implements the Tracer interface to trace a single step of VM execution
 func (jst *JavascriptTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	jst.capture("step", env, pc, op, gas, cost, memory, stack, contract, depth, err)
+	return nil
+}
+
+// capture passes a single step of VM execution to the given tracer function.
+func (jst *JavascriptTracer) capture(method string, env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) {
 	if jst.err == nil {
 		jst.memory.memory = memory
 		jst.stack.stack = stack
@@ -337,20 +412,89 @@ func (jst *JavascriptTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode,
 		jst.log["account"] = contract.Address()
 		jst.log["err"] = err
 
-		_, err := jst.callSafely("step", jst.logvalue, jst.dbvalue)
+		_, err := jst.callSafely(method, jst.logvalue, jst.dbvalue)
 		if err != nil {
-			jst.err = wrapError("step", err)
+			jst.err = wrapError(method, err)
 		}
 	}
+}
+
+// CaptureStart implements the Tracer interface, passing the outermost call frame
+// to the tracer's enter function.
+func (jst *JavascriptTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	typ := vm.CALL
+	if create {
+		typ = vm.CREATE
+	}
+	return jst.CaptureEnter(typ, from, to, input, gas, value)
+}
+
+// CaptureFault implements the Tracer interface, passing the failed step to the
+// tracer's fault function, or its step function with the error set if there's
+// no fault function.
+func (jst *JavascriptTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if jst.hasFault {
+		jst.capture("fault", env, pc, op, gas, cost, memory, stack, contract, depth, err)
+	} else {
+		jst.capture("step", env, pc, op, gas, cost, memory, stack, contract, depth, err)
+	}
 	return nil
 }
 
-// CaptureEnd is called after the call finishes
-func (jst *JavascriptTracer) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
-	//TODO! @Arachnid please figure out of there's anything we can use this method for
+// CaptureEnter implements the Tracer interface, passing the entered call frame
+// to the tracer's enter function. Addresses, input and value are hex encoded.
+// The db object is only usable for the outermost frame if the state was set.
+func (jst *JavascriptTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) error {
+	if jst.err != nil || !jst.hasEnter {
+		return nil
+	}
+	frame := map[string]interface{}{
+		"type":  typ.String(),
+		"from":  hexutil.Encode(from.Bytes()),
+		"to":    hexutil.Encode(to.Bytes()),
+		"input": hexutil.Encode(input),
+		"gas":   gas,
+	}
+	if value != nil {
+		frame["value"] = hexutil.EncodeBig(value)
+	}
+	if _, err := jst.callSafely("enter", frame, jst.dbvalue); err != nil {
+		jst.err = wrapError("enter", err)
+	}
+	return nil
+}
+
+// CaptureExit implements the Tracer interface, passing the result of the exited
+// call frame to the tracer's exit function. The output is hex encoded.
+func (jst *JavascriptTracer) CaptureExit(output []byte, gasUsed uint64, err error) error {
+	if jst.err != nil || !jst.hasExit {
+		return nil
+	}
+	result := map[string]interface{}{
+		"output":  hexutil.Encode(output),
+		"gasUsed": gasUsed,
+	}
+	if err != nil {
+		result["error"] = err.Error()
+	}
+	if _, err := jst.callSafely("exit", result); err != nil {
+		jst.err = wrapError("exit", err)
+	}
+	return nil
+}
+
+// CaptureStorageChange implements the Tracer interface, it's not exposed to the
+// tracer code which can access storage through the db object instead.
+func (jst *JavascriptTracer) CaptureStorageChange(env *vm.EVM, addr common.Address, key, value common.Hash) error {
 	return nil
 }
 
+// CaptureEnd implements the Tracer interface, passing the result of the outermost
+// call frame to the tracer's exit function.
+func (jst *JavascriptTracer) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
+	return jst.CaptureExit(output, gasUsed, err)
+}
+
 // GetResult calls the Javascript 'result' function and returns its value, or any accumulated error
 func (jst *JavascriptTracer) GetResult() (result interface{}, err error) {
 	if jst.err != nil {
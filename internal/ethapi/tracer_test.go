@@ -17,6 +17,7 @@
 package ethapi
 
 import (
+	"encoding/json"
 	"errors"
 	"math/big"
 	"reflect"
@@ -24,7 +25,12 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -145,3 +151,174 @@ func TestHaltBetweenSteps(t *testing.T) {
 		t.Errorf("Expected timeout error, got %v", err)
 	}
 }
+
+// runCallTrace executes a contract calling into a second one, which writes and
+// reads a storage slot, returning the JSON encoded result of the tracer.
+func runCallTrace(t *testing.T, tracer *JavascriptTracer) string {
+	statedb, caller := newCallTraceState()
+
+	tracer.SetStateDB(statedb)
+	executeCallTrace(t, statedb, caller, tracer)
+
+	ret, err := tracer.GetResult()
+	if err != nil {
+		t.Fatalf("failed to retrieve trace result: %v", err)
+	}
+	blob, err := json.Marshal(ret)
+	if err != nil {
+		t.Fatalf("failed to encode trace result: %v", err)
+	}
+	return string(blob)
+}
+
+// newCallTraceState creates a state with a contract calling into a second one,
+// which writes and reads a storage slot, returning the address of the caller.
+func newCallTraceState() (*state.StateDB, common.Address) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+	caller, callee := common.Address{0xaa}, common.Address{0xbb}
+	statedb.SetBalance(common.Address{0x01}, big.NewInt(1000))
+	statedb.SetCode(callee, []byte{
+		byte(vm.PUSH1), 0x2a, byte(vm.PUSH1), 0x01, byte(vm.SSTORE),
+		byte(vm.PUSH1), 0x01, byte(vm.SLOAD), byte(vm.POP), byte(vm.STOP),
+	})
+	code := []byte{byte(vm.PUSH4), 0xaa, 0xbb, 0xcc, 0xdd, byte(vm.PUSH1), 0xe0, byte(vm.PUSH1), 0x02, byte(vm.EXP), byte(vm.MUL), byte(vm.PUSH1), 0x00, byte(vm.MSTORE)}
+	code = append(code, byte(vm.PUSH1), 0x00, byte(vm.PUSH1), 0x00, byte(vm.PUSH1), 0x24, byte(vm.PUSH1), 0x00, byte(vm.PUSH1), 0x00, byte(vm.PUSH20))
+	code = append(code, callee.Bytes()...)
+	code = append(code, byte(vm.PUSH2), 0xff, 0xff, byte(vm.CALL), byte(vm.POP), byte(vm.STOP))
+	statedb.SetCode(caller, code)
+
+	return statedb, caller
+}
+
+// executeCallTrace calls the given contract from a fixed origin with tracing.
+func executeCallTrace(t *testing.T, statedb *state.StateDB, contract common.Address, tracer vm.Tracer) {
+	_, _, err := runtime.Call(contract, nil, &runtime.Config{
+		Origin:    common.Address{0x01},
+		GasLimit:  100000,
+		State:     statedb,
+		EVMConfig: vm.Config{Debug: true, Tracer: tracer},
+	})
+	if err != nil {
+		t.Fatalf("failed to execute call: %v", err)
+	}
+}
+
+// Tests that the built-in tracers can be requested by name and produce the
+// expected results.
+func TestBuiltinTracers(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"4byteTracer", `{"0xaabbccdd-32":1}`},
+		{"callTracer", `{"calls":[{"from":"0xaa00000000000000000000000000000000000000","gas":"0xffff","gasUsed":"0x4ef3","input":"0xaabbccdd0000000000000000000000000000000000000000000000000000000000000000","output":"0x","to":"0xbb00000000000000000000000000000000000000","type":"CALL","value":"0x0"}],"from":"0x0100000000000000000000000000000000000000","gas":"0x186a0","gasUsed":"0x521c","input":"0x","output":"0x","to":"0xaa00000000000000000000000000000000000000","type":"CALL","value":"0x0"}`},
+		{"prestateTracer", `{"0x0100000000000000000000000000000000000000":{"balance":"0x3e8","code":"0x","nonce":0,"storage":{}},"0xaa00000000000000000000000000000000000000":{"balance":"0x0","code":"0x63aabbccdd60e060020a026000526000600060246000600073bb0000000000000000000000000000000000000061fffff15000","nonce":0,"storage":{}},"0xbb00000000000000000000000000000000000000":{"balance":"0x0","code":"0x602a6001556001545000","nonce":0,"storage":{"0x0000000000000000000000000000000000000000000000000000000000000001":"0x0000000000000000000000000000000000000000000000000000000000000000"}}}`},
+	}
+	for _, tt := range tests {
+		tracer, err := NewJavascriptTracer(tt.name)
+		if err != nil {
+			t.Fatalf("%s: failed to create tracer: %v", tt.name, err)
+		}
+		if have := runCallTrace(t, tracer); have != tt.want {
+			t.Errorf("%s: result mismatch:\nhave %s\nwant %s", tt.name, have, tt.want)
+		}
+	}
+}
+
+// Tests that the prestate tracer reports the sender and recipient of a message as
+// they were before the gas purchase, nonce increment and value transfer.
+func TestPrestateTracerMessage(t *testing.T) {
+	statedb, caller := newCallTraceState()
+	sender := common.Address{0x01}
+	statedb.SetBalance(sender, big.NewInt(1000000))
+
+	tracer, err := NewJavascriptTracer("prestateTracer")
+	if err != nil {
+		t.Fatalf("failed to create tracer: %v", err)
+	}
+	tracer.SetStateDB(statedb.Copy())
+
+	ctx := vm.Context{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		Origin:      sender,
+		BlockNumber: new(big.Int),
+		GasLimit:    big.NewInt(1000000),
+		GasPrice:    big.NewInt(1),
+	}
+	evm := vm.NewEVM(ctx, statedb, params.TestChainConfig, vm.Config{Debug: true, Tracer: tracer})
+	msg := types.NewMessage(sender, &caller, 0, big.NewInt(7), big.NewInt(100000), big.NewInt(1), nil, true)
+	if _, _, _, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(big.NewInt(1000000))); err != nil {
+		t.Fatalf("failed to apply message: %v", err)
+	}
+	ret, err := tracer.GetResult()
+	if err != nil {
+		t.Fatalf("failed to retrieve trace result: %v", err)
+	}
+	blob, _ := json.Marshal(ret)
+
+	var prestate map[common.Address]struct {
+		Balance string `json:"balance"`
+		Nonce   uint64 `json:"nonce"`
+	}
+	if err := json.Unmarshal(blob, &prestate); err != nil {
+		t.Fatalf("failed to decode trace result: %v", err)
+	}
+	if acc := prestate[sender]; acc.Balance != "0xf4240" || acc.Nonce != 0 {
+		t.Errorf("sender prestate mismatch: have balance %s, nonce %d, want 0xf4240, 0", acc.Balance, acc.Nonce)
+	}
+	if acc := prestate[caller]; acc.Balance != "0x0" {
+		t.Errorf("recipient prestate balance mismatch: have %s, want 0x0", acc.Balance)
+	}
+}
+
+// Tests that db.getState accepts storage keys as hashes, as it did originally,
+// besides the forms converted by toWord.
+func TestGetStateForms(t *testing.T) {
+	tracer, err := NewJavascriptTracer(`{
+		values: [],
+		step: function(log, db) {
+			if (log.op.toString() == 'SLOAD') {
+				this.values.push(toHex(db.getState(log.contract.address(), log.stack.peek(0))));
+				this.values.push(toHex(db.getState(toHex(toAddress(log.contract.address())), '0x01')));
+				this.values.push(db.getState(log.contract.address(), slot));
+			}
+		},
+		result: function() { return this.values; }
+	}`)
+	if err != nil {
+		t.Fatalf("failed to create tracer: %v", err)
+	}
+	tracer.vm.Set("slot", common.BytesToHash([]byte{0x01}))
+
+	word := common.BytesToHash([]byte{0x2a}).Hex()
+	if have, want := runCallTrace(t, tracer), `["`+word+`","`+word+`","`+word+`"]`; have != want {
+		t.Errorf("result mismatch: have %s, want %s", have, want)
+	}
+}
+
+// Tests that failed steps are passed to the fault function if one exists.
+func TestFault(t *testing.T) {
+	tracer, err := NewJavascriptTracer("{steps: 0, faults: [], step: function() { this.steps++; }, fault: function(log) { this.faults.push(log.op.toString()); }, result: function() { return [this.steps, this.faults]; }}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := vm.NewEVM(vm.Context{}, nil, params.TestChainConfig, vm.Config{Debug: true, Tracer: tracer})
+
+	contract := vm.NewContract(account{}, account{}, big.NewInt(0), 10000)
+	contract.Code = []byte{byte(vm.PUSH1), 0x1, byte(vm.ADD)}
+
+	if _, err := env.Interpreter().Run(0, contract, []byte{}); err == nil {
+		t.Fatalf("stack underflow not detected")
+	}
+	ret, err := tracer.GetResult()
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob, _ := json.Marshal(ret)
+	if want := `[1,["ADD"]]`; string(blob) != want {
+		t.Errorf("result mismatch: have %s, want %s", blob, want)
+	}
+}
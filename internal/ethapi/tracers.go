@@ -0,0 +1,158 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+// builtinTracers are the Javascript tracers that can be requested by name instead
+// of supplying their code.
+var builtinTracers = map[string]string{
+	"callTracer":     callTracer,
+	"prestateTracer": prestateTracer,
+	"4byteTracer":    fourByteTracer,
+}
+
+// callTracer assembles the tree of call frames of the execution, along with their
+// inputs, outputs, gas usage and errors.
+const callTracer = `{
+	// callstack is the stack of the currently executing call frames.
+	callstack: [],
+
+	// enter pushes a new call frame onto the stack.
+	enter: function(frame) {
+		var call = {
+			type:  frame.type,
+			from:  frame.from,
+			to:    frame.to,
+			input: frame.input,
+			gas:   '0x' + frame.gas.toString(16)
+		};
+		if (frame.value !== undefined) {
+			call.value = frame.value;
+		}
+		this.callstack.push(call);
+	},
+
+	// exit pops the finished call frame off the stack, attaching it to its parent.
+	exit: function(result) {
+		var call = this.callstack.pop();
+		call.gasUsed = '0x' + result.gasUsed.toString(16);
+		call.output = result.output;
+		if (result.error !== undefined) {
+			call.error = result.error;
+		}
+		if (this.callstack.length == 0) {
+			this.root = call;
+			return;
+		}
+		var parent = this.callstack[this.callstack.length - 1];
+		if (parent.calls === undefined) {
+			parent.calls = [];
+		}
+		parent.calls.push(call);
+	},
+
+	// step is unused, call frames are tracked through enter and exit.
+	step: function(log, db) {},
+
+	// result returns the outermost call frame.
+	result: function() {
+		return this.root;
+	}
+}`
+
+// prestateTracer collects the state of all the accounts and storage slots the
+// execution touches, as it was before the transaction.
+const prestateTracer = `{
+	// prestate is the state of the touched accounts, keyed by address.
+	prestate: {},
+
+	// lookupAccount records the state of an account if not yet known.
+	lookupAccount: function(addr, db) {
+		var key = toHex(toAddress(addr));
+		if (this.prestate[key] !== undefined) {
+			return;
+		}
+		var acc = toAddress(addr);
+		this.prestate[key] = {
+			balance: '0x' + db.getBalance(acc).Text(16),
+			nonce:   db.getNonce(acc),
+			code:    toHex(db.getCode(acc)),
+			storage: {}
+		};
+	},
+
+	// lookupStorage records the value of a storage slot if not yet known.
+	lookupStorage: function(addr, slot, db) {
+		this.lookupAccount(addr, db);
+
+		var storage = this.prestate[toHex(toAddress(addr))].storage;
+		var key = toHex(toWord(slot));
+		if (storage[key] === undefined) {
+			storage[key] = toHex(db.getState(toAddress(addr), toWord(slot)));
+		}
+	},
+
+	// enter records the accounts taking part in a call frame. Created contracts
+	// didn't exist before, so they are skipped.
+	enter: function(frame, db) {
+		this.lookupAccount(frame.from, db);
+		if (frame.type != 'CREATE') {
+			this.lookupAccount(frame.to, db);
+		}
+	},
+
+	// step records the accounts and storage slots accessed by an instruction.
+	step: function(log, db) {
+		switch (log.op.toString()) {
+		case 'SLOAD': case 'SSTORE':
+			this.lookupStorage(log.contract.address(), log.stack.peek(0), db);
+			break;
+		case 'BALANCE': case 'EXTCODESIZE': case 'EXTCODECOPY': case 'SELFDESTRUCT':
+			this.lookupAccount(log.stack.peek(0), db);
+			break;
+		}
+	},
+
+	// result returns the collected state.
+	result: function() {
+		return this.prestate;
+	}
+}`
+
+// fourByteTracer counts the 4 byte function selectors called during the execution,
+// along with the size of the passed arguments. Contract creations and calls to
+// precompiled contracts are skipped.
+const fourByteTracer = `{
+	// ids counts the calls by selector and argument size.
+	ids: {},
+
+	// enter counts the selector and argument size of a call frame.
+	enter: function(frame) {
+		if (frame.type == 'CREATE' || frame.input.length < 10 || /^0x0{38}0[1-8]$/.test(frame.to)) {
+			return;
+		}
+		var key = frame.input.slice(0, 10) + '-' + (frame.input.length - 10) / 2;
+		this.ids[key] = (this.ids[key] || 0) + 1;
+	},
+
+	// step is unused, calls are tracked through enter.
+	step: function(log, db) {},
+
+	// result returns the counted selectors.
+	result: function() {
+		return this.ids;
+	}
+}`
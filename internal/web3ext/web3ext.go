@@ -23,6 +23,7 @@ var Modules = map[string]string{
 	"clique":     Clique_JS,
 	"debug":      Debug_JS,
 	"eth":        Eth_JS,
+	"les":        LES_JS,
 	"miner":      Miner_JS,
 	"net":        Net_JS,
 	"personal":   Personal_JS,
@@ -181,6 +182,11 @@ const Debug_JS = `
 web3._extend({
 	property: 'debug',
 	methods: [
+		new web3._extend.Method({
+			name: 'compactDatabase',
+			call: 'debug_compactDatabase',
+			params: 0
+		}),
 		new web3._extend.Method({
 			name: 'printBlock',
 			call: 'debug_printBlock',
@@ -236,6 +242,10 @@ web3._extend({
 			name: 'chaindbCompact',
 			call: 'debug_chaindbCompact',
 		}),
+		new web3._extend.Method({
+			name: 'dbStats',
+			call: 'debug_dbStats',
+		}),
 		new web3._extend.Method({
 			name: 'metrics',
 			call: 'debug_metrics',
@@ -338,6 +348,12 @@ web3._extend({
 			params: 2,
 			inputFormatter: [null, null]
 		}),
+		new web3._extend.Method({
+			name: 'traceCall',
+			call: 'debug_traceCall',
+			params: 3,
+			inputFormatter: [null, web3._extend.formatters.inputDefaultBlockNumberFormatter, null]
+		}),
 		new web3._extend.Method({
 			name: 'preimage',
 			call: 'debug_preimage',
@@ -349,6 +365,17 @@ web3._extend({
 			call: 'debug_getBadBlocks',
 			params: 0,
 		}),
+		new web3._extend.Method({
+			name: 'vmStats',
+			call: 'debug_vmStats',
+			params: 1,
+			inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+			name: 'resetVMStats',
+			call: 'debug_resetVMStats',
+			params: 0,
+		}),
 		new web3._extend.Method({
 			name: 'storageRangeAt',
 			call: 'debug_storageRangeAt',
@@ -399,6 +426,23 @@ web3._extend({
 			params: 1,
 			inputFormatter: [web3._extend.formatters.inputTransactionFormatter]
 		}),
+		new web3._extend.Method({
+			name: 'simulateTransaction',
+			call: 'eth_simulateTransaction',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputCallFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'simulateRawTransaction',
+			call: 'eth_simulateRawTransaction',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'createAccessList',
+			call: 'eth_createAccessList',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputCallFormatter, web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		}),
 		new web3._extend.Method({
 			name: 'getRawTransaction',
 			call: 'eth_getRawTransactionByHash',
@@ -414,6 +458,10 @@ web3._extend({
 		}),
 	],
 	properties: [
+		new web3._extend.Property({
+			name: 'bloomStatus',
+			getter: 'eth_bloomStatus'
+		}),
 		new web3._extend.Property({
 			name: 'pendingTransactions',
 			getter: 'eth_pendingTransactions',
@@ -430,6 +478,24 @@ web3._extend({
 });
 `
 
+const LES_JS = `
+web3._extend({
+	property: 'les',
+	methods: [
+		new web3._extend.Method({
+			name: 'deposit',
+			call: 'les_deposit',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'balance',
+			call: 'les_balance',
+			params: 1
+		}),
+	]
+});
+`
+
 const Miner_JS = `
 web3._extend({
 	property: 'miner',
@@ -465,6 +531,35 @@ web3._extend({
 			name: 'getHashrate',
 			call: 'miner_getHashrate'
 		}),
+		new web3._extend.Method({
+			name: 'remoteMiners',
+			call: 'miner_remoteMiners'
+		}),
+		new web3._extend.Method({
+			name: 'getPolicy',
+			call: 'miner_getPolicy'
+		}),
+		new web3._extend.Method({
+			name: 'setIncludeUncles',
+			call: 'miner_setIncludeUncles',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setUncleDepth',
+			call: 'miner_setUncleDepth',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setTxOrdering',
+			call: 'miner_setTxOrdering',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setMinGasPrice',
+			call: 'miner_setMinGasPrice',
+			params: 1,
+			inputFormatter: [web3._extend.utils.fromDecimal]
+		}),
 	],
 	properties: []
 });
@@ -492,6 +587,35 @@ web3._extend({
 			call: 'personal_importRawKey',
 			params: 2
 		}),
+		new web3._extend.Method({
+			name: 'importMnemonic',
+			call: 'personal_importMnemonic',
+			params: 3,
+			inputFormatter: [null, null, null]
+		}),
+		new web3._extend.Method({
+			name: 'importMnemonicKey',
+			call: 'personal_importMnemonicKey',
+			params: 4,
+			inputFormatter: [null, null, null, null]
+		}),
+		new web3._extend.Method({
+			name: 'exportDerivation',
+			call: 'personal_exportDerivation',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'auditLog',
+			call: 'personal_auditLog',
+			params: 1,
+			inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+			name: 'approveSigning',
+			call: 'personal_approveSigning',
+			params: 2
+		}),
 		new web3._extend.Method({
 			name: 'sign',
 			call: 'personal_sign',
@@ -519,6 +643,10 @@ web3._extend({
 			name: 'listWallets',
 			getter: 'personal_listWallets'
 		}),
+		new web3._extend.Property({
+			name: 'pendingApprovals',
+			getter: 'personal_pendingApprovals'
+		}),
 	]
 })
 `
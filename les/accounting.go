@@ -0,0 +1,267 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// clientAccountPrefix is the database key prefix the accounts of the LES clients
+// are stored at, followed by the node ID of the client.
+var clientAccountPrefix = []byte("les-account-")
+
+// clientDepositPrefix is the database key prefix the already credited deposits
+// are marked at, followed by the hash of the deposit transaction.
+var clientDepositPrefix = []byte("les-deposit-")
+
+// depositConfirmations is the number of blocks a deposit must be buried under
+// before it's credited, so it's not reverted by a reorg.
+const depositConfirmations = 12
+
+var (
+	errNoPayments         = errors.New("light client payments disabled")
+	errUnknownDeposit     = errors.New("unknown deposit transaction")
+	errUnconfirmedDeposit = errors.New("deposit not confirmed yet")
+	errDepositPayee       = errors.New("deposit not paid to the server")
+	errDepositClient      = errors.New("deposit not made for the client")
+	errDepositFailed      = errors.New("deposit transaction failed")
+	errDepositClaimed     = errors.New("deposit already credited")
+)
+
+// clientAccount is the balance sheet of a single LES client. Costs are measured
+// in the same units as the flow control request costs.
+type clientAccount struct {
+	Spent  uint64 // Total cost of the requests served to the client
+	Credit uint64 // Total payments deposited by the client
+}
+
+// clientAccounts tracks the cost of the requests served to each LES client and
+// refuses serving clients which consumed their free quota along with all their
+// deposited credit.
+//
+// Clients pay by depositing ether on-chain to the payee of the server, with their
+// node ID as transaction data. Deposits are only credited once verified against
+// the local chain.
+type clientAccounts struct {
+	db        ethdb.Database
+	freeQuota uint64                             // Cost served to every client free of charge
+	payee     common.Address                     // Address the deposits are paid to (zero = no payments)
+	price     *big.Int                           // Wei to deposit per unit of request cost
+	accounts  map[discover.NodeID]*clientAccount // Accounts of the clients accessed since startup
+	dirty     map[discover.NodeID]struct{}       // Accounts modified since last persisted
+
+	lock sync.Mutex
+}
+
+// newClientAccounts creates the request cost accounting of a LES server, serving
+// every client up to the given free quota and crediting the deposits paid to the
+// payee at the given price (1 wei per cost unit if nil). A zero quota disables
+// accounting, in which case nil is returned.
+func newClientAccounts(db ethdb.Database, freeQuota uint64, payee common.Address, price *big.Int) *clientAccounts {
+	if freeQuota == 0 {
+		return nil
+	}
+	if price == nil || price.Sign() <= 0 {
+		price = big.NewInt(1)
+	}
+	return &clientAccounts{
+		db:        db,
+		freeQuota: freeQuota,
+		payee:     payee,
+		price:     price,
+		accounts:  make(map[discover.NodeID]*clientAccount),
+		dirty:     make(map[discover.NodeID]struct{}),
+	}
+}
+
+// account retrieves the account of a client, loading it from the database if
+// it's not yet cached. The lock must be held.
+func (a *clientAccounts) account(id discover.NodeID) *clientAccount {
+	if acc, ok := a.accounts[id]; ok {
+		return acc
+	}
+	acc := new(clientAccount)
+	if blob, err := a.db.Get(append(clientAccountPrefix, id[:]...)); err == nil {
+		if err := rlp.DecodeBytes(blob, acc); err != nil {
+			log.Warn("Invalid light client account", "id", id, "err", err)
+			acc = new(clientAccount)
+		}
+	}
+	a.accounts[id] = acc
+	return acc
+}
+
+// saturatingAdd adds two costs, capping the sum at the maximum representable
+// value instead of wrapping around.
+func saturatingAdd(x, y uint64) uint64 {
+	if sum, overflow := math.SafeAdd(x, y); !overflow {
+		return sum
+	}
+	return math.MaxUint64
+}
+
+// limit returns the total cost a client may be served, the lock must be held.
+func (a *clientAccounts) limit(acc *clientAccount) uint64 {
+	return saturatingAdd(a.freeQuota, acc.Credit)
+}
+
+// allow reports whether a client can afford a request of the given cost, without
+// billing it yet. A nil accounting allows everything.
+func (a *clientAccounts) allow(id discover.NodeID, cost uint64) bool {
+	if a == nil {
+		return true
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	acc := a.account(id)
+	limit := a.limit(acc)
+	return acc.Spent <= limit && cost <= limit-acc.Spent
+}
+
+// charge bills the cost of a served request to a client. A nil accounting bills
+// nothing.
+func (a *clientAccounts) charge(id discover.NodeID, cost uint64) {
+	if a == nil {
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	acc := a.account(id)
+	acc.Spent = saturatingAdd(acc.Spent, cost)
+	a.dirty[id] = struct{}{}
+}
+
+// deposit credits a payment of a client.
+func (a *clientAccounts) deposit(id discover.NodeID, amount uint64) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	acc := a.account(id)
+	acc.Credit = saturatingAdd(acc.Credit, amount)
+	a.dirty[id] = struct{}{}
+}
+
+// claim verifies an on-chain deposit and credits it to the account of a client,
+// returning the credited amount. The deposit must be a successful, confirmed
+// canonical transaction paying the payee with the node ID of the client as data,
+// not credited before.
+func (a *clientAccounts) claim(id discover.NodeID, hash common.Hash) (uint64, error) {
+	if a.payee == (common.Address{}) {
+		return 0, errNoPayments
+	}
+	tx, block, number, _ := core.GetTransaction(a.db, hash)
+	if tx == nil || core.GetCanonicalHash(a.db, number) != block {
+		return 0, errUnknownDeposit
+	}
+	if head := core.GetBlockNumber(a.db, core.GetHeadBlockHash(a.db)); head < number+depositConfirmations {
+		return 0, errUnconfirmedDeposit
+	}
+	if tx.To() == nil || *tx.To() != a.payee {
+		return 0, errDepositPayee
+	}
+	if !bytes.Equal(tx.Data(), id[:]) {
+		return 0, errDepositClient
+	}
+	if receipt, _, _, _ := core.GetReceipt(a.db, hash); receipt == nil || receipt.Status != types.ReceiptStatusSuccessful {
+		return 0, errDepositFailed
+	}
+	amount := uint64(math.MaxUint64)
+	if credit := new(big.Int).Div(tx.Value(), a.price); credit.BitLen() <= 64 {
+		amount = credit.Uint64()
+	}
+	// Credit the deposit and mark it claimed atomically
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	key := append(clientDepositPrefix, hash[:]...)
+	if claimed, _ := a.db.Has(key); claimed {
+		return 0, errDepositClaimed
+	}
+	acc := a.account(id)
+	credit := acc.Credit
+	acc.Credit = saturatingAdd(credit, amount)
+
+	blob, err := rlp.EncodeToBytes(acc)
+	if err != nil {
+		acc.Credit = credit
+		return 0, err
+	}
+	batch := a.db.NewBatch()
+	batch.Put(key, id[:])
+	batch.Put(append(clientAccountPrefix, id[:]...), blob)
+	if err := batch.Write(); err != nil {
+		acc.Credit = credit
+		return 0, err
+	}
+	delete(a.dirty, id)
+	return amount, nil
+}
+
+// balance returns the cost a client may still consume, along with the cost
+// already served to it.
+func (a *clientAccounts) balance(id discover.NodeID) (remaining, spent uint64) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	acc := a.account(id)
+	if limit := a.limit(acc); limit > acc.Spent {
+		remaining = limit - acc.Spent
+	}
+	return remaining, acc.Spent
+}
+
+// store persists the modified accounts and drops the one of the given client
+// from the memory cache if requested, as it's disconnecting.
+func (a *clientAccounts) store(drop *discover.NodeID) {
+	if a == nil {
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for id := range a.dirty {
+		blob, err := rlp.EncodeToBytes(a.accounts[id])
+		if err != nil {
+			log.Error("Failed to encode light client account", "id", id, "err", err)
+			continue
+		}
+		if err := a.db.Put(append(clientAccountPrefix, id[:]...), blob); err != nil {
+			log.Error("Failed to store light client account", "id", id, "err", err)
+			continue
+		}
+		delete(a.dirty, id)
+	}
+	if drop != nil {
+		if _, ok := a.dirty[*drop]; !ok {
+			delete(a.accounts, *drop)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+)
+
+// Tests that clients are served up to their free quota, beyond it only with
+// deposited credit, and that their accounts survive restarts.
+func TestClientAccounting(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	accounts := newClientAccounts(db, 100, common.Address{}, nil)
+
+	id := discover.NodeID{0x01}
+	if !accounts.allow(id, 60) {
+		t.Fatalf("request within free quota refused")
+	}
+	accounts.charge(id, 60)
+	if accounts.allow(id, 60) {
+		t.Fatalf("request beyond free quota accepted")
+	}
+	if remaining, spent := accounts.balance(id); remaining != 40 || spent != 60 {
+		t.Fatalf("balance mismatch: have %d/%d, want %d/%d", remaining, spent, 40, 60)
+	}
+	// Deposit some credit and ensure the client is served again
+	accounts.deposit(id, 50)
+	if !accounts.allow(id, 60) {
+		t.Fatalf("request within credit refused")
+	}
+	accounts.charge(id, 60)
+	// Disconnect the client, restart and ensure the account is retained
+	accounts.store(&id)
+
+	accounts = newClientAccounts(db, 100, common.Address{}, nil)
+	if remaining, spent := accounts.balance(id); remaining != 30 || spent != 120 {
+		t.Fatalf("balance mismatch after restart: have %d/%d, want %d/%d", remaining, spent, 30, 120)
+	}
+	if accounts.allow(id, 31) {
+		t.Fatalf("request beyond credit accepted after restart")
+	}
+	// Ensure disabled accounting accepts everything
+	if accounts = newClientAccounts(db, 0, common.Address{}, nil); !accounts.allow(id, 1000) {
+		t.Fatalf("request refused with accounting disabled")
+	}
+}
+
+// Tests that huge deposits and costs saturate instead of wrapping around.
+func TestClientAccountingOverflow(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	accounts := newClientAccounts(db, 100, common.Address{}, nil)
+
+	id := discover.NodeID{0x01}
+	accounts.deposit(id, math.MaxUint64)
+	accounts.deposit(id, math.MaxUint64)
+	if remaining, _ := accounts.balance(id); remaining != math.MaxUint64 {
+		t.Fatalf("remaining balance mismatch: have %d, want %d", remaining, uint64(math.MaxUint64))
+	}
+	accounts.charge(id, 10)
+	accounts.charge(id, math.MaxUint64)
+	if remaining, spent := accounts.balance(id); remaining != 0 || spent != math.MaxUint64 {
+		t.Fatalf("balance mismatch: have %d/%d, want %d/%d", remaining, spent, 0, uint64(math.MaxUint64))
+	}
+	if accounts.allow(id, 1) {
+		t.Fatalf("request beyond exhausted credit accepted")
+	}
+	// Ensure a huge request doesn't wrap around the spent cost of a fresh client
+	if fresh := (discover.NodeID{0x02}); accounts.allow(fresh, math.MaxUint64) {
+		t.Fatalf("overflowing request accepted")
+	}
+}
+
+// Tests that on-chain deposits are only credited once verified against the chain.
+func TestClientDeposits(t *testing.T) {
+	var (
+		id    = discover.NodeID{0x01}
+		payee = common.Address{0xff}
+		txs   = make(map[string]common.Hash)
+	)
+	deposit := func(name string, block *core.BlockGen, to common.Address, data []byte) {
+		tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testBankAddress), to, big.NewInt(1000), big.NewInt(50000), nil, data), types.HomesteadSigner{}, testBankKey)
+		block.AddTx(tx)
+		txs[name] = tx.Hash()
+	}
+	generator := func(i int, block *core.BlockGen) {
+		switch i {
+		case 0:
+			deposit("valid", block, payee, id[:])
+			deposit("payee", block, common.Address{0xee}, id[:])
+			deposit("client", block, payee, []byte{0x02})
+		case depositConfirmations:
+			deposit("unconfirmed", block, payee, id[:])
+		}
+	}
+	db, _ := ethdb.NewMemDatabase()
+	newTestProtocolManagerMust(t, false, depositConfirmations+1, generator, nil, nil, db)
+
+	// Ensure deposits are refused unless a payee is configured
+	if _, err := newClientAccounts(db, 100, common.Address{}, nil).claim(id, txs["valid"]); err != errNoPayments {
+		t.Errorf("deposit without payee: error mismatch: have %v, want %v", err, errNoPayments)
+	}
+	accounts := newClientAccounts(db, 100, payee, big.NewInt(10))
+	for name, want := range map[string]error{
+		"payee":       errDepositPayee,
+		"client":      errDepositClient,
+		"unconfirmed": errUnconfirmedDeposit,
+		"unknown":     errUnknownDeposit,
+	} {
+		if _, err := accounts.claim(id, txs[name]); err != want {
+			t.Errorf("%s deposit: error mismatch: have %v, want %v", name, err, want)
+		}
+	}
+	// Credit a valid deposit at the configured price, but only once
+	if amount, err := accounts.claim(id, txs["valid"]); err != nil || amount != 100 {
+		t.Fatalf("valid deposit: have %d, %v, want %d, nil", amount, err, 100)
+	}
+	if _, err := accounts.claim(id, txs["valid"]); err != errDepositClaimed {
+		t.Errorf("replayed deposit: error mismatch: have %v, want %v", err, errDepositClaimed)
+	}
+	// Ensure the credit is persisted right away
+	accounts = newClientAccounts(db, 100, payee, big.NewInt(10))
+	if remaining, _ := accounts.balance(id); remaining != 200 {
+		t.Errorf("remaining balance mismatch: have %d, want %d", remaining, 200)
+	}
+	if _, err := accounts.claim(id, txs["valid"]); err != errDepositClaimed {
+		t.Errorf("replayed deposit after restart: error mismatch: have %v, want %v", err, errDepositClaimed)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+)
+
+// errNoAccounting is returned by the client account API if the server doesn't
+// account the requests of its clients.
+var errNoAccounting = errors.New("light client accounting disabled")
+
+// PrivateLightServerAPI provides an API to manage the accounts of the clients of
+// a light server.
+type PrivateLightServerAPI struct {
+	server *LesServer
+}
+
+// NewPrivateLightServerAPI creates a new light server account management API.
+func NewPrivateLightServerAPI(server *LesServer) *PrivateLightServerAPI {
+	return &PrivateLightServerAPI{server: server}
+}
+
+// ClientBalance is the accounting state of a single light client.
+type ClientBalance struct {
+	Remaining hexutil.Uint64 `json:"remaining"` // Request cost the client may still consume
+	Spent     hexutil.Uint64 `json:"spent"`     // Request cost already served to the client
+}
+
+// Deposit verifies an on-chain deposit of a light client and credits it to its
+// account, allowing it to be served beyond the free quota. The deposit must pay
+// the payee of the server with the node ID of the client as transaction data.
+func (api *PrivateLightServerAPI) Deposit(id string, tx common.Hash) (*ClientBalance, error) {
+	node, err := api.client(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := api.server.accounts.claim(node, tx); err != nil {
+		return nil, err
+	}
+	return api.Balance(id)
+}
+
+// Balance returns the accounting state of a light client.
+func (api *PrivateLightServerAPI) Balance(id string) (*ClientBalance, error) {
+	node, err := api.client(id)
+	if err != nil {
+		return nil, err
+	}
+	remaining, spent := api.server.accounts.balance(node)
+	return &ClientBalance{Remaining: hexutil.Uint64(remaining), Spent: hexutil.Uint64(spent)}, nil
+}
+
+// client parses the node ID of a light client, ensuring accounting is enabled.
+func (api *PrivateLightServerAPI) client(id string) (discover.NodeID, error) {
+	if api.server.accounts == nil {
+		return discover.NodeID{}, errNoAccounting
+	}
+	return discover.HexID(id)
+}
@@ -42,6 +42,8 @@ type LesApiBackend struct {
 	eth           *LightEthereum
 	gpo           *gasprice.Oracle
 	statusBackend *ethapi.StatusBackend
+	safeDepth     uint64
+	callPool      *ethapi.CallPool
 }
 
 func (b *LesApiBackend) GetStatusBackend() *ethapi.StatusBackend {
@@ -65,6 +67,12 @@ func (b *LesApiBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNum
 	if blockNr == rpc.LatestBlockNumber || blockNr == rpc.PendingBlockNumber {
 		return b.eth.blockchain.CurrentHeader(), nil
 	}
+	if blockNr == rpc.SafeBlockNumber {
+		blockNr = 0
+		if head := b.eth.blockchain.CurrentHeader().Number.Uint64(); head > b.safeDepth {
+			blockNr = rpc.BlockNumber(head - b.safeDepth)
+		}
+	}
 
 	return b.eth.blockchain.GetHeaderByNumberOdr(ctx, uint64(blockNr))
 }
@@ -103,6 +111,10 @@ func (b *LesApiBackend) GetEVM(ctx context.Context, msg core.Message, state *sta
 	return vm.NewEVM(context, state, b.eth.chainConfig, vmCfg), state.Error, nil
 }
 
+func (b *LesApiBackend) CallPool() *ethapi.CallPool {
+	return b.callPool
+}
+
 func (b *LesApiBackend) SendTx(ctx context.Context, signedTx *types.Transaction) error {
 	return b.eth.txPool.Add(ctx, signedTx)
 }
@@ -155,6 +167,10 @@ func (b *LesApiBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEven
 	return b.eth.blockchain.SubscribeRemovedLogsEvent(ch)
 }
 
+func (b *LesApiBackend) SubscribeReorgEvent(ch chan<- core.ReorgEvent) event.Subscription {
+	return b.eth.blockchain.SubscribeReorgEvent(ch)
+}
+
 func (b *LesApiBackend) Downloader() *downloader.Downloader {
 	return b.eth.Downloader()
 }
@@ -84,7 +84,10 @@ func New(ctx *node.ServiceContext, config *eth.Config) (*LightEthereum, error) {
 	if err != nil {
 		return nil, err
 	}
-	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlock(chainDb, config.Genesis)
+	if err := core.CheckNetworkId(chainDb, config.Genesis, config.NetworkId); err != nil {
+		return nil, err
+	}
+	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlockWithOverride(chainDb, config.Genesis, config.OverrideByzantium)
 	if _, isCompat := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !isCompat {
 		return nil, genesisErr
 	}
@@ -113,7 +116,10 @@ func New(ctx *node.ServiceContext, config *eth.Config) (*LightEthereum, error) {
 	leth.serverPool = newServerPool(chainDb, quitSync, &leth.wg)
 	leth.retriever = newRetrieveManager(peers, leth.reqDist, leth.serverPool)
 	leth.odr = NewLesOdr(chainDb, leth.chtIndexer, leth.bloomTrieIndexer, leth.bloomIndexer, leth.retriever)
-	if leth.blockchain, err = light.NewLightChain(leth.odr, leth.chainConfig, leth.engine); err != nil {
+	if config.LightCache > 0 {
+		leth.odr.cache = light.NewOdrCache(chainDb, uint64(config.LightCache)*1024*1024)
+	}
+	if leth.blockchain, err = light.NewLightChain(leth.odr, leth.chainConfig, leth.engine, config.Checkpoint); err != nil {
 		return nil, err
 	}
 	leth.bloomIndexer.Start(leth.blockchain)
@@ -128,7 +134,7 @@ func New(ctx *node.ServiceContext, config *eth.Config) (*LightEthereum, error) {
 	if leth.protocolManager, err = NewProtocolManager(leth.chainConfig, true, ClientProtocolVersions, config.NetworkId, leth.eventMux, leth.engine, leth.peers, leth.blockchain, nil, chainDb, leth.odr, leth.relay, quitSync, &leth.wg); err != nil {
 		return nil, err
 	}
-	leth.ApiBackend = &LesApiBackend{leth, nil, nil}
+	leth.ApiBackend = &LesApiBackend{leth, nil, nil, config.SafeDepth, ethapi.NewCallPool(config.RPCCallWorkers)}
 	gpoParams := config.GPO
 	if gpoParams.Default == nil {
 		gpoParams.Default = config.GasPrice
@@ -155,6 +161,18 @@ func lesTopic(genesisHash common.Hash, protocolVersion uint) discv5.Topic {
 	return discv5.Topic(name + "@" + common.Bytes2Hex(genesisHash.Bytes()[0:8]))
 }
 
+// lesCapacityTopic is the discovery topic advertised in addition to the regular one
+// by servers dedicating at least highCapacityServ percent of time to LES clients.
+//
+// Discovery topics are static labels, so only the capacity class is advertised
+// through them. The server head and the request types it serves change over time
+// and are announced in the LES handshake instead (headNum/headHash, serveHeaders,
+// serveChainSince, serveStateSince and txRelay), where the client rejects servers
+// unable to serve it.
+func lesCapacityTopic(genesisHash common.Hash, protocolVersion uint) discv5.Topic {
+	return lesTopic(genesisHash, protocolVersion) + "/HC"
+}
+
 type LightDummyAPI struct{}
 
 // Etherbase is the address that mining rewards will be send to
@@ -222,6 +240,13 @@ func (s *LightEthereum) Protocols() []p2p.Protocol {
 	return s.protocolManager.SubProtocols
 }
 
+// Health implements node.HealthReporter. The light client is ready while it is
+// not syncing and there are servers to retrieve data from.
+func (s *LightEthereum) Health() (interface{}, bool) {
+	status := eth.NewHealthStatus(s.protocolManager.downloader, s.blockchain.CurrentHeader(), s.peers.Len())
+	return status, !status.Syncing && status.Peers > 0
+}
+
 // Start implements node.Service, starting all internal goroutines needed by the
 // Ethereum protocol implementation.
 func (s *LightEthereum) Start(srvr *p2p.Server) error {
@@ -231,7 +256,7 @@ func (s *LightEthereum) Start(srvr *p2p.Server) error {
 	// search the topic belonging to the oldest supported protocol because
 	// servers always advertise all supported protocols
 	protocolVersion := ClientProtocolVersions[len(ClientProtocolVersions)-1]
-	s.serverPool.start(srvr, lesTopic(s.blockchain.Genesis().Hash(), protocolVersion))
+	s.serverPool.start(srvr, lesTopic(s.blockchain.Genesis().Hash(), protocolVersion), lesCapacityTopic(s.blockchain.Genesis().Hash(), protocolVersion))
 	s.protocolManager.Start()
 	return nil
 }
@@ -240,6 +265,9 @@ func (s *LightEthereum) Start(srvr *p2p.Server) error {
 // Ethereum protocol.
 func (s *LightEthereum) Stop() error {
 	s.odr.Stop()
+	if s.odr.cache != nil {
+		s.odr.cache.Close()
+	}
 	if s.bloomIndexer != nil {
 		s.bloomIndexer.Close()
 	}
@@ -18,8 +18,11 @@ package les
 
 import (
 	"bytes"
+	"fmt"
 	"math/big"
 	"math/rand"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -29,8 +32,10 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/les/flowcontrol"
 	"github.com/ethereum/go-ethereum/light"
 	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/trie"
@@ -499,3 +504,82 @@ func TestTransactionStatusLes2(t *testing.T) {
 	test(tx1, false, txStatus{Status: core.TxStatusPending})
 	test(tx2, false, txStatus{Status: core.TxStatusPending})
 }
+
+// Tests that regular clients beyond the allowance are refused, while priority
+// clients are admitted and served with larger flow control buffers.
+func TestClientPriority(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	pm := newTestProtocolManagerMust(t, false, 0, nil, nil, nil, db)
+	pm.server.maxClients = 1
+	pm.server.priority = make(map[discover.NodeID]bool)
+	pm.server.priParams = &flowcontrol.ServerParams{
+		BufLimit:    pm.server.defParams.BufLimit * priorityFactor,
+		MinRecharge: pm.server.defParams.MinRecharge * priorityFactor,
+	}
+	peer, _ := newTestPeer(t, "peer", 2, pm, true)
+	defer peer.close()
+
+	// A second regular client should be refused
+	extra, errc := newTestPeer(t, "extra", 2, pm, true)
+	defer extra.close()
+
+	select {
+	case err := <-errc:
+		if err != p2p.DiscTooManyPeers {
+			t.Errorf("regular client error mismatch: have %v, want %v", err, p2p.DiscTooManyPeers)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("regular client beyond the allowance not refused")
+	}
+	// Priority clients should be served with their own parameters
+	var id discover.NodeID
+	id[0] = 0x01
+	pm.server.priority[id] = true
+
+	if params := pm.server.clientParams(id); params != pm.server.priParams {
+		t.Errorf("priority client parameters mismatch: have %+v, want %+v", params, pm.server.priParams)
+	}
+	if params := pm.server.clientParams(peer.ID()); params != pm.server.defParams {
+		t.Errorf("regular client parameters mismatch: have %+v, want %+v", params, pm.server.defParams)
+	}
+}
+
+// Tests that the client limit only counts regular clients, and that concurrent
+// registrations can't overshoot it.
+func TestClientLimit(t *testing.T) {
+	ps := newPeerSet()
+
+	// Race a batch of regular clients for the limited slots
+	const limit = 4
+	var (
+		wg       sync.WaitGroup
+		admitted int32
+	)
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := ps.Register(&peer{id: fmt.Sprintf("regular-%d", i)}, limit); err == nil {
+				atomic.AddInt32(&admitted, 1)
+			} else if err != p2p.DiscTooManyPeers {
+				t.Errorf("regular client %d: registration error mismatch: have %v, want %v", i, err, p2p.DiscTooManyPeers)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if admitted != limit {
+		t.Fatalf("admitted regular clients mismatch: have %d, want %d", admitted, limit)
+	}
+	// Priority clients should be admitted regardless, without taking regular slots
+	for i := 0; i < limit; i++ {
+		if err := ps.Register(&peer{id: fmt.Sprintf("priority-%d", i), priority: true}, limit); err != nil {
+			t.Fatalf("priority client %d refused: %v", i, err)
+		}
+	}
+	if ps.regular != limit {
+		t.Errorf("regular client count mismatch: have %d, want %d", ps.regular, limit)
+	}
+	if err := ps.Register(&peer{id: "regular-extra"}, limit); err != p2p.DiscTooManyPeers {
+		t.Errorf("extra regular client error mismatch: have %v, want %v", err, p2p.DiscTooManyPeers)
+	}
+}
@@ -30,6 +30,7 @@ type LesOdr struct {
 	db                                         ethdb.Database
 	chtIndexer, bloomTrieIndexer, bloomIndexer *core.ChainIndexer
 	retriever                                  *retrieveManager
+	cache                                      *light.OdrCache // Tracker of the retrieved data stored on disk (nil = unbounded)
 	stop                                       chan struct{}
 }
 
@@ -49,6 +50,11 @@ func (odr *LesOdr) Stop() {
 	close(odr.stop)
 }
 
+// Cache returns the tracker of the retrieved data stored on disk.
+func (odr *LesOdr) Cache() *light.OdrCache {
+	return odr.cache
+}
+
 // Database returns the backing database
 func (odr *LesOdr) Database() ethdb.Database {
 	return odr.db
@@ -111,6 +117,9 @@ func (odr *LesOdr) Retrieve(ctx context.Context, req light.OdrRequest) (err erro
 	if err = odr.retriever.retrieve(ctx, reqID, rq, func(p distPeer, msg *Msg) error { return lreq.Validate(odr.db, msg) }, odr.stop); err == nil {
 		// retrieved from network, store in db
 		req.StoreResult(odr.db)
+		if odr.cache != nil {
+			odr.cache.Add(req)
+		}
 	} else {
 		log.Debug("Failed to retrieve data from network", "err", err)
 	}
@@ -72,10 +72,14 @@ type peer struct {
 	hasBlock       func(common.Hash, uint64) bool
 	responseErrors int
 
-	fcClient       *flowcontrol.ClientNode // nil if the peer is server only
-	fcServer       *flowcontrol.ServerNode // nil if the peer is client only
+	fcClient       *flowcontrol.ClientNode   // nil if the peer is server only
+	fcParams       *flowcontrol.ServerParams // flow control parameters the client is served with
+	fcServer       *flowcontrol.ServerNode   // nil if the peer is client only
 	fcServerParams *flowcontrol.ServerParams
 	fcCosts        requestCostTable
+	priority       bool // Whether the client is served with priority (never refused)
+
+	serveCapacity uint64 // Serving capacity per client advertised by the server (0 = unknown)
 }
 
 func newPeer(version int, network uint64, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
@@ -410,8 +414,11 @@ func (p *peer) Handshake(td *big.Int, head common.Hash, headNum uint64, genesis
 		send = send.add("serveChainSince", uint64(0))
 		send = send.add("serveStateSince", uint64(0))
 		send = send.add("txRelay", nil)
-		send = send.add("flowControl/BL", server.defParams.BufLimit)
-		send = send.add("flowControl/MRR", server.defParams.MinRecharge)
+		send = send.add("serveCapacity", server.capacity)
+		p.priority = server.isPriority(p.ID())
+		p.fcParams = server.clientParams(p.ID())
+		send = send.add("flowControl/BL", p.fcParams.BufLimit)
+		send = send.add("flowControl/MRR", p.fcParams.MinRecharge)
 		list := server.fcCostStats.getCurrentList()
 		send = send.add("flowControl/MRC", list)
 		p.fcCosts = list.decode()
@@ -465,7 +472,7 @@ func (p *peer) Handshake(td *big.Int, head common.Hash, headNum uint64, genesis
 		if recv.get("announceType", &p.announceType) != nil {
 			p.announceType = announceTypeSimple
 		}
-		p.fcClient = flowcontrol.NewClientNode(server.fcManager, server.defParams)
+		p.fcClient = flowcontrol.NewClientNode(server.fcManager, p.fcParams)
 	} else {
 		if recv.get("serveChainSince", nil) != nil {
 			return errResp(ErrUselessPeer, "peer cannot serve chain")
@@ -490,6 +497,11 @@ func (p *peer) Handshake(td *big.Int, head common.Hash, headNum uint64, genesis
 		p.fcServerParams = params
 		p.fcServer = flowcontrol.NewServerNode(params)
 		p.fcCosts = MRC.decode()
+
+		// Older servers don't advertise their capacity, leave it unknown then
+		if recv.get("serveCapacity", &p.serveCapacity) != nil {
+			p.serveCapacity = 0
+		}
 	}
 
 	p.headInfo = &announceData{Td: rTd, Hash: rHash, Number: rNum}
@@ -516,6 +528,7 @@ type peerSet struct {
 	peers      map[string]*peer
 	lock       sync.RWMutex
 	notifyList []peerSetNotify
+	regular    int // Number of registered peers not served with priority
 	closed     bool
 }
 
@@ -542,15 +555,26 @@ func (ps *peerSet) notify(n peerSetNotify) {
 }
 
 // Register injects a new peer into the working set, or returns an error if the
-// peer is already known.
-func (ps *peerSet) Register(p *peer) error {
+// peer is already known. Peers not served with priority are refused if maxRegular
+// of them are already registered (0 = unlimited), the check being done atomically
+// with the registration so concurrent handshakes can't overshoot the limit.
+func (ps *peerSet) Register(p *peer, maxRegular int) error {
 	ps.lock.Lock()
 	if ps.closed {
+		ps.lock.Unlock()
 		return errClosed
 	}
 	if _, ok := ps.peers[p.id]; ok {
+		ps.lock.Unlock()
 		return errAlreadyRegistered
 	}
+	if !p.priority {
+		if maxRegular > 0 && ps.regular >= maxRegular {
+			ps.lock.Unlock()
+			return p2p.DiscTooManyPeers
+		}
+		ps.regular++
+	}
 	ps.peers[p.id] = p
 	p.sendQueue = newExecQueue(100)
 	peers := make([]peerSetNotify, len(ps.notifyList))
@@ -572,6 +596,9 @@ func (ps *peerSet) Unregister(id string) error {
 		return errNotRegistered
 	} else {
 		delete(ps.peers, id)
+		if !p.priority {
+			ps.regular--
+		}
 		peers := make([]peerSetNotify, len(ps.notifyList))
 		copy(peers, ps.notifyList)
 		ps.lock.Unlock()
@@ -123,7 +123,7 @@ func testAccess(t *testing.T, protocol int, fn accessTestFn) {
 	// expect retrievals to fail (except genesis block) without a les peer
 	test(0)
 
-	peers.Register(lpeer)
+	peers.Register(lpeer, 0)
 	time.Sleep(time.Millisecond * 10) // ensure that all peerSetNotify callbacks are executed
 	lpeer.lock.Lock()
 	lpeer.hasBlock = func(common.Hash, uint64) bool { return true }
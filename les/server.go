@@ -33,15 +33,41 @@ import (
 	"github.com/ethereum/go-ethereum/light"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/p2p/discv5"
 	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
+// highCapacityServ is the minimum percentage of time a server has to dedicate to
+// serving LES clients to advertise itself as a high capacity one.
+const highCapacityServ = 100
+
+// serveCapacity returns the capacity a server advertises to its clients: the
+// thousandths of its time dedicated to serving LES requests (LightServ) that are
+// available to each client slot. Servers accepting unlimited clients are rated as
+// if they were serving the default number of them.
+func serveCapacity(lightServ, lightPeers int) uint64 {
+	if lightPeers <= 0 {
+		lightPeers = eth.DefaultConfig.LightPeers
+	}
+	return uint64(lightServ) * 1000 / uint64(lightPeers)
+}
+
+// priorityFactor is the multiplier of the flow control buffer limit and recharge
+// rate of priority clients compared to regular ones.
+const priorityFactor = 10
+
 type LesServer struct {
 	protocolManager *ProtocolManager
 	fcManager       *flowcontrol.ClientManager // nil if our node is client only
 	fcCostStats     *requestCostStats
 	defParams       *flowcontrol.ServerParams
+	priParams       *flowcontrol.ServerParams // Flow control parameters of priority clients
+	priority        map[discover.NodeID]bool  // Clients served with priority
+	maxClients      int                       // Maximum number of non-priority clients (0 = unlimited)
+	capacity        uint64                    // Serving capacity per client advertised in the handshake
+	accounts        *clientAccounts           // Request cost accounting of the clients (nil = disabled)
 	lesTopics       []discv5.Topic
 	privateKey      *ecdsa.PrivateKey
 	quitSync        chan struct{}
@@ -60,11 +86,28 @@ func NewLesServer(eth *eth.Ethereum, config *eth.Config) (*LesServer, error) {
 	for i, pv := range ServerProtocolVersions {
 		lesTopics[i] = lesTopic(eth.BlockChain().Genesis().Hash(), pv)
 	}
+	if config.LightServ >= highCapacityServ {
+		for _, pv := range ServerProtocolVersions {
+			lesTopics = append(lesTopics, lesCapacityTopic(eth.BlockChain().Genesis().Hash(), pv))
+		}
+	}
 
+	priority := make(map[discover.NodeID]bool)
+	for _, id := range config.LightPriority {
+		node, err := discover.HexID(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid priority light client %q: %v", id, err)
+		}
+		priority[node] = true
+	}
 	srv := &LesServer{
 		protocolManager:  pm,
 		quitSync:         quitSync,
 		lesTopics:        lesTopics,
+		priority:         priority,
+		maxClients:       config.LightPeers,
+		capacity:         serveCapacity(config.LightServ, config.LightPeers),
+		accounts:         newClientAccounts(eth.ChainDb(), config.LightQuota, config.LightPayee, config.LightPrice),
 		chtIndexer:       light.NewChtIndexer(eth.ChainDb(), false),
 		bloomTrieIndexer: light.NewBloomTrieIndexer(eth.ChainDb(), false),
 	}
@@ -97,15 +140,45 @@ func NewLesServer(eth *eth.Ethereum, config *eth.Config) (*LesServer, error) {
 		BufLimit:    300000000,
 		MinRecharge: 50000,
 	}
+	srv.priParams = &flowcontrol.ServerParams{
+		BufLimit:    srv.defParams.BufLimit * priorityFactor,
+		MinRecharge: srv.defParams.MinRecharge * priorityFactor,
+	}
 	srv.fcManager = flowcontrol.NewClientManager(uint64(config.LightServ), 10, 1000000000)
 	srv.fcCostStats = newCostStats(eth.ChainDb())
 	return srv, nil
 }
 
+// isPriority reports whether the client with the given node ID is served with
+// priority.
+func (s *LesServer) isPriority(id discover.NodeID) bool {
+	return s.priority[id]
+}
+
+// clientParams returns the flow control parameters to serve the client with the
+// given node ID with.
+func (s *LesServer) clientParams(id discover.NodeID) *flowcontrol.ServerParams {
+	if s.isPriority(id) && s.priParams != nil {
+		return s.priParams
+	}
+	return s.defParams
+}
+
 func (s *LesServer) Protocols() []p2p.Protocol {
 	return s.protocolManager.SubProtocols
 }
 
+// APIs returns the RPC services offered by the LES server.
+func (s *LesServer) APIs() []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: "les",
+			Version:   "1.0",
+			Service:   NewPrivateLightServerAPI(s),
+		},
+	}
+}
+
 // Start starts the LES server
 func (s *LesServer) Start(srvr *p2p.Server) {
 	s.protocolManager.Start()
@@ -132,6 +205,7 @@ func (s *LesServer) Stop() {
 	s.chtIndexer.Close()
 	// bloom trie indexer is closed by parent bloombits indexer
 	s.fcCostStats.store()
+	s.accounts.store(nil)
 	s.fcManager.Stop()
 	go func() {
 		<-s.protocolManager.noMorePeers
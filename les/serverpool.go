@@ -89,6 +89,15 @@ const (
 	// initStatsWeight is used to initialize previously unknown peers with good
 	// statistics to give a chance to prove themselves
 	initStatsWeight = 1
+	// highCapacityBoost is the selection weight multiplier of newly discovered
+	// servers advertising themselves as high capacity ones
+	highCapacityBoost = 4
+	// refCapacity is the serving capacity advertised by a regular server (one
+	// dedicating half of its time to 20 clients), used as the reference for
+	// weighting known servers by their advertised capacity, limited to the range
+	// [1/maxCapacityFactor, maxCapacityFactor]
+	refCapacity       = 2500
+	maxCapacityFactor = 10
 )
 
 // serverPool implements a pool for storing and selecting newly discovered and already
@@ -102,11 +111,14 @@ type serverPool struct {
 	wg     *sync.WaitGroup
 	connWg sync.WaitGroup
 
-	topic discv5.Topic
+	topic    discv5.Topic
+	capTopic discv5.Topic
 
-	discSetPeriod chan time.Duration
-	discNodes     chan *discv5.Node
-	discLookups   chan bool
+	discSetPeriod    chan time.Duration
+	discNodes        chan *discv5.Node
+	discLookups      chan bool
+	discCapSetPeriod chan time.Duration // Search period of high capacity servers
+	discCapNodes     chan *discv5.Node  // Discovered high capacity servers
 
 	entries              map[discover.NodeID]*poolEntry
 	lock                 sync.Mutex
@@ -138,9 +150,12 @@ func newServerPool(db ethdb.Database, quit chan struct{}, wg *sync.WaitGroup) *s
 	return pool
 }
 
-func (pool *serverPool) start(server *p2p.Server, topic discv5.Topic) {
+// start starts discovering servers advertising the given topic, preferring those
+// also advertising the high capacity topic.
+func (pool *serverPool) start(server *p2p.Server, topic, capTopic discv5.Topic) {
 	pool.server = server
 	pool.topic = topic
+	pool.capTopic = capTopic
 	pool.dbKey = append([]byte("serverPool/"), []byte(topic)...)
 	pool.wg.Add(1)
 	pool.loadNodes()
@@ -150,6 +165,10 @@ func (pool *serverPool) start(server *p2p.Server, topic discv5.Topic) {
 		pool.discNodes = make(chan *discv5.Node, 100)
 		pool.discLookups = make(chan bool, 100)
 		go pool.server.DiscV5.SearchTopic(pool.topic, pool.discSetPeriod, pool.discNodes, pool.discLookups)
+
+		pool.discCapSetPeriod = make(chan time.Duration, 1)
+		pool.discCapNodes = make(chan *discv5.Node, 100)
+		go pool.server.DiscV5.SearchTopic(pool.capTopic, pool.discCapSetPeriod, pool.discCapNodes, nil)
 	}
 
 	go pool.eventLoop()
@@ -196,6 +215,9 @@ func (pool *serverPool) registered(entry *poolEntry) {
 
 	entry.state = psRegistered
 	entry.regTime = mclock.Now()
+	if entry.peer != nil && entry.peer.serveCapacity != 0 {
+		entry.capacity = entry.peer.serveCapacity
+	}
 	if !entry.known {
 		pool.newQueue.remove(entry)
 		entry.known = true
@@ -280,6 +302,7 @@ func (pool *serverPool) eventLoop() {
 	var convTime mclock.AbsTime
 	if pool.discSetPeriod != nil {
 		pool.discSetPeriod <- time.Millisecond * 100
+		pool.discCapSetPeriod <- time.Millisecond * 100
 	}
 	for {
 		select {
@@ -317,6 +340,13 @@ func (pool *serverPool) eventLoop() {
 			pool.updateCheckDial(entry)
 			pool.lock.Unlock()
 
+		case node := <-pool.discCapNodes:
+			pool.lock.Lock()
+			entry := pool.findOrNewNode(discover.NodeID(node.ID), node.IP, node.TCP)
+			entry.highCapacity = true
+			pool.updateCheckDial(entry)
+			pool.lock.Unlock()
+
 		case conv := <-pool.discLookups:
 			if conv {
 				if lookupCnt == 0 {
@@ -327,6 +357,7 @@ func (pool *serverPool) eventLoop() {
 					pool.fastDiscover = false
 					if pool.discSetPeriod != nil {
 						pool.discSetPeriod <- time.Minute
+						pool.discCapSetPeriod <- time.Minute
 					}
 				}
 			}
@@ -334,6 +365,7 @@ func (pool *serverPool) eventLoop() {
 		case <-pool.quit:
 			if pool.discSetPeriod != nil {
 				close(pool.discSetPeriod)
+				close(pool.discCapSetPeriod)
 			}
 			pool.connWg.Wait()
 			pool.saveNodes()
@@ -561,10 +593,13 @@ type poolEntry struct {
 
 	delayedRetry bool
 	shortRetry   int
+
+	highCapacity bool   // Whether the server was discovered through the high capacity topic (persistent)
+	capacity     uint64 // Serving capacity per client advertised by the server (persistent)
 }
 
 func (e *poolEntry) EncodeRLP(w io.Writer) error {
-	return rlp.Encode(w, []interface{}{e.id, e.lastConnected.ip, e.lastConnected.port, e.lastConnected.fails, &e.connectStats, &e.delayStats, &e.responseStats, &e.timeoutStats})
+	return rlp.Encode(w, []interface{}{e.id, e.lastConnected.ip, e.lastConnected.port, e.lastConnected.fails, &e.connectStats, &e.delayStats, &e.responseStats, &e.timeoutStats, e.capacity, e.highCapacity})
 }
 
 func (e *poolEntry) DecodeRLP(s *rlp.Stream) error {
@@ -574,10 +609,21 @@ func (e *poolEntry) DecodeRLP(s *rlp.Stream) error {
 		Port                       uint16
 		Fails                      uint
 		CStat, DStat, RStat, TStat poolStats
+		Rest                       []rlp.RawValue `rlp:"tail"` // Capacity and high capacity flag, missing from older databases
 	}
 	if err := s.Decode(&entry); err != nil {
 		return err
 	}
+	if len(entry.Rest) > 0 {
+		if err := rlp.DecodeBytes(entry.Rest[0], &e.capacity); err != nil {
+			return err
+		}
+	}
+	if len(entry.Rest) > 1 {
+		if err := rlp.DecodeBytes(entry.Rest[1], &e.highCapacity); err != nil {
+			return err
+		}
+	}
 	addr := &poolEntryAddress{ip: entry.IP, port: entry.Port, fails: entry.Fails, lastSeen: mclock.Now()}
 	e.id = entry.ID
 	e.addr = make(map[string]*poolEntryAddress)
@@ -602,11 +648,15 @@ func (e *discoveredEntry) Weight() int64 {
 	if e.state != psNotConnected || e.delayedRetry {
 		return 0
 	}
+	weight := int64(1000000000)
+	if e.highCapacity {
+		weight *= highCapacityBoost
+	}
 	t := time.Duration(mclock.Now() - e.lastDiscovered)
 	if t <= discoverExpireStart {
-		return 1000000000
+		return weight
 	} else {
-		return int64(1000000000 * math.Exp(-float64(t-discoverExpireStart)/float64(discoverExpireConst)))
+		return int64(float64(weight) * math.Exp(-float64(t-discoverExpireStart)/float64(discoverExpireConst)))
 	}
 }
 
@@ -618,7 +668,27 @@ func (e *knownEntry) Weight() int64 {
 	if e.state != psNotConnected || !e.known || e.delayedRetry {
 		return 0
 	}
-	return int64(1000000000 * e.connectStats.recentAvg() * math.Exp(-float64(e.lastConnected.fails)*failDropLn-e.responseStats.recentAvg()/float64(responseScoreTC)-e.delayStats.recentAvg()/float64(delayScoreTC)) * math.Pow((1-e.timeoutStats.recentAvg()), timeoutPow))
+	return int64(1000000000 * (*poolEntry)(e).capacityFactor() * e.connectStats.recentAvg() * math.Exp(-float64(e.lastConnected.fails)*failDropLn-e.responseStats.recentAvg()/float64(responseScoreTC)-e.delayStats.recentAvg()/float64(delayScoreTC)) * math.Pow((1-e.timeoutStats.recentAvg()), timeoutPow))
+}
+
+// capacityFactor returns the selection weight multiplier of a known server based on
+// the capacity it advertised, relative to a regular server. Servers not advertising
+// their capacity are rated by the topic they were discovered through.
+func (e *poolEntry) capacityFactor() float64 {
+	if e.capacity == 0 {
+		if e.highCapacity {
+			return highCapacityBoost
+		}
+		return 1
+	}
+	factor := float64(e.capacity) / refCapacity
+	if factor > maxCapacityFactor {
+		factor = maxCapacityFactor
+	}
+	if factor < 1.0/maxCapacityFactor {
+		factor = 1.0 / maxCapacityFactor
+	}
+	return factor
 }
 
 // poolEntryAddress is a separate object because currently it is necessary to remember
@@ -0,0 +1,113 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"net"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// newTestPoolEntry creates a known server pool entry advertising the given capacity.
+func newTestPoolEntry(id byte, capacity uint64) *poolEntry {
+	addr := &poolEntryAddress{ip: net.IP{127, 0, 0, 1}, port: 30303}
+	entry := &poolEntry{
+		id:            discover.NodeID{id},
+		lastConnected: addr,
+		known:         true,
+		capacity:      capacity,
+
+		lastDiscovered: mclock.Now(),
+	}
+	entry.connectStats.init(1, 1)
+	return entry
+}
+
+// Tests that the advertised capacity of known servers is persisted, and that
+// entries stored without it are still loaded.
+func TestPoolEntryCapacityEncoding(t *testing.T) {
+	entry := newTestPoolEntry(1, 2*refCapacity)
+
+	blob, err := rlp.EncodeToBytes(entry)
+	if err != nil {
+		t.Fatalf("failed to encode entry: %v", err)
+	}
+	var decoded poolEntry
+	if err := rlp.DecodeBytes(blob, &decoded); err != nil {
+		t.Fatalf("failed to decode entry: %v", err)
+	}
+	if decoded.capacity != entry.capacity {
+		t.Errorf("capacity mismatch: have %d, want %d", decoded.capacity, entry.capacity)
+	}
+	// Ensure the discovery topic of the server is persisted too
+	entry.highCapacity = true
+	if blob, err = rlp.EncodeToBytes(entry); err != nil {
+		t.Fatalf("failed to encode entry: %v", err)
+	}
+	if err := rlp.DecodeBytes(blob, &decoded); err != nil {
+		t.Fatalf("failed to decode entry: %v", err)
+	}
+	if !decoded.highCapacity {
+		t.Errorf("high capacity flag not persisted")
+	}
+	// Encode an entry the way older versions did and ensure it's accepted
+	legacy, _ := rlp.EncodeToBytes([]interface{}{entry.id, entry.lastConnected.ip, entry.lastConnected.port, entry.lastConnected.fails, &entry.connectStats, &entry.delayStats, &entry.responseStats, &entry.timeoutStats})
+	if err := rlp.DecodeBytes(legacy, &decoded); err != nil {
+		t.Fatalf("failed to decode legacy entry: %v", err)
+	}
+}
+
+// Tests that servers are selected preferentially based on their advertised and
+// discovered capacity.
+func TestPoolEntryCapacityWeight(t *testing.T) {
+	regular, high, unknown := newTestPoolEntry(1, refCapacity), newTestPoolEntry(2, 4*refCapacity), newTestPoolEntry(3, 0)
+	if rw, hw := (*knownEntry)(regular).Weight(), (*knownEntry)(high).Weight(); hw != 4*rw {
+		t.Errorf("high capacity weight mismatch: have %d, want %d", hw, 4*rw)
+	}
+	if rw, uw := (*knownEntry)(regular).Weight(), (*knownEntry)(unknown).Weight(); uw != rw {
+		t.Errorf("unknown capacity weight mismatch: have %d, want %d", uw, rw)
+	}
+	if factor := newTestPoolEntry(4, 1000*refCapacity).capacityFactor(); factor != maxCapacityFactor {
+		t.Errorf("capacity factor not capped: have %v, want %v", factor, maxCapacityFactor)
+	}
+	// Ensure servers discovered through the high capacity topic are preferred,
+	// even when known if they don't advertise their capacity
+	regular.highCapacity, high.highCapacity = false, true
+	if rw, hw := (*discoveredEntry)(regular).Weight(), (*discoveredEntry)(high).Weight(); hw != highCapacityBoost*rw {
+		t.Errorf("discovered high capacity weight mismatch: have %d, want %d", hw, highCapacityBoost*rw)
+	}
+	if unknown.highCapacity = true; unknown.capacityFactor() != highCapacityBoost {
+		t.Errorf("unadvertised high capacity factor mismatch: have %v, want %v", unknown.capacityFactor(), highCapacityBoost)
+	}
+}
+
+// Tests that the advertised serving capacity reflects the serving time available
+// per client slot.
+func TestServeCapacity(t *testing.T) {
+	if capacity := serveCapacity(50, 20); capacity != refCapacity {
+		t.Errorf("regular server capacity mismatch: have %d, want %d", capacity, refCapacity)
+	}
+	if capacity := serveCapacity(100, 10); capacity != 4*refCapacity {
+		t.Errorf("dedicated server capacity mismatch: have %d, want %d", capacity, 4*refCapacity)
+	}
+	if capacity := serveCapacity(50, 0); capacity != refCapacity {
+		t.Errorf("unlimited server capacity mismatch: have %d, want %d", capacity, refCapacity)
+	}
+}
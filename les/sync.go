@@ -79,6 +79,12 @@ func (pm *ProtocolManager) synchronise(peer *peer) {
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
-	pm.blockchain.(*light.LightChain).SyncCht(ctx)
+	lc := pm.blockchain.(*light.LightChain)
+	lc.SyncCht(ctx)
+
+	// Fill in the headers skipped by the CHT checkpoint while syncing forward
+	if anchor := lc.BackfillAnchor(); anchor != nil {
+		pm.downloader.Backfill(anchor)
+	}
 	pm.downloader.Synchronise(peer.id, peer.Head(), peer.Td(), downloader.LightSync)
 }
@@ -23,9 +23,14 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
+// txResendBlocks is the number of new heads a relayed transaction may remain
+// unmined for before it's resent through another server.
+const txResendBlocks = 3
+
 type ltrInfo struct {
 	tx     *types.Transaction
 	sentTo map[*peer]struct{}
+	waited int // Number of new heads since the transaction was last sent
 }
 
 type LesTxRelay struct {
@@ -66,6 +71,7 @@ func (self *LesTxRelay) unregisterPeer(p *peer) {
 
 // send sends a list of transactions to at most a given number of peers at
 // once, never resending any particular transaction to the same peer twice
+// until all the connected peers have been tried
 func (self *LesTxRelay) send(txs types.Transactions, count int) {
 	sendTo := make(map[*peer]types.Transactions)
 
@@ -85,8 +91,20 @@ func (self *LesTxRelay) send(txs types.Transactions, count int) {
 			self.txSent[hash] = ltr
 			self.txPending[hash] = struct{}{}
 		}
+		ltr.waited = 0
 
 		if len(self.peerList) > 0 {
+			// Start over if all the connected peers were tried already
+			tried := true
+			for _, peer := range self.peerList {
+				if _, ok := ltr.sentTo[peer]; !ok {
+					tried = false
+					break
+				}
+			}
+			if tried {
+				ltr.sentTo = make(map[*peer]struct{})
+			}
 			cnt := count
 			pos := self.peerStartPos
 			for {
@@ -151,15 +169,17 @@ func (self *LesTxRelay) NewHead(head common.Hash, mined []common.Hash, rollback
 
 	for _, hash := range rollback {
 		self.txPending[hash] = struct{}{}
+		self.txSent[hash].waited = txResendBlocks
 	}
-
-	if len(self.txPending) > 0 {
-		txs := make(types.Transactions, len(self.txPending))
-		i := 0
-		for hash := range self.txPending {
-			txs[i] = self.txSent[hash].tx
-			i++
+	// Resend the transactions not mined for a while through other peers
+	var txs types.Transactions
+	for hash := range self.txPending {
+		ltr := self.txSent[hash]
+		if ltr.waited++; ltr.waited >= txResendBlocks {
+			txs = append(txs, ltr.tx)
 		}
+	}
+	if len(txs) > 0 {
 		self.send(txs, 1)
 	}
 }
@@ -0,0 +1,70 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Tests that relayed transactions are only resent after remaining unmined for a
+// number of blocks, rotating through all the connected servers.
+func TestTxRelayResend(t *testing.T) {
+	quit := make(chan struct{})
+	defer close(quit)
+
+	relay := NewLesTxRelay(newPeerSet(), newRequestDistributor(nil, quit))
+	relay.peerList = []*peer{{id: "a"}, {id: "b"}}
+
+	tx := types.NewTransaction(0, common.Address{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil)
+	relay.Send(types.Transactions{tx})
+
+	ltr := relay.txSent[tx.Hash()]
+	if len(ltr.sentTo) != 2 {
+		t.Fatalf("initial send count mismatch: have %d, want %d", len(ltr.sentTo), 2)
+	}
+	// Ensure the transaction isn't resent before its time
+	for i := 0; i < txResendBlocks-1; i++ {
+		relay.NewHead(common.Hash{byte(i)}, nil, nil)
+		if len(ltr.sentTo) != 2 {
+			t.Fatalf("head %d: transaction resent early", i)
+		}
+	}
+	// Ensure it's resent once all servers were tried, starting over
+	relay.NewHead(common.Hash{0xff}, nil, nil)
+	if len(ltr.sentTo) != 1 {
+		t.Fatalf("resend count mismatch: have %d, want %d", len(ltr.sentTo), 1)
+	}
+	if ltr.waited != 0 {
+		t.Fatalf("resend wait not reset: have %d", ltr.waited)
+	}
+	// Ensure mined transactions are not resent anymore
+	for i := 0; i < txResendBlocks; i++ {
+		relay.NewHead(common.Hash{byte(i)}, []common.Hash{tx.Hash()}, nil)
+	}
+	if len(ltr.sentTo) != 1 {
+		t.Fatalf("mined transaction resent")
+	}
+	// Ensure rolled back transactions are resent immediately
+	relay.NewHead(common.Hash{0xfe}, nil, []common.Hash{tx.Hash()})
+	if len(ltr.sentTo) != 2 {
+		t.Fatalf("rolled back transaction not resent")
+	}
+}
@@ -72,7 +72,10 @@ type LightChain struct {
 // NewLightChain returns a fully initialised light chain using information
 // available in the database. It initialises the default Ethereum header
 // validator.
-func NewLightChain(odr OdrBackend, config *params.ChainConfig, engine consensus.Engine) (*LightChain, error) {
+//
+// Header sync is bootstrapped from the given trusted checkpoint, or from the one
+// shipped for the network if none is given (nil).
+func NewLightChain(odr OdrBackend, config *params.ChainConfig, engine consensus.Engine, checkpoint *params.TrustedCheckpoint) (*LightChain, error) {
 	bodyCache, _ := lru.New(bodyCacheLimit)
 	bodyRLPCache, _ := lru.New(bodyCacheLimit)
 	blockCache, _ := lru.New(blockCacheLimit)
@@ -95,8 +98,11 @@ func NewLightChain(odr OdrBackend, config *params.ChainConfig, engine consensus.
 	if bc.genesisBlock == nil {
 		return nil, core.ErrNoGenesis
 	}
-	if cp, ok := trustedCheckpoints[bc.genesisBlock.Hash()]; ok {
-		bc.addTrustedCheckpoint(cp)
+	if checkpoint == nil {
+		checkpoint = trustedCheckpoints[bc.genesisBlock.Hash()]
+	}
+	if checkpoint != nil {
+		bc.addTrustedCheckpoint(checkpoint)
 	}
 
 	if err := bc.loadLastState(); err != nil {
@@ -114,19 +120,19 @@ func NewLightChain(odr OdrBackend, config *params.ChainConfig, engine consensus.
 }
 
 // addTrustedCheckpoint adds a trusted checkpoint to the blockchain
-func (self *LightChain) addTrustedCheckpoint(cp trustedCheckpoint) {
+func (self *LightChain) addTrustedCheckpoint(cp *params.TrustedCheckpoint) {
 	if self.odr.ChtIndexer() != nil {
-		StoreChtRoot(self.chainDb, cp.sectionIdx, cp.sectionHead, cp.chtRoot)
-		self.odr.ChtIndexer().AddKnownSectionHead(cp.sectionIdx, cp.sectionHead)
+		StoreChtRoot(self.chainDb, cp.SectionIndex, cp.SectionHead, cp.CHTRoot)
+		self.odr.ChtIndexer().AddKnownSectionHead(cp.SectionIndex, cp.SectionHead)
 	}
 	if self.odr.BloomTrieIndexer() != nil {
-		StoreBloomTrieRoot(self.chainDb, cp.sectionIdx, cp.sectionHead, cp.bloomTrieRoot)
-		self.odr.BloomTrieIndexer().AddKnownSectionHead(cp.sectionIdx, cp.sectionHead)
+		StoreBloomTrieRoot(self.chainDb, cp.SectionIndex, cp.SectionHead, cp.BloomRoot)
+		self.odr.BloomTrieIndexer().AddKnownSectionHead(cp.SectionIndex, cp.SectionHead)
 	}
 	if self.odr.BloomIndexer() != nil {
-		self.odr.BloomIndexer().AddKnownSectionHead(cp.sectionIdx, cp.sectionHead)
+		self.odr.BloomIndexer().AddKnownSectionHead(cp.SectionIndex, cp.SectionHead)
 	}
-	log.Info("Added trusted checkpoint", "chain name", cp.name)
+	log.Info("Added trusted checkpoint", "chain name", cp.Name, "section", cp.SectionIndex, "head", cp.SectionHead)
 }
 
 func (self *LightChain) getProcInterrupt() bool {
@@ -478,6 +484,21 @@ func (self *LightChain) SyncCht(ctx context.Context) bool {
 	return false
 }
 
+// BackfillAnchor returns the header of the last trusted CHT section head if the
+// chain was bootstrapped from it and the headers below it are not yet complete,
+// or nil otherwise.
+func (self *LightChain) BackfillAnchor() *types.Header {
+	if self.odr.ChtIndexer() == nil || core.GetCanonicalHash(self.chainDb, 1) != (common.Hash{}) {
+		return nil
+	}
+	chtCount, _, _ := self.odr.ChtIndexer().Sections()
+	if chtCount == 0 {
+		return nil
+	}
+	num := chtCount*ChtFrequency - 1
+	return core.GetHeader(self.chainDb, core.GetCanonicalHash(self.chainDb, num), num)
+}
+
 // LockChain locks the chain mutex for reading so that multiple canonical hashes can be
 // retrieved while it is guaranteed that they belong to the same version of the chain
 func (self *LightChain) LockChain() {
@@ -510,6 +531,12 @@ func (self *LightChain) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscri
 	return self.scope.Track(new(event.Feed).Subscribe(ch))
 }
 
+// SubscribeReorgEvent implements the interface of filters.Backend
+// LightChain does not send core.ReorgEvent, so return an empty subscription.
+func (self *LightChain) SubscribeReorgEvent(ch chan<- core.ReorgEvent) event.Subscription {
+	return self.scope.Track(new(event.Feed).Subscribe(ch))
+}
+
 // SubscribeRemovedLogsEvent implements the interface of filters.Backend
 // LightChain does not send core.RemovedLogsEvent, so return an empty subscription.
 func (self *LightChain) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
@@ -86,6 +86,7 @@ func GetCanonicalHash(ctx context.Context, odr OdrBackend, number uint64) (commo
 // GetBodyRLP retrieves the block body (transactions and uncles) in RLP encoding.
 func GetBodyRLP(ctx context.Context, odr OdrBackend, hash common.Hash, number uint64) (rlp.RawValue, error) {
 	if data := core.GetBodyRLP(odr.Database(), hash, number); data != nil {
+		touchCache(odr, odrCacheBody, hash)
 		return data, nil
 	}
 	r := &BlockRequest{Hash: hash, Number: number}
@@ -131,6 +132,7 @@ func GetBlock(ctx context.Context, odr OdrBackend, hash common.Hash, number uint
 func GetBlockReceipts(ctx context.Context, odr OdrBackend, hash common.Hash, number uint64) (types.Receipts, error) {
 	receipts := core.GetBlockReceipts(odr.Database(), hash, number)
 	if receipts != nil {
+		touchCache(odr, odrCacheReceipts, hash)
 		return receipts, nil
 	}
 	r := &ReceiptsRequest{Hash: hash, Number: number}
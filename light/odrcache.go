@@ -0,0 +1,296 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package light
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// odrCacheIndexPrefix is the database key prefix the index records of the ODR
+// cache are stored under, followed by the kind and the hash of the entry.
+var odrCacheIndexPrefix = []byte("odr-cache-")
+
+// odrCacheFlushInterval is the number of modified index records after which they
+// are persisted, limiting the untracked data left behind by a crash.
+const odrCacheFlushInterval = 1024
+
+// Kinds of data tracked by the ODR cache.
+const (
+	odrCacheNode     = iota // Trie node or contract code, keyed by hash
+	odrCacheBody            // Block body
+	odrCacheReceipts        // Block receipts
+)
+
+// odrCacheEntry is a single item of retrieved data stored in the database.
+type odrCacheEntry struct {
+	Kind   uint8       `rlp:"-"` // Stored in the key of the index record
+	Hash   common.Hash `rlp:"-"` // Stored in the key of the index record
+	Number uint64
+	Size   uint64
+	Used   uint64 // Sequence number of the last access, ordering the entries on load
+}
+
+// odrCacheKey uniquely identifies an entry of the ODR cache.
+type odrCacheKey struct {
+	kind uint8
+	hash common.Hash
+}
+
+// record returns the database key of the index record of the entry.
+func (k odrCacheKey) record() []byte {
+	return append(append(append([]byte{}, odrCacheIndexPrefix...), k.kind), k.hash[:]...)
+}
+
+// odrCacheEntriesByUse implements sort.Interface to order entries by last access.
+type odrCacheEntriesByUse []*odrCacheEntry
+
+func (s odrCacheEntriesByUse) Len() int           { return len(s) }
+func (s odrCacheEntriesByUse) Less(i, j int) bool { return s[i].Used < s[j].Used }
+func (s odrCacheEntriesByUse) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// OdrCache tracks the data stored in the local database by ODR retrievals (trie
+// proofs, contract code, block bodies and receipts), evicting the least recently
+// used items once their total size exceeds a limit. Headers, canonical hashes
+// and chain indexer data are never evicted. The index is persisted as a record
+// per entry to allow the cache to outlive restarts.
+type OdrCache struct {
+	db    ethdb.Database
+	limit uint64 // Maximum total size of the tracked data
+	size  uint64 // Current total size of the tracked data
+	clock uint64 // Sequence number of the last access
+
+	entries *list.List                     // Tracked entries, most recently used first
+	index   map[odrCacheKey]*list.Element  // Entries indexed by kind and hash
+	dirty   map[odrCacheKey]*odrCacheEntry // Entries whose index records are stale
+
+	lock sync.Mutex
+}
+
+// NewOdrCache creates an ODR cache limiting the retrieved data stored in the
+// given database to the given size, loading any previously persisted index.
+func NewOdrCache(db ethdb.Database, limit uint64) *OdrCache {
+	cache := &OdrCache{
+		db:      db,
+		limit:   limit,
+		entries: list.New(),
+		index:   make(map[odrCacheKey]*list.Element),
+		dirty:   make(map[odrCacheKey]*odrCacheEntry),
+	}
+	var entries []*odrCacheEntry
+
+	it := db.NewIteratorWithPrefix(odrCacheIndexPrefix)
+	for it.Next() {
+		key := it.Key()[len(odrCacheIndexPrefix):]
+		if len(key) != 1+common.HashLength {
+			continue
+		}
+		entry := new(odrCacheEntry)
+		if err := rlp.DecodeBytes(it.Value(), entry); err != nil {
+			log.Warn("Failed to decode ODR cache record", "key", common.ToHex(key), "err", err)
+			continue
+		}
+		entry.Kind, entry.Hash = key[0], common.BytesToHash(key[1:])
+		entries = append(entries, entry)
+	}
+	if err := it.Error(); err != nil {
+		log.Warn("Failed to load ODR cache index", "err", err)
+	}
+	it.Release()
+
+	sort.Sort(odrCacheEntriesByUse(entries))
+	for _, entry := range entries {
+		cache.index[odrCacheKey{entry.Kind, entry.Hash}] = cache.entries.PushFront(entry)
+		cache.size += entry.Size
+		cache.clock = entry.Used
+	}
+	cache.evict()
+	return cache
+}
+
+// Add starts tracking the data stored by a successfully retrieved request.
+func (c *OdrCache) Add(req OdrRequest) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	switch req := req.(type) {
+	case *TrieRequest:
+		for _, node := range req.Proof.NodeList() {
+			c.add(&odrCacheEntry{Kind: odrCacheNode, Hash: crypto.Keccak256Hash(node), Size: uint64(len(node))})
+		}
+	case *CodeRequest:
+		c.add(&odrCacheEntry{Kind: odrCacheNode, Hash: req.Hash, Size: uint64(len(req.Data))})
+	case *BlockRequest:
+		c.add(&odrCacheEntry{Kind: odrCacheBody, Hash: req.Hash, Number: req.Number, Size: uint64(len(req.Rlp))})
+	case *ReceiptsRequest:
+		blob, _ := rlp.EncodeToBytes(req.Receipts)
+		c.add(&odrCacheEntry{Kind: odrCacheReceipts, Hash: req.Hash, Number: req.Number, Size: uint64(len(blob))})
+	default:
+		return
+	}
+	c.evict()
+}
+
+// add inserts or refreshes a single entry. The lock must be held.
+func (c *OdrCache) add(entry *odrCacheEntry) {
+	key := odrCacheKey{entry.Kind, entry.Hash}
+	if elem, ok := c.index[key]; ok {
+		c.use(key, elem)
+		return
+	}
+	c.index[key] = c.entries.PushFront(entry)
+	c.size += entry.Size
+	c.use(key, c.index[key])
+}
+
+// use marks a tracked entry as most recently used. The lock must be held.
+func (c *OdrCache) use(key odrCacheKey, elem *list.Element) {
+	entry := elem.Value.(*odrCacheEntry)
+
+	c.clock++
+	entry.Used = c.clock
+	c.entries.MoveToFront(elem)
+	c.dirty[key] = entry
+}
+
+// touch marks an entry as recently used, if it's tracked.
+func (c *OdrCache) touch(kind uint8, hash common.Hash) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	key := odrCacheKey{kind, hash}
+	if elem, ok := c.index[key]; ok {
+		c.use(key, elem)
+		if len(c.dirty) >= odrCacheFlushInterval {
+			c.flush()
+		}
+	}
+}
+
+// evict deletes the least recently used entries (and their index records) from
+// the database until the tracked data fits into the limit, persisting the stale
+// index records if there are enough of them.
+func (c *OdrCache) evict() {
+	for c.limit > 0 && c.size > c.limit {
+		elem := c.entries.Back()
+		entry := elem.Value.(*odrCacheEntry)
+		key := odrCacheKey{entry.Kind, entry.Hash}
+
+		switch entry.Kind {
+		case odrCacheNode:
+			c.db.Delete(entry.Hash[:])
+		case odrCacheBody:
+			core.DeleteBody(c.db, entry.Hash, entry.Number)
+		case odrCacheReceipts:
+			core.DeleteBlockReceipts(c.db, entry.Hash, entry.Number)
+		}
+		c.db.Delete(key.record())
+
+		c.entries.Remove(elem)
+		delete(c.index, key)
+		delete(c.dirty, key)
+		c.size -= entry.Size
+	}
+	if len(c.dirty) >= odrCacheFlushInterval {
+		c.flush()
+	}
+}
+
+// flush persists the stale index records of the cache. The lock must be held.
+func (c *OdrCache) flush() {
+	batch := c.db.NewBatch()
+	for key, entry := range c.dirty {
+		blob, err := rlp.EncodeToBytes(entry)
+		if err != nil {
+			log.Error("Failed to encode ODR cache record", "err", err)
+			return
+		}
+		batch.Put(key.record(), blob)
+	}
+	if err := batch.Write(); err != nil {
+		log.Error("Failed to store ODR cache index", "err", err)
+		return
+	}
+	c.dirty = make(map[odrCacheKey]*odrCacheEntry)
+}
+
+// Close persists the index of the cache.
+func (c *OdrCache) Close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.flush()
+}
+
+// Size returns the total size of the data tracked by the cache.
+func (c *OdrCache) Size() uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.size
+}
+
+// cachingBackend is implemented by ODR backends tracking the retrieved data in an
+// ODR cache.
+type cachingBackend interface {
+	Cache() *OdrCache
+}
+
+// touchCache marks a locally found item as recently used in the ODR cache of the
+// backend, if it has one.
+func touchCache(odr OdrBackend, kind uint8, hash common.Hash) {
+	if backend, ok := odr.(cachingBackend); ok {
+		if cache := backend.Cache(); cache != nil {
+			cache.touch(kind, hash)
+		}
+	}
+}
+
+// cachedNodeDatabase wraps the database of an ODR backend, marking the trie nodes
+// read from it as recently used in the ODR cache.
+type cachedNodeDatabase struct {
+	ethdb.Database
+	cache *OdrCache
+}
+
+// Get retrieves a trie node from the database, touching it in the cache if found.
+func (db *cachedNodeDatabase) Get(key []byte) ([]byte, error) {
+	blob, err := db.Database.Get(key)
+	if err == nil && len(key) == common.HashLength {
+		db.cache.touch(odrCacheNode, common.BytesToHash(key))
+	}
+	return blob, err
+}
+
+// nodeDatabase returns the database to resolve the trie nodes of the backend from,
+// tracking the accesses in its ODR cache if it has one.
+func nodeDatabase(odr OdrBackend) ethdb.Database {
+	if backend, ok := odr.(cachingBackend); ok {
+		if cache := backend.Cache(); cache != nil {
+			return &cachedNodeDatabase{Database: odr.Database(), cache: cache}
+		}
+	}
+	return odr.Database()
+}
@@ -0,0 +1,149 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package light
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// storeCode stores a contract code in the database the way a retrieval would and
+// returns the request tracking it.
+func storeCode(db ethdb.Database, code []byte) *CodeRequest {
+	req := &CodeRequest{Hash: crypto.Keccak256Hash(code), Data: code}
+	req.StoreResult(db)
+	return req
+}
+
+// Tests that the least recently used data is evicted from the database once the
+// cache exceeds its limit, and that recently accessed data is kept.
+func TestOdrCacheEviction(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	cache := NewOdrCache(db, 300)
+
+	a := storeCode(db, bytes.Repeat([]byte{0x01}, 100))
+	b := storeCode(db, bytes.Repeat([]byte{0x02}, 100))
+	c := storeCode(db, bytes.Repeat([]byte{0x03}, 100))
+	for _, req := range []*CodeRequest{a, b, c} {
+		cache.Add(req)
+	}
+	if size := cache.Size(); size != 300 {
+		t.Fatalf("cache size mismatch: have %d, want %d", size, 300)
+	}
+	// Access the oldest item and add a new one, evicting the second oldest
+	cache.touch(odrCacheNode, a.Hash)
+	d := storeCode(db, bytes.Repeat([]byte{0x04}, 100))
+	cache.Add(d)
+
+	if size := cache.Size(); size != 300 {
+		t.Fatalf("cache size mismatch: have %d, want %d", size, 300)
+	}
+	for _, req := range []*CodeRequest{a, c, d} {
+		if ok, _ := db.Has(req.Hash[:]); !ok {
+			t.Errorf("code %x evicted", req.Hash)
+		}
+	}
+	if ok, _ := db.Has(b.Hash[:]); ok {
+		t.Errorf("code %x not evicted", b.Hash)
+	}
+}
+
+// Tests that the index of the cache is persisted, so data retrieved before a
+// restart is still evicted afterwards.
+func TestOdrCachePersistence(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	cache := NewOdrCache(db, 1000)
+
+	a := storeCode(db, bytes.Repeat([]byte{0x01}, 100))
+	b := storeCode(db, bytes.Repeat([]byte{0x02}, 100))
+	cache.Add(a)
+	cache.Add(b)
+	cache.Close()
+
+	// Reopen the cache with a lower limit, evicting the oldest item
+	cache = NewOdrCache(db, 150)
+	if size := cache.Size(); size != 100 {
+		t.Fatalf("cache size mismatch: have %d, want %d", size, 100)
+	}
+	if ok, _ := db.Has(a.Hash[:]); ok {
+		t.Errorf("code %x not evicted", a.Hash)
+	}
+	if ok, _ := db.Has(b.Hash[:]); !ok {
+		t.Errorf("code %x evicted", b.Hash)
+	}
+}
+
+// Tests that each entry is persisted as its own index record, along with its
+// recency, so the eviction order survives restarts.
+func TestOdrCacheRecords(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	cache := NewOdrCache(db, 1000)
+
+	a := storeCode(db, bytes.Repeat([]byte{0x01}, 100))
+	b := storeCode(db, bytes.Repeat([]byte{0x02}, 100))
+	cache.Add(a)
+	cache.Add(b)
+	cache.touch(odrCacheNode, a.Hash)
+	cache.Close()
+
+	for _, req := range []*CodeRequest{a, b} {
+		if ok, _ := db.Has(odrCacheKey{odrCacheNode, req.Hash}.record()); !ok {
+			t.Errorf("index record of %x missing", req.Hash)
+		}
+	}
+	// Reopen the cache with a lower limit, evicting the least recently used item
+	cache = NewOdrCache(db, 150)
+	if ok, _ := db.Has(a.Hash[:]); !ok {
+		t.Errorf("code %x evicted", a.Hash)
+	}
+	if ok, _ := db.Has(b.Hash[:]); ok {
+		t.Errorf("code %x not evicted", b.Hash)
+	}
+	if ok, _ := db.Has(odrCacheKey{odrCacheNode, b.Hash}.record()); ok {
+		t.Errorf("index record of evicted %x not deleted", b.Hash)
+	}
+}
+
+// Tests that trie nodes resolved from the local database are marked as recently
+// used, protecting them from eviction.
+func TestOdrCacheNodeReads(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	cache := NewOdrCache(db, 300)
+	nodes := &cachedNodeDatabase{Database: db, cache: cache}
+
+	a := storeCode(db, bytes.Repeat([]byte{0x01}, 100))
+	b := storeCode(db, bytes.Repeat([]byte{0x02}, 100))
+	c := storeCode(db, bytes.Repeat([]byte{0x03}, 100))
+	for _, req := range []*CodeRequest{a, b, c} {
+		cache.Add(req)
+	}
+	// Resolve the oldest item as a trie would and add a new one
+	if _, err := nodes.Get(a.Hash[:]); err != nil {
+		t.Fatalf("failed to read node %x: %v", a.Hash, err)
+	}
+	cache.Add(storeCode(db, bytes.Repeat([]byte{0x04}, 100)))
+
+	if ok, _ := db.Has(a.Hash[:]); !ok {
+		t.Errorf("read node %x evicted", a.Hash)
+	}
+	if ok, _ := db.Has(b.Hash[:]); ok {
+		t.Errorf("unread node %x not evicted", b.Hash)
+	}
+}
@@ -69,6 +69,7 @@ func (db *odrDatabase) ContractCode(addrHash, codeHash common.Hash) ([]byte, err
 		return nil, nil
 	}
 	if code, err := db.backend.Database().Get(codeHash[:]); err == nil {
+		touchCache(db.backend, odrCacheNode, codeHash)
 		return code, nil
 	}
 	id := *db.id
@@ -141,7 +142,7 @@ func (t *odrTrie) do(key []byte, fn func() error) error {
 	for {
 		var err error
 		if t.trie == nil {
-			t.trie, err = trie.New(t.id.Root, t.db.backend.Database())
+			t.trie, err = trie.New(t.id.Root, nodeDatabase(t.db.backend))
 		}
 		if err == nil {
 			err = fn()
@@ -167,7 +168,7 @@ func newNodeIterator(t *odrTrie, startkey []byte) trie.NodeIterator {
 	// Open the actual non-ODR trie if that hasn't happened yet.
 	if t.trie == nil {
 		it.do(func() error {
-			t, err := trie.New(t.id.Root, t.db.backend.Database())
+			t, err := trie.New(t.id.Root, nodeDatabase(t.db.backend))
 			if err == nil {
 				it.t.trie = t
 			}
@@ -0,0 +1,138 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is the layout of the timestamp suffix of rotated files.
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotatingFileHandler returns a handler which writes log records to the given file
+// using the given format, rotating it once it grows beyond maxSize bytes or gets
+// older than maxAge. Rotated files are renamed with a timestamp suffix, and only
+// the newest maxBackups of them are kept. Zero values disable the respective limit.
+func RotatingFileHandler(path string, maxSize int64, maxAge time.Duration, maxBackups int, fmtr Format) (Handler, error) {
+	w := &rotatingWriter{
+		path:       path,
+		maxSize:    maxSize,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return closingHandler{w, StreamHandler(w, fmtr)}, nil
+}
+
+// rotatingWriter is an io.WriteCloser appending to a file, which it rotates when
+// the size or age limit is reached.
+type rotatingWriter struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+
+	file    *os.File
+	size    int64     // Bytes written to the current file
+	created time.Time // Time the current file was started
+	lock    sync.Mutex
+}
+
+// open opens the log file for appending, continuing an existing one.
+func (w *rotatingWriter) open() error {
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	w.file, w.size, w.created = file, info.Size(), time.Now()
+	return nil
+}
+
+// Write implements io.Writer, rotating the file beforehand if it's due.
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	if w.size > 0 && ((w.maxSize > 0 && w.size+int64(len(p)) > w.maxSize) || (w.maxAge > 0 && time.Since(w.created) > w.maxAge)) {
+		// If the rotation fails, keep appending to the current file if possible
+		if err := w.rotate(); err != nil && w.file == nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// rotate moves the current file aside, deletes the surplus old ones and starts a
+// new file. If the file can't be moved, the current one is reopened. The lock must
+// be held.
+func (w *rotatingWriter) rotate() error {
+	err := w.file.Close()
+	w.file = nil
+	if err != nil {
+		return w.reopen(err)
+	}
+	backup := fmt.Sprintf("%s.%s", w.path, time.Now().Format(backupTimeFormat))
+	if err := os.Rename(w.path, backup); err != nil {
+		return w.reopen(err)
+	}
+	if w.maxBackups > 0 {
+		backups := w.backups()
+		for len(backups) > w.maxBackups {
+			os.Remove(backups[0])
+			backups = backups[1:]
+		}
+	}
+	return w.open()
+}
+
+// backups returns the rotated files, oldest first. Files next to the log file
+// sharing its name but not a timestamp suffix are not considered.
+func (w *rotatingWriter) backups() []string {
+	files, _ := filepath.Glob(w.path + ".*")
+
+	var backups []string
+	for _, file := range files {
+		if _, err := time.Parse(backupTimeFormat, file[len(w.path)+1:]); err == nil {
+			backups = append(backups, file)
+		}
+	}
+	sort.Strings(backups) // Timestamps sort chronologically
+	return backups
+}
+
+// reopen continues the current file after a failed rotation, returning the
+// rotation error.
+func (w *rotatingWriter) reopen(err error) error {
+	if oerr := w.open(); oerr != nil {
+		return oerr
+	}
+	return err
+}
+
+// Close implements io.Closer, closing the current file.
+func (w *rotatingWriter) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Close()
+}
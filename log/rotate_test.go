@@ -0,0 +1,181 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestWriter creates a rotating writer in a temporary directory.
+func newTestWriter(t *testing.T, maxSize int64, maxAge time.Duration, maxBackups int) (*rotatingWriter, string) {
+	dir, err := ioutil.TempDir("", "log-rotate-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &rotatingWriter{
+		path:       filepath.Join(dir, "geth.log"),
+		maxSize:    maxSize,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+	}
+	if err := w.open(); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return w, dir
+}
+
+// write writes a line to the writer, failing the test on error. Rotated files are
+// named by millisecond timestamps, so writes are spaced to keep them apart.
+func write(t *testing.T, w *rotatingWriter, line string) {
+	time.Sleep(2 * time.Millisecond)
+	if _, err := w.Write([]byte(line)); err != nil {
+		t.Fatalf("failed to write %q: %v", line, err)
+	}
+}
+
+// backups returns the contents of the rotated files, oldest first.
+func backups(t *testing.T, w *rotatingWriter) []string {
+	files := w.backups()
+	contents := make([]string, len(files))
+	for i, file := range files {
+		blob, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[i] = string(blob)
+	}
+	return contents
+}
+
+// current returns the contents of the file currently written.
+func current(t *testing.T, w *rotatingWriter) string {
+	blob, err := ioutil.ReadFile(w.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(blob)
+}
+
+// Tests that the file is rotated before a write would exceed the size limit, and
+// that existing files are continued.
+func TestRotateSize(t *testing.T) {
+	w, dir := newTestWriter(t, 10, 0, 0)
+	defer os.RemoveAll(dir)
+
+	write(t, w, "12345\n")
+	write(t, w, "678\n")
+	if have := backups(t, w); len(have) != 0 {
+		t.Fatalf("rotated below the size limit: %q", have)
+	}
+	write(t, w, "9\n")
+	if have, want := backups(t, w), []string{"12345\n678\n"}; strings.Join(have, "|") != strings.Join(want, "|") {
+		t.Fatalf("backups mismatch: have %q, want %q", have, want)
+	}
+	// Writes larger than the limit still go to a fresh file
+	write(t, w, "0123456789abc\n")
+	if have := current(t, w); have != "0123456789abc\n" {
+		t.Fatalf("current file mismatch: have %q", have)
+	}
+	w.Close()
+
+	// Reopening continues the file and its size
+	w = &rotatingWriter{path: w.path, maxSize: 100}
+	if err := w.open(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if w.size != 14 {
+		t.Errorf("size of continued file mismatch: have %d, want 14", w.size)
+	}
+}
+
+// Tests that the file is rotated once it gets older than the age limit.
+func TestRotateAge(t *testing.T) {
+	w, dir := newTestWriter(t, 0, time.Hour, 0)
+	defer os.RemoveAll(dir)
+	defer w.Close()
+
+	write(t, w, "first\n")
+	write(t, w, "second\n")
+	if have := backups(t, w); len(have) != 0 {
+		t.Fatalf("rotated below the age limit: %q", have)
+	}
+	w.created = w.created.Add(-2 * time.Hour)
+	write(t, w, "third\n")
+	if have, want := backups(t, w), []string{"first\nsecond\n"}; strings.Join(have, "|") != strings.Join(want, "|") {
+		t.Fatalf("backups mismatch: have %q, want %q", have, want)
+	}
+	if have := current(t, w); have != "third\n" {
+		t.Fatalf("current file mismatch: have %q", have)
+	}
+}
+
+// Tests that only the newest backups are kept.
+func TestRotateBackups(t *testing.T) {
+	w, dir := newTestWriter(t, 1, 0, 2)
+	defer os.RemoveAll(dir)
+	defer w.Close()
+
+	for _, line := range []string{"a", "b", "c", "d", "e"} {
+		write(t, w, line)
+	}
+	if have, want := backups(t, w), []string{"c", "d"}; strings.Join(have, "|") != strings.Join(want, "|") {
+		t.Fatalf("backups mismatch: have %q, want %q", have, want)
+	}
+	if have := current(t, w); have != "e" {
+		t.Fatalf("current file mismatch: have %q", have)
+	}
+}
+
+// Tests that a failed rotation doesn't break logging.
+func TestRotateFailure(t *testing.T) {
+	w, dir := newTestWriter(t, 1, 0, 0)
+	defer os.RemoveAll(dir)
+	defer w.Close()
+
+	// Deleting the file makes renaming it for the rotation fail
+	write(t, w, "a")
+	if err := os.Remove(w.path); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.rotate(); err == nil {
+		t.Fatal("rotating a deleted file succeeded")
+	}
+	write(t, w, "b")
+	write(t, w, "c")
+	if have, want := backups(t, w), []string{"b"}; strings.Join(have, "|") != strings.Join(want, "|") {
+		t.Fatalf("backups mismatch: have %q, want %q", have, want)
+	}
+	if have := current(t, w); have != "c" {
+		t.Fatalf("current file mismatch: have %q", have)
+	}
+}
+
+// Tests that only rotated files are pruned, not others sharing the log file's name.
+func TestRotateUnrelatedFiles(t *testing.T) {
+	w, dir := newTestWriter(t, 1, 0, 1)
+	defer os.RemoveAll(dir)
+	defer w.Close()
+
+	siblings := []string{w.path + ".toml", w.path + ".ipc", w.path + ".2006-01-02"}
+	for _, sibling := range siblings {
+		if err := ioutil.WriteFile(sibling, []byte("keep"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, line := range []string{"a", "b", "c"} {
+		write(t, w, line)
+	}
+	if have, want := backups(t, w), []string{"b"}; strings.Join(have, "|") != strings.Join(want, "|") {
+		t.Fatalf("backups mismatch: have %q, want %q", have, want)
+	}
+	for _, sibling := range siblings {
+		if _, err := os.Stat(sibling); err != nil {
+			t.Errorf("unrelated file %s pruned: %v", filepath.Base(sibling), err)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package influxdb periodically pushes the metrics registry to an InfluxDB
+// server using the v1 line protocol.
+package influxdb
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/rcrowley/go-metrics"
+)
+
+// Config contains the settings of the InfluxDB reporter.
+type Config struct {
+	Endpoint string            // Address of the InfluxDB server (e.g. http://localhost:8086)
+	Database string            // Database to write the metrics into
+	Username string            // Username to authenticate with, empty for none
+	Password string            // Password to authenticate with
+	Tags     map[string]string // Tags attached to all reported metrics
+	Interval time.Duration     // Time between two consecutive reports
+}
+
+// reporter pushes the metrics of a registry to an InfluxDB server.
+type reporter struct {
+	reg    metrics.Registry
+	config Config
+	url    string
+	tags   string // Pre-formatted tag set, including the leading comma
+	client *http.Client
+}
+
+// newReporter creates a reporter for the given registry and configuration.
+func newReporter(reg metrics.Registry, config Config) (*reporter, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid InfluxDB endpoint: %v", err)
+	}
+	endpoint.Path = strings.TrimSuffix(endpoint.Path, "/") + "/write"
+	endpoint.RawQuery = url.Values{"db": {config.Database}, "precision": {"ns"}}.Encode()
+
+	keys := make([]string, 0, len(config.Tags))
+	for key := range config.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var tags string
+	for _, key := range keys {
+		tags += "," + escape(key, ",= ") + "=" + escape(config.Tags[key], ",= ")
+	}
+	return &reporter{
+		reg:    reg,
+		config: config,
+		url:    endpoint.String(),
+		tags:   tags,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Report pushes the metrics of the given registry to InfluxDB every configured
+// interval. It only returns if the configuration is invalid.
+func Report(reg metrics.Registry, config Config) error {
+	r, err := newReporter(reg, config)
+	if err != nil {
+		return err
+	}
+	for range time.Tick(config.Interval) {
+		if err := r.send(); err != nil {
+			log.Warn("Failed to report metrics to InfluxDB", "err", err)
+		}
+	}
+	return nil
+}
+
+// send writes the current values of all metrics to InfluxDB.
+func (r *reporter) send() error {
+	buf := new(bytes.Buffer)
+	r.write(buf, time.Now())
+
+	req, err := http.NewRequest("POST", r.url, buf)
+	if err != nil {
+		return err
+	}
+	if r.config.Username != "" {
+		req.SetBasicAuth(r.config.Username, r.config.Password)
+	}
+	res, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("server returned %s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// write writes all metrics of the registry as line protocol points, ordered by
+// name.
+func (r *reporter) write(w io.Writer, now time.Time) {
+	all := make(map[string]interface{})
+	r.reg.Each(func(name string, metric interface{}) {
+		all[name] = metric
+	})
+	names := make([]string, 0, len(all))
+	for name := range all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var fields string
+		switch metric := all[name].(type) {
+		case metrics.Counter:
+			fields = fmt.Sprintf("count=%di", metric.Count())
+		case metrics.Gauge:
+			fields = fmt.Sprintf("value=%di", metric.Value())
+		case metrics.GaugeFloat64:
+			fields = "value=" + float(metric.Value())
+		case metrics.Meter:
+			m := metric.Snapshot()
+			fields = fmt.Sprintf("count=%di,m1=%s,m5=%s,m15=%s,mean=%s",
+				m.Count(), float(m.Rate1()), float(m.Rate5()), float(m.Rate15()), float(m.RateMean()))
+		case metrics.Timer:
+			t := metric.Snapshot()
+			ps := t.Percentiles([]float64{0.5, 0.75, 0.95, 0.99})
+			fields = fmt.Sprintf("count=%di,min=%di,max=%di,mean=%s,p50=%s,p75=%s,p95=%s,p99=%s,m1=%s,m5=%s,m15=%s",
+				t.Count(), t.Min(), t.Max(), float(t.Mean()), float(ps[0]), float(ps[1]), float(ps[2]), float(ps[3]),
+				float(t.Rate1()), float(t.Rate5()), float(t.Rate15()))
+		case metrics.Histogram:
+			h := metric.Snapshot()
+			ps := h.Percentiles([]float64{0.5, 0.75, 0.95, 0.99})
+			fields = fmt.Sprintf("count=%di,min=%di,max=%di,mean=%s,p50=%s,p75=%s,p95=%s,p99=%s",
+				h.Count(), h.Min(), h.Max(), float(h.Mean()), float(ps[0]), float(ps[1]), float(ps[2]), float(ps[3]))
+		default:
+			continue
+		}
+		fmt.Fprintf(w, "%s%s %s %d\n", escape(name, ", "), r.tags, fields, now.UnixNano())
+	}
+}
+
+// float formats a floating point field value.
+func float(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// escape backslash escapes the given special characters of a measurement name or
+// tag key or value.
+func escape(s string, special string) string {
+	var b bytes.Buffer
+	for _, r := range s {
+		if strings.ContainsRune(special, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
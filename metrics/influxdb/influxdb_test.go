@@ -0,0 +1,106 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package influxdb
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// Tests that the metrics are pushed as tagged line protocol points, using the
+// configured database and credentials.
+func TestReport(t *testing.T) {
+	reg := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("txpool/pending/discard", reg).Inc(3)
+	metrics.GetOrRegisterGauge("chain/head/block", reg).Update(42)
+
+	var (
+		query, user, pass, body string
+		authed                  bool
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Path + "?" + r.URL.RawQuery
+		user, pass, authed = r.BasicAuth()
+		blob, _ := ioutil.ReadAll(r.Body)
+		body = string(blob)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	r, err := newReporter(reg, Config{
+		Endpoint: server.URL,
+		Database: "geth",
+		Username: "user",
+		Password: "secret",
+		Tags:     map[string]string{"node": "node 1", "network": "1"},
+		Interval: time.Second,
+	})
+	if err != nil {
+		t.Fatalf("failed to create reporter: %v", err)
+	}
+	if err := r.send(); err != nil {
+		t.Fatalf("failed to report metrics: %v", err)
+	}
+	if want := "/write?db=geth&precision=ns"; query != want {
+		t.Errorf("request mismatch: have %s, want %s", query, want)
+	}
+	if !authed || user != "user" || pass != "secret" {
+		t.Errorf("credentials mismatch: have %s/%s (%v), want user/secret", user, pass, authed)
+	}
+	lines := []string{
+		`chain/head/block,network=1,node=node\ 1 value=42i`,
+		`txpool/pending/discard,network=1,node=node\ 1 count=3i`,
+	}
+	for _, line := range lines {
+		if !containsLine(body, line) {
+			t.Errorf("point missing: %q\nbody:\n%s", line, body)
+		}
+	}
+}
+
+// Tests that server side failures are reported.
+func TestReportFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "database not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	r, err := newReporter(metrics.NewRegistry(), Config{Endpoint: server.URL, Database: "missing"})
+	if err != nil {
+		t.Fatalf("failed to create reporter: %v", err)
+	}
+	if err := r.send(); err == nil {
+		t.Errorf("failure not reported")
+	}
+}
+
+// containsLine checks whether the body contains a point starting with the given
+// measurement, tags and fields.
+func containsLine(body, prefix string) bool {
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, prefix+" ") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package prometheus exposes the metrics registry in the Prometheus text
+// exposition format.
+package prometheus
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/rcrowley/go-metrics"
+)
+
+// quantiles are the percentiles reported for timers and histograms.
+var quantiles = []float64{0.5, 0.75, 0.95, 0.99}
+
+// Handler returns an HTTP handler serving the metrics of the given registry in
+// the Prometheus text exposition format.
+func Handler(reg metrics.Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if err := Write(w, reg); err != nil {
+			log.Debug("Failed to serve Prometheus metrics", "err", err)
+		}
+	})
+}
+
+// ListenAndServe serves the metrics of the given registry on the /metrics path
+// of the given address. It only returns on failure.
+func ListenAndServe(addr string, reg metrics.Registry) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler(reg))
+	return http.ListenAndServe(addr, mux)
+}
+
+// Write writes all metrics of the registry in the Prometheus text exposition
+// format, ordered by name. Counters and meters are reported as counters, gauges
+// as gauges, while timers and histograms as summaries.
+func Write(w io.Writer, reg metrics.Registry) error {
+	all := make(map[string]interface{})
+	reg.Each(func(name string, metric interface{}) {
+		all[name] = metric
+	})
+	names := make([]string, 0, len(all))
+	for name := range all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := bufio.NewWriter(w)
+	for _, name := range names {
+		key := metricName(name)
+		switch metric := all[name].(type) {
+		case metrics.Counter:
+			writeType(out, key, "counter")
+			fmt.Fprintf(out, "%s %d\n", key, metric.Count())
+		case metrics.Gauge:
+			writeType(out, key, "gauge")
+			fmt.Fprintf(out, "%s %d\n", key, metric.Value())
+		case metrics.GaugeFloat64:
+			writeType(out, key, "gauge")
+			fmt.Fprintf(out, "%s %v\n", key, metric.Value())
+		case metrics.Meter:
+			writeType(out, key, "counter")
+			fmt.Fprintf(out, "%s %d\n", key, metric.Snapshot().Count())
+		case metrics.Timer:
+			t := metric.Snapshot()
+			writeSummary(out, key, t.Percentiles(quantiles), t.Sum(), t.Count())
+		case metrics.Histogram:
+			h := metric.Snapshot()
+			writeSummary(out, key, h.Percentiles(quantiles), h.Sum(), h.Count())
+		}
+	}
+	return out.Flush()
+}
+
+// writeType writes the type declaration of a metric.
+func writeType(w io.Writer, key string, kind string) {
+	fmt.Fprintf(w, "# TYPE %s %s\n", key, kind)
+}
+
+// writeSummary writes a summary metric with the given quantile values.
+func writeSummary(w io.Writer, key string, values []float64, sum int64, count int64) {
+	writeType(w, key, "summary")
+	for i, q := range quantiles {
+		fmt.Fprintf(w, "%s{quantile=\"%v\"} %v\n", key, q, values[i])
+	}
+	fmt.Fprintf(w, "%s_sum %d\n", key, sum)
+	fmt.Fprintf(w, "%s_count %d\n", key, count)
+}
+
+// metricName converts a registry metric name (e.g. p2p/InboundTraffic) into a
+// valid Prometheus metric name (e.g. p2p_InboundTraffic).
+func metricName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == ':':
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,56 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package prometheus
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// Tests that the metrics of a registry are written in the exposition format.
+func TestWrite(t *testing.T) {
+	reg := metrics.NewRegistry()
+
+	metrics.GetOrRegisterCounter("txpool/pending/discard", reg).Inc(3)
+	metrics.GetOrRegisterGauge("chain/head/block", reg).Update(42)
+	metrics.GetOrRegisterMeter("p2p/InboundTraffic", reg).Mark(1024)
+	metrics.GetOrRegisterTimer("rpc/duration", reg).Update(10)
+
+	buf := new(bytes.Buffer)
+	if err := Write(buf, reg); err != nil {
+		t.Fatalf("failed to write metrics: %v", err)
+	}
+	want := `# TYPE chain_head_block gauge
+chain_head_block 42
+# TYPE p2p_InboundTraffic counter
+p2p_InboundTraffic 1024
+# TYPE rpc_duration summary
+rpc_duration{quantile="0.5"} 10
+rpc_duration{quantile="0.75"} 10
+rpc_duration{quantile="0.95"} 10
+rpc_duration{quantile="0.99"} 10
+rpc_duration_sum 10
+rpc_duration_count 1
+# TYPE txpool_pending_discard counter
+txpool_pending_discard 3
+`
+	if have := buf.String(); have != want {
+		t.Errorf("exposition mismatch:\nhave:\n%s\nwant:\n%s", have, want)
+	}
+}
@@ -177,6 +177,17 @@ func (self *Miner) PendingBlock() *types.Block {
 	return self.worker.pendingBlock()
 }
 
+// Policy returns the block assembly rules currently followed by the miner.
+func (self *Miner) Policy() Policy {
+	return self.worker.getPolicy()
+}
+
+// SetPolicy updates the block assembly rules of the miner. The new rules take
+// effect from the next block the miner starts working on.
+func (self *Miner) SetPolicy(policy Policy) error {
+	return self.worker.setPolicy(policy)
+}
+
 func (self *Miner) SetEtherbase(addr common.Address) {
 	self.coinbase = addr
 	self.worker.setEtherbase(addr)
@@ -0,0 +1,274 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"container/heap"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// MaxUncleDepth is the maximum distance between a block and the uncles it may
+// include, as allowed by the consensus rules.
+const MaxUncleDepth = 6
+
+// TxOrdering is the strategy used by the miner to order the pending transactions
+// when assembling a new block.
+type TxOrdering int
+
+const (
+	// OrderPriceNonce interleaves the transactions of all accounts, always picking
+	// the best paying executable transaction next.
+	OrderPriceNonce TxOrdering = iota
+
+	// OrderPrice ranks the accounts by the gas price of their first executable
+	// transaction, including all the transactions of an account back to back.
+	OrderPrice
+
+	// OrderFIFO picks the transactions in the order they were first seen by the
+	// miner, while still honouring the account nonces.
+	OrderFIFO
+)
+
+// String implements the stringer interface.
+func (o TxOrdering) String() string {
+	switch o {
+	case OrderPriceNonce:
+		return "price+nonce"
+	case OrderPrice:
+		return "price"
+	case OrderFIFO:
+		return "fifo"
+	default:
+		return fmt.Sprintf("unknown ordering %d", int(o))
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (o TxOrdering) MarshalText() ([]byte, error) {
+	switch o {
+	case OrderPriceNonce, OrderPrice, OrderFIFO:
+		return []byte(o.String()), nil
+	default:
+		return nil, fmt.Errorf("unknown transaction ordering %d", int(o))
+	}
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (o *TxOrdering) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "price+nonce":
+		*o = OrderPriceNonce
+	case "price":
+		*o = OrderPrice
+	case "fifo":
+		*o = OrderFIFO
+	default:
+		return fmt.Errorf(`unknown transaction ordering %q, want "price+nonce", "price" or "fifo"`, text)
+	}
+	return nil
+}
+
+// Policy is the set of rules the miner follows when assembling new blocks.
+type Policy struct {
+	IncludeUncles bool       // Whether to include uncles into mined blocks
+	MaxUncleDepth int        // Maximum distance of the uncles to include (1..MaxUncleDepth)
+	TxOrdering    TxOrdering // Strategy to order the pending transactions with
+	MinGasPrice   *big.Int   // Minimum gas price of transactions to include (nil = any)
+}
+
+// DefaultPolicy contains the block assembly rules used by default.
+var DefaultPolicy = Policy{
+	IncludeUncles: true,
+	MaxUncleDepth: MaxUncleDepth,
+	TxOrdering:    OrderPriceNonce,
+}
+
+// validate checks that the policy settings are within the allowed ranges.
+func (p *Policy) validate() error {
+	if p.MaxUncleDepth < 1 || p.MaxUncleDepth > MaxUncleDepth {
+		return fmt.Errorf("invalid uncle depth %d, want 1..%d", p.MaxUncleDepth, MaxUncleDepth)
+	}
+	if _, err := p.TxOrdering.MarshalText(); err != nil {
+		return err
+	}
+	if p.MinGasPrice != nil && p.MinGasPrice.Sign() < 0 {
+		return fmt.Errorf("negative minimum gas price %v", p.MinGasPrice)
+	}
+	return nil
+}
+
+// txSource is a set of pending transactions the worker picks from one by one
+// when filling a block.
+type txSource interface {
+	// Peek returns the next transaction to try, or nil if none are left.
+	Peek() *types.Transaction
+
+	// Shift replaces the current transaction with the next one of the same account.
+	Shift()
+
+	// Pop removes the current transaction along with all subsequent ones of the
+	// same account, used if the transaction cannot be executed.
+	Pop()
+}
+
+// newTxSource orders the given pending transactions according to the requested
+// strategy. The arrival times are only used by the FIFO ordering.
+func newTxSource(ordering TxOrdering, signer types.Signer, pending map[common.Address]types.Transactions, arrivals map[common.Hash]time.Time) txSource {
+	switch ordering {
+	case OrderPrice:
+		return newTxsByAccountPrice(pending)
+	case OrderFIFO:
+		return newTxsByArrival(signer, pending, arrivals)
+	default:
+		return types.NewTransactionsByPriceAndNonce(signer, pending)
+	}
+}
+
+// txsByAccountPrice is a transaction set which returns the transactions of whole
+// accounts back to back, ranked by the gas price of their first transaction.
+type txsByAccountPrice struct {
+	accounts []types.Transactions // Nonce-sorted transactions of each account, by price
+}
+
+// newTxsByAccountPrice creates an account-price ordered transaction set.
+func newTxsByAccountPrice(pending map[common.Address]types.Transactions) *txsByAccountPrice {
+	accounts := make([]types.Transactions, 0, len(pending))
+	for _, txs := range pending {
+		if len(txs) > 0 {
+			accounts = append(accounts, txs)
+		}
+	}
+	sort.Sort(accountsByPrice(accounts))
+	return &txsByAccountPrice{accounts: accounts}
+}
+
+// Peek returns the next transaction of the best paying account.
+func (t *txsByAccountPrice) Peek() *types.Transaction {
+	if len(t.accounts) == 0 {
+		return nil
+	}
+	return t.accounts[0][0]
+}
+
+// Shift moves on to the next transaction of the current account, or to the next
+// account if there are no more.
+func (t *txsByAccountPrice) Shift() {
+	if t.accounts[0] = t.accounts[0][1:]; len(t.accounts[0]) == 0 {
+		t.accounts = t.accounts[1:]
+	}
+}
+
+// Pop skips all remaining transactions of the current account.
+func (t *txsByAccountPrice) Pop() {
+	t.accounts = t.accounts[1:]
+}
+
+// accountsByPrice sorts account transaction lists by their first gas price.
+type accountsByPrice []types.Transactions
+
+func (s accountsByPrice) Len() int           { return len(s) }
+func (s accountsByPrice) Less(i, j int) bool { return s[i][0].GasPrice().Cmp(s[j][0].GasPrice()) > 0 }
+func (s accountsByPrice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// txsByArrival is a transaction set which returns transactions in the order they
+// were first seen, while still honouring the account nonces.
+type txsByArrival struct {
+	txs    map[common.Address]types.Transactions // Per account nonce-sorted list of transactions
+	heads  *arrivalHeap                          // Next transaction for each unique account (arrival heap)
+	signer types.Signer                          // Signer for the set of transactions
+}
+
+// newTxsByArrival creates an arrival ordered transaction set. Transactions with
+// unknown arrival times are considered older than all the others.
+func newTxsByArrival(signer types.Signer, pending map[common.Address]types.Transactions, arrivals map[common.Hash]time.Time) *txsByArrival {
+	heads := &arrivalHeap{arrivals: arrivals}
+	for acc, txs := range pending {
+		if len(txs) == 0 {
+			continue
+		}
+		heads.txs = append(heads.txs, txs[0])
+		pending[acc] = txs[1:]
+	}
+	heap.Init(heads)
+
+	return &txsByArrival{
+		txs:    pending,
+		heads:  heads,
+		signer: signer,
+	}
+}
+
+// Peek returns the earliest seen executable transaction.
+func (t *txsByArrival) Peek() *types.Transaction {
+	if len(t.heads.txs) == 0 {
+		return nil
+	}
+	return t.heads.txs[0]
+}
+
+// Shift replaces the current head with the next one from the same account.
+func (t *txsByArrival) Shift() {
+	acc, _ := types.Sender(t.signer, t.heads.txs[0])
+	if txs, ok := t.txs[acc]; ok && len(txs) > 0 {
+		t.heads.txs[0], t.txs[acc] = txs[0], txs[1:]
+		heap.Fix(t.heads, 0)
+	} else {
+		heap.Pop(t.heads)
+	}
+}
+
+// Pop removes the current head, *not* replacing it with the next one from the
+// same account.
+func (t *txsByArrival) Pop() {
+	heap.Pop(t.heads)
+}
+
+// arrivalHeap is a heap of transactions ordered by their arrival times, with the
+// gas price deciding between transactions seen at the same time.
+type arrivalHeap struct {
+	txs      []*types.Transaction
+	arrivals map[common.Hash]time.Time
+}
+
+func (h *arrivalHeap) Len() int      { return len(h.txs) }
+func (h *arrivalHeap) Swap(i, j int) { h.txs[i], h.txs[j] = h.txs[j], h.txs[i] }
+
+func (h *arrivalHeap) Less(i, j int) bool {
+	ti, tj := h.arrivals[h.txs[i].Hash()], h.arrivals[h.txs[j].Hash()]
+	if !ti.Equal(tj) {
+		return ti.Before(tj)
+	}
+	return h.txs[i].GasPrice().Cmp(h.txs[j].GasPrice()) > 0
+}
+
+func (h *arrivalHeap) Push(x interface{}) {
+	h.txs = append(h.txs, x.(*types.Transaction))
+}
+
+func (h *arrivalHeap) Pop() interface{} {
+	old := h.txs
+	n := len(old)
+	x := old[n-1]
+	h.txs = old[0 : n-1]
+	return x
+}
@@ -0,0 +1,131 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// makeTxs creates a batch of nonce ordered transactions signed by key, with the
+// given gas prices.
+func makeTxs(signer types.Signer, key *ecdsa.PrivateKey, prices ...int64) types.Transactions {
+	var txs types.Transactions
+	for nonce, price := range prices {
+		tx, _ := types.SignTx(types.NewTransaction(uint64(nonce), common.Address{}, big.NewInt(0), big.NewInt(21000), big.NewInt(price), nil), signer, key)
+		txs = append(txs, tx)
+	}
+	return txs
+}
+
+// drain collects all the transactions from a source, in order.
+func drain(source txSource) types.Transactions {
+	var txs types.Transactions
+	for tx := source.Peek(); tx != nil; tx = source.Peek() {
+		txs = append(txs, tx)
+		source.Shift()
+	}
+	return txs
+}
+
+// Tests that the different transaction orderings return the pending transactions
+// in their expected orders.
+func TestTxOrderings(t *testing.T) {
+	signer := types.HomesteadSigner{}
+
+	key1, _ := crypto.GenerateKey()
+	key2, _ := crypto.GenerateKey()
+	addr1, addr2 := crypto.PubkeyToAddress(key1.PublicKey), crypto.PubkeyToAddress(key2.PublicKey)
+
+	txs1 := makeTxs(signer, key1, 2, 10)
+	txs2 := makeTxs(signer, key2, 5, 1)
+
+	pending := func() map[common.Address]types.Transactions {
+		return map[common.Address]types.Transactions{addr1: txs1, addr2: txs2}
+	}
+	now := time.Now()
+	arrivals := map[common.Hash]time.Time{
+		txs1[0].Hash(): now.Add(2 * time.Second),
+		txs1[1].Hash(): now.Add(3 * time.Second),
+		txs2[0].Hash(): now,
+		txs2[1].Hash(): now.Add(4 * time.Second),
+	}
+	tests := []struct {
+		ordering TxOrdering
+		want     types.Transactions
+	}{
+		{OrderPriceNonce, types.Transactions{txs2[0], txs1[0], txs1[1], txs2[1]}},
+		{OrderPrice, types.Transactions{txs2[0], txs2[1], txs1[0], txs1[1]}},
+		{OrderFIFO, types.Transactions{txs2[0], txs1[0], txs1[1], txs2[1]}},
+	}
+	for _, tt := range tests {
+		have := drain(newTxSource(tt.ordering, signer, pending(), arrivals))
+		if len(have) != len(tt.want) {
+			t.Errorf("%v: transaction count mismatch: have %d, want %d", tt.ordering, len(have), len(tt.want))
+			continue
+		}
+		for i := range have {
+			if have[i] != tt.want[i] {
+				t.Errorf("%v: transaction %d mismatch: have %x, want %x", tt.ordering, i, have[i].Hash(), tt.want[i].Hash())
+			}
+		}
+	}
+	// Popping an account should drop all its remaining transactions
+	for _, ordering := range []TxOrdering{OrderPriceNonce, OrderPrice, OrderFIFO} {
+		source := newTxSource(ordering, signer, pending(), arrivals)
+		source.Pop()
+		if have := drain(source); len(have) != 2 || have[0] != txs1[0] || have[1] != txs1[1] {
+			t.Errorf("%v: remaining transactions mismatch after pop: %v", ordering, have)
+		}
+	}
+}
+
+// Tests that invalid miner policies are rejected.
+func TestPolicyValidation(t *testing.T) {
+	tests := []struct {
+		policy Policy
+		valid  bool
+	}{
+		{DefaultPolicy, true},
+		{Policy{MaxUncleDepth: 1, TxOrdering: OrderFIFO, MinGasPrice: big.NewInt(1)}, true},
+		{Policy{MaxUncleDepth: 0}, false},
+		{Policy{MaxUncleDepth: MaxUncleDepth + 1}, false},
+		{Policy{MaxUncleDepth: 1, TxOrdering: TxOrdering(100)}, false},
+		{Policy{MaxUncleDepth: 1, MinGasPrice: big.NewInt(-1)}, false},
+	}
+	for i, tt := range tests {
+		if err := tt.policy.validate(); (err == nil) != tt.valid {
+			t.Errorf("test %d: validity mismatch: have %v, want valid %v", i, err, tt.valid)
+		}
+	}
+	for _, ordering := range []TxOrdering{OrderPriceNonce, OrderPrice, OrderFIFO} {
+		text, err := ordering.MarshalText()
+		if err != nil {
+			t.Fatalf("%v: failed to marshal: %v", ordering, err)
+		}
+		var decoded TxOrdering
+		if err := decoded.UnmarshalText(text); err != nil || decoded != ordering {
+			t.Errorf("%v: round trip mismatch: have %v, err %v", ordering, decoded, err)
+		}
+	}
+}
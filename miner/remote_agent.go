@@ -19,6 +19,7 @@ package miner
 import (
 	"errors"
 	"math/big"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -27,14 +28,42 @@ import (
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
 )
 
+const (
+	// staleThreshold is the number of blocks a work package may lag behind the
+	// current one and still have its solutions accepted, as they can become uncles.
+	staleThreshold = 7
+
+	// minerTimeout is the time after which a remote miner's statistics are dropped
+	// if it doesn't show any activity.
+	minerTimeout = 10 * time.Minute
+
+	// maxRemoteMiners is the maximum number of remote miners tracked individually.
+	// Identifiers are chosen by the miners, so any beyond are tracked as anonymous.
+	maxRemoteMiners = 1024
+)
+
 type hashrate struct {
 	ping time.Time
 	rate uint64
 }
 
+// RemoteMiner contains the statistics of a remote miner, identified by the
+// identifier it reports itself with. Miners not reporting any identifier are
+// aggregated under an empty one.
+type RemoteMiner struct {
+	ID       string    `json:"id"`
+	Hashrate uint64    `json:"hashrate"` // Last reported hash rate, zero if outdated
+	Work     uint64    `json:"work"`     // Number of work packages fetched
+	Accepted uint64    `json:"accepted"` // Number of valid solutions submitted
+	Stale    uint64    `json:"stale"`    // Number of solutions submitted for outdated work
+	Invalid  uint64    `json:"invalid"`  // Number of invalid solutions submitted
+	LastSeen time.Time `json:"lastSeen"`
+}
+
 type RemoteAgent struct {
 	mu sync.Mutex
 
@@ -46,9 +75,12 @@ type RemoteAgent struct {
 	engine      consensus.Engine
 	currentWork *Work
 	work        map[common.Hash]*Work
+	workFeed    event.Feed // Feed of the work packages of new work, subscribers must not block
 
 	hashrateMu sync.RWMutex
-	hashrate   map[common.Hash]hashrate
+	hashrate   map[string]hashrate
+
+	miners map[string]*RemoteMiner // Statistics of the remote miners, protected by mu
 
 	running int32 // running indicates whether the agent is active. Call atomically
 }
@@ -58,15 +90,20 @@ func NewRemoteAgent(chain consensus.ChainReader, engine consensus.Engine) *Remot
 		chain:    chain,
 		engine:   engine,
 		work:     make(map[common.Hash]*Work),
-		hashrate: make(map[common.Hash]hashrate),
+		hashrate: make(map[string]hashrate),
+		miners:   make(map[string]*RemoteMiner),
 	}
 }
 
-func (a *RemoteAgent) SubmitHashrate(id common.Hash, rate uint64) {
+// SubmitHashrate records the hash rate reported by a remote miner.
+func (a *RemoteAgent) SubmitHashrate(id string, rate uint64) {
 	a.hashrateMu.Lock()
-	defer a.hashrateMu.Unlock()
-
 	a.hashrate[id] = hashrate{time.Now(), rate}
+	a.hashrateMu.Unlock()
+
+	a.mu.Lock()
+	a.miner(id)
+	a.mu.Unlock()
 }
 
 func (a *RemoteAgent) Work() chan<- *Work {
@@ -106,42 +143,65 @@ func (a *RemoteAgent) GetHashRate() (tot int64) {
 	return
 }
 
-func (a *RemoteAgent) GetWork() ([3]string, error) {
+// GetWork returns the work package of the current work for the given remote
+// miner, which may be anonymous.
+func (a *RemoteAgent) GetWork(id string) ([3]string, error) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
+	if a.currentWork == nil {
+		return [3]string{}, errors.New("No work available yet, don't panic.")
+	}
+	a.miner(id).Work++
+	return workPackage(a.currentWork.Block), nil
+}
+
+// SubscribeWork subscribes to the work packages of new work, allowing miners to
+// be notified instead of polling GetWork. The subscriber must consume the packages
+// promptly, as the sealing of new work waits for them to be delivered.
+func (a *RemoteAgent) SubscribeWork(ch chan<- [3]string) event.Subscription {
+	return a.workFeed.Subscribe(ch)
+}
+
+// workPackage returns the work package of a block for external miners, consisting
+// of the hex encoded header pow-hash, the seed hash used for the DAG and the
+// boundary condition ("target", 2^256/difficulty).
+func workPackage(block *types.Block) [3]string {
 	var res [3]string
 
-	if a.currentWork != nil {
-		block := a.currentWork.Block
-
-		res[0] = block.HashNoNonce().Hex()
-		seedHash := ethash.SeedHash(block.NumberU64())
-		res[1] = common.BytesToHash(seedHash).Hex()
-		// Calculate the "target" to be returned to the external miner
-		n := big.NewInt(1)
-		n.Lsh(n, 255)
-		n.Div(n, block.Difficulty())
-		n.Lsh(n, 1)
-		res[2] = common.BytesToHash(n.Bytes()).Hex()
-
-		a.work[block.HashNoNonce()] = a.currentWork
-		return res, nil
-	}
-	return res, errors.New("No work available yet, don't panic.")
+	res[0] = block.HashNoNonce().Hex()
+	seedHash := ethash.SeedHash(block.NumberU64())
+	res[1] = common.BytesToHash(seedHash).Hex()
+	// Calculate the "target" to be returned to the external miner
+	n := big.NewInt(1)
+	n.Lsh(n, 255)
+	n.Div(n, block.Difficulty())
+	n.Lsh(n, 1)
+	res[2] = common.BytesToHash(n.Bytes()).Hex()
+
+	return res
 }
 
-// SubmitWork tries to inject a pow solution into the remote agent, returning
-// whether the solution was accepted or not (not can be both a bad pow as well as
-// any other error, like no work pending).
-func (a *RemoteAgent) SubmitWork(nonce types.BlockNonce, mixDigest, hash common.Hash) bool {
+// SubmitWork tries to inject a pow solution of a remote miner into the remote
+// agent, returning whether the solution was accepted or not (not can be both a
+// bad pow as well as any other error, like no work pending). Solutions for work
+// lagging behind the current one are accepted up to staleThreshold blocks.
+func (a *RemoteAgent) SubmitWork(id string, nonce types.BlockNonce, mixDigest, hash common.Hash) bool {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	// Make sure the work submitted is present
+	miner := a.miner(id)
+
+	// Make sure the work submitted is present and recent enough
 	work := a.work[hash]
 	if work == nil {
-		log.Info("Work submitted but none pending", "hash", hash)
+		log.Info("Work submitted but none pending", "hash", hash, "miner", id)
+		miner.Stale++
+		return false
+	}
+	if a.currentWork != nil && work.Block.NumberU64()+staleThreshold < a.currentWork.Block.NumberU64() {
+		log.Info("Stale work submitted", "number", work.Block.NumberU64(), "current", a.currentWork.Block.NumberU64(), "miner", id)
+		miner.Stale++
 		return false
 	}
 	// Make sure the Engine solutions is indeed valid
@@ -150,7 +210,8 @@ func (a *RemoteAgent) SubmitWork(nonce types.BlockNonce, mixDigest, hash common.
 	result.MixDigest = mixDigest
 
 	if err := a.engine.VerifySeal(a.chain, result); err != nil {
-		log.Warn("Invalid proof-of-work submitted", "hash", hash, "err", err)
+		log.Warn("Invalid proof-of-work submitted", "hash", hash, "miner", id, "err", err)
+		miner.Invalid++
 		return false
 	}
 	block := work.Block.WithSeal(result)
@@ -158,10 +219,49 @@ func (a *RemoteAgent) SubmitWork(nonce types.BlockNonce, mixDigest, hash common.
 	// Solutions seems to be valid, return to the miner and notify acceptance
 	a.returnCh <- &Result{work, block}
 	delete(a.work, hash)
+	miner.Accepted++
 
 	return true
 }
 
+// Miners returns the statistics of the recently active remote miners, ordered
+// by their identifier.
+func (a *RemoteAgent) Miners() []*RemoteMiner {
+	a.mu.Lock()
+	miners := make([]*RemoteMiner, 0, len(a.miners))
+	for _, miner := range a.miners {
+		cpy := *miner
+		miners = append(miners, &cpy)
+	}
+	a.mu.Unlock()
+
+	a.hashrateMu.RLock()
+	for _, miner := range miners {
+		miner.Hashrate = a.hashrate[miner.ID].rate
+	}
+	a.hashrateMu.RUnlock()
+
+	sort.Sort(minersByID(miners))
+	return miners
+}
+
+// miner returns the statistics of a remote miner, marking it active. If too many
+// miners are tracked already, unknown ones are accounted as anonymous. The lock
+// must be held.
+func (a *RemoteAgent) miner(id string) *RemoteMiner {
+	miner, ok := a.miners[id]
+	if !ok && len(a.miners) >= maxRemoteMiners {
+		id = ""
+		miner, ok = a.miners[id]
+	}
+	if !ok {
+		miner = &RemoteMiner{ID: id}
+		a.miners[id] = miner
+	}
+	miner.LastSeen = time.Now()
+	return miner
+}
+
 // loop monitors mining events on the work and quit channels, updating the internal
 // state of the remote miner until a termination is requested.
 //
@@ -176,16 +276,28 @@ func (a *RemoteAgent) loop(workCh chan *Work, quitCh chan struct{}) {
 		select {
 		case <-quitCh:
 			return
-		case work := <-workCh:
+		case work, ok := <-workCh:
+			if !ok {
+				return // Stopped, channel closed along with quitCh
+			}
+			// Track the new work and drop the ones too old to be accepted
 			a.mu.Lock()
 			a.currentWork = work
+			a.work[work.Block.HashNoNonce()] = work
+			for hash, old := range a.work {
+				if old.Block.NumberU64()+staleThreshold < work.Block.NumberU64() {
+					delete(a.work, hash)
+				}
+			}
 			a.mu.Unlock()
+
+			a.workFeed.Send(workPackage(work.Block))
 		case <-ticker.C:
 			// cleanup
 			a.mu.Lock()
-			for hash, work := range a.work {
-				if time.Since(work.createdAt) > 7*(12*time.Second) {
-					delete(a.work, hash)
+			for id, miner := range a.miners {
+				if time.Since(miner.LastSeen) > minerTimeout {
+					delete(a.miners, id)
 				}
 			}
 			a.mu.Unlock()
@@ -200,3 +312,10 @@ func (a *RemoteAgent) loop(workCh chan *Work, quitCh chan struct{}) {
 		}
 	}
 }
+
+// minersByID implements sort.Interface to order remote miners by identifier.
+type minersByID []*RemoteMiner
+
+func (m minersByID) Len() int           { return len(m) }
+func (m minersByID) Less(i, j int) bool { return m[i].ID < m[j].ID }
+func (m minersByID) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
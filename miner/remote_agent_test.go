@@ -0,0 +1,124 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// newRemoteTestAgent creates a running remote agent sealing with a fake engine,
+// failing the verification of the given block number.
+func newRemoteTestAgent(fail uint64) (*RemoteAgent, chan [3]string, chan *Result) {
+	agent := NewRemoteAgent(nil, ethash.NewFakeFailer(fail))
+
+	results := make(chan *Result, 16)
+	agent.SetReturnCh(results)
+	agent.Start()
+
+	packages := make(chan [3]string, 16)
+	agent.SubscribeWork(packages)
+
+	return agent, packages, results
+}
+
+// feedRemoteTestWork hands a new block to the agent to be sealed, waiting until
+// it's accepted as the current work.
+func feedRemoteTestWork(t *testing.T, agent *RemoteAgent, packages chan [3]string, number int64) *types.Block {
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(number), Difficulty: big.NewInt(131072)})
+	agent.Work() <- &Work{Block: block}
+
+	select {
+	case work := <-packages:
+		if work[0] != block.HashNoNonce().Hex() {
+			t.Fatalf("work package mismatch: have %s, want %s", work[0], block.HashNoNonce().Hex())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("work package for block #%d not published", number)
+	}
+	return block
+}
+
+// Tests that solutions are accepted for recent work, while the ones for stale or
+// unknown work and invalid ones are rejected, tracking the statistics per miner.
+func TestRemoteAgentSubmitWork(t *testing.T) {
+	agent, packages, results := newRemoteTestAgent(11)
+	defer agent.Stop()
+
+	if _, err := agent.GetWork("a"); err == nil {
+		t.Fatalf("work handed out before any was available")
+	}
+	blocks := make([]*types.Block, 12)
+	for i := 1; i < len(blocks); i++ {
+		blocks[i] = feedRemoteTestWork(t, agent, packages, int64(i))
+	}
+	if work, err := agent.GetWork("a"); err != nil || work[0] != blocks[11].HashNoNonce().Hex() {
+		t.Fatalf("current work mismatch: have %v/%v, want %s", work, err, blocks[11].HashNoNonce().Hex())
+	}
+	// Work too old is rejected, work within the stale threshold accepted
+	if agent.SubmitWork("a", types.BlockNonce{}, blocks[1].MixDigest(), blocks[1].HashNoNonce()) {
+		t.Errorf("stale work accepted")
+	}
+	if !agent.SubmitWork("a", types.BlockNonce{}, blocks[4].MixDigest(), blocks[4].HashNoNonce()) {
+		t.Errorf("recent work rejected")
+	}
+	if result := <-results; result.Block.NumberU64() != 4 {
+		t.Errorf("sealed block mismatch: have #%d, want #4", result.Block.NumberU64())
+	}
+	// Solutions failing verification are rejected
+	if agent.SubmitWork("b", types.BlockNonce{}, blocks[11].MixDigest(), blocks[11].HashNoNonce()) {
+		t.Errorf("invalid work accepted")
+	}
+	agent.SubmitHashrate("b", 100)
+
+	miners := agent.Miners()
+	if len(miners) != 2 {
+		t.Fatalf("remote miner count mismatch: have %d, want 2", len(miners))
+	}
+	if a := miners[0]; a.ID != "a" || a.Work != 1 || a.Accepted != 1 || a.Stale != 1 || a.Invalid != 0 {
+		t.Errorf("miner a statistics mismatch: %+v", a)
+	}
+	if b := miners[1]; b.ID != "b" || b.Work != 0 || b.Accepted != 0 || b.Invalid != 1 || b.Hashrate != 100 {
+		t.Errorf("miner b statistics mismatch: %+v", b)
+	}
+	if rate := agent.GetHashRate(); rate != 100 {
+		t.Errorf("hash rate mismatch: have %d, want 100", rate)
+	}
+}
+
+// Tests that the number of individually tracked remote miners is capped, further
+// ones being tracked as anonymous.
+func TestRemoteAgentMinerLimit(t *testing.T) {
+	agent, _, _ := newRemoteTestAgent(0)
+	defer agent.Stop()
+
+	for i := 0; i < 2*maxRemoteMiners; i++ {
+		agent.SubmitHashrate(fmt.Sprintf("miner-%d", i), 1)
+	}
+	miners := agent.Miners()
+	if len(miners) != maxRemoteMiners+1 {
+		t.Fatalf("tracked miner count mismatch: have %d, want %d", len(miners), maxRemoteMiners+1)
+	}
+	if miners[0].ID != "" {
+		t.Errorf("excess miners not tracked as anonymous")
+	}
+}
@@ -0,0 +1,307 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// maxStratumRequestSize is the maximum size of a single request line accepted
+	// from a stratum client.
+	maxStratumRequestSize = 64 * 1024
+
+	// stratumWriteTimeout is the time a stratum client has to accept a message
+	// before it's considered stalled and disconnected.
+	stratumWriteTimeout = 10 * time.Second
+)
+
+// stratumRequest is a single line delimited JSON-RPC request of a stratum client.
+type stratumRequest struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Worker string          `json:"worker"` // Optional name of the mining rig
+}
+
+// stratumResponse is a reply to a request, or an unsolicited work notification
+// (with a zero id) to a logged in client.
+type stratumResponse struct {
+	ID      json.RawMessage `json:"id"`
+	Version string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result"`
+	Error   *stratumError   `json:"error,omitempty"`
+}
+
+// stratumError is the error returned to a stratum client if its request failed.
+type stratumError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+var (
+	errStratumMethod = &stratumError{-32601, "method not found"}
+	errStratumParams = &stratumError{-32602, "invalid params"}
+	errStratumLogin  = &stratumError{-32000, "not logged in"}
+)
+
+// StratumServer hands out the work of a remote agent to external mining farms over
+// persistent TCP connections, using the eth-proxy flavour of the stratum protocol.
+// Unlike polling eth_getWork over HTTP, logged in miners get new work pushed to them
+// as soon as it's available.
+type StratumServer struct {
+	agent    *RemoteAgent
+	listener net.Listener
+
+	conns map[net.Conn]struct{} // Currently connected clients, closed on shutdown
+	lock  sync.Mutex            // Protects the client set
+	wg    sync.WaitGroup        // Tracks the serving goroutines
+}
+
+// NewStratumServer starts serving the work of the remote agent on the given TCP
+// listening address.
+func NewStratumServer(agent *RemoteAgent, addr string) (*StratumServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	server := &StratumServer{
+		agent:    agent,
+		listener: listener,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	server.wg.Add(1)
+	go server.serve()
+
+	log.Info("Stratum work server started", "addr", listener.Addr())
+	return server, nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *StratumServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Close stops accepting new miners, disconnects the existing ones and waits for
+// all the serving goroutines to terminate.
+func (s *StratumServer) Close() {
+	s.listener.Close()
+
+	s.lock.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.lock.Unlock()
+
+	s.wg.Wait()
+	log.Info("Stratum work server stopped")
+}
+
+// serve accepts the incoming miner connections until the listener is closed.
+func (s *StratumServer) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.lock.Lock()
+		s.conns[conn] = struct{}{}
+		s.lock.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			newStratumConn(s.agent, conn).serve()
+
+			s.lock.Lock()
+			delete(s.conns, conn)
+			s.lock.Unlock()
+		}()
+	}
+}
+
+// stratumConn is a connection to a single stratum client.
+type stratumConn struct {
+	agent *RemoteAgent
+	conn  net.Conn
+	id    string // Identifier the miner logged in with, empty if not logged in
+
+	lock sync.Mutex // Serializes the replies and the pushed work on the connection
+}
+
+func newStratumConn(agent *RemoteAgent, conn net.Conn) *stratumConn {
+	return &stratumConn{agent: agent, conn: conn}
+}
+
+// serve processes the requests of the client until it disconnects or sends an
+// invalid request.
+func (c *stratumConn) serve() {
+	defer c.conn.Close()
+
+	logger := log.New("remote", c.conn.RemoteAddr())
+	logger.Debug("Stratum miner connected")
+	defer logger.Debug("Stratum miner disconnected")
+
+	// Push new work to the client once it logs in
+	quit := make(chan struct{})
+	defer close(quit)
+
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 4096), maxStratumRequestSize)
+
+	for scanner.Scan() {
+		var req stratumRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			logger.Debug("Invalid stratum request", "err", err)
+			return
+		}
+		result, serr := c.handle(&req, quit)
+		if err := c.send(&stratumResponse{ID: req.ID, Version: "2.0", Result: result, Error: serr}); err != nil {
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		logger.Debug("Stratum connection failed", "err", err)
+	}
+}
+
+// handle executes a single request of the client.
+func (c *stratumConn) handle(req *stratumRequest, quit chan struct{}) (interface{}, *stratumError) {
+	switch req.Method {
+	case "eth_submitLogin":
+		var params []string
+		if err := json.Unmarshal(req.Params, &params); err != nil || len(params) == 0 {
+			return nil, errStratumParams
+		}
+		if c.id != "" {
+			return true, nil
+		}
+		c.id = params[0]
+		if req.Worker != "" {
+			c.id += "." + req.Worker
+		}
+		workCh := make(chan [3]string, 1)
+		go c.push(c.agent.SubscribeWork(workCh), workCh, quit)
+		return true, nil
+
+	case "eth_getWork":
+		if c.id == "" {
+			return nil, errStratumLogin
+		}
+		work, err := c.agent.GetWork(c.id)
+		if err != nil {
+			return nil, &stratumError{-32000, err.Error()}
+		}
+		return work, nil
+
+	case "eth_submitWork":
+		if c.id == "" {
+			return nil, errStratumLogin
+		}
+		var params []json.RawMessage
+		if err := json.Unmarshal(req.Params, &params); err != nil || len(params) != 3 {
+			return nil, errStratumParams
+		}
+		var (
+			nonce     types.BlockNonce
+			hash, mix common.Hash
+		)
+		if json.Unmarshal(params[0], &nonce) != nil || json.Unmarshal(params[1], &hash) != nil || json.Unmarshal(params[2], &mix) != nil {
+			return nil, errStratumParams
+		}
+		return c.agent.SubmitWork(c.id, nonce, mix, hash), nil
+
+	case "eth_submitHashrate":
+		if c.id == "" {
+			return nil, errStratumLogin
+		}
+		var params []json.RawMessage
+		if err := json.Unmarshal(req.Params, &params); err != nil || len(params) == 0 {
+			return nil, errStratumParams
+		}
+		var rate hexutil.Uint64
+		if err := json.Unmarshal(params[0], &rate); err != nil {
+			return nil, errStratumParams
+		}
+		c.agent.SubmitHashrate(c.id, uint64(rate))
+		return true, nil
+	}
+	return nil, errStratumMethod
+}
+
+// push sends the work packages of new work to the client until the connection
+// is torn down. The work feed is never blocked by the client: packages not yet
+// written out are superseded by newer ones.
+func (c *stratumConn) push(sub event.Subscription, workCh chan [3]string, quit chan struct{}) {
+	defer sub.Unsubscribe()
+
+	latest := make(chan [3]string, 1)
+	go func() {
+		for {
+			select {
+			case work := <-latest:
+				if err := c.send(&stratumResponse{ID: json.RawMessage("0"), Version: "2.0", Result: work}); err != nil {
+					c.conn.Close()
+					return
+				}
+			case <-quit:
+				return
+			}
+		}
+	}()
+	for {
+		select {
+		case work := <-workCh:
+			select {
+			case <-latest:
+			default:
+			}
+			latest <- work
+		case <-sub.Err():
+			return
+		case <-quit:
+			return
+		}
+	}
+}
+
+// send writes a single line delimited message to the client, failing if the client
+// doesn't accept it in time.
+func (c *stratumConn) send(msg *stratumResponse) error {
+	blob, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(stratumWriteTimeout))
+	_, err = c.conn.Write(append(blob, '\n'))
+	return err
+}
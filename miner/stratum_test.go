@@ -0,0 +1,158 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// stratumTestClient is a line delimited JSON-RPC client talking to a stratum server.
+type stratumTestClient struct {
+	conn    net.Conn
+	scanner *bufio.Scanner
+}
+
+// call sends a request to the server, returning the next message received.
+func (c *stratumTestClient) call(t *testing.T, id int, method string, params ...interface{}) *stratumTestResponse {
+	req := fmt.Sprintf(`{"id":%d,"method":%q,"params":%s,"worker":"rig"}`+"\n", id, method, toJSON(params))
+	if _, err := c.conn.Write([]byte(req)); err != nil {
+		t.Fatalf("failed to send %s: %v", method, err)
+	}
+	return c.read(t)
+}
+
+// read waits for the next message from the server.
+func (c *stratumTestClient) read(t *testing.T) *stratumTestResponse {
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	if !c.scanner.Scan() {
+		t.Fatalf("failed to read response: %v", c.scanner.Err())
+	}
+	res := new(stratumTestResponse)
+	if err := json.Unmarshal(c.scanner.Bytes(), res); err != nil {
+		t.Fatalf("invalid response %s: %v", c.scanner.Bytes(), err)
+	}
+	return res
+}
+
+type stratumTestResponse struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *stratumError   `json:"error"`
+}
+
+func toJSON(v interface{}) string {
+	blob, _ := json.Marshal(v)
+	return string(blob)
+}
+
+// Tests that stratum miners have to log in, get new work pushed and can submit
+// their solutions and hash rates.
+func TestStratumServer(t *testing.T) {
+	agent, packages, results := newRemoteTestAgent(0)
+	defer agent.Stop()
+
+	server, err := NewStratumServer(agent, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start stratum server: %v", err)
+	}
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to connect to stratum server: %v", err)
+	}
+	defer conn.Close()
+	client := &stratumTestClient{conn: conn, scanner: bufio.NewScanner(conn)}
+
+	// Requests are rejected until logged in
+	if res := client.call(t, 1, "eth_getWork"); res.ID != 1 || res.Error == nil || res.Error.Code != errStratumLogin.Code {
+		t.Fatalf("work handed out before login: %+v", res)
+	}
+	if res := client.call(t, 2, "eth_unknown"); res.Error == nil || res.Error.Code != errStratumMethod.Code {
+		t.Fatalf("unknown method accepted: %+v", res)
+	}
+	if res := client.call(t, 3, "eth_submitLogin", "0xwallet"); res.ID != 3 || string(res.Result) != "true" {
+		t.Fatalf("login failed: %+v", res)
+	}
+	// New work is pushed to the logged in miner
+	block := feedRemoteTestWork(t, agent, packages, 1)
+
+	push := client.read(t)
+	if want := toJSON(workPackage(block)); push.ID != 0 || string(push.Result) != want {
+		t.Fatalf("pushed work mismatch: have %s, want %s", push.Result, want)
+	}
+	if res := client.call(t, 4, "eth_getWork"); string(res.Result) != string(push.Result) {
+		t.Fatalf("fetched work mismatch: have %s, want %s", res.Result, push.Result)
+	}
+	// Solutions and hash rates are accepted and accounted to the miner
+	if res := client.call(t, 5, "eth_submitHashrate", "0x64", block.HashNoNonce()); string(res.Result) != "true" {
+		t.Fatalf("hash rate submission failed: %+v", res)
+	}
+	if res := client.call(t, 6, "eth_submitWork", types.BlockNonce{1}, block.HashNoNonce(), block.MixDigest()); string(res.Result) != "true" {
+		t.Fatalf("work submission failed: %+v", res)
+	}
+	if result := <-results; result.Block.Nonce() != (types.BlockNonce{1}).Uint64() {
+		t.Errorf("sealed nonce mismatch: have %x, want %x", result.Block.Nonce(), types.BlockNonce{1})
+	}
+	if res := client.call(t, 7, "eth_submitWork", "0x00", block.HashNoNonce()); res.Error == nil || res.Error.Code != errStratumParams.Code {
+		t.Fatalf("malformed submission accepted: %+v", res)
+	}
+	miners := agent.Miners()
+	if len(miners) != 1 || miners[0].ID != "0xwallet.rig" || miners[0].Work != 1 || miners[0].Accepted != 1 || miners[0].Hashrate != 100 {
+		t.Fatalf("remote miner statistics mismatch: %+v", miners)
+	}
+}
+
+// Tests that stratum miners not reading their connection can't stall the sealing
+// of new work.
+func TestStratumSlowClient(t *testing.T) {
+	agent, packages, _ := newRemoteTestAgent(0)
+	defer agent.Stop()
+
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	conn := newStratumConn(agent, server)
+	quit := make(chan struct{})
+	defer close(quit)
+
+	if _, err := conn.handle(&stratumRequest{Method: "eth_submitLogin", Params: json.RawMessage(`["0xwallet"]`)}, quit); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	// Nothing is read from the client side of the pipe, so pushes never complete
+	for i := int64(1); i <= 5; i++ {
+		select {
+		case agent.Work() <- &Work{Block: types.NewBlockWithHeader(&types.Header{Number: big.NewInt(i), Difficulty: big.NewInt(131072)})}:
+		case <-time.After(time.Second):
+			t.Fatalf("work #%d not accepted, agent stalled", i)
+		}
+		select {
+		case <-packages:
+		case <-time.After(time.Second):
+			t.Fatalf("work #%d not published, agent stalled", i)
+		}
+	}
+}
@@ -120,6 +120,12 @@ type worker struct {
 	uncleMu        sync.Mutex
 	possibleUncles map[common.Hash]*types.Block
 
+	policyMu sync.RWMutex
+	policy   Policy // Block assembly rules to follow
+
+	arrivalMu sync.Mutex
+	arrivals  map[common.Hash]time.Time // First seen times of the pending transactions
+
 	unconfirmed *unconfirmedBlocks // set of locally mined blocks pending canonicalness confirmations
 
 	// atomic status counters
@@ -141,6 +147,8 @@ func newWorker(config *params.ChainConfig, engine consensus.Engine, coinbase com
 		chain:          eth.BlockChain(),
 		proc:           eth.BlockChain().Validator(),
 		possibleUncles: make(map[common.Hash]*types.Block),
+		policy:         DefaultPolicy,
+		arrivals:       make(map[common.Hash]time.Time),
 		coinbase:       coinbase,
 		agents:         make(map[Agent]struct{}),
 		unconfirmed:    newUnconfirmedBlocks(eth.BlockChain(), miningLogAtDepth),
@@ -170,6 +178,22 @@ func (self *worker) setExtra(extra []byte) {
 	self.extra = extra
 }
 
+func (self *worker) setPolicy(policy Policy) error {
+	if err := policy.validate(); err != nil {
+		return err
+	}
+	self.policyMu.Lock()
+	defer self.policyMu.Unlock()
+	self.policy = policy
+	return nil
+}
+
+func (self *worker) getPolicy() Policy {
+	self.policyMu.RLock()
+	defer self.policyMu.RUnlock()
+	return self.policy
+}
+
 func (self *worker) pending() (*types.Block, *state.StateDB) {
 	self.currentMu.Lock()
 	defer self.currentMu.Unlock()
@@ -260,14 +284,23 @@ func (self *worker) update() {
 
 		// Handle TxPreEvent
 		case ev := <-self.txCh:
+			// Track the arrival time for FIFO transaction ordering
+			self.arrivalMu.Lock()
+			if _, ok := self.arrivals[ev.Tx.Hash()]; !ok {
+				self.arrivals[ev.Tx.Hash()] = time.Now()
+			}
+			self.arrivalMu.Unlock()
+
 			// Apply transaction to the pending state if we're not mining
 			if atomic.LoadInt32(&self.mining) == 0 {
+				policy := self.getPolicy()
+
 				self.currentMu.Lock()
 				acc, _ := types.Sender(self.current.signer, ev.Tx)
 				txs := map[common.Address]types.Transactions{acc: {ev.Tx}}
 				txset := types.NewTransactionsByPriceAndNonce(self.current.signer, txs)
 
-				self.current.commitTransactions(self.mux, txset, self.chain, self.coinbase)
+				self.current.commitTransactions(self.mux, txset, self.chain, self.coinbase, policy.MinGasPrice)
 				self.currentMu.Unlock()
 			} else {
 				// If we're mining, but nothing is being processed, wake on new transactions
@@ -354,6 +387,33 @@ func (self *worker) push(work *Work) {
 	}
 }
 
+// sortedPending orders the pending transactions according to the given strategy,
+// also dropping the arrival times of transactions no longer pending.
+func (self *worker) sortedPending(ordering TxOrdering, signer types.Signer, pending map[common.Address]types.Transactions) txSource {
+	self.arrivalMu.Lock()
+	live := make(map[common.Hash]time.Time, len(self.arrivals))
+	for _, txs := range pending {
+		for _, tx := range txs {
+			if seen, ok := self.arrivals[tx.Hash()]; ok {
+				live[tx.Hash()] = seen
+			}
+		}
+	}
+	self.arrivals = live
+
+	// The tracked arrivals keep changing, give the ordering its own copy
+	var arrivals map[common.Hash]time.Time
+	if ordering == OrderFIFO {
+		arrivals = make(map[common.Hash]time.Time, len(live))
+		for hash, seen := range live {
+			arrivals[hash] = seen
+		}
+	}
+	self.arrivalMu.Unlock()
+
+	return newTxSource(ordering, signer, pending, arrivals)
+}
+
 // makeCurrent creates a new environment for the current cycle.
 func (self *worker) makeCurrent(parent *types.Block, header *types.Header) error {
 	state, err := self.chain.StateAt(parent.Root())
@@ -454,8 +514,10 @@ func (self *worker) commitNewWork() {
 		log.Error("Failed to fetch pending transactions", "err", err)
 		return
 	}
-	txs := types.NewTransactionsByPriceAndNonce(self.current.signer, pending)
-	work.commitTransactions(self.mux, txs, self.chain, self.coinbase)
+	policy := self.getPolicy()
+
+	txs := self.sortedPending(policy.TxOrdering, self.current.signer, pending)
+	work.commitTransactions(self.mux, txs, self.chain, self.coinbase, policy.MinGasPrice)
 
 	// compute uncles for the new block.
 	var (
@@ -463,10 +525,10 @@ func (self *worker) commitNewWork() {
 		badUncles []common.Hash
 	)
 	for hash, uncle := range self.possibleUncles {
-		if len(uncles) == 2 {
+		if len(uncles) == 2 || !policy.IncludeUncles {
 			break
 		}
-		if err := self.commitUncle(work, uncle.Header()); err != nil {
+		if err := self.commitUncle(work, uncle.Header(), policy.MaxUncleDepth); err != nil {
 			log.Trace("Bad uncle found and will be removed", "hash", hash)
 			log.Trace(fmt.Sprint(uncle))
 
@@ -492,11 +554,14 @@ func (self *worker) commitNewWork() {
 	self.push(work)
 }
 
-func (self *worker) commitUncle(work *Work, uncle *types.Header) error {
+func (self *worker) commitUncle(work *Work, uncle *types.Header, maxDepth int) error {
 	hash := uncle.Hash()
 	if work.uncles.Has(hash) {
 		return fmt.Errorf("uncle not unique")
 	}
+	if depth := new(big.Int).Sub(work.header.Number, uncle.Number); depth.Cmp(big.NewInt(int64(maxDepth))) > 0 {
+		return fmt.Errorf("uncle too deep (%v > %d)", depth, maxDepth)
+	}
 	if !work.ancestors.Has(uncle.ParentHash) {
 		return fmt.Errorf("uncle's parent unknown (%x)", uncle.ParentHash[0:4])
 	}
@@ -507,7 +572,7 @@ func (self *worker) commitUncle(work *Work, uncle *types.Header) error {
 	return nil
 }
 
-func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsByPriceAndNonce, bc *core.BlockChain, coinbase common.Address) {
+func (env *Work) commitTransactions(mux *event.TypeMux, txs txSource, bc *core.BlockChain, coinbase common.Address, minGasPrice *big.Int) {
 	gp := new(core.GasPool).AddGas(env.header.GasLimit)
 
 	var coalescedLogs []*types.Log
@@ -531,6 +596,14 @@ func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsB
 			txs.Pop()
 			continue
 		}
+		// Skip the account if the transaction doesn't pay enough, the subsequent
+		// ones of the same account can't be included without it.
+		if minGasPrice != nil && tx.GasPrice().Cmp(minGasPrice) < 0 {
+			log.Trace("Skipping underpriced transaction", "hash", tx.Hash(), "price", tx.GasPrice(), "min", minGasPrice)
+
+			txs.Pop()
+			continue
+		}
 		// Start executing the transaction
 		env.state.Prepare(tx.Hash(), common.Hash{}, env.tcount)
 
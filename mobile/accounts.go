@@ -93,6 +93,13 @@ func NewKeyStore(keydir string, scryptN, scryptP int) *KeyStore {
 	return &KeyStore{keystore: keystore.NewKeyStore(keydir, scryptN, scryptP)}
 }
 
+// CalibrateScryptN returns the highest scrypt N parameter between LightScryptN and
+// StandardScryptN for which a key derivation with the given P parameter takes at
+// most the given number of milliseconds on the device.
+func CalibrateScryptN(limitMillis int64, scryptP int) (int, error) {
+	return keystore.CalibrateScryptN(time.Duration(limitMillis)*time.Millisecond, scryptP)
+}
+
 // HasAddress reports whether a key with the given address is present.
 func (ks *KeyStore) HasAddress(address *Address) bool {
 	return ks.keystore.HasAddress(address.address)
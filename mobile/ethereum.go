@@ -21,6 +21,7 @@ package geth
 import (
 	"errors"
 	"math/big"
+	"time"
 
 	ethereum "github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
@@ -84,6 +85,13 @@ func (p *SyncProgress) GetHighestBlock() int64  { return int64(p.progress.Highes
 func (p *SyncProgress) GetPulledStates() int64  { return int64(p.progress.PulledStates) }
 func (p *SyncProgress) GetKnownStates() int64   { return int64(p.progress.KnownStates) }
 
+func (p *SyncProgress) GetPulledHeaders() int64  { return int64(p.progress.PulledHeaders) }
+func (p *SyncProgress) GetPulledBodies() int64   { return int64(p.progress.PulledBodies) }
+func (p *SyncProgress) GetPulledReceipts() int64 { return int64(p.progress.PulledReceipts) }
+
+// GetEstimatedTime returns the estimated number of seconds until the sync completes.
+func (p *SyncProgress) GetEstimatedTime() int64 { return int64(p.progress.EstimatedTime / time.Second) }
+
 // Topics is a set of topic lists to filter events with.
 type Topics struct{ topics [][]common.Hash }
 
@@ -18,14 +18,18 @@ package node
 
 import (
 	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/external"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/accounts/usbwallet"
 	"github.com/ethereum/go-ethereum/common"
@@ -37,6 +41,8 @@ import (
 
 const (
 	datadirPrivateKey      = "nodekey"            // Path within the datadir to the node's private key
+	datadirAuditKey        = "auditkey"           // Path within the datadir to the signing audit log key
+	datadirSigningSpent    = "signing-spent.json" // Path within the datadir to the daily spending of the signing rules
 	datadirDefaultKeyStore = "keystore"           // Path within the datadir to the keystore
 	datadirStaticNodes     = "static-nodes.json"  // Path within the datadir to the static node list
 	datadirTrustedNodes    = "trusted-nodes.json" // Path within the datadir to the trusted node list
@@ -66,6 +72,11 @@ type Config struct {
 	// in memory.
 	DataDir string
 
+	// DBEngine is the database engine used for the databases created within the
+	// data directory (see ethdb.Engines). If empty, existing databases are opened
+	// with the engine they were created with and new ones use LevelDB.
+	DBEngine string `toml:",omitempty"`
+
 	// Configuration of peer-to-peer networking.
 	P2P p2p.Config
 
@@ -82,9 +93,27 @@ type Config struct {
 	// scrypt KDF at the expense of security.
 	UseLightweightKDF bool `toml:",omitempty"`
 
+	// KeyStoreScryptN and KeyStoreScryptP override the scrypt parameters used to
+	// encrypt new key files, allowing to tune the key derivation cost to the
+	// resources of the device. Zero values keep the defaults.
+	KeyStoreScryptN int `toml:",omitempty"`
+	KeyStoreScryptP int `toml:",omitempty"`
+
 	// NoUSB disables hardware wallet monitoring and connectivity.
 	NoUSB bool `toml:",omitempty"`
 
+	// ExternalSigner is the IPC endpoint of an external signer process to forward
+	// signing requests to, so that keys don't need to be loaded into the node.
+	ExternalSigner string `toml:",omitempty"`
+
+	// SignAuditLog is the file to record all signing operations performed with the
+	// managed accounts into. Relative paths are placed inside the data directory.
+	SignAuditLog string `toml:",omitempty"`
+
+	// SigningRules is a JSON file with the rules transactions must satisfy to be
+	// signed without interactive approval.
+	SigningRules string `toml:",omitempty"`
+
 	// IPCPath is the requested location to place the IPC endpoint. If the path is
 	// a simple file name, it is placed inside the data directory (or on the root
 	// pipe path on Windows), whereas if it's a resolvable path name (absolute or
@@ -135,6 +164,24 @@ type Config struct {
 	// *WARNING* Only set this if the node is running in a trusted network, exposing
 	// private APIs to untrusted users is a major security risk.
 	WSExposeAll bool `toml:",omitempty"`
+
+	// Plugins are the names of the registered service plugins to run. They are
+	// started in the given order, after the services registered on the node.
+	Plugins []string `toml:",omitempty"`
+
+	// PluginFiles are Go plugins (built with -buildmode=plugin) to load when the
+	// node is created, registering additional service plugins. Relative paths are
+	// resolved against the current directory.
+	PluginFiles []string `toml:",omitempty"`
+
+	// ShutdownTimeout is the maximum time stopping the node may take, including
+	// draining the RPC requests in flight and stopping the services. Services still
+	// running at the deadline are abandoned. Zero means no limit.
+	ShutdownTimeout time.Duration `toml:",omitempty"`
+
+	// ServiceStopTimeout is the maximum time a single service may take to stop
+	// before the shutdown moves on to the next one. Zero means no limit.
+	ServiceStopTimeout time.Duration `toml:",omitempty"`
 }
 
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
@@ -318,6 +365,28 @@ func (c *Config) NodeKey() *ecdsa.PrivateKey {
 	return key
 }
 
+// auditKey retrieves the secret key authenticating the signing audit log, or
+// generates and stores a new one if none exists yet.
+func (c *Config) auditKey() ([]byte, error) {
+	keyfile := c.resolvePath(datadirAuditKey)
+	if blob, err := ioutil.ReadFile(keyfile); err == nil {
+		return hex.DecodeString(strings.TrimSpace(string(blob)))
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(keyfile), 0700); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(keyfile, []byte(hex.EncodeToString(key)), 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
 // StaticNodes returns a list of node enode URLs configured as static nodes.
 func (c *Config) StaticNodes() []*discover.Node {
 	return c.parsePersistentNodes(c.resolvePath(datadirStaticNodes))
@@ -368,6 +437,18 @@ func (c *Config) AccountConfig() (int, int, string, error) {
 		scryptN = keystore.LightScryptN
 		scryptP = keystore.LightScryptP
 	}
+	if c.KeyStoreScryptN != 0 {
+		if c.KeyStoreScryptN < 2 || c.KeyStoreScryptN&(c.KeyStoreScryptN-1) != 0 {
+			return 0, 0, "", fmt.Errorf("invalid scrypt N %d: must be a power of two above 1", c.KeyStoreScryptN)
+		}
+		scryptN = c.KeyStoreScryptN
+	}
+	if c.KeyStoreScryptP < 0 {
+		return 0, 0, "", fmt.Errorf("invalid scrypt P %d", c.KeyStoreScryptP)
+	}
+	if c.KeyStoreScryptP != 0 {
+		scryptP = c.KeyStoreScryptP
+	}
 
 	var (
 		keydir string
@@ -421,5 +502,43 @@ func makeAccountManager(conf *Config) (*accounts.Manager, string, error) {
 			backends = append(backends, trezorhub)
 		}
 	}
-	return accounts.NewManager(backends...), ephemeral, nil
+	if conf.ExternalSigner != "" {
+		// Connect to the external signer, refusing to run without it as the user
+		// explicitly requested signing to be done out of process
+		signer, err := external.NewBackend(conf.ExternalSigner, conf.NodeName())
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to connect to external signer %q: %v", conf.ExternalSigner, err)
+		}
+		backends = append(backends, signer)
+	}
+	am := accounts.NewManager(backends...)
+
+	if conf.SignAuditLog != "" {
+		// Open the audit log, refusing to run if it was tampered with
+		path := conf.resolvePath(conf.SignAuditLog)
+		if path == "" {
+			return nil, "", fmt.Errorf("signing audit log %q needs a data directory", conf.SignAuditLog)
+		}
+		key, err := conf.auditKey()
+		if err != nil {
+			return nil, "", err
+		}
+		audit, err := accounts.OpenAuditLog(path, key)
+		if err != nil {
+			return nil, "", err
+		}
+		am.SetAuditLog(audit)
+	}
+	if conf.SigningRules != "" {
+		rules, err := accounts.LoadSigningRules(conf.SigningRules)
+		if err != nil {
+			return nil, "", err
+		}
+		guard, err := accounts.NewSigningGuard(rules, conf.resolvePath(datadirSigningSpent))
+		if err != nil {
+			return nil, "", err
+		}
+		am.SetSigningGuard(guard)
+	}
+	return am, ephemeral, nil
 }
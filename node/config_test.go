@@ -24,6 +24,7 @@ import (
 	"runtime"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/p2p"
 )
@@ -148,3 +149,51 @@ func TestNodeKeyPersistency(t *testing.T) {
 		t.Fatalf("ephemeral node key persisted to disk")
 	}
 }
+
+// Tests that the scrypt parameters of new keys can be overridden, and that invalid
+// ones are rejected.
+func TestAccountConfigScrypt(t *testing.T) {
+	tests := []struct {
+		config Config
+		n, p   int
+		fail   bool
+	}{
+		{config: Config{}, n: keystore.StandardScryptN, p: keystore.StandardScryptP},
+		{config: Config{UseLightweightKDF: true}, n: keystore.LightScryptN, p: keystore.LightScryptP},
+		{config: Config{KeyStoreScryptN: 1 << 14}, n: 1 << 14, p: keystore.StandardScryptP},
+		{config: Config{UseLightweightKDF: true, KeyStoreScryptP: 2}, n: keystore.LightScryptN, p: 2},
+		{config: Config{KeyStoreScryptN: 1000}, fail: true},
+		{config: Config{KeyStoreScryptP: -1}, fail: true},
+	}
+	for i, tt := range tests {
+		n, p, _, err := tt.config.AccountConfig()
+		if tt.fail {
+			if err == nil {
+				t.Errorf("test %d: invalid parameters accepted", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: failed to assemble config: %v", i, err)
+			continue
+		}
+		if n != tt.n || p != tt.p {
+			t.Errorf("test %d: scrypt parameters mismatch: have %d/%d, want %d/%d", i, n, p, tt.n, tt.p)
+		}
+	}
+}
+
+// Tests that a node configured with an external signer refuses to start if the
+// signer can't be reached.
+func TestExternalSignerUnavailable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := &Config{DataDir: dir, NoUSB: true, ExternalSigner: filepath.Join(dir, "signer.ipc")}
+	if _, err := New(conf); err == nil {
+		t.Fatalf("node created without a reachable external signer")
+	}
+}
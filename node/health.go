@@ -0,0 +1,102 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
+)
+
+// HealthReporter is an optional interface services can implement to take part in
+// the node's health and readiness reports.
+type HealthReporter interface {
+	// Health returns the service specific health details, and whether the service
+	// is ready to serve requests.
+	Health() (status interface{}, ready bool)
+}
+
+// HealthReport is the health of a node, served on the /health and /ready paths of
+// the HTTP endpoint for load balancers and orchestration probes.
+type HealthReport struct {
+	Running   bool                      `json:"running"`   // Whether the node is running (liveness)
+	Ready     bool                      `json:"ready"`     // Whether all services are ready (readiness)
+	Peers     int                       `json:"peers"`     // Number of connected peers
+	MaxPeers  int                       `json:"maxPeers"`  // Maximum number of peers
+	Discovery bool                      `json:"discovery"` // Whether peer discovery is running
+	Services  map[string]*ServiceHealth `json:"services"`  // Health of the individual services
+}
+
+// ServiceHealth is the health of a single service running on a node.
+type ServiceHealth struct {
+	Ready  bool        `json:"ready"`
+	Status interface{} `json:"status,omitempty"` // Service specific details
+}
+
+// Health reports on the health of the node and its services. Services that don't
+// implement HealthReporter are considered ready as long as they are running.
+func (n *Node) Health() *HealthReport {
+	report := &HealthReport{Services: make(map[string]*ServiceHealth)}
+	if atomic.LoadInt32(&n.stopping) != 0 {
+		return report
+	}
+	n.healthLock.RLock()
+	defer n.healthLock.RUnlock()
+
+	if n.server == nil {
+		return report
+	}
+	report.Running, report.Ready = true, true
+	report.Peers = n.server.PeerCount()
+	report.MaxPeers = n.server.MaxPeers
+	report.Discovery = !n.server.NoDiscovery || n.server.DiscoveryV5
+
+	for kind, service := range n.services {
+		health := &ServiceHealth{Ready: true}
+		if reporter, ok := service.(HealthReporter); ok {
+			health.Status, health.Ready = reporter.Health()
+		}
+		report.Services[serviceName(kind)] = health
+		report.Ready = report.Ready && health.Ready
+	}
+	return report
+}
+
+// serviceName returns the name a service is reported under, its package qualified
+// type name.
+func serviceName(kind reflect.Type) string {
+	return strings.TrimPrefix(kind.String(), "*")
+}
+
+// healthHandler serves the node's health report, failing with 503 Service
+// Unavailable if the node isn't running, or if readiness is requested and not all
+// services are ready.
+func (n *Node) healthHandler(readiness bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := n.Health()
+
+		code := http.StatusOK
+		if !report.Running || (readiness && !report.Ready) {
+			code = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(report)
+	}
+}
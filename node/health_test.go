@@ -0,0 +1,138 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// healthService is a service reporting a configurable readiness.
+type healthService struct {
+	NoopService
+	ready bool
+}
+
+func (s *healthService) Health() (interface{}, bool) { return "status", s.ready }
+
+// Tests that the health and readiness endpoints report the state of the node and
+// its services.
+func TestHealthEndpoints(t *testing.T) {
+	config := testNodeConfig()
+	config.HTTPHost, config.HTTPPort = "127.0.0.1", 0
+
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	service := new(healthService)
+	if err := stack.Register(func(*ServiceContext) (Service, error) { return service, nil }); err != nil {
+		t.Fatalf("health service registration failed: %v", err)
+	}
+	if err := stack.Register(NewNoopService); err != nil {
+		t.Fatalf("noop service registration failed: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	defer stack.Stop()
+
+	url := "http://" + stack.httpListener.Addr().String()
+	check := func(path string, code int, ready bool) {
+		res, err := http.Get(url + path)
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", path, err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != code {
+			t.Errorf("%s: status code mismatch: have %d, want %d", path, res.StatusCode, code)
+		}
+		var report HealthReport
+		if err := json.NewDecoder(res.Body).Decode(&report); err != nil {
+			t.Fatalf("%s: failed to decode report: %v", path, err)
+		}
+		if !report.Running || report.Ready != ready {
+			t.Errorf("%s: report mismatch: running %v, ready %v", path, report.Running, report.Ready)
+		}
+		if health := report.Services["node.healthService"]; health == nil || health.Ready != ready || health.Status != "status" {
+			t.Errorf("%s: service health mismatch: %+v", path, health)
+		}
+		if health := report.Services["node.NoopService"]; health == nil || !health.Ready {
+			t.Errorf("%s: noop service health mismatch: %+v", path, health)
+		}
+	}
+	// The node is alive but not ready until all services are
+	check("/health", http.StatusOK, false)
+	check("/ready", http.StatusServiceUnavailable, false)
+
+	service.ready = true
+	check("/ready", http.StatusOK, true)
+}
+
+// Tests that health checks don't block while the node is shutting down.
+func TestHealthDuringStop(t *testing.T) {
+	stack, err := New(testNodeConfig())
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	stopping, release := make(chan struct{}), make(chan struct{})
+	constructor := func(*ServiceContext) (Service, error) {
+		return &InstrumentedService{stopHook: func() {
+			close(stopping)
+			<-release
+		}}, nil
+	}
+	if err := stack.Register(constructor); err != nil {
+		t.Fatalf("service registration failed: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	if report := stack.Health(); !report.Running {
+		t.Fatalf("running node reported as stopped")
+	}
+	errc := make(chan error)
+	go func() { errc <- stack.Stop() }()
+	<-stopping
+
+	reports := make(chan *HealthReport, 1)
+	go func() { reports <- stack.Health() }()
+	select {
+	case report := <-reports:
+		if report.Running {
+			t.Errorf("stopping node reported as running")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("health check blocked by the shutdown")
+	}
+	// Checks racing with the start of the shutdown mustn't wait for it either
+	atomic.StoreInt32(&stack.stopping, 0)
+	go func() { reports <- stack.Health() }()
+	select {
+	case <-reports:
+	case <-time.After(time.Second):
+		t.Errorf("health check blocked by the shutdown")
+	}
+	close(release)
+	if err := <-errc; err != nil {
+		t.Fatalf("failed to stop protocol stack: %v", err)
+	}
+}
@@ -20,11 +20,14 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/ethdb"
@@ -36,6 +39,10 @@ import (
 	"github.com/prometheus/prometheus/util/flock"
 )
 
+// rpcDrainTimeout is the maximum time the API requests in flight are given to
+// finish when the node is stopped.
+const rpcDrainTimeout = 5 * time.Second
+
 // Node is a container on which services can be registered.
 type Node struct {
 	eventmux *event.TypeMux // Event multiplexer used between the services of a stack
@@ -49,7 +56,9 @@ type Node struct {
 	server       *p2p.Server // Currently running P2P networking layer
 
 	serviceFuncs []ServiceConstructor     // Service constructors (in dependency order)
+	pluginFuncs  []ServiceConstructor     // Constructors of the configured service plugins
 	services     map[reflect.Type]Service // Currently running services
+	serviceOrder []reflect.Type           // Types of the running services in construction order
 
 	rpcAPIs       []rpc.API   // List of APIs currently provided by the node
 	inprocHandler *rpc.Server // In-process RPC request handler to process the API requests
@@ -67,8 +76,11 @@ type Node struct {
 	wsListener net.Listener // Websocket RPC listener socket to server API requests
 	wsHandler  *rpc.Server  // Websocket RPC request handler to process the API requests
 
-	stop chan struct{} // Channel to wait for termination notifications
-	lock sync.RWMutex
+	stop     chan struct{} // Channel to wait for termination notifications
+	stopping int32         // Set while the node is shutting down, failing health checks
+	lock     sync.RWMutex
+
+	healthLock sync.RWMutex // Protects the server and services for health checks, which mustn't wait for lock
 }
 
 // New creates a new P2P node, ready for protocol registration.
@@ -95,6 +107,16 @@ func New(conf *Config) (*Node, error) {
 	if strings.HasSuffix(conf.Name, ".ipc") {
 		return nil, errors.New(`Config.Name cannot end in ".ipc"`)
 	}
+	// Load the plugin files and resolve the service plugins to run
+	for _, path := range conf.PluginFiles {
+		if err := loadPluginFile(path); err != nil {
+			return nil, fmt.Errorf("failed to load plugin file %s: %v", path, err)
+		}
+	}
+	pluginFuncs, err := pluginConstructors(conf.Plugins)
+	if err != nil {
+		return nil, err
+	}
 	// Ensure that the AccountManager method works before the node has started.
 	// We rely on this in cmd/geth.
 	am, ephemeralKeystore, err := makeAccountManager(conf)
@@ -108,6 +130,7 @@ func New(conf *Config) (*Node, error) {
 		ephemeralKeystore: ephemeralKeystore,
 		config:            conf,
 		serviceFuncs:      []ServiceConstructor{},
+		pluginFuncs:       pluginFuncs,
 		ipcEndpoint:       conf.IPCEndpoint(),
 		httpEndpoint:      conf.HTTPEndpoint(),
 		wsEndpoint:        conf.WSEndpoint(),
@@ -159,8 +182,13 @@ func (n *Node) Start() error {
 	log.Info("Starting peer-to-peer node", "instance", n.serverConfig.Name)
 
 	// Otherwise copy and specialize the P2P configuration
+	constructors := make([]ServiceConstructor, 0, len(n.serviceFuncs)+len(n.pluginFuncs))
+	constructors = append(constructors, n.serviceFuncs...)
+	constructors = append(constructors, n.pluginFuncs...)
+
 	services := make(map[reflect.Type]Service)
-	for _, constructor := range n.serviceFuncs {
+	order := make([]reflect.Type, 0, len(constructors))
+	for _, constructor := range constructors {
 		// Create a new context for the particular service
 		ctx := &ServiceContext{
 			config:         n.config,
@@ -181,6 +209,7 @@ func (n *Node) Start() error {
 			return &DuplicateServiceError{Kind: kind}
 		}
 		services[kind] = service
+		order = append(order, kind)
 	}
 	// Gather the protocols and start the freshly assembled P2P server
 	for _, service := range services {
@@ -213,8 +242,12 @@ func (n *Node) Start() error {
 		return err
 	}
 	// Finish initializing the startup
+	n.healthLock.Lock()
 	n.services = services
+	n.serviceOrder = order
 	n.server = running
+	n.healthLock.Unlock()
+
 	n.stop = make(chan struct{})
 
 	return nil
@@ -386,7 +419,12 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 	if listener, err = net.Listen("tcp", endpoint); err != nil {
 		return err
 	}
-	go rpc.NewHTTPServer(cors, handler).Serve(listener)
+	mux := http.NewServeMux()
+	mux.Handle("/", rpc.NewHTTPServer(cors, handler).Handler)
+	mux.Handle("/health", n.healthHandler(false))
+	mux.Handle("/ready", n.healthHandler(true))
+
+	go (&http.Server{Handler: mux}).Serve(listener)
 	log.Info(fmt.Sprintf("HTTP endpoint opened: http://%s", endpoint))
 
 	// All listeners booted successfully
@@ -468,6 +506,11 @@ func (n *Node) stopWS() {
 // Stop terminates a running node along with all it's services. In the node was
 // not started, an error is returned.
 func (n *Node) Stop() error {
+	// Fail the health checks before waiting for the lock, they'd block on it for
+	// the whole shutdown otherwise
+	atomic.StoreInt32(&n.stopping, 1)
+	defer atomic.StoreInt32(&n.stopping, 0)
+
 	n.lock.Lock()
 	defer n.lock.Unlock()
 
@@ -476,22 +519,36 @@ func (n *Node) Stop() error {
 		return ErrNodeStopped
 	}
 
-	// Terminate the API, services and the p2p server.
+	var deadline time.Time
+	if n.config.ShutdownTimeout > 0 {
+		deadline = time.Now().Add(n.config.ShutdownTimeout)
+	}
+	// Reject new API requests and let the ones in flight finish, so they don't race
+	// with the services shutting down, then terminate the API.
+	n.drainRPC(deadline)
 	n.stopWS()
 	n.stopHTTP()
 	n.stopIPC()
 	n.rpcAPIs = nil
+
+	// Terminate the services in reverse construction order, so that none of them
+	// outlives the services it depends on, and lastly the p2p server.
 	failure := &StopError{
 		Services: make(map[reflect.Type]error),
 	}
-	for kind, service := range n.services {
-		if err := service.Stop(); err != nil {
+	for i := len(n.serviceOrder) - 1; i >= 0; i-- {
+		kind := n.serviceOrder[i]
+		if err := n.stopService(kind, n.services[kind], deadline); err != nil {
 			failure.Services[kind] = err
 		}
 	}
 	n.server.Stop()
+
+	n.healthLock.Lock()
 	n.services = nil
+	n.serviceOrder = nil
 	n.server = nil
+	n.healthLock.Unlock()
 
 	// Release instance directory lock.
 	if n.instanceDirLock != nil {
@@ -519,6 +576,63 @@ func (n *Node) Stop() error {
 	return nil
 }
 
+// stopTimeout returns the time a shutdown step may take: the given limit, capped by
+// the time left until the shutdown deadline. Zero values mean no limit, in which
+// case ok is false.
+func stopTimeout(limit time.Duration, deadline time.Time) (timeout time.Duration, ok bool) {
+	if deadline.IsZero() {
+		return limit, limit > 0
+	}
+	left := deadline.Sub(time.Now())
+	if limit > 0 && limit < left {
+		return limit, true
+	}
+	return left, true
+}
+
+// drainRPC rejects new API requests on all endpoints and waits for the ones being
+// executed to finish, until the drain timeout or the shutdown deadline.
+func (n *Node) drainRPC(deadline time.Time) {
+	timeout, _ := stopTimeout(rpcDrainTimeout, deadline)
+
+	var pend sync.WaitGroup
+	for _, handler := range []*rpc.Server{n.inprocHandler, n.ipcHandler, n.httpHandler, n.wsHandler} {
+		if handler == nil {
+			continue
+		}
+		pend.Add(1)
+		go func(handler *rpc.Server) {
+			defer pend.Done()
+			if !handler.Drain(timeout) {
+				log.Warn("API requests still running at shutdown")
+			}
+		}(handler)
+	}
+	pend.Wait()
+}
+
+// stopService terminates a service, waiting for it until the service stop timeout
+// or the shutdown deadline. Services not terminating in time are left running.
+func (n *Node) stopService(kind reflect.Type, service Service, deadline time.Time) error {
+	errc := make(chan error, 1)
+	go func() { errc <- service.Stop() }()
+
+	timeout, ok := stopTimeout(n.config.ServiceStopTimeout, deadline)
+	if !ok {
+		return <-errc
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-timer.C:
+		log.Error("Service failed to stop in time", "service", kind, "timeout", timeout)
+		return ErrStopTimeout
+	}
+}
+
 // Wait blocks the thread until the node is stopped. If the node is not running
 // at the time of invocation, the method immediately returns.
 func (n *Node) Wait() {
@@ -639,7 +753,7 @@ func (n *Node) OpenDatabase(name string, cache, handles int) (ethdb.Database, er
 	if n.config.DataDir == "" {
 		return ethdb.NewMemDatabase()
 	}
-	return ethdb.NewLDBDatabase(n.config.resolvePath(name), cache, handles)
+	return ethdb.Open(n.config.DBEngine, n.config.resolvePath(name), cache, handles)
 }
 
 // ResolvePath returns the absolute path of a resource in the instance directory.
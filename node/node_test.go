@@ -407,6 +407,48 @@ func TestServiceTerminationGuarantee(t *testing.T) {
 	}
 }
 
+// Tests that services are stopped in reverse construction order, and that services
+// not stopping in time are abandoned.
+func TestServiceStopOrderAndTimeout(t *testing.T) {
+	config := testNodeConfig()
+	config.ServiceStopTimeout = 100 * time.Millisecond
+
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	var stopped []string
+	release := make(chan struct{})
+	defer close(release)
+
+	makers := []InstrumentingWrapper{InstrumentedServiceMakerA, InstrumentedServiceMakerB, InstrumentedServiceMakerC}
+	for i, id := range []string{"A", "B", "C"} {
+		id := id // Closure for the constructor
+		constructor := func(*ServiceContext) (Service, error) {
+			service := &InstrumentedService{stopHook: func() { stopped = append(stopped, id) }}
+			if id == "B" {
+				service.stopHook = func() { <-release }
+			}
+			return service, nil
+		}
+		if err := stack.Register(makers[i](constructor)); err != nil {
+			t.Fatalf("service %s: registration failed: %v", id, err)
+		}
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	err = stack.Stop()
+	if err, ok := err.(*StopError); !ok {
+		t.Fatalf("termination failure mismatch: have %v, want StopError", err)
+	} else if hung := reflect.TypeOf(&InstrumentedServiceB{}); err.Services[hung] != ErrStopTimeout || len(err.Services) != 1 {
+		t.Fatalf("termination failures mismatch: have %v, want %v for %v", err.Services, ErrStopTimeout, hung)
+	}
+	if !reflect.DeepEqual(stopped, []string{"C", "A"}) {
+		t.Fatalf("termination order mismatch: have %v, want %v", stopped, []string{"C", "A"})
+	}
+}
+
 // TestServiceRetrieval tests that individual services can be retrieved.
 func TestServiceRetrieval(t *testing.T) {
 	// Create a simple stack and register two service types
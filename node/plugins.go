@@ -0,0 +1,81 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// errPluginsUnsupported is returned when loading plugin files on platforms or Go
+// versions without support for shared object plugins.
+var errPluginsUnsupported = errors.New("plugin files not supported on this platform")
+
+var (
+	plugins     = make(map[string]ServiceConstructor) // Services that nodes can be configured to run
+	pluginsLock sync.RWMutex                          // Protects the registered plugins
+)
+
+// RegisterPlugin makes a service available under the given name, so nodes can be
+// configured to run it (Config.Plugins) without the embedding program having to
+// register it explicitly. It is meant to be called from the init function of the
+// package implementing the service, be that linked in or a plugin file loaded via
+// Config.PluginFiles. Registering the same name twice panics.
+//
+// Plugin services are full node services: they may run p2p protocols, expose RPC
+// namespaces through their APIs, access the p2p server in Start and retrieve the
+// services registered before them through the ServiceContext.
+func RegisterPlugin(name string, constructor ServiceConstructor) {
+	pluginsLock.Lock()
+	defer pluginsLock.Unlock()
+
+	if _, ok := plugins[name]; ok {
+		panic(fmt.Sprintf("service plugin %q registered twice", name))
+	}
+	plugins[name] = constructor
+}
+
+// Plugins returns the names of all the registered service plugins.
+func Plugins() []string {
+	pluginsLock.RLock()
+	defer pluginsLock.RUnlock()
+
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// pluginConstructors returns the constructors of the named service plugins.
+func pluginConstructors(names []string) ([]ServiceConstructor, error) {
+	pluginsLock.RLock()
+	defer pluginsLock.RUnlock()
+
+	constructors := make([]ServiceConstructor, 0, len(names))
+	for _, name := range names {
+		constructor, ok := plugins[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown service plugin %q", name)
+		}
+		constructors = append(constructors, constructor)
+	}
+	return constructors, nil
+}
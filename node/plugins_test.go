@@ -0,0 +1,61 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"testing"
+)
+
+func init() {
+	// The plugin depends on the noop service registered on the node
+	RegisterPlugin("test", func(ctx *ServiceContext) (Service, error) {
+		var noop *NoopService
+		if err := ctx.Service(&noop); err != nil {
+			return nil, err
+		}
+		return new(NoopServiceA), nil
+	})
+}
+
+// Tests that configured service plugins are started after the services registered
+// on the node, and that unknown plugins are rejected.
+func TestServicePlugins(t *testing.T) {
+	config := testNodeConfig()
+	config.Plugins = []string{"test"}
+
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	if err := stack.Register(NewNoopService); err != nil {
+		t.Fatalf("noop service registration failed: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start stack: %v", err)
+	}
+	defer stack.Stop()
+
+	var plugin *NoopServiceA
+	if err := stack.Service(&plugin); err != nil {
+		t.Fatalf("plugin service retrieval mismatch: have %v, want %v", err, nil)
+	}
+	// Unknown plugins fail the node creation
+	config.Plugins = []string{"unknown"}
+	if _, err := New(config); err == nil {
+		t.Fatalf("unknown plugin accepted")
+	}
+}
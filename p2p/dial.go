@@ -31,8 +31,11 @@ import (
 
 const (
 	// This is the amount of time spent waiting in between
-	// redialing a certain node.
+	// redialing a certain node. The delay doubles with every
+	// consecutive failed dial, up to the maximum backoff.
 	dialHistoryExpiration = 30 * time.Second
+	maxDialBackoff        = 10 * time.Minute
+	maxStaticDialBackoff  = 2 * time.Minute
 
 	// Discovery lookups are throttled and can only run
 	// once every few seconds.
@@ -42,6 +45,10 @@ const (
 	// attempted to be connected.
 	fallbackInterval = 20 * time.Second
 
+	// Number of known good peers from previous runs loaded as preferred
+	// dial candidates on startup.
+	maxGoodPeerCandidates = 32
+
 	// Endpoint resolution is throttled with bounded backoff.
 	initialResolveDelay = 60 * time.Second
 	maxResolveDelay     = time.Hour
@@ -61,8 +68,7 @@ type TCPDialer struct {
 
 // Dial creates a TCP connection to the node
 func (t TCPDialer) Dial(dest *discover.Node) (net.Conn, error) {
-	addr := &net.TCPAddr{IP: dest.IP, Port: int(dest.TCP)}
-	return t.Dialer.Dial("tcp", addr.String())
+	return t.Dialer.Dial("tcp", dest.TCPAddr().String())
 }
 
 // dialstate schedules dials and discovery lookups.
@@ -77,8 +83,10 @@ type dialstate struct {
 	dialing       map[discover.NodeID]connFlag
 	lookupBuf     []*discover.Node // current discovery lookup results
 	randomNodes   []*discover.Node // filled from Table
+	goodPeers     []*discover.Node // known good peers from previous runs, dialed first
 	static        map[discover.NodeID]*dialTask
 	hist          *dialHistory
+	backoff       map[discover.NodeID]*dialBackoff
 
 	start     time.Time        // time when the dialer was first used
 	bootnodes []*discover.Node // default dials when there are no peers
@@ -90,6 +98,14 @@ type discoverTable interface {
 	Resolve(target discover.NodeID) *discover.Node
 	Lookup(target discover.NodeID) []*discover.Node
 	ReadRandomNodes([]*discover.Node) int
+	MarkGoodPeer(*discover.Node)
+	GoodPeers(n int) []*discover.Node
+}
+
+// dialBackoff tracks the consecutive dial failures of a node.
+type dialBackoff struct {
+	fails int
+	last  time.Time
 }
 
 // the dial history remembers recent dials.
@@ -137,8 +153,12 @@ func newDialState(static []*discover.Node, bootnodes []*discover.Node, ntab disc
 		bootnodes:   make([]*discover.Node, len(bootnodes)),
 		randomNodes: make([]*discover.Node, maxdyn/2),
 		hist:        new(dialHistory),
+		backoff:     make(map[discover.NodeID]*dialBackoff),
 	}
 	copy(s.bootnodes, bootnodes)
+	if ntab != nil && maxdyn > 0 {
+		s.goodPeers = ntab.GoodPeers(maxGoodPeerCandidates)
+	}
 	for _, n := range static {
 		s.addStatic(n)
 	}
@@ -164,7 +184,7 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now
 	var newtasks []task
 	addDial := func(flag connFlag, n *discover.Node) bool {
 		if err := s.checkDial(n, peers); err != nil {
-			log.Trace("Skipping dial candidate", "id", n.ID, "addr", &net.TCPAddr{IP: n.IP, Port: int(n.TCP)}, "err", err)
+			log.Trace("Skipping dial candidate", "id", n.ID, "addr", n.TCPAddr(), "err", err)
 			return false
 		}
 		s.dialing[n.ID] = flag
@@ -185,21 +205,40 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now
 		}
 	}
 
-	// Expire the dial history on every invocation.
+	// Expire the dial history on every invocation, and reset the backoff of
+	// nodes that connected or haven't been tried for a long time.
 	s.hist.expire(now)
+	for id, b := range s.backoff {
+		if peers[id] != nil {
+			// The last dial succeeded, allow a quick redial if it drops.
+			s.hist.limit(id, b.last.Add(dialHistoryExpiration))
+			delete(s.backoff, id)
+		} else if now.Sub(b.last) > 2*maxDialBackoff {
+			delete(s.backoff, id)
+		}
+	}
 
 	// Create dials for static nodes if they are not connected.
 	for id, t := range s.static {
 		err := s.checkDial(t.dest, peers)
 		switch err {
 		case errNotWhitelisted, errSelf:
-			log.Warn("Removing static dial candidate", "id", t.dest.ID, "addr", &net.TCPAddr{IP: t.dest.IP, Port: int(t.dest.TCP)}, "err", err)
+			log.Warn("Removing static dial candidate", "id", t.dest.ID, "addr", t.dest.TCPAddr(), "err", err)
 			delete(s.static, t.dest.ID)
 		case nil:
 			s.dialing[id] = t.flags
 			newtasks = append(newtasks, t)
 		}
 	}
+	// Dial peers that behaved well in previous runs before falling back to
+	// discovery, they are the most likely ones to accept us again.
+	for len(s.goodPeers) > 0 && needDynDials > 0 {
+		n := s.goodPeers[0]
+		s.goodPeers = s.goodPeers[1:]
+		if addDial(dynDialedConn, n) {
+			needDynDials--
+		}
+	}
 	// If we don't have any peers whatsoever, try to dial a random bootnode. This
 	// scenario is useful for the testnet (and private networks) where the discovery
 	// table might be full of mostly bad peers, making it hard to find good ones.
@@ -277,7 +316,7 @@ func (s *dialstate) checkDial(n *discover.Node, peers map[discover.NodeID]*Peer)
 func (s *dialstate) taskDone(t task, now time.Time) {
 	switch t := t.(type) {
 	case *dialTask:
-		s.hist.add(t.dest.ID, now.Add(dialHistoryExpiration))
+		s.hist.add(t.dest.ID, now.Add(s.dialDelay(t, now)))
 		delete(s.dialing, t.dest.ID)
 	case *discoverTask:
 		s.lookupRunning = false
@@ -285,6 +324,34 @@ func (s *dialstate) taskDone(t task, now time.Time) {
 	}
 }
 
+// dialDelay computes the amount of time to wait before the destination of the
+// given finished dial may be dialed again. Each call counts as a failure until
+// the node shows up as connected, doubling the delay up to a limit. Static
+// nodes are capped lower to reconnect to them faster.
+func (s *dialstate) dialDelay(t *dialTask, now time.Time) time.Duration {
+	b := s.backoff[t.dest.ID]
+	if b == nil {
+		b = new(dialBackoff)
+		s.backoff[t.dest.ID] = b
+	}
+	limit := maxDialBackoff
+	if t.flags&staticDialedConn != 0 {
+		limit = maxStaticDialBackoff
+	}
+	delay := dialHistoryExpiration
+	for i := 0; i < b.fails && delay < limit; i++ {
+		delay *= 2
+	}
+	if delay > limit {
+		delay = limit
+	}
+	if delay < limit {
+		b.fails++
+	}
+	b.last = now
+	return delay
+}
+
 func (t *dialTask) Do(srv *Server) {
 	if t.dest.Incomplete() {
 		if !t.resolve(srv) {
@@ -330,7 +397,7 @@ func (t *dialTask) resolve(srv *Server) bool {
 	// The node was found.
 	t.resolveDelay = initialResolveDelay
 	t.dest = resolved
-	log.Debug("Resolved node", "id", t.dest.ID, "addr", &net.TCPAddr{IP: t.dest.IP, Port: int(t.dest.TCP)})
+	log.Debug("Resolved node", "id", t.dest.ID, "addr", t.dest.TCPAddr())
 	return true
 }
 
@@ -394,6 +461,15 @@ func (h dialHistory) contains(id discover.NodeID) bool {
 	}
 	return false
 }
+func (h *dialHistory) limit(id discover.NodeID, exp time.Time) {
+	for i, v := range *h {
+		if v.id == id && v.exp.After(exp) {
+			(*h)[i].exp = exp
+			heap.Fix(h, i)
+			return
+		}
+	}
+}
 func (h *dialHistory) expire(now time.Time) {
 	for h.Len() > 0 && h.min().exp.Before(now) {
 		heap.Pop(h)
@@ -83,6 +83,16 @@ func (t fakeTable) Close()                                   {}
 func (t fakeTable) Lookup(discover.NodeID) []*discover.Node  { return nil }
 func (t fakeTable) Resolve(discover.NodeID) *discover.Node   { return nil }
 func (t fakeTable) ReadRandomNodes(buf []*discover.Node) int { return copy(buf, t) }
+func (t fakeTable) MarkGoodPeer(*discover.Node)              {}
+func (t fakeTable) GoodPeers(int) []*discover.Node           { return nil }
+
+// goodPeerTable is a fakeTable which also reports known good peers.
+type goodPeerTable struct {
+	fakeTable
+	good []*discover.Node
+}
+
+func (t goodPeerTable) GoodPeers(n int) []*discover.Node { return t.good }
 
 // This test checks that dynamic dials are launched from discovery results.
 func TestDialStateDynDial(t *testing.T) {
@@ -281,13 +291,32 @@ func TestDialStateDynDialBootnode(t *testing.T) {
 					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(3)}},
 				},
 			},
-			// No dials succeed, 1st bootnode is attempted again, expired random nodes retried
+			// No dials succeed, 1st bootnode is attempted again, random nodes
+			// are backing off after their second failure
 			{
 				done: []task{
 					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(3)}},
 				},
 				new: []task{
 					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(1)}},
+				},
+			},
+			// No dials succeed, 2nd bootnode is attempted again
+			{
+				done: []task{
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(1)}},
+				},
+				new: []task{
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(2)}},
+				},
+			},
+			// No dials succeed, 3rd bootnode is attempted again, backed off random nodes retried
+			{
+				done: []task{
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(2)}},
+				},
+				new: []task{
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(3)}},
 					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(4)}},
 					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(5)}},
 				},
@@ -298,7 +327,7 @@ func TestDialStateDynDialBootnode(t *testing.T) {
 					{rw: &conn{flags: dynDialedConn, id: uintID(4)}},
 				},
 				done: []task{
-					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(1)}},
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(3)}},
 					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(4)}},
 					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(5)}},
 				},
@@ -402,6 +431,44 @@ func TestDialStateDynDialFromTable(t *testing.T) {
 	})
 }
 
+// This test checks that known good peers from previous runs are dialed before
+// any discovery results.
+func TestDialStateGoodPeers(t *testing.T) {
+	table := goodPeerTable{
+		good: []*discover.Node{
+			{ID: uintID(1)},
+			{ID: uintID(2)},
+			{ID: uintID(3)},
+		},
+	}
+	runDialTest(t, dialtest{
+		init: newDialState(nil, nil, table, 4, nil),
+		rounds: []round{
+			// All good peers are dialed, a lookup is started for the rest.
+			{
+				new: []task{
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(1)}},
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(2)}},
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(3)}},
+					&discoverTask{},
+				},
+			},
+			// Dialing nodes 1,2 succeeds, 3 fails. Good peers aren't retried.
+			{
+				peers: []*Peer{
+					{rw: &conn{flags: dynDialedConn, id: uintID(1)}},
+					{rw: &conn{flags: dynDialedConn, id: uintID(2)}},
+				},
+				done: []task{
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(1)}},
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(2)}},
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(3)}},
+				},
+			},
+		},
+	})
+}
+
 // This test checks that candidates that do not match the netrestrict list are not dialed.
 func TestDialStateNetRestrict(t *testing.T) {
 	// This table always returns the same random nodes
@@ -583,6 +650,38 @@ func TestDialStateCache(t *testing.T) {
 	})
 }
 
+// This test checks that failing dials are retried with exponential backoff,
+// which is reset once the node connects.
+func TestDialStateBackoff(t *testing.T) {
+	wantStatic := []*discover.Node{{ID: uintID(1)}}
+	dial := &dialTask{flags: staticDialedConn, dest: &discover.Node{ID: uintID(1)}}
+	wait := func(d time.Duration) *waitExpireTask { return &waitExpireTask{Duration: d} }
+
+	runDialTest(t, dialtest{
+		init: newDialState(wantStatic, nil, fakeTable{}, 0, nil),
+		rounds: []round{
+			{new: []task{dial}}, // t=0
+			{done: []task{dial}, new: []task{wait(30 * time.Second)}}, // t=16: first failure, 30s backoff
+			{done: []task{wait(0)}, new: []task{wait(14 * time.Second)}},
+			{done: []task{wait(0)}, new: []task{dial}},                // t=48
+			{done: []task{dial}, new: []task{wait(60 * time.Second)}}, // t=64: second failure, 60s backoff
+			{done: []task{wait(0)}, new: []task{wait(44 * time.Second)}},
+			{done: []task{wait(0)}, new: []task{wait(28 * time.Second)}},
+			{done: []task{wait(0)}, new: []task{wait(12 * time.Second)}},
+			{done: []task{wait(0)}, new: []task{dial}}, // t=128
+			// t=144: the dial succeeds, backoff is reset to the default.
+			{
+				peers: []*Peer{{rw: &conn{flags: staticDialedConn, id: uintID(1)}}},
+				done:  []task{dial},
+				new:   []task{wait(30 * time.Second)},
+			},
+			// t=160: the node drops and is redialed after the default delay.
+			{done: []task{wait(0)}, new: []task{wait(14 * time.Second)}},
+			{done: []task{wait(0)}, new: []task{dial}},
+		},
+	})
+}
+
 func TestDialResolve(t *testing.T) {
 	resolved := discover.NewNode(uintID(1), net.IP{127, 0, 55, 234}, 3333, 4444)
 	table := &resolveMock{answer: resolved}
@@ -650,3 +749,5 @@ func (t *resolveMock) Close()                                   {}
 func (t *resolveMock) Bootstrap([]*discover.Node)               {}
 func (t *resolveMock) Lookup(discover.NodeID) []*discover.Node  { return nil }
 func (t *resolveMock) ReadRandomNodes(buf []*discover.Node) int { return 0 }
+func (t *resolveMock) MarkGoodPeer(*discover.Node)              {}
+func (t *resolveMock) GoodPeers(int) []*discover.Node           { return nil }
@@ -24,6 +24,7 @@ import (
 	"crypto/rand"
 	"encoding/binary"
 	"os"
+	"sort"
 	"sync"
 	"time"
 
@@ -39,9 +40,10 @@ import (
 )
 
 var (
-	nodeDBNilNodeID      = NodeID{}       // Special node ID to use as a nil element.
-	nodeDBNodeExpiration = 24 * time.Hour // Time after which an unseen node should be dropped.
-	nodeDBCleanupCycle   = time.Hour      // Time period for running the expiration task.
+	nodeDBNilNodeID          = NodeID{}           // Special node ID to use as a nil element.
+	nodeDBNodeExpiration     = 24 * time.Hour     // Time after which an unseen node should be dropped.
+	nodeDBGoodPeerExpiration = 7 * 24 * time.Hour // Time after which a good peer should be forgotten.
+	nodeDBCleanupCycle       = time.Hour          // Time period for running the expiration task.
 )
 
 // nodeDB stores all nodes we know about.
@@ -61,6 +63,9 @@ var (
 	nodeDBDiscoverPing      = nodeDBDiscoverRoot + ":lastping"
 	nodeDBDiscoverPong      = nodeDBDiscoverRoot + ":lastpong"
 	nodeDBDiscoverFindFails = nodeDBDiscoverRoot + ":findfail"
+
+	nodeDBPeerRoot     = ":p2p"
+	nodeDBPeerLastGood = nodeDBPeerRoot + ":lastgood"
 )
 
 // newNodeDB creates a new node database for storing and retrieving infos about
@@ -241,18 +246,21 @@ func (db *nodeDB) expirer() {
 }
 
 // expireNodes iterates over the database and deletes all nodes that have not
-// been seen (i.e. received a pong from) for some allotted time.
+// been seen (i.e. received a pong from) for some allotted time, unless they
+// were recently connected to as good peers.
 func (db *nodeDB) expireNodes() error {
-	threshold := time.Now().Add(-nodeDBNodeExpiration)
-
+	var (
+		threshold     = time.Now().Add(-nodeDBNodeExpiration)
+		goodThreshold = time.Now().Add(-nodeDBGoodPeerExpiration)
+	)
 	// Find discovered nodes that are older than the allowance
 	it := db.lvl.NewIterator(nil, nil)
 	defer it.Release()
 
 	for it.Next() {
-		// Skip the item if not a discovery node
+		// Skip the item if not a discovery node or a good peer
 		id, field := splitKey(it.Key())
-		if field != nodeDBDiscoverRoot {
+		if field != nodeDBDiscoverRoot && field != nodeDBPeerRoot {
 			continue
 		}
 		// Skip the node if not expired yet (and not self)
@@ -260,6 +268,9 @@ func (db *nodeDB) expireNodes() error {
 			if seen := db.lastPong(id); seen.After(threshold) {
 				continue
 			}
+			if good := db.lastGoodPeer(id); good.After(goodThreshold) {
+				continue
+			}
 		}
 		// Otherwise delete all associated information
 		db.deleteNode(id)
@@ -298,6 +309,76 @@ func (db *nodeDB) updateFindFails(id NodeID, fails int) error {
 	return db.storeInt64(makeKey(id, nodeDBDiscoverFindFails), int64(fails))
 }
 
+// lastGoodPeer retrieves the last time a remote node was connected to as a
+// well behaved peer.
+func (db *nodeDB) lastGoodPeer(id NodeID) time.Time {
+	return time.Unix(db.fetchInt64(makeKey(id, nodeDBPeerLastGood)), 0)
+}
+
+// updateGoodPeer inserts - potentially overwriting - a node into the set of
+// known good peers, marking it as seen at the given time.
+func (db *nodeDB) updateGoodPeer(node *Node, instance time.Time) error {
+	blob, err := rlp.EncodeToBytes(node)
+	if err != nil {
+		return err
+	}
+	if err := db.lvl.Put(makeKey(node.ID, nodeDBPeerRoot), blob, nil); err != nil {
+		return err
+	}
+	return db.storeInt64(makeKey(node.ID, nodeDBPeerLastGood), instance.Unix())
+}
+
+// queryGoodPeers retrieves at most n known good peers that were seen within
+// the given age limit, ordered by the time they were last seen (most recent
+// first).
+func (db *nodeDB) queryGoodPeers(n int, maxAge time.Duration) []*Node {
+	var (
+		threshold = time.Now().Add(-maxAge)
+		peers     goodPeersByTime
+		it        = db.lvl.NewIterator(util.BytesPrefix(nodeDBItemPrefix), nil)
+	)
+	defer it.Release()
+
+	for it.Next() {
+		id, field := splitKey(it.Key())
+		if field != nodeDBPeerRoot || id == db.self {
+			continue
+		}
+		last := db.lastGoodPeer(id)
+		if last.Before(threshold) {
+			continue
+		}
+		node := new(Node)
+		if err := rlp.DecodeBytes(it.Value(), node); err != nil {
+			log.Warn("Failed to decode peer RLP", "id", id, "err", err)
+			continue
+		}
+		node.sha = crypto.Keccak256Hash(node.ID[:])
+		peers = append(peers, goodPeer{node, last})
+	}
+	sort.Sort(peers)
+
+	nodes := make([]*Node, 0, n)
+	for i := 0; i < len(peers) && i < n; i++ {
+		nodes = append(nodes, peers[i].node)
+	}
+	return nodes
+}
+
+// goodPeer is a known good peer along with the time it was last seen.
+type goodPeer struct {
+	node *Node
+	seen time.Time
+}
+
+// goodPeersByTime implements sort.Interface, ordering peers by the time
+// they were last seen, most recent first.
+type goodPeersByTime []goodPeer
+
+func (p goodPeersByTime) Len() int           { return len(p) }
+func (p goodPeersByTime) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p goodPeersByTime) Less(i, j int) bool { return p[i].seen.After(p[j].seen) }
+
 // querySeeds retrieves random nodes to be used as potential seed nodes
 // for bootstrapping.
 func (db *nodeDB) querySeeds(n int, maxAge time.Duration) []*Node {
@@ -378,3 +378,63 @@ func TestNodeDBSelfExpiration(t *testing.T) {
 		t.Errorf("self not evacuated")
 	}
 }
+
+func TestNodeDBGoodPeers(t *testing.T) {
+	db, _ := newNodeDB("", Version, nodeDBSeedQueryNodes[0].node.ID)
+	defer db.close()
+
+	// Mark all the nodes as good peers, self and a stale one included
+	now := time.Now()
+	for i, seed := range nodeDBSeedQueryNodes {
+		seen := now.Add(time.Duration(-i) * time.Minute)
+		if i == len(nodeDBSeedQueryNodes)-1 {
+			seen = now.Add(-2 * time.Hour)
+		}
+		if err := db.updateGoodPeer(seed.node, seen); err != nil {
+			t.Fatalf("node %d: failed to insert good peer: %v", i, err)
+		}
+	}
+	// Self and the stale peer must be skipped, the rest ordered by recency
+	peers := db.queryGoodPeers(len(nodeDBSeedQueryNodes), time.Hour)
+	want := nodeDBSeedQueryNodes[1 : len(nodeDBSeedQueryNodes)-1]
+	if len(peers) != len(want) {
+		t.Fatalf("good peer count mismatch: have %d, want %d", len(peers), len(want))
+	}
+	for i, peer := range peers {
+		if peer.ID != want[i].node.ID {
+			t.Errorf("good peer %d: id mismatch: have %x, want %x", i, peer.ID[:8], want[i].node.ID[:8])
+		}
+	}
+	// Check that the result count is capped
+	if peers := db.queryGoodPeers(1, time.Hour); len(peers) != 1 || peers[0].ID != want[0].node.ID {
+		t.Errorf("capped query mismatch: have %v", peers)
+	}
+}
+
+func TestNodeDBGoodPeerExpiration(t *testing.T) {
+	db, _ := newNodeDB("", Version, NodeID{})
+	defer db.close()
+
+	// Insert nodes with a stale pong, but one of them recently connected
+	for i, seed := range nodeDBExpirationNodes {
+		if err := db.updateNode(seed.node); err != nil {
+			t.Fatalf("node %d: failed to insert: %v", i, err)
+		}
+		if err := db.updateLastPong(seed.node.ID, time.Now().Add(-nodeDBNodeExpiration-time.Minute)); err != nil {
+			t.Fatalf("node %d: failed to update pong: %v", i, err)
+		}
+	}
+	good := nodeDBExpirationNodes[0].node
+	if err := db.updateGoodPeer(good, time.Now()); err != nil {
+		t.Fatalf("failed to insert good peer: %v", err)
+	}
+	if err := db.expireNodes(); err != nil {
+		t.Fatalf("failed to expire nodes: %v", err)
+	}
+	if db.node(good.ID) == nil {
+		t.Errorf("good peer expired")
+	}
+	if db.node(nodeDBExpirationNodes[1].node.ID) != nil {
+		t.Errorf("stale node not expired")
+	}
+}
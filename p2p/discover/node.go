@@ -39,11 +39,20 @@ const NodeIDBits = 512
 
 // Node represents a host on the network.
 // The fields of Node may not be modified.
+//
+// A node has a single endpoint, either IPv4 or IPv6. Advertising both address
+// families of dual-stack hosts is not supported by the discovery protocol; such
+// hosts should listen on an unspecified address (e.g. ":30303"), accepting both
+// IPv4 and IPv6 traffic, and are known to each peer by the address it sees.
 type Node struct {
 	IP       net.IP // len 4 for IPv4 or 16 for IPv6
 	UDP, TCP uint16 // port numbers
 	ID       NodeID // the node's public key
 
+	// zone is the IPv6 scope zone of link-local addresses. It is only known
+	// locally (e.g. from an enode URL) and never relayed to other nodes.
+	zone string
+
 	// This is a cached copy of sha3(ID) which is used for node
 	// distance calculations. This is part of Node in order to make it
 	// possible to write tests that need a node at a certain distance.
@@ -72,7 +81,13 @@ func NewNode(id NodeID, ip net.IP, udpPort, tcpPort uint16) *Node {
 }
 
 func (n *Node) addr() *net.UDPAddr {
-	return &net.UDPAddr{IP: n.IP, Port: int(n.UDP)}
+	return &net.UDPAddr{IP: n.IP, Port: int(n.UDP), Zone: n.zone}
+}
+
+// TCPAddr returns the RLPx endpoint of the node, including the IPv6 scope
+// zone if one is known.
+func (n *Node) TCPAddr() *net.TCPAddr {
+	return &net.TCPAddr{IP: n.IP, Port: int(n.TCP), Zone: n.zone}
 }
 
 // Incomplete returns true for nodes with no IP address.
@@ -105,9 +120,8 @@ func (n *Node) String() string {
 	if n.Incomplete() {
 		u.Host = fmt.Sprintf("%x", n.ID[:])
 	} else {
-		addr := net.TCPAddr{IP: n.IP, Port: int(n.TCP)}
 		u.User = url.User(fmt.Sprintf("%x", n.ID[:]))
-		u.Host = addr.String()
+		u.Host = n.TCPAddr().String()
 		if n.UDP != n.TCP {
 			u.RawQuery = "discport=" + strconv.Itoa(int(n.UDP))
 		}
@@ -140,6 +154,11 @@ var incompleteNodeURL = regexp.MustCompile("(?i)^(?:enode://)?([0-9a-f]+)$")
 // and UDP discovery port 30301.
 //
 //    enode://<hex node id>@10.3.58.6:30303?discport=30301
+//
+// IPv6 addresses must be enclosed in square brackets. Link-local IPv6
+// addresses may carry a scope zone, which has to be escaped as %25:
+//
+//    enode://<hex node id>@[fe80::1%25eth0]:30303
 func ParseNode(rawurl string) (*Node, error) {
 	if m := incompleteNodeURL.FindStringSubmatch(rawurl); m != nil {
 		id, err := HexID(m[1])
@@ -155,6 +174,7 @@ func parseComplete(rawurl string) (*Node, error) {
 	var (
 		id               NodeID
 		ip               net.IP
+		zone             string
 		tcpPort, udpPort uint64
 	)
 	u, err := url.Parse(rawurl)
@@ -176,6 +196,9 @@ func parseComplete(rawurl string) (*Node, error) {
 	if err != nil {
 		return nil, fmt.Errorf("invalid host: %v", err)
 	}
+	if i := strings.LastIndex(host, "%"); i >= 0 {
+		host, zone = host[:i], host[i+1:]
+	}
 	if ip = net.ParseIP(host); ip == nil {
 		return nil, errors.New("invalid IP address")
 	}
@@ -183,6 +206,9 @@ func parseComplete(rawurl string) (*Node, error) {
 	if ipv4 := ip.To4(); ipv4 != nil {
 		ip = ipv4
 	}
+	if zone != "" && (len(ip) != net.IPv6len || !ip.IsLinkLocalUnicast()) {
+		return nil, errors.New("scope zone only allowed for link-local IPv6 addresses")
+	}
 	// Parse the port numbers.
 	if tcpPort, err = strconv.ParseUint(port, 10, 16); err != nil {
 		return nil, errors.New("invalid port")
@@ -195,7 +221,9 @@ func parseComplete(rawurl string) (*Node, error) {
 			return nil, errors.New("invalid discport in query")
 		}
 	}
-	return NewNode(id, ip, uint16(udpPort), uint16(tcpPort)), nil
+	n := NewNode(id, ip, uint16(udpPort), uint16(tcpPort))
+	n.zone = zone
+	return n, nil
 }
 
 // MustParseNode parses a node URL. It panics if the URL is not valid.
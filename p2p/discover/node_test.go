@@ -115,6 +115,21 @@ var parseNodeTests = []struct {
 			52150,
 		),
 	},
+	{
+		rawurl: "enode://1dd9d65c4552b5eb43d5ad55a2ee3f56c6cbc1c64a5c8d659f51fcd51bace24351232b8d7821617d2b29b54b81cdefb9b3e9c37d7fd5f63270bcc9e1a6f6a439@[fe80::1%25eth0]:52150",
+		wantResult: &Node{
+			IP:   net.ParseIP("fe80::1"),
+			UDP:  52150,
+			TCP:  52150,
+			ID:   MustHexID("0x1dd9d65c4552b5eb43d5ad55a2ee3f56c6cbc1c64a5c8d659f51fcd51bace24351232b8d7821617d2b29b54b81cdefb9b3e9c37d7fd5f63270bcc9e1a6f6a439"),
+			sha:  crypto.Keccak256Hash(MustHexID("0x1dd9d65c4552b5eb43d5ad55a2ee3f56c6cbc1c64a5c8d659f51fcd51bace24351232b8d7821617d2b29b54b81cdefb9b3e9c37d7fd5f63270bcc9e1a6f6a439").Bytes()),
+			zone: "eth0",
+		},
+	},
+	{
+		rawurl:    "enode://1dd9d65c4552b5eb43d5ad55a2ee3f56c6cbc1c64a5c8d659f51fcd51bace24351232b8d7821617d2b29b54b81cdefb9b3e9c37d7fd5f63270bcc9e1a6f6a439@[2001:db8::1%25eth0]:52150",
+		wantError: `scope zone only allowed for link-local IPv6 addresses`,
+	},
 	// Incomplete nodes with no address.
 	{
 		rawurl: "1dd9d65c4552b5eb43d5ad55a2ee3f56c6cbc1c64a5c8d659f51fcd51bace24351232b8d7821617d2b29b54b81cdefb9b3e9c37d7fd5f63270bcc9e1a6f6a439",
@@ -180,6 +195,20 @@ func TestNodeString(t *testing.T) {
 	}
 }
 
+// Tests that IPv4-mapped IPv6 addresses are normalized to plain IPv4.
+func TestParseNodeIPv4Mapped(t *testing.T) {
+	n, err := ParseNode("enode://1dd9d65c4552b5eb43d5ad55a2ee3f56c6cbc1c64a5c8d659f51fcd51bace24351232b8d7821617d2b29b54b81cdefb9b3e9c37d7fd5f63270bcc9e1a6f6a439@[::ffff:127.0.0.1]:52150")
+	if err != nil {
+		t.Fatalf("failed to parse node: %v", err)
+	}
+	if !bytes.Equal(n.IP, net.IP{127, 0, 0, 1}) {
+		t.Errorf("IP mismatch: got %v (len %d), want 127.0.0.1", n.IP, len(n.IP))
+	}
+	if want := "127.0.0.1:52150"; n.TCPAddr().String() != want {
+		t.Errorf("TCP address mismatch: got %v, want %v", n.TCPAddr(), want)
+	}
+}
+
 func TestHexID(t *testing.T) {
 	ref := NodeID{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 128, 106, 217, 182, 31, 165, 174, 1, 67, 7, 235, 220, 150, 66, 83, 173, 205, 159, 44, 10, 57, 42, 161, 26, 188}
 	id1 := MustHexID("0x000000000000000000000000000000000000000000000000000000000000000000000000000000806ad9b61fa5ae014307ebdc964253adcd9f2c0a392aa11abc")
@@ -49,6 +49,7 @@ const (
 	autoRefreshInterval = 1 * time.Hour
 	seedCount           = 30
 	seedMaxAge          = 5 * 24 * time.Hour
+	goodPeerMaxAge      = 5 * 24 * time.Hour
 )
 
 type Table struct {
@@ -170,6 +171,20 @@ func randUint(max uint32) uint32 {
 	return binary.BigEndian.Uint32(b[:]) % max
 }
 
+// MarkGoodPeer records the given node in the node database as a well behaved
+// peer, making it a preferred dial candidate after a restart.
+func (tab *Table) MarkGoodPeer(n *Node) {
+	if err := tab.db.updateGoodPeer(n, time.Now()); err != nil {
+		log.Debug("Failed to store good peer", "id", n.ID, "err", err)
+	}
+}
+
+// GoodPeers retrieves at most n recently seen good peers from the node
+// database, most recently seen first.
+func (tab *Table) GoodPeers(n int) []*Node {
+	return tab.db.queryGoodPeers(n, goodPeerMaxAge)
+}
+
 // Close terminates the network listener and flushes the node database.
 func (tab *Table) Close() {
 	select {
@@ -497,6 +497,76 @@ func TestForwardCompatibility(t *testing.T) {
 	}
 }
 
+// Tests that NAT port mappings and external IP lookups are only used for sockets
+// bound to IPv4 or dual-stack (unspecified) addresses, keeping the local endpoint
+// of loopback and specific IPv6 listeners.
+func TestUDP_NATEndpoint(t *testing.T) {
+	ext := net.IP{33, 44, 55, 66}
+	tests := []struct {
+		listen net.IP
+		mapped bool
+	}{
+		{listen: net.IP{10, 0, 0, 1}, mapped: true},
+		{listen: net.IPv4zero, mapped: true},
+		{listen: net.IPv6unspecified, mapped: true},
+		{listen: net.IPv4(127, 0, 0, 1), mapped: false},
+		{listen: net.IPv6loopback, mapped: false},
+		{listen: net.ParseIP("2001:db8::1"), mapped: false},
+	}
+	for _, tt := range tests {
+		natm := &fakeNAT{ext: ext, mapped: make(chan string, 1)}
+		conn := &addrPipe{dgramPipe: newpipe(), addr: &net.UDPAddr{IP: tt.listen, Port: 30303}}
+
+		tab, _, err := newUDP(newkey(), conn, natm, "", nil)
+		if err != nil {
+			t.Fatalf("listen %v: failed to create transport: %v", tt.listen, err)
+		}
+		want := tt.listen
+		if tt.mapped {
+			want = ext
+		}
+		if !tab.self.IP.Equal(want) {
+			t.Errorf("listen %v: endpoint IP mismatch: have %v, want %v", tt.listen, tab.self.IP, want)
+		}
+		select {
+		case proto := <-natm.mapped:
+			if !tt.mapped {
+				t.Errorf("listen %v: unexpected %s port mapping", tt.listen, proto)
+			}
+		case <-time.After(100 * time.Millisecond):
+			if tt.mapped {
+				t.Errorf("listen %v: port not mapped", tt.listen)
+			}
+		}
+		tab.Close()
+	}
+}
+
+// fakeNAT is a NAT interface with a fixed external IP, reporting added mappings.
+type fakeNAT struct {
+	ext    net.IP
+	mapped chan string
+}
+
+func (n *fakeNAT) AddMapping(protocol string, extport, intport int, name string, lifetime time.Duration) error {
+	select {
+	case n.mapped <- protocol:
+	default:
+	}
+	return nil
+}
+func (n *fakeNAT) DeleteMapping(protocol string, extport, intport int) error { return nil }
+func (n *fakeNAT) ExternalIP() (net.IP, error)                               { return n.ext, nil }
+func (n *fakeNAT) String() string                                            { return "fake" }
+
+// addrPipe is a fake UDP socket bound to a specific local address.
+type addrPipe struct {
+	*dgramPipe
+	addr *net.UDPAddr
+}
+
+func (c *addrPipe) LocalAddr() net.Addr { return c.addr }
+
 // dgramPipe is a fake UDP socket. It queues all sent datagrams.
 type dgramPipe struct {
 	mu      *sync.Mutex
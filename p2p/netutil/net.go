@@ -155,20 +155,34 @@ func IsSpecialNetwork(ip net.IP) bool {
 	return special6.Contains(ip)
 }
 
+// UsesNAT reports whether NAT port mappings and external address lookups apply
+// to a listener bound to the given IP. This is the case for IPv4 and unspecified
+// (dual-stack) addresses. Loopback addresses and specific IPv6 addresses are
+// reachable without address translation.
+func UsesNAT(ip net.IP) bool {
+	if ip.IsLoopback() {
+		return false
+	}
+	return ip.To4() != nil || ip.IsUnspecified()
+}
+
 var (
 	errInvalid     = errors.New("invalid IP")
 	errUnspecified = errors.New("zero address")
 	errSpecial     = errors.New("special network")
 	errLoopback    = errors.New("loopback address from non-loopback host")
 	errLAN         = errors.New("LAN address from WAN host")
+	errLinkLocal   = errors.New("link-local address from other host")
 )
 
 // CheckRelayIP reports whether an IP relayed from the given sender IP
 // is a valid connection target.
 //
-// There are four rules:
+// There are five rules:
 //   - Special network addresses are never valid.
 //   - Loopback addresses are OK if relayed by a loopback host.
+//   - Link-local addresses are only OK if relayed by the host itself,
+//     they are meaningless outside of the sender's link scope.
 //   - LAN addresses are OK if relayed by a LAN host.
 //   - All other addresses are always acceptable.
 func CheckRelayIP(sender, addr net.IP) error {
@@ -184,6 +198,9 @@ func CheckRelayIP(sender, addr net.IP) error {
 	if addr.IsLoopback() && !sender.IsLoopback() {
 		return errLoopback
 	}
+	if addr.IsLinkLocalUnicast() && !addr.Equal(sender) {
+		return errLinkLocal
+	}
 	if IsLAN(addr) && !IsLAN(sender) {
 		return errLAN
 	}
@@ -147,6 +147,8 @@ func TestCheckRelayIP(t *testing.T) {
 		{"23.55.1.242", "255.255.255.255", errSpecial},
 		{"192.168.0.1", "127.0.2.19", errLoopback},
 		{"23.55.1.242", "192.168.0.1", errLAN},
+		{"fe80::2", "fe80::1", errLinkLocal},
+		{"192.168.0.1", "169.254.1.1", errLinkLocal},
 
 		{"127.0.0.1", "127.0.2.19", nil},
 		{"127.0.0.1", "192.168.0.1", nil},
@@ -154,6 +156,9 @@ func TestCheckRelayIP(t *testing.T) {
 		{"192.168.0.1", "192.168.0.1", nil},
 		{"192.168.0.1", "23.55.1.242", nil},
 		{"23.55.1.242", "23.55.1.242", nil},
+		{"fe80::1", "fe80::1", nil},
+		{"2001:4860::1", "2a00:1450::1", nil},
+		{"::ffff:23.55.1.242", "23.55.1.2", nil},
 	}
 
 	for _, test := range tests {
@@ -164,6 +169,27 @@ func TestCheckRelayIP(t *testing.T) {
 	}
 }
 
+func TestUsesNAT(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"0.0.0.0", true},
+		{"::", true},
+		{"192.168.0.1", true},
+		{"::ffff:192.168.0.1", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"2a00:1450::1", false},
+		{"fe80::1", false},
+	}
+	for _, test := range tests {
+		if got := UsesNAT(parseIP(test.ip)); got != test.want {
+			t.Errorf("UsesNAT(%s): got %t, want %t", test.ip, got, test.want)
+		}
+	}
+}
+
 func BenchmarkCheckRelayIP(b *testing.B) {
 	sender := parseIP("23.55.1.242")
 	addr := parseIP("23.55.1.2")
@@ -180,23 +180,40 @@ func (p *Peer) Log() log.Logger {
 
 func (p *Peer) run() (remoteRequested bool, err error) {
 	var (
-		writeStart = make(chan struct{}, 1)
+		writeStart [numPriorities]chan struct{}
 		writeErr   = make(chan error, 1)
 		readErr    = make(chan error, 1)
 		reason     DiscReason // sent to the peer
 	)
+	for i := range writeStart {
+		writeStart[i] = make(chan struct{})
+	}
 	p.wg.Add(2)
 	go p.readLoop(readErr)
 	go p.pingLoop()
 
 	// Start all protocol handlers.
-	writeStart <- struct{}{}
 	p.startProtocols(writeStart, writeErr)
 
-	// Wait for an error or disconnect.
+	// Wait for an error or disconnect. The write slot is handed out to
+	// waiting protocol writers in order of priority whenever it is idle.
+	writeIdle := true
 loop:
 	for {
+		if writeIdle && grantWrite(writeStart) {
+			writeIdle = false
+		}
+		var high, normal, bulk chan struct{}
+		if writeIdle {
+			high, normal, bulk = writeStart[PriorityHigh], writeStart[PriorityNormal], writeStart[PriorityBulk]
+		}
 		select {
+		case high <- struct{}{}:
+			writeIdle = false
+		case normal <- struct{}{}:
+			writeIdle = false
+		case bulk <- struct{}{}:
+			writeIdle = false
 		case err = <-writeErr:
 			// A write finished. Allow the next write to start if
 			// there was no error.
@@ -204,7 +221,7 @@ loop:
 				reason = DiscNetworkError
 				break loop
 			}
-			writeStart <- struct{}{}
+			writeIdle = true
 		case err = <-readErr:
 			if r, ok := err.(DiscReason); ok {
 				remoteRequested = true
@@ -227,6 +244,20 @@ loop:
 	return remoteRequested, err
 }
 
+// grantWrite tries to hand the write slot to a waiting writer, checking the
+// priority classes from highest to lowest. It reports whether any writer
+// accepted the slot.
+func grantWrite(writeStart [numPriorities]chan struct{}) bool {
+	for prio := numPriorities - 1; prio >= 0; prio-- {
+		select {
+		case writeStart[prio] <- struct{}{}:
+			return true
+		default:
+		}
+	}
+	return false
+}
+
 func (p *Peer) pingLoop() {
 	ping := time.NewTimer(pingInterval)
 	defer p.wg.Done()
@@ -328,12 +359,14 @@ outer:
 	return result
 }
 
-func (p *Peer) startProtocols(writeStart <-chan struct{}, writeErr chan<- error) {
+func (p *Peer) startProtocols(writeStart [numPriorities]chan struct{}, writeErr chan<- error) {
 	p.wg.Add(len(p.running))
 	for _, proto := range p.running {
 		proto := proto
 		proto.closed = p.closed
-		proto.wstart = writeStart
+		for i := range writeStart {
+			proto.wstart[i] = writeStart[i]
+		}
 		proto.werr = writeErr
 		var rw MsgReadWriter = proto
 		if p.events != nil {
@@ -367,10 +400,10 @@ func (p *Peer) getProto(code uint64) (*protoRW, error) {
 
 type protoRW struct {
 	Protocol
-	in     chan Msg        // receices read messages
-	closed <-chan struct{} // receives when peer is shutting down
-	wstart <-chan struct{} // receives when write may start
-	werr   chan<- error    // for write results
+	in     chan Msg                       // receices read messages
+	closed <-chan struct{}                // receives when peer is shutting down
+	wstart [numPriorities]<-chan struct{} // receives when write may start, per priority
+	werr   chan<- error                   // for write results
 	offset uint64
 	w      MsgWriter
 }
@@ -379,9 +412,10 @@ func (rw *protoRW) WriteMsg(msg Msg) (err error) {
 	if msg.Code >= rw.Length {
 		return newPeerError(errInvalidMsgCode, "not handled")
 	}
+	wstart := rw.wstart[rw.priority(msg.Code)]
 	msg.Code += rw.offset
 	select {
-	case <-rw.wstart:
+	case <-wstart:
 		err = rw.w.WriteMsg(msg)
 		// Report write status back to Peer.run. It will initiate
 		// shutdown if the error is non-nil and unblock the next write
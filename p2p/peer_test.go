@@ -120,6 +120,50 @@ func TestPeerProtoEncodeMsg(t *testing.T) {
 	}
 }
 
+// Tests that when the connection is congested, queued writes are granted
+// access to the link in order of their priority class.
+func TestPeerProtoWritePriority(t *testing.T) {
+	proto := Protocol{
+		Name:   "a",
+		Length: 3,
+		Priority: func(code uint64) MsgPriority {
+			switch code {
+			case 0:
+				return PriorityBulk
+			case 1:
+				return PriorityHigh
+			default:
+				return PriorityNormal
+			}
+		},
+		Run: func(peer *Peer, rw MsgReadWriter) error {
+			// Block the link with a normal write, queue a bulk and a
+			// high priority write behind it.
+			go SendItems(rw, 2, "normal")
+			time.Sleep(50 * time.Millisecond)
+			go SendItems(rw, 0, "bulk")
+			time.Sleep(50 * time.Millisecond)
+			go SendItems(rw, 1, "high")
+			time.Sleep(50 * time.Millisecond)
+			<-peer.closed
+			return nil
+		},
+	}
+	closer, rw, _, _ := testPeer([]Protocol{proto})
+	defer closer()
+
+	time.Sleep(200 * time.Millisecond)
+	if err := ExpectMsg(rw, baseProtocolLength+2, []string{"normal"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExpectMsg(rw, baseProtocolLength+1, []string{"high"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExpectMsg(rw, baseProtocolLength+0, []string{"bulk"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestPeerPing(t *testing.T) {
 	closer, rw, _, _ := testPeer(nil)
 	defer closer()
@@ -52,6 +52,42 @@ type Protocol struct {
 	// about a certain peer in the network. If an info retrieval function is set,
 	// but returns nil, it is assumed that the protocol handshake is still running.
 	PeerInfo func(id discover.NodeID) interface{}
+
+	// Priority is an optional helper method to classify outgoing messages of
+	// the protocol. When the link to a peer is congested, writes of higher
+	// priority are granted access to the connection before lower ones. If
+	// not set, all messages are sent with PriorityNormal.
+	Priority func(code uint64) MsgPriority
+}
+
+// MsgPriority is the scheduling class of an outgoing message.
+type MsgPriority int
+
+const (
+	// PriorityBulk is meant for large, latency insensitive transfers such as
+	// state sync data or whisper envelopes.
+	PriorityBulk MsgPriority = iota
+
+	// PriorityNormal is the default class for protocol messages.
+	PriorityNormal
+
+	// PriorityHigh is meant for consensus critical messages like block and
+	// transaction propagation.
+	PriorityHigh
+
+	numPriorities = int(PriorityHigh) + 1
+)
+
+// priority returns the scheduling class of the given protocol message code.
+func (p Protocol) priority(code uint64) MsgPriority {
+	if p.Priority == nil {
+		return PriorityNormal
+	}
+	prio := p.Priority(code)
+	if prio < PriorityBulk || prio > PriorityHigh {
+		return PriorityNormal
+	}
+	return prio
 }
 
 func (p Protocol) cap() Cap {
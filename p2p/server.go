@@ -43,7 +43,7 @@ const (
 	// Maximum number of concurrently handshaking inbound connections.
 	maxAcceptConns = 50
 
-	// Maximum number of concurrently dialing outbound connections.
+	// Default maximum number of concurrently dialing outbound connections.
 	maxActiveDialTasks = 16
 
 	// Maximum time allowed for reading a complete message.
@@ -52,6 +52,10 @@ const (
 
 	// Maximum amount of time allowed for writing a complete message.
 	frameWriteTimeout = 20 * time.Second
+
+	// Minimum amount of time a dialed peer needs to stay connected to be
+	// remembered as a good peer across restarts.
+	goodPeerMinLifetime = 5 * time.Minute
 )
 
 var errServerStopped = errors.New("server stopped")
@@ -136,6 +140,21 @@ type Config struct {
 	// If NoDial is true, the server will not dial any peers.
 	NoDial bool `toml:",omitempty"`
 
+	// DialTimeout is the maximum amount of time allowed for establishing an
+	// outbound TCP connection when the default dialer is used. Zero defaults
+	// to 15 seconds.
+	DialTimeout time.Duration `toml:",omitempty"`
+
+	// HandshakeTimeout is the maximum amount of time allowed for completing
+	// the encryption and protocol handshakes of a new connection. Zero
+	// defaults to 5 seconds.
+	HandshakeTimeout time.Duration `toml:",omitempty"`
+
+	// MaxActiveDials is the maximum number of concurrently running dial
+	// tasks. Static and trusted nodes are dialed ahead of other candidates
+	// when the limit is reached. Zero defaults to 16.
+	MaxActiveDials int `toml:",omitempty"`
+
 	// If EnableMsgEvents is set then the server will emit PeerEvents
 	// whenever a message is sent to or received from a peer
 	EnableMsgEvents bool
@@ -198,6 +217,7 @@ type conn struct {
 	transport
 	flags connFlag
 	cont  chan error      // The run loop uses cont to signal errors to SetupConn.
+	dest  *discover.Node  // valid for dialed connections
 	id    discover.NodeID // valid after the encryption handshake
 	caps  []Cap           // valid after the protocol handshake
 	name  string          // valid after the protocol handshake
@@ -367,9 +387,20 @@ func (srv *Server) Start() (err error) {
 	}
 	if srv.newTransport == nil {
 		srv.newTransport = newRLPX
+		if timeout := srv.HandshakeTimeout; timeout != 0 {
+			srv.newTransport = func(fd net.Conn) transport {
+				t := newRLPX(fd)
+				fd.SetDeadline(time.Now().Add(timeout))
+				return t
+			}
+		}
 	}
 	if srv.Dialer == nil {
-		srv.Dialer = TCPDialer{&net.Dialer{Timeout: defaultDialTimeout}}
+		timeout := srv.DialTimeout
+		if timeout == 0 {
+			timeout = defaultDialTimeout
+		}
+		srv.Dialer = TCPDialer{&net.Dialer{Timeout: timeout}}
 	}
 	srv.quit = make(chan struct{})
 	srv.addpeer = make(chan *conn)
@@ -442,7 +473,7 @@ func (srv *Server) startListening() error {
 	srv.loopWG.Add(1)
 	go srv.listenLoop()
 	// Map the TCP listening port if NAT is configured.
-	if !laddr.IP.IsLoopback() && srv.NAT != nil {
+	if srv.NAT != nil && netutil.UsesNAT(laddr.IP) {
 		srv.loopWG.Add(1)
 		go func() {
 			nat.Map(srv.NAT, srv.quit, "tcp", laddr.Port, laddr.Port, "ethereum p2p")
@@ -464,10 +495,15 @@ func (srv *Server) run(dialstate dialer) {
 	var (
 		peers        = make(map[discover.NodeID]*Peer)
 		trusted      = make(map[discover.NodeID]bool, len(srv.TrustedNodes))
-		taskdone     = make(chan task, maxActiveDialTasks)
+		maxDials     = srv.MaxActiveDials
 		runningTasks []task
 		queuedTasks  []task // tasks that can't run yet
 	)
+	if maxDials <= 0 {
+		maxDials = maxActiveDialTasks
+	}
+	taskdone := make(chan task, maxDials)
+
 	// Put trusted nodes into a map to speed up checks.
 	// Trusted peers are loaded on startup and cannot be
 	// modified while the server is running.
@@ -487,7 +523,7 @@ func (srv *Server) run(dialstate dialer) {
 	// starts until max number of active tasks is satisfied
 	startTasks := func(ts []task) (rest []task) {
 		i := 0
-		for ; len(runningTasks) < maxActiveDialTasks && i < len(ts); i++ {
+		for ; len(runningTasks) < maxDials && i < len(ts); i++ {
 			t := ts[i]
 			log.Trace("New dial task", "task", t)
 			go func() { t.Do(srv); taskdone <- t }()
@@ -498,10 +534,12 @@ func (srv *Server) run(dialstate dialer) {
 	scheduleTasks := func() {
 		// Start from queue first.
 		queuedTasks = append(queuedTasks[:0], startTasks(queuedTasks)...)
-		// Query dialer for new tasks and start as many as possible now.
-		if len(runningTasks) < maxActiveDialTasks {
+		// Query dialer for new tasks and start as many as possible now,
+		// static and trusted nodes first.
+		if len(runningTasks) < maxDials {
 			nt := dialstate.newTasks(len(runningTasks)+len(queuedTasks), peers, time.Now())
-			queuedTasks = append(queuedTasks, startTasks(nt)...)
+			queuedTasks = append(queuedTasks, startTasks(prioritizeTasks(nt, trusted))...)
+			queuedTasks = prioritizeTasks(queuedTasks, trusted)
 		}
 	}
 
@@ -581,12 +619,18 @@ running:
 			// A peer disconnected.
 			d := common.PrettyDuration(mclock.Now() - pd.created)
 			pd.log.Debug("Removing p2p peer", "duration", d, "peers", len(peers)-1, "req", pd.requested, "err", pd.err)
+			srv.recordGoodPeer(pd.Peer, pd.err)
 			delete(peers, pd.ID())
 		}
 	}
 
 	log.Trace("P2P networking is spinning down")
 
+	// Remember the peers we're still connected to before the node database
+	// gets closed together with discovery.
+	for _, p := range peers {
+		srv.recordGoodPeer(p, nil)
+	}
 	// Terminate discovery. If there is a running lookup it will terminate soon.
 	if srv.ntab != nil {
 		srv.ntab.Close()
@@ -608,6 +652,39 @@ running:
 	}
 }
 
+// prioritizeTasks reorders the given tasks in place so that dials to static and
+// trusted nodes come before any other tasks, preserving their relative order.
+func prioritizeTasks(ts []task, trusted map[discover.NodeID]bool) []task {
+	var prio, rest []task
+	for _, t := range ts {
+		if dt, ok := t.(*dialTask); ok && (dt.flags&staticDialedConn != 0 || trusted[dt.dest.ID]) {
+			prio = append(prio, t)
+		} else {
+			rest = append(rest, t)
+		}
+	}
+	return append(append(ts[:0], prio...), rest...)
+}
+
+// recordGoodPeer stores a dialed peer in the node database if it stayed
+// connected long enough and wasn't dropped for misbehaving, so that it can be
+// preferred when dialing after a restart.
+func (srv *Server) recordGoodPeer(p *Peer, err error) {
+	if srv.ntab == nil || p.rw.dest == nil {
+		return
+	}
+	if time.Duration(mclock.Now()-p.created) < goodPeerMinLifetime {
+		return
+	}
+	if err != nil {
+		switch discReasonForError(err) {
+		case DiscProtocolError, DiscUselessPeer, DiscUnexpectedIdentity, DiscIncompatibleVersion:
+			return
+		}
+	}
+	srv.ntab.MarkGoodPeer(p.rw.dest)
+}
+
 func (srv *Server) protoHandshakeChecks(peers map[discover.NodeID]*Peer, c *conn) error {
 	// Drop connections with no matching protocols.
 	if len(srv.Protocols) > 0 && countMatchingProtocols(srv.Protocols, c.caps) == 0 {
@@ -703,7 +780,7 @@ func (srv *Server) SetupConn(fd net.Conn, flags connFlag, dialDest *discover.Nod
 	srv.lock.Lock()
 	running := srv.running
 	srv.lock.Unlock()
-	c := &conn{fd: fd, transport: srv.newTransport(fd), flags: flags, cont: make(chan error)}
+	c := &conn{fd: fd, transport: srv.newTransport(fd), flags: flags, cont: make(chan error), dest: dialDest}
 	if !running {
 		c.close(errServerStopped)
 		return
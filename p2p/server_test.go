@@ -287,6 +287,25 @@ func TestServerManyTasks(t *testing.T) {
 	}
 }
 
+// This test checks that dials to static and trusted nodes are moved ahead of
+// other tasks.
+func TestPrioritizeTasks(t *testing.T) {
+	var (
+		dyn1    = &dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(1)}}
+		dyn2    = &dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(2)}}
+		trusted = &dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(3)}}
+		static  = &dialTask{flags: staticDialedConn, dest: &discover.Node{ID: uintID(4)}}
+		lookup  = &discoverTask{}
+	)
+	tasks := prioritizeTasks([]task{dyn1, lookup, trusted, dyn2, static}, map[discover.NodeID]bool{uintID(3): true})
+	want := []task{trusted, static, dyn1, lookup, dyn2}
+	for i := range want {
+		if tasks[i] != want[i] {
+			t.Fatalf("task order mismatch: have %v, want %v", tasks, want)
+		}
+	}
+}
+
 type taskgen struct {
 	newFunc  func(running int, peers map[discover.NodeID]*Peer) []task
 	doneFunc func(task)
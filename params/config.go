@@ -26,6 +26,7 @@ import (
 var (
 	MainnetGenesisHash = common.HexToHash("0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3") // Mainnet genesis hash to enforce below configs on
 	TestnetGenesisHash = common.HexToHash("0x41941023680923e0fe4d74a34bdac8141f2540e3ae90623718e47d66d1ca4a2d") // Testnet genesis hash to enforce below configs on
+	RinkebyGenesisHash = common.HexToHash("0x6341fd3daf94b748c72ced5a5b26028f2474f5f00d824504e4fa37a75767e177") // Rinkeby genesis hash to enforce below configs on
 )
 
 var (
@@ -82,19 +83,31 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), new(EthashConfig), nil}
+	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, new(EthashConfig), nil}
 
 	// AllCliqueProtocolChanges contains every protocol change (EIPs) introduced
 	// and accepted by the Ethereum core developers into the Clique consensus.
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, &CliqueConfig{Period: 0, Epoch: 30000}}
+	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), new(EthashConfig), nil}
+	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, new(EthashConfig), nil}
 	TestRules       = TestChainConfig.Rules(new(big.Int))
 )
 
+// TrustedCheckpoint represents a set of post-processed trie roots (CHT and
+// BloomTrie) associated with the appropriate section index and head hash. It is
+// used to start light syncing from this checkpoint and avoid downloading the
+// entire header chain while still being able to securely access old headers/logs.
+type TrustedCheckpoint struct {
+	Name         string      `toml:",omitempty"`
+	SectionIndex uint64      // Index of the last section covered by the checkpoint
+	SectionHead  common.Hash // Hash of the last header in the section
+	CHTRoot      common.Hash // Root of the canonical hash trie up to the section
+	BloomRoot    common.Hash // Root of the bloom trie up to the section
+}
+
 // ChainConfig is the core config which determines the blockchain settings.
 //
 // ChainConfig is stored in the database on a per block basis. This means
@@ -117,11 +130,28 @@ type ChainConfig struct {
 
 	ByzantiumBlock *big.Int `json:"byzantiumBlock,omitempty"` // Byzantium switch block (nil = no fork, 0 = already on byzantium)
 
+	// Custom precompiled contracts, gas repricings and interpreters of private networks
+	Precompiles   map[common.Address]*PrecompileConfig `json:"precompiles,omitempty"`
+	GasRepricings []*GasRepricing                      `json:"gasRepricings,omitempty"` // Ordered by block
+	Interpreters  map[string]*big.Int                  `json:"interpreters,omitempty"`  // Activation blocks of alternative interpreters by name
+
 	// Various consensus engines
 	Ethash *EthashConfig `json:"ethash,omitempty"`
 	Clique *CliqueConfig `json:"clique,omitempty"`
 }
 
+// PrecompileConfig activates a natively implemented contract, registered with the
+// VM under the given name, at a custom address.
+type PrecompileConfig struct {
+	Name  string   `json:"name"`            // Name the implementation is registered with
+	Block *big.Int `json:"block,omitempty"` // Activation block (nil = inactive, 0 = genesis)
+}
+
+// IsActive returns whether num is either equal to the activation block or greater.
+func (c *PrecompileConfig) IsActive(num *big.Int) bool {
+	return isForked(c.Block, num)
+}
+
 // EthashConfig is the consensus engine configs for proof-of-work based sealing.
 type EthashConfig struct{}
 
@@ -191,21 +221,91 @@ func (c *ChainConfig) IsByzantium(num *big.Int) bool {
 	return isForked(c.ByzantiumBlock, num)
 }
 
-// GasTable returns the gas table corresponding to the current phase (homestead or homestead reprice).
+// GasTable returns the gas table in effect at num: the one of the last repricing
+// fork, changed by the last custom repricing scheduled at or before num. Prices
+// not set by a custom repricing keep the value in effect at its block.
 //
 // The returned GasTable's fields shouldn't, under any circumstances, be changed.
 func (c *ChainConfig) GasTable(num *big.Int) GasTable {
 	if num == nil {
 		return GasTableHomestead
 	}
-	switch {
-	case c.IsEIP158(num):
-		return GasTableEIP158
-	case c.IsEIP150(num):
-		return GasTableEIP150
-	default:
-		return GasTableHomestead
+	return c.gasTable(num, c.GasRepricings)
+}
+
+// gasTable returns the gas table in effect at num, considering only the given
+// custom repricings.
+func (c *ChainConfig) gasTable(num *big.Int, repricings []*GasRepricing) GasTable {
+	for i := len(repricings) - 1; i >= 0; i-- {
+		if isForked(repricings[i].Block, num) {
+			return repricings[i].Table.apply(c.gasTable(repricings[i].Block, repricings[:i]))
+		}
+	}
+	for i := len(forkGasTables) - 1; i >= 0; i-- {
+		if isForked(forkGasTables[i].block(c), num) {
+			return forkGasTables[i].table
+		}
+	}
+	return GasTableHomestead
+}
+
+// OpcodeCosts returns the fixed opcode costs replaced by the last custom repricing
+// scheduled at or before num, indexed by opcode name.
+//
+// The returned map shouldn't, under any circumstances, be changed.
+func (c *ChainConfig) OpcodeCosts(num *big.Int) map[string]uint64 {
+	if repricing := c.gasRepricing(num); repricing != nil {
+		return repricing.Opcodes
 	}
+	return nil
+}
+
+// gasRepricing returns the last custom gas repricing scheduled at or before num,
+// or nil if there's none.
+func (c *ChainConfig) gasRepricing(num *big.Int) *GasRepricing {
+	for i := len(c.GasRepricings) - 1; i >= 0; i-- {
+		if isForked(c.GasRepricings[i].Block, num) {
+			return c.GasRepricings[i]
+		}
+	}
+	return nil
+}
+
+// CheckConfigForkOrder checks that we don't "skip" any forks: a fork cannot be
+// scheduled before a preceding one, nor enabled if a preceding one is disabled.
+// The DAO fork is an optional, standalone transition and is not checked.
+func (c *ChainConfig) CheckConfigForkOrder() error {
+	type fork struct {
+		name  string
+		block *big.Int
+	}
+	var last fork
+	for _, cur := range []fork{
+		{"homesteadBlock", c.HomesteadBlock},
+		{"eip150Block", c.EIP150Block},
+		{"eip155Block", c.EIP155Block},
+		{"eip158Block", c.EIP158Block},
+		{"byzantiumBlock", c.ByzantiumBlock},
+	} {
+		if last.name != "" {
+			switch {
+			case last.block == nil && cur.block != nil:
+				return fmt.Errorf("unsupported fork ordering: %v not enabled, but %v enabled at %v", last.name, cur.name, cur.block)
+			case last.block != nil && cur.block != nil && last.block.Cmp(cur.block) > 0:
+				return fmt.Errorf("unsupported fork ordering: %v enabled at %v, but %v enabled at %v", last.name, last.block, cur.name, cur.block)
+			}
+		}
+		last = cur
+	}
+	for i, repricing := range c.GasRepricings {
+		switch {
+		case repricing.Block == nil:
+			return fmt.Errorf("unsupported gas repricing: #%d has no block", i)
+		case i > 0 && c.GasRepricings[i-1].Block.Cmp(repricing.Block) >= 0:
+			return fmt.Errorf("unsupported gas repricing ordering: #%d at %v, but #%d at %v", i-1, c.GasRepricings[i-1].Block, i, repricing.Block)
+		}
+	}
+	return nil
 }
 
 // CheckCompatible checks whether scheduled fork transitions have been imported
@@ -251,7 +351,113 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.ByzantiumBlock, newcfg.ByzantiumBlock, head) {
 		return newCompatError("Byzantium fork block", c.ByzantiumBlock, newcfg.ByzantiumBlock)
 	}
-	return nil
+	if err := checkPrecompilesCompatible(c.Precompiles, newcfg.Precompiles, head); err != nil {
+		return err
+	}
+	if err := checkInterpretersCompatible(c.Interpreters, newcfg.Interpreters, head); err != nil {
+		return err
+	}
+	return checkRepricingsCompatible(c, newcfg, head)
+}
+
+// checkInterpretersCompatible checks whether the alternative interpreters can be
+// rescheduled, which is only possible before their activation.
+func checkInterpretersCompatible(oldcfg, newcfg map[string]*big.Int, head *big.Int) *ConfigCompatError {
+	var lowest *ConfigCompatError
+	check := func(name string) {
+		if isForkIncompatible(oldcfg[name], newcfg[name], head) {
+			err := newCompatError(fmt.Sprintf("%s interpreter activation block", name), oldcfg[name], newcfg[name])
+			if lowest == nil || err.RewindTo < lowest.RewindTo {
+				lowest = err
+			}
+		}
+	}
+	for name := range oldcfg {
+		check(name)
+	}
+	for name := range newcfg {
+		check(name)
+	}
+	return lowest
+}
+
+// checkRepricingsCompatible checks whether the custom gas repricings can be changed,
+// which is only possible for the ones not yet in effect.
+func checkRepricingsCompatible(c, newcfg *ChainConfig, head *big.Int) *ConfigCompatError {
+	// Find the first block the gas tables of the two configs differ at
+	var blocks []*big.Int
+	for _, repricing := range append(append([]*GasRepricing{}, c.GasRepricings...), newcfg.GasRepricings...) {
+		if repricing.Block != nil {
+			blocks = append(blocks, repricing.Block)
+		}
+	}
+	var first *big.Int
+	for _, block := range blocks {
+		if !sameGasRepricing(c.gasRepricing(block), newcfg.gasRepricing(block)) && (first == nil || block.Cmp(first) < 0) {
+			first = block
+		}
+	}
+	if first == nil || !isForked(first, head) {
+		return nil
+	}
+	var s1, s2 *big.Int
+	if repricing := c.gasRepricing(first); repricing != nil {
+		s1 = repricing.Block
+	}
+	if repricing := newcfg.gasRepricing(first); repricing != nil {
+		s2 = repricing.Block
+	}
+	return newCompatError("gas repricing block", s1, s2)
+}
+
+// sameGasRepricing returns whether two optional gas repricings set the same costs.
+func sameGasRepricing(a, b *GasRepricing) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if !a.Table.equal(&b.Table) || len(a.Opcodes) != len(b.Opcodes) {
+		return false
+	}
+	for name, gas := range a.Opcodes {
+		if other, ok := b.Opcodes[name]; !ok || other != gas {
+			return false
+		}
+	}
+	return true
+}
+
+// checkPrecompilesCompatible checks whether the custom precompiled contracts can
+// be rescheduled or replaced, which is only possible before their activation.
+func checkPrecompilesCompatible(oldcfg, newcfg map[common.Address]*PrecompileConfig, head *big.Int) *ConfigCompatError {
+	addrs := make(map[common.Address]struct{})
+	for addr := range oldcfg {
+		addrs[addr] = struct{}{}
+	}
+	for addr := range newcfg {
+		addrs[addr] = struct{}{}
+	}
+	var lowest *ConfigCompatError
+	for addr := range addrs {
+		var s1, s2 *big.Int
+		if cfg := oldcfg[addr]; cfg != nil {
+			s1 = cfg.Block
+		}
+		if cfg := newcfg[addr]; cfg != nil {
+			s2 = cfg.Block
+		}
+		// Replacing the implementation is equivalent to disabling the old one
+		incompatible := isForkIncompatible(s1, s2, head)
+		if !incompatible && oldcfg[addr] != nil && newcfg[addr] != nil && oldcfg[addr].Name != newcfg[addr].Name {
+			incompatible, s2 = isForked(s1, head), nil
+		}
+		if incompatible {
+			err := newCompatError(fmt.Sprintf("precompile %x activation block", addr), s1, s2)
+			if lowest == nil || err.RewindTo < lowest.RewindTo {
+				lowest = err
+			}
+		}
+	}
+	return lowest
 }
 
 // isForkIncompatible returns true if a fork scheduled at s1 cannot be rescheduled to
@@ -17,12 +17,17 @@
 package params
 
 import (
+	"encoding/json"
 	"math/big"
 	"reflect"
 	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
 )
 
 func TestCheckCompatible(t *testing.T) {
+	expByte := uint64(10)
+
 	type test struct {
 		stored, new *ChainConfig
 		head        uint64
@@ -70,6 +75,73 @@ func TestCheckCompatible(t *testing.T) {
 				RewindTo:     9,
 			},
 		},
+		{
+			stored:  &ChainConfig{Precompiles: map[common.Address]*PrecompileConfig{{0x01, 0x00}: {Name: "a", Block: big.NewInt(10)}}},
+			new:     &ChainConfig{Precompiles: map[common.Address]*PrecompileConfig{{0x01, 0x00}: {Name: "b", Block: big.NewInt(20)}}},
+			head:    9,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{Precompiles: map[common.Address]*PrecompileConfig{{0x01, 0x00}: {Name: "a", Block: big.NewInt(10)}}},
+			new:    &ChainConfig{},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "precompile 0100000000000000000000000000000000000000 activation block",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    nil,
+				RewindTo:     9,
+			},
+		},
+		{
+			stored: &ChainConfig{Precompiles: map[common.Address]*PrecompileConfig{{0x01, 0x00}: {Name: "a", Block: big.NewInt(10)}}},
+			new:    &ChainConfig{Precompiles: map[common.Address]*PrecompileConfig{{0x01, 0x00}: {Name: "b", Block: big.NewInt(10)}}},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "precompile 0100000000000000000000000000000000000000 activation block",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    nil,
+				RewindTo:     9,
+			},
+		},
+		{
+			stored: &ChainConfig{Interpreters: map[string]*big.Int{"ewasm": big.NewInt(10)}},
+			new:    &ChainConfig{Interpreters: map[string]*big.Int{"ewasm": big.NewInt(20)}},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "ewasm interpreter activation block",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(20),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored:  &ChainConfig{GasRepricings: []*GasRepricing{{Block: big.NewInt(10)}}},
+			new:     &ChainConfig{GasRepricings: []*GasRepricing{{Block: big.NewInt(10)}, {Block: big.NewInt(20), Table: GasTableOverride{ExpByte: &expByte}}}},
+			head:    15,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{GasRepricings: []*GasRepricing{{Block: big.NewInt(10)}}},
+			new:    &ChainConfig{GasRepricings: []*GasRepricing{{Block: big.NewInt(10), Opcodes: map[string]uint64{"ADD": 5}}}},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "gas repricing block",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored: &ChainConfig{GasRepricings: []*GasRepricing{{Block: big.NewInt(10)}}},
+			new:    &ChainConfig{GasRepricings: []*GasRepricing{{Block: big.NewInt(10), Table: GasTableOverride{ExpByte: &expByte}}}},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "gas repricing block",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -79,3 +151,91 @@ func TestCheckCompatible(t *testing.T) {
 		}
 	}
 }
+
+func TestCheckConfigForkOrder(t *testing.T) {
+	tests := []struct {
+		config  *ChainConfig
+		wantErr bool
+	}{
+		{config: MainnetChainConfig},
+		{config: TestnetChainConfig},
+		{config: RinkebyChainConfig},
+		{config: AllEthashProtocolChanges},
+		{config: &ChainConfig{HomesteadBlock: big.NewInt(1)}},
+		{config: &ChainConfig{HomesteadBlock: big.NewInt(1), DAOForkBlock: big.NewInt(0), EIP150Block: big.NewInt(2)}},
+		{config: &ChainConfig{HomesteadBlock: big.NewInt(2), EIP150Block: big.NewInt(1)}, wantErr: true},
+		{config: &ChainConfig{HomesteadBlock: big.NewInt(0), ByzantiumBlock: big.NewInt(10)}, wantErr: true},
+		{config: &ChainConfig{GasRepricings: []*GasRepricing{{Block: big.NewInt(1)}, {Block: big.NewInt(2)}}}},
+		{config: &ChainConfig{GasRepricings: []*GasRepricing{{Block: big.NewInt(2)}, {Block: big.NewInt(2)}}}, wantErr: true},
+		{config: &ChainConfig{GasRepricings: []*GasRepricing{{Block: nil}}}, wantErr: true},
+	}
+	for i, test := range tests {
+		if err := test.config.CheckConfigForkOrder(); (err != nil) != test.wantErr {
+			t.Errorf("test %d: error mismatch: have %v, want error %v", i, err, test.wantErr)
+		}
+	}
+}
+
+// Tests that custom gas repricings change the gas tables from their block on, and
+// that prices they don't set keep the value in effect at their block.
+func TestGasRepricing(t *testing.T) {
+	var repricings []*GasRepricing
+	if err := json.Unmarshal([]byte(`[
+		{"block": 10, "table": {"sload": 800, "calls": 900}},
+		{"block": 20, "table": {"balance": 1000}, "opcodes": {"ADD": 5}}
+	]`), &repricings); err != nil {
+		t.Fatalf("failed to decode repricings: %v", err)
+	}
+	config := &ChainConfig{EIP150Block: big.NewInt(5), EIP158Block: big.NewInt(15), GasRepricings: repricings}
+
+	first := GasTableEIP150
+	first.SLoad, first.Calls = 800, 900
+
+	second := first
+	second.Balance = 1000
+
+	tests := []struct {
+		num     int64
+		table   GasTable
+		opcodes map[string]uint64
+	}{
+		{9, GasTableEIP150, nil},
+		{10, first, nil},
+		{15, first, nil}, // forks after a repricing don't change the prices it keeps
+		{20, second, map[string]uint64{"ADD": 5}},
+	}
+	for _, tt := range tests {
+		if table := config.GasTable(big.NewInt(tt.num)); table != tt.table {
+			t.Errorf("block %d: gas table mismatch: have %+v, want %+v", tt.num, table, tt.table)
+		}
+		if costs := config.OpcodeCosts(big.NewInt(tt.num)); len(costs) != len(tt.opcodes) || costs["ADD"] != tt.opcodes["ADD"] {
+			t.Errorf("block %d: opcode costs mismatch: have %v, want %v", tt.num, costs, tt.opcodes)
+		}
+	}
+	// Prices can be explicitly set to zero
+	var suicide uint64
+	config.GasRepricings[1].Table.Suicide = &suicide
+	if table := config.GasTable(big.NewInt(20)); table.Suicide != 0 || table.Balance != 1000 {
+		t.Errorf("gas table mismatch with free suicides: have %+v", table)
+	}
+}
+
+// Tests that the gas tables of the repricing forks are selected by their blocks.
+func TestForkGasTables(t *testing.T) {
+	config := &ChainConfig{EIP150Block: big.NewInt(5), EIP158Block: big.NewInt(10)}
+	tests := []struct {
+		num   int64
+		table GasTable
+	}{
+		{0, GasTableHomestead},
+		{4, GasTableHomestead},
+		{5, GasTableEIP150},
+		{9, GasTableEIP150},
+		{10, GasTableEIP158},
+	}
+	for _, tt := range tests {
+		if table := config.GasTable(big.NewInt(tt.num)); table != tt.table {
+			t.Errorf("block %d: gas table mismatch: have %+v, want %+v", tt.num, table, tt.table)
+		}
+	}
+}
@@ -16,22 +16,89 @@
 
 package params
 
+import "math/big"
+
+// GasTable contains the gas prices of the operations that have been repriced by
+// the forks. Private networks may schedule their own repricings in the chain
+// config (see GasRepricing).
 type GasTable struct {
-	ExtcodeSize uint64
-	ExtcodeCopy uint64
-	Balance     uint64
-	SLoad       uint64
-	Calls       uint64
-	Suicide     uint64
+	ExtcodeSize uint64 `json:"extcodeSize"`
+	ExtcodeCopy uint64 `json:"extcodeCopy"`
+	Balance     uint64 `json:"balance"`
+	SLoad       uint64 `json:"sload"`
+	Calls       uint64 `json:"calls"`
+	Suicide     uint64 `json:"suicide"`
 
-	ExpByte uint64
+	ExpByte uint64 `json:"expByte"`
 
 	// CreateBySuicide occurs when the
 	// refunded account is one that does
 	// not exist. This logic is similar
 	// to call. May be left nil. Nil means
 	// not charged.
-	CreateBySuicide uint64
+	CreateBySuicide uint64 `json:"createBySuicide"`
+}
+
+// GasRepricing changes the gas table in effect from the given block on, and
+// optionally the fixed costs of some opcodes.
+type GasRepricing struct {
+	Block   *big.Int          `json:"block"`             // Repricing switch block
+	Table   GasTableOverride  `json:"table"`             // Gas table changes in effect from the switch block on
+	Opcodes map[string]uint64 `json:"opcodes,omitempty"` // Fixed costs of opcodes (by name) replacing the built-in ones
+}
+
+// GasTableOverride contains the gas prices changed by a custom repricing. Prices
+// left nil keep the value of the gas table in effect at the repricing's block.
+type GasTableOverride struct {
+	ExtcodeSize     *uint64 `json:"extcodeSize,omitempty"`
+	ExtcodeCopy     *uint64 `json:"extcodeCopy,omitempty"`
+	Balance         *uint64 `json:"balance,omitempty"`
+	SLoad           *uint64 `json:"sload,omitempty"`
+	Calls           *uint64 `json:"calls,omitempty"`
+	Suicide         *uint64 `json:"suicide,omitempty"`
+	ExpByte         *uint64 `json:"expByte,omitempty"`
+	CreateBySuicide *uint64 `json:"createBySuicide,omitempty"`
+}
+
+// apply returns the gas table with the prices set by the override replaced.
+func (o *GasTableOverride) apply(table GasTable) GasTable {
+	override := func(field *uint64, price *uint64) {
+		if price != nil {
+			*field = *price
+		}
+	}
+	override(&table.ExtcodeSize, o.ExtcodeSize)
+	override(&table.ExtcodeCopy, o.ExtcodeCopy)
+	override(&table.Balance, o.Balance)
+	override(&table.SLoad, o.SLoad)
+	override(&table.Calls, o.Calls)
+	override(&table.Suicide, o.Suicide)
+	override(&table.ExpByte, o.ExpByte)
+	override(&table.CreateBySuicide, o.CreateBySuicide)
+	return table
+}
+
+// equal returns whether two overrides set the same prices.
+func (o *GasTableOverride) equal(other *GasTableOverride) bool {
+	same := func(a, b *uint64) bool {
+		if a == nil || b == nil {
+			return a == b
+		}
+		return *a == *b
+	}
+	return same(o.ExtcodeSize, other.ExtcodeSize) && same(o.ExtcodeCopy, other.ExtcodeCopy) &&
+		same(o.Balance, other.Balance) && same(o.SLoad, other.SLoad) && same(o.Calls, other.Calls) &&
+		same(o.Suicide, other.Suicide) && same(o.ExpByte, other.ExpByte) && same(o.CreateBySuicide, other.CreateBySuicide)
+}
+
+// forkGasTables are the gas tables of the built-in repricing forks in activation
+// order, along with the chain config field scheduling each.
+var forkGasTables = []struct {
+	block func(c *ChainConfig) *big.Int
+	table GasTable
+}{
+	{func(c *ChainConfig) *big.Int { return c.EIP150Block }, GasTableEIP150},
+	{func(c *ChainConfig) *big.Int { return c.EIP158Block }, GasTableEIP158},
 }
 
 var (
@@ -23,4 +23,16 @@ const (
 	// BloomBitsBlocks is the number of blocks a single bloom bit section vector
 	// contains.
 	BloomBitsBlocks uint64 = 4096
+
+	// ImmutabilityThreshold is the number of blocks after which a chain segment is
+	// considered immutable (i.e. soft finality). It is used by the chain data
+	// freezer to decide which blocks can be moved out of the active database.
+	ImmutabilityThreshold = 90000
+
+	// MainnetSafeDepth, TestnetSafeDepth and RinkebySafeDepth are the number of
+	// blocks below the chain head after which blocks are considered safe from
+	// reorganisations on the respective networks.
+	MainnetSafeDepth = 12
+	TestnetSafeDepth = 24
+	RinkebySafeDepth = 6
 )
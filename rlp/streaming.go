@@ -0,0 +1,135 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rlp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrElemSizeLimit is returned by ListReader if an element of the list is larger
+// than the configured limit.
+var ErrElemSizeLimit = errors.New("rlp: element exceeds size limit")
+
+// EncodedSize returns the size of the RLP encoding of val. It can be used to
+// compute the content size of a list before streaming it out with a ListWriter.
+func EncodedSize(val interface{}) (uint64, error) {
+	eb := encbufPool.Get().(*encbuf)
+	defer encbufPool.Put(eb)
+	eb.reset()
+	if err := eb.encode(val); err != nil {
+		return 0, err
+	}
+	return uint64(eb.size()), nil
+}
+
+// ListWriter encodes an RLP list piecemeal into an output stream, without holding
+// more than a single element in memory. Since the list header contains the size
+// of the list, the total size of the encoded elements needs to be known upfront
+// (e.g. by summing their EncodedSize in a first pass).
+//
+// ListWriter is not safe for concurrent use.
+type ListWriter struct {
+	w       io.Writer
+	size    uint64 // Declared content size of the list
+	written uint64 // Content bytes written so far
+}
+
+// NewListWriter writes the header of a list with the given content size to w
+// and returns a writer for the elements.
+func NewListWriter(w io.Writer, size uint64) (*ListWriter, error) {
+	head := make([]byte, 9)
+	n := puthead(head, 0xC0, 0xF7, size)
+	if _, err := w.Write(head[:n]); err != nil {
+		return nil, err
+	}
+	return &ListWriter{w: w, size: size}, nil
+}
+
+// Encode encodes val as the next element of the list. Please see the
+// documentation of Encode for the encoding rules.
+func (lw *ListWriter) Encode(val interface{}) error {
+	eb := encbufPool.Get().(*encbuf)
+	defer encbufPool.Put(eb)
+	eb.reset()
+	if err := eb.encode(val); err != nil {
+		return err
+	}
+	if size := uint64(eb.size()); lw.written+size > lw.size {
+		return fmt.Errorf("rlp: list content exceeds declared size %d", lw.size)
+	}
+	lw.written += uint64(eb.size())
+	return eb.toWriter(lw.w)
+}
+
+// Close verifies that the written elements filled the list exactly. It does not
+// close the underlying writer.
+func (lw *ListWriter) Close() error {
+	if lw.written != lw.size {
+		return fmt.Errorf("rlp: list content size %d, declared %d", lw.written, lw.size)
+	}
+	return nil
+}
+
+// ListReader decodes an RLP list piecemeal from an input stream, without holding
+// more than a single element in memory. Each element is checked against a size
+// limit before being decoded, protecting against allocating huge buffers on
+// malicious or corrupt input.
+//
+// ListReader is not safe for concurrent use.
+type ListReader struct {
+	s     *Stream
+	size  uint64 // Content size of the list
+	limit uint64 // Maximum size of a single element, zero if unlimited
+}
+
+// NewListReader reads the header of a list from r and returns a reader for its
+// elements. Elements larger than elemLimit bytes are rejected with
+// ErrElemSizeLimit, unless the limit is zero.
+func NewListReader(r io.Reader, elemLimit uint64) (*ListReader, error) {
+	s := NewStream(r, 0)
+	size, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	return &ListReader{s: s, size: size, limit: elemLimit}, nil
+}
+
+// Size returns the content size of the list.
+func (lr *ListReader) Size() uint64 {
+	return lr.size
+}
+
+// Decode decodes the next element of the list into the value pointed to by val.
+// It returns io.EOF once all elements have been read. Please see the
+// documentation of Decode for the decoding rules.
+func (lr *ListReader) Decode(val interface{}) error {
+	_, size, err := lr.s.Kind()
+	switch {
+	case err == EOL:
+		if err := lr.s.ListEnd(); err != nil {
+			return err
+		}
+		return io.EOF
+	case err != nil:
+		return err
+	case lr.limit > 0 && size > lr.limit:
+		return ErrElemSizeLimit
+	}
+	return lr.s.Decode(val)
+}
@@ -0,0 +1,126 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rlp
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+)
+
+type streamingTestElem struct {
+	Index uint64
+	Data  []byte
+}
+
+// Tests that lists streamed out with a ListWriter can be streamed back in with a
+// ListReader, through a pipe so neither side sees the whole list.
+func TestListStreaming(t *testing.T) {
+	elems := make([]streamingTestElem, 10000)
+	for i := range elems {
+		elems[i] = streamingTestElem{Index: uint64(i), Data: bytes.Repeat([]byte{byte(i)}, i%100)}
+	}
+	var size uint64
+	for _, elem := range elems {
+		n, err := EncodedSize(elem)
+		if err != nil {
+			t.Fatalf("failed to size element: %v", err)
+		}
+		size += n
+	}
+	r, w := io.Pipe()
+	go func() {
+		lw, err := NewListWriter(w, size)
+		if err != nil {
+			w.CloseWithError(err)
+			return
+		}
+		for _, elem := range elems {
+			if err := lw.Encode(elem); err != nil {
+				w.CloseWithError(err)
+				return
+			}
+		}
+		w.CloseWithError(lw.Close())
+	}()
+	lr, err := NewListReader(r, 256)
+	if err != nil {
+		t.Fatalf("failed to open list: %v", err)
+	}
+	if lr.Size() != size {
+		t.Errorf("list size mismatch: have %d, want %d", lr.Size(), size)
+	}
+	for i := 0; ; i++ {
+		var elem streamingTestElem
+		if err := lr.Decode(&elem); err == io.EOF {
+			if i != len(elems) {
+				t.Fatalf("element count mismatch: have %d, want %d", i, len(elems))
+			}
+			break
+		} else if err != nil {
+			t.Fatalf("element %d: failed to decode: %v", i, err)
+		}
+		if elem.Index != elems[i].Index || !bytes.Equal(elem.Data, elems[i].Data) {
+			t.Fatalf("element %d: mismatch: have %v, want %v", i, elem, elems[i])
+		}
+	}
+}
+
+// Tests that ListWriter enforces the declared list size.
+func TestListWriterSize(t *testing.T) {
+	// Exceeding the declared size must fail without writing the element
+	buf := new(bytes.Buffer)
+	lw, _ := NewListWriter(buf, 2)
+	if err := lw.Encode([]byte("abc")); err == nil {
+		t.Errorf("oversized element accepted")
+	}
+	if err := lw.Encode(uint(1)); err != nil {
+		t.Errorf("failed to encode element: %v", err)
+	}
+	// Closing a list that's not full must fail
+	if err := lw.Close(); err == nil {
+		t.Errorf("incomplete list accepted")
+	}
+	if err := lw.Encode(uint(2)); err != nil {
+		t.Errorf("failed to encode element: %v", err)
+	}
+	if err := lw.Close(); err != nil {
+		t.Errorf("failed to close full list: %v", err)
+	}
+	if want := unhex("C20102"); !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("output mismatch: have %x, want %x", buf.Bytes(), want)
+	}
+}
+
+// Tests that ListReader rejects elements above the size limit before reading
+// them, and inputs that aren't lists.
+func TestListReaderLimits(t *testing.T) {
+	// A list header claiming a huge string must not be allocated, even if the
+	// length of the input is unknown
+	lr, err := NewListReader(bufio.NewReader(bytes.NewReader(unhex("F8 40 B8 3E 01"))), 32)
+	if err != nil {
+		t.Fatalf("failed to open list: %v", err)
+	}
+	var data []byte
+	if err := lr.Decode(&data); err != ErrElemSizeLimit {
+		t.Errorf("oversized element error mismatch: have %v, want %v", err, ErrElemSizeLimit)
+	}
+	if _, err := NewListReader(bytes.NewReader(unhex("83 010203")), 32); err != ErrExpectedList {
+		t.Errorf("non-list error mismatch: have %v, want %v", err, ErrExpectedList)
+	}
+}
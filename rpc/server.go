@@ -24,6 +24,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/ethereum/go-ethereum/log"
 	"gopkg.in/fatih/set.v0"
@@ -173,7 +174,7 @@ func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecO
 
 		// check if server is ordered to shutdown and return an error
 		// telling the client that his request failed.
-		if atomic.LoadInt32(&s.run) != 1 {
+		if !s.admit() {
 			err = &shutdownError{}
 			if batch {
 				resps := make([]interface{}, len(reqs))
@@ -188,6 +189,7 @@ func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecO
 		}
 		// If a single shot request is executing, run and return immediately
 		if singleShot {
+			defer s.inflight.Done()
 			if batch {
 				s.execBatch(ctx, codec, reqs)
 			} else {
@@ -200,6 +202,7 @@ func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecO
 
 		go func(reqs []*serverRequest, batch bool) {
 			defer pend.Done()
+			defer s.inflight.Done()
 			if batch {
 				s.execBatch(ctx, codec, reqs)
 			} else {
@@ -225,8 +228,43 @@ func (s *Server) ServeSingleRequest(codec ServerCodec, options CodecOption) {
 	s.serveRequest(codec, true, options)
 }
 
-// Stop will stop reading new requests, wait for stopPendingRequestTimeout to allow pending requests to finish,
-// close all codecs which will cancel pending requests/subscriptions.
+// admit registers a request for execution, unless the server is shutting down.
+func (s *Server) admit() bool {
+	s.inflightMu.RLock()
+	defer s.inflightMu.RUnlock()
+
+	if atomic.LoadInt32(&s.run) != 1 || atomic.LoadInt32(&s.draining) != 0 {
+		return false
+	}
+	s.inflight.Add(1)
+	return true
+}
+
+// Drain stops accepting new requests, rejecting them with a shutdown error, and
+// waits at most the given timeout for the requests being executed to finish. It
+// reports whether all of them finished in time. The server still needs to be
+// stopped afterwards.
+func (s *Server) Drain(timeout time.Duration) bool {
+	s.inflightMu.Lock()
+	atomic.StoreInt32(&s.draining, 1)
+	s.inflightMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Stop will stop reading new requests and close all codecs which will cancel
+// pending requests/subscriptions. Use Drain beforehand to let the requests being
+// executed finish.
 func (s *Server) Stop() {
 	if atomic.CompareAndSwapInt32(&s.run, 1, 0) {
 		log.Debug("RPC Server shutdown initiatied")
@@ -255,7 +293,9 @@ func (s *Server) createSubscription(ctx context.Context, c ServerCodec, req *ser
 
 // handle executes a request and returns the response from the callback.
 func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverRequest) (interface{}, func()) {
+	rpcRequestMeter.Mark(1)
 	if req.err != nil {
+		rpcFailureMeter.Mark(1)
 		return codec.CreateErrorResponse(&req.id, req.err), nil
 	}
 
@@ -308,18 +348,24 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 	}
 
 	// execute RPC method and return result
+	start := time.Now()
 	reply := req.callb.method.Func.Call(arguments)
+	rpcServingTimer.UpdateSince(start)
+
 	if len(reply) == 0 {
+		rpcSuccessMeter.Mark(1)
 		return codec.CreateResponse(req.id, nil), nil
 	}
 
 	if req.callb.errPos >= 0 { // test if method returned an error
 		if !reply[req.callb.errPos].IsNil() {
+			rpcFailureMeter.Mark(1)
 			e := reply[req.callb.errPos].Interface().(error)
 			res := codec.CreateErrorResponse(&req.id, &callbackError{e.Error()})
 			return res, nil
 		}
 	}
+	rpcSuccessMeter.Mark(1)
 	return codec.CreateResponse(req.id, reply[0].Interface()), nil
 }
 
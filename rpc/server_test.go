@@ -160,3 +160,50 @@ func TestServerMethodExecution(t *testing.T) {
 func TestServerMethodWithCtx(t *testing.T) {
 	testServerMethodExecution(t, "echoWithCtx")
 }
+
+// Tests that draining a server lets the requests being executed finish, while new
+// ones are rejected.
+func TestServerDrain(t *testing.T) {
+	server := newTestServer("service", new(Service))
+	defer server.Stop()
+
+	client := DialInProc(server)
+	defer client.Close()
+
+	// Start a slow request and drain the server while it's running
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Call(nil, "service_sleep", 200*time.Millisecond)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	if !server.Drain(time.Second) {
+		t.Fatalf("running request didn't finish in time")
+	}
+	if err := <-done; err != nil {
+		t.Errorf("running request failed: %v", err)
+	}
+	// New requests are rejected, and hence finish immediately
+	if err := client.Call(nil, "service_sleep", time.Second); err == nil {
+		t.Errorf("request accepted while draining")
+	}
+	if !server.Drain(100 * time.Millisecond) {
+		t.Errorf("rejected request still running")
+	}
+}
+
+// Tests that draining gives up if the requests don't finish in time.
+func TestServerDrainTimeout(t *testing.T) {
+	server := newTestServer("service", new(Service))
+	defer server.Stop()
+
+	client := DialInProc(server)
+	defer client.Close()
+
+	go client.Call(nil, "service_sleep", time.Second)
+	time.Sleep(50 * time.Millisecond)
+
+	if server.Drain(50 * time.Millisecond) {
+		t.Fatalf("drain didn't time out")
+	}
+}
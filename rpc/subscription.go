@@ -29,6 +29,11 @@ var (
 	ErrSubscriptionNotFound = errors.New("subscription not found")
 )
 
+// maxPendingNotifications is the number of notifications buffered for a subscription
+// until it's activated. Subscriptions exceeding it are dropped, as they are not
+// going to be activated in time anymore, if at all.
+const maxPendingNotifications = 10000
+
 // ID defines a pseudo random number that is used to identify RPC subscriptions.
 type ID string
 
@@ -37,7 +42,8 @@ type ID string
 type Subscription struct {
 	ID        ID
 	namespace string
-	err       chan error // closed on unsubscribe
+	err       chan error    // closed on unsubscribe
+	pending   []interface{} // notifications sent before activation, delivered on activation
 }
 
 // Err returns a channel that is closed when the client send an unsubscribe request.
@@ -52,7 +58,7 @@ type notifierKey struct{}
 // Server callbacks use the notifier to send notifications.
 type Notifier struct {
 	codec    ServerCodec
-	subMu    sync.RWMutex // guards active and inactive maps
+	subMu    sync.Mutex // guards active and inactive maps, serializes notifications
 	active   map[ID]*Subscription
 	inactive map[ID]*Subscription
 }
@@ -75,7 +81,7 @@ func NotifierFromContext(ctx context.Context) (*Notifier, bool) {
 
 // CreateSubscription returns a new subscription that is coupled to the
 // RPC connection. By default subscriptions are inactive and notifications
-// are dropped until the subscription is marked as active. This is done
+// are buffered until the subscription is marked as active. This is done
 // by the RPC server after the subscription ID is send to the client.
 func (n *Notifier) CreateSubscription() *Subscription {
 	s := &Subscription{ID: NewID(), err: make(chan error)}
@@ -87,17 +93,40 @@ func (n *Notifier) CreateSubscription() *Subscription {
 
 // Notify sends a notification to the client with the given data as payload.
 // If an error occurs the RPC connection is closed and the error is returned.
+// Inactive subscriptions buffering too many notifications are dropped, returning
+// ErrSubscriptionNotFound.
 func (n *Notifier) Notify(id ID, data interface{}) error {
-	n.subMu.RLock()
-	defer n.subMu.RUnlock()
-
-	sub, active := n.active[id]
-	if active {
-		notification := n.codec.CreateNotification(string(id), sub.namespace, data)
-		if err := n.codec.Write(notification); err != nil {
-			n.codec.Close()
-			return err
+	n.subMu.Lock()
+	defer n.subMu.Unlock()
+
+	if sub, active := n.active[id]; active {
+		return n.send(sub, data)
+	}
+	if sub, inactive := n.inactive[id]; inactive {
+		if len(sub.pending) >= maxPendingNotifications {
+			n.drop(sub)
+			return ErrSubscriptionNotFound
 		}
+		sub.pending = append(sub.pending, data)
+	}
+	return nil
+}
+
+// drop discards an inactive subscription along with its buffered notifications,
+// signalling the service to stop sending any. The subscription lock must be held.
+func (n *Notifier) drop(sub *Subscription) {
+	delete(n.inactive, sub.ID)
+	sub.pending = nil
+	close(sub.err)
+}
+
+// send writes a notification of a subscription to the client. The subscription
+// lock must be held.
+func (n *Notifier) send(sub *Subscription, data interface{}) error {
+	notification := n.codec.CreateNotification(string(sub.ID), sub.namespace, data)
+	if err := n.codec.Write(notification); err != nil {
+		n.codec.Close()
+		return err
 	}
 	return nil
 }
@@ -120,16 +149,30 @@ func (n *Notifier) unsubscribe(id ID) error {
 	return ErrSubscriptionNotFound
 }
 
-// activate enables a subscription. Until a subscription is enabled all
-// notifications are dropped. This method is called by the RPC server after
-// the subscription ID was sent to client. This prevents notifications being
-// send to the client before the subscription ID is send to the client.
+// activate enables a subscription, delivering the notifications buffered until
+// then. This method is called by the RPC server after the subscription ID was
+// sent to client. This prevents notifications being send to the client before
+// the subscription ID is send to the client. If the ID couldn't be sent, the
+// connection is closed and the subscription is dropped instead.
 func (n *Notifier) activate(id ID, namespace string) {
 	n.subMu.Lock()
 	defer n.subMu.Unlock()
 	if sub, found := n.inactive[id]; found {
+		select {
+		case <-n.codec.Closed():
+			n.drop(sub)
+			return
+		default:
+		}
 		sub.namespace = namespace
 		n.active[id] = sub
 		delete(n.inactive, id)
+
+		for _, data := range sub.pending {
+			if err := n.send(sub, data); err != nil {
+				break
+			}
+		}
+		sub.pending = nil
 	}
 }
@@ -89,6 +89,22 @@ func (s *NotificationTestService) SomeSubscription(ctx context.Context, n, val i
 
 // HangSubscription blocks on s.unblockHangSubscription before
 // sending anything.
+// EagerSubscription sends all its notifications before the subscription is even
+// returned, i.e. before it is activated.
+func (s *NotificationTestService) EagerSubscription(ctx context.Context, n, val int) (*Subscription, error) {
+	notifier, supported := NotifierFromContext(ctx)
+	if !supported {
+		return nil, ErrNotificationsUnsupported
+	}
+	subscription := notifier.CreateSubscription()
+	for i := 0; i < n; i++ {
+		if err := notifier.Notify(subscription.ID, val+i); err != nil {
+			return nil, err
+		}
+	}
+	return subscription, nil
+}
+
 func (s *NotificationTestService) HangSubscription(ctx context.Context, val int) (*Subscription, error) {
 	notifier, supported := NotifierFromContext(ctx)
 	if !supported {
@@ -328,3 +344,73 @@ func TestSubscriptionMultipleNamespaces(t *testing.T) {
 		}
 	}
 }
+
+// Tests that notifications sent before a subscription is activated are delivered
+// once it is, in order.
+func TestSubscriptionBuffering(t *testing.T) {
+	server := NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", new(NotificationTestService)); err != nil {
+		t.Fatalf("unable to register test service %v", err)
+	}
+	client := DialInProc(server)
+	defer client.Close()
+
+	nc := make(chan int)
+	sub, err := client.EthSubscribe(context.Background(), nc, "eagerSubscription", 10, 0)
+	if err != nil {
+		t.Fatal("can't subscribe:", err)
+	}
+	defer sub.Unsubscribe()
+
+	for i := 0; i < 10; i++ {
+		select {
+		case val := <-nc:
+			if val != i {
+				t.Fatalf("value mismatch: got %d, want %d", val, i)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("notification %d not delivered", i)
+		}
+	}
+}
+
+// Tests that inactive subscriptions don't buffer notifications without bound, and
+// that they are dropped along with their buffer if they can't be activated.
+func TestSubscriptionBufferLimit(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	notifier := newNotifier(NewJSONCodec(server))
+	sub := notifier.CreateSubscription()
+	for i := 0; i < maxPendingNotifications; i++ {
+		if err := notifier.Notify(sub.ID, i); err != nil {
+			t.Fatalf("notification %d rejected: %v", i, err)
+		}
+	}
+	if err := notifier.Notify(sub.ID, maxPendingNotifications); err != ErrSubscriptionNotFound {
+		t.Fatalf("notification beyond the limit error mismatch: have %v, want %v", err, ErrSubscriptionNotFound)
+	}
+	select {
+	case <-sub.Err():
+	default:
+		t.Fatalf("overflowing subscription not dropped")
+	}
+	if len(notifier.inactive) != 0 || sub.pending != nil {
+		t.Fatalf("overflowing subscription still buffered")
+	}
+	// Subscriptions whose ID couldn't be sent are not activated
+	sub = notifier.CreateSubscription()
+	notifier.Notify(sub.ID, 0)
+	notifier.codec.Close()
+	notifier.activate(sub.ID, "eth")
+
+	select {
+	case <-sub.Err():
+	default:
+		t.Fatalf("unsent subscription not dropped")
+	}
+	if len(notifier.inactive) != 0 || len(notifier.active) != 0 || sub.pending != nil {
+		t.Fatalf("unsent subscription still tracked")
+	}
+}
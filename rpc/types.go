@@ -74,6 +74,10 @@ type Server struct {
 	run      int32
 	codecsMu sync.Mutex
 	codecs   *set.Set
+
+	draining   int32          // Set when no new requests are accepted anymore
+	inflight   sync.WaitGroup // Requests currently being executed
+	inflightMu sync.RWMutex   // Orders request admission against draining
 }
 
 // rpcRequest represents a raw incoming RPC request
@@ -119,6 +123,7 @@ type ServerCodec interface {
 type BlockNumber int64
 
 const (
+	SafeBlockNumber     = BlockNumber(-3)
 	PendingBlockNumber  = BlockNumber(-2)
 	LatestBlockNumber   = BlockNumber(-1)
 	EarliestBlockNumber = BlockNumber(0)
@@ -146,6 +151,9 @@ func (bn *BlockNumber) UnmarshalJSON(data []byte) error {
 	case "pending":
 		*bn = PendingBlockNumber
 		return nil
+	case "safe":
+		*bn = SafeBlockNumber
+		return nil
 	}
 
 	blckNum, err := hexutil.DecodeUint64(input)
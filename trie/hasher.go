@@ -30,6 +30,7 @@ type hasher struct {
 	tmp                  *bytes.Buffer
 	sha                  hash.Hash
 	cachegen, cachelimit uint16
+	parallel             bool // Whether to hash the children of the topmost full node concurrently
 }
 
 // hashers live in a global pool.
@@ -39,9 +40,9 @@ var hasherPool = sync.Pool{
 	},
 }
 
-func newHasher(cachegen, cachelimit uint16) *hasher {
+func newHasher(cachegen, cachelimit uint16, parallel bool) *hasher {
 	h := hasherPool.Get().(*hasher)
-	h.cachegen, h.cachelimit = cachegen, cachelimit
+	h.cachegen, h.cachelimit, h.parallel = cachegen, cachelimit, parallel
 	return h
 }
 
@@ -123,14 +124,20 @@ func (h *hasher) hashChildren(original node, db DatabaseWriter) (node, node, err
 		// Hash the full node's children, caching the newly hashed subtrees
 		collapsed, cached := n.copy(), n.copy()
 
-		for i := 0; i < 16; i++ {
-			if n.Children[i] != nil {
-				collapsed.Children[i], cached.Children[i], err = h.hash(n.Children[i], db, false)
-				if err != nil {
-					return original, original, err
+		if h.parallel {
+			if err := h.hashChildrenParallel(n, collapsed, cached, db); err != nil {
+				return original, original, err
+			}
+		} else {
+			for i := 0; i < 16; i++ {
+				if n.Children[i] != nil {
+					collapsed.Children[i], cached.Children[i], err = h.hash(n.Children[i], db, false)
+					if err != nil {
+						return original, original, err
+					}
+				} else {
+					collapsed.Children[i] = valueNode(nil) // Ensure that nil children are encoded as empty strings.
 				}
-			} else {
-				collapsed.Children[i] = valueNode(nil) // Ensure that nil children are encoded as empty strings.
 			}
 		}
 		cached.Children[16] = n.Children[16]
@@ -145,6 +152,51 @@ func (h *hasher) hashChildren(original node, db DatabaseWriter) (node, node, err
 	}
 }
 
+// hashChildrenParallel hashes the children of a full node concurrently, each with
+// its own sequential hasher. Database writers are not safe for concurrent use, so
+// the nodes to store are collected per child and written out once all children
+// are done.
+func (h *hasher) hashChildrenParallel(n, collapsed, cached *fullNode, db DatabaseWriter) error {
+	var (
+		batches [16]*nodeBatch
+		errs    [16]error
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < 16; i++ {
+		if n.Children[i] == nil {
+			collapsed.Children[i] = valueNode(nil) // Ensure that nil children are encoded as empty strings.
+			continue
+		}
+		var writer DatabaseWriter
+		if db != nil {
+			batches[i] = new(nodeBatch)
+			writer = batches[i]
+		}
+		wg.Add(1)
+		go func(i int, writer DatabaseWriter) {
+			defer wg.Done()
+
+			hasher := newHasher(h.cachegen, h.cachelimit, false)
+			defer returnHasherToPool(hasher)
+
+			collapsed.Children[i], cached.Children[i], errs[i] = hasher.hash(n.Children[i], writer, false)
+		}(i, writer)
+	}
+	wg.Wait()
+
+	for i := 0; i < 16; i++ {
+		if errs[i] != nil {
+			return errs[i]
+		}
+		if batches[i] != nil {
+			if err := batches[i].flush(db); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (h *hasher) store(n node, db DatabaseWriter, force bool) (node, error) {
 	// Don't store hashes or empty nodes.
 	if _, isHash := n.(hashNode); n == nil || isHash {
@@ -171,3 +223,25 @@ func (h *hasher) store(n node, db DatabaseWriter, force bool) (node, error) {
 	}
 	return hash, nil
 }
+
+// nodeBatch collects the nodes stored by a hasher, to be written to a database
+// later on. The values are copied since the hasher reuses its encoding buffer.
+type nodeBatch struct {
+	keys, values [][]byte
+}
+
+func (b *nodeBatch) Put(key, value []byte) error {
+	b.keys = append(b.keys, common.CopyBytes(key))
+	b.values = append(b.values, common.CopyBytes(value))
+	return nil
+}
+
+// flush writes the collected nodes to db in insertion order.
+func (b *nodeBatch) flush(db DatabaseWriter) error {
+	for i, key := range b.keys {
+		if err := db.Put(key, b.values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
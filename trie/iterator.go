@@ -22,6 +22,7 @@ import (
 	"errors"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 // Iterator is a key-value trie iterator that traverses a Trie.
@@ -80,6 +81,12 @@ type NodeIterator interface {
 	Leaf() bool
 	LeafBlob() []byte
 	LeafKey() []byte
+
+	// LeafProof returns the merkle proof of the leaf, as the RLP encoded trie nodes
+	// on the path to it, starting with the root. The proof can be verified with
+	// VerifyProof after inserting the nodes into a database keyed by their hash.
+	// The method panics if the iterator is not positioned at a leaf.
+	LeafProof() [][]byte
 }
 
 // nodeIteratorState represents the iteration state at one particular node of the
@@ -157,6 +164,29 @@ func (it *nodeIterator) LeafKey() []byte {
 	panic("not at leaf")
 }
 
+func (it *nodeIterator) LeafProof() [][]byte {
+	if len(it.stack) > 0 {
+		if _, ok := it.stack[len(it.stack)-1].node.(valueNode); ok {
+			hasher := newHasher(0, 0, false)
+			defer returnHasherToPool(hasher)
+
+			proofs := make([][]byte, 0, len(it.stack))
+			for i, item := range it.stack[:len(it.stack)-1] {
+				// Gather the nodes stored by hash (and the root), embedded
+				// nodes are contained in their parents
+				node, _, _ := hasher.hashChildren(item.node, nil)
+				hashed, _ := hasher.store(node, nil, false)
+				if _, ok := hashed.(hashNode); ok || i == 0 {
+					enc, _ := rlp.EncodeToBytes(node)
+					proofs = append(proofs, enc)
+				}
+			}
+			return proofs
+		}
+	}
+	panic("not at leaf")
+}
+
 func (it *nodeIterator) Path() []byte {
 	return it.path
 }
@@ -369,6 +399,10 @@ func (it *differenceIterator) LeafKey() []byte {
 	return it.b.LeafKey()
 }
 
+func (it *differenceIterator) LeafProof() [][]byte {
+	return it.b.LeafProof()
+}
+
 func (it *differenceIterator) Path() []byte {
 	return it.b.Path()
 }
@@ -472,6 +506,10 @@ func (it *unionIterator) LeafKey() []byte {
 	return (*it.items)[0].LeafKey()
 }
 
+func (it *unionIterator) LeafProof() [][]byte {
+	return (*it.items)[0].LeafProof()
+}
+
 func (it *unionIterator) Path() []byte {
 	return (*it.items)[0].Path()
 }
@@ -18,10 +18,12 @@ package trie
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rlp"
 )
@@ -66,7 +68,7 @@ func (t *Trie) Prove(key []byte, fromLevel uint, proofDb DatabaseWriter) error {
 			panic(fmt.Sprintf("%T: invalid node: %v", tn, tn))
 		}
 	}
-	hasher := newHasher(0, 0)
+	hasher := newHasher(0, 0, false)
 	for i, n := range nodes {
 		// Don't bother checking for errors here since hasher panics
 		// if encoding doesn't work and we're not writing to any database.
@@ -105,7 +107,7 @@ func VerifyProof(rootHash common.Hash, key []byte, proofDb DatabaseReader) (valu
 		if err != nil {
 			return nil, fmt.Errorf("bad proof node %d: %v", i, err), i
 		}
-		keyrest, cld := get(n, key)
+		keyrest, cld := get(n, key, true)
 		switch cld := cld.(type) {
 		case nil:
 			// The trie doesn't contain the key.
@@ -119,7 +121,10 @@ func VerifyProof(rootHash common.Hash, key []byte, proofDb DatabaseReader) (valu
 	}
 }
 
-func get(tn node, key []byte) ([]byte, node) {
+// get returns the child of tn on the path to key, along with the remainder of the
+// key. If skipResolved is set, it descends through resolved nodes until reaching
+// a hash or value node, otherwise it stops after a single step.
+func get(tn node, key []byte, skipResolved bool) ([]byte, node) {
 	for {
 		switch n := tn.(type) {
 		case *shortNode:
@@ -128,9 +133,15 @@ func get(tn node, key []byte) ([]byte, node) {
 			}
 			tn = n.Val
 			key = key[len(n.Key):]
+			if !skipResolved {
+				return key, tn
+			}
 		case *fullNode:
 			tn = n.Children[key[0]]
 			key = key[1:]
+			if !skipResolved {
+				return key, tn
+			}
 		case hashNode:
 			return key, n
 		case nil:
@@ -142,3 +153,378 @@ func get(tn node, key []byte) ([]byte, node) {
 		}
 	}
 }
+
+// proofToPath resolves the path to key from the nodes of a merkle proof, linking
+// them into a partial trie rooted at root (or at the proof's root node if nil).
+// Nodes off the path are left as hash nodes. It returns the root along with the
+// value at key, if the proof contains it. If allowNonExistent is set, the proof
+// may prove the absence of the key instead.
+func proofToPath(rootHash common.Hash, root node, key []byte, proofDb DatabaseReader, allowNonExistent bool) (node, []byte, error) {
+	// resolveNode retrieves and decodes a trie node from the proof
+	resolveNode := func(hash []byte) (node, error) {
+		buf, _ := proofDb.Get(hash)
+		if buf == nil {
+			return nil, fmt.Errorf("proof node (hash %064x) missing", hash)
+		}
+		n, err := decodeNode(hash, buf, 0)
+		if err != nil {
+			return nil, fmt.Errorf("bad proof node: %v", err)
+		}
+		return n, nil
+	}
+	// The root node must be part of the proof
+	if root == nil {
+		n, err := resolveNode(rootHash[:])
+		if err != nil {
+			return nil, nil, err
+		}
+		root = n
+	}
+	var (
+		err           error
+		child, parent node
+		keyrest       []byte
+		value         []byte
+	)
+	key, parent = keybytesToHex(key), root
+	for {
+		keyrest, child = get(parent, key, false)
+		if _, ok := parent.(*shortNode); ok {
+			if _, ok := child.(*shortNode); ok {
+				// Valid tries never nest short nodes, the rest of the proof code
+				// relies on it
+				return nil, nil, errors.New("invalid proof: short node under short node")
+			}
+		}
+		switch cld := child.(type) {
+		case nil:
+			// The trie doesn't contain the key. The resolved nodes are still
+			// proven correct, which is enough to prove a range.
+			if allowNonExistent {
+				return root, nil, nil
+			}
+			return nil, nil, errors.New("key not contained in trie")
+		case *shortNode, *fullNode:
+			// Already resolved by a previous path
+			key, parent = keyrest, child
+			continue
+		case hashNode:
+			if child, err = resolveNode(cld); err != nil {
+				return nil, nil, err
+			}
+		case valueNode:
+			value = cld
+		}
+		// Link the resolved child into its parent
+		switch pnode := parent.(type) {
+		case *shortNode:
+			if _, ok := child.(*shortNode); ok {
+				return nil, nil, errors.New("invalid proof: short node under short node")
+			}
+			pnode.Val = child
+		case *fullNode:
+			pnode.Children[key[0]] = child
+		default:
+			return nil, nil, fmt.Errorf("invalid proof: %T node on path", pnode)
+		}
+		if len(value) > 0 {
+			return root, value, nil
+		}
+		key, parent = keyrest, child
+	}
+}
+
+// unsetInternal removes all references to the nodes between the paths to the left
+// and right keys from a partial trie built from their proofs, so that the range
+// can be refilled from its leaves. The visited nodes are marked dirty as their
+// content changes. The left key must be smaller than the right key. It returns
+// whether the entire trie was within the range.
+func unsetInternal(n node, left []byte, right []byte) (bool, error) {
+	left, right = keybytesToHex(left), keybytesToHex(right)
+
+	// Step down to the fork point of the two paths. It is either a short node
+	// not matching one of the keys, or a full node where the paths diverge.
+	var (
+		pos    = 0
+		parent node
+
+		// Position of the keys relative to a forking short node's key
+		shortForkLeft, shortForkRight int
+	)
+findFork:
+	for {
+		switch rn := (n).(type) {
+		case *shortNode:
+			rn.flags = nodeFlag{dirty: true}
+
+			if len(left)-pos < len(rn.Key) {
+				shortForkLeft = bytes.Compare(left[pos:], rn.Key)
+			} else {
+				shortForkLeft = bytes.Compare(left[pos:pos+len(rn.Key)], rn.Key)
+			}
+			if len(right)-pos < len(rn.Key) {
+				shortForkRight = bytes.Compare(right[pos:], rn.Key)
+			} else {
+				shortForkRight = bytes.Compare(right[pos:pos+len(rn.Key)], rn.Key)
+			}
+			if shortForkLeft != 0 || shortForkRight != 0 {
+				break findFork
+			}
+			parent = n
+			n, pos = rn.Val, pos+len(rn.Key)
+
+		case *fullNode:
+			if pos >= len(left) || pos >= len(right) {
+				return false, errors.New("invalid proof: full node past the keys")
+			}
+			rn.flags = nodeFlag{dirty: true}
+
+			leftnode, rightnode := rn.Children[left[pos]], rn.Children[right[pos]]
+			if leftnode == nil || rightnode == nil || leftnode != rightnode {
+				break findFork
+			}
+			parent = n
+			n, pos = rn.Children[left[pos]], pos+1
+
+		default:
+			return false, fmt.Errorf("invalid proof: %T node on path", n)
+		}
+	}
+	switch rn := n.(type) {
+	case *shortNode:
+		// Both keys on the same side of the short node leave nothing in range
+		if (shortForkLeft == -1 && shortForkRight == -1) || (shortForkLeft == 1 && shortForkRight == 1) {
+			return false, errors.New("empty range")
+		}
+		// The short node is entirely within the range, drop it
+		if shortForkLeft != 0 && shortForkRight != 0 {
+			if parent == nil {
+				return true, nil
+			}
+			return false, unlinkChild(parent, left, pos)
+		}
+		// Only one of the keys matches the short node, unset its side
+		if shortForkRight != 0 {
+			if _, ok := rn.Val.(valueNode); ok {
+				if parent == nil {
+					return true, nil
+				}
+				return false, unlinkChild(parent, left, pos)
+			}
+			return false, unset(rn, rn.Val, left[pos:], len(rn.Key), false)
+		}
+		if shortForkLeft != 0 {
+			if _, ok := rn.Val.(valueNode); ok {
+				if parent == nil {
+					return true, nil
+				}
+				return false, unlinkChild(parent, right, pos)
+			}
+			return false, unset(rn, rn.Val, right[pos:], len(rn.Key), true)
+		}
+		return false, nil
+
+	case *fullNode:
+		// Drop the children between the paths, then unset along both of them
+		for i := left[pos] + 1; i < right[pos]; i++ {
+			rn.Children[i] = nil
+		}
+		if err := unset(rn, rn.Children[left[pos]], left[pos:], 1, false); err != nil {
+			return false, err
+		}
+		if err := unset(rn, rn.Children[right[pos]], right[pos:], 1, true); err != nil {
+			return false, err
+		}
+		return false, nil
+
+	default:
+		return false, fmt.Errorf("invalid proof: %T node at fork", n)
+	}
+}
+
+// unset removes the references to all nodes on one side of the path to key below
+// the fork point: the ones left of it if removeLeft is set (path of the right
+// key), or the ones right of it otherwise (path of the left key). If the key
+// doesn't exist, the branch diverging from its path is dropped if within range.
+func unset(parent node, child node, key []byte, pos int, removeLeft bool) error {
+	switch cld := child.(type) {
+	case *fullNode:
+		if pos >= len(key) {
+			return errors.New("invalid proof: full node past the key")
+		}
+		if removeLeft {
+			for i := 0; i < int(key[pos]); i++ {
+				cld.Children[i] = nil
+			}
+		} else {
+			for i := key[pos] + 1; i < 16; i++ {
+				cld.Children[i] = nil
+			}
+		}
+		cld.flags = nodeFlag{dirty: true}
+		return unset(cld, cld.Children[key[pos]], key, pos+1, removeLeft)
+
+	case *shortNode:
+		if len(key[pos:]) < len(cld.Key) || !bytes.Equal(cld.Key, key[pos:pos+len(cld.Key)]) {
+			// The path doesn't exist, drop the diverging branch if within range
+			if removeLeft {
+				if bytes.Compare(cld.Key, key[pos:]) < 0 {
+					return unlinkChild(parent, key, pos)
+				}
+			} else {
+				if bytes.Compare(cld.Key, key[pos:]) > 0 {
+					return unlinkChild(parent, key, pos)
+				}
+			}
+			return nil
+		}
+		if _, ok := cld.Val.(valueNode); ok {
+			return unlinkChild(parent, key, pos)
+		}
+		cld.flags = nodeFlag{dirty: true}
+		return unset(cld, cld.Val, key, pos+len(cld.Key), removeLeft)
+
+	case nil:
+		// The path ends in an empty child of a full node
+		return nil
+
+	default:
+		return fmt.Errorf("invalid proof: %T node on path", child)
+	}
+}
+
+// unlinkChild removes the child a path leaves a full node through: the one at the
+// nibble of key preceding pos. Proofs with any other kind of parent are malformed.
+func unlinkChild(parent node, key []byte, pos int) error {
+	fn, ok := parent.(*fullNode)
+	if !ok || pos < 1 || pos > len(key) {
+		return fmt.Errorf("invalid proof: %T node as parent", parent)
+	}
+	fn.Children[key[pos-1]] = nil
+	return nil
+}
+
+// hasRightElement returns whether the partial trie contains any elements right
+// of the path to key. The path must be fully resolved, the key may not exist.
+func hasRightElement(node node, key []byte) bool {
+	pos, key := 0, keybytesToHex(key)
+	for node != nil {
+		switch rn := node.(type) {
+		case *fullNode:
+			for i := key[pos] + 1; i < 16; i++ {
+				if rn.Children[i] != nil {
+					return true
+				}
+			}
+			node, pos = rn.Children[key[pos]], pos+1
+		case *shortNode:
+			if len(key)-pos < len(rn.Key) || !bytes.Equal(rn.Key, key[pos:pos+len(rn.Key)]) {
+				return bytes.Compare(rn.Key, key[pos:]) > 0
+			}
+			node, pos = rn.Val, pos+len(rn.Key)
+		case valueNode:
+			return false
+		default:
+			panic(fmt.Sprintf("%T: invalid node: %v", node, node))
+		}
+	}
+	return false
+}
+
+// VerifyRangeProof checks whether the given leaves are exactly the content of the
+// trie with the given root hash between firstKey and lastKey (both inclusive).
+// The proof must contain the merkle proofs of both edge keys, which don't need to
+// exist in the trie (see Prove). The keys must be sorted in ascending order.
+//
+// If the proof is nil, the leaves must make up the entire trie. It returns
+// whether the trie contains further elements right of the range.
+func VerifyRangeProof(rootHash common.Hash, firstKey []byte, lastKey []byte, keys [][]byte, values [][]byte, proofDb DatabaseReader) (bool, error) {
+	if len(keys) != len(values) {
+		return false, fmt.Errorf("inconsistent proof data, keys: %d, values: %d", len(keys), len(values))
+	}
+	for i := 0; i < len(keys)-1; i++ {
+		if bytes.Compare(keys[i], keys[i+1]) >= 0 {
+			return false, errors.New("range is not monotonically increasing")
+		}
+	}
+	for i, value := range values {
+		if len(value) == 0 {
+			return false, fmt.Errorf("empty value for key %x", keys[i])
+		}
+	}
+	// Without edge proofs, the leaves must make up the entire trie
+	if proofDb == nil {
+		tr := new(Trie)
+		tr.db, _ = ethdb.NewMemDatabase()
+		for i, key := range keys {
+			tr.Update(key, values[i])
+		}
+		if have := tr.Hash(); have != rootHash {
+			return false, fmt.Errorf("invalid proof, want hash %x, got %x", rootHash, have)
+		}
+		return false, nil
+	}
+	// Without leaves, the proof must show that the range is empty
+	if len(keys) == 0 {
+		root, value, err := proofToPath(rootHash, nil, firstKey, proofDb, true)
+		if err != nil {
+			return false, err
+		}
+		if value != nil || hasRightElement(root, firstKey) {
+			return false, errors.New("more entries available")
+		}
+		return false, nil
+	}
+	// A single leaf with identical edge keys can be verified by a plain proof
+	if len(keys) == 1 && bytes.Equal(firstKey, lastKey) {
+		root, value, err := proofToPath(rootHash, nil, firstKey, proofDb, false)
+		if err != nil {
+			return false, err
+		}
+		if !bytes.Equal(firstKey, keys[0]) {
+			return false, errors.New("correct proof but invalid key")
+		}
+		if !bytes.Equal(value, values[0]) {
+			return false, errors.New("correct proof but invalid data")
+		}
+		return hasRightElement(root, firstKey), nil
+	}
+	// Otherwise both edge paths are needed, with the leaves in between them
+	if bytes.Compare(firstKey, lastKey) >= 0 {
+		return false, errors.New("invalid edge keys")
+	}
+	if len(firstKey) != len(lastKey) {
+		return false, errors.New("inconsistent edge keys")
+	}
+	if bytes.Compare(keys[0], firstKey) < 0 || bytes.Compare(keys[len(keys)-1], lastKey) > 0 {
+		return false, errors.New("leaves outside of the edge keys")
+	}
+	root, _, err := proofToPath(rootHash, nil, firstKey, proofDb, true)
+	if err != nil {
+		return false, err
+	}
+	root, _, err = proofToPath(rootHash, root, lastKey, proofDb, true)
+	if err != nil {
+		return false, err
+	}
+	// Drop everything between the edge paths and refill it from the leaves. The
+	// result must match the original trie if the leaves are complete.
+	empty, err := unsetInternal(root, firstKey, lastKey)
+	if err != nil {
+		return false, err
+	}
+	tr := &Trie{root: root}
+	if empty {
+		tr.root = nil
+	}
+	tr.db, _ = ethdb.NewMemDatabase()
+	for i, key := range keys {
+		if err := tr.TryUpdate(key, values[i]); err != nil {
+			return false, fmt.Errorf("invalid proof: %v", err)
+		}
+	}
+	if have := tr.Hash(); have != rootHash {
+		return false, fmt.Errorf("invalid proof, want hash %x, got %x", rootHash, have)
+	}
+	return hasRightElement(tr.root, keys[len(keys)-1]), nil
+}
@@ -20,12 +20,14 @@ import (
 	"bytes"
 	crand "crypto/rand"
 	mrand "math/rand"
+	"sort"
 	"testing"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 func init() {
@@ -89,6 +91,205 @@ func TestVerifyBadProof(t *testing.T) {
 }
 
 // mutateByte changes one byte in b.
+// Tests that the proofs emitted by the node iterator for the visited leaves are
+// valid merkle proofs.
+func TestLeafProof(t *testing.T) {
+	trie, vals := randomTrie(500)
+	root := trie.Hash()
+
+	leaves := 0
+	for it := trie.NodeIterator(nil); it.Next(true); {
+		if !it.Leaf() {
+			continue
+		}
+		leaves++
+
+		proofs, _ := ethdb.NewMemDatabase()
+		for _, enc := range it.LeafProof() {
+			proofs.Put(crypto.Keccak256(enc), enc)
+		}
+		key := it.LeafKey()
+		val, err, _ := VerifyProof(root, key, proofs)
+		if err != nil {
+			t.Fatalf("VerifyProof error for key %x: %v", key, err)
+		}
+		if !bytes.Equal(val, vals[string(key)].v) {
+			t.Fatalf("VerifyProof returned wrong value for key %x: got %x, want %x", key, val, vals[string(key)].v)
+		}
+	}
+	if leaves != len(vals) {
+		t.Errorf("leaf count mismatch: have %d, want %d", leaves, len(vals))
+	}
+}
+
+// Tests that ranges of leaves are proven complete by the proofs of their edges,
+// existent or not.
+func TestRangeProof(t *testing.T) {
+	trie, vals := randomTrie(500)
+	root := trie.Hash()
+	entries := sortedEntries(vals)
+
+	for i := 0; i < 500; i++ {
+		start := mrand.Intn(len(entries))
+		end := start + 1 + mrand.Intn(len(entries)-start)
+
+		// Prove the edge keys themselves, or keys right next to them
+		first, last := entries[start].k, entries[end-1].k
+		if i%2 == 1 {
+			first, last = decreaseKey(common.CopyBytes(first)), increaseKey(common.CopyBytes(last))
+			if bytes.Compare(first, entries[start].k) > 0 || bytes.Compare(last, entries[end-1].k) < 0 {
+				continue // The all-zero and all-0xff keys wrap around
+			}
+			if (start > 0 && bytes.Compare(first, entries[start-1].k) <= 0) || (end < len(entries) && bytes.Compare(last, entries[end].k) >= 0) {
+				continue
+			}
+		}
+		proofs, _ := ethdb.NewMemDatabase()
+		if err := trie.Prove(first, 0, proofs); err != nil {
+			t.Fatalf("failed to prove the first node: %v", err)
+		}
+		if err := trie.Prove(last, 0, proofs); err != nil {
+			t.Fatalf("failed to prove the last node: %v", err)
+		}
+		var keys, values [][]byte
+		for _, entry := range entries[start:end] {
+			keys = append(keys, entry.k)
+			values = append(values, entry.v)
+		}
+		more, err := VerifyRangeProof(root, first, last, keys, values, proofs)
+		if err != nil {
+			t.Fatalf("range [%d, %d): failed to verify proof: %v", start, end, err)
+		}
+		if want := end < len(entries); more != want {
+			t.Fatalf("range [%d, %d): more elements mismatch: have %v, want %v", start, end, more, want)
+		}
+	}
+	// The entire trie needs no proof at all
+	var keys, values [][]byte
+	for _, entry := range entries {
+		keys = append(keys, entry.k)
+		values = append(values, entry.v)
+	}
+	if _, err := VerifyRangeProof(root, nil, nil, keys, values, nil); err != nil {
+		t.Fatalf("failed to verify the entire trie: %v", err)
+	}
+}
+
+// Tests that incomplete or tampered ranges are rejected.
+func TestBadRangeProof(t *testing.T) {
+	trie, vals := randomTrie(500)
+	root := trie.Hash()
+	entries := sortedEntries(vals)
+
+	for i := 0; i < 500; i++ {
+		start := mrand.Intn(len(entries) - 2)
+		end := start + 3 + mrand.Intn(len(entries)-start-2)
+
+		proofs, _ := ethdb.NewMemDatabase()
+		trie.Prove(entries[start].k, 0, proofs)
+		trie.Prove(entries[end-1].k, 0, proofs)
+
+		var keys, values [][]byte
+		for _, entry := range entries[start:end] {
+			keys = append(keys, entry.k)
+			values = append(values, common.CopyBytes(entry.v))
+		}
+		index := 1 + mrand.Intn(len(keys)-2)
+		switch mrand.Intn(2) {
+		case 0:
+			// Drop a leaf within the range
+			keys = append(keys[:index:index], keys[index+1:]...)
+			values = append(values[:index:index], values[index+1:]...)
+		case 1:
+			// Modify the value of a leaf
+			values[index] = randBytes(20)
+		}
+		if _, err := VerifyRangeProof(root, entries[start].k, entries[end-1].k, keys, values, proofs); err == nil {
+			t.Fatalf("range [%d, %d): tampered range accepted", start, end)
+		}
+	}
+
+	// Proofs not structured like a trie are rejected: here a short node links to
+	// another short node through a hash
+	key := bytes.Repeat([]byte{0x11}, 32)
+	hexkey := keybytesToHex(key)
+
+	leaf, _ := rlp.EncodeToBytes([]interface{}{hexToCompact(hexkey[2:]), bytes.Repeat([]byte{0xff}, 32)})
+	ext, _ := rlp.EncodeToBytes([]interface{}{hexToCompact(hexkey[:2]), crypto.Keccak256(leaf)})
+
+	proofs, _ := ethdb.NewMemDatabase()
+	proofs.Put(crypto.Keccak256(leaf), leaf)
+	proofs.Put(crypto.Keccak256(ext), ext)
+
+	last := increaseKey(common.CopyBytes(key))
+	if _, err := VerifyRangeProof(common.BytesToHash(crypto.Keccak256(ext)), key, last, [][]byte{key}, [][]byte{bytes.Repeat([]byte{0xff}, 32)}, proofs); err == nil {
+		t.Fatalf("malformed proof accepted")
+	}
+}
+
+// Tests that empty ranges are proven by the proof of a single edge.
+func TestEmptyRangeProof(t *testing.T) {
+	trie, vals := randomTrie(500)
+	root := trie.Hash()
+	entries := sortedEntries(vals)
+
+	// Nothing is right of the last element
+	last := increaseKey(common.CopyBytes(entries[len(entries)-1].k))
+	proofs, _ := ethdb.NewMemDatabase()
+	trie.Prove(last, 0, proofs)
+	if _, err := VerifyRangeProof(root, last, nil, nil, nil, proofs); err != nil {
+		t.Errorf("failed to verify empty range: %v", err)
+	}
+	// Elements are right of any key within the trie
+	first := decreaseKey(common.CopyBytes(entries[len(entries)/2].k))
+	proofs, _ = ethdb.NewMemDatabase()
+	trie.Prove(first, 0, proofs)
+	if _, err := VerifyRangeProof(root, first, nil, nil, nil, proofs); err == nil {
+		t.Errorf("non-empty range accepted as empty")
+	}
+}
+
+// sortedEntries returns the key-value pairs ordered by key.
+func sortedEntries(vals map[string]*kv) []*kv {
+	entries := make(kvsByKey, 0, len(vals))
+	for _, entry := range vals {
+		entries = append(entries, entry)
+	}
+	sort.Sort(entries)
+	return entries
+}
+
+// kvsByKey implements sort.Interface to order key-value pairs by key.
+type kvsByKey []*kv
+
+func (p kvsByKey) Len() int           { return len(p) }
+func (p kvsByKey) Less(i, j int) bool { return bytes.Compare(p[i].k, p[j].k) < 0 }
+func (p kvsByKey) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+// increaseKey returns the key incremented by one, treating it as a big endian
+// number.
+func increaseKey(key []byte) []byte {
+	for i := len(key) - 1; i >= 0; i-- {
+		key[i]++
+		if key[i] != 0x0 {
+			break
+		}
+	}
+	return key
+}
+
+// decreaseKey returns the key decremented by one, treating it as a big endian
+// number.
+func decreaseKey(key []byte) []byte {
+	for i := len(key) - 1; i >= 0; i-- {
+		key[i]--
+		if key[i] != 0xff {
+			break
+		}
+	}
+	return key
+}
+
 func mutateByte(b []byte) {
 	for r := mrand.Intn(len(b)); ; {
 		new := byte(mrand.Intn(255))
@@ -0,0 +1,373 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	memcacheHitMeter  = metrics.NewMeter("trie/memcache/hit")
+	memcacheMissMeter = metrics.NewMeter("trie/memcache/miss")
+)
+
+// LeafCallback is invoked for every value stored in a trie node inserted into a
+// NodeStore. It returns the hashes of any additional database entries the value
+// refers to (e.g. the storage trie root and code hash of an account), which are
+// then kept alive for as long as the node containing the value is.
+type LeafCallback func(value []byte) []common.Hash
+
+// NodeStore is an intermediate write layer between the trie data structures and
+// the disk database. Trie nodes committed into it are held in memory along with
+// reference counts, so that tries belonging to stale states can be garbage
+// collected without ever touching the disk. Tries which need to be persisted are
+// flushed explicitly via Commit.
+//
+// NodeStore implements Database, so tries can be opened on top of it and will
+// see both the in-memory and the persisted nodes.
+//
+// Secure trie key preimages are only retained if preimage recording is enabled,
+// in which case they are buffered in memory and flushed along with the nodes.
+type NodeStore struct {
+	diskdb ethdb.Database // Persistent storage for matured trie nodes
+	onleaf LeafCallback   // Resolver for references hidden in leaf values
+
+	nodes map[common.Hash]*cachedNode // Data and references of in-memory nodes
+	size  common.StorageSize          // Storage size of the in-memory nodes
+
+	preimages     map[string][]byte  // Trie key preimages pending a flush (nil = not recorded)
+	preimagesSize common.StorageSize // Storage size of the pending preimages
+
+	gctime  time.Duration      // Time spent on garbage collection since last commit
+	gcnodes uint64             // Nodes garbage collected since last commit
+	gcsize  common.StorageSize // Data storage garbage collected since last commit
+
+	lock sync.RWMutex
+}
+
+// cachedNode is a trie node held in memory by a NodeStore.
+type cachedNode struct {
+	blob     []byte        // Encoded node (or other referenced blob)
+	parents  int           // Number of live nodes and external references to this one
+	children []common.Hash // Entries referenced by this node
+}
+
+// NewNodeStore creates an in-memory trie node store on top of diskdb. The optional
+// onleaf callback resolves references embedded in trie leaf values. If preimages
+// is set, the hashed key preimages of secure tries are recorded too.
+func NewNodeStore(diskdb ethdb.Database, onleaf LeafCallback, preimages bool) *NodeStore {
+	store := &NodeStore{
+		diskdb: diskdb,
+		onleaf: onleaf,
+		nodes:  make(map[common.Hash]*cachedNode),
+	}
+	if preimages {
+		store.preimages = make(map[string][]byte)
+	}
+	return store
+}
+
+// DiskDB retrieves the persistent database backing the node store.
+func (s *NodeStore) DiskDB() ethdb.Database {
+	return s.diskdb
+}
+
+// Put inserts a trie node (or a blob referenced from a trie leaf) into memory.
+// Entries not keyed by a hash are trie key preimages, which are not subject to
+// garbage collection: they are either buffered until the next flush or dropped,
+// depending on whether preimage recording is enabled.
+func (s *NodeStore) Put(key, value []byte) error {
+	if len(key) != common.HashLength {
+		return s.putPreimage(key, value)
+	}
+	hash := common.BytesToHash(key)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.nodes[hash]; ok {
+		return nil
+	}
+	// The trie reuses value across calls, keep a private copy
+	blob := common.CopyBytes(value)
+	entry := &cachedNode{blob: blob, children: s.references(hash, blob)}
+	for _, child := range entry.children {
+		if c := s.nodes[child]; c != nil {
+			c.parents++
+		}
+	}
+	s.nodes[hash] = entry
+	s.size += common.StorageSize(common.HashLength + len(blob))
+	return nil
+}
+
+// putPreimage buffers a trie key preimage until the next flush, if recording is
+// enabled.
+func (s *NodeStore) putPreimage(key, value []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.preimages == nil {
+		return nil
+	}
+	if _, ok := s.preimages[string(key)]; ok {
+		return nil
+	}
+	s.preimages[string(key)] = common.CopyBytes(value)
+	s.preimagesSize += common.StorageSize(len(key) + len(value))
+	return nil
+}
+
+// references decodes blob as a trie node and gathers the hashes of all the
+// entries it refers to. Blobs which are not trie nodes have no references.
+func (s *NodeStore) references(hash common.Hash, blob []byte) []common.Hash {
+	n, err := decodeNode(hash[:], blob, 0)
+	if err != nil {
+		return nil
+	}
+	return s.gatherChildren(n, nil)
+}
+
+func (s *NodeStore) gatherChildren(n node, refs []common.Hash) []common.Hash {
+	switch n := n.(type) {
+	case *shortNode:
+		return s.gatherChildren(n.Val, refs)
+	case *fullNode:
+		for _, child := range n.Children {
+			if child != nil {
+				refs = s.gatherChildren(child, refs)
+			}
+		}
+		return refs
+	case hashNode:
+		return append(refs, common.BytesToHash(n))
+	case valueNode:
+		if s.onleaf != nil {
+			refs = append(refs, s.onleaf(n)...)
+		}
+		return refs
+	default:
+		return refs
+	}
+}
+
+// Get retrieves a trie node from memory, or from disk if it was already flushed
+// (or never tracked by the store).
+func (s *NodeStore) Get(key []byte) ([]byte, error) {
+	s.lock.RLock()
+	if len(key) == common.HashLength {
+		if node := s.nodes[common.BytesToHash(key)]; node != nil {
+			s.lock.RUnlock()
+			memcacheHitMeter.Mark(1)
+			return node.blob, nil
+		}
+		memcacheMissMeter.Mark(1)
+	} else if preimage, ok := s.preimages[string(key)]; ok {
+		s.lock.RUnlock()
+		return preimage, nil
+	}
+	s.lock.RUnlock()
+
+	return s.diskdb.Get(key)
+}
+
+// Has reports whether a trie node is available either in memory or on disk.
+func (s *NodeStore) Has(key []byte) (bool, error) {
+	if len(key) == common.HashLength {
+		s.lock.RLock()
+		_, ok := s.nodes[common.BytesToHash(key)]
+		s.lock.RUnlock()
+
+		if ok {
+			return true, nil
+		}
+	}
+	return s.diskdb.Has(key)
+}
+
+// Size returns the current storage size of the in-memory trie nodes, including
+// any trie key preimages pending a flush.
+func (s *NodeStore) Size() common.StorageSize {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.size + s.preimagesSize
+}
+
+// PreimagesSize returns the storage size of the trie key preimages pending a
+// flush to disk.
+func (s *NodeStore) PreimagesSize() common.StorageSize {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.preimagesSize
+}
+
+// Nodes returns the number of trie nodes held in memory.
+func (s *NodeStore) Nodes() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return len(s.nodes)
+}
+
+// Reference adds an external reference to the trie rooted at root, preventing it
+// from being garbage collected. It is a no-op if the root is not held in memory.
+func (s *NodeStore) Reference(root common.Hash) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if node := s.nodes[root]; node != nil {
+		node.parents++
+	}
+}
+
+// Dereference removes an external reference from the trie rooted at root. Any
+// in-memory node whose reference count drops to zero is deleted, recursively
+// releasing its own children.
+func (s *NodeStore) Dereference(root common.Hash) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	nodes, storage, start := len(s.nodes), s.size, time.Now()
+	s.dereference(root)
+
+	s.gcnodes += uint64(nodes - len(s.nodes))
+	s.gcsize += storage - s.size
+	s.gctime += time.Since(start)
+
+	log.Debug("Dereferenced trie from memory database", "nodes", nodes-len(s.nodes), "size", storage-s.size, "time", time.Since(start),
+		"gcnodes", s.gcnodes, "gcsize", s.gcsize, "gctime", s.gctime, "livenodes", len(s.nodes), "livesize", s.size)
+}
+
+func (s *NodeStore) dereference(hash common.Hash) {
+	node := s.nodes[hash]
+	if node == nil {
+		return
+	}
+	if node.parents > 0 {
+		node.parents--
+	}
+	if node.parents == 0 {
+		delete(s.nodes, hash)
+		s.size -= common.StorageSize(common.HashLength + len(node.blob))
+
+		for _, child := range node.children {
+			s.dereference(child)
+		}
+	}
+}
+
+// Commit writes all in-memory nodes reachable from root to disk and removes
+// them from the memory cache. Other tries sharing these nodes are unaffected,
+// as they will be served from disk from now on.
+func (s *NodeStore) Commit(root common.Hash) error {
+	start := time.Now()
+
+	// Flush the trie under a read lock so concurrent readers may proceed
+	s.lock.RLock()
+	var (
+		order   []common.Hash
+		seen    = make(map[common.Hash]struct{})
+		flushed = make([]string, 0, len(s.preimages))
+	)
+	s.gather(root, seen, &order)
+
+	batch := s.diskdb.NewBatch()
+	for key, preimage := range s.preimages {
+		flushed = append(flushed, key)
+		if err := batch.Put([]byte(key), preimage); err != nil {
+			s.lock.RUnlock()
+			return err
+		}
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				s.lock.RUnlock()
+				log.Error("Failed to write preimages to disk", "err", err)
+				return err
+			}
+			batch = s.diskdb.NewBatch()
+		}
+	}
+	for _, hash := range order {
+		if err := batch.Put(hash[:], s.nodes[hash].blob); err != nil {
+			s.lock.RUnlock()
+			return err
+		}
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				s.lock.RUnlock()
+				log.Error("Failed to write trie to disk", "err", err)
+				return err
+			}
+			batch = s.diskdb.NewBatch()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		s.lock.RUnlock()
+		log.Error("Failed to write trie to disk", "err", err)
+		return err
+	}
+	s.lock.RUnlock()
+
+	// Everything is on disk, drop the flushed nodes from memory
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	nodes, storage := len(s.nodes), s.size
+	for _, hash := range order {
+		if node := s.nodes[hash]; node != nil {
+			delete(s.nodes, hash)
+			s.size -= common.StorageSize(common.HashLength + len(node.blob))
+		}
+	}
+	// Only drop the preimages flushed, new ones may have arrived in between
+	preimagesSize := s.preimagesSize
+	for _, key := range flushed {
+		s.preimagesSize -= common.StorageSize(len(key) + len(s.preimages[key]))
+		delete(s.preimages, key)
+	}
+	log.Info("Persisted trie from memory database", "nodes", nodes-len(s.nodes), "size", storage-s.size, "preimages", len(flushed), "preimagesize", preimagesSize-s.preimagesSize,
+		"time", time.Since(start), "gcnodes", s.gcnodes, "gcsize", s.gcsize, "gctime", s.gctime, "livenodes", len(s.nodes), "livesize", s.size)
+
+	s.gcnodes, s.gcsize, s.gctime = 0, 0, 0
+	return nil
+}
+
+// gather collects the in-memory nodes reachable from hash, children before their
+// parents, so that an interrupted flush never leaves a parent on disk without its
+// children.
+func (s *NodeStore) gather(hash common.Hash, seen map[common.Hash]struct{}, order *[]common.Hash) {
+	node := s.nodes[hash]
+	if node == nil {
+		return
+	}
+	if _, ok := seen[hash]; ok {
+		return
+	}
+	seen[hash] = struct{}{}
+	for _, child := range node.children {
+		s.gather(child, seen, order)
+	}
+	*order = append(*order, hash)
+}
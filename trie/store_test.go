@@ -0,0 +1,182 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// Tests that stale tries are garbage collected from memory without ever reaching
+// the disk, while live ones remain accessible and can be flushed.
+func TestNodeStoreGarbageCollection(t *testing.T) {
+	diskdb, _ := ethdb.NewMemDatabase()
+	store := NewNodeStore(diskdb, nil, false)
+
+	// Create an initial trie and a modified version of it
+	trie, _ := New(common.Hash{}, store)
+	for i := 0; i < 100; i++ {
+		trie.Update([]byte(fmt.Sprintf("key-%03d", i)), []byte(fmt.Sprintf("val-%03d", i)))
+	}
+	oldRoot, _ := trie.Commit()
+	store.Reference(oldRoot)
+
+	for i := 0; i < 10; i++ {
+		trie.Update([]byte(fmt.Sprintf("key-%03d", i)), []byte(fmt.Sprintf("new-%03d", i)))
+	}
+	newRoot, _ := trie.Commit()
+	store.Reference(newRoot)
+
+	if len(diskdb.Keys()) != 0 {
+		t.Fatalf("trie nodes leaked to disk: have %d entries", len(diskdb.Keys()))
+	}
+	live := store.Nodes()
+
+	// Drop the old trie and ensure only its exclusive nodes were collected
+	store.Dereference(oldRoot)
+	if nodes := store.Nodes(); nodes >= live || nodes == 0 {
+		t.Fatalf("node count mismatch after dereference: have %d, had %d", nodes, live)
+	}
+	if _, err := New(oldRoot, store); err == nil {
+		t.Fatalf("dereferenced root still accessible")
+	}
+	checkStoreTrie(t, newRoot, store, 10)
+
+	// Flush the live trie and ensure it's fully available from disk
+	if err := store.Commit(newRoot); err != nil {
+		t.Fatalf("failed to commit trie: %v", err)
+	}
+	if nodes, size := store.Nodes(), store.Size(); nodes != 0 || size != 0 {
+		t.Fatalf("memory not released after commit: nodes %d, size %v", nodes, size)
+	}
+	checkStoreTrie(t, newRoot, diskdb, 10)
+}
+
+// Tests that shared nodes are only released when the last referencing trie is.
+func TestNodeStoreSharedNodes(t *testing.T) {
+	diskdb, _ := ethdb.NewMemDatabase()
+	store := NewNodeStore(diskdb, nil, false)
+
+	trie, _ := New(common.Hash{}, store)
+	for i := 0; i < 100; i++ {
+		trie.Update([]byte(fmt.Sprintf("key-%03d", i)), []byte(fmt.Sprintf("val-%03d", i)))
+	}
+	root, _ := trie.Commit()
+
+	// Reference the same root twice (e.g. two blocks with identical state)
+	store.Reference(root)
+	store.Reference(root)
+
+	store.Dereference(root)
+	checkStoreTrie(t, root, store, 0)
+
+	store.Dereference(root)
+	if nodes := store.Nodes(); nodes != 0 {
+		t.Fatalf("nodes left after final dereference: %d", nodes)
+	}
+}
+
+// Tests that entries referenced from trie leaves via the leaf callback are kept
+// alive and flushed together with the trie.
+func TestNodeStoreLeafReferences(t *testing.T) {
+	diskdb, _ := ethdb.NewMemDatabase()
+	store := NewNodeStore(diskdb, func(value []byte) []common.Hash {
+		return []common.Hash{common.BytesToHash(value)}
+	}, false)
+	blob := []byte("referenced blob")
+	hash := crypto.Keccak256Hash(blob)
+	store.Put(hash[:], blob)
+
+	trie, _ := New(common.Hash{}, store)
+	trie.Update([]byte("key"), hash[:])
+	root, _ := trie.Commit()
+	store.Reference(root)
+
+	if err := store.Commit(root); err != nil {
+		t.Fatalf("failed to commit trie: %v", err)
+	}
+	if have, err := diskdb.Get(hash[:]); err != nil || !bytes.Equal(have, blob) {
+		t.Fatalf("referenced blob not flushed: have %x, err %v", have, err)
+	}
+	if nodes := store.Nodes(); nodes != 0 {
+		t.Fatalf("nodes left after commit: %d", nodes)
+	}
+}
+
+// Tests that entries not keyed by hash (trie key preimages) bypass the node cache,
+// and are only retained, until the next flush, if preimage recording is enabled.
+func TestNodeStorePreimages(t *testing.T) {
+	// Without preimage recording, preimages should be discarded
+	diskdb, _ := ethdb.NewMemDatabase()
+	store := NewNodeStore(diskdb, nil, false)
+
+	store.Put([]byte("secure-key-preimage"), []byte("value"))
+	if have, _ := store.Get([]byte("secure-key-preimage")); have != nil {
+		t.Fatalf("preimage recorded while disabled: %x", have)
+	}
+	// With preimage recording, preimages should be buffered until flushed
+	diskdb, _ = ethdb.NewMemDatabase()
+	store = NewNodeStore(diskdb, nil, true)
+
+	store.Put([]byte("secure-key-preimage"), []byte("value"))
+	if nodes := store.Nodes(); nodes != 0 {
+		t.Fatalf("preimage cached as trie node")
+	}
+	if have, _ := store.Get([]byte("secure-key-preimage")); !bytes.Equal(have, []byte("value")) {
+		t.Fatalf("preimage not retrievable: %x", have)
+	}
+	if ok, _ := diskdb.Has([]byte("secure-key-preimage")); ok {
+		t.Fatalf("preimage written to disk before flush")
+	}
+	if size := store.PreimagesSize(); size != common.StorageSize(len("secure-key-preimage")+len("value")) {
+		t.Fatalf("preimage size mismatch: have %v", size)
+	}
+	if err := store.Commit(common.Hash{}); err != nil {
+		t.Fatalf("failed to flush store: %v", err)
+	}
+	if have, _ := diskdb.Get([]byte("secure-key-preimage")); !bytes.Equal(have, []byte("value")) {
+		t.Fatalf("preimage not written to disk: %x", have)
+	}
+	if size := store.PreimagesSize(); size != 0 {
+		t.Fatalf("preimage size not reset after flush: %v", size)
+	}
+}
+
+// checkStoreTrie verifies the content of a test trie whose first modified
+// entries were updated after creation.
+func checkStoreTrie(t *testing.T, root common.Hash, db Database, modified int) {
+	trie, err := New(root, db)
+	if err != nil {
+		t.Fatalf("failed to open trie %x: %v", root, err)
+	}
+	for i := 0; i < 100; i++ {
+		want := fmt.Sprintf("val-%03d", i)
+		if i < modified {
+			want = fmt.Sprintf("new-%03d", i)
+		}
+		if have, err := trie.TryGet([]byte(fmt.Sprintf("key-%03d", i))); err != nil {
+			t.Fatalf("key %d: failed to retrieve: %v", i, err)
+		} else if string(have) != want {
+			t.Fatalf("key %d: value mismatch: have %s, want %s", i, have, want)
+		}
+	}
+}
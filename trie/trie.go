@@ -27,6 +27,11 @@ import (
 	"github.com/rcrowley/go-metrics"
 )
 
+// parallelHashThreshold is the number of updates since the last hashing (or
+// commit) above which the children of the trie root are hashed (or committed)
+// concurrently. Below it, the goroutine overhead outweighs the gains.
+const parallelHashThreshold = 100
+
 var (
 	// This is the known root hash of an empty trie.
 	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
@@ -92,6 +97,11 @@ type Trie struct {
 	// new nodes are tagged with the current generation and unloaded
 	// when their generation is older than than cachegen-cachelimit.
 	cachegen, cachelimit uint16
+
+	// Number of updates since the last hashing and commit, deciding whether
+	// hashing or committing the trie is worth being parallelized.
+	unhashed    int
+	uncommitted int
 }
 
 // SetCacheLimit sets the number of 'cache generations' to keep.
@@ -213,6 +223,8 @@ func (t *Trie) Update(key, value []byte) {
 //
 // If a node was not found in the database, a MissingNodeError is returned.
 func (t *Trie) TryUpdate(key, value []byte) error {
+	t.unhashed++
+	t.uncommitted++
 	k := keybytesToHex(key)
 	if len(value) != 0 {
 		_, n, err := t.insert(t.root, nil, k, valueNode(value))
@@ -309,6 +321,8 @@ func (t *Trie) Delete(key []byte) {
 // TryDelete removes any existing value for key from the trie.
 // If a node was not found in the database, a MissingNodeError is returned.
 func (t *Trie) TryDelete(key []byte) error {
+	t.unhashed++
+	t.uncommitted++
 	k := keybytesToHex(key)
 	_, n, err := t.delete(t.root, nil, k)
 	if err != nil {
@@ -500,7 +514,17 @@ func (t *Trie) hashRoot(db DatabaseWriter) (node, node, error) {
 	if t.root == nil {
 		return hashNode(emptyRoot.Bytes()), nil, nil
 	}
-	h := newHasher(t.cachegen, t.cachelimit)
+	// Hashing beforehand doesn't make committing cheaper, the nodes still have to
+	// be stored, so commits are parallelized on their own count
+	updates := t.unhashed
+	if db != nil {
+		updates = t.uncommitted
+		t.uncommitted = 0
+	}
+	t.unhashed = 0
+
+	h := newHasher(t.cachegen, t.cachelimit, updates >= parallelHashThreshold)
 	defer returnHasherToPool(h)
+
 	return h.hash(t.root, db, true)
 }
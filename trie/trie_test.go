@@ -504,6 +504,61 @@ func TestRandom(t *testing.T) {
 	}
 }
 
+// Tests that hashing and committing a trie in parallel yields the same root and
+// stores the same nodes as doing it sequentially.
+func TestParallelHash(t *testing.T) {
+	for _, count := range []int{1, 16, 1000} {
+		sequential, parallel := makeAccountTrie(count, false), makeAccountTrie(count, true)
+		if have, want := parallel.Hash(), sequential.Hash(); have != want {
+			t.Errorf("%d accounts: hash mismatch: have %x, want %x", count, have, want)
+		}
+		sequential, parallel = makeAccountTrie(count, false), makeAccountTrie(count, true)
+		seqdb, _ := ethdb.NewMemDatabase()
+		pardb, _ := ethdb.NewMemDatabase()
+
+		want, _ := sequential.CommitTo(seqdb)
+		have, err := parallel.CommitTo(pardb)
+		if err != nil {
+			t.Fatalf("%d accounts: parallel commit failed: %v", count, err)
+		}
+		if have != want {
+			t.Errorf("%d accounts: commit hash mismatch: have %x, want %x", count, have, want)
+		}
+		if have, want := len(pardb.Keys()), len(seqdb.Keys()); have != want {
+			t.Errorf("%d accounts: stored node count mismatch: have %d, want %d", count, have, want)
+		}
+		for _, key := range seqdb.Keys() {
+			if blob, err := pardb.Get(key); err != nil {
+				t.Errorf("%d accounts: node %x missing", count, key)
+			} else if want, _ := seqdb.Get(key); !bytes.Equal(blob, want) {
+				t.Errorf("%d accounts: node %x mismatch: have %x, want %x", count, key, blob, want)
+			}
+		}
+	}
+}
+
+// Tests that hashing a trie doesn't prevent committing it in parallel.
+func TestParallelCommitAfterHash(t *testing.T) {
+	trie := newEmpty()
+	for i := 0; i < parallelHashThreshold; i++ {
+		trie.Update(crypto.Keccak256([]byte{byte(i)}), []byte{byte(i)})
+	}
+	trie.Hash()
+	if trie.unhashed != 0 {
+		t.Errorf("unhashed update count not reset by hashing: %d", trie.unhashed)
+	}
+	if trie.uncommitted != parallelHashThreshold {
+		t.Errorf("uncommitted update count mismatch after hashing: have %d, want %d", trie.uncommitted, parallelHashThreshold)
+	}
+	db, _ := ethdb.NewMemDatabase()
+	if _, err := trie.CommitTo(db); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+	if trie.uncommitted != 0 {
+		t.Errorf("uncommitted update count not reset by committing: %d", trie.uncommitted)
+	}
+}
+
 func BenchmarkGet(b *testing.B)      { benchGet(b, false) }
 func BenchmarkGetDB(b *testing.B)    { benchGet(b, true) }
 func BenchmarkUpdateBE(b *testing.B) { benchUpdate(b, binary.BigEndian) }
@@ -554,12 +609,52 @@ func benchUpdate(b *testing.B, e binary.ByteOrder) *Trie {
 // we cannot use b.N as the number of hashing rouns, since all rounds apart from
 // the first one will be NOOP. As such, we'll use b.N as the number of account to
 // insert into the trie before measuring the hashing.
-func BenchmarkHash(b *testing.B) {
-	// Make the random benchmark deterministic
+func BenchmarkHash(b *testing.B)         { benchHash(b, false) }
+func BenchmarkHashParallel(b *testing.B) { benchHash(b, true) }
+
+// Benchmarks the trie commit following the same pattern as the hashing above,
+// storing the nodes into an in-memory database.
+func BenchmarkCommit(b *testing.B)         { benchCommit(b, false) }
+func BenchmarkCommitParallel(b *testing.B) { benchCommit(b, true) }
+
+func benchHash(b *testing.B, parallel bool) {
+	trie := makeAccountTrie(b.N, parallel)
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	trie.Hash()
+}
+
+// Benchmarks hashing a trie and then committing it, as done when importing blocks.
+func BenchmarkHashCommit(b *testing.B)         { benchHashCommit(b, false) }
+func BenchmarkHashCommitParallel(b *testing.B) { benchHashCommit(b, true) }
+
+func benchCommit(b *testing.B, parallel bool) {
+	trie := makeAccountTrie(b.N, parallel)
+	db, _ := ethdb.NewMemDatabase()
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	trie.CommitTo(db)
+}
+
+func benchHashCommit(b *testing.B, parallel bool) {
+	trie := makeAccountTrie(b.N, parallel)
+	db, _ := ethdb.NewMemDatabase()
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	trie.Hash()
+	trie.CommitTo(db)
+}
+
+// makeAccountTrie creates a realistic account trie with the given number of
+// accounts, hashing it in parallel or sequentially.
+func makeAccountTrie(count int, parallel bool) *Trie {
+	// Make the random accounts deterministic
 	random := rand.New(rand.NewSource(0))
 
-	// Create a realistic account trie to hash
-	addresses := make([][20]byte, b.N)
+	addresses := make([][20]byte, count)
 	for i := 0; i < len(addresses); i++ {
 		for j := 0; j < len(addresses[i]); j++ {
 			addresses[i][j] = byte(random.Intn(256))
@@ -575,14 +670,17 @@ func BenchmarkHash(b *testing.B) {
 		)
 		accounts[i], _ = rlp.EncodeToBytes([]interface{}{nonce, balance, root, code})
 	}
-	// Insert the accounts into the trie and hash it
+	// Insert the accounts into the trie
 	trie := newEmpty()
 	for i := 0; i < len(addresses); i++ {
 		trie.Update(crypto.Keccak256(addresses[i][:]), accounts[i])
 	}
-	b.ResetTimer()
-	b.ReportAllocs()
-	trie.Hash()
+	if parallel {
+		trie.unhashed, trie.uncommitted = parallelHashThreshold, parallelHashThreshold
+	} else {
+		trie.unhashed, trie.uncommitted = 0, 0
+	}
+	return trie
 }
 
 func tempDB() (string, Database) {
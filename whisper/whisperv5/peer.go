@@ -163,6 +163,7 @@ func (p *Peer) broadcast() error {
 		}
 	}
 	if cnt > 0 {
+		envelopeSentMeter.Mark(int64(cnt))
 		log.Trace("broadcast", "num. messages", cnt)
 	}
 	return nil
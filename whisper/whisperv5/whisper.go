@@ -112,6 +112,13 @@ func New(cfg *Config) *Whisper {
 		Version: uint(ProtocolVersion),
 		Length:  NumberOfMessageCodes,
 		Run:     whisper.HandlePeer,
+		Priority: func(code uint64) p2p.MsgPriority {
+			// Envelopes are bulk traffic, keep them behind consensus messages
+			if code == messagesCode {
+				return p2p.PriorityBulk
+			}
+			return p2p.PriorityNormal
+		},
 		NodeInfo: func() interface{} {
 			return map[string]interface{}{
 				"version":        ProtocolVersionStr,
@@ -246,7 +253,11 @@ func (w *Whisper) SendP2PMessage(peerID []byte, envelope *Envelope) error {
 
 // SendP2PDirect sends a peer-to-peer message to a specific peer.
 func (w *Whisper) SendP2PDirect(peer *Peer, envelope *Envelope) error {
-	return p2p.Send(peer.ws, p2pCode, envelope)
+	if err := p2p.Send(peer.ws, p2pCode, envelope); err != nil {
+		return err
+	}
+	directSentMeter.Mark(1)
+	return nil
 }
 
 // NewKeyPair generates a new cryptographic identity for the client, and injects
@@ -610,8 +621,10 @@ func (wh *Whisper) runMessageLoop(p *Peer, rw p2p.MsgReadWriter) error {
 				log.Warn("failed to decode envelope, peer will be disconnected", "peer", p.peer.ID(), "err", err)
 				return errors.New("invalid envelope")
 			}
+			envelopeReceivedMeter.Mark(1)
 			cached, err := wh.add(&envelope)
 			if err != nil {
+				envelopeRejectedMeter.Mark(1)
 				log.Warn("bad envelope received, peer will be disconnected", "peer", p.peer.ID(), "err", err)
 				return errors.New("invalid envelope")
 			}
@@ -631,6 +644,7 @@ func (wh *Whisper) runMessageLoop(p *Peer, rw p2p.MsgReadWriter) error {
 					return errors.New("invalid direct message")
 				}
 
+				directReceivedMeter.Mark(1)
 				wh.traceIncomingDelivery(true, message.SentStatus, nil, &envelope, nil, nil)
 				wh.postEvent(&envelope, true)
 			}
@@ -718,9 +732,11 @@ func (wh *Whisper) add(envelope *Envelope) (bool, error) {
 
 	if alreadyCached {
 		log.Trace("whisper envelope already cached", "hash", envelope.Hash().Hex())
+		envelopeDuplicateMeter.Mark(1)
 		wh.traceIncomingDelivery(false, message.ResentStatus, nil, envelope, nil, nil)
 	} else {
 		log.Trace("cached whisper envelope", "hash", envelope.Hash().Hex())
+		envelopeCachedMeter.Mark(1)
 		wh.statsMu.Lock()
 		wh.stats.memoryUsed += envelope.size()
 		wh.statsMu.Unlock()
@@ -109,6 +109,13 @@ func New(cfg *Config) *Whisper {
 		Version: uint(ProtocolVersion),
 		Length:  NumberOfMessageCodes,
 		Run:     whisper.HandlePeer,
+		Priority: func(code uint64) p2p.MsgPriority {
+			// Envelopes are bulk traffic, keep them behind consensus messages
+			if code == messagesCode {
+				return p2p.PriorityBulk
+			}
+			return p2p.PriorityNormal
+		},
 		NodeInfo: func() interface{} {
 			return map[string]interface{}{
 				"version":        ProtocolVersionStr,